@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/db"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const adminPasswordEnv = "JJUDGE_ADMIN_PASSWORD"
+
+var (
+	adminUsername      string
+	adminEmail         string
+	adminName          string
+	adminResetPassword bool
+	adminTenant        string
+)
+
+// adminCmd groups account administration commands.
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Manage administrator accounts",
+}
+
+var adminCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an admin account or promote an existing user",
+	Long: `Creates an admin account with the given username and email. If the
+username already exists the account is promoted to admin and its password is
+left unchanged unless --reset-password is set.
+
+The password is read from $` + adminPasswordEnv + ` when set, otherwise it is
+prompted for on the terminal (or read as one line from stdin). The account
+belongs to the default tenant unless --tenant names another. Usage:
+
+	jjudge admin create --username root --email root@example.com
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		username := strings.TrimSpace(adminUsername)
+		email := strings.TrimSpace(adminEmail)
+		if username == "" {
+			return errors.New("--username is required")
+		}
+
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		if adminTenant != "" {
+			tenant, err := store.NewTenantRepository(dbConn).GetBySlug(ctx, adminTenant)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					return fmt.Errorf("tenant %s does not exist", adminTenant)
+				}
+				return fmt.Errorf("look up tenant failed: %w", err)
+			}
+			ctx = store.WithTenant(ctx, tenant.ID)
+		}
+
+		events := services.NewEventService(store.NewEventRepository(dbConn), nil, cfg.Events.Channel)
+		userService := services.NewUserService(store.NewUserRepository(dbConn), events)
+
+		user, err := userService.GetByUsername(ctx, username)
+		switch {
+		case err == nil:
+			user.Role = "admin"
+			if email != "" {
+				user.Email = email
+			}
+			if name := strings.TrimSpace(adminName); name != "" {
+				user.Name = name
+			}
+			if adminResetPassword {
+				hash, err := readAdminPasswordHash(cmd)
+				if err != nil {
+					return err
+				}
+				user.PasswordHash = hash
+			}
+			if _, err := userService.Update(ctx, user); err != nil {
+				return fmt.Errorf("promote user failed: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "promoted %s to admin\n", username)
+			return nil
+		case !errors.Is(err, store.ErrNotFound):
+			return fmt.Errorf("look up user failed: %w", err)
+		}
+
+		if email == "" {
+			return errors.New("--email is required when creating a new account")
+		}
+		name := strings.TrimSpace(adminName)
+		if name == "" {
+			name = username
+		}
+		hash, err := readAdminPasswordHash(cmd)
+		if err != nil {
+			return err
+		}
+		if _, err := userService.Create(ctx, types.User{
+			Username:     username,
+			Email:        email,
+			Name:         name,
+			Role:         "admin",
+			PasswordHash: hash,
+		}); err != nil {
+			return fmt.Errorf("create admin failed: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "created admin %s\n", username)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adminCmd)
+	adminCmd.AddCommand(adminCreateCmd)
+
+	adminCreateCmd.Flags().StringVar(&adminUsername, "username", "", "account username")
+	adminCreateCmd.Flags().StringVar(&adminEmail, "email", "", "account email (required for new accounts)")
+	adminCreateCmd.Flags().StringVar(&adminName, "name", "", "display name (default the username)")
+	adminCreateCmd.Flags().BoolVar(&adminResetPassword, "reset-password", false, "set a new password when promoting an existing user")
+	adminCreateCmd.Flags().StringVar(&adminTenant, "tenant", "", "slug of the tenant the account belongs to (default the default tenant)")
+}
+
+// readAdminPasswordHash reads the admin password from the environment or
+// stdin and returns its bcrypt hash.
+func readAdminPasswordHash(cmd *cobra.Command) (string, error) {
+	password := os.Getenv(adminPasswordEnv)
+	if password == "" {
+		var err error
+		password, err = promptPassword(cmd.ErrOrStderr(), cmd.InOrStdin())
+		if err != nil {
+			return "", err
+		}
+	}
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// promptPassword reads one line from in. When stdin is a terminal, echo is
+// switched off with stty for the duration of the prompt.
+func promptPassword(out io.Writer, in io.Reader) (string, error) {
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(out, "Password: ")
+		if err := stty("-echo"); err == nil {
+			defer func() {
+				_ = stty("echo")
+				fmt.Fprintln(out)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password failed: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func stty(arg string) error {
+	c := exec.Command("stty", arg)
+	c.Stdin = os.Stdin
+	return c.Run()
+}
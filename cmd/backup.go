@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/backup"
+	"github.com/jjudge-oj/apiserver/internal/db"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var backupOutput string
+
+// backupCmd represents the backup command.
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Snapshot the database and referenced storage objects",
+	Long: `Writes a tar.gz archive containing a pg_dump of the database, every
+storage object referenced by a testcase bundle, and a manifest with
+checksums. Requires pg_dump on PATH. Usage:
+
+	jjudge backup -o jjudge-backup.tar.gz
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		objectStorage, err := storage.NewFromConfig(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("init storage failed: %w", err)
+		}
+
+		output := backupOutput
+		if output == "" {
+			output = fmt.Sprintf("jjudge-backup-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+		}
+		out, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("create archive failed: %w", err)
+		}
+
+		b := backup.New(buildPostgresURL(cfg), objectStorage, store.NewProblemRepository(dbConn))
+		manifest, err := b.Create(ctx, out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(output)
+			return fmt.Errorf("backup failed: %w", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "wrote %s (%d objects)\n", output, len(manifest.Objects))
+		for _, key := range manifest.MissingObjects {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: object %s is referenced but missing from storage\n", key)
+		}
+		return nil
+	},
+}
+
+// restoreCmd represents the restore command.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Restore the database and storage objects from a backup archive",
+	Long: `Verifies a backup archive created by "jjudge backup" against its manifest,
+replaces the database contents and uploads the archived storage objects.
+Requires psql on PATH. Usage:
+
+	jjudge restore jjudge-backup.tar.gz
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		in, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("open archive failed: %w", err)
+		}
+		defer in.Close()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		objectStorage, err := storage.NewFromConfig(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("init storage failed: %w", err)
+		}
+		if err := objectStorage.EnsureBucket(ctx); err != nil {
+			return fmt.Errorf("ensure bucket failed: %w", err)
+		}
+
+		b := backup.New(buildPostgresURL(cfg), objectStorage, store.NewProblemRepository(dbConn))
+		manifest, err := b.Restore(ctx, in)
+		if err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "restored backup from %s (%d objects)\n", manifest.CreatedAt.Format(time.RFC3339), len(manifest.Objects))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "archive path (default jjudge-backup-<timestamp>.tar.gz)")
+}
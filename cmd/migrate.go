@@ -7,6 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -15,6 +21,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const migrationsDir = "internal/db/migrations"
+
+// migrationFilePattern matches golang-migrate file names such as
+// 000001_init.up.sql.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// migrationNamePattern restricts names passed to migrate create.
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
 // migrateCmd represents the migrate command.
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
@@ -25,13 +40,9 @@ var migrateUpCmd = &cobra.Command{
 	Use:   "up",
 	Short: "Apply all up migrations",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg := config.LoadConfig()
-		dsn := buildPostgresURL(cfg)
-
-		migrationsURL := "file://internal/db/migrations"
-		migrator, err := migrate.New(migrationsURL, dsn)
+		migrator, err := newMigrator()
 		if err != nil {
-			return fmt.Errorf("init migrator failed: %w", err)
+			return err
 		}
 		defer func() {
 			_, _ = migrator.Close()
@@ -47,9 +58,223 @@ var migrateUpCmd = &cobra.Command{
 	},
 }
 
+var migrateDownAll bool
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [n]",
+	Short: "Roll back the last n migrations (default 1)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps := 1
+		if len(args) == 1 {
+			if migrateDownAll {
+				return errors.New("--all cannot be combined with a step count")
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[0])
+			}
+			steps = n
+		}
+
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, _ = migrator.Close()
+		}()
+
+		if migrateDownAll {
+			err = migrator.Down()
+		} else {
+			err = migrator.Steps(-steps)
+		}
+		if err != nil {
+			if errors.Is(err, migrate.ErrNoChange) {
+				return nil
+			}
+			return fmt.Errorf("migrate down failed: %w", err)
+		}
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the applied schema version and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, _ = migrator.Close()
+		}()
+
+		var current uint
+		version, dirty, err := migrator.Version()
+		switch {
+		case errors.Is(err, migrate.ErrNilVersion):
+			fmt.Fprintln(cmd.OutOrStdout(), "version: none")
+		case err != nil:
+			return fmt.Errorf("read schema version failed: %w", err)
+		default:
+			current = version
+			state := "clean"
+			if dirty {
+				state = "dirty"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "version: %d (%s)\n", version, state)
+		}
+
+		migrations, err := listMigrations()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			status := "pending"
+			if m.version <= current {
+				status = "applied"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%06d  %-8s %s\n", m.version, status, m.name)
+		}
+		if dirty {
+			fmt.Fprintf(cmd.OutOrStdout(), "\nschema is dirty; fix the failed migration and run `migrate force %d` or `migrate force %d`\n", current, previousVersion(migrations, current))
+		}
+		return nil
+	},
+}
+
+var migrateForceCmd = &cobra.Command{
+	Use:   "force <version>",
+	Short: "Set the schema version without running migrations and clear the dirty flag",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+
+		migrator, err := newMigrator()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_, _ = migrator.Close()
+		}()
+
+		if err := migrator.Force(version); err != nil {
+			return fmt.Errorf("migrate force failed: %w", err)
+		}
+		return nil
+	},
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create empty up and down migration files with the next version",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := strings.ToLower(strings.TrimSpace(args[0]))
+		if !migrationNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", args[0])
+		}
+
+		migrations, err := listMigrations()
+		if err != nil {
+			return err
+		}
+		var next uint = 1
+		if len(migrations) > 0 {
+			next = migrations[len(migrations)-1].version + 1
+		}
+
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(migrationsDir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return fmt.Errorf("create migration file failed: %w", err)
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), path)
+		}
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateForceCmd)
+	migrateCmd.AddCommand(migrateCreateCmd)
+
+	migrateDownCmd.Flags().BoolVar(&migrateDownAll, "all", false, "Roll back every applied migration")
+}
+
+func newMigrator() (*migrate.Migrate, error) {
+	cfg := config.LoadConfig()
+	dsn := buildPostgresURL(cfg)
+
+	migrator, err := migrate.New("file://"+migrationsDir, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("init migrator failed: %w", err)
+	}
+	return migrator, nil
+}
+
+type migrationFile struct {
+	version uint
+	name    string
+}
+
+// listMigrations returns the migrations in migrationsDir ordered by version.
+// A version is listed once even though it has both up and down files.
+func listMigrations() ([]migrationFile, error) {
+	entries, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir failed: %w", err)
+	}
+
+	seen := make(map[uint]bool)
+	var migrations []migrationFile
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 0)
+		if err != nil {
+			continue
+		}
+		if seen[uint(version)] {
+			continue
+		}
+		seen[uint(version)] = true
+		migrations = append(migrations, migrationFile{version: uint(version), name: match[2]})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// previousVersion returns the version applied before current, or -1 when
+// current is the first migration.
+func previousVersion(migrations []migrationFile, current uint) int {
+	prev := -1
+	for _, m := range migrations {
+		if m.version >= current {
+			break
+		}
+		prev = int(m.version)
+	}
+	return prev
 }
 
 func buildPostgresURL(cfg config.Config) string {
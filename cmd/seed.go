@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/db"
+	"github.com/jjudge-oj/apiserver/internal/seed"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/spf13/cobra"
+)
+
+const seedPasswordEnv = "JJUDGE_SEED_PASSWORD"
+
+var seedPassword string
+
+// seedCmd represents the seed command.
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Load sample problems and demo users for local development",
+	Long: `Creates demo users (admin, alice, bob) and a set of sample problems whose
+testcase bundles are generated and uploaded to object storage. Existing
+users and problems with the same username or title are left untouched, so
+the command can be re-run safely. Usage:
+
+	jjudge seed --password devpass
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password := seedPassword
+		if password == "" {
+			password = strings.TrimSpace(os.Getenv(seedPasswordEnv))
+		}
+		if password == "" {
+			return fmt.Errorf("a demo password is required: pass --password or set %s", seedPasswordEnv)
+		}
+
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		objectStorage, err := storage.NewFromConfig(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("init storage failed: %w", err)
+		}
+		if err := objectStorage.EnsureBucket(ctx); err != nil {
+			return fmt.Errorf("ensure bucket failed: %w", err)
+		}
+
+		events := services.NewEventService(store.NewEventRepository(dbConn), nil, cfg.Events.Channel)
+		seeder := seed.New(
+			services.NewProblemService(store.NewProblemRepository(dbConn), events),
+			services.NewUserService(store.NewUserRepository(dbConn), events),
+			objectStorage,
+		)
+		result, err := seeder.Run(ctx, password)
+		if err != nil {
+			return fmt.Errorf("seed failed: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "users: %d created, %d already present\n", len(result.UsersCreated), len(result.UsersSkipped))
+		fmt.Fprintf(out, "problems: %d created, %d already present\n", len(result.ProblemsCreated), len(result.ProblemsSkipped))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+
+	seedCmd.Flags().StringVar(&seedPassword, "password", "", "password for every demo user (default $"+seedPasswordEnv+")")
+}
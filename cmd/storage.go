@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/db"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/storagegc"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	storageGCDryRun     bool
+	storageGCGrace      time.Duration
+	storageOffloadBatch int
+	storagePruneDryRun  bool
+	storagePruneAge     time.Duration
+)
+
+// storageCmd groups object storage maintenance commands.
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Object storage maintenance",
+}
+
+// storageGCCmd represents the storage gc command.
+var storageGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete storage objects no testcase bundle or submission references",
+	Long: `Lists every object in the bucket and deletes those that no testcase
+bundle or submission references and that are older than the grace period.
+Usage:
+
+	jjudge storage gc --dry-run
+	jjudge storage gc --grace 72h
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		grace := storageGCGrace
+		if !cmd.Flags().Changed("grace") {
+			grace = time.Duration(cfg.StorageGC.GraceSeconds) * time.Second
+		}
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		objectStorage, err := storage.NewFromConfig(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("init storage failed: %w", err)
+		}
+
+		collector := storagegc.New(objectStorage, store.NewProblemRepository(dbConn), grace)
+		result, err := collector.Run(ctx, storageGCDryRun)
+		if err != nil {
+			return fmt.Errorf("storage gc failed: %w", err)
+		}
+
+		verb := "deleted"
+		if storageGCDryRun {
+			verb = "would delete"
+		}
+		out := cmd.OutOrStdout()
+		for _, key := range result.Deleted {
+			fmt.Fprintf(out, "%s %s\n", verb, key)
+		}
+		fmt.Fprintf(out, "scanned %d objects: %d referenced, %d within grace period, %s %d (%d bytes)\n",
+			result.Scanned, result.Referenced, result.Recent, verb, len(result.Deleted), result.DeletedBytes)
+		return nil
+	},
+}
+
+// storageOffloadCodeCmd represents the storage offload-code command.
+var storageOffloadCodeCmd = &cobra.Command{
+	Use:   "offload-code",
+	Short: "Move submission sources from the database to object storage",
+	Long: `Copies the source of every submission still stored in the database to
+object storage and clears it from the row, keeping its preview and length.
+It can be interrupted and run again.
+Usage:
+
+	jjudge storage offload-code
+	jjudge storage offload-code --batch 500
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if storageOffloadBatch < 1 {
+			return fmt.Errorf("--batch must be positive")
+		}
+
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		objectStorage, err := storage.NewFromConfig(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("init storage failed: %w", err)
+		}
+
+		submissionService := services.NewSubmissionService(store.NewSubmissionRepository(dbConn), nil, nil, objectStorage)
+		moved, err := submissionService.OffloadCode(ctx, storageOffloadBatch)
+		fmt.Fprintf(cmd.OutOrStdout(), "moved %d submission sources to object storage\n", moved)
+		if err != nil {
+			return fmt.Errorf("offload code failed: %w", err)
+		}
+		return nil
+	},
+}
+
+// storagePruneCodeCmd represents the storage prune-code command.
+var storagePruneCodeCmd = &cobra.Command{
+	Use:   "prune-code",
+	Short: "Remove the sources of old submissions that were not accepted",
+	Long: `Clears the source of every submission older than the retention age
+whose verdict is not Accepted, keeping its verdict, timings and other
+metadata. Sources in object storage are deleted by the next storage gc.
+Usage:
+
+	jjudge storage prune-code --dry-run
+	jjudge storage prune-code --older-than 4320h
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		maxAge := storagePruneAge
+		if !cmd.Flags().Changed("older-than") {
+			maxAge = time.Duration(cfg.Retention.SubmissionCodeSeconds) * time.Second
+		}
+		if maxAge <= 0 {
+			return fmt.Errorf("--older-than or RETENTION_SUBMISSION_CODE_SECONDS must be set")
+		}
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		submissionService := services.NewSubmissionService(store.NewSubmissionRepository(dbConn), nil, nil, nil)
+		result, err := submissionService.PruneCode(ctx, maxAge, storagePruneDryRun)
+		verb := "pruned"
+		if storagePruneDryRun {
+			verb = "would prune"
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s the sources of %d submissions created before %s (%d bytes)\n",
+			verb, result.Submissions, result.Cutoff.UTC().Format(time.RFC3339), result.Bytes)
+		if err != nil {
+			return fmt.Errorf("prune code failed: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageGCCmd)
+	storageCmd.AddCommand(storageOffloadCodeCmd)
+	storageCmd.AddCommand(storagePruneCodeCmd)
+
+	storageGCCmd.Flags().BoolVar(&storageGCDryRun, "dry-run", false, "report unreferenced objects without deleting them")
+	storageGCCmd.Flags().DurationVar(&storageGCGrace, "grace", 0, "keep unreferenced objects younger than this (default STORAGE_GC_GRACE_SECONDS)")
+
+	storageOffloadCodeCmd.Flags().IntVar(&storageOffloadBatch, "batch", 100, "number of submissions to move per database query")
+
+	storagePruneCodeCmd.Flags().BoolVar(&storagePruneDryRun, "dry-run", false, "report the submissions that would be pruned without changing them")
+	storagePruneCodeCmd.Flags().DurationVar(&storagePruneAge, "older-than", 0, "prune submissions older than this (default RETENTION_SUBMISSION_CODE_SECONDS)")
+}
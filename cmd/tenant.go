@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/db"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var tenantName string
+
+// tenantCmd groups tenant administration commands.
+var tenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Manage the judges hosted by this deployment",
+}
+
+var tenantCreateCmd = &cobra.Command{
+	Use:   "create <slug>",
+	Short: "Create a tenant",
+	Long: `Creates a tenant, an isolated judge with its own users, problems and
+submissions. Requests select it with the X-Tenant header or, when
+TENANT_BASE_DOMAIN is set, through the subdomain named after its slug.
+Give it an admin with "jjudge admin create --tenant <slug>". Usage:
+
+	jjudge tenant create acme --name "ACME Programming Club"
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		name := tenantName
+		if name == "" {
+			name = args[0]
+		}
+		tenant, err := services.NewTenantService(store.NewTenantRepository(dbConn)).Create(ctx, args[0], name)
+		if err != nil {
+			if errors.Is(err, store.ErrConflict) {
+				return fmt.Errorf("tenant %s already exists", args[0])
+			}
+			return fmt.Errorf("create tenant failed: %w", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "created tenant %s (id %d)\n", tenant.Slug, tenant.ID)
+		return nil
+	},
+}
+
+var tenantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tenants",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.LoadConfig()
+		ctx := cmd.Context()
+
+		dbConn, err := db.Open(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("connect database failed: %w", err)
+		}
+		defer dbConn.Close()
+
+		tenants, err := services.NewTenantService(store.NewTenantRepository(dbConn)).List(ctx)
+		if err != nil {
+			return fmt.Errorf("list tenants failed: %w", err)
+		}
+		for _, tenant := range tenants {
+			fmt.Fprintf(cmd.OutOrStdout(), "%-6d %-32s %s\n", tenant.ID, tenant.Slug, tenant.Name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tenantCmd)
+	tenantCmd.AddCommand(tenantCreateCmd)
+	tenantCmd.AddCommand(tenantListCmd)
+
+	tenantCreateCmd.Flags().StringVar(&tenantName, "name", "", "display name (default the slug)")
+}
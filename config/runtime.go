@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Log levels accepted by LOG_LEVEL.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// RuntimeConfig holds the settings that can be reloaded while the server
+// runs. Everything else, such as listeners, database and queue
+// connections, takes a restart to change.
+//
+// The settings come from the environment, overridden by the file named by
+// CONFIG_FILE, in .env format, if any. Only that file changes after
+// startup, so settings meant to be reloaded belong there.
+type RuntimeConfig struct {
+	Abuse AbuseConfig
+
+	// CORSAllowedOrigins lists the origins browsers may call the API from;
+	// "*" allows any. Empty disables CORS.
+	CORSAllowedOrigins []string
+
+	// FeatureFlags lists the enabled feature flags.
+	FeatureFlags []string
+
+	// LogLevel is "debug", "info", "warn" or "error". Requests are logged
+	// at "info" and below.
+	LogLevel string
+}
+
+// RuntimeConfigFile returns the path of the file holding the reloadable
+// settings, or "" when there is none.
+func RuntimeConfigFile() string {
+	return getEnv("CONFIG_FILE", "")
+}
+
+// LoadRuntimeConfig reads the reloadable settings.
+func LoadRuntimeConfig() (RuntimeConfig, error) {
+	lookup := os.LookupEnv
+	if path := RuntimeConfigFile(); path != "" {
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return RuntimeConfig{}, fmt.Errorf("reading %s: %w", path, err)
+		}
+		lookup = func(key string) (string, bool) {
+			if value, exists := values[key]; exists {
+				return value, true
+			}
+			return os.LookupEnv(key)
+		}
+	}
+
+	var errs []string
+	lookupInt := func(key string, defaultValue int) int {
+		raw, exists := lookup(key)
+		if !exists {
+			return defaultValue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid %s: %q", key, raw))
+			return defaultValue
+		}
+		return value
+	}
+	lookupString := func(key, defaultValue string) string {
+		if value, exists := lookup(key); exists {
+			return value
+		}
+		return defaultValue
+	}
+
+	cfg := RuntimeConfig{
+		Abuse: AbuseConfig{
+			WindowSeconds:   lookupInt("ABUSE_WINDOW_SECONDS", 60),
+			MaxRequests:     lookupInt("ABUSE_MAX_REQUESTS", 1200),
+			MaxErrorPercent: lookupInt("ABUSE_MAX_ERROR_PERCENT", 80),
+			MinRequests:     lookupInt("ABUSE_MIN_REQUESTS", 100),
+			BlockSeconds:    lookupInt("ABUSE_BLOCK_SECONDS", 0),
+		},
+		CORSAllowedOrigins: splitList(lookupString("CORS_ALLOWED_ORIGINS", "")),
+		FeatureFlags:       splitList(lookupString("FEATURE_FLAGS", "")),
+		LogLevel:           strings.ToLower(strings.TrimSpace(lookupString("LOG_LEVEL", "info"))),
+	}
+	if !slices.Contains(logLevels, cfg.LogLevel) {
+		errs = append(errs, fmt.Sprintf("invalid LOG_LEVEL: %q", cfg.LogLevel))
+	}
+	if len(errs) > 0 {
+		return RuntimeConfig{}, errors.New(strings.Join(errs, "; "))
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
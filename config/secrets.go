@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds the request reading secrets from Vault at startup.
+const vaultTimeout = 10 * time.Second
+
+// secrets resolves secret settings from, in order of precedence, the
+// environment variable itself, a file named by the variable with a _FILE
+// suffix (as Kubernetes secret mounts and Vault agent templates provide)
+// and the Vault secret configured with VAULT_SECRET_PATH.
+type secrets struct {
+	vault map[string]string
+	errs  []error
+}
+
+// loadSecrets reads the Vault secret when VAULT_SECRET_PATH is set. Vault
+// is reached at VAULT_ADDR with the token in VAULT_TOKEN or
+// VAULT_TOKEN_FILE, in the VAULT_NAMESPACE namespace if any.
+func loadSecrets() *secrets {
+	s := &secrets{}
+	path := getEnv("VAULT_SECRET_PATH", "")
+	if path == "" {
+		return s
+	}
+
+	token := s.get("VAULT_TOKEN", "")
+	if token == "" {
+		s.errs = append(s.errs, errors.New("VAULT_SECRET_PATH requires VAULT_TOKEN or VAULT_TOKEN_FILE"))
+		return s
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+	values, err := readVaultSecret(ctx, getEnv("VAULT_ADDR", "http://127.0.0.1:8200"), token, getEnv("VAULT_NAMESPACE", ""), path)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("vault: %w", err))
+		return s
+	}
+	s.vault = values
+	return s
+}
+
+// get returns the secret named key, or defaultValue when no source sets it.
+func (s *secrets) get(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	if path, exists := os.LookupEnv(key + "_FILE"); exists {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return defaultValue
+		}
+		// Files written by editors and most tools end with a newline that
+		// is not part of the secret.
+		return strings.TrimRight(string(data), "\r\n")
+	}
+	if value, exists := s.vault[key]; exists {
+		return value
+	}
+	return defaultValue
+}
+
+func (s *secrets) err() error {
+	return errors.Join(s.errs...)
+}
+
+// readVaultSecret reads a secret from a KV secrets engine of either
+// version. Its fields are named after the environment variables they
+// replace, such as DB_PASSWORD. For version 2 the path includes "data/",
+// as in "secret/data/jjudge".
+func readVaultSecret(ctx context.Context, addr, token, namespace, path string) (map[string]string, error) {
+	url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("reading %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	fields := body.Data
+	// KV version 2 nests the fields under data.data, next to metadata.
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+
+	values := make(map[string]string, len(fields))
+	for key, raw := range fields {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("reading %s: field %s is not a string", path, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
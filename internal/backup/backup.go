@@ -0,0 +1,428 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+)
+
+const (
+	formatVersion = 1
+	manifestName  = "manifest.json"
+	databaseName  = "database.sql"
+	objectsPrefix = "objects/"
+)
+
+// ObjectKeyLister returns the storage object keys referenced by the database.
+type ObjectKeyLister interface {
+	ListObjectKeys(ctx context.Context) ([]string, error)
+}
+
+// Manifest describes the contents of a backup archive.
+type Manifest struct {
+	FormatVersion  int           `json:"format_version"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Bucket         string        `json:"bucket"`
+	Database       FileEntry     `json:"database"`
+	Objects        []ObjectEntry `json:"objects"`
+	MissingObjects []string      `json:"missing_objects,omitempty"`
+}
+
+// FileEntry records the size and checksum of a file in the archive.
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ObjectEntry records a storage object captured in the archive.
+type ObjectEntry struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Backup snapshots the database and the storage objects it references into
+// a single tar.gz archive, and restores such archives.
+type Backup struct {
+	databaseURL string
+	storage     *storage.Storage
+	keys        ObjectKeyLister
+}
+
+// New constructs a Backup. databaseURL is passed to pg_dump and psql, which
+// must be available on PATH.
+func New(databaseURL string, objectStorage *storage.Storage, keys ObjectKeyLister) *Backup {
+	return &Backup{
+		databaseURL: databaseURL,
+		storage:     objectStorage,
+		keys:        keys,
+	}
+}
+
+// Create writes a backup archive to w. Referenced objects that cannot be
+// read from storage are listed in the manifest as missing rather than
+// failing the backup.
+func (b *Backup) Create(ctx context.Context, w io.Writer) (Manifest, error) {
+	workDir, err := os.MkdirTemp("", "jjudge-backup-")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	manifest := Manifest{
+		FormatVersion: formatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Bucket:        b.storage.Bucket(),
+	}
+
+	dumpPath := filepath.Join(workDir, databaseName)
+	if err := b.dumpDatabase(ctx, dumpPath); err != nil {
+		return Manifest{}, err
+	}
+	size, sum, err := hashFile(dumpPath)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest.Database = FileEntry{Name: databaseName, Size: size, SHA256: sum}
+
+	keys, err := b.keys.ListObjectKeys(ctx)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("list object keys: %w", err)
+	}
+	objectPaths := make(map[string]string, len(keys))
+	for i, key := range keys {
+		objectPath := filepath.Join(workDir, fmt.Sprintf("object-%d", i))
+		entry, err := b.downloadObject(ctx, key, objectPath)
+		if err != nil {
+			manifest.MissingObjects = append(manifest.MissingObjects, key)
+			continue
+		}
+		manifest.Objects = append(manifest.Objects, entry)
+		objectPaths[key] = objectPath
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := writeTarBytes(tw, manifestName, manifestData); err != nil {
+		return Manifest{}, err
+	}
+	if err := writeTarFile(tw, databaseName, dumpPath); err != nil {
+		return Manifest{}, err
+	}
+	for _, entry := range manifest.Objects {
+		if err := writeTarFile(tw, objectsPrefix+entry.Key, objectPaths[entry.Key]); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	if err := gw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// Restore verifies a backup archive against its manifest, then restores the
+// database and uploads the captured objects. After restoring, every object
+// referenced by the database must be present in the archive, or must have
+// already been missing when the backup was taken.
+func (b *Backup) Restore(ctx context.Context, r io.Reader) (Manifest, error) {
+	workDir, err := os.MkdirTemp("", "jjudge-restore-")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(workDir)
+	}()
+
+	manifest, files, err := extractArchive(r, workDir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := verifyArchive(manifest, files); err != nil {
+		return Manifest{}, err
+	}
+
+	if err := b.restoreDatabase(ctx, files[databaseName]); err != nil {
+		return Manifest{}, err
+	}
+
+	for _, entry := range manifest.Objects {
+		if err := uploadObject(ctx, b.storage, entry, files[objectsPrefix+entry.Key]); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	keys, err := b.keys.ListObjectKeys(ctx)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("list object keys: %w", err)
+	}
+	known := make(map[string]struct{}, len(manifest.Objects)+len(manifest.MissingObjects))
+	for _, entry := range manifest.Objects {
+		known[entry.Key] = struct{}{}
+	}
+	for _, key := range manifest.MissingObjects {
+		known[key] = struct{}{}
+	}
+	var unaccounted []string
+	for _, key := range keys {
+		if _, ok := known[key]; !ok {
+			unaccounted = append(unaccounted, key)
+		}
+	}
+	if len(unaccounted) > 0 {
+		return manifest, fmt.Errorf("restored database references objects not in the archive: %s", strings.Join(unaccounted, ", "))
+	}
+	return manifest, nil
+}
+
+func (b *Backup) dumpDatabase(ctx context.Context, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "pg_dump",
+		"--dbname="+b.databaseURL,
+		"--format=plain",
+		"--clean",
+		"--if-exists",
+		"--no-owner",
+		"--no-privileges",
+	)
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Close()
+}
+
+func (b *Backup) restoreDatabase(ctx context.Context, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "psql",
+		"--dbname="+b.databaseURL,
+		"--quiet",
+		"--single-transaction",
+		"--set=ON_ERROR_STOP=1",
+	)
+	cmd.Stdin = in
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql restore failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (b *Backup) downloadObject(ctx context.Context, key, dst string) (ObjectEntry, error) {
+	reader, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	defer reader.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), reader)
+	if err != nil {
+		return ObjectEntry{}, err
+	}
+	if err := out.Close(); err != nil {
+		return ObjectEntry{}, err
+	}
+	return ObjectEntry{
+		Key:    key,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func uploadObject(ctx context.Context, objectStorage *storage.Storage, entry ObjectEntry, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := objectStorage.Put(ctx, entry.Key, in, entry.Size, "application/octet-stream"); err != nil {
+		return fmt.Errorf("upload object %s: %w", entry.Key, err)
+	}
+	return nil
+}
+
+func extractArchive(r io.Reader, dir string) (Manifest, map[string]string, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, nil, errors.New("invalid backup archive")
+	}
+	defer gr.Close()
+
+	var manifest Manifest
+	haveManifest := false
+	files := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for i := 0; ; i++ {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, nil, errors.New("invalid backup archive")
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			return Manifest{}, nil, fmt.Errorf("unexpected archive entry: %s", header.Name)
+		}
+
+		name := path.Clean(header.Name)
+		if name != header.Name || name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return Manifest{}, nil, fmt.Errorf("unsafe archive entry: %s", header.Name)
+		}
+
+		if name == manifestName {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return Manifest{}, nil, errors.New("invalid backup manifest")
+			}
+			haveManifest = true
+			continue
+		}
+
+		dst := filepath.Join(dir, fmt.Sprintf("entry-%d", i))
+		out, err := os.Create(dst)
+		if err != nil {
+			return Manifest{}, nil, err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			_ = out.Close()
+			return Manifest{}, nil, err
+		}
+		if err := out.Close(); err != nil {
+			return Manifest{}, nil, err
+		}
+		files[name] = dst
+	}
+
+	if !haveManifest {
+		return Manifest{}, nil, errors.New("backup archive has no manifest")
+	}
+	if manifest.FormatVersion != formatVersion {
+		return Manifest{}, nil, fmt.Errorf("unsupported backup format version %d", manifest.FormatVersion)
+	}
+	return manifest, files, nil
+}
+
+func verifyArchive(manifest Manifest, files map[string]string) error {
+	if err := verifyFile(databaseName, manifest.Database.Size, manifest.Database.SHA256, files); err != nil {
+		return err
+	}
+	for _, entry := range manifest.Objects {
+		if err := verifyFile(objectsPrefix+entry.Key, entry.Size, entry.SHA256, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyFile(name string, size int64, sum string, files map[string]string) error {
+	filePath, ok := files[name]
+	if !ok {
+		return fmt.Errorf("backup archive is missing %s", name)
+	}
+	actualSize, actualSum, err := hashFile(filePath)
+	if err != nil {
+		return err
+	}
+	if actualSize != size || actualSum != sum {
+		return fmt.Errorf("checksum mismatch for %s", name)
+	}
+	return nil
+}
+
+func hashFile(filePath string) (int64, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeTarBytes(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeTarFile(tw *tar.Writer, name, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, in)
+	return err
+}
@@ -7,12 +7,12 @@ import (
 	"net/url"
 	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jjudge-oj/apiserver/config"
-	_ "github.com/lib/pq"
 )
 
 const (
-	defaultDBDriver     = "postgres"
+	defaultDBDriver     = "pgx"
 	defaultPingTimeout  = 5 * time.Second
 	defaultConnMaxIdle  = 2 * time.Minute
 	defaultConnMaxLife  = 30 * time.Minute
@@ -39,16 +39,11 @@ func Open(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 
 	dsn := u.String()
 
-	db, err := sql.Open(defaultDBDriver, dsn)
+	db, err := open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetConnMaxIdleTime(defaultConnMaxIdle)
-	db.SetConnMaxLifetime(defaultConnMaxLife)
-	db.SetMaxIdleConns(defaultMaxIdleConns)
-	db.SetMaxOpenConns(defaultMaxOpenConns)
-
 	ctx, cancel := context.WithTimeout(context.Background(), defaultPingTimeout)
 	defer cancel()
 	if err := db.PingContext(ctx); err != nil {
@@ -58,3 +53,27 @@ func Open(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// OpenReplica connects to the read replica named by DB_REPLICA_DSN, or
+// returns nil when none is configured. Unlike Open it does not fail when
+// the replica cannot be reached, since reads fall back to the primary
+// until it can.
+func OpenReplica(cfg config.Config) (*sql.DB, error) {
+	if cfg.Database.ReplicaDSN == "" {
+		return nil, nil
+	}
+	return open(cfg.Database.ReplicaDSN)
+}
+
+func open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(defaultDBDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetConnMaxIdleTime(defaultConnMaxIdle)
+	db.SetConnMaxLifetime(defaultConnMaxLife)
+	db.SetMaxIdleConns(defaultMaxIdleConns)
+	db.SetMaxOpenConns(defaultMaxOpenConns)
+	return db, nil
+}
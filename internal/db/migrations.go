@@ -0,0 +1,9 @@
+package db
+
+import "embed"
+
+// Migrations holds the schema migrations in golang-migrate's file layout,
+// for running them without access to the source tree.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
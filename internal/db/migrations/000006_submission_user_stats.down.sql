@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS submissions_user_accepted_idx;
+DROP INDEX IF EXISTS submissions_user_created_at_idx;
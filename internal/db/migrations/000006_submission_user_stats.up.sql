@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS submissions_user_created_at_idx ON submissions(user_id, created_at);
+
+CREATE INDEX IF NOT EXISTS submissions_user_accepted_idx
+    ON submissions(user_id, problem_id, created_at)
+    WHERE verdict = 2;
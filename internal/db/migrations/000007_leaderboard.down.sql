@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW IF EXISTS leaderboard_entries;
@@ -0,0 +1,31 @@
+-- One row per (period, user). period is 'all' for all-time totals or a
+-- UTC month formatted as YYYY-MM. A problem counts as solved in a period
+-- when the user has an accepted submission for it in that period, and its
+-- score is the user's best score for the problem in that period.
+CREATE MATERIALIZED VIEW IF NOT EXISTS leaderboard_entries AS
+WITH per_problem AS (
+    SELECT 'all'::text AS period,
+           user_id,
+           problem_id,
+           MAX(score) AS score,
+           BOOL_OR(verdict = 2) AS solved
+    FROM submissions
+    GROUP BY user_id, problem_id
+    UNION ALL
+    SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM') AS period,
+           user_id,
+           problem_id,
+           MAX(score) AS score,
+           BOOL_OR(verdict = 2) AS solved
+    FROM submissions
+    GROUP BY to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM'), user_id, problem_id
+)
+SELECT period,
+       user_id,
+       COUNT(1) FILTER (WHERE solved) AS solved,
+       COALESCE(SUM(score), 0)::BIGINT AS score
+FROM per_problem
+GROUP BY period, user_id;
+
+-- Required for REFRESH MATERIALIZED VIEW CONCURRENTLY.
+CREATE UNIQUE INDEX IF NOT EXISTS leaderboard_entries_period_user_idx ON leaderboard_entries(period, user_id);
@@ -0,0 +1 @@
+ALTER TABLE problems DROP COLUMN IF EXISTS type;
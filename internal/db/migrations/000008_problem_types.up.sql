@@ -0,0 +1 @@
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS type TEXT NOT NULL DEFAULT 'batch';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS submissions_updated_at_idx;
+DROP INDEX IF EXISTS submissions_unjudged_created_at_idx;
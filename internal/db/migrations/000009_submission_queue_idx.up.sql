@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS submissions_unjudged_created_at_idx
+    ON submissions(created_at)
+    WHERE verdict IN (0, 1);
+
+CREATE INDEX IF NOT EXISTS submissions_updated_at_idx ON submissions(updated_at);
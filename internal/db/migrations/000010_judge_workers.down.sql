@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS judge_workers_last_seen_at_idx;
+DROP TABLE IF EXISTS judge_workers;
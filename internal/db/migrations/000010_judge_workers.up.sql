@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS judge_workers (
+    id TEXT PRIMARY KEY,
+    pool TEXT NOT NULL,
+    languages JSONB NOT NULL DEFAULT '[]'::jsonb,
+    arch TEXT NOT NULL DEFAULT '',
+    registered_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS judge_workers_last_seen_at_idx ON judge_workers(last_seen_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS runs_user_id_unfinished_idx;
+DROP TABLE IF EXISTS runs;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS runs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    language TEXT NOT NULL,
+    code TEXT NOT NULL,
+    stdin TEXT NOT NULL DEFAULT '',
+    verdict INT NOT NULL DEFAULT 0,
+    stdout TEXT NOT NULL DEFAULT '',
+    stderr TEXT NOT NULL DEFAULT '',
+    cpu_time BIGINT NOT NULL DEFAULT 0,
+    memory BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS runs_user_id_unfinished_idx
+    ON runs(user_id)
+    WHERE verdict IN (0, 1);
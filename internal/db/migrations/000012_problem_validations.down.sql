@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS problem_validations;
+ALTER TABLE problems DROP COLUMN IF EXISTS validation_status;
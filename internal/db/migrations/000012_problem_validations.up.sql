@@ -0,0 +1,13 @@
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS validation_status TEXT NOT NULL DEFAULT 'none';
+
+CREATE TABLE IF NOT EXISTS problem_validations (
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    bundle_version INT NOT NULL,
+    solution TEXT NOT NULL,
+    language TEXT NOT NULL,
+    expected INT NOT NULL,
+    verdict INT NOT NULL DEFAULT 0,
+    message TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (problem_id, bundle_version, solution)
+);
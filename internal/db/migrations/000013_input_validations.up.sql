@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS input_validations (
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    bundle_version INT NOT NULL,
+    validator TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    errors JSONB NOT NULL DEFAULT '[]'::jsonb,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (problem_id, bundle_version)
+);
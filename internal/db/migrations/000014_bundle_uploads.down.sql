@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bundle_uploads_problem_id_idx;
+DROP TABLE IF EXISTS bundle_uploads;
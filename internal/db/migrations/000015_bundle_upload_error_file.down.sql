@@ -0,0 +1 @@
+ALTER TABLE bundle_uploads DROP COLUMN IF EXISTS error_file;
@@ -0,0 +1 @@
+ALTER TABLE bundle_uploads ADD COLUMN IF NOT EXISTS error_file TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE testcase_bundles
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS integrity_error,
+    DROP COLUMN IF EXISTS corrupted;
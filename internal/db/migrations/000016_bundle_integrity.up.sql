@@ -0,0 +1,4 @@
+ALTER TABLE testcase_bundles
+    ADD COLUMN IF NOT EXISTS corrupted BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS integrity_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS verified_at TIMESTAMPTZ;
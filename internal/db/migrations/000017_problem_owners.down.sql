@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS problems_owner_id_idx;
+ALTER TABLE problems DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,3 @@
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS owner_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS problems_owner_id_idx ON problems(owner_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS problem_review_comments_problem_id_idx;
+DROP TABLE IF EXISTS problem_review_comments;
+DROP INDEX IF EXISTS problem_review_decisions_problem_id_idx;
+DROP TABLE IF EXISTS problem_review_decisions;
+ALTER TABLE problems
+    DROP COLUMN IF EXISTS published,
+    DROP COLUMN IF EXISTS review_status;
@@ -0,0 +1,27 @@
+-- Existing problems predate reviews and are already public.
+ALTER TABLE problems
+    ADD COLUMN IF NOT EXISTS review_status TEXT NOT NULL DEFAULT 'approved',
+    ADD COLUMN IF NOT EXISTS published BOOLEAN NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS problem_review_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    reviewer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS problem_review_decisions_problem_id_idx ON problem_review_decisions(problem_id);
+
+CREATE TABLE IF NOT EXISTS problem_review_comments (
+    id BIGSERIAL PRIMARY KEY,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES problem_review_comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS problem_review_comments_problem_id_idx ON problem_review_comments(problem_id);
@@ -0,0 +1,7 @@
+-- Sources already moved to object storage are not copied back; offloaded
+-- rows keep only their preview.
+UPDATE submissions SET code = code_preview WHERE code_key IS NOT NULL;
+
+ALTER TABLE submissions DROP COLUMN IF EXISTS code_length;
+ALTER TABLE submissions DROP COLUMN IF EXISTS code_preview;
+ALTER TABLE submissions DROP COLUMN IF EXISTS code_key;
@@ -0,0 +1,10 @@
+-- Submission sources move to object storage. code_key names the object
+-- holding the full source; rows without one still keep it in code until
+-- `jjudge storage offload-code` moves it.
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS code_key TEXT;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS code_preview TEXT NOT NULL DEFAULT '';
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS code_length INTEGER NOT NULL DEFAULT 0;
+
+UPDATE submissions
+SET code_preview = LEFT(code, 1024),
+    code_length = OCTET_LENGTH(code);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS submissions_problem_user_id_idx;
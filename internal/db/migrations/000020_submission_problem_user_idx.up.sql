@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS submissions_problem_user_id_idx ON submissions(problem_id, user_id, id DESC);
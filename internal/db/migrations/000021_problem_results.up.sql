@@ -0,0 +1,33 @@
+-- One row per (user, problem) the user has a judged submission for,
+-- holding their best attempt: an accepted submission beats any other,
+-- then the higher score, then the earlier submission. Rows are kept up to
+-- date by the submission store whenever a verdict changes.
+CREATE TABLE IF NOT EXISTS problem_results (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    best_submission_id BIGINT NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+    best_score INTEGER NOT NULL,
+    best_verdict INTEGER NOT NULL,
+    attempts INTEGER NOT NULL,
+    first_accepted_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, problem_id)
+);
+
+CREATE INDEX IF NOT EXISTS problem_results_problem_id_idx ON problem_results(problem_id);
+
+INSERT INTO problem_results (user_id, problem_id, best_submission_id, best_score, best_verdict, attempts, first_accepted_at, updated_at)
+SELECT DISTINCT ON (user_id, problem_id)
+       user_id,
+       problem_id,
+       id,
+       score,
+       verdict,
+       COUNT(1) OVER w,
+       MIN(created_at) FILTER (WHERE verdict = 2) OVER w,
+       NOW()
+FROM submissions
+WHERE verdict NOT IN (0, 1)
+WINDOW w AS (PARTITION BY user_id, problem_id)
+ORDER BY user_id, problem_id, (verdict = 2) DESC, score DESC, id
+ON CONFLICT (user_id, problem_id) DO NOTHING;
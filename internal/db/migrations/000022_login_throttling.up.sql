@@ -0,0 +1,18 @@
+-- Failed login counters. key is "user:<username>" or "ip:<address>";
+-- logins for a key are refused until blocked_until.
+CREATE TABLE IF NOT EXISTS login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    blocked_until TIMESTAMPTZ NOT NULL
+);
+
+-- Accounts locked after too many failed logins. The lock lifts at
+-- locked_until or when the emailed unlock token is redeemed; only its
+-- SHA-256 is stored.
+CREATE TABLE IF NOT EXISTS account_lockouts (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    locked_until TIMESTAMPTZ NOT NULL,
+    unlock_token_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Client details so users can tell their sessions apart.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+UPDATE sessions SET last_used_at = created_at WHERE last_used_at IS NULL;
+
+ALTER TABLE sessions ALTER COLUMN last_used_at SET NOT NULL;
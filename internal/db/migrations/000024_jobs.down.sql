@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS job_schedules;
+DROP INDEX IF EXISTS jobs_created_at_idx;
+DROP INDEX IF EXISTS jobs_unfinished_run_at_idx;
+DROP TABLE IF EXISTS jobs;
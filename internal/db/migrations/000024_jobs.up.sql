@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    result JSONB,
+    error TEXT NOT NULL DEFAULT '',
+    run_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Jobs still to be run, or whose runner may have died, in the order they
+-- are claimed.
+CREATE INDEX IF NOT EXISTS jobs_unfinished_run_at_idx
+    ON jobs(run_at, id)
+    WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS jobs_created_at_idx ON jobs(created_at);
+
+-- The next run of each scheduled task, shared by all API servers so that a
+-- task runs once per interval however many servers there are.
+CREATE TABLE IF NOT EXISTS job_schedules (
+    kind TEXT PRIMARY KEY,
+    next_run_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS submissions_created_at_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS submissions_created_at_idx ON submissions(created_at);
@@ -0,0 +1,3 @@
+-- code_pruned_at marks submissions whose source was removed by the
+-- retention policy. Their code, code_key and code_preview are empty.
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS code_pruned_at TIMESTAMPTZ;
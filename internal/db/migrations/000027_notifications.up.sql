@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    subject_id BIGINT NOT NULL DEFAULT 0,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- A user's notifications, newest first.
+CREATE INDEX IF NOT EXISTS notifications_user_id_idx ON notifications(user_id, id DESC);
+
+-- Unread counts, which are fetched far more often than the list.
+CREATE INDEX IF NOT EXISTS notifications_unread_idx
+    ON notifications(user_id)
+    WHERE read_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS notification_preferences_digest_idx;
+DROP TABLE IF EXISTS notification_preferences;
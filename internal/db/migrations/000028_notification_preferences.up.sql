@@ -0,0 +1,12 @@
+-- Users without a row have the default preferences.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email_notifications BOOLEAN NOT NULL DEFAULT false,
+    verdict_digest TEXT NOT NULL DEFAULT 'off',
+    verdict_digest_sent_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notification_preferences_digest_idx
+    ON notification_preferences(verdict_digest, user_id)
+    WHERE verdict_digest <> 'off';
@@ -0,0 +1,10 @@
+-- Outstanding password reset tokens, stored hashed. A user has at most
+-- one; requesting another replaces it.
+CREATE TABLE IF NOT EXISTS password_resets (
+    token_hash TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets(user_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS problem_comments_root_id_idx;
+DROP INDEX IF EXISTS problem_comments_threads_idx;
+DROP TABLE IF EXISTS problem_comments;
@@ -0,0 +1,23 @@
+-- Discussion of a problem. Comments without a parent start a thread;
+-- replies carry the thread's first comment as root_id so that whole
+-- threads are fetched at once.
+CREATE TABLE IF NOT EXISTS problem_comments (
+    id BIGSERIAL PRIMARY KEY,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    root_id BIGINT REFERENCES problem_comments(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES problem_comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    spoiler BOOLEAN NOT NULL DEFAULT false,
+    hidden_at TIMESTAMPTZ,
+    hidden_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    deleted_at TIMESTAMPTZ,
+    edited_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS problem_comments_threads_idx
+    ON problem_comments(problem_id, id)
+    WHERE root_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS problem_comments_root_id_idx ON problem_comments(root_id, id);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS content_reports (
+    id BIGSERIAL PRIMARY KEY,
+    reporter_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    action TEXT NOT NULL DEFAULT '',
+    resolver_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ
+);
+
+-- A user may have one open report per piece of content.
+CREATE UNIQUE INDEX IF NOT EXISTS content_reports_open_idx
+    ON content_reports(reporter_id, target_type, target_id)
+    WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS content_reports_status_idx ON content_reports(status, id);
+
+CREATE TABLE IF NOT EXISTS user_bans (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    banned_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
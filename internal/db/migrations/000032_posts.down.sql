@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_tags_idx;
+DROP INDEX IF EXISTS posts_published_idx;
+DROP TABLE IF EXISTS posts;
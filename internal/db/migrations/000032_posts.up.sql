@@ -0,0 +1,19 @@
+-- News posts for the judge's homepage. A post without published_at is a
+-- draft; one with a future published_at is listed from then on.
+CREATE TABLE IF NOT EXISTS posts (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    tags JSONB NOT NULL DEFAULT '[]'::jsonb,
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    author_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    published_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS posts_published_idx
+    ON posts(pinned DESC, published_at DESC)
+    WHERE published_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS posts_tags_idx ON posts USING GIN (tags);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS problem_list_items_position_idx;
+DROP TABLE IF EXISTS problem_list_items;
+DROP TABLE IF EXISTS problem_lists;
@@ -0,0 +1,18 @@
+-- Ordered collections of problems, such as training sheets.
+CREATE TABLE IF NOT EXISTS problem_lists (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    owner_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS problem_list_items (
+    list_id INTEGER NOT NULL REFERENCES problem_lists(id) ON DELETE CASCADE,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (list_id, problem_id)
+);
+
+CREATE INDEX IF NOT EXISTS problem_list_items_position_idx ON problem_list_items(list_id, position);
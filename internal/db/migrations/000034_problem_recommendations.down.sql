@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS problem_recommendations_rank_idx;
+DROP TABLE IF EXISTS problem_recommendations;
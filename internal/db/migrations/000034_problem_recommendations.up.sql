@@ -0,0 +1,12 @@
+-- Problems suggested to each user next, recomputed periodically from the
+-- difficulty of the problems they solved recently.
+CREATE TABLE IF NOT EXISTS problem_recommendations (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    problem_id INTEGER NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+    rank INTEGER NOT NULL,
+    target_difficulty INTEGER NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, problem_id)
+);
+
+CREATE INDEX IF NOT EXISTS problem_recommendations_rank_idx ON problem_recommendations(user_id, rank);
@@ -0,0 +1,3 @@
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS compile_stderr,
+    DROP COLUMN IF EXISTS compile_stdout;
@@ -0,0 +1,5 @@
+-- Compiler output reported by the judge, kept apart from the verdict
+-- message and shown only to the submission's owner.
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS compile_stdout TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS compile_stderr TEXT NOT NULL DEFAULT '';
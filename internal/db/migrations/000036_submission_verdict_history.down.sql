@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS submission_verdict_history_submission_id_idx;
+DROP TABLE IF EXISTS submission_verdict_history;
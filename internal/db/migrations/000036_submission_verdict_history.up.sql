@@ -0,0 +1,19 @@
+-- Outcomes a submission had before being judged again. A row is written
+-- whenever a judged submission is updated, so rejudges keep the
+-- original result.
+CREATE TABLE IF NOT EXISTS submission_verdict_history (
+    id BIGSERIAL PRIMARY KEY,
+    submission_id BIGINT NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+    verdict INTEGER NOT NULL,
+    score INTEGER NOT NULL,
+    tests_passed INTEGER NOT NULL,
+    tests_total INTEGER NOT NULL,
+    cpu_time BIGINT NOT NULL,
+    memory BIGINT NOT NULL,
+    message TEXT NOT NULL,
+    judged_at TIMESTAMPTZ NOT NULL,
+    replaced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS submission_verdict_history_submission_id_idx
+    ON submission_verdict_history(submission_id, id);
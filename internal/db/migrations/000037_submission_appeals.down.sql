@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS submission_appeals_user_id_idx;
+DROP INDEX IF EXISTS submission_appeals_status_idx;
+DROP INDEX IF EXISTS submission_appeals_open_idx;
+DROP TABLE IF EXISTS submission_appeals;
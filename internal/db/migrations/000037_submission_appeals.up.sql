@@ -0,0 +1,22 @@
+-- Appeals against a submission's verdict. Each submission has at most one
+-- open appeal; admins resolve it by dismissing it, rejudging the
+-- submission or overriding its verdict.
+CREATE TABLE IF NOT EXISTS submission_appeals (
+    id BIGSERIAL PRIMARY KEY,
+    submission_id BIGINT NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    resolution TEXT NOT NULL DEFAULT '',
+    response TEXT NOT NULL DEFAULT '',
+    resolved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS submission_appeals_open_idx
+    ON submission_appeals(submission_id)
+    WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS submission_appeals_status_idx ON submission_appeals(status, id);
+CREATE INDEX IF NOT EXISTS submission_appeals_user_id_idx ON submission_appeals(user_id, id);
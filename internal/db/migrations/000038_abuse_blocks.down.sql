@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS abuse_blocks_blocked_until_idx;
+DROP TABLE IF EXISTS abuse_blocks;
@@ -0,0 +1,10 @@
+-- Principals ("user:<id>" or "ip:<address>") blocked from the API by the
+-- abuse detector, until blocked_until or until an admin unblocks them.
+CREATE TABLE IF NOT EXISTS abuse_blocks (
+    principal TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,
+    blocked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS abuse_blocks_blocked_until_idx ON abuse_blocks(blocked_until);
@@ -0,0 +1,17 @@
+-- Fails if two tenants share a username or email.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tenant_username_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tenant_email_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+ALTER TABLE content_reports DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE submission_appeals DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE events DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE problem_lists DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE announcements DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE submissions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE problems DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenants;
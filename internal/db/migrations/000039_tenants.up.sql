@@ -0,0 +1,48 @@
+-- Isolated judges hosted by one deployment. Existing data belongs to the
+-- default tenant, which requests naming no tenant are served by.
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'Default')
+ON CONFLICT (id) DO NOTHING;
+SELECT setval('tenants_id_seq', GREATEST((SELECT MAX(id) FROM tenants), 1));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE problems ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE submissions ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE problem_lists ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE submission_appeals ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE content_reports ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+
+-- Rows are always written with their tenant from now on.
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE problems ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE submissions ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE announcements ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE posts ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE problem_lists ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE events ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE submission_appeals ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE content_reports ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Usernames and emails are unique within a tenant.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_username_key UNIQUE (tenant_id, username);
+ALTER TABLE users ADD CONSTRAINT users_tenant_email_key UNIQUE (tenant_id, email);
+
+CREATE INDEX IF NOT EXISTS problems_tenant_id_idx ON problems(tenant_id, id);
+CREATE INDEX IF NOT EXISTS submissions_tenant_id_idx ON submissions(tenant_id, id);
+CREATE INDEX IF NOT EXISTS announcements_tenant_id_idx ON announcements(tenant_id);
+CREATE INDEX IF NOT EXISTS posts_tenant_id_idx ON posts(tenant_id);
+CREATE INDEX IF NOT EXISTS problem_lists_tenant_id_idx ON problem_lists(tenant_id, id);
+CREATE INDEX IF NOT EXISTS events_tenant_id_idx ON events(tenant_id, id);
+CREATE INDEX IF NOT EXISTS submission_appeals_tenant_id_idx ON submission_appeals(tenant_id, id);
+CREATE INDEX IF NOT EXISTS content_reports_tenant_id_idx ON content_reports(tenant_id, id);
@@ -0,0 +1,2 @@
+ALTER TABLE problems
+    DROP COLUMN IF EXISTS solution_visibility;
@@ -0,0 +1,3 @@
+-- Accepted solutions stay private to their authors unless a problem opts in.
+ALTER TABLE problems
+    ADD COLUMN IF NOT EXISTS solution_visibility TEXT NOT NULL DEFAULT 'never';
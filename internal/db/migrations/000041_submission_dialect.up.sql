@@ -0,0 +1,2 @@
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS dialect TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS submissions_suspicion_score_idx;
+
+ALTER TABLE submissions
+    DROP COLUMN IF EXISTS suspicion_score;
@@ -0,0 +1,8 @@
+-- How strongly a submission's source looks like it hardcodes expected
+-- outputs, from 0 to 100. Flagged submissions are listed for admin review.
+ALTER TABLE submissions
+    ADD COLUMN IF NOT EXISTS suspicion_score INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS submissions_suspicion_score_idx
+    ON submissions(suspicion_score DESC, id DESC)
+    WHERE suspicion_score > 0;
@@ -0,0 +1,486 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Execute runs a query request against schema. Errors raised by resolvers
+// are reported in the response next to the data of the other fields;
+// requests that cannot be executed at all get a response without data.
+func Execute(ctx context.Context, schema *Schema, req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		var syntaxErr *syntaxError
+		if errors.As(err, &syntaxErr) {
+			return Response{Errors: []Error{{
+				Message:   err.Error(),
+				Locations: []Location{location(req.Query, syntaxErr.pos)},
+			}}}
+		}
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	maxDepth := schema.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	maxComplexity := schema.MaxComplexity
+	if maxComplexity <= 0 {
+		maxComplexity = DefaultMaxComplexity
+	}
+	v := &validator{doc: doc, op: op, src: req.Query, maxDepth: maxDepth, maxComplexity: maxComplexity}
+	v.validate(schema.Query)
+	if len(v.errors) > 0 {
+		return Response{Errors: v.errors}
+	}
+
+	variables, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return Response{Errors: errs}
+	}
+
+	e := &executor{ctx: ctx, doc: doc, src: req.Query, variables: variables}
+	data := e.executeSelections(schema.Query, nil, op.selections, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+// selectOperation picks the operation to run: the named one, or the only
+// one in the document.
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, errors.New("operationName is required when the document contains several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables applies defaults to the provided variables and checks
+// that required ones are present. Only declared variables are kept.
+func coerceVariables(op *operation, provided map[string]any) (map[string]any, []Error) {
+	variables := make(map[string]any, len(op.variables))
+	var errs []Error
+	for _, definition := range op.variables {
+		v, ok := provided[definition.name]
+		if !ok && definition.defaultValue != nil {
+			v, ok = definition.defaultValue.resolve(nil), true
+		}
+		if definition.required && v == nil {
+			errs = append(errs, Error{Message: fmt.Sprintf("variable $%s is required", definition.name)})
+			continue
+		}
+		if ok {
+			variables[definition.name] = v
+		}
+	}
+	return variables, errs
+}
+
+// validator checks a query against the schema before it runs, so that an
+// invalid query fails as a whole rather than field by field.
+type validator struct {
+	doc           *document
+	op            *operation
+	src           string
+	maxDepth      int
+	tooDeep       bool
+	maxComplexity int
+	complexity    int
+	errors        []Error
+}
+
+func (v *validator) errorf(pos int, format string, args ...any) {
+	v.errors = append(v.errors, Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []Location{location(v.src, pos)},
+	})
+}
+
+func (v *validator) validate(query *Object) {
+	v.directives(v.op.directives, false)
+	v.selections(query, v.op.selections, 1, map[string]bool{})
+}
+
+func (v *validator) selections(obj *Object, selections []selection, depth int, spreading map[string]bool) {
+	if depth > v.maxDepth {
+		if !v.tooDeep {
+			v.tooDeep = true
+			v.errorf(selections[0].pos, "query is nested too deeply (at most %d levels)", v.maxDepth)
+		}
+		return
+	}
+
+	for _, sel := range selections {
+		// Fragments spread several times are walked each time, so stop
+		// as soon as the limit is hit rather than after the whole walk.
+		if v.complexity > v.maxComplexity {
+			return
+		}
+		if sel.spread == "" && !sel.inline {
+			v.complexity++
+			if v.complexity > v.maxComplexity {
+				v.errorf(sel.pos, "query selects too many fields (at most %d)", v.maxComplexity)
+				return
+			}
+		}
+		v.directives(sel.directives, true)
+
+		switch {
+		case sel.spread != "":
+			frag, ok := v.doc.fragments[sel.spread]
+			if !ok {
+				v.errorf(sel.pos, "unknown fragment %q", sel.spread)
+				continue
+			}
+			if spreading[frag.name] {
+				v.errorf(sel.pos, "fragment %q spreads itself", frag.name)
+				continue
+			}
+			if frag.typeCondition != obj.Name {
+				v.errorf(sel.pos, "fragment %q on type %q cannot be spread within type %q", frag.name, frag.typeCondition, obj.Name)
+				continue
+			}
+			v.directives(frag.directives, true)
+			spreading[frag.name] = true
+			v.selections(obj, frag.selections, depth, spreading)
+			delete(spreading, frag.name)
+
+		case sel.inline:
+			if sel.typeCondition != "" && sel.typeCondition != obj.Name {
+				v.errorf(sel.pos, "fragment on type %q cannot be spread within type %q", sel.typeCondition, obj.Name)
+				continue
+			}
+			v.selections(obj, sel.selections, depth, spreading)
+
+		case sel.name == "__typename":
+			if len(sel.selections) > 0 {
+				v.errorf(sel.pos, "field %q must not have a selection", sel.name)
+			}
+
+		default:
+			field, ok := obj.Fields[sel.name]
+			if !ok {
+				v.errorf(sel.pos, "cannot query field %q on type %q", sel.name, obj.Name)
+				continue
+			}
+			seen := map[string]bool{}
+			for _, arg := range sel.arguments {
+				if seen[arg.name] {
+					v.errorf(sel.pos, "argument %q is given more than once", arg.name)
+				}
+				seen[arg.name] = true
+				if !slices.Contains(field.Args, arg.name) {
+					v.errorf(sel.pos, "unknown argument %q on field %q of type %q", arg.name, sel.name, obj.Name)
+				}
+				v.variables(arg.value, sel.pos)
+			}
+			switch {
+			case field.Type == nil && len(sel.selections) > 0:
+				v.errorf(sel.pos, "field %q of type %q must not have a selection", sel.name, obj.Name)
+			case field.Type != nil && len(sel.selections) == 0:
+				v.errorf(sel.pos, "field %q of type %q must have a selection of subfields", sel.name, obj.Name)
+			case field.Type != nil:
+				v.selections(field.Type, sel.selections, depth+1, spreading)
+			}
+		}
+	}
+}
+
+// directives checks that only @skip and @include are used, and only on
+// selections.
+func (v *validator) directives(directives []directive, onSelection bool) {
+	for _, d := range directives {
+		if !onSelection || (d.name != "skip" && d.name != "include") {
+			v.errorf(d.pos, "directive @%s is not supported here", d.name)
+			continue
+		}
+		if len(d.arguments) != 1 || d.arguments[0].name != "if" {
+			v.errorf(d.pos, "directive @%s takes exactly the argument \"if\"", d.name)
+			continue
+		}
+		v.variables(d.arguments[0].value, d.pos)
+	}
+}
+
+// variables checks that every variable used in val is declared.
+func (v *validator) variables(val value, pos int) {
+	switch val.kind {
+	case valueVariable:
+		for _, definition := range v.op.variables {
+			if definition.name == val.variable {
+				return
+			}
+		}
+		v.errorf(pos, "variable $%s is not defined", val.variable)
+	case valueList:
+		for _, item := range val.list {
+			v.variables(item, pos)
+		}
+	case valueObject:
+		for _, field := range val.object {
+			v.variables(field.value, pos)
+		}
+	}
+}
+
+type executor struct {
+	ctx       context.Context
+	doc       *document
+	src       string
+	variables map[string]any
+	errors    []Error
+}
+
+// collectedField is a response key and the field selections merged into
+// it.
+type collectedField struct {
+	key   string
+	nodes []selection
+}
+
+func (e *executor) executeSelections(obj *Object, source any, selections []selection, path []any) *orderedMap {
+	result := &orderedMap{}
+	for _, field := range e.collectFields(obj, selections, nil, map[string]bool{}) {
+		node := field.nodes[0]
+		fieldPath := append(path[:len(path):len(path)], field.key)
+
+		if node.name == "__typename" {
+			result.set(field.key, obj.Name)
+			continue
+		}
+		definition := obj.Fields[node.name]
+
+		args := make(map[string]any, len(node.arguments))
+		for _, arg := range node.arguments {
+			if arg.value.kind == valueVariable {
+				if _, ok := e.variables[arg.value.variable]; !ok {
+					continue
+				}
+			}
+			args[arg.name] = arg.value.resolve(e.variables)
+		}
+
+		value, err := e.resolve(definition, Params{Context: e.ctx, Source: source, Args: args}, node.name)
+		if err != nil {
+			e.fieldError(err, node.pos, fieldPath)
+			result.set(field.key, nil)
+			continue
+		}
+
+		var subselections []selection
+		for _, n := range field.nodes {
+			subselections = append(subselections, n.selections...)
+		}
+		result.set(field.key, e.complete(definition.Type, value, subselections, fieldPath))
+	}
+	return result
+}
+
+// collectFields flattens fragments and groups the selected fields by
+// response key, in the order they first appear.
+func (e *executor) collectFields(obj *Object, selections []selection, fields []*collectedField, visited map[string]bool) []*collectedField {
+	for _, sel := range selections {
+		if !e.included(sel.directives) {
+			continue
+		}
+		switch {
+		case sel.spread != "":
+			if visited[sel.spread] {
+				continue
+			}
+			visited[sel.spread] = true
+			frag := e.doc.fragments[sel.spread]
+			if e.included(frag.directives) {
+				fields = e.collectFields(obj, frag.selections, fields, visited)
+			}
+		case sel.inline:
+			fields = e.collectFields(obj, sel.selections, fields, visited)
+		default:
+			key := sel.responseKey()
+			merged := false
+			for _, field := range fields {
+				if field.key != key {
+					continue
+				}
+				if field.nodes[0].name != sel.name {
+					e.fieldError(fmt.Errorf("fields %q and %q conflict because both are returned as %q; use different aliases", field.nodes[0].name, sel.name, key), sel.pos, nil)
+				} else {
+					field.nodes = append(field.nodes, sel)
+				}
+				merged = true
+				break
+			}
+			if !merged {
+				fields = append(fields, &collectedField{key: key, nodes: []selection{sel}})
+			}
+		}
+	}
+	return fields
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(directives []directive) bool {
+	for _, d := range directives {
+		condition := d.arguments[0].value.resolve(e.variables) == true
+		if d.name == "skip" && condition || d.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *executor) resolve(field *Field, p Params, name string) (any, error) {
+	if field.Resolve != nil {
+		return field.Resolve(p)
+	}
+	return defaultResolve(p.Source, name), nil
+}
+
+// complete selects the fields of object values. Scalars are returned as
+// is.
+func (e *executor) complete(typ *Object, value any, selections []selection, path []any) any {
+	if typ == nil {
+		return value
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if rv.Kind() == reflect.Slice {
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = e.complete(typ, rv.Index(i).Interface(), selections, append(path[:len(path):len(path)], i))
+		}
+		return items
+	}
+	return e.executeSelections(typ, rv.Interface(), selections, path)
+}
+
+func (e *executor) fieldError(err error, pos int, path []any) {
+	gqlErr := Error{
+		Message:   err.Error(),
+		Locations: []Location{location(e.src, pos)},
+		Path:      path,
+	}
+	var extErr extensionsError
+	if errors.As(err, &extErr) {
+		gqlErr.Extensions = extErr.Extensions()
+	}
+	e.errors = append(e.errors, gqlErr)
+}
+
+// defaultResolve reads the field name from a struct, by JSON name, or from
+// a map with string keys.
+func defaultResolve(source any, name string) any {
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil
+		}
+		return v.Interface()
+	case reflect.Struct:
+		want := snakeCase(name)
+		for _, f := range reflect.VisibleFields(rv.Type()) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if tag == want {
+				return rv.FieldByIndex(f.Index).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// snakeCase converts a camelCase field name to snake_case.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// orderedMap is a JSON object that keeps its keys in insertion order, as
+// GraphQL responses list fields in the order they were selected.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if m.values == nil {
+		m.values = map[string]any{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		encodedValue, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,279 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type testBook struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	PageCount int    `json:"page_count"`
+	AuthorID  int    `json:"author_id"`
+}
+
+type codedError struct{ code string }
+
+func (e codedError) Error() string              { return "failed: " + e.code }
+func (e codedError) Extensions() map[string]any { return map[string]any{"code": e.code} }
+
+func testSchema() *Schema {
+	authors := map[int]testAuthor{1: {ID: 1, Name: "Knuth"}}
+	books := []testBook{
+		{ID: 1, Title: "TAOCP", PageCount: 672, AuthorID: 1},
+		{ID: 2, Title: "Concrete Mathematics", PageCount: 657, AuthorID: 1},
+	}
+
+	author := &Object{Name: "Author"}
+	book := &Object{Name: "Book"}
+	author.Fields = Fields{
+		"id":   {},
+		"name": {},
+		"books": {Type: book, Args: []string{"limit"}, Resolve: func(p Params) (any, error) {
+			limit, err := p.Int("limit", len(books))
+			if err != nil {
+				return nil, err
+			}
+			return books[:min(limit, len(books))], nil
+		}},
+	}
+	book.Fields = Fields{
+		"id":        {},
+		"title":     {},
+		"pageCount": {},
+		"author": {Type: author, Resolve: func(p Params) (any, error) {
+			return authors[p.Source.(testBook).AuthorID], nil
+		}},
+	}
+	query := &Object{Name: "Query", Fields: Fields{
+		"book": {Type: book, Args: []string{"id"}, Resolve: func(p Params) (any, error) {
+			id, err := p.Int("id", 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range books {
+				if b.ID == id {
+					return b, nil
+				}
+			}
+			return nil, codedError{code: "NOT_FOUND"}
+		}},
+		"books": {Type: book, Resolve: func(Params) (any, error) {
+			return books, nil
+		}},
+		"greeting": {Args: []string{"name", "loud"}, Resolve: func(p Params) (any, error) {
+			name, err := p.String("name")
+			if err != nil {
+				return nil, err
+			}
+			loud, err := p.Bool("loud", false)
+			if err != nil {
+				return nil, err
+			}
+			greeting := "hello " + name
+			if loud {
+				greeting = strings.ToUpper(greeting)
+			}
+			return greeting, nil
+		}},
+	}}
+	return &Schema{Query: query, MaxDepth: 4, MaxComplexity: 20}
+}
+
+// run executes req and returns the response encoded as JSON, which is
+// how handlers return it and keeps the field order of the data.
+func run(t *testing.T, schema *Schema, req Request) string {
+	t.Helper()
+	data, err := json.Marshal(Execute(context.Background(), schema, req))
+	if err != nil {
+		t.Fatalf("encode response: %v", err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "nested selections",
+			req:  Request{Query: `{ book(id: 1) { title pageCount author { name books(limit: 1) { id } } } }`},
+			want: `{"data":{"book":{"title":"TAOCP","pageCount":672,"author":{"name":"Knuth","books":[{"id":1}]}}}}`,
+		},
+		{
+			name: "aliases",
+			req:  Request{Query: `{ a: book(id: 1) { t: title } b: book(id: 2) { t: title } }`},
+			want: `{"data":{"a":{"t":"TAOCP"},"b":{"t":"Concrete Mathematics"}}}`,
+		},
+		{
+			name: "variables and defaults",
+			req: Request{
+				Query:     `query ($id: Int!, $name: String = "you", $loud: Boolean) { book(id: $id) { id } greeting(name: $name, loud: $loud) }`,
+				Variables: map[string]any{"id": float64(2), "loud": true},
+			},
+			want: `{"data":{"book":{"id":2},"greeting":"HELLO YOU"}}`,
+		},
+		{
+			name: "fragments merge into one field",
+			req: Request{Query: `
+				{ book(id: 1) { ...Title ... on Book { id } author { id } author { name } } }
+				fragment Title on Book { title }
+			`},
+			want: `{"data":{"book":{"title":"TAOCP","id":1,"author":{"id":1,"name":"Knuth"}}}}`,
+		},
+		{
+			name: "skip and include",
+			req: Request{
+				Query:     `query ($on: Boolean!) { book(id: 1) { id @skip(if: $on) title @include(if: $on) pageCount @include(if: false) } }`,
+				Variables: map[string]any{"on": true},
+			},
+			want: `{"data":{"book":{"title":"TAOCP"}}}`,
+		},
+		{
+			name: "typename",
+			req:  Request{Query: `{ __typename book(id: 1) { __typename } }`},
+			want: `{"data":{"__typename":"Query","book":{"__typename":"Book"}}}`,
+		},
+		{
+			name: "lists",
+			req:  Request{Query: `{ books { id } }`},
+			want: `{"data":{"books":[{"id":1},{"id":2}]}}`,
+		},
+		{
+			name: "resolver error keeps other fields",
+			req:  Request{Query: "{\n  missing: book(id: 9) { id }\n  book(id: 1) { id }\n}"},
+			want: `{"data":{"missing":null,"book":{"id":1}},"errors":[{"message":"failed: NOT_FOUND","locations":[{"line":2,"column":3}],"path":["missing"],"extensions":{"code":"NOT_FOUND"}}]}`,
+		},
+		{
+			name: "argument of the wrong type",
+			req:  Request{Query: `{ greeting(name: 3) }`},
+			want: `{"data":{"greeting":null},"errors":[{"message":"argument \"name\" must be a string","locations":[{"line":1,"column":3}],"path":["greeting"]}]}`,
+		},
+		{
+			name: "named operation",
+			req:  Request{Query: `query A { greeting } query B { books { id } }`, OperationName: "A"},
+			want: `{"data":{"greeting":"hello "}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, testSchema(), tt.req); got != tt.want {
+				t.Fatalf("response = %s\nwant       %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExecuteRejects checks requests that fail as a whole: they get
+// errors and no data, and no resolver runs.
+func TestExecuteRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     Request
+		message string
+	}{
+		{name: "syntax error", req: Request{Query: `{ book(id: 1) { id }`}, message: "syntax error: unexpected end of document"},
+		{name: "unknown field", req: Request{Query: `{ book(id: 1) { isbn } }`}, message: `cannot query field "isbn" on type "Book"`},
+		{name: "unknown argument", req: Request{Query: `{ book(id: 1, isbn: "x") { id } }`}, message: `unknown argument "isbn" on field "book" of type "Query"`},
+		{name: "repeated argument", req: Request{Query: `{ book(id: 1, id: 2) { id } }`}, message: `argument "id" is given more than once`},
+		{name: "selection on scalar", req: Request{Query: `{ greeting { id } }`}, message: `field "greeting" of type "Query" must not have a selection`},
+		{name: "missing selection", req: Request{Query: `{ book(id: 1) }`}, message: `field "book" of type "Query" must have a selection of subfields`},
+		{name: "undefined variable", req: Request{Query: `{ book(id: $id) { id } }`}, message: "variable $id is not defined"},
+		{name: "missing required variable", req: Request{Query: `query ($id: Int!) { book(id: $id) { id } }`}, message: "variable $id is required"},
+		{name: "unknown fragment", req: Request{Query: `{ book(id: 1) { ...Missing } }`}, message: `unknown fragment "Missing"`},
+		{name: "fragment on other type", req: Request{Query: `{ book(id: 1) { ...A } } fragment A on Author { id }`}, message: `fragment "A" on type "Author" cannot be spread within type "Book"`},
+		{name: "fragment cycle", req: Request{Query: `{ book(id: 1) { ...A } } fragment A on Book { id ...B } fragment B on Book { ...A }`}, message: `fragment "A" spreads itself`},
+		{name: "unsupported directive", req: Request{Query: `{ book(id: 1) @deprecated { id } }`}, message: "directive @deprecated is not supported here"},
+		{name: "ambiguous operation", req: Request{Query: `query A { greeting } query B { greeting }`}, message: "operationName is required when the document contains several operations"},
+		{name: "unknown operation", req: Request{Query: `query A { greeting }`, OperationName: "B"}, message: `unknown operation "B"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Execute(context.Background(), testSchema(), tt.req)
+			if resp.Data != nil {
+				t.Fatalf("data = %v, want none", resp.Data)
+			}
+			if len(resp.Errors) == 0 || resp.Errors[0].Message != tt.message {
+				t.Fatalf("errors = %+v, want %q", resp.Errors, tt.message)
+			}
+		})
+	}
+}
+
+func TestExecuteDepthLimit(t *testing.T) {
+	schema := testSchema()
+	// book > author > books > id is 4 levels, the schema's limit.
+	ok := `{ book(id: 1) { author { books { id } } } }`
+	if resp := Execute(context.Background(), schema, Request{Query: ok}); len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v, want none", resp.Errors)
+	}
+
+	tests := map[string]string{
+		"fields":           `{ book(id: 1) { author { books { author { name } } } } }`,
+		"through fragment": `{ book(id: 1) { author { ...Deep } } } fragment Deep on Author { books { author { name } } }`,
+		"inline fragment":  `{ book(id: 1) { author { ... on Author { books { author { name } } } } } }`,
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := Execute(context.Background(), schema, Request{Query: query})
+			if resp.Data != nil || len(resp.Errors) != 1 {
+				t.Fatalf("response = %+v, want one error and no data", resp)
+			}
+			if want := "query is nested too deeply (at most 4 levels)"; resp.Errors[0].Message != want {
+				t.Fatalf("message = %q, want %q", resp.Errors[0].Message, want)
+			}
+		})
+	}
+}
+
+func TestExecuteComplexityLimit(t *testing.T) {
+	schema := testSchema()
+	aliases := func(n int) string {
+		var b strings.Builder
+		b.WriteString("{")
+		for i := range n {
+			fmt.Fprintf(&b, " b%d: book(id: 1) { id }", i)
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	// Each aliased book selects two fields, against a limit of 20.
+	if resp := Execute(context.Background(), schema, Request{Query: aliases(10)}); len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v, want none", resp.Errors)
+	}
+
+	// Fragments that spread the next one twice select 2^30 fields; the
+	// check must stop at the limit instead of walking them all.
+	var bomb strings.Builder
+	bomb.WriteString("{ book(id: 1) { ...F0 } }")
+	for i := range 30 {
+		fmt.Fprintf(&bomb, " fragment F%d on Book { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	bomb.WriteString(" fragment F30 on Book { id }")
+
+	tests := map[string]string{
+		"aliases":   aliases(11),
+		"fragments": bomb.String(),
+	}
+	for name, query := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := Execute(context.Background(), schema, Request{Query: query})
+			if resp.Data != nil || len(resp.Errors) != 1 {
+				t.Fatalf("response = %+v, want one error and no data", resp)
+			}
+			if want := "query selects too many fields (at most 20)"; resp.Errors[0].Message != want {
+				t.Fatalf("message = %q, want %q", resp.Errors[0].Message, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,730 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed GraphQL request document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	name       string
+	variables  []variableDefinition
+	directives []directive
+	selections []selection
+	pos        int
+}
+
+type variableDefinition struct {
+	name         string
+	required     bool
+	defaultValue *value
+}
+
+type fragment struct {
+	name          string
+	typeCondition string
+	directives    []directive
+	selections    []selection
+	pos           int
+}
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection struct {
+	// Field.
+	alias      string
+	name       string
+	arguments  []argument
+	selections []selection
+
+	// Fragment spread (spread set) or inline fragment (inline set).
+	spread        string
+	inline        bool
+	typeCondition string
+
+	directives []directive
+	pos        int
+}
+
+// responseKey is the key a field's value is returned under.
+func (s selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value value
+}
+
+type directive struct {
+	name      string
+	arguments []argument
+	pos       int
+}
+
+type valueKind int
+
+const (
+	valueLiteral valueKind = iota
+	valueVariable
+	valueList
+	valueObject
+)
+
+// value is an argument value. Literals hold their Go form: int, float64,
+// string, bool, or nil; enum values are strings.
+type value struct {
+	kind     valueKind
+	literal  any
+	variable string
+	list     []value
+	object   []argument
+}
+
+// resolve returns the Go form of v, substituting variables. Lists become
+// []any and objects map[string]any.
+func (v value) resolve(variables map[string]any) any {
+	switch v.kind {
+	case valueVariable:
+		return variables[v.variable]
+	case valueList:
+		list := make([]any, len(v.list))
+		for i, item := range v.list {
+			list[i] = item.resolve(variables)
+		}
+		return list
+	case valueObject:
+		object := make(map[string]any, len(v.object))
+		for _, field := range v.object {
+			object[field.name] = field.value.resolve(variables)
+		}
+		return object
+	default:
+		return v.literal
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// syntaxError is a parse error at a byte offset of the source.
+type syntaxError struct {
+	message string
+	pos     int
+}
+
+func (e *syntaxError) Error() string {
+	return "syntax error: " + e.message
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// parse parses a request document. Only queries are supported.
+func parse(src string) (*document, error) {
+	p := &parser{src: src}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokenName && p.tok.value == "query":
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokenName && (p.tok.value == "mutation" || p.tok.value == "subscription"):
+			return nil, &syntaxError{message: p.tok.value + " operations are not supported", pos: p.tok.pos}
+		case p.tok.kind == tokenName && p.tok.value == "fragment":
+			frag, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[frag.name]; ok {
+				return nil, &syntaxError{message: fmt.Sprintf("fragment %q is defined more than once", frag.name), pos: frag.pos}
+			}
+			doc.fragments[frag.name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &syntaxError{message: "document contains no operation", pos: p.tok.pos}
+	}
+	return doc, nil
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{pos: p.tok.pos}
+	if p.peek("{") {
+		selections, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		op.selections = selections
+		return op, nil
+	}
+
+	// The "query" keyword.
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName {
+		op.name = p.tok.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		variables, err := p.parseVariableDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		op.variables = variables
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	op.directives = directives
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinitions() ([]variableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var definitions []variableDefinition
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		required, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		definition := variableDefinition{name: name, required: required}
+		if p.peek("=") {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue(true)
+			if err != nil {
+				return nil, err
+			}
+			definition.defaultValue = &v
+		}
+		if _, err := p.parseDirectives(); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+	return definitions, p.advance()
+}
+
+// parseType parses a type reference and reports whether it is non-null.
+// Types are otherwise not checked; arguments are coerced by resolvers.
+func (p *parser) parseType() (bool, error) {
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return false, err
+	}
+	if p.peek("!") {
+		return true, p.advance()
+	}
+	return false, nil
+}
+
+func (p *parser) parseFragment() (*fragment, error) {
+	frag := &fragment{pos: p.tok.pos}
+	// The "fragment" keyword.
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, &syntaxError{message: `fragment cannot be named "on"`, pos: frag.pos}
+	}
+	frag.name = name
+	if p.tok.kind != tokenName || p.tok.value != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if frag.typeCondition, err = p.expectName(); err != nil {
+		return nil, err
+	}
+	if frag.directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if frag.selections, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+func (p *parser) parseSelectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if p.peek("}") {
+		return nil, &syntaxError{message: "selection set is empty", pos: p.tok.pos}
+	}
+	var selections []selection
+	for !p.peek("}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) parseSelection() (selection, error) {
+	sel := selection{pos: p.tok.pos}
+	var err error
+
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return selection{}, err
+		}
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			sel.spread = p.tok.value
+			if err := p.advance(); err != nil {
+				return selection{}, err
+			}
+			sel.directives, err = p.parseDirectives()
+			return sel, err
+		}
+		sel.inline = true
+		if p.tok.kind == tokenName {
+			if err := p.advance(); err != nil {
+				return selection{}, err
+			}
+			if sel.typeCondition, err = p.expectName(); err != nil {
+				return selection{}, err
+			}
+		}
+		if sel.directives, err = p.parseDirectives(); err != nil {
+			return selection{}, err
+		}
+		sel.selections, err = p.parseSelectionSet()
+		return sel, err
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return selection{}, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return selection{}, err
+		}
+		sel.alias = name
+		if name, err = p.expectName(); err != nil {
+			return selection{}, err
+		}
+	}
+	sel.name = name
+	if p.peek("(") {
+		if sel.arguments, err = p.parseArguments(false); err != nil {
+			return selection{}, err
+		}
+	}
+	if sel.directives, err = p.parseDirectives(); err != nil {
+		return selection{}, err
+	}
+	if p.peek("{") {
+		if sel.selections, err = p.parseSelectionSet(); err != nil {
+			return selection{}, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) parseArguments(constant bool) ([]argument, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var arguments []argument
+	for !p.peek(")") {
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.parseValue(constant)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument{name: name, value: v})
+	}
+	return arguments, p.advance()
+}
+
+func (p *parser) parseDirectives() ([]directive, error) {
+	var directives []directive
+	for p.peek("@") {
+		d := directive{pos: p.tok.pos}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		d.name = name
+		if p.peek("(") {
+			if d.arguments, err = p.parseArguments(false); err != nil {
+				return nil, err
+			}
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// parseValue parses a value. Variables are not allowed in constant
+// values, such as variable defaults.
+func (p *parser) parseValue(constant bool) (value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			return value{}, &syntaxError{message: fmt.Sprintf("integer %s out of range", tok.value), pos: tok.pos}
+		}
+		return value{literal: n}, p.advance()
+	case tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return value{}, &syntaxError{message: fmt.Sprintf("invalid number %s", tok.value), pos: tok.pos}
+		}
+		return value{literal: f}, p.advance()
+	case tokenString:
+		return value{literal: tok.value}, p.advance()
+	case tokenName:
+		var literal any
+		switch tok.value {
+		case "true":
+			literal = true
+		case "false":
+			literal = false
+		case "null":
+			literal = nil
+		default:
+			literal = tok.value
+		}
+		return value{literal: literal}, p.advance()
+	}
+
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return value{}, err
+		}
+		name, err := p.expectName()
+		if err != nil {
+			return value{}, err
+		}
+		return value{kind: valueVariable, variable: name}, nil
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return value{}, err
+		}
+		v := value{kind: valueList}
+		for !p.peek("]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return value{}, err
+			}
+			v.list = append(v.list, item)
+		}
+		return v, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return value{}, err
+		}
+		v := value{kind: valueObject}
+		for !p.peek("}") {
+			name, err := p.expectName()
+			if err != nil {
+				return value{}, err
+			}
+			if err := p.expect(":"); err != nil {
+				return value{}, err
+			}
+			field, err := p.parseValue(constant)
+			if err != nil {
+				return value{}, err
+			}
+			v.object = append(v.object, argument{name: name, value: field})
+		}
+		return v, p.advance()
+	}
+	return value{}, p.unexpected()
+}
+
+func (p *parser) peek(punctuator string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == punctuator
+}
+
+func (p *parser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return &syntaxError{message: "unexpected end of document", pos: p.tok.pos}
+	}
+	return &syntaxError{message: fmt.Sprintf("unexpected %q", p.tok.value), pos: p.tok.pos}
+}
+
+// advance reads the next token, skipping whitespace, commas and comments.
+func (p *parser) advance() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := p.pos
+	if start >= len(p.src) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[start]
+	switch {
+	case strings.HasPrefix(p.src[start:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokenPunctuator, value: "...", pos: start}
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		return p.lexNumber()
+	case c == '"':
+		return p.lexString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[start:])
+		return &syntaxError{message: fmt.Sprintf("unexpected character %q", r), pos: start}
+	}
+	return nil
+}
+
+func (p *parser) lexNumber() error {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() int {
+		n := 0
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return &syntaxError{message: "invalid number", pos: start}
+	}
+	kind := tokenInt
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		kind = tokenFloat
+		if digits() == 0 {
+			return &syntaxError{message: "invalid number", pos: start}
+		}
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		kind = tokenFloat
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			return &syntaxError{message: "invalid number", pos: start}
+		}
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == '_' || p.src[p.pos] == '.' || isLetter(p.src[p.pos])) {
+		return &syntaxError{message: "invalid number", pos: start}
+	}
+	p.tok = token{kind: kind, value: p.src[start:p.pos], pos: start}
+	return nil
+}
+
+func (p *parser) lexString() error {
+	start := p.pos
+	if strings.HasPrefix(p.src[start:], `"""`) {
+		return &syntaxError{message: "block strings are not supported", pos: start}
+	}
+	p.pos++
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			return &syntaxError{message: "unterminated string", pos: start}
+		}
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			p.tok = token{kind: tokenString, value: b.String(), pos: start}
+			return nil
+		case '\\':
+			if p.pos+1 >= len(p.src) {
+				return &syntaxError{message: "unterminated string", pos: start}
+			}
+			escape := p.src[p.pos+1]
+			p.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					return &syntaxError{message: "invalid unicode escape", pos: p.pos - 2}
+				}
+				code, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return &syntaxError{message: "invalid unicode escape", pos: p.pos - 2}
+				}
+				b.WriteRune(rune(code))
+				p.pos += 4
+			default:
+				return &syntaxError{message: fmt.Sprintf("invalid escape \\%c", escape), pos: p.pos - 2}
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// location converts a byte offset of src to a line and column, both
+// starting at 1.
+func location(src string, pos int) Location {
+	loc := Location{Line: 1, Column: 1}
+	for i, r := range src {
+		if i >= pos {
+			break
+		}
+		if r == '\n' {
+			loc.Line++
+			loc.Column = 1
+		} else {
+			loc.Column++
+		}
+	}
+	return loc
+}
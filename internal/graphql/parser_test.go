@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNestedSelections(t *testing.T) {
+	doc, err := parse(`{ problem(id: 1) { id submissions { items { id user { username } } } } }`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(doc.operations) != 1 {
+		t.Fatalf("operations = %d, want 1", len(doc.operations))
+	}
+
+	var names []string
+	var walk func(selections []selection, prefix string)
+	walk = func(selections []selection, prefix string) {
+		for _, sel := range selections {
+			names = append(names, prefix+sel.name)
+			walk(sel.selections, prefix+sel.name+".")
+		}
+	}
+	walk(doc.operations[0].selections, "")
+	want := []string{
+		"problem",
+		"problem.id",
+		"problem.submissions",
+		"problem.submissions.items",
+		"problem.submissions.items.id",
+		"problem.submissions.items.user",
+		"problem.submissions.items.user.username",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("fields = %q, want %q", names, want)
+	}
+}
+
+func TestParseArguments(t *testing.T) {
+	doc, err := parse(`{ f(i: -12, fl: 1.5e2, s: "a\"bé\n", t: true, n: null, e: HTML, l: [1, "x"], o: {k: {v: 2}}) }`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	got := map[string]any{}
+	for _, arg := range doc.operations[0].selections[0].arguments {
+		got[arg.name] = arg.value.resolve(nil)
+	}
+	want := map[string]any{
+		"i":  -12,
+		"fl": 150.0,
+		"s":  "a\"bé\n",
+		"t":  true,
+		"n":  nil,
+		"e":  "HTML",
+		"l":  []any{1, "x"},
+		"o":  map[string]any{"k": map[string]any{"v": 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("arguments = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	doc, err := parse(`query Page($id: Int!, $limit: Int = 20, $tags: [String!]) {
+		problem(id: $id) { submissions(limit: $limit, tags: [$tags]) { total } }
+	}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	op := doc.operations[0]
+	if op.name != "Page" {
+		t.Fatalf("name = %q, want Page", op.name)
+	}
+	if len(op.variables) != 3 {
+		t.Fatalf("variables = %+v, want 3", op.variables)
+	}
+	id, limit, tags := op.variables[0], op.variables[1], op.variables[2]
+	if id.name != "id" || !id.required || id.defaultValue != nil {
+		t.Fatalf("$id = %+v", id)
+	}
+	if limit.name != "limit" || limit.required || limit.defaultValue == nil || limit.defaultValue.resolve(nil) != 20 {
+		t.Fatalf("$limit = %+v", limit)
+	}
+	if tags.name != "tags" || tags.required {
+		t.Fatalf("$tags = %+v", tags)
+	}
+
+	variables := map[string]any{"id": 7, "limit": 5, "tags": "dp"}
+	problem := op.selections[0]
+	if got := problem.arguments[0].value.resolve(variables); got != 7 {
+		t.Fatalf("id = %v, want 7", got)
+	}
+	submissions := problem.selections[0]
+	if got := submissions.arguments[0].value.resolve(variables); got != 5 {
+		t.Fatalf("limit = %v, want 5", got)
+	}
+	if got := submissions.arguments[1].value.resolve(variables); !reflect.DeepEqual(got, []any{"dp"}) {
+		t.Fatalf("tags = %v, want [dp]", got)
+	}
+}
+
+func TestParseAliasesAndFragments(t *testing.T) {
+	doc, err := parse(`
+		query {
+			first: problem(id: 1) { ...Summary }
+			second: problem(id: 2) { ... on Problem @include(if: true) { id } }
+		}
+		fragment Summary on Problem { title }
+	`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	first, second := doc.operations[0].selections[0], doc.operations[0].selections[1]
+	if first.alias != "first" || first.name != "problem" || first.responseKey() != "first" {
+		t.Fatalf("first = %+v", first)
+	}
+	if second.responseKey() != "second" {
+		t.Fatalf("second key = %q", second.responseKey())
+	}
+	if first.selections[0].spread != "Summary" {
+		t.Fatalf("spread = %+v", first.selections[0])
+	}
+	inline := second.selections[0]
+	if !inline.inline || inline.typeCondition != "Problem" || len(inline.directives) != 1 || inline.directives[0].name != "include" {
+		t.Fatalf("inline fragment = %+v", inline)
+	}
+	frag := doc.fragments["Summary"]
+	if frag == nil || frag.typeCondition != "Problem" || frag.selections[0].name != "title" {
+		t.Fatalf("fragment = %+v", frag)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		message string
+		loc     Location
+	}{
+		{name: "empty", src: "", message: "document contains no operation", loc: Location{Line: 1, Column: 1}},
+		{name: "only fragment", src: "fragment F on Problem { id }", message: "document contains no operation", loc: Location{Line: 1, Column: 29}},
+		{name: "unclosed selection", src: "{ problem(id: 1) { id }", message: "unexpected end of document", loc: Location{Line: 1, Column: 24}},
+		{name: "empty selection", src: "{ problem { } }", message: "selection set is empty", loc: Location{Line: 1, Column: 13}},
+		{name: "missing argument value", src: "{ problem(id: ) { id } }", message: `unexpected ")"`, loc: Location{Line: 1, Column: 15}},
+		{name: "variable in default", src: "query ($a: Int = $b) { id }", message: `unexpected "$"`, loc: Location{Line: 1, Column: 18}},
+		{name: "mutation", src: "mutation { id }", message: "mutation operations are not supported", loc: Location{Line: 1, Column: 1}},
+		{name: "subscription", src: "subscription { id }", message: "subscription operations are not supported", loc: Location{Line: 1, Column: 1}},
+		{name: "duplicate fragment", src: "{ id } fragment F on Q { id } fragment F on Q { id }", message: `fragment "F" is defined more than once`, loc: Location{Line: 1, Column: 31}},
+		{name: "fragment named on", src: "{ id } fragment on on Q { id }", message: `fragment cannot be named "on"`, loc: Location{Line: 1, Column: 8}},
+		{name: "unterminated string", src: "{ user(username: \"ab\n\") { id } }", message: "unterminated string", loc: Location{Line: 1, Column: 18}},
+		{name: "bad escape", src: `{ user(username: "\q") { id } }`, message: `invalid escape \q`, loc: Location{Line: 1, Column: 19}},
+		{name: "bad unicode escape", src: `{ user(username: "\u00zz") { id } }`, message: "invalid unicode escape", loc: Location{Line: 1, Column: 19}},
+		{name: "block string", src: `{ user(username: """x""") { id } }`, message: "block strings are not supported", loc: Location{Line: 1, Column: 18}},
+		{name: "bad number", src: "{ problem(id: 1x) { id } }", message: "invalid number", loc: Location{Line: 1, Column: 15}},
+		{name: "integer overflow", src: "{ problem(id: 99999999999999999999) { id } }", message: "integer 99999999999999999999 out of range", loc: Location{Line: 1, Column: 15}},
+		{name: "bad character", src: "{ id ? }", message: `unexpected character '?'`, loc: Location{Line: 1, Column: 6}},
+		{name: "position on later line", src: "{\n  problem(id: 1) {\n    id\n  }\n  ]\n}", message: `unexpected "]"`, loc: Location{Line: 5, Column: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.src)
+			var syntaxErr *syntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, want a syntax error", err)
+			}
+			if syntaxErr.message != tt.message {
+				t.Fatalf("message = %q, want %q", syntaxErr.message, tt.message)
+			}
+			if got := location(tt.src, syntaxErr.pos); got != tt.loc {
+				t.Fatalf("location = %+v, want %+v", got, tt.loc)
+			}
+			if !strings.HasPrefix(err.Error(), "syntax error: ") {
+				t.Fatalf("error = %q", err)
+			}
+		})
+	}
+}
+
+func TestParseIgnoresCommentsAndCommas(t *testing.T) {
+	doc, err := parse("\uFEFF# leading comment\n{ a, b # trailing\n ,c }")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var names []string
+	for _, sel := range doc.operations[0].selections {
+		names = append(names, sel.name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Fatalf("fields = %q, want [a b c]", names)
+	}
+}
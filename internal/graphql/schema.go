@@ -0,0 +1,156 @@
+// Package graphql executes read-only GraphQL queries against a schema of
+// Go resolvers. It implements the parts of the language clients use to
+// fetch data (operations, variables, aliases, fragments and the @skip and
+// @include directives) but not mutations, subscriptions or introspection
+// beyond __typename.
+//
+// Types are not declared for scalars: a field either resolves to an object
+// type, whose fields are selected in turn, or to a value that is encoded
+// as JSON as is.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Schema is the root of a GraphQL schema.
+type Schema struct {
+	Query *Object
+
+	// MaxDepth limits how deeply selections may nest. Zero means
+	// DefaultMaxDepth.
+	MaxDepth int
+
+	// MaxComplexity limits how many fields a query may select, counting
+	// the fields of a fragment each time it is spread. Zero means
+	// DefaultMaxComplexity.
+	MaxComplexity int
+}
+
+const (
+	// DefaultMaxDepth is the selection depth allowed when Schema.MaxDepth
+	// is not set.
+	DefaultMaxDepth = 10
+
+	// DefaultMaxComplexity is the number of fields a query may select when
+	// Schema.MaxComplexity is not set.
+	DefaultMaxComplexity = 1000
+)
+
+// Object is an object type.
+type Object struct {
+	Name   string
+	Fields Fields
+}
+
+// Fields maps field names to their definitions.
+type Fields map[string]*Field
+
+// Field is a field of an object type.
+type Field struct {
+	// Type is the object type of the field's value, or of its elements
+	// when the value is a slice. It is nil for scalar fields.
+	Type *Object
+
+	// Args names the arguments the field accepts.
+	Args []string
+
+	// Resolve returns the field's value. When nil the value is read from
+	// the struct field of the source whose JSON name is the snake_case
+	// form of the field name, so "timeLimit" reads `json:"time_limit"`.
+	Resolve func(p Params) (any, error)
+}
+
+// Params are passed to resolvers.
+type Params struct {
+	Context context.Context
+
+	// Source is the value of the object the field belongs to; nil for
+	// fields of the query type.
+	Source any
+
+	// Args holds the arguments given in the query, with variables
+	// substituted. Omitted arguments are absent.
+	Args map[string]any
+}
+
+// Int returns the integer argument name, or def when it was not given.
+func (p Params) Int(name string, def int) (int, error) {
+	raw, ok := p.Args[name]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	switch v := raw.(type) {
+	case int:
+		return v, nil
+	case float64:
+		// JSON variables decode as float64.
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// String returns the string argument name, or "" when it was not given.
+func (p Params) String(name string) (string, error) {
+	raw, ok := p.Args[name]
+	if !ok || raw == nil {
+		return "", nil
+	}
+	v, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// Bool returns the boolean argument name, or def when it was not given.
+func (p Params) Bool(name string, def bool) (bool, error) {
+	raw, ok := p.Args[name]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	v, ok := raw.(bool)
+	if !ok {
+		return false, fmt.Errorf("argument %q must be a boolean", name)
+	}
+	return v, nil
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of executing a request. Data is omitted when the
+// request failed before execution, such as on a syntax error.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations,omitempty"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// Location is a position in the query document.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// extensionsError is implemented by resolver errors that carry
+// extensions, such as an error code, to report with the message.
+type extensionsError interface {
+	error
+	Extensions() map[string]any
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AbuseGuard rejects requests from principals blocked by detector with a
+// 429 and records every other request's status. Requests bearing a valid
+// token are attributed to its user and the rest to the client IP, so it
+// must run after RealIP and ResolveTenant. Admins of the
+// request's tenant are never tracked, so they can always lift blocks.
+func AbuseGuard(detector *services.AbuseDetector, keys *JWTKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, admin := requestPrincipal(r, keys)
+			if admin {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if until, blocked := detector.Check(principal); blocked {
+				retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+				writeErrorCode(w, http.StatusTooManyRequests, CodeAbuseBlocked, "too many requests")
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			detector.Record(r.Context(), principal, status)
+		})
+	}
+}
+
+// requestPrincipal identifies who made r and whether they are an admin.
+// The token is only verified, not checked against its session, as the auth
+// middleware does later.
+func requestPrincipal(r *http.Request, keys *JWTKeys) (string, bool) {
+	if token, err := bearerToken(r); err == nil {
+		if claims, err := parseToken(token, keys); err == nil {
+			admin := strings.EqualFold(claims.Role, adminRole) && claims.tenant() == requestTenant(r)
+			return services.UserPrincipal(claims.Subject), admin
+		}
+	}
+	return services.IPPrincipal(clientIP(r)), false
+}
+
+// ListAbuseBlocks returns the principals blocked by the abuse detector.
+// Blocks apply across tenants, so only admins of the default tenant may
+// list or lift them; see requireDefaultTenant.
+func (h *AdminHandler) ListAbuseBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := h.abuseDetector.Blocks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list blocks")
+		return
+	}
+	writeJSON(w, http.StatusOK, AbuseBlockListResponse{Items: blocks})
+}
+
+// Unblock lifts the block of a principal, such as "user:42" or
+// "ip:203.0.113.7".
+func (h *AdminHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	principal := chi.URLParam(r, "principal")
+	if err := h.abuseDetector.Unblock(r.Context(), principal); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeAbuseBlockNotFound, "block not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to lift block")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AbuseBlockListResponse lists the principals blocked by the abuse
+// detector.
+type AbuseBlockListResponse struct {
+	Items []types.AbuseBlock `json:"items"`
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeAbuseBlockRepo struct {
+	blocks map[string]types.AbuseBlock
+}
+
+func (r *fakeAbuseBlockRepo) Block(_ context.Context, block types.AbuseBlock) error {
+	r.blocks[block.Principal] = block
+	return nil
+}
+
+func (r *fakeAbuseBlockRepo) ListActive(context.Context, time.Time) ([]types.AbuseBlock, error) {
+	var blocks []types.AbuseBlock
+	for _, block := range r.blocks {
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (r *fakeAbuseBlockRepo) Unblock(_ context.Context, principal string) error {
+	if _, ok := r.blocks[principal]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.blocks, principal)
+	return nil
+}
+
+// strictAbusePolicy blocks a principal from its third request in a
+// minute.
+var strictAbusePolicy = services.AbusePolicy{Window: time.Minute, MaxRequests: 1, BlockDuration: time.Hour}
+
+func TestAbuseGuardIgnoresUntrustedForwardedFor(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("parse trusted proxies: %v", err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       []int
+	}{
+		{"untrusted peer", "203.0.113.7:4711", []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}},
+		{"trusted proxy", "10.0.0.1:4711", []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := services.NewAbuseDetector(&fakeAbuseBlockRepo{blocks: map[string]types.AbuseBlock{}}, strictAbusePolicy)
+			h := RealIP(trusted)(AbuseGuard(detector, NewJWTKeys("test-secret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+			// Every request claims to come from another client.
+			for i, want := range tt.want {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i+1))
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != want {
+					t.Fatalf("request %d status = %d, want %d", i+1, rec.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAbuseBlocksRequireDefaultTenant(t *testing.T) {
+	const principal = "ip:203.0.113.7"
+	tests := []struct {
+		name       string
+		tenant     int
+		wantList   int
+		wantDelete int
+	}{
+		{"default tenant", store.DefaultTenantID, http.StatusOK, http.StatusNoContent},
+		{"other tenant", store.DefaultTenantID + 1, http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeAbuseBlockRepo{blocks: map[string]types.AbuseBlock{}}
+			detector := services.NewAbuseDetector(repo, strictAbusePolicy)
+			for range 2 {
+				detector.Record(context.Background(), principal, http.StatusOK)
+			}
+			if _, blocked := detector.Check(principal); !blocked {
+				t.Fatalf("%s not blocked", principal)
+			}
+			users := services.NewUserService(&fakeUserRepo{users: map[int]types.User{
+				admin: {ID: admin, Role: types.RoleAdmin},
+			}}, nil)
+			r := chi.NewRouter()
+			r.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(w, r.WithContext(store.WithTenant(r.Context(), tt.tenant)))
+				})
+			})
+			r.Route("/admin", func(r chi.Router) {
+				AdminRouter(r, nil, nil, nil, nil, users, nil, nil, nil, detector, nil, fakeAuth)
+			})
+
+			rec := serve(t, r, httptest.NewRequest(http.MethodGet, "/admin/abuse/blocks", nil), admin)
+			if rec.Code != tt.wantList {
+				t.Fatalf("list status = %d, want %d: %s", rec.Code, tt.wantList, rec.Body)
+			}
+			rec = serve(t, r, httptest.NewRequest(http.MethodDelete, "/admin/abuse/blocks/"+principal, nil), admin)
+			if rec.Code != tt.wantDelete {
+				t.Fatalf("unblock status = %d, want %d: %s", rec.Code, tt.wantDelete, rec.Body)
+			}
+			_, blocked := detector.Check(principal)
+			if want := tt.wantDelete != http.StatusNoContent; blocked != want {
+				t.Fatalf("blocked after unblock = %v, want %v", blocked, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/jobs"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	formFieldImportFile = "file"
+	maxImportBytes      = 1 << 20
+	maxImportRows       = 1000
+)
+
+// AdminHandler provides HTTP handlers for administrative operations.
+type AdminHandler struct {
+	userImportService *services.UserImportService
+	submissionService *services.SubmissionService
+	integrityService  *services.BundleIntegrityService
+	sessionService    *services.SessionService
+	userService       *services.UserService
+	jobRunner         *jobs.Runner
+	overviewService   *services.OverviewService
+	exportService     *services.ExportService
+	abuseDetector     *services.AbuseDetector
+	configReloader    ConfigReloader
+}
+
+// ConfigReloader reloads the settings that can change while the server
+// runs.
+type ConfigReloader interface {
+	Reload() error
+}
+
+// NewAdminHandler constructs an AdminHandler with the provided services.
+func NewAdminHandler(
+	userImportService *services.UserImportService,
+	submissionService *services.SubmissionService,
+	integrityService *services.BundleIntegrityService,
+	sessionService *services.SessionService,
+	userService *services.UserService,
+	jobRunner *jobs.Runner,
+	overviewService *services.OverviewService,
+	exportService *services.ExportService,
+	abuseDetector *services.AbuseDetector,
+	configReloader ConfigReloader,
+) *AdminHandler {
+	return &AdminHandler{
+		userImportService: userImportService,
+		submissionService: submissionService,
+		integrityService:  integrityService,
+		sessionService:    sessionService,
+		userService:       userService,
+		jobRunner:         jobRunner,
+		overviewService:   overviewService,
+		exportService:     exportService,
+		abuseDetector:     abuseDetector,
+		configReloader:    configReloader,
+	}
+}
+
+// AdminRouter registers admin-only routes on the given router.
+func AdminRouter(
+	r chi.Router,
+	userImportService *services.UserImportService,
+	submissionService *services.SubmissionService,
+	integrityService *services.BundleIntegrityService,
+	sessionService *services.SessionService,
+	userService *services.UserService,
+	jobRunner *jobs.Runner,
+	overviewService *services.OverviewService,
+	exportService *services.ExportService,
+	abuseDetector *services.AbuseDetector,
+	configReloader ConfigReloader,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewAdminHandler(userImportService, submissionService, integrityService, sessionService, userService, jobRunner, overviewService, exportService, abuseDetector, configReloader)
+
+	r.Use(authMiddleware, requireAdmin(userService))
+	r.Get("/overview", handler.GetOverview)
+	r.Post("/users/import", handler.ImportUsers)
+	r.Put("/users/{username}/role", handler.SetUserRole)
+	r.Get("/judge/queue", handler.GetJudgeQueue)
+	r.Post("/problems/{problemID}/verify-bundle", handler.VerifyBundle)
+	r.Get("/jobs", handler.ListJobs)
+	r.Get("/submissions/suspicious", handler.ListSuspiciousSubmissions)
+	r.Get("/export/problems", handler.ExportProblems)
+	r.Get("/export/submissions", handler.ExportSubmissions)
+	r.With(requireDefaultTenant).Get("/abuse/blocks", handler.ListAbuseBlocks)
+	r.With(requireDefaultTenant).Delete("/abuse/blocks/{principal}", handler.Unblock)
+	r.Post("/config/reload", handler.ReloadConfig)
+}
+
+// SetUserRole assigns a role to a user, e.g. to promote them to setter.
+// Tokens carry the role they were issued with, so changing the role of
+// anyone but a plain user ends their sessions; see requireRole.
+func (h *AdminHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	var req UserRoleRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := h.userService.GetByUsername(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeUserNotFound, "user not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+
+	updated, err := h.userService.SetRole(r.Context(), user.ID, req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRole) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to update role")
+		return
+	}
+
+	if user.Role != updated.Role && user.Role != types.RoleUser {
+		if err := h.sessionService.EndAll(r.Context(), user.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to end sessions")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// UserRoleRequest is the payload for assigning a role.
+type UserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// VerifyBundle re-downloads the problem's latest stored bundle, compares
+// its SHA-256 with the recorded one and flags it as corrupted on mismatch.
+func (h *AdminHandler) VerifyBundle(w http.ResponseWriter, r *http.Request) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	verification, err := h.integrityService.Verify(r.Context(), problemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeBundleNotFound, "stored testcase bundle not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to verify testcase bundle")
+		return
+	}
+	writeJSON(w, http.StatusOK, verification)
+}
+
+// GetOverview returns the key operational numbers for the admin dashboard.
+func (h *AdminHandler) GetOverview(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.overviewService.Overview(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load overview")
+		return
+	}
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// ReloadConfig applies the current reloadable settings, such as abuse
+// thresholds, CORS origins, feature flags and the log level. Invalid
+// settings are rejected and the running ones kept.
+func (h *AdminHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := h.configReloader.Reload(); err != nil {
+		writeErrorCode(w, http.StatusBadRequest, CodeConfigInvalid, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetJudgeQueue reports pending and judging submission counts and wait times.
+func (h *AdminHandler) GetJudgeQueue(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.submissionService.QueueStats(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load judge queue stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// ListJobs returns background jobs, newest first. Pass ?status=failed to
+// list only failures.
+func (h *AdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := types.JobStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", types.JobPending, types.JobRunning, types.JobSucceeded, types.JobFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	items, total, err := h.jobRunner.List(r.Context(), status, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list jobs")
+		return
+	}
+	writeJSON(w, http.StatusOK, JobListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// JobListResponse is a page of background jobs.
+type JobListResponse struct {
+	Items []types.Job `json:"items"`
+	Pagination
+}
+
+// ListSuspiciousSubmissions lists submissions that look like they hardcode
+// expected outputs, most suspicious first. ?min_score= lowers or raises
+// the flagging threshold, 50 by default.
+func (h *AdminHandler) ListSuspiciousSubmissions(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	minScore := services.SuspicionFlagScore
+	if raw := r.URL.Query().Get("min_score"); raw != "" {
+		minScore, err = strconv.Atoi(raw)
+		if err != nil || minScore < 1 || minScore > 100 {
+			writeError(w, http.StatusBadRequest, "min_score must be between 1 and 100")
+			return
+		}
+	}
+
+	items, total, err := h.submissionService.ListSuspicious(r.Context(), minScore, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list submissions")
+		return
+	}
+	writeJSON(w, http.StatusOK, SubmissionListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// ImportUsers creates accounts from a CSV with the columns username, name,
+// email and an optional password. The CSV is either the raw request body
+// or a multipart file field named "file". Pass ?send_credentials=true to
+// email generated passwords instead of returning them.
+func (h *AdminHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	sendCredentials := false
+	if raw := r.URL.Query().Get("send_credentials"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid send_credentials")
+			return
+		}
+		sendCredentials = parsed
+	}
+
+	source, err := importSource(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	defer source.Close()
+
+	rows, err := parseUserImportCSV(io.LimitReader(source, maxImportBytes+1))
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := h.userImportService.Import(r.Context(), rows, sendCredentials)
+	if err != nil {
+		if errors.Is(err, services.ErrMailerNotConfigured) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to import users")
+		return
+	}
+
+	resp := UserImportResponse{Results: results}
+	for _, result := range results {
+		if result.Status == services.UserImportCreated {
+			resp.Created++
+		} else {
+			resp.Failed++
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func importSource(r *http.Request) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	if err := r.ParseMultipartForm(maxImportBytes); err != nil {
+		return nil, errors.New("invalid multipart form")
+	}
+	file, _, err := r.FormFile(formFieldImportFile)
+	if err != nil {
+		return nil, errors.New("csv file is required")
+	}
+	return file, nil
+}
+
+// parseUserImportCSV reads import rows. A leading header row is skipped
+// when its first column is "username".
+func parseUserImportCSV(reader io.Reader) ([]services.UserImportRow, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.New("failed to read csv")
+	}
+	if len(data) > maxImportBytes {
+		return nil, errors.New("csv too large")
+	}
+
+	cr := csv.NewReader(strings.NewReader(string(data)))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var rows []services.UserImportRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rows) == 0 && line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "username") {
+			continue
+		}
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: expected username, name, email and optional password", line)
+		}
+
+		row := services.UserImportRow{
+			Line:     line,
+			Username: strings.TrimSpace(record[0]),
+			Name:     strings.TrimSpace(record[1]),
+			Email:    strings.TrimSpace(record[2]),
+		}
+		if len(record) == 4 {
+			row.Password = record[3]
+		}
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return nil, fmt.Errorf("at most %d rows can be imported at once", maxImportRows)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("csv has no rows")
+	}
+	return rows, nil
+}
+
+// UserImportResponse summarizes a bulk user import.
+type UserImportResponse struct {
+	Created int                         `json:"created"`
+	Failed  int                         `json:"failed"`
+	Results []services.UserImportResult `json:"results"`
+}
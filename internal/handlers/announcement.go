@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AnnouncementHandler provides HTTP handlers for announcements.
+type AnnouncementHandler struct {
+	announcementService *services.AnnouncementService
+	userService         *services.UserService
+}
+
+// NewAnnouncementHandler constructs an AnnouncementHandler with the provided services.
+func NewAnnouncementHandler(announcementService *services.AnnouncementService, userService *services.UserService) *AnnouncementHandler {
+	return &AnnouncementHandler{
+		announcementService: announcementService,
+		userService:         userService,
+	}
+}
+
+// AnnouncementRouter registers announcement routes on the given router.
+func AnnouncementRouter(
+	r chi.Router,
+	announcementService *services.AnnouncementService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewAnnouncementHandler(announcementService, userService)
+	admin := requireAdmin(userService)
+
+	r.Get("/", handler.ListAnnouncements)
+	r.With(authMiddleware, admin).Post("/", handler.CreateAnnouncement)
+	r.Route("/{announcementID}", func(r chi.Router) {
+		r.Get("/", handler.GetAnnouncement)
+		r.With(authMiddleware, admin).Put("/", handler.UpdateAnnouncement)
+		r.With(authMiddleware, admin).Delete("/", handler.DeleteAnnouncement)
+	})
+}
+
+func (h *AnnouncementHandler) ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	items, total, err := h.announcementService.ListActive(r.Context(), offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list announcements")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, AnnouncementListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+func (h *AnnouncementHandler) GetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAnnouncementID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	announcement, err := h.announcementService.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeAnnouncementNotFound, "announcement not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch announcement")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, announcement)
+}
+
+func (h *AnnouncementHandler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	req, err := parseAnnouncementRequest(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := h.announcementService.Create(r.Context(), types.Announcement{
+		Title:     req.Title,
+		Body:      req.Body,
+		Pinned:    req.Pinned,
+		ExpiresAt: req.ExpiresAt,
+		AuthorID:  userID,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create announcement")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *AnnouncementHandler) UpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAnnouncementID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req, err := parseAnnouncementRequest(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.announcementService.Update(r.Context(), types.Announcement{
+		ID:        id,
+		Title:     req.Title,
+		Body:      req.Body,
+		Pinned:    req.Pinned,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeAnnouncementNotFound, "announcement not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to update announcement")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *AnnouncementHandler) DeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAnnouncementID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.announcementService.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeAnnouncementNotFound, "announcement not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete announcement")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AnnouncementRequest is the JSON payload for creating or updating an announcement.
+type AnnouncementRequest struct {
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Pinned    bool       `json:"pinned"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AnnouncementListResponse is the paginated list response payload.
+type AnnouncementListResponse struct {
+	Items []types.Announcement `json:"items"`
+	Pagination
+}
+
+func parseAnnouncementID(r *http.Request) (int, error) {
+	raw := chi.URLParam(r, "announcementID")
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid announcement id")
+	}
+	return id, nil
+}
+
+func parseAnnouncementRequest(r *http.Request) (AnnouncementRequest, error) {
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return AnnouncementRequest{}, errors.New("invalid request")
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Title == "" {
+		return AnnouncementRequest{}, errors.New("title is required")
+	}
+	if req.Body == "" {
+		return AnnouncementRequest{}, errors.New("body is required")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return AnnouncementRequest{}, errors.New("expires_at must be in the future")
+	}
+	return req, nil
+}
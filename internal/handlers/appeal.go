@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AppealHandler provides HTTP handlers for verdict appeals.
+type AppealHandler struct {
+	appealService *services.AppealService
+	userService   *services.UserService
+}
+
+// NewAppealHandler constructs an AppealHandler with the provided services.
+func NewAppealHandler(appealService *services.AppealService, userService *services.UserService) *AppealHandler {
+	return &AppealHandler{
+		appealService: appealService,
+		userService:   userService,
+	}
+}
+
+// AppealRouter registers appeal routes on the given router. Users file
+// and follow their own appeals; admins see all of them and resolve them.
+func AppealRouter(
+	r chi.Router,
+	appealService *services.AppealService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewAppealHandler(appealService, userService)
+
+	r.Use(authMiddleware)
+	r.Get("/", handler.ListAppeals)
+	r.Post("/", handler.FileAppeal)
+	r.Get("/{appealID}", handler.GetAppeal)
+	r.With(requireAdmin(userService)).Post("/{appealID}/resolve", handler.ResolveAppeal)
+}
+
+// ListAppeals returns a page of appeals, oldest first: the caller's own,
+// or everyone's for admins. Pass ?status=open or ?status=resolved to
+// filter.
+func (h *AppealHandler) ListAppeals(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	status := types.AppealStatus(r.URL.Query().Get("status"))
+	appeals, total, err := h.appealService.List(r.Context(), user, status, offset, limit)
+	if err != nil {
+		writeAppealError(w, err, "failed to list appeals")
+		return
+	}
+	writeJSON(w, http.StatusOK, AppealListResponse{
+		Items:      appeals,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// FileAppeal appeals the verdict of one of the caller's judged
+// submissions.
+func (h *AppealHandler) FileAppeal(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req AppealRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxAppealMessageBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.SubmissionID < 1 {
+		writeError(w, http.StatusBadRequest, "invalid submission id")
+		return
+	}
+
+	appeal, err := h.appealService.File(r.Context(), user, req.SubmissionID, req.Message)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+			return
+		}
+		writeAppealError(w, err, "failed to file appeal")
+		return
+	}
+	writeJSON(w, http.StatusCreated, appeal)
+}
+
+// GetAppeal returns one of the caller's appeals, or any appeal for admins.
+func (h *AppealHandler) GetAppeal(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAppealID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	appeal, err := h.appealService.Get(r.Context(), user, id)
+	if err != nil {
+		writeAppealError(w, err, "failed to fetch appeal")
+		return
+	}
+	writeJSON(w, http.StatusOK, appeal)
+}
+
+// ResolveAppeal dismisses an open appeal, rejudges its submission or
+// overrides the submission's verdict, with an optional response to the
+// user. Admins only.
+func (h *AppealHandler) ResolveAppeal(w http.ResponseWriter, r *http.Request) {
+	id, err := parseAppealID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req AppealResolutionRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxAppealMessageBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	decision := services.AppealDecision{
+		Resolution: req.Resolution,
+		Response:   req.Response,
+		Score:      req.Score,
+	}
+	if req.Resolution == types.AppealOverridden {
+		verdict, err := types.ParseVerdict(req.Verdict)
+		if err != nil {
+			writeErrorCode(w, http.StatusBadRequest, CodeAppealInvalid, "invalid verdict")
+			return
+		}
+		decision.Verdict = verdict
+	}
+
+	appeal, err := h.appealService.Resolve(r.Context(), user, id, decision)
+	if err != nil {
+		writeAppealError(w, err, "failed to resolve appeal")
+		return
+	}
+	writeJSON(w, http.StatusOK, appeal)
+}
+
+func (h *AppealHandler) currentUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return types.User{}, false
+	}
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+// AppealRequest is the payload for filing an appeal.
+type AppealRequest struct {
+	SubmissionID int64  `json:"submission_id"`
+	Message      string `json:"message"`
+}
+
+// AppealResolutionRequest is the payload for resolving an appeal.
+// Resolution is "dismissed", "rejudged" or "overridden"; Verdict (such as
+// "AC") and Score are the new outcome of an override.
+type AppealResolutionRequest struct {
+	Resolution types.AppealResolution `json:"resolution"`
+	Response   string                 `json:"response"`
+	Verdict    string                 `json:"verdict"`
+	Score      int                    `json:"score"`
+}
+
+// AppealListResponse is a page of appeals.
+type AppealListResponse struct {
+	Items []types.Appeal `json:"items"`
+	Pagination
+}
+
+func parseAppealID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "appealID"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid appeal id")
+	}
+	return id, nil
+}
+
+func writeAppealError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, CodeAppealNotFound, "appeal not found")
+	case errors.Is(err, services.ErrInvalidAppeal):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrAppealExists), errors.Is(err, services.ErrAppealResolved):
+		writeErrorFrom(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
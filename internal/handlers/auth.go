@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,44 +21,61 @@ import (
 )
 
 const defaultTokenTTL = 24 * time.Hour
-const defaultUserRole = "user"
+const defaultUserRole = types.RoleUser
 
 // AuthHandler provides JWT authentication endpoints.
 type AuthHandler struct {
-	userService *services.UserService
-	secret      []byte
-	tokenTTL    time.Duration
+	userService    *services.UserService
+	sessionService *services.SessionService
+	loginThrottle  *services.LoginThrottleService
+	accountEmails  *services.AccountEmailService
+	externalAuth   *services.ExternalAuthService
+	saml           SAMLProxy
+	keys           *JWTKeys
+	tokenTTL       time.Duration
 }
 
 // NewAuthHandler constructs an AuthHandler with the provided dependencies.
-func NewAuthHandler(userService *services.UserService, jwtSecret string) *AuthHandler {
+func NewAuthHandler(userService *services.UserService, sessionService *services.SessionService, loginThrottle *services.LoginThrottleService, accountEmails *services.AccountEmailService, externalAuth *services.ExternalAuthService, saml SAMLProxy, keys *JWTKeys) *AuthHandler {
 	return &AuthHandler{
-		userService: userService,
-		secret:      []byte(jwtSecret),
-		tokenTTL:    defaultTokenTTL,
+		userService:    userService,
+		sessionService: sessionService,
+		loginThrottle:  loginThrottle,
+		accountEmails:  accountEmails,
+		externalAuth:   externalAuth,
+		saml:           saml,
+		keys:           keys,
+		tokenTTL:       defaultTokenTTL,
 	}
 }
 
 // AuthRouter registers auth routes on the given router.
-func AuthRouter(r chi.Router, userService *services.UserService, jwtSecret string) {
-	handler := NewAuthHandler(userService, jwtSecret)
+func AuthRouter(r chi.Router, userService *services.UserService, sessionService *services.SessionService, loginThrottle *services.LoginThrottleService, accountEmails *services.AccountEmailService, externalAuth *services.ExternalAuthService, saml SAMLProxy, keys *JWTKeys) {
+	handler := NewAuthHandler(userService, sessionService, loginThrottle, accountEmails, externalAuth, saml, keys)
 
 	r.Post("/register", handler.Register)
 	r.Post("/login", handler.Login)
+	r.Post("/unlock", handler.Unlock)
+	r.Post("/password-reset", handler.RequestPasswordReset)
+	r.Post("/password-reset/confirm", handler.ResetPassword)
+	r.Get("/jwks.json", handler.JWKS)
+	r.Get("/saml", handler.SAMLLogin)
 	r.With(handler.RequireAuth).Get("/me", handler.Me)
+	r.With(handler.RequireAuth).Get("/sessions", handler.ListSessions)
+	r.With(handler.RequireAuth).Delete("/sessions/{sessionID}", handler.RevokeSession)
 }
 
 // RequireAuth enforces JWT authentication and injects the subject into context.
 func (h *AuthHandler) RequireAuth(next http.Handler) http.Handler {
-	return requireAuth(h.secret)(next)
+	return requireAuth(h.keys, h.sessionService)(next)
 }
 
 // RequireAuth constructs auth middleware for other routers.
-func RequireAuth(jwtSecret string) func(http.Handler) http.Handler {
-	return requireAuth([]byte(jwtSecret))
+func RequireAuth(keys *JWTKeys, sessionService *services.SessionService) func(http.Handler) http.Handler {
+	return requireAuth(keys, sessionService)
 }
 
-func requireAuth(secret []byte) func(http.Handler) http.Handler {
+func requireAuth(keys *JWTKeys, sessionService *services.SessionService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenString, err := bearerToken(r)
@@ -64,18 +84,112 @@ func requireAuth(secret []byte) func(http.Handler) http.Handler {
 				return
 			}
 
-			subject, err := parseTokenSubject(tokenString, secret)
+			claims, err := parseToken(tokenString, keys)
+			if err != nil || claims.tenant() != requestTenant(r) {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), contextSubjectKey, claims.Subject)
+			userID, err := userIDFromContext(ctx)
 			if err != nil {
 				writeError(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), contextSubjectKey, subject)
+			if err := sessionService.Validate(r.Context(), claims.ID, userID); err != nil {
+				if errors.Is(err, services.ErrSessionInvalid) {
+					writeErrorCode(w, http.StatusUnauthorized, CodeSessionInvalid, "session expired")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "failed to validate session")
+				return
+			}
+
+			ctx = context.WithValue(ctx, contextSessionKey, claims.ID)
+			ctx = context.WithValue(ctx, contextRoleKey, claims.Role)
+			ctx = services.WithActor(ctx, userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// requireAdmin rejects requests whose authenticated user is not an admin. It
+// must run after the auth middleware.
+func requireAdmin(userService *services.UserService) func(http.Handler) http.Handler {
+	return requireRole(userService, adminRole)
+}
+
+// requireSetter rejects requests whose authenticated user may not create
+// problems. It must run after the auth middleware.
+func requireSetter(userService *services.UserService) func(http.Handler) http.Handler {
+	return requireRole(userService, adminRole, setterRole)
+}
+
+// requireRole rejects requests whose authenticated user holds none of the
+// given roles. It must run after the auth middleware. A role claim in the
+// token that grants access is trusted without loading the user; otherwise
+// the stored role decides, so promotions apply to existing tokens.
+func requireRole(userService *services.UserService, roles ...string) func(http.Handler) http.Handler {
+	message := strings.Join(roles, " or ") + " access required"
+	hasRole := func(role string) bool {
+		return slices.ContainsFunc(roles, func(allowed string) bool {
+			return strings.EqualFold(role, allowed)
+		})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := userIDFromContext(r.Context())
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			if hasRole(roleFromContext(r.Context())) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := userService.GetByID(r.Context(), userID)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					writeError(w, http.StatusUnauthorized, "unauthorized")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, "failed to load user")
+				return
+			}
+
+			if !hasRole(user.Role) {
+				writeError(w, http.StatusForbidden, message)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// callerIsAdmin reports whether the request was made by an admin, for
+// routes that anyone may use but that show admins more. It is false for
+// anonymous requests.
+func callerIsAdmin(r *http.Request, userService *services.UserService) (bool, error) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		return false, nil
+	}
+	if strings.EqualFold(roleFromContext(r.Context()), adminRole) {
+		return true, nil
+	}
+	user, err := userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.EqualFold(user.Role, adminRole), nil
+}
+
 // Register creates a new user account and returns a JWT.
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
@@ -87,13 +201,18 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	req.Username = strings.TrimSpace(req.Username)
 	req.Email = strings.TrimSpace(req.Email)
 	req.Name = strings.TrimSpace(req.Name)
-	if req.Username == "" || req.Email == "" || req.Name == "" || req.Password == "" {
-		writeError(w, http.StatusBadRequest, "missing required fields")
+	var v validator
+	v.required("username", req.Username)
+	v.required("email", req.Email)
+	v.required("name", req.Name)
+	v.required("password", req.Password)
+	if err := v.err(); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
 	if _, err := h.userService.GetByUsername(r.Context(), req.Username); err == nil {
-		writeError(w, http.StatusConflict, "username already exists")
+		writeErrorCode(w, http.StatusConflict, CodeUsernameTaken, "username already exists")
 		return
 	} else if !errors.Is(err, store.ErrNotFound) {
 		writeError(w, http.StatusInternalServerError, "failed to check user")
@@ -118,16 +237,23 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := issueToken(user.ID, h.secret, h.tokenTTL)
+	token, err := h.startSession(r, user)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to create token")
 		return
 	}
+	h.accountEmails.Welcome(r.Context(), user)
 
 	writeJSON(w, http.StatusCreated, AuthResponse{Token: token, User: user})
 }
 
-// Login verifies credentials and returns a JWT.
+// Login verifies credentials and returns a JWT. Failed attempts back off
+// further logins for the account and the client address, and enough of
+// them lock the account; see services.LoginThrottleService.
+//
+// When a directory is configured, users without a local password are
+// checked against it and their account is created on first login; local
+// accounts, such as those made with "jjudge admin create", keep working.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -136,28 +262,60 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	req.Username = strings.TrimSpace(req.Username)
-	if req.Username == "" || req.Password == "" {
-		writeError(w, http.StatusBadRequest, "missing credentials")
+	var v validator
+	v.required("username", req.Username)
+	v.required("password", req.Password)
+	if err := v.err(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	ip := clientIP(r)
+	if err := h.loginThrottle.Check(r.Context(), req.Username, ip); err != nil {
+		writeLoginBlocked(w, err)
 		return
 	}
 
 	user, err := h.userService.GetByUsername(r.Context(), req.Username)
-	if err != nil {
-		if errors.Is(err, store.ErrNotFound) {
-			writeError(w, http.StatusUnauthorized, "invalid credentials")
+	switch {
+	case err == nil && !services.IsExternalAccount(user):
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+			h.rejectLogin(w, r, req.Username, ip)
 			return
 		}
+	case (err == nil || errors.Is(err, store.ErrNotFound)) && h.externalAuth.DirectoryEnabled():
+		user, err = h.externalAuth.DirectoryLogin(r.Context(), req.Username, req.Password)
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			h.rejectLogin(w, r, req.Username, ip)
+			return
+		case errors.Is(err, services.ErrInvalidExternalIdentity):
+			writeErrorFrom(w, http.StatusForbidden, err)
+			return
+		case err != nil:
+			log.Printf("auth: %v", err)
+			writeError(w, http.StatusBadGateway, "failed to authenticate with the directory")
+			return
+		}
+	case err == nil || errors.Is(err, store.ErrNotFound):
+		h.rejectLogin(w, r, req.Username, ip)
+		return
+	default:
 		writeError(w, http.StatusInternalServerError, "failed to authenticate")
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		writeError(w, http.StatusUnauthorized, "invalid credentials")
+	if err := h.loginThrottle.RecordSuccess(r.Context(), user.Username); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to authenticate")
 		return
 	}
 
-	token, err := issueToken(user.ID, h.secret, h.tokenTTL)
+	token, err := h.startSession(r, user)
 	if err != nil {
+		if errors.Is(err, services.ErrUserBanned) {
+			writeErrorFrom(w, http.StatusForbidden, err)
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "failed to create token")
 		return
 	}
@@ -165,6 +323,123 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, AuthResponse{Token: token, User: user})
 }
 
+// rejectLogin records a failed login and writes the 401.
+func (h *AuthHandler) rejectLogin(w http.ResponseWriter, r *http.Request, username, ip string) {
+	if err := h.loginThrottle.RecordFailure(r.Context(), username, ip); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		return
+	}
+	writeErrorCode(w, http.StatusUnauthorized, CodeInvalidCredentials, "invalid credentials")
+}
+
+// writeLoginBlocked writes the response for a login refused by the
+// throttle: 423 for a locked account, 429 while backing off, each with a
+// Retry-After header.
+func writeLoginBlocked(w http.ResponseWriter, err error) {
+	var blocked *services.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		writeError(w, http.StatusInternalServerError, "failed to authenticate")
+		return
+	}
+	retryAfter := int(math.Ceil(time.Until(blocked.Until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	if errors.Is(err, services.ErrAccountLocked) {
+		writeErrorFrom(w, http.StatusLocked, err)
+		return
+	}
+	writeErrorFrom(w, http.StatusTooManyRequests, err)
+}
+
+// Unlock lifts an account lockout using the token from the unlock email.
+func (h *AuthHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	var v validator
+	v.required("token", req.Token)
+	if err := v.err(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := h.loginThrottle.Unlock(r.Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrUnlockTokenInvalid) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to unlock account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset emails a password reset token to the named user.
+// It answers 202 whether or not the account exists, so that it cannot be
+// used to find out.
+func (h *AuthHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	var v validator
+	v.required("username", strings.TrimSpace(req.Username))
+	if err := v.err(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := h.accountEmails.RequestPasswordReset(r.Context(), req.Username); err != nil {
+		if errors.Is(err, services.ErrMailerNotConfigured) {
+			writeErrorFrom(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to request password reset")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using the token from the password
+// reset email, signing the account out everywhere.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	var v validator
+	v.required("token", req.Token)
+	v.required("password", req.Password)
+	if err := v.err(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	if err := h.accountEmails.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrPasswordResetTokenInvalid) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS publishes the public keys tokens can be verified with, so that other
+// services can check tokens themselves. It is empty when tokens are signed
+// with a shared secret.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.keys.PublicKeys())
+}
+
 // Me returns the current authenticated user.
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	userID, err := userIDFromContext(r.Context())
@@ -186,6 +461,49 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, user)
 }
 
+// ListSessions returns the caller's active sessions. The session making the
+// request is flagged as current.
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	sessions, err := h.sessionService.List(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+
+	currentID := sessionIDFromContext(r.Context())
+	items := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		items = append(items, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+	writeJSON(w, http.StatusOK, SessionListResponse{Items: items})
+}
+
+// RevokeSession ends one of the caller's sessions, logging out whichever
+// device holds its token. Revoking the current session logs the caller out.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if err := h.sessionService.Revoke(r.Context(), userID, chi.URLParam(r, "sessionID")); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeSessionNotFound, "session not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 type RegisterRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
@@ -198,40 +516,97 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type UnlockRequest struct {
+	Token string `json:"token"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// SessionResponse is a session as listed to its owner.
+type SessionResponse struct {
+	types.Session
+	// Current is set on the session that authenticated the request.
+	Current bool `json:"current"`
+}
+
+type SessionListResponse struct {
+	Items []SessionResponse `json:"items"`
+}
+
 type AuthResponse struct {
 	Token string     `json:"token"`
 	User  types.User `json:"user"`
 }
 
-func issueToken(userID int, secret []byte, ttl time.Duration) (string, error) {
-	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		Subject:   strconv.Itoa(userID),
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+// startSession opens a session for the user and issues a token bound to it.
+func (h *AuthHandler) startSession(r *http.Request, user types.User) (string, error) {
+	session, err := h.sessionService.Start(r.Context(), user.ID, h.tokenTTL, services.SessionClient{
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	})
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secret)
+	return issueToken(user, requestTenant(r), session.ID, h.keys, session.CreatedAt, session.ExpiresAt)
 }
 
-func parseTokenSubject(tokenString string, secret []byte) (string, error) {
-	claims := jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return secret, nil
-	})
+// tokenClaims are the claims of an access token. Username and Role are
+// copies taken when the token was issued; see requireRole. Tenant is the
+// tenant the user belongs to; the token is only accepted there.
+type tokenClaims struct {
+	Username string `json:"username,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Tenant   int    `json:"tenant,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// tenant returns the tenant the token was issued in. Tokens issued before
+// tenants existed carry none and belong to the default tenant.
+func (c tokenClaims) tenant() int {
+	if c.Tenant == 0 {
+		return store.DefaultTenantID
+	}
+	return c.Tenant
+}
+
+func issueToken(user types.User, tenantID int, sessionID string, keys *JWTKeys, issuedAt, expiresAt time.Time) (string, error) {
+	claims := tokenClaims{
+		Username: user.Username,
+		Role:     user.Role,
+		Tenant:   tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	return keys.sign(claims)
+}
+
+func parseToken(tokenString string, keys *JWTKeys) (tokenClaims, error) {
+	claims := tokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, &claims, keys.verificationKey)
 	if err != nil {
-		return "", err
+		return tokenClaims{}, err
 	}
 	if !token.Valid {
-		return "", errors.New("invalid token")
+		return tokenClaims{}, errors.New("invalid token")
 	}
 	if strings.TrimSpace(claims.Subject) == "" {
-		return "", errors.New("missing subject")
+		return tokenClaims{}, errors.New("missing subject")
+	}
+	if strings.TrimSpace(claims.ID) == "" {
+		return tokenClaims{}, errors.New("missing session id")
 	}
-	return claims.Subject, nil
+	return claims, nil
 }
 
 func bearerToken(r *http.Request) (string, error) {
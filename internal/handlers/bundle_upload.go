@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// BundleUploadHandler provides HTTP handlers for multipart testcase bundle
+// uploads.
+type BundleUploadHandler struct {
+	uploadService  *services.BundleUploadService
+	problemService *services.ProblemService
+	userService    *services.UserService
+}
+
+// NewBundleUploadHandler constructs a BundleUploadHandler with the provided
+// services.
+func NewBundleUploadHandler(
+	uploadService *services.BundleUploadService,
+	problemService *services.ProblemService,
+	userService *services.UserService,
+) *BundleUploadHandler {
+	return &BundleUploadHandler{
+		uploadService:  uploadService,
+		problemService: problemService,
+		userService:    userService,
+	}
+}
+
+// BundleUploadRouter registers bundle upload routes on the given router.
+// Sessions are for bundles above the POST /problems size limit: start one,
+// send its parts either through PUT .../parts/{part} or to presigned URLs,
+// then complete it and poll the session until it is ready or failed.
+// Sessions are available to users who may edit the problem.
+func BundleUploadRouter(
+	r chi.Router,
+	uploadService *services.BundleUploadService,
+	problemService *services.ProblemService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewBundleUploadHandler(uploadService, problemService, userService)
+
+	r.Use(authMiddleware, requireSetter(userService))
+	r.Post("/", handler.CreateUpload)
+	r.Route("/{uploadID}", func(r chi.Router) {
+		r.Use(handler.requireUploadEditor)
+		r.Get("/", handler.GetUpload)
+		r.Put("/parts/{part}", handler.PutPart)
+		r.Post("/parts/{part}/url", handler.GetPartURL)
+		r.Post("/complete", handler.CompleteUpload)
+	})
+}
+
+func (h *BundleUploadHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req BundleUploadRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !authorizeProblemEdit(w, r, h.userService, h.problemService, req.ProblemID) {
+		return
+	}
+
+	created, err := h.uploadService.Create(r.Context(), types.BundleUpload{
+		ProblemID:      req.ProblemID,
+		UserID:         userID,
+		Filename:       req.Filename,
+		TestcaseGroups: req.TestcaseGroups,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidBundleUpload):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to create bundle upload")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *BundleUploadHandler) GetUpload(w http.ResponseWriter, r *http.Request) {
+	upload, err := h.uploadService.Get(r.Context(), chi.URLParam(r, "uploadID"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeBundleUploadNotFound, "bundle upload not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch bundle upload")
+		return
+	}
+	writeJSON(w, http.StatusOK, upload)
+}
+
+// PutPart stores the raw request body as one part of the bundle. The
+// request must declare its Content-Length.
+func (h *BundleUploadHandler) PutPart(w http.ResponseWriter, r *http.Request) {
+	part, err := parsePartNumber(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	if r.ContentLength > services.MaxBundlePartBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "part is too large")
+		return
+	}
+	if r.ContentLength <= 0 {
+		writeError(w, http.StatusLengthRequired, "content length is required")
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, services.MaxBundlePartBytes)
+	err = h.uploadService.PutPart(r.Context(), chi.URLParam(r, "uploadID"), part, body, r.ContentLength)
+	if err != nil {
+		writeBundleUploadError(w, err, "failed to store part")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPartURL presigns an upload of one part straight to object storage.
+func (h *BundleUploadHandler) GetPartURL(w http.ResponseWriter, r *http.Request) {
+	part, err := parsePartNumber(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	url, expiresAt, err := h.uploadService.PartUploadURL(r.Context(), chi.URLParam(r, "uploadID"), part)
+	if err != nil {
+		writeBundleUploadError(w, err, "failed to presign part upload")
+		return
+	}
+	writeJSON(w, http.StatusOK, BundlePartURLResponse{URL: url, ExpiresAt: expiresAt})
+}
+
+// CompleteUpload closes the session and starts processing the bundle.
+func (h *BundleUploadHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	upload, err := h.uploadService.Complete(r.Context(), chi.URLParam(r, "uploadID"))
+	if err != nil {
+		writeBundleUploadError(w, err, "failed to complete bundle upload")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, upload)
+}
+
+// requireUploadEditor rejects requests for a session from users who may not
+// edit its problem.
+func (h *BundleUploadHandler) requireUploadEditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upload, err := h.uploadService.Get(r.Context(), chi.URLParam(r, "uploadID"))
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeErrorCode(w, http.StatusNotFound, CodeBundleUploadNotFound, "bundle upload not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to fetch bundle upload")
+			return
+		}
+		if !authorizeProblemEdit(w, r, h.userService, h.problemService, upload.ProblemID) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BundleUploadRequest is the payload for starting a bundle upload.
+type BundleUploadRequest struct {
+	ProblemID      int                   `json:"problem_id"`
+	Filename       string                `json:"filename"`
+	TestcaseGroups []types.TestcaseGroup `json:"testcase_groups"`
+}
+
+// BundlePartURLResponse is a presigned URL for uploading one part with a
+// plain PUT.
+type BundlePartURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func writeBundleUploadError(w http.ResponseWriter, err error, message string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, CodeBundleUploadNotFound, "bundle upload not found")
+	case errors.Is(err, services.ErrInvalidBundleUpload):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrBundleUploadClosed):
+		writeErrorFrom(w, http.StatusConflict, err)
+	case errors.As(err, &maxBytesErr):
+		writeError(w, http.StatusRequestEntityTooLarge, "part is too large")
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+func parsePartNumber(r *http.Request) (int, error) {
+	part, err := strconv.Atoi(chi.URLParam(r, "part"))
+	if err != nil || part < 1 || part > services.MaxBundleParts {
+		return 0, errors.New("invalid part number")
+	}
+	return part, nil
+}
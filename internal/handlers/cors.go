@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// corsExposedHeaders are the response headers browsers let scripts read.
+const corsExposedHeaders = "API-Version, Deprecation, Sunset, Link, ETag, Retry-After, Content-Disposition"
+
+// CORS answers cross-origin requests from an allow-list of origins that can
+// be replaced while the server runs.
+type CORS struct {
+	origins atomic.Pointer[[]string]
+}
+
+// NewCORS constructs a CORS allowing origins; "*" allows any origin and no
+// origins disables CORS.
+func NewCORS(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+// SetOrigins replaces the allowed origins.
+func (c *CORS) SetOrigins(origins []string) {
+	origins = slices.Clone(origins)
+	c.origins.Store(&origins)
+}
+
+func (c *CORS) allowed(origin string) bool {
+	origins := *c.origins.Load()
+	return slices.Contains(origins, "*") || slices.Contains(origins, origin)
+}
+
+// Middleware adds CORS headers for allowed origins and answers their
+// preflight requests. Tokens are sent in the Authorization header, so
+// credentials are not allowed.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		if !c.allowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header.Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE")
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", strings.TrimSpace(requested))
+			}
+			header.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrorCode is a stable, machine-readable identifier carried in error
+// responses so clients can branch on it instead of matching messages.
+// Codes are never renamed once published; new ones may be added.
+type ErrorCode string
+
+// Generic error codes, used when no more specific code applies.
+const (
+	CodeInvalidRequest   ErrorCode = "INVALID_REQUEST"
+	CodeValidationFailed ErrorCode = "VALIDATION_FAILED"
+	CodeUnauthorized     ErrorCode = "UNAUTHORIZED"
+	CodeForbidden        ErrorCode = "FORBIDDEN"
+	CodeNotFound         ErrorCode = "NOT_FOUND"
+	CodeConflict         ErrorCode = "CONFLICT"
+	CodeLengthRequired   ErrorCode = "LENGTH_REQUIRED"
+	CodePayloadTooLarge  ErrorCode = "PAYLOAD_TOO_LARGE"
+	CodeQuotaExceeded    ErrorCode = "QUOTA_EXCEEDED"
+	CodeInternal         ErrorCode = "INTERNAL"
+	CodeUnavailable      ErrorCode = "UNAVAILABLE"
+
+	CodeAPIVersionUnsupported ErrorCode = "API_VERSION_UNSUPPORTED"
+	CodeAPIVersionRetired     ErrorCode = "API_VERSION_RETIRED"
+)
+
+// Resource-specific error codes.
+const (
+	CodeProblemNotFound          ErrorCode = "PROBLEM_NOT_FOUND"
+	CodeProblemListNotFound      ErrorCode = "PROBLEM_LIST_NOT_FOUND"
+	CodeProblemListInvalid       ErrorCode = "PROBLEM_LIST_INVALID"
+	CodeProblemForbidden         ErrorCode = "PROBLEM_FORBIDDEN"
+	CodeSubmissionNotFound       ErrorCode = "SUBMISSION_NOT_FOUND"
+	CodeSubmissionCodePruned     ErrorCode = "SUBMISSION_CODE_PRUNED"
+	CodeSubmissionNotPending     ErrorCode = "SUBMISSION_NOT_PENDING"
+	CodeSubmissionCancelled      ErrorCode = "SUBMISSION_CANCELLED"
+	CodeRunNotFound              ErrorCode = "RUN_NOT_FOUND"
+	CodeRunInvalid               ErrorCode = "RUN_INVALID"
+	CodeRunFinished              ErrorCode = "RUN_FINISHED"
+	CodeUserNotFound             ErrorCode = "USER_NOT_FOUND"
+	CodeUsernameTaken            ErrorCode = "USERNAME_TAKEN"
+	CodeRoleInvalid              ErrorCode = "ROLE_INVALID"
+	CodeInvalidCredentials       ErrorCode = "INVALID_CREDENTIALS"
+	CodeLoginThrottled           ErrorCode = "LOGIN_THROTTLED"
+	CodeAccountLocked            ErrorCode = "ACCOUNT_LOCKED"
+	CodeUnlockTokenInvalid       ErrorCode = "UNLOCK_TOKEN_INVALID"
+	CodeSessionInvalid           ErrorCode = "SESSION_INVALID"
+	CodeSessionNotFound          ErrorCode = "SESSION_NOT_FOUND"
+	CodeAppealNotFound           ErrorCode = "APPEAL_NOT_FOUND"
+	CodeAppealInvalid            ErrorCode = "APPEAL_INVALID"
+	CodeAppealExists             ErrorCode = "APPEAL_EXISTS"
+	CodeAppealResolved           ErrorCode = "APPEAL_RESOLVED"
+	CodeAnnouncementNotFound     ErrorCode = "ANNOUNCEMENT_NOT_FOUND"
+	CodeCommentNotFound          ErrorCode = "COMMENT_NOT_FOUND"
+	CodeCommentInvalid           ErrorCode = "COMMENT_INVALID"
+	CodeCommentForbidden         ErrorCode = "COMMENT_FORBIDDEN"
+	CodeCommentEditWindow        ErrorCode = "COMMENT_EDIT_WINDOW_CLOSED"
+	CodeReportNotFound           ErrorCode = "REPORT_NOT_FOUND"
+	CodeReportInvalid            ErrorCode = "REPORT_INVALID"
+	CodeReportClosed             ErrorCode = "REPORT_CLOSED"
+	CodeModerationActionInvalid  ErrorCode = "MODERATION_ACTION_INVALID"
+	CodeBanNotFound              ErrorCode = "BAN_NOT_FOUND"
+	CodeAccountBanned            ErrorCode = "ACCOUNT_BANNED"
+	CodeNotificationNotFound     ErrorCode = "NOTIFICATION_NOT_FOUND"
+	CodeNotificationPrefsInvalid ErrorCode = "NOTIFICATION_PREFERENCES_INVALID"
+	CodePasswordResetInvalid     ErrorCode = "PASSWORD_RESET_TOKEN_INVALID"
+	CodePostNotFound             ErrorCode = "POST_NOT_FOUND"
+	CodePostInvalid              ErrorCode = "POST_INVALID"
+	CodeBundleNotFound           ErrorCode = "BUNDLE_NOT_FOUND"
+	CodeBundleInvalid            ErrorCode = "BUNDLE_INVALID"
+	CodeBundleInvalidFilename    ErrorCode = "BUNDLE_INVALID_FILENAME"
+	CodeBundleCorrupted          ErrorCode = "BUNDLE_CORRUPTED"
+	CodeBundleUploadNotFound     ErrorCode = "BUNDLE_UPLOAD_NOT_FOUND"
+	CodeBundleUploadClosed       ErrorCode = "BUNDLE_UPLOAD_CLOSED"
+	CodeGenerationNotPending     ErrorCode = "GENERATION_NOT_PENDING"
+	CodeValidationNotFound       ErrorCode = "VALIDATION_NOT_FOUND"
+	CodeLanguageUnsupported      ErrorCode = "LANGUAGE_UNSUPPORTED"
+	CodeLanguageUndetected       ErrorCode = "LANGUAGE_UNDETECTED"
+	CodeAnswersRequired          ErrorCode = "ANSWERS_REQUIRED"
+	CodeAnswersNotAccepted       ErrorCode = "ANSWERS_NOT_ACCEPTED"
+	CodeAnswersInvalid           ErrorCode = "ANSWERS_INVALID"
+	CodeTooManyIDs               ErrorCode = "TOO_MANY_IDS"
+	CodeReviewForbidden          ErrorCode = "REVIEW_FORBIDDEN"
+	CodeReviewInvalid            ErrorCode = "REVIEW_INVALID"
+	CodeReviewTransition         ErrorCode = "REVIEW_INVALID_TRANSITION"
+	CodeLeaderboardQuery         ErrorCode = "LEADERBOARD_QUERY_INVALID"
+	CodeJudgeMessageInvalid      ErrorCode = "JUDGE_MESSAGE_INVALID"
+	CodeJudgeResultStale         ErrorCode = "JUDGE_RESULT_STALE"
+	CodeJudgeWorkerInvalid       ErrorCode = "JUDGE_WORKER_INVALID"
+	CodeJudgeQueueUnavailable    ErrorCode = "JUDGE_QUEUE_UNAVAILABLE"
+	CodeMailerNotConfigured      ErrorCode = "MAILER_NOT_CONFIGURED"
+	CodeAbuseBlocked             ErrorCode = "ABUSE_BLOCKED"
+	CodeAbuseBlockNotFound       ErrorCode = "ABUSE_BLOCK_NOT_FOUND"
+	CodeConfigInvalid            ErrorCode = "CONFIG_INVALID"
+	CodeExportInvalid            ErrorCode = "EXPORT_INVALID"
+	CodeTenantNotFound           ErrorCode = "TENANT_NOT_FOUND"
+	CodeExternalIdentityInvalid  ErrorCode = "EXTERNAL_IDENTITY_INVALID"
+	CodeLocalAccountExists       ErrorCode = "LOCAL_ACCOUNT_EXISTS"
+	CodeValidationResultInvalid  ErrorCode = "VALIDATION_RESULT_INVALID"
+)
+
+// serviceErrorCodes maps service-layer errors to codes. More specific
+// errors come first, since some wrap others.
+var serviceErrorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{services.ErrInvalidBundleFilename, CodeBundleInvalidFilename},
+	{services.ErrInvalidBundleUpload, CodeBundleInvalid},
+	{services.ErrInvalidGeneratedBundle, CodeBundleInvalid},
+	{services.ErrBundleUploadClosed, CodeBundleUploadClosed},
+	{services.ErrBundleCorrupted, CodeBundleCorrupted},
+	{services.ErrGenerationNotPending, CodeGenerationNotPending},
+	{services.ErrProblemForbidden, CodeProblemForbidden},
+	{services.ErrReviewForbidden, CodeReviewForbidden},
+	{services.ErrReviewTransition, CodeReviewTransition},
+	{services.ErrInvalidReview, CodeReviewInvalid},
+	{services.ErrUnsupportedLanguage, CodeLanguageUnsupported},
+	{services.ErrAnswersRequired, CodeAnswersRequired},
+	{services.ErrAnswersNotAccepted, CodeAnswersNotAccepted},
+	{services.ErrInvalidAnswers, CodeAnswersInvalid},
+	{services.ErrTooManySubmissionIDs, CodeTooManyIDs},
+	{services.ErrSubmissionNotPending, CodeSubmissionNotPending},
+	{services.ErrSubmissionCodePruned, CodeSubmissionCodePruned},
+	{services.ErrInvalidAppeal, CodeAppealInvalid},
+	{services.ErrAppealExists, CodeAppealExists},
+	{services.ErrAppealResolved, CodeAppealResolved},
+	{services.ErrTooManyRuns, CodeQuotaExceeded},
+	{services.ErrInvalidRun, CodeRunInvalid},
+	{services.ErrRunFinished, CodeRunFinished},
+	{services.ErrInvalidRole, CodeRoleInvalid},
+	{services.ErrSessionInvalid, CodeSessionInvalid},
+	{services.ErrLoginThrottled, CodeLoginThrottled},
+	{services.ErrAccountLocked, CodeAccountLocked},
+	{services.ErrUnlockTokenInvalid, CodeUnlockTokenInvalid},
+	{services.ErrPasswordResetTokenInvalid, CodePasswordResetInvalid},
+	{services.ErrInvalidNotificationPreferences, CodeNotificationPrefsInvalid},
+	{services.ErrInvalidProblemComment, CodeCommentInvalid},
+	{services.ErrInvalidPost, CodePostInvalid},
+	{services.ErrInvalidProblemList, CodeProblemListInvalid},
+	{services.ErrProblemCommentForbidden, CodeCommentForbidden},
+	{services.ErrProblemCommentEditWindow, CodeCommentEditWindow},
+	{services.ErrInvalidReport, CodeReportInvalid},
+	{services.ErrReportClosed, CodeReportClosed},
+	{services.ErrInvalidModerationAction, CodeModerationActionInvalid},
+	{services.ErrUserBanned, CodeAccountBanned},
+	{services.ErrInvalidLeaderboardQuery, CodeLeaderboardQuery},
+	{services.ErrInvalidJudgeWorker, CodeJudgeWorkerInvalid},
+	{services.ErrStaleJudgeResult, CodeJudgeResultStale},
+	{services.ErrInvalidValidationResult, CodeValidationResultInvalid},
+	{services.ErrJudgeQueueUnavailable, CodeJudgeQueueUnavailable},
+	{services.ErrMailerNotConfigured, CodeMailerNotConfigured},
+	{services.ErrInvalidExport, CodeExportInvalid},
+	{services.ErrInvalidCredentials, CodeInvalidCredentials},
+	{services.ErrInvalidExternalIdentity, CodeExternalIdentityInvalid},
+	{services.ErrLocalAccountExists, CodeLocalAccountExists},
+	{types.ErrInvalidJudgeMessage, CodeJudgeMessageInvalid},
+	{store.ErrNotFound, CodeNotFound},
+}
+
+// errorCode returns the code for err, falling back to the generic code
+// for status when err is not a known service error.
+func errorCode(err error, status int) ErrorCode {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return CodeValidationFailed
+	}
+	var detectErr *services.LanguageDetectionError
+	if errors.As(err, &detectErr) {
+		return CodeLanguageUndetected
+	}
+	for _, entry := range serviceErrorCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+	return statusErrorCode(status)
+}
+
+// statusErrorCode returns the generic code for an HTTP status.
+func statusErrorCode(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusLocked:
+		return CodeAccountLocked
+	case http.StatusLengthRequired:
+		return CodeLengthRequired
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeQuotaExceeded
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// writeErrorCode writes an error response with an explicit code.
+func writeErrorCode(w http.ResponseWriter, status int, code ErrorCode, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message, Code: code})
+}
+
+// writeErrorFrom writes err's message with the code mapped from it.
+func writeErrorFrom(w http.ResponseWriter, status int, err error) {
+	writeErrorCode(w, status, errorCode(err, status), err.Error())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// EventHandler provides HTTP handlers for reading the domain event log.
+type EventHandler struct {
+	eventService *services.EventService
+}
+
+// NewEventHandler constructs an EventHandler with the provided service.
+func NewEventHandler(eventService *services.EventService) *EventHandler {
+	return &EventHandler{eventService: eventService}
+}
+
+// EventRouter registers event log routes on the given router. The log is
+// admin-only.
+func EventRouter(
+	r chi.Router,
+	eventService *services.EventService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewEventHandler(eventService)
+
+	r.Use(authMiddleware, requireAdmin(userService))
+	r.Get("/", handler.ListEvents)
+}
+
+// ListEvents returns events after the ?after= log position, so integrators
+// can tail the log by passing the last ID they have seen.
+func (h *EventHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	afterID, limit, err := parseEventCursor(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	eventType := strings.TrimSpace(r.URL.Query().Get("type"))
+
+	events, err := h.eventService.ListAfter(r.Context(), afterID, eventType, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list events")
+		return
+	}
+
+	next := afterID
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+	w.Header().Add("Link", linkValue(pageURL(r, map[string]string{"after": strconv.FormatInt(next, 10)}), "next"))
+	writeJSON(w, http.StatusOK, EventListResponse{
+		Items: events,
+		Next:  next,
+	})
+}
+
+// EventListResponse is the event log page payload. Next is the cursor to
+// pass as ?after= to fetch subsequent events.
+type EventListResponse struct {
+	Items []types.Event `json:"items"`
+	Next  int64         `json:"next"`
+}
+
+func parseEventCursor(r *http.Request) (int64, int, error) {
+	var afterID int64
+	if raw := strings.TrimSpace(r.URL.Query().Get("after")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("invalid after cursor")
+		}
+		afterID = parsed
+	}
+
+	limit := 0
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			return 0, 0, errors.New("invalid limit")
+		}
+		limit = parsed
+	}
+	return afterID, limit, nil
+}
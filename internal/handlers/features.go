@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+)
+
+// FeatureFlags holds the enabled feature flags, which can be replaced while
+// the server runs.
+type FeatureFlags struct {
+	enabled atomic.Pointer[[]string]
+}
+
+// NewFeatureFlags constructs FeatureFlags with the given flags enabled.
+func NewFeatureFlags(enabled []string) *FeatureFlags {
+	f := &FeatureFlags{}
+	f.Set(enabled)
+	return f
+}
+
+// Set replaces the enabled flags.
+func (f *FeatureFlags) Set(enabled []string) {
+	enabled = slices.Clone(enabled)
+	slices.Sort(enabled)
+	enabled = slices.Compact(enabled)
+	f.enabled.Store(&enabled)
+}
+
+// Enabled reports whether the named flag is enabled.
+func (f *FeatureFlags) Enabled(name string) bool {
+	_, found := slices.BinarySearch(*f.enabled.Load(), name)
+	return found
+}
+
+// List returns the enabled flags, for clients that toggle features with
+// them.
+func (f *FeatureFlags) List(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, FeatureFlagsResponse{Enabled: *f.enabled.Load()})
+}
+
+// FeatureFlagsResponse lists the enabled feature flags.
+type FeatureFlagsResponse struct {
+	Enabled []string `json:"enabled"`
+}
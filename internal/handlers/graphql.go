@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/graphql"
+	"github.com/jjudge-oj/apiserver/internal/markdown"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	maxGraphQLBytes      = 64 << 10
+	maxGraphQLDepth      = 6
+	maxGraphQLComplexity = 300
+)
+
+// GraphQLHandler serves a read-only GraphQL view of problems, standings,
+// user profiles and submissions, so that a page needing several of them
+// takes one request. Visibility follows the matching REST endpoints.
+type GraphQLHandler struct {
+	problemService     *services.ProblemService
+	submissionService  *services.SubmissionService
+	leaderboardService *services.LeaderboardService
+	userService        *services.UserService
+	schema             *graphql.Schema
+}
+
+// NewGraphQLHandler constructs a GraphQLHandler with the provided services.
+func NewGraphQLHandler(
+	problemService *services.ProblemService,
+	submissionService *services.SubmissionService,
+	leaderboardService *services.LeaderboardService,
+	userService *services.UserService,
+) *GraphQLHandler {
+	h := &GraphQLHandler{
+		problemService:     problemService,
+		submissionService:  submissionService,
+		leaderboardService: leaderboardService,
+		userService:        userService,
+	}
+	h.schema = h.buildSchema()
+	return h
+}
+
+// GraphQLRouter registers the GraphQL endpoint on the given router.
+// Requests without credentials run anonymously.
+func GraphQLRouter(
+	r chi.Router,
+	problemService *services.ProblemService,
+	submissionService *services.SubmissionService,
+	leaderboardService *services.LeaderboardService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewGraphQLHandler(problemService, submissionService, leaderboardService, userService)
+
+	r.Use(optionalAuth(authMiddleware))
+	r.Get("/", handler.Query)
+	r.Post("/", handler.Query)
+}
+
+// optionalAuth applies authMiddleware to requests that carry an
+// Authorization header and lets the others through unauthenticated.
+func optionalAuth(authMiddleware func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if authMiddleware == nil {
+			return next
+		}
+		authenticated := authMiddleware(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Query executes a GraphQL query sent as a JSON body or, for GET, in the
+// query, operationName and variables query parameters. Responses follow
+// the GraphQL over HTTP conventions: field errors are listed next to the
+// data with a 200, while queries that fail validation get a 400 without
+// data.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if raw := query.Get("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid variables")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(io.LimitReader(r.Body, maxGraphQLBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, "missing query")
+		return
+	}
+
+	// The schema has no mutations, so every request is a read.
+	resp := graphql.Execute(store.WithReplicaReads(r.Context()), h.schema, req)
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// graphQLError is a resolver error reported with the error code the REST
+// API uses for the same failure.
+type graphQLError struct {
+	code    ErrorCode
+	message string
+}
+
+func newGraphQLError(code ErrorCode, message string) error {
+	return &graphQLError{code: code, message: message}
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// graphQLPage is a page of a list.
+type graphQLPage struct {
+	Items any `json:"items"`
+	Pagination
+}
+
+// graphQLStandings is a page of the leaderboard.
+type graphQLStandings struct {
+	Items  []types.LeaderboardEntry `json:"items"`
+	Period string                   `json:"period"`
+	Pagination
+}
+
+func (h *GraphQLHandler) buildSchema() *graphql.Schema {
+	pageArgs := []string{"page", "limit"}
+	pageFields := func(items *graphql.Object) graphql.Fields {
+		return graphql.Fields{
+			"items": {Type: items},
+			"total": {},
+			"page":  {},
+			"limit": {},
+			"next":  {},
+			"prev":  {},
+		}
+	}
+
+	user := &graphql.Object{Name: "User"}
+	problem := &graphql.Object{Name: "Problem"}
+	submission := &graphql.Object{Name: "Submission"}
+	solvedProblem := &graphql.Object{Name: "SolvedProblem"}
+	dailyActivity := &graphql.Object{Name: "DailyActivity"}
+	languageLimit := &graphql.Object{Name: "LanguageLimit", Fields: graphql.Fields{
+		"language":    {},
+		"timeLimit":   {},
+		"memoryLimit": {},
+	}}
+	leaderboardEntry := &graphql.Object{Name: "LeaderboardEntry"}
+	problemPage := &graphql.Object{Name: "ProblemPage", Fields: pageFields(problem)}
+	submissionPage := &graphql.Object{Name: "SubmissionPage", Fields: pageFields(submission)}
+	standings := &graphql.Object{Name: "Standings", Fields: pageFields(leaderboardEntry)}
+	standings.Fields["period"] = &graphql.Field{}
+
+	user.Fields = graphql.Fields{
+		"id":        {},
+		"username":  {},
+		"name":      {},
+		"role":      {},
+		"createdAt": {},
+		"solved":    {Type: solvedProblem, Resolve: h.resolveSolved},
+		"activity":  {Type: dailyActivity, Resolve: h.resolveActivity},
+	}
+	problem.Fields = graphql.Fields{
+		"id":          {},
+		"title":       {},
+		"description": {Args: []string{"format"}, Resolve: resolveDescription},
+		"type":        {},
+		"difficulty":  {},
+		"timeLimit":   {},
+		"memoryLimit": {},
+		"languageLimits": {Type: languageLimit, Resolve: func(p graphql.Params) (any, error) {
+			return services.LanguageLimits(p.Source.(types.Problem)), nil
+		}},
+		"tags":             {},
+		"validationStatus": {},
+		"reviewStatus":     {},
+		"published":        {},
+		"createdAt":        {},
+		"updatedAt":        {},
+		"submissions": {
+			Type:    submissionPage,
+			Args:    append([]string{"mine"}, pageArgs...),
+			Resolve: h.resolveProblemSubmissions,
+		},
+	}
+	submission.Fields = graphql.Fields{
+		"id":           {},
+		"problemId":    {},
+		"userId":       {},
+		"code":         {},
+		"codePreview":  {},
+		"codeLength":   {},
+		"codePrunedAt": {},
+		"language":     {},
+		"verdict":      {},
+		"score":        {},
+		"cpuTime":      {},
+		"memory":       {},
+		"message":      {},
+		"testsPassed":  {},
+		"testsTotal":   {},
+		"createdAt":    {},
+		"updatedAt":    {},
+		"problem": {Type: problem, Resolve: func(p graphql.Params) (any, error) {
+			return h.problem(p, p.Source.(types.Submission).ProblemID)
+		}},
+		"user": {Type: user, Resolve: func(p graphql.Params) (any, error) {
+			return h.userByID(p, p.Source.(types.Submission).UserID)
+		}},
+	}
+	solvedProblem.Fields = graphql.Fields{
+		"problemId":       {},
+		"firstAcceptedAt": {},
+		"problem": {Type: problem, Resolve: func(p graphql.Params) (any, error) {
+			return h.problem(p, p.Source.(types.SolvedProblem).ProblemID)
+		}},
+	}
+	dailyActivity.Fields = graphql.Fields{
+		"date":  {},
+		"count": {},
+	}
+	leaderboardEntry.Fields = graphql.Fields{
+		"rank":     {},
+		"userId":   {},
+		"username": {},
+		"name":     {},
+		"solved":   {},
+		"score":    {},
+		"user": {Type: user, Resolve: func(p graphql.Params) (any, error) {
+			return h.userByID(p, p.Source.(types.LeaderboardEntry).UserID)
+		}},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"problems": {Type: problemPage, Args: pageArgs, Resolve: h.resolveProblems},
+			"problem": {Type: problem, Args: []string{"id"}, Resolve: func(p graphql.Params) (any, error) {
+				id, err := requiredID(p)
+				if err != nil {
+					return nil, err
+				}
+				return h.problem(p, id)
+			}},
+			"standings": {
+				Type:    standings,
+				Args:    append([]string{"period", "month", "sort"}, pageArgs...),
+				Resolve: h.resolveStandings,
+			},
+			"user":       {Type: user, Args: []string{"username"}, Resolve: h.resolveUser},
+			"viewer":     {Type: user, Resolve: h.resolveViewer},
+			"submission": {Type: submission, Args: []string{"id"}, Resolve: h.resolveSubmission},
+		},
+	}
+	return &graphql.Schema{Query: query, MaxDepth: maxGraphQLDepth, MaxComplexity: maxGraphQLComplexity}
+}
+
+func (h *GraphQLHandler) resolveProblems(p graphql.Params) (any, error) {
+	page, limit, offset, err := graphQLPagination(p)
+	if err != nil {
+		return nil, err
+	}
+	items, total, err := h.problemService.List(p.Context, offset, limit)
+	if err != nil {
+		return nil, newGraphQLError(CodeInternal, "failed to list problems")
+	}
+	return graphQLPage{Items: items, Pagination: newPagination(page, limit, total)}, nil
+}
+
+// problem returns a problem like GET /problems/{problemID}: unpublished
+// problems are reported as not found to callers who may not see them.
+func (h *GraphQLHandler) problem(p graphql.Params, id int) (any, error) {
+	problem, err := h.problemService.Get(p.Context, id)
+	if err == nil && !problem.Published {
+		err = h.authorizeView(p, problem)
+	}
+	if err != nil {
+		var gqlErr *graphQLError
+		if errors.As(err, &gqlErr) {
+			return nil, err
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, newGraphQLError(CodeProblemNotFound, "problem not found")
+		}
+		return nil, newGraphQLError(CodeInternal, "failed to fetch problem")
+	}
+	return problem, nil
+}
+
+// authorizeView returns store.ErrNotFound unless the caller, who may be
+// anonymous, may see the problem.
+func (h *GraphQLHandler) authorizeView(p graphql.Params, problem types.Problem) error {
+	var user types.User
+	if _, err := userIDFromContext(p.Context); err == nil {
+		if user, err = h.viewer(p); err != nil {
+			return err
+		}
+	}
+	return h.problemService.AuthorizeView(p.Context, user, problem)
+}
+
+// resolveDescription returns the statement as Markdown, or rendered to
+// HTML with format: "html".
+func resolveDescription(p graphql.Params) (any, error) {
+	description := p.Source.(types.Problem).Description
+	format, err := p.String("format")
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	switch format {
+	case "", "markdown":
+		return description, nil
+	case "html":
+		rendered, err := markdown.Render(description)
+		if err != nil {
+			return nil, newGraphQLError(CodeInternal, "failed to render description")
+		}
+		return rendered, nil
+	default:
+		return nil, newGraphQLError(CodeInvalidRequest, "invalid format")
+	}
+}
+
+// resolveProblemSubmissions lists a problem's submissions like
+// GET /problems/{problemID}/submissions: the caller's own with mine: true,
+// otherwise everyone's, which only admins may list.
+func (h *GraphQLHandler) resolveProblemSubmissions(p graphql.Params) (any, error) {
+	viewer, err := h.viewer(p)
+	if err != nil {
+		return nil, err
+	}
+	mine, err := p.Bool("mine", false)
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	page, limit, offset, err := graphQLPagination(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerID := viewer.ID
+	if !mine {
+		if !strings.EqualFold(viewer.Role, adminRole) {
+			return nil, newGraphQLError(CodeForbidden, "only admins may list all submissions; use mine: true")
+		}
+		ownerID = 0
+	}
+
+	items, total, err := h.submissionService.ListByProblem(p.Context, p.Source.(types.Problem).ID, ownerID, offset, limit)
+	if err != nil {
+		return nil, newGraphQLError(CodeInternal, "failed to list submissions")
+	}
+	return graphQLPage{Items: items, Pagination: newPagination(page, limit, total)}, nil
+}
+
+// resolveStandings returns the leaderboard like GET /leaderboard.
+func (h *GraphQLHandler) resolveStandings(p graphql.Params) (any, error) {
+	page, limit, offset, err := graphQLPagination(p)
+	if err != nil {
+		return nil, err
+	}
+	rawPeriod, err := p.String("period")
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	month, err := p.String("month")
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	sortBy, err := p.String("sort")
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+
+	period := types.LeaderboardPeriodAll
+	switch rawPeriod {
+	case "", "all":
+	case "monthly":
+		period = month
+		if period == "" {
+			period = time.Now().UTC().Format("2006-01")
+		}
+	default:
+		return nil, newGraphQLError(CodeInvalidRequest, "invalid period")
+	}
+
+	entries, total, err := h.leaderboardService.List(p.Context, period, sortBy, offset, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLeaderboardQuery) {
+			return nil, newGraphQLError(CodeLeaderboardQuery, "invalid month or sort")
+		}
+		return nil, newGraphQLError(CodeInternal, "failed to load leaderboard")
+	}
+	return graphQLStandings{
+		Items:      entries,
+		Period:     period,
+		Pagination: newPagination(page, limit, total),
+	}, nil
+}
+
+func (h *GraphQLHandler) resolveUser(p graphql.Params) (any, error) {
+	username, err := p.String("username")
+	if err != nil {
+		return nil, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	if username == "" {
+		return nil, newGraphQLError(CodeInvalidRequest, "missing username")
+	}
+	user, err := h.userService.GetByUsername(p.Context, username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, newGraphQLError(CodeUserNotFound, "user not found")
+		}
+		return nil, newGraphQLError(CodeInternal, "failed to fetch user")
+	}
+	return user, nil
+}
+
+// resolveViewer returns the authenticated user, or null for anonymous
+// requests.
+func (h *GraphQLHandler) resolveViewer(p graphql.Params) (any, error) {
+	if _, err := userIDFromContext(p.Context); err != nil {
+		return nil, nil
+	}
+	return h.viewer(p)
+}
+
+func (h *GraphQLHandler) userByID(p graphql.Params, id int) (any, error) {
+	user, err := h.userService.GetByID(p.Context, id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, newGraphQLError(CodeUserNotFound, "user not found")
+		}
+		return nil, newGraphQLError(CodeInternal, "failed to fetch user")
+	}
+	return user, nil
+}
+
+func (h *GraphQLHandler) resolveSolved(p graphql.Params) (any, error) {
+	solved, err := h.submissionService.ListSolved(p.Context, p.Source.(types.User).ID)
+	if err != nil {
+		return nil, newGraphQLError(CodeInternal, "failed to list solved problems")
+	}
+	return solved, nil
+}
+
+func (h *GraphQLHandler) resolveActivity(p graphql.Params) (any, error) {
+	activity, err := h.submissionService.Activity(p.Context, p.Source.(types.User).ID)
+	if err != nil {
+		return nil, newGraphQLError(CodeInternal, "failed to load activity")
+	}
+	return activity, nil
+}
+
+// resolveSubmission returns a submission with its code, like
+// GET /submissions/{submissionID}: to its author, and to others only when
+// SubmissionService.CanViewCode allows it.
+func (h *GraphQLHandler) resolveSubmission(p graphql.Params) (any, error) {
+	viewer, err := h.viewer(p)
+	if err != nil {
+		return nil, err
+	}
+	id, err := requiredID(p)
+	if err != nil {
+		return nil, err
+	}
+
+	submission, err := h.submissionService.GetWithCode(p.Context, int64(id))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, newGraphQLError(CodeSubmissionNotFound, "submission not found")
+		}
+		return nil, newGraphQLError(CodeInternal, "failed to fetch submission")
+	}
+	if submission.UserID != viewer.ID {
+		problem, err := h.problemService.Get(p.Context, submission.ProblemID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, newGraphQLError(CodeInternal, "failed to fetch problem")
+		}
+		allowed, err := h.submissionService.CanViewCode(p.Context, viewer, submission, problem)
+		if err != nil {
+			return nil, newGraphQLError(CodeInternal, "failed to fetch submission")
+		}
+		if !allowed {
+			return nil, newGraphQLError(CodeSubmissionNotFound, "submission not found")
+		}
+	}
+	return submission, nil
+}
+
+// viewer loads the authenticated user.
+func (h *GraphQLHandler) viewer(p graphql.Params) (types.User, error) {
+	userID, err := userIDFromContext(p.Context)
+	if err != nil {
+		return types.User{}, newGraphQLError(CodeUnauthorized, "unauthorized")
+	}
+	user, err := h.userService.GetByID(p.Context, userID)
+	if err != nil {
+		return types.User{}, newGraphQLError(CodeInternal, "failed to load user")
+	}
+	return user, nil
+}
+
+func requiredID(p graphql.Params) (int, error) {
+	id, err := p.Int("id", 0)
+	if err != nil {
+		return 0, newGraphQLError(CodeInvalidRequest, err.Error())
+	}
+	if id < 1 {
+		return 0, newGraphQLError(CodeInvalidRequest, "invalid id")
+	}
+	return id, nil
+}
+
+// graphQLPagination reads the page and limit arguments with the defaults
+// and bounds of parsePagination.
+func graphQLPagination(p graphql.Params) (page, limit, offset int, err error) {
+	page, err = p.Int("page", defaultPage)
+	if err != nil || page < 1 {
+		return 0, 0, 0, newGraphQLError(CodeInvalidRequest, "invalid page")
+	}
+	limit, err = p.Int("limit", defaultLimit)
+	if err != nil || limit < 1 {
+		return 0, 0, 0, newGraphQLError(CodeInvalidRequest, "invalid limit")
+	}
+	limit = min(limit, maxLimit)
+	return page, limit, (page - 1) * limit, nil
+}
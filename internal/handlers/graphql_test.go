@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeSubmissionRepo struct {
+	services.SubmissionRepository
+	submissions map[int64]types.Submission
+	// solved holds user and problem ID pairs.
+	solved map[[2]int]bool
+}
+
+func (r *fakeSubmissionRepo) Get(_ context.Context, id int64) (types.Submission, error) {
+	submission, ok := r.submissions[id]
+	if !ok {
+		return types.Submission{}, store.ErrNotFound
+	}
+	return submission, nil
+}
+
+func (r *fakeSubmissionRepo) HasSolved(_ context.Context, userID, problemID int) (bool, error) {
+	return r.solved[[2]int{userID, problemID}], nil
+}
+
+const (
+	author   = 1
+	stranger = 2
+	reviewer = 3
+	admin    = 4
+	setter   = 5
+	solver   = 6
+
+	draftProblem     = 10
+	publishedProblem = 11
+)
+
+// visibilityRouter serves the REST problem and submission endpoints and
+// the GraphQL endpoint over the same fixtures:
+//
+//   - problem 10 is a draft by the author; 11 is published and shows
+//     accepted code to those who solved it.
+//   - submission 100 is the author's on the draft; 101 and 102 are the
+//     stranger's accepted and rejected ones on problem 11; 103 is the
+//     stranger's on the draft.
+func visibilityRouter() http.Handler {
+	problems := services.NewProblemService(&fakeProblemRepo{problems: map[int]types.Problem{
+		draftProblem:     {ID: draftProblem, Title: "draft", OwnerID: author},
+		publishedProblem: {ID: publishedProblem, Title: "published", OwnerID: author, Published: true, SolutionVisibility: types.SolutionsAfterSolving},
+	}}, nil)
+	users := services.NewUserService(&fakeUserRepo{users: map[int]types.User{
+		author:   {ID: author, Role: types.RoleSetter},
+		stranger: {ID: stranger, Role: types.RoleUser},
+		reviewer: {ID: reviewer, Role: types.RoleReviewer},
+		admin:    {ID: admin, Role: types.RoleAdmin},
+		setter:   {ID: setter, Role: types.RoleSetter},
+		solver:   {ID: solver, Role: types.RoleUser},
+	}}, nil)
+	submissions := services.NewSubmissionService(&fakeSubmissionRepo{
+		submissions: map[int64]types.Submission{
+			100: {ID: 100, ProblemID: draftProblem, UserID: author, Verdict: types.VerdictAccepted, Code: "draft"},
+			101: {ID: 101, ProblemID: publishedProblem, UserID: stranger, Verdict: types.VerdictAccepted, Code: "accepted"},
+			102: {ID: 102, ProblemID: publishedProblem, UserID: stranger, Verdict: types.VerdictWrongAnswer, Code: "rejected"},
+			103: {ID: 103, ProblemID: draftProblem, UserID: stranger, Verdict: types.VerdictAccepted, Code: "early"},
+		},
+		solved: map[[2]int]bool{{solver, publishedProblem}: true},
+	}, nil, nil, nil)
+
+	r := chi.NewRouter()
+	r.Route("/problems", func(r chi.Router) {
+		ProblemRouter(r, problems, nil, nil, nil, nil, nil, users, fakeAuth)
+	})
+	r.Route("/submissions", func(r chi.Router) {
+		SubmissionRouter(r, submissions, problems, users, fakeAuth)
+	})
+	r.Route("/graphql", func(r chi.Router) {
+		GraphQLRouter(r, problems, submissions, nil, users, fakeAuth)
+	})
+	return r
+}
+
+func serve(t *testing.T, h http.Handler, req *http.Request, caller int) *httptest.ResponseRecorder {
+	t.Helper()
+	if caller != 0 {
+		req.Header.Set("Authorization", "Bearer "+strconv.Itoa(caller))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+type graphQLResult struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Path       []any          `json:"path"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func queryGraphQL(t *testing.T, h http.Handler, query string, caller int) graphQLResult {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatalf("encode query: %v", err)
+	}
+	rec := serve(t, h, httptest.NewRequest(http.MethodPost, "/graphql/", bytes.NewReader(body)), caller)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("graphql status = %d: %s", rec.Code, rec.Body)
+	}
+	var result graphQLResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return result
+}
+
+// errorCode returns the code of the first error, or "" when there is none.
+func (r graphQLResult) errorCode() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	code, _ := r.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func TestGraphQLProblemVisibilityMatchesREST(t *testing.T) {
+	h := visibilityRouter()
+	callers := []int{0, author, stranger, reviewer, admin, setter, solver}
+	visible := map[int][]int{
+		draftProblem:     {author, reviewer, admin},
+		publishedProblem: callers,
+		12:               nil,
+	}
+	for problemID, allowed := range visible {
+		for _, caller := range callers {
+			t.Run(fmt.Sprintf("problem %d caller %d", problemID, caller), func(t *testing.T) {
+				want := slices.Contains(allowed, caller)
+
+				rest := serve(t, h, httptest.NewRequest(http.MethodGet, "/problems/"+strconv.Itoa(problemID)+"/", nil), caller)
+				if got := rest.Code == http.StatusOK; got != want {
+					t.Fatalf("REST status = %d, want visible %v", rest.Code, want)
+				}
+
+				result := queryGraphQL(t, h, fmt.Sprintf(`{ problem(id: %d) { id title } }`, problemID), caller)
+				if got := string(result.Data["problem"]) != "null"; got != want {
+					t.Fatalf("GraphQL problem = %s, want visible %v", result.Data["problem"], want)
+				}
+				if !want && result.errorCode() != string(CodeProblemNotFound) {
+					t.Fatalf("GraphQL errors = %+v, want code %s", result.Errors, CodeProblemNotFound)
+				}
+			})
+		}
+	}
+}
+
+func TestGraphQLSubmissionVisibilityMatchesREST(t *testing.T) {
+	h := visibilityRouter()
+	callers := []int{author, stranger, reviewer, admin, setter, solver}
+	visible := map[int][]int{
+		100: {author, admin},
+		101: {stranger, admin, solver},
+		102: {stranger, admin},
+		103: {stranger, admin},
+	}
+	for submissionID, allowed := range visible {
+		for _, caller := range callers {
+			t.Run(fmt.Sprintf("submission %d caller %d", submissionID, caller), func(t *testing.T) {
+				want := slices.Contains(allowed, caller)
+
+				rest := serve(t, h, httptest.NewRequest(http.MethodGet, "/submissions/"+strconv.Itoa(submissionID), nil), caller)
+				if got := rest.Code == http.StatusOK; got != want {
+					t.Fatalf("REST status = %d, want visible %v", rest.Code, want)
+				}
+
+				result := queryGraphQL(t, h, fmt.Sprintf(`{ submission(id: %d) { id code } }`, submissionID), caller)
+				if got := string(result.Data["submission"]) != "null"; got != want {
+					t.Fatalf("GraphQL submission = %s, want visible %v", result.Data["submission"], want)
+				}
+				if !want && result.errorCode() != string(CodeSubmissionNotFound) {
+					t.Fatalf("GraphQL errors = %+v, want code %s", result.Errors, CodeSubmissionNotFound)
+				}
+			})
+		}
+	}
+
+	t.Run("anonymous", func(t *testing.T) {
+		rest := serve(t, h, httptest.NewRequest(http.MethodGet, "/submissions/101", nil), 0)
+		if rest.Code != http.StatusUnauthorized {
+			t.Fatalf("REST status = %d, want %d", rest.Code, http.StatusUnauthorized)
+		}
+		result := queryGraphQL(t, h, `{ submission(id: 101) { id } }`, 0)
+		if string(result.Data["submission"]) != "null" || result.errorCode() != string(CodeUnauthorized) {
+			t.Fatalf("GraphQL = %s %+v, want null with code %s", result.Data["submission"], result.Errors, CodeUnauthorized)
+		}
+	})
+}
+
+// TestGraphQLNestedProblemHidesDrafts checks that a problem reached
+// through a visible submission is still subject to the problem's own
+// visibility.
+func TestGraphQLNestedProblemHidesDrafts(t *testing.T) {
+	h := visibilityRouter()
+
+	result := queryGraphQL(t, h, `{ submission(id: 103) { id problem { title } } }`, stranger)
+	var submission struct {
+		ID      int             `json:"id"`
+		Problem json.RawMessage `json:"problem"`
+	}
+	if err := json.Unmarshal(result.Data["submission"], &submission); err != nil {
+		t.Fatalf("decode submission: %v", err)
+	}
+	if submission.ID != 103 || string(submission.Problem) != "null" {
+		t.Fatalf("submission = %s, want id 103 without its problem", result.Data["submission"])
+	}
+	if result.errorCode() != string(CodeProblemNotFound) || fmt.Sprint(result.Errors[0].Path) != "[submission problem]" {
+		t.Fatalf("errors = %+v, want %s at submission.problem", result.Errors, CodeProblemNotFound)
+	}
+
+	result = queryGraphQL(t, h, `{ submission(id: 100) { problem { title } } }`, author)
+	if got, want := string(result.Data["submission"]), `{"problem":{"title":"draft"}}`; got != want || len(result.Errors) > 0 {
+		t.Fatalf("author's submission = %s %+v, want %s", got, result.Errors, want)
+	}
+}
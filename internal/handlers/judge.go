@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	judgeTimeHeader    = "X-Judge-Time"
+	maxJudgeClockSkew  = 5 * time.Second
+	judgeCheckOK       = "ok"
+	judgeCheckFailed   = "failed"
+	judgeCheckSkipped  = "skipped"
+	judgeStatusHealthy = "ok"
+	judgeStatusFailing = "failing"
+
+	maxJudgeResultBytes = 8 << 20
+)
+
+// JudgeHandler provides HTTP handlers for judge workers.
+type JudgeHandler struct {
+	judgeService      *services.JudgeService
+	judgeDispatcher   *services.JudgeDispatcher
+	submissionService *services.SubmissionService
+	runService        *services.RunService
+	validationService *services.ProblemValidationService
+	generationService *services.TestcaseGenerationService
+	resultService     *services.JudgeResultService
+	token             []byte
+	workerKeys        services.JudgeWorkerKeys
+}
+
+// NewJudgeHandler constructs a JudgeHandler with the provided dependencies.
+func NewJudgeHandler(
+	judgeService *services.JudgeService,
+	judgeDispatcher *services.JudgeDispatcher,
+	submissionService *services.SubmissionService,
+	runService *services.RunService,
+	validationService *services.ProblemValidationService,
+	generationService *services.TestcaseGenerationService,
+	resultService *services.JudgeResultService,
+	judgeToken string,
+	workerKeys services.JudgeWorkerKeys,
+) *JudgeHandler {
+	return &JudgeHandler{
+		judgeService:      judgeService,
+		judgeDispatcher:   judgeDispatcher,
+		submissionService: submissionService,
+		runService:        runService,
+		validationService: validationService,
+		generationService: generationService,
+		resultService:     resultService,
+		token:             []byte(judgeToken),
+		workerKeys:        workerKeys,
+	}
+}
+
+// JudgeRouter registers judge-facing routes on the given router.
+func JudgeRouter(
+	r chi.Router,
+	judgeService *services.JudgeService,
+	judgeDispatcher *services.JudgeDispatcher,
+	submissionService *services.SubmissionService,
+	runService *services.RunService,
+	validationService *services.ProblemValidationService,
+	generationService *services.TestcaseGenerationService,
+	resultService *services.JudgeResultService,
+	judgeToken string,
+	workerKeys services.JudgeWorkerKeys,
+) {
+	handler := NewJudgeHandler(judgeService, judgeDispatcher, submissionService, runService, validationService, generationService, resultService, judgeToken, workerKeys)
+
+	r.With(handler.requireJudgeToken).Get("/healthz", handler.Healthz)
+	r.With(handler.requireJudgeToken).Post("/workers", handler.RegisterWorker)
+	r.With(handler.requireJudgeToken).Get("/submissions/{submissionID}/code", handler.GetSubmissionCode)
+	r.With(handler.requireJudgeToken).Put("/runs/{runID}", handler.CompleteRun)
+	r.With(handler.requireJudgeToken).Put("/validations", handler.ReportValidation)
+	r.With(handler.requireJudgeToken).Put("/input-validations", handler.ReportInputValidation)
+	r.With(handler.requireJudgeToken).Get("/problems/{problemID}/bundle", handler.GetBundleURL)
+	r.With(handler.requireJudgeToken).Put("/problems/{problemID}/bundles/{version}", handler.UploadGeneratedBundle)
+	r.With(handler.requireWorker).Post("/results", handler.ReportResult)
+}
+
+// ReportResult applies a judge result posted by a worker, for deployments
+// where workers report over HTTP rather than through the message queue.
+// The body is a types.JudgeResult encoded as JSON or, with Content-Type
+// application/x-protobuf, as protobuf. Unknown JSON fields are rejected.
+func (h *JudgeHandler) ReportResult(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJudgeResultBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "judge result too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read judge result")
+		return
+	}
+
+	var result types.JudgeResult
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "", types.JudgeContentTypeJSON:
+		result, err = decodeStrictJudgeResult(data)
+	case types.JudgeContentTypeProtobuf:
+		result, err = types.DecodeJudgeResult(data, contentType)
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, "judge results must be JSON or protobuf")
+		return
+	}
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.resultService.Apply(r.Context(), result); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s not found", result.Kind))
+		case errors.Is(err, services.ErrStaleJudgeResult), errors.Is(err, services.ErrRunFinished):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, types.ErrInvalidJudgeMessage),
+			errors.Is(err, services.ErrInvalidRun),
+			errors.Is(err, services.ErrInvalidValidationResult):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to store judge result")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeStrictJudgeResult decodes a JSON judge result, rejecting unknown
+// fields and trailing data, and validates it.
+func decodeStrictJudgeResult(data []byte) (types.JudgeResult, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var result types.JudgeResult
+	if err := decoder.Decode(&result); err != nil {
+		return types.JudgeResult{}, fmt.Errorf("%w: %v", types.ErrInvalidJudgeMessage, err)
+	}
+	if decoder.More() {
+		return types.JudgeResult{}, fmt.Errorf("%w: unexpected data after result", types.ErrInvalidJudgeMessage)
+	}
+	if err := result.Validate(); err != nil {
+		return types.JudgeResult{}, err
+	}
+	return result, nil
+}
+
+// GetSubmissionCode returns the full source of a submission as plain text,
+// 410 Gone once the retention policy has removed it, or 409 Conflict when
+// the submitter cancelled it so the worker can skip the job.
+func (h *JudgeHandler) GetSubmissionCode(w http.ResponseWriter, r *http.Request) {
+	id, err := parseSubmissionID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	submission, err := h.submissionService.GetWithCode(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch submission code")
+		return
+	}
+	if submission.CodePrunedAt != nil {
+		writeErrorCode(w, http.StatusGone, CodeSubmissionCodePruned, "submission source was removed by the retention policy")
+		return
+	}
+	if submission.Verdict == types.VerdictCancelled {
+		writeErrorCode(w, http.StatusConflict, CodeSubmissionCancelled, "submission was cancelled")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, submission.Code)
+}
+
+// GetBundleURL returns a presigned URL for the latest testcase bundle of a
+// problem, so workers download it from object storage directly.
+func (h *JudgeHandler) GetBundleURL(w http.ResponseWriter, r *http.Request) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	download, err := h.judgeService.BundleDownloadURL(r.Context(), problemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeBundleNotFound, "testcase bundle not found")
+			return
+		}
+		if errors.Is(err, services.ErrBundleCorrupted) {
+			writeErrorFrom(w, http.StatusConflict, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to presign testcase bundle")
+		return
+	}
+	writeJSON(w, http.StatusOK, download)
+}
+
+// UploadGeneratedBundle accepts the tar.gz bundle a worker materialized
+// from bundle {version} of a problem and stores it as the next version.
+func (h *JudgeHandler) UploadGeneratedBundle(w http.ResponseWriter, r *http.Request) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 {
+		writeError(w, http.StatusBadRequest, "invalid bundle version")
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBundleBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read bundle")
+		return
+	}
+	if len(data) > maxBundleBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, "bundle too large")
+		return
+	}
+
+	bundle, err := h.generationService.Complete(r.Context(), problemID, version, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+		case errors.Is(err, services.ErrGenerationNotPending):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, services.ErrInvalidGeneratedBundle):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to store generated bundle")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+// ReportValidation stores the verdict a reference solution received
+// against a bundle version.
+func (h *JudgeHandler) ReportValidation(w http.ResponseWriter, r *http.Request) {
+	var req ValidationResultRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := h.validationService.Report(r.Context(), types.SolutionValidation{
+		ProblemID:         req.ProblemID,
+		BundleVersion:     req.BundleVersion,
+		ReferenceSolution: types.ReferenceSolution{File: req.Solution},
+		Verdict:           req.Verdict,
+		Message:           req.Message,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeValidationNotFound, "validation not found")
+		case errors.Is(err, services.ErrInvalidValidationResult):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to store validation result")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ReportInputValidation stores the outcome of running a bundle's input
+// validator. Workers list every rejected input; an empty list passes.
+func (h *JudgeHandler) ReportInputValidation(w http.ResponseWriter, r *http.Request) {
+	var req InputValidationResultRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := h.validationService.ReportInputs(r.Context(), types.InputValidation{
+		ProblemID:     req.ProblemID,
+		BundleVersion: req.BundleVersion,
+		Errors:        req.Errors,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeValidationNotFound, "input validation not found")
+		case errors.Is(err, services.ErrInvalidValidationResult):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to store input validation result")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// InputValidationResultRequest lists the inputs rejected by a bundle's
+// input validator.
+type InputValidationResultRequest struct {
+	ProblemID     int                `json:"problem_id"`
+	BundleVersion int                `json:"bundle_version"`
+	Errors        []types.InputError `json:"errors"`
+}
+
+// ValidationResultRequest is the verdict of a reference solution reported
+// by a worker.
+type ValidationResultRequest struct {
+	ProblemID     int           `json:"problem_id"`
+	BundleVersion int           `json:"bundle_version"`
+	Solution      string        `json:"solution"`
+	Verdict       types.Verdict `json:"verdict"`
+	Message       string        `json:"message"`
+}
+
+// CompleteRun stores the outcome of a custom run. A run accepts exactly one
+// final result.
+func (h *JudgeHandler) CompleteRun(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRunID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req RunResultRequest
+	limit := int64(2*services.MaxRunOutputBytes + 1<<20)
+	if err := json.NewDecoder(io.LimitReader(r.Body, limit)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	run, err := h.runService.Complete(r.Context(), types.Run{
+		ID:      id,
+		Verdict: req.Verdict,
+		Stdout:  req.Stdout,
+		Stderr:  req.Stderr,
+		CPUTime: req.CPUTime,
+		Memory:  req.Memory,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeRunNotFound, "run not found")
+		case errors.Is(err, services.ErrRunFinished):
+			writeErrorFrom(w, http.StatusConflict, err)
+		case errors.Is(err, services.ErrInvalidRun):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to store run result")
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// RunResultRequest is the outcome of a custom run reported by a worker.
+type RunResultRequest struct {
+	Verdict types.Verdict `json:"verdict"`
+	Stdout  string        `json:"stdout"`
+	Stderr  string        `json:"stderr"`
+	CPUTime int64         `json:"cpu_time"`
+	Memory  int64         `json:"memory"`
+}
+
+// RegisterWorker records a worker's capabilities and heartbeat. Workers call
+// it on startup and periodically afterwards, then consume the returned
+// channel; jobs are only routed to pools with recent heartbeats.
+func (h *JudgeHandler) RegisterWorker(w http.ResponseWriter, r *http.Request) {
+	var req JudgeWorkerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	worker, err := h.judgeDispatcher.Register(r.Context(), types.JudgeWorker{
+		ID:        req.ID,
+		Pool:      req.Pool,
+		Languages: req.Languages,
+		Arch:      req.Arch,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidJudgeWorker) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to register worker")
+		return
+	}
+	writeJSON(w, http.StatusOK, worker)
+}
+
+// JudgeWorkerRequest is the registration payload sent by judge workers.
+type JudgeWorkerRequest struct {
+	ID        string   `json:"id"`
+	Pool      string   `json:"pool"`
+	Languages []string `json:"languages"`
+	Arch      string   `json:"arch"`
+}
+
+// Healthz reports whether a judge node is correctly set up to talk to the
+// server: its token, bundle download access and clock skew.
+func (h *JudgeHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	checks := []JudgeHealthCheck{
+		{Name: "token", Status: judgeCheckOK},
+		h.checkBundleAccess(r),
+		checkClockSkew(r, now),
+	}
+
+	resp := JudgeHealthResponse{
+		Status:     judgeStatusHealthy,
+		ServerTime: now,
+		Checks:     checks,
+	}
+	status := http.StatusOK
+	for _, check := range checks {
+		if check.Status == judgeCheckFailed {
+			resp.Status = judgeStatusFailing
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeJSON(w, status, resp)
+}
+
+// JudgeHealthResponse is the diagnostics payload returned to judge nodes.
+type JudgeHealthResponse struct {
+	Status     string             `json:"status"`
+	ServerTime time.Time          `json:"server_time"`
+	Checks     []JudgeHealthCheck `json:"checks"`
+}
+
+// JudgeHealthCheck is the outcome of a single judge diagnostic.
+type JudgeHealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (h *JudgeHandler) checkBundleAccess(r *http.Request) JudgeHealthCheck {
+	check := JudgeHealthCheck{Name: "bundle_access"}
+
+	raw := strings.TrimSpace(r.URL.Query().Get("problem_id"))
+	if raw == "" {
+		check.Status = judgeCheckSkipped
+		check.Detail = "pass ?problem_id= to verify bundle download access"
+		return check
+	}
+	problemID, err := strconv.Atoi(raw)
+	if err != nil || problemID < 1 {
+		check.Status = judgeCheckFailed
+		check.Detail = "invalid problem id"
+		return check
+	}
+
+	if err := h.judgeService.CheckBundleAccess(r.Context(), problemID); err != nil {
+		check.Status = judgeCheckFailed
+		if errors.Is(err, store.ErrNotFound) {
+			check.Detail = fmt.Sprintf("problem %d has no testcase bundle", problemID)
+		} else {
+			check.Detail = err.Error()
+		}
+		return check
+	}
+
+	check.Status = judgeCheckOK
+	return check
+}
+
+func checkClockSkew(r *http.Request, now time.Time) JudgeHealthCheck {
+	check := JudgeHealthCheck{Name: "clock_skew"}
+
+	raw := strings.TrimSpace(r.Header.Get(judgeTimeHeader))
+	if raw == "" {
+		check.Status = judgeCheckSkipped
+		check.Detail = fmt.Sprintf("send the %s header (RFC 3339) to verify clock skew", judgeTimeHeader)
+		return check
+	}
+	judgeTime, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		check.Status = judgeCheckFailed
+		check.Detail = fmt.Sprintf("invalid %s header, expected RFC 3339", judgeTimeHeader)
+		return check
+	}
+
+	skew := judgeTime.Sub(now)
+	check.Detail = fmt.Sprintf("judge clock differs from server by %s", skew.Round(time.Millisecond))
+	if skew.Abs() > maxJudgeClockSkew {
+		check.Status = judgeCheckFailed
+		check.Detail += fmt.Sprintf(" (max %s), check NTP on the judge node", maxJudgeClockSkew)
+		return check
+	}
+	check.Status = judgeCheckOK
+	return check
+}
+
+// requireWorker authenticates an individual judge worker, either by a
+// client certificate verified against the judge CA or by a worker API key
+// sent as a bearer token. The shared judge token is not accepted.
+func (h *JudgeHandler) requireWorker(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, err := bearerToken(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "missing worker credentials")
+			return
+		}
+		if !h.workerKeys.Valid(key) {
+			writeError(w, http.StatusUnauthorized, "invalid worker key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *JudgeHandler) requireJudgeToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := bearerToken(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "missing judge token")
+			return
+		}
+		if len(h.token) == 0 || subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid judge token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
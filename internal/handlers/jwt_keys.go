@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKeys are the keys tokens are signed and verified with. Tokens are
+// signed with the current key and name it in their "kid" header. Other
+// keys only verify, so rotating the signing key does not end every session
+// at once.
+//
+// Signing is either HS256 with a shared secret or, with an RSA or Ed25519
+// private key, RS256 or EdDSA. The public half of asymmetric keys is
+// published as a JWK set so that other services can verify tokens without
+// holding the secret.
+type JWTKeys struct {
+	currentID string
+	method    jwt.SigningMethod
+	signing   any
+	keys      map[string]jwtKey
+}
+
+// jwtKey is a verification key and the only method it is accepted for.
+type jwtKey struct {
+	method jwt.SigningMethod
+	key    any
+}
+
+// NewJWTKeys returns keys that sign with the HMAC secret current and also
+// verify with previous. Blank previous secrets are ignored.
+func NewJWTKeys(current string, previous ...string) *JWTKeys {
+	current = strings.TrimSpace(current)
+	keys := &JWTKeys{
+		currentID: secretKeyID(current),
+		method:    jwt.SigningMethodHS256,
+		signing:   []byte(current),
+		keys:      map[string]jwtKey{},
+	}
+	keys.AddSecret(current)
+	for _, secret := range previous {
+		keys.AddSecret(secret)
+	}
+	return keys
+}
+
+// NewAsymmetricJWTKeys returns keys that sign with the PEM encoded RSA or
+// Ed25519 private key, using RS256 or EdDSA respectively, and also verify
+// with the PEM encoded public keys in previous.
+func NewAsymmetricJWTKeys(privatePEM []byte, previous ...[]byte) (*JWTKeys, error) {
+	private, err := parsePrivateKeyPEM(privatePEM)
+	if err != nil {
+		return nil, err
+	}
+	keys := &JWTKeys{keys: map[string]jwtKey{}}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		keys.method = jwt.SigningMethodRS256
+		keys.signing = private
+		keys.currentID, err = keys.addPublicKey(&private.PublicKey)
+	case ed25519.PrivateKey:
+		keys.method = jwt.SigningMethodEdDSA
+		keys.signing = private
+		keys.currentID, err = keys.addPublicKey(private.Public())
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, publicPEM := range previous {
+		public, err := parsePublicKeyPEM(publicPEM)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := keys.addPublicKey(public); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Algorithm returns the JWS algorithm tokens are signed with.
+func (k *JWTKeys) Algorithm() string {
+	return k.method.Alg()
+}
+
+// AddSecret makes an HMAC secret verify HS256 tokens, such as those issued
+// before switching to asymmetric signing. A blank secret is ignored.
+func (k *JWTKeys) AddSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return
+	}
+	k.keys[secretKeyID(secret)] = jwtKey{
+		method: jwt.SigningMethodHS256,
+		key:    []byte(secret),
+	}
+}
+
+func (k *JWTKeys) addPublicKey(public any) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", public)
+	}
+	id := jwtKeyID(der)
+	k.keys[id] = jwtKey{method: method, key: public}
+	return id, nil
+}
+
+// jwtKeyID derives a key ID from key material, so rotation needs no
+// separate key ID configuration.
+func jwtKeyID(material []byte) string {
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+func secretKeyID(secret string) string {
+	return jwtKeyID([]byte("jjudge-jwt-key:" + secret))
+}
+
+func (k *JWTKeys) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.currentID
+	return token.SignedString(k.signing)
+}
+
+// verificationKey selects the key for a token by its "kid" header. A key
+// only verifies tokens of its own algorithm, so a public key can never be
+// used as an HMAC secret. Tokens issued before key IDs were introduced
+// are tried against every key of their algorithm.
+func (k *JWTKeys) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		var set jwt.VerificationKeySet
+		for _, key := range k.keys {
+			if key.method.Alg() == token.Method.Alg() {
+				set.Keys = append(set.Keys, key.key)
+			}
+		}
+		if len(set.Keys) == 0 {
+			return nil, errors.New("invalid signing method")
+		}
+		return set, nil
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if key.method.Alg() != token.Method.Alg() {
+		return nil, errors.New("invalid signing method")
+	}
+	return key.key, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// N and E are the RSA modulus and exponent.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve and X are the Ed25519 curve name and public key.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKeys returns the asymmetric verification keys. HMAC secrets are
+// never included.
+func (k *JWTKeys) PublicKeys() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for id, key := range k.keys {
+		jwk := JWK{KeyID: id, Algorithm: key.method.Alg(), Use: "sig"}
+		switch public := key.key.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	slices.SortFunc(set.Keys, func(a, b JWK) int {
+		return strings.Compare(a.KeyID, b.KeyID)
+	})
+	return set
+}
+
+func parsePrivateKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("private key is neither PKCS #8 nor PKCS #1")
+}
+
+func parsePublicKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block in public key")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("public key is neither PKIX nor PKCS #1")
+}
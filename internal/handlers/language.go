@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// LanguageRouter registers the language registry routes on the given
+// router.
+func LanguageRouter(r chi.Router) {
+	r.Get("/", ListLanguages)
+}
+
+// ListLanguages returns the languages submissions and runs may use, with
+// the names accepted for each and their syntax highlighting mode.
+func ListLanguages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, LanguageListResponse{Items: services.DefaultLanguages})
+}
+
+// LanguageListResponse lists the registered languages.
+type LanguageListResponse struct {
+	Items []types.Language `json:"items"`
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// LeaderboardHandler provides HTTP handlers for the global leaderboard.
+type LeaderboardHandler struct {
+	leaderboardService *services.LeaderboardService
+}
+
+// NewLeaderboardHandler constructs a LeaderboardHandler with the provided services.
+func NewLeaderboardHandler(leaderboardService *services.LeaderboardService) *LeaderboardHandler {
+	return &LeaderboardHandler{leaderboardService: leaderboardService}
+}
+
+// LeaderboardRouter registers leaderboard routes on the given router.
+func LeaderboardRouter(r chi.Router, leaderboardService *services.LeaderboardService) {
+	handler := NewLeaderboardHandler(leaderboardService)
+
+	r.Get("/", handler.GetLeaderboard)
+}
+
+// GetLeaderboard returns ranked users. Query parameters:
+//   - period: "all" (default) or "monthly"
+//   - month: YYYY-MM for the monthly period, defaulting to the current UTC month
+//   - sort: "solved" (default) or "score"
+//
+// Responses carry an ETag, so polls of an unchanged page get 304 Not
+// Modified.
+func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	period := types.LeaderboardPeriodAll
+	switch strings.TrimSpace(query.Get("period")) {
+	case "", "all":
+	case "monthly":
+		period = strings.TrimSpace(query.Get("month"))
+		if period == "" {
+			period = time.Now().UTC().Format("2006-01")
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "invalid period")
+		return
+	}
+	sortBy := strings.TrimSpace(query.Get("sort"))
+
+	entries, total, err := h.leaderboardService.List(r.Context(), period, sortBy, offset, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLeaderboardQuery) {
+			writeError(w, http.StatusBadRequest, "invalid month or sort")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load leaderboard")
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, LeaderboardResponse{
+		Items:      entries,
+		Period:     period,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// LeaderboardResponse is a page of leaderboard entries.
+type LeaderboardResponse struct {
+	Items  []types.LeaderboardEntry `json:"items"`
+	Period string                   `json:"period"`
+	Pagination
+}
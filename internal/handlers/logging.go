@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// LogLevel holds the server's log level, which can be changed while the
+// server runs.
+type LogLevel struct {
+	level atomic.Value
+}
+
+// NewLogLevel constructs a LogLevel set to level: "debug", "info", "warn"
+// or "error".
+func NewLogLevel(level string) *LogLevel {
+	l := &LogLevel{}
+	l.Set(level)
+	return l
+}
+
+// Set changes the level.
+func (l *LogLevel) Set(level string) {
+	l.level.Store(level)
+}
+
+// Get returns the level.
+func (l *LogLevel) Get() string {
+	return l.level.Load().(string)
+}
+
+// RequestLogger logs every request, like middleware.Logger, while the
+// level is "info" or "debug".
+func (l *LogLevel) RequestLogger(next http.Handler) http.Handler {
+	logged := middleware.Logger(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch l.Get() {
+		case "debug", "info":
+			logged.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ModerationHandler provides HTTP handlers for abuse reports and bans.
+type ModerationHandler struct {
+	moderationService *services.ModerationService
+	userService       *services.UserService
+}
+
+// NewModerationHandler constructs a ModerationHandler with the provided
+// services.
+func NewModerationHandler(moderationService *services.ModerationService, userService *services.UserService) *ModerationHandler {
+	return &ModerationHandler{
+		moderationService: moderationService,
+		userService:       userService,
+	}
+}
+
+// ModerationRouter registers moderation routes on the given router. Any
+// signed-in user may file a report; the queue and bans are for admins.
+func ModerationRouter(r chi.Router, moderationService *services.ModerationService, userService *services.UserService, authMiddleware func(http.Handler) http.Handler) {
+	handler := NewModerationHandler(moderationService, userService)
+
+	r.Use(authMiddleware)
+	r.Post("/reports", handler.CreateReport)
+	r.Group(func(r chi.Router) {
+		r.Use(requireAdmin(userService))
+		r.Get("/reports", handler.ListReports)
+		r.Post("/reports/{reportID}/resolve", handler.ResolveReport)
+		r.Put("/bans/{username}", handler.BanUser)
+		r.Delete("/bans/{username}", handler.UnbanUser)
+	})
+}
+
+// CreateReport flags a comment, problem or user for moderators.
+func (h *ModerationHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReportRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxReportReasonBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	report, err := h.moderationService.Report(r.Context(), user, types.ContentReport{
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeNotFound, "reported content not found")
+			return
+		}
+		writeModerationError(w, err, "failed to file report")
+		return
+	}
+	writeJSON(w, http.StatusCreated, report)
+}
+
+// ListReports returns a page of reports, oldest first. ?status= filters
+// them to open, resolved or dismissed ones.
+func (h *ModerationHandler) ListReports(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := types.ReportStatus(r.URL.Query().Get("status"))
+	reports, total, err := h.moderationService.List(r.Context(), status, offset, limit)
+	if err != nil {
+		writeModerationError(w, err, "failed to list reports")
+		return
+	}
+	writeJSON(w, http.StatusOK, ReportListResponse{
+		Items:      reports,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// ResolveReport closes an open report with an action: none to dismiss
+// it, hide to hide the content, or ban to ban its author.
+func (h *ModerationHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "invalid report id")
+		return
+	}
+	moderator, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReportResolutionRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxReportReasonBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	report, err := h.moderationService.Resolve(r.Context(), moderator, id, req.Action, req.Note)
+	if err != nil {
+		writeModerationError(w, err, "failed to resolve report")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// BanUser bars a user from signing in and ends their sessions.
+func (h *ModerationHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req BanRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxReportReasonBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ban, err := h.moderationService.Ban(r.Context(), moderator, user.ID, req.Reason)
+	if err != nil {
+		writeModerationError(w, err, "failed to ban user")
+		return
+	}
+	writeJSON(w, http.StatusOK, ban)
+}
+
+// UnbanUser lifts a user's ban.
+func (h *ModerationHandler) UnbanUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.pathUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.moderationService.Unban(r.Context(), user.ID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeBanNotFound, "user is not banned")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to unban user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ModerationHandler) pathUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	user, err := h.userService.GetByUsername(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeUserNotFound, "user not found")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+func (h *ModerationHandler) currentUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return types.User{}, false
+	}
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+// ReportRequest is the payload for filing a report.
+type ReportRequest struct {
+	TargetType types.ReportTarget `json:"target_type"`
+	TargetID   int64              `json:"target_id"`
+	Reason     string             `json:"reason"`
+}
+
+// ReportResolutionRequest is the payload for resolving a report.
+type ReportResolutionRequest struct {
+	Action types.ReportAction `json:"action"`
+	Note   string             `json:"note"`
+}
+
+// BanRequest is the payload for banning a user.
+type BanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReportListResponse is a page of the moderation queue.
+type ReportListResponse struct {
+	Items []types.ContentReport `json:"items"`
+	Pagination
+}
+
+func writeModerationError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, CodeReportNotFound, "report not found")
+	case errors.Is(err, services.ErrInvalidReport), errors.Is(err, services.ErrInvalidModerationAction):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrReportClosed):
+		writeErrorFrom(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
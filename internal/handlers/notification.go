@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// NotificationHandler provides HTTP handlers for the caller's
+// notification center.
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationHandler constructs a NotificationHandler with the provided services.
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// NotificationRouter registers notification routes on the given router.
+func NotificationRouter(
+	r chi.Router,
+	notificationService *services.NotificationService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewNotificationHandler(notificationService)
+
+	r.Use(authMiddleware)
+	r.Get("/", handler.ListNotifications)
+	r.Get("/unread-count", handler.GetUnreadCount)
+	r.Get("/preferences", handler.GetPreferences)
+	r.Put("/preferences", handler.UpdatePreferences)
+	r.Post("/read", handler.MarkAllRead)
+	r.Post("/{notificationID}/read", handler.MarkRead)
+}
+
+// ListNotifications returns the caller's notifications newest first, or
+// only the unread ones with ?unread=true, along with the unread count.
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	unreadOnly := false
+	if raw := r.URL.Query().Get("unread"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid unread")
+			return
+		}
+		unreadOnly = parsed
+	}
+
+	items, total, err := h.notificationService.List(r.Context(), userID, unreadOnly, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list notifications")
+		return
+	}
+	unread, err := h.notificationService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to count notifications")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, NotificationListResponse{
+		Items:      items,
+		Unread:     unread,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// GetUnreadCount returns the number of the caller's unread notifications,
+// for clients that poll for a badge count.
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	unread, err := h.notificationService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to count notifications")
+		return
+	}
+	writeJSON(w, http.StatusOK, UnreadCountResponse{Unread: unread})
+}
+
+// MarkRead marks one of the caller's notifications read.
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	id, err := parseNotificationID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.notificationService.MarkRead(r.Context(), userID, id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeNotificationNotFound, "notification not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to mark notification read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks all of the caller's notifications read.
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if _, err := h.notificationService.MarkAllRead(r.Context(), userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to mark notifications read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPreferences returns the caller's notification preferences.
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	prefs, err := h.notificationService.Preferences(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to fetch notification preferences")
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences replaces the caller's notification preferences.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req NotificationPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	prefs, err := h.notificationService.SetPreferences(r.Context(), types.NotificationPreferences{
+		UserID:             userID,
+		EmailNotifications: req.EmailNotifications,
+		VerdictDigest:      req.VerdictDigest,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNotificationPreferences) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to update notification preferences")
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// NotificationPreferencesRequest is the payload for updating notification
+// preferences. VerdictDigest is "off", "daily" or "weekly"; empty means
+// "off".
+type NotificationPreferencesRequest struct {
+	EmailNotifications bool   `json:"email_notifications"`
+	VerdictDigest      string `json:"verdict_digest"`
+}
+
+// NotificationListResponse is a page of the caller's notifications.
+type NotificationListResponse struct {
+	Items []types.Notification `json:"items"`
+
+	// Unread is the number of unread notifications, whichever page or
+	// filter was requested.
+	Unread int `json:"unread"`
+	Pagination
+}
+
+// UnreadCountResponse is the number of the caller's unread notifications.
+type UnreadCountResponse struct {
+	Unread int `json:"unread"`
+}
+
+func parseNotificationID(r *http.Request) (int64, error) {
+	raw := chi.URLParam(r, "notificationID")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid notification id")
+	}
+	return id, nil
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/markdown"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// PostHandler provides HTTP handlers for news posts.
+type PostHandler struct {
+	postService *services.PostService
+	userService *services.UserService
+}
+
+// NewPostHandler constructs a PostHandler with the provided services.
+func NewPostHandler(postService *services.PostService, userService *services.UserService) *PostHandler {
+	return &PostHandler{
+		postService: postService,
+		userService: userService,
+	}
+}
+
+// PostRouter registers post routes on the given router. Anyone may read
+// published posts; admins write them and also see drafts.
+func PostRouter(
+	r chi.Router,
+	postService *services.PostService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewPostHandler(postService, userService)
+	admin := requireAdmin(userService)
+
+	r.With(optionalAuth(authMiddleware)).Get("/", handler.ListPosts)
+	r.With(authMiddleware, admin).Post("/", handler.CreatePost)
+	r.Route("/{postID}", func(r chi.Router) {
+		r.With(optionalAuth(authMiddleware)).Get("/", handler.GetPost)
+		r.With(authMiddleware, admin).Put("/", handler.UpdatePost)
+		r.With(authMiddleware, admin).Delete("/", handler.DeletePost)
+	})
+}
+
+// ListPosts returns a page of published posts, pinned ones first and
+// newest first otherwise. Pass ?tag= to filter by tag. Admins may pass
+// ?drafts=true to include drafts and scheduled posts.
+func (h *PostHandler) ListPosts(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	includeDrafts := false
+	if raw := r.URL.Query().Get("drafts"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid drafts")
+			return
+		}
+		includeDrafts = parsed
+	}
+	if includeDrafts {
+		admin, err := callerIsAdmin(r, h.userService)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		if !admin {
+			writeError(w, http.StatusForbidden, "admin access required")
+			return
+		}
+	}
+
+	items, total, err := h.postService.List(r.Context(), r.URL.Query().Get("tag"), includeDrafts, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list posts")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PostListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// GetPost returns a published post, or a draft to admins. Pass
+// ?format=html to get the body rendered from Markdown.
+func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePostID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	admin, err := callerIsAdmin(r, h.userService)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+
+	post, err := h.postService.Get(r.Context(), id, admin)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodePostNotFound, "post not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch post")
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "markdown":
+		writeJSON(w, http.StatusOK, post)
+	case "html":
+		rendered, err := markdown.Render(post.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to render body")
+			return
+		}
+		post.Body = rendered
+		writeJSON(w, http.StatusOK, PostDetailResponse{Post: post, BodyFormat: "html"})
+	default:
+		writeError(w, http.StatusBadRequest, "invalid format")
+	}
+}
+
+func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req PostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	created, err := h.postService.Create(r.Context(), types.Post{
+		Title:       req.Title,
+		Body:        req.Body,
+		Tags:        req.Tags,
+		Pinned:      req.Pinned,
+		PublishedAt: req.PublishedAt,
+		AuthorID:    userID,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPost) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create post")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePostID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req PostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	updated, err := h.postService.Update(r.Context(), types.Post{
+		ID:          id,
+		Title:       req.Title,
+		Body:        req.Body,
+		Tags:        req.Tags,
+		Pinned:      req.Pinned,
+		PublishedAt: req.PublishedAt,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPost):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodePostNotFound, "post not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to update post")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parsePostID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.postService.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodePostNotFound, "post not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete post")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostRequest is the JSON payload for creating or updating a post. A null
+// published_at saves a draft; a future one schedules the post.
+type PostRequest struct {
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Tags        []string   `json:"tags"`
+	Pinned      bool       `json:"pinned"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// PostListResponse is the paginated list response payload.
+type PostListResponse struct {
+	Items []types.Post `json:"items"`
+	Pagination
+}
+
+// PostDetailResponse is a post whose body has been rendered.
+type PostDetailResponse struct {
+	types.Post
+	BodyFormat string `json:"body_format"`
+}
+
+func parsePostID(r *http.Request) (int, error) {
+	raw := chi.URLParam(r, "postID")
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid post id")
+	}
+	return id, nil
+}
@@ -1,16 +1,19 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/markdown"
 	"github.com/jjudge-oj/apiserver/internal/services"
 	"github.com/jjudge-oj/apiserver/internal/store"
 	"github.com/jjudge-oj/apiserver/types"
@@ -22,7 +25,8 @@ const (
 	maxLimit            = 100
 	maxMultipartMemory  = 128 << 20
 	maxBundleBytes      = 256 << 20
-	adminRole           = "admin"
+	adminRole           = types.RoleAdmin
+	setterRole          = types.RoleSetter
 	formFieldBundle     = "bundle"
 	formFieldGroups     = "testcase_groups"
 	formFieldTitle      = "title"
@@ -31,6 +35,8 @@ const (
 	formFieldTimeLimit  = "time_limit"
 	formFieldMemLimit   = "memory_limit"
 	formFieldTags       = "tags"
+	formFieldType       = "type"
+	formFieldSolutions  = "solution_visibility"
 )
 
 // BundleFile represents an uploaded testcase bundle.
@@ -41,15 +47,27 @@ type BundleFile struct {
 
 // ProblemHandler provides HTTP handlers for problems.
 type ProblemHandler struct {
-	problemService *services.ProblemService
-	userService    *services.UserService
+	problemService    *services.ProblemService
+	validationService *services.ProblemValidationService
+	uploadService     *services.BundleUploadService
+	submissionService *services.SubmissionService
+	userService       *services.UserService
 }
 
 // NewProblemHandler constructs a handler with the provided store.
-func NewProblemHandler(problemService *services.ProblemService, userService *services.UserService) *ProblemHandler {
+func NewProblemHandler(
+	problemService *services.ProblemService,
+	validationService *services.ProblemValidationService,
+	uploadService *services.BundleUploadService,
+	submissionService *services.SubmissionService,
+	userService *services.UserService,
+) *ProblemHandler {
 	return &ProblemHandler{
-		problemService: problemService,
-		userService:    userService,
+		problemService:    problemService,
+		validationService: validationService,
+		uploadService:     uploadService,
+		submissionService: submissionService,
+		userService:       userService,
 	}
 }
 
@@ -57,92 +75,188 @@ func NewProblemHandler(problemService *services.ProblemService, userService *ser
 func ProblemRouter(
 	r chi.Router,
 	problemService *services.ProblemService,
+	validationService *services.ProblemValidationService,
+	uploadService *services.BundleUploadService,
+	reviewService *services.ProblemReviewService,
+	commentService *services.ProblemCommentService,
+	submissionService *services.SubmissionService,
 	userService *services.UserService,
 	authMiddleware func(http.Handler) http.Handler,
 ) {
-	handler := NewProblemHandler(problemService, userService)
+	handler := NewProblemHandler(problemService, validationService, uploadService, submissionService, userService)
+	reviewHandler := NewProblemReviewHandler(reviewService, problemService, userService)
+	commentHandler := NewProblemCommentHandler(commentService, userService)
 
 	r.Get("/", handler.ListProblems)
 	if authMiddleware != nil {
-		r.With(authMiddleware, handler.requireAdmin).Post("/", handler.CreateProblem)
+		r.With(authMiddleware, handler.requireSetter).Post("/", handler.CreateProblem)
 	} else {
-		r.With(handler.requireAdmin).Post("/", handler.CreateProblem)
+		r.With(handler.requireSetter).Post("/", handler.CreateProblem)
 	}
 	r.Route("/{problemID}", func(r chi.Router) {
-		r.Get("/", handler.GetProblem)
+		r.With(optionalAuth(authMiddleware)).Get("/", handler.GetProblem)
 		if authMiddleware != nil {
-			r.With(authMiddleware, handler.requireAdmin).Put("/", handler.UpdateProblem)
-			r.With(authMiddleware, handler.requireAdmin).Delete("/", handler.DeleteProblem)
+			r.With(authMiddleware).Get("/submissions", handler.ListSubmissions)
+			r.With(authMiddleware, handler.requireEditor).Put("/", handler.UpdateProblem)
+			r.With(authMiddleware, handler.requireEditor).Patch("/", handler.PatchProblem)
+			r.With(authMiddleware, handler.requireEditor).Put("/bundle", handler.UploadBundle)
+			r.With(authMiddleware, handler.requireEditor).Delete("/", handler.DeleteProblem)
+			r.With(authMiddleware, handler.requireEditor).Get("/tag-suggestions", handler.GetTagSuggestions)
+			r.With(authMiddleware, handler.requireEditor).Get("/validation", handler.GetValidation)
+			r.With(authMiddleware, handler.requireEditor).Get("/bundle-status", handler.GetBundleStatus)
 		} else {
-			r.With(handler.requireAdmin).Put("/", handler.UpdateProblem)
-			r.With(handler.requireAdmin).Delete("/", handler.DeleteProblem)
+			r.Get("/submissions", handler.ListSubmissions)
+			r.With(handler.requireEditor).Put("/", handler.UpdateProblem)
+			r.With(handler.requireEditor).Patch("/", handler.PatchProblem)
+			r.With(handler.requireEditor).Put("/bundle", handler.UploadBundle)
+			r.With(handler.requireEditor).Delete("/", handler.DeleteProblem)
+			r.With(handler.requireEditor).Get("/tag-suggestions", handler.GetTagSuggestions)
+			r.With(handler.requireEditor).Get("/validation", handler.GetValidation)
+			r.With(handler.requireEditor).Get("/bundle-status", handler.GetBundleStatus)
 		}
+		r.Group(func(r chi.Router) {
+			if authMiddleware != nil {
+				r.Use(authMiddleware)
+			}
+			problemReviewRoutes(r, reviewHandler, handler.requireEditor)
+		})
+		problemCommentRoutes(r, commentHandler, authMiddleware)
 	})
 }
 
+// ListProblems returns a page of problem summaries. Pass
+// ?include=description to add each problem's statement. Responses carry
+// an ETag, so clients polling an unchanged page get 304 Not Modified.
 func (h *ProblemHandler) ListProblems(w http.ResponseWriter, r *http.Request) {
 	page, limit, offset, err := parsePagination(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeErrorFrom(w, http.StatusBadRequest, err)
 		return
 	}
 
-	items, total, err := h.problemService.List(r.Context(), offset, limit)
+	withDescription := false
+	for _, field := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "description":
+			withDescription = true
+		default:
+			writeError(w, http.StatusBadRequest, "invalid include")
+			return
+		}
+	}
+
+	items, total, err := h.problemService.ListSummaries(r.Context(), offset, limit, withDescription)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list problems")
 		return
 	}
 
 	resp := ProblemListResponse{
-		Items: items,
-		Page:  page,
-		Limit: limit,
-		Total: total,
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
 	}
-	writeJSON(w, http.StatusOK, resp)
+	writeJSONWithETag(w, r, http.StatusOK, resp)
 }
 
+// GetProblem returns a problem, with its description as markdown or, given
+// format=html, rendered. Responses carry an ETag like ListProblems.
+// Unpublished problems are only shown to the callers AuthorizeView lets
+// see them; everyone else gets a 404.
 func (h *ProblemHandler) GetProblem(w http.ResponseWriter, r *http.Request) {
 	id, err := parseProblemID(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeErrorFrom(w, http.StatusBadRequest, err)
 		return
 	}
 
 	problem, err := h.problemService.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			writeError(w, http.StatusNotFound, "problem not found")
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
 		return
 	}
+	if !problem.Published {
+		if err := h.authorizeView(r, problem); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+			return
+		}
+	}
+	problem.LanguageLimits = services.LanguageLimits(problem)
 
-	writeJSON(w, http.StatusOK, problem)
+	switch r.URL.Query().Get("format") {
+	case "", "markdown":
+		writeJSONWithETag(w, r, http.StatusOK, problem)
+	case "html":
+		rendered, err := markdown.Render(problem.Description)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to render description")
+			return
+		}
+		problem.Description = rendered
+		writeJSONWithETag(w, r, http.StatusOK, ProblemDetailResponse{Problem: problem, DescriptionFormat: "html"})
+	default:
+		writeError(w, http.StatusBadRequest, "invalid format")
+	}
 }
 
+// authorizeView returns store.ErrNotFound unless the caller, if any, may
+// see the problem.
+func (h *ProblemHandler) authorizeView(r *http.Request, problem types.Problem) error {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		return h.problemService.AuthorizeView(r.Context(), types.User{}, problem)
+	}
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	return h.problemService.AuthorizeView(r.Context(), user, problem)
+}
+
+// CreateProblem creates a problem owned by the caller. A multipart form
+// must carry the testcase bundle, which is verified in the background; poll
+// GET /problems/{id}/bundle-status for the outcome. A JSON body carries
+// only the statement and metadata; the bundle is sent later to
+// PUT /problems/{id}/bundle.
 func (h *ProblemHandler) CreateProblem(w http.ResponseWriter, r *http.Request) {
-	req, err := parseProblemForm(r)
+	userID, err := userIDFromContext(r.Context())
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	tcBundle, err := h.problemService.GetTestcaseBundleFromArchive(req.Bundle.Filename, req.Bundle.Data, req.TestcaseGroups)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		h.createProblemFromJSON(w, r, userID)
+		return
+	}
+
+	req, err := parseProblemForm(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeRequestError(w, err)
+		return
+	}
+	if err := services.ValidateBundleUpload(req.Bundle.Filename, req.TestcaseGroups); err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
 		return
 	}
 
 	problem := types.Problem{
-		Title:          req.Title,
-		Description:    req.Description,
-		Difficulty:     req.Difficulty,
-		TimeLimit:      req.TimeLimit,
-		MemoryLimit:    req.MemoryLimit,
-		Tags:           req.Tags,
-		TestcaseBundle: tcBundle,
+		Title:       req.Title,
+		Description: req.Description,
+		Type:        req.Type,
+		Difficulty:  req.Difficulty,
+		TimeLimit:   req.TimeLimit,
+		MemoryLimit: req.MemoryLimit,
+		Tags:        req.Tags,
+		OwnerID:     userID,
 	}
 
 	created, err := h.problemService.Create(r.Context(), problem)
@@ -151,31 +265,44 @@ func (h *ProblemHandler) CreateProblem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, created)
+	upload, err := h.submitBundle(r, userID, created.ID, req)
+	if err != nil {
+		// A problem is unusable without its bundle, so it is not kept.
+		_ = h.problemService.Delete(r.Context(), created.ID)
+		writeError(w, http.StatusInternalServerError, "failed to queue testcase bundle")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, ProblemSaveResponse{
+		Problem:       created,
+		SuggestedTags: h.suggestTags(r, created),
+		BundleUpload:  &upload,
+	})
 }
 
+// UpdateProblem updates a problem. A bundle sent with it is processed in
+// the background like one sent to CreateProblem.
 func (h *ProblemHandler) UpdateProblem(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
 	id, err := parseProblemID(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeErrorFrom(w, http.StatusBadRequest, err)
 		return
 	}
 
 	req, err := parseProblemForm(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeRequestError(w, err)
 		return
 	}
-
-	// Update testcase bundle if provided.
 	if req.Bundle.Data != nil {
-		tcBundle, err := h.problemService.GetTestcaseBundleFromArchive(req.Bundle.Filename, req.Bundle.Data, req.TestcaseGroups)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-		if err := h.problemService.UpdateTestcaseBundle(r.Context(), id, tcBundle); err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to update testcase bundle")
+		if err := services.ValidateBundleUpload(req.Bundle.Filename, req.TestcaseGroups); err != nil {
+			writeErrorFrom(w, http.StatusBadRequest, err)
 			return
 		}
 	}
@@ -184,6 +311,7 @@ func (h *ProblemHandler) UpdateProblem(w http.ResponseWriter, r *http.Request) {
 		ID:          id,
 		Title:       req.Title,
 		Description: req.Description,
+		Type:        req.Type,
 		Difficulty:  req.Difficulty,
 		TimeLimit:   req.TimeLimit,
 		MemoryLimit: req.MemoryLimit,
@@ -191,26 +319,281 @@ func (h *ProblemHandler) UpdateProblem(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			writeError(w, http.StatusNotFound, "problem not found")
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to update problem")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, updated)
+	// Update testcase bundle if provided. It is queued after the update so
+	// that it is processed against the problem's new type.
+	var upload *types.BundleUpload
+	if req.Bundle.Data != nil {
+		submitted, err := h.submitBundle(r, userID, id, req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to queue testcase bundle")
+			return
+		}
+		upload = &submitted
+	}
+
+	writeJSON(w, http.StatusOK, ProblemSaveResponse{
+		Problem:       updated,
+		SuggestedTags: h.suggestTags(r, updated),
+		BundleUpload:  upload,
+	})
+}
+
+func (h *ProblemHandler) createProblemFromJSON(w http.ResponseWriter, r *http.Request, userID int) {
+	var req ProblemCreateRequest
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	problem, err := req.problem()
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	problem.OwnerID = userID
+
+	created, err := h.problemService.Create(r.Context(), problem)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create problem")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ProblemSaveResponse{
+		Problem:       created,
+		SuggestedTags: h.suggestTags(r, created),
+	})
+}
+
+// UploadBundle replaces a problem's testcase bundle with the request body,
+// a gzipped tarball. The optional ?filename= names it (default
+// bundle.tar.gz) and ?testcase_groups= carries the group configuration as
+// JSON. The bundle is processed in the background like one sent with
+// CreateProblem.
+func (h *ProblemHandler) UploadBundle(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	req := ProblemUpsertRequest{Bundle: BundleFile{Filename: strings.TrimSpace(query.Get("filename"))}}
+	if req.Bundle.Filename == "" {
+		req.Bundle.Filename = "bundle.tar.gz"
+	}
+	if rawGroups := strings.TrimSpace(query.Get(formFieldGroups)); rawGroups != "" {
+		if err := json.Unmarshal([]byte(rawGroups), &req.TestcaseGroups); err != nil {
+			var v validator
+			v.add(formFieldGroups, "must be a JSON array of testcase groups", constraintJSON)
+			writeRequestError(w, v.err())
+			return
+		}
+	}
+	if err := services.ValidateBundleUpload(req.Bundle.Filename, req.TestcaseGroups); err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Bundle.Data, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "bundle too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read bundle")
+		return
+	}
+	if len(req.Bundle.Data) == 0 {
+		writeError(w, http.StatusBadRequest, "bundle is required")
+		return
+	}
+
+	if _, err := h.problemService.Get(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+		return
+	}
+
+	upload, err := h.submitBundle(r, userID, id, req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to queue testcase bundle")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, upload)
+}
+
+// ListSubmissions returns a page of submissions to the problem, newest
+// first. With ?mine=true only the caller's own attempts are listed;
+// listing everyone's submissions is reserved for admins.
+func (h *ProblemHandler) ListSubmissions(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	mine := false
+	if raw := r.URL.Query().Get("mine"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid mine")
+			return
+		}
+		mine = parsed
+	}
+
+	ownerID := userID
+	if !mine {
+		user, err := h.userService.GetByID(r.Context(), userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		if !strings.EqualFold(user.Role, adminRole) {
+			writeError(w, http.StatusForbidden, "only admins may list all submissions; use mine=true")
+			return
+		}
+		ownerID = 0
+	}
+
+	if _, err := h.problemService.Get(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+		return
+	}
+
+	items, total, err := h.submissionService.ListByProblem(r.Context(), id, ownerID, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list submissions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SubmissionListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// PatchProblem applies a JSON partial update: only the fields present in
+// the body change, and no bundle is involved. Publishing follows the same
+// rules as the review workflow's publish endpoint; if they refuse it, no
+// field changes either.
+func (h *ProblemHandler) PatchProblem(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var patch types.ProblemPatch
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	normalizeProblemPatch(&patch)
+	if err := validateProblemPatch(patch); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	updated, err := h.problemService.Patch(r.Context(), id, patch)
+	if err != nil {
+		writeReviewError(w, err, "failed to update problem")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ProblemSaveResponse{
+		Problem:       updated,
+		SuggestedTags: h.suggestTags(r, updated),
+	})
+}
+
+// normalizeProblemPatch trims text fields the way parseProblemForm does.
+func normalizeProblemPatch(patch *types.ProblemPatch) {
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		patch.Title = &title
+	}
+	if patch.Description != nil {
+		description := strings.TrimSpace(*patch.Description)
+		patch.Description = &description
+	}
+	if patch.Tags != nil {
+		tags := parseTags(strings.Join(*patch.Tags, ","))
+		patch.Tags = &tags
+	}
+}
+
+func validateProblemPatch(patch types.ProblemPatch) error {
+	var v validator
+	if patch.Title != nil {
+		v.required(formFieldTitle, *patch.Title)
+	}
+	if patch.Description != nil {
+		v.required(formFieldDesc, *patch.Description)
+	}
+	if patch.Difficulty != nil && *patch.Difficulty < 0 {
+		v.add(formFieldDifficulty, "must not be negative", constraintPositive)
+	}
+	if patch.TimeLimit != nil && *patch.TimeLimit < 1 {
+		v.add(formFieldTimeLimit, "must be positive", constraintPositive)
+	}
+	if patch.MemoryLimit != nil && *patch.MemoryLimit < 1 {
+		v.add(formFieldMemLimit, "must be positive", constraintPositive)
+	}
+	if patch.SolutionVisibility != nil {
+		switch *patch.SolutionVisibility {
+		case types.SolutionsNever, types.SolutionsAfterSolving:
+		default:
+			v.add(formFieldSolutions, fmt.Sprintf("must be one of %s, %s", types.SolutionsNever, types.SolutionsAfterSolving), constraintOneOf)
+		}
+	}
+	return v.err()
 }
 
 func (h *ProblemHandler) DeleteProblem(w http.ResponseWriter, r *http.Request) {
 	id, err := parseProblemID(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeErrorFrom(w, http.StatusBadRequest, err)
 		return
 	}
 
 	if err := h.problemService.Delete(r.Context(), id); err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			writeError(w, http.StatusNotFound, "problem not found")
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "failed to delete problem")
@@ -220,10 +603,126 @@ func (h *ProblemHandler) DeleteProblem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *ProblemHandler) GetTagSuggestions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	problem, err := h.problemService.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+		return
+	}
+
+	suggestions, err := h.problemService.SuggestTags(r.Context(), problem)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to suggest tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TagSuggestionsResponse{SuggestedTags: suggestions})
+}
+
+// GetValidation lists the results of the input validator and reference
+// solutions shipped with the problem's latest testcase bundle, including
+// each input the validator rejected.
+func (h *ProblemHandler) GetValidation(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	problem, err := h.problemService.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+		return
+	}
+
+	validations, err := h.validationService.List(r.Context(), id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusInternalServerError, "failed to load validation results")
+		return
+	}
+	if validations == nil {
+		validations = []types.SolutionValidation{}
+	}
+
+	resp := ProblemValidationResponse{
+		Status:    problem.ValidationStatus,
+		Solutions: validations,
+	}
+	inputs, err := h.validationService.Inputs(r.Context(), id)
+	switch {
+	case err == nil:
+		if inputs.Errors == nil {
+			inputs.Errors = []types.InputError{}
+		}
+		resp.Inputs = &inputs
+	case !errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusInternalServerError, "failed to load validation results")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetBundleStatus reports the processing status of the problem's latest
+// uploaded bundle, including why it was rejected.
+func (h *ProblemHandler) GetBundleStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	upload, err := h.uploadService.LatestForProblem(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeBundleUploadNotFound, "no bundle upload found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch bundle status")
+		return
+	}
+	writeJSON(w, http.StatusOK, upload)
+}
+
+// submitBundle queues the bundle of a problem form for processing.
+func (h *ProblemHandler) submitBundle(r *http.Request, userID, problemID int, req ProblemUpsertRequest) (types.BundleUpload, error) {
+	return h.uploadService.Submit(r.Context(), types.BundleUpload{
+		ProblemID:      problemID,
+		UserID:         userID,
+		Filename:       req.Bundle.Filename,
+		TestcaseGroups: req.TestcaseGroups,
+	}, bytes.NewReader(req.Bundle.Data), int64(len(req.Bundle.Data)))
+}
+
+// suggestTags computes tag suggestions for a saved problem. Suggestions are
+// best-effort and never fail the save itself.
+func (h *ProblemHandler) suggestTags(r *http.Request, problem types.Problem) []services.TagSuggestion {
+	suggestions, err := h.problemService.SuggestTags(r.Context(), problem)
+	if err != nil || suggestions == nil {
+		return []services.TagSuggestion{}
+	}
+	return suggestions
+}
+
 // ProblemUpsertRequest represents the parsed multipart form payload.
 type ProblemUpsertRequest struct {
 	Title          string
 	Description    string
+	Type           types.ProblemType
 	Difficulty     int
 	TimeLimit      int64
 	MemoryLimit    int64
@@ -232,17 +731,105 @@ type ProblemUpsertRequest struct {
 	Bundle         BundleFile
 }
 
+// ProblemCreateRequest is the JSON payload for creating a problem without
+// its testcase bundle.
+type ProblemCreateRequest struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Type        types.ProblemType `json:"type"`
+	Difficulty  int               `json:"difficulty"`
+	TimeLimit   int64             `json:"time_limit"`
+	MemoryLimit int64             `json:"memory_limit"`
+	Tags        []string          `json:"tags"`
+}
+
+// problem validates the request and converts it to a problem.
+func (req ProblemCreateRequest) problem() (types.Problem, error) {
+	var v validator
+	title := strings.TrimSpace(req.Title)
+	v.required(formFieldTitle, title)
+	description := strings.TrimSpace(req.Description)
+	v.required(formFieldDesc, description)
+
+	problemType := req.Type
+	switch problemType {
+	case "":
+		problemType = types.ProblemTypeBatch
+	case types.ProblemTypeBatch, types.ProblemTypeOutputOnly, types.ProblemTypeGrader:
+	default:
+		v.add(formFieldType, fmt.Sprintf("must be one of %s, %s, %s", types.ProblemTypeBatch, types.ProblemTypeOutputOnly, types.ProblemTypeGrader), constraintOneOf)
+	}
+	if req.Difficulty < 0 {
+		v.add(formFieldDifficulty, "must not be negative", constraintPositive)
+	}
+	if req.TimeLimit < 0 {
+		v.add(formFieldTimeLimit, "must not be negative", constraintPositive)
+	}
+	if req.MemoryLimit < 0 {
+		v.add(formFieldMemLimit, "must not be negative", constraintPositive)
+	}
+	if err := v.err(); err != nil {
+		return types.Problem{}, err
+	}
+
+	return types.Problem{
+		Title:       title,
+		Description: description,
+		Type:        problemType,
+		Difficulty:  req.Difficulty,
+		TimeLimit:   req.TimeLimit,
+		MemoryLimit: req.MemoryLimit,
+		Tags:        parseTags(strings.Join(req.Tags, ",")),
+	}, nil
+}
+
+// SubmissionListResponse is a page of submission summaries.
+type SubmissionListResponse struct {
+	Items []types.Submission `json:"items"`
+	Pagination
+}
+
+// ProblemValidationResponse reports a problem's validation status together
+// with the input validation and the result of each reference solution.
+type ProblemValidationResponse struct {
+	Status    types.ValidationStatus     `json:"status"`
+	Inputs    *types.InputValidation     `json:"inputs,omitempty"`
+	Solutions []types.SolutionValidation `json:"solutions"`
+}
+
 // ProblemListResponse is the paginated list response payload.
 type ProblemListResponse struct {
-	Items []types.Problem `json:"items"`
-	Page  int             `json:"page"`
-	Limit int             `json:"limit"`
-	Total int             `json:"total"`
+	Items []types.ProblemSummary `json:"items"`
+	Pagination
+}
+
+// ProblemSaveResponse is returned after creating or updating a problem and
+// carries tag suggestions the setter may accept, along with the upload
+// processing the bundle sent with it.
+type ProblemSaveResponse struct {
+	types.Problem
+	SuggestedTags []services.TagSuggestion `json:"suggested_tags"`
+	BundleUpload  *types.BundleUpload      `json:"bundle_upload,omitempty"`
+}
+
+// ProblemDetailResponse is a problem whose description has been rendered.
+// Math segments are kept with their delimiters inside
+// <span class="math math-inline|math-display"> for client-side KaTeX.
+type ProblemDetailResponse struct {
+	types.Problem
+	DescriptionFormat string `json:"description_format"`
 }
 
-// ErrorResponse is a simple error payload.
+// TagSuggestionsResponse is the tag suggestions payload.
+type TagSuggestionsResponse struct {
+	SuggestedTags []services.TagSuggestion `json:"suggested_tags"`
+}
+
+// ErrorResponse is a simple error payload. Code identifies the error for
+// programmatic handling; Error is meant for humans and may change.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code"`
 }
 
 func parsePagination(r *http.Request) (page, limit, offset int, err error) {
@@ -289,29 +876,35 @@ func parseProblemForm(r *http.Request) (ProblemUpsertRequest, error) {
 		return ProblemUpsertRequest{}, errors.New("invalid multipart form")
 	}
 
+	var v validator
 	title := strings.TrimSpace(r.FormValue(formFieldTitle))
-	if title == "" {
-		return ProblemUpsertRequest{}, errors.New("title is required")
-	}
+	v.required(formFieldTitle, title)
 
 	description := strings.TrimSpace(r.FormValue(formFieldDesc))
-	if description == "" {
-		return ProblemUpsertRequest{}, errors.New("description is required")
-	}
+	v.required(formFieldDesc, description)
 
 	difficulty, err := parseOptionalInt(r.FormValue(formFieldDifficulty))
 	if err != nil {
-		return ProblemUpsertRequest{}, errors.New("invalid difficulty")
+		v.add(formFieldDifficulty, "must be an integer", constraintInteger)
 	}
 
 	timeLimit, err := parseOptionalInt64(r.FormValue(formFieldTimeLimit))
 	if err != nil {
-		return ProblemUpsertRequest{}, errors.New("invalid time limit")
+		v.add(formFieldTimeLimit, "must be an integer number of milliseconds", constraintInteger)
 	}
 
 	memoryLimit, err := parseOptionalInt64(r.FormValue(formFieldMemLimit))
 	if err != nil {
-		return ProblemUpsertRequest{}, errors.New("invalid memory limit")
+		v.add(formFieldMemLimit, "must be an integer number of bytes", constraintInteger)
+	}
+
+	problemType := types.ProblemType(strings.TrimSpace(r.FormValue(formFieldType)))
+	switch problemType {
+	case "":
+		problemType = types.ProblemTypeBatch
+	case types.ProblemTypeBatch, types.ProblemTypeOutputOnly, types.ProblemTypeGrader:
+	default:
+		v.add(formFieldType, fmt.Sprintf("must be one of %s, %s, %s", types.ProblemTypeBatch, types.ProblemTypeOutputOnly, types.ProblemTypeGrader), constraintOneOf)
 	}
 
 	tags := parseTags(r.FormValue(formFieldTags))
@@ -319,18 +912,24 @@ func parseProblemForm(r *http.Request) (ProblemUpsertRequest, error) {
 	var tcGroups []types.TestcaseGroup
 	if rawGroups := strings.TrimSpace(r.FormValue(formFieldGroups)); rawGroups != "" {
 		if err := json.Unmarshal([]byte(rawGroups), &tcGroups); err != nil {
-			return ProblemUpsertRequest{}, errors.New("invalid testcase groups")
+			v.add(formFieldGroups, "must be a JSON array of testcase groups", constraintJSON)
 		}
 	}
 
-	bundle, err := parseBundleFile(r.MultipartForm)
-	if err != nil {
-		return ProblemUpsertRequest{}, err
+	var bundle BundleFile
+	if len(r.MultipartForm.File[formFieldBundle]) == 0 {
+		v.add(formFieldBundle, "file is required", constraintRequired)
+	} else if bundle, err = parseBundleFile(r.MultipartForm); err != nil {
+		v.add(formFieldBundle, err.Error(), constraintFile)
 	}
 
+	if err := v.err(); err != nil {
+		return ProblemUpsertRequest{}, err
+	}
 	return ProblemUpsertRequest{
 		Title:          title,
 		Description:    description,
+		Type:           problemType,
 		Difficulty:     difficulty,
 		TimeLimit:      timeLimit,
 		MemoryLimit:    memoryLimit,
@@ -415,28 +1014,61 @@ func readFileLimited(reader io.Reader, limit int64) ([]byte, error) {
 	return data, nil
 }
 
-func (h *ProblemHandler) requireAdmin(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := userIDFromContext(r.Context())
-		if err != nil {
-			writeError(w, http.StatusUnauthorized, "unauthorized")
-			return
-		}
+func (h *ProblemHandler) requireSetter(next http.Handler) http.Handler {
+	return requireSetter(h.userService)(next)
+}
 
-		user, err := h.userService.GetByID(r.Context(), userID)
+// requireEditor rejects requests from users who may not edit the problem in
+// the URL: admins may edit every problem, setters only those they own. It
+// must run after the auth middleware.
+func (h *ProblemHandler) requireEditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problemID, err := parseProblemID(r)
 		if err != nil {
-			if errors.Is(err, store.ErrNotFound) {
-				writeError(w, http.StatusUnauthorized, "unauthorized")
-				return
-			}
-			writeError(w, http.StatusInternalServerError, "failed to load user")
+			writeErrorFrom(w, http.StatusBadRequest, err)
 			return
 		}
-
-		if !strings.EqualFold(user.Role, adminRole) {
-			writeError(w, http.StatusForbidden, "admin access required")
+		if !authorizeProblemEdit(w, r, h.userService, h.problemService, problemID) {
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authorizeProblemEdit checks that the authenticated user may edit a
+// problem, writing an error response and returning false if not.
+func authorizeProblemEdit(
+	w http.ResponseWriter,
+	r *http.Request,
+	userService *services.UserService,
+	problemService *services.ProblemService,
+	problemID int,
+) bool {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+	user, err := userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return false
+	}
+
+	if err := problemService.AuthorizeEdit(r.Context(), user, problemID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+		case errors.Is(err, services.ErrProblemForbidden):
+			writeErrorFrom(w, http.StatusForbidden, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to authorize request")
+		}
+		return false
+	}
+	return true
+}
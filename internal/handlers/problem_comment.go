@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemCommentHandler provides HTTP handlers for problem discussions.
+type ProblemCommentHandler struct {
+	commentService *services.ProblemCommentService
+	userService    *services.UserService
+}
+
+// NewProblemCommentHandler constructs a ProblemCommentHandler with the
+// provided services.
+func NewProblemCommentHandler(commentService *services.ProblemCommentService, userService *services.UserService) *ProblemCommentHandler {
+	return &ProblemCommentHandler{
+		commentService: commentService,
+		userService:    userService,
+	}
+}
+
+// problemCommentRoutes registers discussion routes under
+// /problems/{problemID}. Anyone who can see the problem may read the
+// threads; posting, editing and moderating need a signed-in user.
+func problemCommentRoutes(r chi.Router, handler *ProblemCommentHandler, authMiddleware func(http.Handler) http.Handler) {
+	r.Route("/comments", func(r chi.Router) {
+		r.With(optionalAuth(authMiddleware)).Get("/", handler.ListComments)
+		r.Group(func(r chi.Router) {
+			if authMiddleware != nil {
+				r.Use(authMiddleware)
+			}
+			r.Post("/", handler.CreateComment)
+			r.Patch("/{commentID}", handler.EditComment)
+			r.Delete("/{commentID}", handler.DeleteComment)
+			r.Post("/{commentID}/hide", handler.HideComment)
+			r.Post("/{commentID}/unhide", handler.UnhideComment)
+		})
+	})
+}
+
+// ListComments returns a page of the problem's threads, oldest first,
+// each with its replies. Spoilers are redacted until the caller has solved
+// the problem.
+func (h *ProblemCommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var viewer *types.User
+	if _, err := userIDFromContext(r.Context()); err == nil {
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		viewer = &user
+	}
+
+	threads, total, err := h.commentService.List(r.Context(), viewer, problemID, offset, limit)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeCommentError(w, err, "failed to list comments")
+		return
+	}
+	writeJSON(w, http.StatusOK, ProblemCommentListResponse{
+		Items:      threads,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// CreateComment starts a thread or, with parent_id, replies in one.
+func (h *ProblemCommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ProblemCommentRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxProblemCommentBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	comment, err := h.commentService.Create(r.Context(), user, types.ProblemComment{
+		ProblemID: problemID,
+		ParentID:  req.ParentID,
+		Body:      req.Body,
+		Spoiler:   req.Spoiler,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) && req.ParentID == nil {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeCommentError(w, err, "failed to post comment")
+		return
+	}
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// EditComment replaces the body and spoiler flag of one of the caller's
+// comments while it can still be edited.
+func (h *ProblemCommentHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	problemID, commentID, err := parseCommentPath(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ProblemCommentRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxProblemCommentBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	comment, err := h.commentService.Edit(r.Context(), user, problemID, commentID, req.Body, req.Spoiler)
+	if err != nil {
+		writeCommentError(w, err, "failed to edit comment")
+		return
+	}
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// DeleteComment erases one of the caller's comments, or any comment for
+// admins.
+func (h *ProblemCommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	problemID, commentID, err := parseCommentPath(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.commentService.Delete(r.Context(), user, problemID, commentID); err != nil {
+		writeCommentError(w, err, "failed to delete comment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HideComment hides a comment from other users. Admins only.
+func (h *ProblemCommentHandler) HideComment(w http.ResponseWriter, r *http.Request) {
+	h.setHidden(w, r, true)
+}
+
+// UnhideComment shows a hidden comment again. Admins only.
+func (h *ProblemCommentHandler) UnhideComment(w http.ResponseWriter, r *http.Request) {
+	h.setHidden(w, r, false)
+}
+
+func (h *ProblemCommentHandler) setHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	problemID, commentID, err := parseCommentPath(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := h.commentService.SetHidden(r.Context(), user, problemID, commentID, hidden)
+	if err != nil {
+		writeCommentError(w, err, "failed to moderate comment")
+		return
+	}
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (h *ProblemCommentHandler) currentUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return types.User{}, false
+	}
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+// ProblemCommentRequest is the payload for posting or editing a comment.
+// ParentID is ignored on edits.
+type ProblemCommentRequest struct {
+	ParentID *int64 `json:"parent_id"`
+	Body     string `json:"body"`
+	Spoiler  bool   `json:"spoiler"`
+}
+
+// ProblemCommentListResponse is a page of a problem's threads.
+type ProblemCommentListResponse struct {
+	Items []types.ProblemComment `json:"items"`
+	Pagination
+}
+
+func parseCommentPath(r *http.Request) (int, int64, error) {
+	problemID, err := parseProblemID(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil || commentID < 1 {
+		return 0, 0, errors.New("invalid comment id")
+	}
+	return problemID, commentID, nil
+}
+
+func writeCommentError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, CodeCommentNotFound, "comment not found")
+	case errors.Is(err, services.ErrInvalidProblemComment):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrProblemCommentForbidden):
+		writeErrorFrom(w, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrProblemCommentEditWindow):
+		writeErrorFrom(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
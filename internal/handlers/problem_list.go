@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemListHandler provides HTTP handlers for problem lists.
+type ProblemListHandler struct {
+	listService *services.ProblemListService
+	userService *services.UserService
+}
+
+// NewProblemListHandler constructs a ProblemListHandler with the provided
+// services.
+func NewProblemListHandler(listService *services.ProblemListService, userService *services.UserService) *ProblemListHandler {
+	return &ProblemListHandler{
+		listService: listService,
+		userService: userService,
+	}
+}
+
+// ProblemListRouter registers problem list routes on the given router.
+// Anyone may read lists, with their own progress when signed in; admins
+// create and edit them.
+func ProblemListRouter(
+	r chi.Router,
+	listService *services.ProblemListService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewProblemListHandler(listService, userService)
+	admin := requireAdmin(userService)
+
+	r.With(optionalAuth(authMiddleware)).Get("/", handler.ListProblemLists)
+	r.With(authMiddleware, admin).Post("/", handler.CreateProblemList)
+	r.Route("/{listID}", func(r chi.Router) {
+		r.With(optionalAuth(authMiddleware)).Get("/", handler.GetProblemList)
+		r.With(authMiddleware, admin).Put("/", handler.UpdateProblemList)
+		r.With(authMiddleware, admin).Delete("/", handler.DeleteProblemList)
+	})
+}
+
+// ListProblemLists returns a page of lists, newest first, each with how
+// many of its problems the caller solved.
+func (h *ProblemListHandler) ListProblemLists(w http.ResponseWriter, r *http.Request) {
+	page, limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, admin, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+
+	items, total, err := h.listService.List(r.Context(), userID, admin, offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list problem lists")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ProblemListListResponse{
+		Items:      items,
+		Pagination: paginate(w, r, page, limit, total),
+	})
+}
+
+// GetProblemList returns a list with its problems in order, each marked
+// with whether the caller solved or attempted it.
+func (h *ProblemListHandler) GetProblemList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemListID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, admin, ok := h.viewer(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.listService.Get(r.Context(), id, userID, admin)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemListNotFound, "problem list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem list")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *ProblemListHandler) CreateProblemList(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req ProblemListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	created, err := h.listService.Create(r.Context(), types.ProblemList{
+		Title:       req.Title,
+		Description: req.Description,
+		OwnerID:     userID,
+	}, req.ProblemIDs)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidProblemList) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create problem list")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdateProblemList replaces a list's title, description and problems.
+func (h *ProblemListHandler) UpdateProblemList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemListID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req ProblemListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	updated, err := h.listService.Update(r.Context(), types.ProblemList{
+		ID:          id,
+		Title:       req.Title,
+		Description: req.Description,
+	}, req.ProblemIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidProblemList):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeProblemListNotFound, "problem list not found")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to update problem list")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *ProblemListHandler) DeleteProblemList(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemListID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.listService.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemListNotFound, "problem list not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to delete problem list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// viewer returns the caller's user ID, zero when anonymous, and whether
+// they are an admin and so see unpublished problems on lists.
+func (h *ProblemListHandler) viewer(w http.ResponseWriter, r *http.Request) (int, bool, bool) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		return 0, false, true
+	}
+	admin, err := callerIsAdmin(r, h.userService)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return 0, false, false
+	}
+	return userID, admin, true
+}
+
+// ProblemListRequest is the JSON payload for creating or replacing a
+// problem list. ProblemIDs are the problems in order.
+type ProblemListRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ProblemIDs  []int  `json:"problem_ids"`
+}
+
+// ProblemListListResponse is the paginated list response payload.
+type ProblemListListResponse struct {
+	Items []types.ProblemList `json:"items"`
+	Pagination
+}
+
+func parseProblemListID(r *http.Request) (int, error) {
+	raw := chi.URLParam(r, "listID")
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid problem list id")
+	}
+	return id, nil
+}
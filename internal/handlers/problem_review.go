@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemReviewHandler provides HTTP handlers for the problem review
+// workflow.
+type ProblemReviewHandler struct {
+	reviewService  *services.ProblemReviewService
+	problemService *services.ProblemService
+	userService    *services.UserService
+}
+
+// NewProblemReviewHandler constructs a ProblemReviewHandler with the
+// provided services.
+func NewProblemReviewHandler(
+	reviewService *services.ProblemReviewService,
+	problemService *services.ProblemService,
+	userService *services.UserService,
+) *ProblemReviewHandler {
+	return &ProblemReviewHandler{
+		reviewService:  reviewService,
+		problemService: problemService,
+		userService:    userService,
+	}
+}
+
+// problemReviewRoutes registers review routes under /problems/{problemID}.
+// The problem's editors submit it for review, reviewers decide on it, both
+// may comment, and editors publish it once approved.
+func problemReviewRoutes(r chi.Router, handler *ProblemReviewHandler, requireEditor func(http.Handler) http.Handler) {
+	r.Route("/reviews", func(r chi.Router) {
+		r.With(handler.requireParticipant).Get("/", handler.GetReview)
+		r.With(requireEditor).Post("/submit", handler.SubmitForReview)
+		r.Post("/decisions", handler.Decide)
+		r.With(handler.requireParticipant).Post("/comments", handler.Comment)
+	})
+	r.With(requireEditor).Post("/publish", handler.Publish)
+	r.With(requireEditor).Post("/unpublish", handler.Unpublish)
+}
+
+func (h *ProblemReviewHandler) GetReview(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	review, err := h.reviewService.Get(r.Context(), id)
+	if err != nil {
+		writeReviewError(w, err, "failed to load review")
+		return
+	}
+	writeJSON(w, http.StatusOK, review)
+}
+
+// SubmitForReview puts the problem up for review.
+func (h *ProblemReviewHandler) SubmitForReview(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.reviewService.Submit(r.Context(), id); err != nil {
+		writeReviewError(w, err, "failed to submit problem for review")
+		return
+	}
+	h.GetReview(w, r)
+}
+
+// Decide approves the problem or requests changes to it.
+func (h *ProblemReviewHandler) Decide(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReviewDecisionRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxReviewCommentBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	decision, err := h.reviewService.Decide(r.Context(), user, types.ReviewDecision{
+		ProblemID: id,
+		Decision:  req.Decision,
+		Comment:   req.Comment,
+	})
+	if err != nil {
+		writeReviewError(w, err, "failed to record review decision")
+		return
+	}
+	writeJSON(w, http.StatusCreated, decision)
+}
+
+// Comment adds a comment to the review discussion.
+func (h *ProblemReviewHandler) Comment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req ReviewCommentRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, services.MaxReviewCommentBytes+1<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	comment, err := h.reviewService.Comment(r.Context(), types.ReviewComment{
+		ProblemID: id,
+		ParentID:  req.ParentID,
+		UserID:    userID,
+		Target:    req.Target,
+		Body:      req.Body,
+	})
+	if err != nil {
+		writeReviewError(w, err, "failed to add review comment")
+		return
+	}
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// Publish lists an approved problem and opens it to submissions.
+func (h *ProblemReviewHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	h.setPublished(w, r, true)
+}
+
+// Unpublish hides a problem again.
+func (h *ProblemReviewHandler) Unpublish(w http.ResponseWriter, r *http.Request) {
+	h.setPublished(w, r, false)
+}
+
+func (h *ProblemReviewHandler) setPublished(w http.ResponseWriter, r *http.Request, published bool) {
+	id, err := parseProblemID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.reviewService.SetPublished(r.Context(), id, published); err != nil {
+		writeReviewError(w, err, "failed to update problem")
+		return
+	}
+	h.GetReview(w, r)
+}
+
+// requireParticipant lets reviewers and the problem's editors through.
+func (h *ProblemReviewHandler) requireParticipant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problemID, err := parseProblemID(r)
+		if err != nil {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		if !services.CanReview(user) && !authorizeProblemEdit(w, r, h.userService, h.problemService, problemID) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ProblemReviewHandler) currentUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return types.User{}, false
+	}
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+// ReviewDecisionRequest is the payload for deciding on a problem.
+type ReviewDecisionRequest struct {
+	Decision types.ReviewStatus `json:"decision"`
+	Comment  string             `json:"comment"`
+}
+
+// ReviewCommentRequest is the payload for commenting on a problem.
+type ReviewCommentRequest struct {
+	ParentID *int64             `json:"parent_id"`
+	Target   types.ReviewTarget `json:"target"`
+	Body     string             `json:"body"`
+}
+
+func writeReviewError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+	case errors.Is(err, services.ErrInvalidReview):
+		writeErrorFrom(w, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrReviewForbidden):
+		writeErrorFrom(w, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrReviewTransition):
+		writeErrorFrom(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
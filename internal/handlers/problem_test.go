@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeProblemRepo struct {
+	services.ProblemRepository
+	problems map[int]types.Problem
+}
+
+func (r *fakeProblemRepo) Get(_ context.Context, id int) (types.Problem, error) {
+	problem, ok := r.problems[id]
+	if !ok {
+		return types.Problem{}, store.ErrNotFound
+	}
+	return problem, nil
+}
+
+// Patch matches the store: changing the statement of an approved problem
+// sends it back to draft, and publishing a problem that is not approved,
+// or along with such a change, matches nothing and changes nothing.
+func (r *fakeProblemRepo) Patch(_ context.Context, id int, patch types.ProblemPatch) (types.Problem, error) {
+	problem, ok := r.problems[id]
+	if !ok {
+		return types.Problem{}, store.ErrNotFound
+	}
+	changesReview := patch.Title != nil && *patch.Title != problem.Title ||
+		patch.Description != nil && *patch.Description != problem.Description
+	if patch.Published != nil && *patch.Published && (problem.ReviewStatus != types.ReviewApproved || changesReview) {
+		return types.Problem{}, store.ErrNotFound
+	}
+	if changesReview && problem.ReviewStatus == types.ReviewApproved {
+		problem.ReviewStatus = types.ReviewDraft
+	}
+	if patch.Title != nil {
+		problem.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		problem.Description = *patch.Description
+	}
+	if patch.Difficulty != nil {
+		problem.Difficulty = *patch.Difficulty
+	}
+	if patch.Tags != nil {
+		problem.Tags = *patch.Tags
+	}
+	if patch.Published != nil {
+		problem.Published = *patch.Published
+	}
+	r.problems[id] = problem
+	return problem, nil
+}
+
+func (r *fakeProblemRepo) ListTagged(context.Context, int) ([]types.Problem, error) {
+	return nil, nil
+}
+
+func (r *fakeProblemRepo) ListCoSolved(context.Context, int, int, int) ([]store.CoSolvedProblem, error) {
+	return nil, nil
+}
+
+type fakeUserRepo struct {
+	services.UserRepository
+	users map[int]types.User
+}
+
+func (r *fakeUserRepo) GetByID(_ context.Context, id int) (types.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return types.User{}, store.ErrNotFound
+	}
+	return user, nil
+}
+
+// fakeAuth authenticates "Bearer <user id>" tokens.
+func fakeAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextSubjectKey, id)))
+	})
+}
+
+func TestGetProblemHidesDrafts(t *testing.T) {
+	problems := services.NewProblemService(&fakeProblemRepo{problems: map[int]types.Problem{
+		10: {ID: 10, Title: "draft", OwnerID: author},
+		11: {ID: 11, Title: "published", OwnerID: author, Published: true},
+	}}, nil)
+	users := services.NewUserService(&fakeUserRepo{users: map[int]types.User{
+		author:   {ID: author, Role: types.RoleSetter},
+		stranger: {ID: stranger, Role: types.RoleUser},
+		reviewer: {ID: reviewer, Role: types.RoleReviewer},
+		admin:    {ID: admin, Role: types.RoleAdmin},
+		setter:   {ID: setter, Role: types.RoleSetter},
+	}}, nil)
+
+	r := chi.NewRouter()
+	r.Route("/problems", func(r chi.Router) {
+		ProblemRouter(r, problems, nil, nil, nil, nil, nil, users, fakeAuth)
+	})
+
+	tests := []struct {
+		name    string
+		problem int
+		caller  int
+		want    int
+	}{
+		{"anonymous draft", 10, 0, http.StatusNotFound},
+		{"user draft", 10, stranger, http.StatusNotFound},
+		{"other setter draft", 10, setter, http.StatusNotFound},
+		{"author draft", 10, author, http.StatusOK},
+		{"reviewer draft", 10, reviewer, http.StatusOK},
+		{"admin draft", 10, admin, http.StatusOK},
+		{"anonymous published", 11, 0, http.StatusOK},
+		{"missing", 12, admin, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/problems/"+strconv.Itoa(tt.problem)+"/", nil)
+			if tt.caller != 0 {
+				req.Header.Set("Authorization", "Bearer "+strconv.Itoa(tt.caller))
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusNotFound && !strings.Contains(rec.Body.String(), string(CodeProblemNotFound)) {
+				t.Fatalf("body = %s, want code %s", rec.Body, CodeProblemNotFound)
+			}
+		})
+	}
+}
+
+func TestPatchProblem(t *testing.T) {
+	const approvedProblem = 12
+	draft := types.Problem{ID: draftProblem, Title: "draft", Description: "statement", Difficulty: 3, OwnerID: author, ReviewStatus: types.ReviewDraft}
+	approved := types.Problem{ID: approvedProblem, Title: "approved", Description: "statement", Difficulty: 3, OwnerID: author, ReviewStatus: types.ReviewApproved}
+
+	tests := []struct {
+		name    string
+		problem int
+		caller  int
+		body    string
+		want    int
+		// after is the stored problem after the request.
+		after types.Problem
+	}{
+		{
+			name: "title only", problem: draftProblem, caller: author,
+			body: `{"title": "  renamed  "}`, want: http.StatusOK,
+			after: types.Problem{ID: draftProblem, Title: "renamed", Description: "statement", Difficulty: 3, OwnerID: author, ReviewStatus: types.ReviewDraft},
+		},
+		{
+			name: "several fields", problem: draftProblem, caller: admin,
+			body: `{"description": "new statement", "difficulty": 5, "tags": ["dp", " graphs "]}`, want: http.StatusOK,
+			after: types.Problem{ID: draftProblem, Title: "draft", Description: "new statement", Difficulty: 5, Tags: []string{"dp", "graphs"}, OwnerID: author, ReviewStatus: types.ReviewDraft},
+		},
+		{
+			name: "publish approved", problem: approvedProblem, caller: author,
+			body: `{"difficulty": 4, "published": true}`, want: http.StatusOK,
+			after: types.Problem{ID: approvedProblem, Title: "approved", Description: "statement", Difficulty: 4, OwnerID: author, ReviewStatus: types.ReviewApproved, Published: true},
+		},
+		{
+			name: "approved statement", problem: approvedProblem, caller: author,
+			body: `{"description": "new statement"}`, want: http.StatusOK,
+			after: types.Problem{ID: approvedProblem, Title: "approved", Description: "new statement", Difficulty: 3, OwnerID: author, ReviewStatus: types.ReviewDraft},
+		},
+		{
+			name: "publish approved with a new statement", problem: approvedProblem, caller: author,
+			body: `{"description": "new statement", "published": true}`, want: http.StatusConflict,
+			after: approved,
+		},
+		{
+			name: "publish draft", problem: draftProblem, caller: author,
+			body: `{"title": "renamed", "published": true}`, want: http.StatusConflict,
+			after: draft,
+		},
+		{
+			name: "empty title", problem: draftProblem, caller: author,
+			body: `{"title": " ", "difficulty": 4}`, want: http.StatusBadRequest,
+			after: draft,
+		},
+		{
+			name: "unknown field", problem: draftProblem, caller: author,
+			body: `{"owner_id": 2}`, want: http.StatusBadRequest,
+			after: draft,
+		},
+		{
+			name: "not the owner", problem: draftProblem, caller: setter,
+			body: `{"title": "renamed"}`, want: http.StatusForbidden,
+			after: draft,
+		},
+		{
+			name: "missing", problem: 99, caller: admin,
+			body: `{"title": "renamed"}`, want: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeProblemRepo{problems: map[int]types.Problem{draftProblem: draft, approvedProblem: approved}}
+			users := services.NewUserService(&fakeUserRepo{users: map[int]types.User{
+				author: {ID: author, Role: types.RoleSetter},
+				admin:  {ID: admin, Role: types.RoleAdmin},
+				setter: {ID: setter, Role: types.RoleSetter},
+			}}, nil)
+			r := chi.NewRouter()
+			r.Route("/problems", func(r chi.Router) {
+				ProblemRouter(r, services.NewProblemService(repo, nil), nil, nil, nil, nil, nil, users, fakeAuth)
+			})
+
+			req := httptest.NewRequest(http.MethodPatch, "/problems/"+strconv.Itoa(tt.problem)+"/", strings.NewReader(tt.body))
+			rec := serve(t, r, req, tt.caller)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.after.ID == 0 {
+				return
+			}
+			if got := repo.problems[tt.problem]; !reflect.DeepEqual(got, tt.after) {
+				t.Fatalf("stored problem = %+v, want %+v", got, tt.after)
+			}
+		})
+	}
+}
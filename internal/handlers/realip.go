@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// ParseTrustedProxies parses comma-separated proxy addresses and CIDR
+// ranges, such as "10.0.0.0/8, 192.0.2.10".
+func ParseTrustedProxies(raw string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// RealIP sets the request's RemoteAddr to the client address reported by
+// X-Forwarded-For or X-Real-IP, but only when the request came from one of
+// the trusted proxies. Any client can send these headers, so without a
+// trusted proxy they are ignored; otherwise a client could pick the
+// address that login throttling and abuse detection key on.
+//
+// X-Forwarded-For is read from the right, skipping trusted proxies, so
+// addresses a client prepended are never used.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		return slices.ContainsFunc(trusted, func(prefix netip.Prefix) bool {
+			return prefix.Contains(addr)
+		})
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := parseIP(clientIP(r))
+			if !ok || !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if client, ok := forwardedFor(r.Header.Values("X-Forwarded-For"), isTrusted); ok {
+				r.RemoteAddr = client.String()
+			} else if client, ok := parseIP(r.Header.Get("X-Real-IP")); ok {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor returns the last address of the X-Forwarded-For values
+// that is not a trusted proxy. It stops at the first invalid entry, since
+// nothing left of it can be relied on.
+func forwardedFor(values []string, isTrusted func(netip.Addr) bool) (netip.Addr, bool) {
+	var hops []string
+	for _, value := range values {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseIP(hops[i])
+		if !ok {
+			break
+		}
+		last = addr
+		if !isTrusted(addr) {
+			return addr, true
+		}
+	}
+	// Every hop was a trusted proxy; the leftmost is the closest to the
+	// client.
+	return last, last.IsValid()
+}
+
+func parseIP(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// clientIP returns the client address of r without the port. The server's
+// RealIP middleware has already applied X-Forwarded-For and X-Real-IP from
+// trusted proxies.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatalf("parse trusted proxies: %v", err)
+	}
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:4711", want: "203.0.113.7"},
+		{name: "untrusted peer", remoteAddr: "203.0.113.7:4711", forwardedFor: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "192.0.2.10:4711", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "forged hops", remoteAddr: "10.1.2.3:4711", forwardedFor: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "proxy chain", remoteAddr: "10.1.2.3:4711", forwardedFor: []string{"198.51.100.1, 10.9.9.9", "192.0.2.10"}, want: "198.51.100.1"},
+		{name: "garbage left of the client", remoteAddr: "10.1.2.3:4711", forwardedFor: []string{"nonsense, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "only proxies", remoteAddr: "10.1.2.3:4711", forwardedFor: []string{"10.0.0.5, 10.0.0.6"}, want: "10.0.0.5"},
+		{name: "invalid forwarded for", remoteAddr: "10.1.2.3:4711", forwardedFor: []string{"nonsense"}, realIP: "198.51.100.2", want: "198.51.100.2"},
+		{name: "no headers", remoteAddr: "10.1.2.3:4711", want: "10.1.2.3"},
+		{name: "mapped address", remoteAddr: "[::ffff:10.1.2.3]:4711", forwardedFor: []string{"198.51.100.1"}, want: "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejects(t *testing.T) {
+	for _, raw := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0.1/"} {
+		if _, err := ParseTrustedProxies(raw); err == nil {
+			t.Errorf("ParseTrustedProxies(%q): want an error", raw)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+)
+
+// RecommendationHandler provides HTTP handlers for problem
+// recommendations.
+type RecommendationHandler struct {
+	recommendationService *services.RecommendationService
+}
+
+// NewRecommendationHandler constructs a RecommendationHandler with the
+// provided services.
+func NewRecommendationHandler(recommendationService *services.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{recommendationService: recommendationService}
+}
+
+// RecommendationRouter registers recommendation routes on the /problems
+// router.
+func RecommendationRouter(
+	r chi.Router,
+	recommendationService *services.RecommendationService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewRecommendationHandler(recommendationService)
+
+	r.With(authMiddleware).Get("/recommended", handler.GetRecommendations)
+}
+
+// GetRecommendations returns unsolved problems near the difficulty the
+// caller has been solving, best match first. They are recomputed
+// periodically, so problems solved since are left out until then.
+func (h *RecommendationHandler) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	recommendations, err := h.recommendationService.ForUser(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to fetch recommendations")
+		return
+	}
+	writeJSON(w, http.StatusOK, recommendations)
+}
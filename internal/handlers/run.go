@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// RunHandler provides HTTP handlers for custom runs.
+type RunHandler struct {
+	runService  *services.RunService
+	userService *services.UserService
+}
+
+// NewRunHandler constructs a RunHandler with the provided services.
+func NewRunHandler(runService *services.RunService, userService *services.UserService) *RunHandler {
+	return &RunHandler{
+		runService:  runService,
+		userService: userService,
+	}
+}
+
+// RunRouter registers custom run routes on the given router.
+func RunRouter(
+	r chi.Router,
+	runService *services.RunService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewRunHandler(runService, userService)
+
+	r.Use(authMiddleware)
+	r.Post("/", handler.CreateRun)
+	r.Get("/{runID}", handler.GetRun)
+}
+
+// CreateRun queues the caller's code to be executed against their own
+// input. The run is not graded; poll GET /runs/{id} for its output.
+func (h *RunHandler) CreateRun(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req RunRequest
+	limit := int64(services.MaxRunCodeBytes + services.MaxRunStdinBytes + 1<<10)
+	if err := json.NewDecoder(io.LimitReader(r.Body, limit)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	created, err := h.runService.Create(r.Context(), types.Run{
+		UserID:   userID,
+		Language: req.Language,
+		Code:     req.Code,
+		Stdin:    req.Stdin,
+	})
+	if err != nil {
+		var unsupportedErr *services.UnsupportedLanguageError
+		switch {
+		case errors.As(err, &unsupportedErr):
+			writeUnsupportedLanguage(w, unsupportedErr)
+		case errors.Is(err, services.ErrUnsupportedLanguage),
+			errors.Is(err, services.ErrInvalidRun):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrTooManyRuns):
+			writeErrorFrom(w, http.StatusTooManyRequests, err)
+		case errors.Is(err, services.ErrJudgeQueueUnavailable):
+			writeErrorCode(w, http.StatusServiceUnavailable, CodeJudgeQueueUnavailable, "custom runs are unavailable")
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to create run")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, created)
+}
+
+// GetRun returns a run to its owner or an admin.
+func (h *RunHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseRunID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := h.runService.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeRunNotFound, "run not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch run")
+		return
+	}
+
+	if run.UserID != userID {
+		user, err := h.userService.GetByID(r.Context(), userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		if !strings.EqualFold(user.Role, adminRole) {
+			writeErrorCode(w, http.StatusNotFound, CodeRunNotFound, "run not found")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+// RunRequest is the payload for starting a custom run.
+type RunRequest struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+	Stdin    string `json:"stdin"`
+}
+
+func parseRunID(r *http.Request) (int64, error) {
+	raw := chi.URLParam(r, "runID")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid run id")
+	}
+	return id, nil
+}
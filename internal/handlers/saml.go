@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// samlProxySecretHeader carries the secret shared with the SAML service
+// provider, proving that a request came through it.
+const samlProxySecretHeader = "X-SAML-Proxy-Secret"
+
+// SAMLProxy configures sign-in through a SAML service provider, such as
+// Shibboleth or mod_auth_mellon, running in front of the server. The
+// provider validates the assertion and passes its attributes in the named
+// headers. An empty Secret disables SAML sign-in.
+type SAMLProxy struct {
+	Secret         string
+	UsernameHeader string
+	EmailHeader    string
+	NameHeader     string
+	GroupsHeader   string
+
+	// RedirectURL is where signed-in users are sent, with the token in the
+	// URL fragment. When empty the token is returned as JSON.
+	RedirectURL string
+}
+
+// SAMLLogin signs in the user whose assertion the SAML service provider
+// validated, creating their account on first sign-in. The groups header
+// holds semicolon-separated values, as Shibboleth passes multi-valued
+// attributes.
+func (h *AuthHandler) SAMLLogin(w http.ResponseWriter, r *http.Request) {
+	if h.saml.Secret == "" {
+		writeError(w, http.StatusNotFound, "SAML sign-in is not configured")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(samlProxySecretHeader)), []byte(h.saml.Secret)) != 1 {
+		writeError(w, http.StatusUnauthorized, "request did not come through the SAML service provider")
+		return
+	}
+
+	var groups []string
+	for _, group := range strings.Split(r.Header.Get(h.saml.GroupsHeader), ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	user, err := h.externalAuth.SignIn(r.Context(), types.ExternalIdentity{
+		Username: r.Header.Get(h.saml.UsernameHeader),
+		Email:    r.Header.Get(h.saml.EmailHeader),
+		Name:     r.Header.Get(h.saml.NameHeader),
+		Groups:   groups,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExternalIdentity) || errors.Is(err, services.ErrLocalAccountExists) {
+			writeErrorFrom(w, http.StatusForbidden, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to sign in")
+		return
+	}
+
+	token, err := h.startSession(r, user)
+	if err != nil {
+		if errors.Is(err, services.ErrUserBanned) {
+			writeErrorFrom(w, http.StatusForbidden, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	if h.saml.RedirectURL != "" {
+		http.Redirect(w, r, h.saml.RedirectURL+"#token="+url.QueryEscape(token), http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, http.StatusOK, AuthResponse{Token: token, User: user})
+}
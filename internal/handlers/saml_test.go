@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+func (r *fakeUserRepo) GetByUsername(_ context.Context, username string) (types.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return types.User{}, store.ErrNotFound
+}
+
+func (r *fakeUserRepo) Create(_ context.Context, user types.User) (types.User, error) {
+	user.ID = len(r.users) + 1
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *fakeUserRepo) Update(_ context.Context, user types.User) (types.User, error) {
+	if _, ok := r.users[user.ID]; !ok {
+		return types.User{}, store.ErrNotFound
+	}
+	r.users[user.ID] = user
+	return user, nil
+}
+
+type fakeSessionRepo struct {
+	services.SessionRepository
+
+	mu       sync.Mutex
+	sessions map[string]types.Session
+}
+
+func (r *fakeSessionRepo) Get(_ context.Context, id string) (types.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return types.Session{}, store.ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *fakeSessionRepo) Create(_ context.Context, session types.Session, _ int) (types.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return session, nil
+}
+
+func (r *fakeSessionRepo) Touch(context.Context, string, time.Time) error {
+	return nil
+}
+
+func (r *fakeSessionRepo) DeleteByUser(_ context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, session := range r.sessions {
+		if session.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+const (
+	samlSecret      = "proxy-secret"
+	samlAdminsGroup = "cn=judge-admins,ou=groups,dc=example,dc=edu"
+)
+
+func samlRouter() http.Handler {
+	users := services.NewUserService(&fakeUserRepo{users: map[int]types.User{}}, nil)
+	sessions := services.NewSessionService(&fakeSessionRepo{sessions: map[string]types.Session{}}, 0)
+	externalAuth := services.NewExternalAuthService(users, sessions, nil, []services.GroupRole{
+		{Group: samlAdminsGroup, Role: types.RoleAdmin},
+	})
+	saml := SAMLProxy{
+		Secret:         samlSecret,
+		UsernameHeader: "X-User",
+		EmailHeader:    "X-Email",
+		GroupsHeader:   "X-Groups",
+	}
+
+	r := chi.NewRouter()
+	r.Route("/auth", func(r chi.Router) {
+		AuthRouter(r, users, sessions, nil, nil, externalAuth, saml, NewJWTKeys("test-secret"))
+	})
+	return r
+}
+
+// samlSignIn signs in jdoe as a member of groups and returns the token.
+func samlSignIn(t *testing.T, h http.Handler, groups string) (string, types.User) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/auth/saml", nil)
+	req.Header.Set(samlProxySecretHeader, samlSecret)
+	req.Header.Set("X-User", "jdoe")
+	req.Header.Set("X-Email", "jdoe@example.edu")
+	req.Header.Set("X-Groups", groups)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SAML sign-in status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp AuthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode sign-in: %v", err)
+	}
+	return resp.Token, resp.User
+}
+
+func me(h http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSAMLDemotionEndsOldSessions(t *testing.T) {
+	h := samlRouter()
+
+	adminToken, user := samlSignIn(t, h, samlAdminsGroup)
+	if user.Role != types.RoleAdmin {
+		t.Fatalf("role = %q, want %q", user.Role, types.RoleAdmin)
+	}
+	// Signing in again with the same groups leaves other sessions alone.
+	secondToken, _ := samlSignIn(t, h, samlAdminsGroup)
+	for _, token := range []string{adminToken, secondToken} {
+		if rec := me(h, token); rec.Code != http.StatusOK {
+			t.Fatalf("me status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+
+	// Removed from the admins group in the directory.
+	userToken, user := samlSignIn(t, h, "cn=staff,ou=groups,dc=example,dc=edu")
+	if user.Role != types.RoleUser {
+		t.Fatalf("role = %q, want %q", user.Role, types.RoleUser)
+	}
+	for _, token := range []string{adminToken, secondToken} {
+		rec := me(h, token)
+		if rec.Code != http.StatusUnauthorized || errorCodeOf(t, rec) != CodeSessionInvalid {
+			t.Fatalf("me with the admin token = %d %s, want %d %s", rec.Code, rec.Body, http.StatusUnauthorized, CodeSessionInvalid)
+		}
+	}
+	if rec := me(h, userToken); rec.Code != http.StatusOK {
+		t.Fatalf("me with the new token = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func errorCodeOf(t *testing.T, rec *httptest.ResponseRecorder) ErrorCode {
+	t.Helper()
+	var body struct {
+		Code ErrorCode `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return body.Code
+}
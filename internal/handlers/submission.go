@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	maxSourceBytes     = 64 << 10
+	maxAnswersBytes    = 8 << 20
+	formFieldProblemID = "problem_id"
+	formFieldLanguage  = "language"
+	formFieldSource    = "source"
+	formFieldAnswers   = "answers"
+)
+
+// SubmissionHandler provides HTTP handlers for submissions.
+type SubmissionHandler struct {
+	submissionService *services.SubmissionService
+	problemService    *services.ProblemService
+	userService       *services.UserService
+}
+
+// NewSubmissionHandler constructs a SubmissionHandler with the provided services.
+func NewSubmissionHandler(
+	submissionService *services.SubmissionService,
+	problemService *services.ProblemService,
+	userService *services.UserService,
+) *SubmissionHandler {
+	return &SubmissionHandler{
+		submissionService: submissionService,
+		problemService:    problemService,
+		userService:       userService,
+	}
+}
+
+// SubmissionRouter registers submission routes on the given router.
+func SubmissionRouter(
+	r chi.Router,
+	submissionService *services.SubmissionService,
+	problemService *services.ProblemService,
+	userService *services.UserService,
+	authMiddleware func(http.Handler) http.Handler,
+) {
+	handler := NewSubmissionHandler(submissionService, problemService, userService)
+
+	r.Use(authMiddleware)
+	r.Post("/", handler.CreateSubmission)
+	r.Post("/status", handler.GetStatuses)
+	r.Get("/{submissionID}", handler.GetSubmission)
+	r.Delete("/{submissionID}/pending", handler.CancelSubmission)
+	r.With(requireAdmin(userService)).Get("/{submissionID}/history", handler.GetVerdictHistory)
+}
+
+func (h *SubmissionHandler) CreateSubmission(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	req, err := parseSubmissionRequest(r)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	problem, err := h.problemService.Get(r.Context(), req.ProblemID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+		return
+	}
+	if !problem.Published {
+		user, err := h.userService.GetByID(r.Context(), userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		if err := h.problemService.AuthorizeView(r.Context(), user, problem); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				writeErrorCode(w, http.StatusNotFound, CodeProblemNotFound, "problem not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+			return
+		}
+	}
+
+	submission := types.Submission{
+		ProblemID: req.ProblemID,
+		UserID:    userID,
+		Code:      req.Code,
+		Language:  req.Language,
+	}
+	var created types.Submission
+	if len(req.Answers) > 0 {
+		created, err = h.submissionService.SubmitAnswers(r.Context(), problem, submission, req.Answers)
+	} else {
+		created, err = h.submissionService.Submit(r.Context(), problem, submission, req.Filename)
+	}
+	if err != nil {
+		var detectErr *services.LanguageDetectionError
+		var unsupportedErr *services.UnsupportedLanguageError
+		switch {
+		case errors.As(err, &detectErr):
+			suggestions := detectErr.Candidates
+			if suggestions == nil {
+				suggestions = []string{}
+			}
+			writeJSON(w, http.StatusBadRequest, LanguageErrorResponse{
+				Error:       detectErr.Error(),
+				Code:        CodeLanguageUndetected,
+				Suggestions: suggestions,
+			})
+		case errors.As(err, &unsupportedErr):
+			writeUnsupportedLanguage(w, unsupportedErr)
+		case errors.Is(err, services.ErrUnsupportedLanguage),
+			errors.Is(err, services.ErrAnswersRequired),
+			errors.Is(err, services.ErrAnswersNotAccepted),
+			errors.Is(err, services.ErrInvalidAnswers):
+			writeErrorFrom(w, http.StatusBadRequest, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to create submission")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// GetSubmission returns a submission with its code. Other users' submissions
+// are shown to admins, and to users the problem's SolutionVisibility lets
+// view accepted code.
+func (h *SubmissionHandler) GetSubmission(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseSubmissionID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	submission, err := h.submissionService.GetWithCode(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch submission")
+		return
+	}
+
+	if submission.UserID != userID {
+		user, err := h.userService.GetByID(r.Context(), userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load user")
+			return
+		}
+		problem, err := h.problemService.Get(r.Context(), submission.ProblemID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusInternalServerError, "failed to fetch problem")
+			return
+		}
+		allowed, err := h.submissionService.CanViewCode(r.Context(), user, submission, problem)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to fetch submission")
+			return
+		}
+		if !allowed {
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+			return
+		}
+		// Compiler output is only shown to the submission's owner.
+		submission.CompileStdout = ""
+		submission.CompileStderr = ""
+	}
+	if !strings.EqualFold(roleFromContext(r.Context()), adminRole) {
+		submission.SuspicionScore = 0
+	}
+
+	writeJSON(w, http.StatusOK, submission)
+}
+
+// CancelSubmission cancels one of the caller's submissions that has not
+// started judging and returns it with the Cancelled verdict.
+func (h *SubmissionHandler) CancelSubmission(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	id, err := parseSubmissionID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	submission, err := h.submissionService.Cancel(r.Context(), id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+		case errors.Is(err, services.ErrSubmissionNotPending):
+			writeErrorFrom(w, http.StatusConflict, err)
+		default:
+			writeError(w, http.StatusInternalServerError, "failed to cancel submission")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, submission)
+}
+
+// GetVerdictHistory returns the outcomes a submission had before it was
+// last judged, oldest first.
+func (h *SubmissionHandler) GetVerdictHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := parseSubmissionID(r)
+	if err != nil {
+		writeErrorFrom(w, http.StatusBadRequest, err)
+		return
+	}
+
+	records, err := h.submissionService.VerdictHistory(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeSubmissionNotFound, "submission not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch submission history")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SubmissionHistoryResponse{Items: records})
+}
+
+// GetStatuses returns the compact statuses of up to services.MaxStatusBatch
+// submissions in one response. Submissions the caller may not view are
+// omitted. The response carries an ETag so unchanged polls cost a 304.
+func (h *SubmissionHandler) GetStatuses(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req SubmissionStatusRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	for _, id := range req.IDs {
+		if id < 1 {
+			writeError(w, http.StatusBadRequest, "invalid submission id")
+			return
+		}
+	}
+
+	user, err := h.userService.GetByID(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	ownerID := userID
+	if strings.EqualFold(user.Role, adminRole) {
+		ownerID = 0
+	}
+
+	statuses, err := h.submissionService.Statuses(r.Context(), req.IDs, ownerID)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManySubmissionIDs) {
+			writeErrorFrom(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch submission statuses")
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, SubmissionStatusResponse{Statuses: statuses})
+}
+
+// SubmissionHistoryResponse lists the earlier outcomes of a submission.
+type SubmissionHistoryResponse struct {
+	Items []types.SubmissionVerdictRecord `json:"items"`
+}
+
+// SubmissionStatusRequest lists the submissions whose statuses are polled.
+type SubmissionStatusRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// SubmissionStatusResponse holds the statuses of the polled submissions
+// the caller may view, in ID order.
+type SubmissionStatusResponse struct {
+	Statuses []types.SubmissionStatus `json:"statuses"`
+}
+
+// SubmissionRequest is the parsed submission payload. It is accepted either
+// as JSON or as a multipart form with the source uploaded as a file.
+// Output-only problems take Answers, keyed by testcase name ("0_1"),
+// instead of Code; in multipart form each answer is an "answers" file named
+// after its testcase, such as 0_1.out.
+type SubmissionRequest struct {
+	ProblemID int               `json:"problem_id"`
+	Language  string            `json:"language"`
+	Code      string            `json:"code"`
+	Filename  string            `json:"filename"`
+	Answers   map[string]string `json:"answers"`
+}
+
+// LanguageErrorResponse is returned when the submission language is missing
+// and could not be detected, listing the likely candidates, or when it is
+// not accepted, listing the languages that are.
+type LanguageErrorResponse struct {
+	Error       string    `json:"error"`
+	Code        ErrorCode `json:"code"`
+	Suggestions []string  `json:"suggestions"`
+}
+
+// writeUnsupportedLanguage writes the 400 for a language the judge or
+// problem does not accept.
+func writeUnsupportedLanguage(w http.ResponseWriter, err *services.UnsupportedLanguageError) {
+	supported := err.Supported
+	if supported == nil {
+		supported = []string{}
+	}
+	writeJSON(w, http.StatusBadRequest, LanguageErrorResponse{
+		Error:       err.Error(),
+		Code:        CodeLanguageUnsupported,
+		Suggestions: supported,
+	})
+}
+
+func parseSubmissionID(r *http.Request) (int64, error) {
+	raw := chi.URLParam(r, "submissionID")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid submission id")
+	}
+	return id, nil
+}
+
+func parseSubmissionRequest(r *http.Request) (SubmissionRequest, error) {
+	var req SubmissionRequest
+	var v validator
+	codeField := "code"
+	// Fields that failed to parse are not checked again below.
+	checkProblemID, checkCode := true, true
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxAnswersBytes); err != nil {
+			return SubmissionRequest{}, errors.New("invalid multipart form")
+		}
+		problemID, err := parseOptionalInt(r.FormValue(formFieldProblemID))
+		if err != nil {
+			v.add(formFieldProblemID, "must be an integer", constraintInteger)
+			checkProblemID = false
+		}
+		req.ProblemID = problemID
+		req.Language = r.FormValue(formFieldLanguage)
+
+		codeField = formFieldSource
+		if answers := r.MultipartForm.File[formFieldAnswers]; len(answers) > 0 {
+			req.Answers, err = readAnswerFiles(answers)
+			if err != nil {
+				v.add(formFieldAnswers, err.Error(), constraintFile)
+			}
+			checkCode = false
+		} else {
+			file, header, err := r.FormFile(formFieldSource)
+			if err != nil {
+				v.add(formFieldSource, "file is required", constraintRequired)
+				checkCode = false
+			} else {
+				data, err := readFileLimited(file, maxSourceBytes)
+				_ = file.Close()
+				if err != nil {
+					v.add(formFieldSource, err.Error(), constraintFile)
+					checkCode = false
+				}
+				req.Code = string(data)
+				req.Filename = header.Filename
+			}
+		}
+	} else {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 2*maxAnswersBytes)).Decode(&req); err != nil {
+			return SubmissionRequest{}, errors.New("invalid request")
+		}
+	}
+
+	req.Language = strings.TrimSpace(req.Language)
+	if checkProblemID {
+		switch {
+		case req.ProblemID == 0:
+			v.add(formFieldProblemID, "is required", constraintRequired)
+		case req.ProblemID < 0:
+			v.add(formFieldProblemID, "must be positive", constraintPositive)
+		}
+	}
+	if len(req.Answers) > 0 {
+		total := 0
+		for _, answer := range req.Answers {
+			total += len(answer)
+		}
+		if total > maxAnswersBytes {
+			v.add(formFieldAnswers, fmt.Sprintf("must be at most %d bytes in total", maxAnswersBytes), constraintMaxBytes)
+		}
+	} else if checkCode {
+		if strings.TrimSpace(req.Code) == "" {
+			v.add(codeField, "is required", constraintRequired)
+		} else if len(req.Code) > maxSourceBytes {
+			v.add(codeField, fmt.Sprintf("must be at most %d bytes", maxSourceBytes), constraintMaxBytes)
+		}
+	}
+	if err := v.err(); err != nil {
+		return SubmissionRequest{}, err
+	}
+	return req, nil
+}
+
+// readAnswerFiles reads output-only answer uploads keyed by file name.
+func readAnswerFiles(files []*multipart.FileHeader) (map[string]string, error) {
+	answers := make(map[string]string, len(files))
+	remaining := int64(maxAnswersBytes)
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			return nil, errors.New("failed to read answer file")
+		}
+		data, err := readFileLimited(file, remaining)
+		_ = file.Close()
+		if err != nil {
+			return nil, errors.New("answers are too large")
+		}
+		remaining -= int64(len(data))
+		if _, dup := answers[header.Filename]; dup {
+			return nil, fmt.Errorf("duplicate answer file %s", header.Filename)
+		}
+		answers[header.Filename] = string(data)
+	}
+	return answers, nil
+}
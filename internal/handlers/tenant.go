@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+)
+
+// tenantHeader names the tenant of a request by slug, taking precedence
+// over its host.
+const tenantHeader = "X-Tenant"
+
+// ResolveTenant scopes each request to the tenant named by its X-Tenant
+// header or, failing that, by its host's label below baseDomain. Requests
+// naming neither are served by the default tenant; those naming an
+// unknown tenant get a 404.
+func ResolveTenant(tenants *services.TenantService, baseDomain string) func(http.Handler) http.Handler {
+	baseDomain = strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug := strings.TrimSpace(r.Header.Get(tenantHeader))
+			if slug == "" {
+				slug = hostTenant(r.Host, baseDomain)
+			}
+			tenantID := store.DefaultTenantID
+			if slug != "" {
+				tenant, err := tenants.Resolve(r.Context(), slug)
+				if err != nil {
+					if errors.Is(err, store.ErrNotFound) {
+						writeErrorCode(w, http.StatusNotFound, CodeTenantNotFound, "tenant not found")
+						return
+					}
+					writeError(w, http.StatusInternalServerError, "failed to resolve tenant")
+					return
+				}
+				tenantID = tenant.ID
+			}
+			next.ServeHTTP(w, r.WithContext(store.WithTenant(r.Context(), tenantID)))
+		})
+	}
+}
+
+// hostTenant returns the label of host directly below baseDomain, or ""
+// when host is not such a subdomain.
+func hostTenant(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	label, found := strings.CutSuffix(host, "."+baseDomain)
+	if !found || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// requireDefaultTenant rejects requests scoped to any tenant but the
+// default one. It guards admin routes acting on state shared by all
+// tenants, which a tenant's own admins must not see or change.
+func requireDefaultTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestTenant(r) != store.DefaultTenantID {
+			writeError(w, http.StatusForbidden, "default tenant admin access required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestTenant returns the tenant ResolveTenant scoped r to.
+func requestTenant(r *http.Request) int {
+	if tenantID, ok := store.TenantFromContext(r.Context()); ok {
+		return tenantID
+	}
+	return store.DefaultTenantID
+}
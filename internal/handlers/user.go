@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// UserHandler provides HTTP handlers for public user profiles.
+type UserHandler struct {
+	userService       *services.UserService
+	submissionService *services.SubmissionService
+}
+
+// NewUserHandler constructs a UserHandler with the provided services.
+func NewUserHandler(userService *services.UserService, submissionService *services.SubmissionService) *UserHandler {
+	return &UserHandler{
+		userService:       userService,
+		submissionService: submissionService,
+	}
+}
+
+// UserRouter registers user profile routes on the given router.
+func UserRouter(r chi.Router, userService *services.UserService, submissionService *services.SubmissionService) {
+	handler := NewUserHandler(userService, submissionService)
+
+	r.Route("/{username}", func(r chi.Router) {
+		r.Get("/solved", handler.GetSolved)
+		r.Get("/activity", handler.GetActivity)
+	})
+}
+
+func (h *UserHandler) GetSolved(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	solved, err := h.submissionService.ListSolved(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list solved problems")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SolvedResponse{Items: solved, Total: len(solved)})
+}
+
+func (h *UserHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	activity, err := h.submissionService.Activity(r.Context(), user.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load activity")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ActivityResponse{Days: activity})
+}
+
+func (h *UserHandler) lookupUser(w http.ResponseWriter, r *http.Request) (types.User, bool) {
+	username := strings.TrimSpace(chi.URLParam(r, "username"))
+	if username == "" {
+		writeError(w, http.StatusBadRequest, "missing username")
+		return types.User{}, false
+	}
+
+	user, err := h.userService.GetByUsername(r.Context(), username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeErrorCode(w, http.StatusNotFound, CodeUserNotFound, "user not found")
+			return types.User{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to fetch user")
+		return types.User{}, false
+	}
+	return user, true
+}
+
+// SolvedResponse lists the problems a user has solved.
+type SolvedResponse struct {
+	Items []types.SolvedProblem `json:"items"`
+	Total int                   `json:"total"`
+}
+
+// ActivityResponse lists a user's daily submission counts.
+type ActivityResponse struct {
+	Days []types.DailyActivity `json:"days"`
+}
@@ -2,16 +2,40 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
 )
 
 type contextKey string
 
-const contextSubjectKey contextKey = "sub"
+const (
+	contextSubjectKey contextKey = "sub"
+	contextSessionKey contextKey = "sid"
+	contextRoleKey    contextKey = "role"
+)
+
+// roleFromContext returns the role claim of the token that authenticated
+// the request, or "" if it carried none.
+func roleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(contextRoleKey).(string)
+	return role
+}
+
+// sessionIDFromContext returns the ID of the session that authenticated
+// the request, or "" outside the auth middleware.
+func sessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(contextSessionKey).(string)
+	return sessionID
+}
 
 func userIDFromContext(ctx context.Context) (int, error) {
 	value := ctx.Value(contextSubjectKey)
@@ -42,12 +66,127 @@ func userIDFromContext(ctx context.Context) (int, error) {
 	}
 }
 
+// ReplicaReads lets GET and HEAD requests read from the database replica,
+// accepting that they may trail recent writes by the replication lag.
+func ReplicaReads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			r = r.WithContext(store.WithReplicaReads(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func writeJSON(w http.ResponseWriter, status int, value any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(value)
 }
 
+// writeError writes an error response with the generic code for status.
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, ErrorResponse{Error: message})
+	writeErrorCode(w, status, statusErrorCode(status), message)
+}
+
+// writeJSONWithETag writes value like writeJSON, tagged with an ETag derived
+// from its encoding. If the request's If-None-Match already names that tag,
+// only 304 Not Modified is sent. The tag is weak because it identifies the
+// JSON value rather than the bytes on the wire, which a proxy may compress.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, status int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag,
+// using weak comparison.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Pagination is the metadata shared by paginated list responses. Next and
+// Prev are the adjacent page numbers, omitted at either end of the list.
+type Pagination struct {
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+	Next  int `json:"next,omitempty"`
+	Prev  int `json:"prev,omitempty"`
+}
+
+// paginate builds the metadata for a page of a list of total items and
+// sets the matching RFC 8288 Link header (first, prev, next, last) on w.
+func paginate(w http.ResponseWriter, r *http.Request, page, limit, total int) Pagination {
+	p := newPagination(page, limit, total)
+	last := lastPage(limit, total)
+
+	pageLink := func(n int) string {
+		return pageURL(r, map[string]string{"page": strconv.Itoa(n), "limit": strconv.Itoa(limit)})
+	}
+	links := []string{linkValue(pageLink(1), "first")}
+	if p.Prev > 0 {
+		links = append(links, linkValue(pageLink(p.Prev), "prev"))
+	}
+	if p.Next > 0 {
+		links = append(links, linkValue(pageLink(p.Next), "next"))
+	}
+	links = append(links, linkValue(pageLink(last), "last"))
+	w.Header().Add("Link", strings.Join(links, ", "))
+	return p
+}
+
+// newPagination builds the metadata for a page of a list of total items.
+func newPagination(page, limit, total int) Pagination {
+	p := Pagination{Total: total, Page: page, Limit: limit}
+	last := lastPage(limit, total)
+	if page > 1 {
+		p.Prev = min(page-1, last)
+	}
+	if page < last {
+		p.Next = page + 1
+	}
+	return p
+}
+
+func lastPage(limit, total int) int {
+	if limit > 0 && total > 0 {
+		return (total + limit - 1) / limit
+	}
+	return 1
+}
+
+// pageURL returns the request's path and query with the given query
+// parameters replaced.
+func pageURL(r *http.Request, params map[string]string) string {
+	query := r.URL.Query()
+	query.Del("per_page")
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func linkValue(target, rel string) string {
+	return fmt.Sprintf("<%s>; rel=%q", target, rel)
 }
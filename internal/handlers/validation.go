@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Validation constraints reported in FieldError.Constraint.
+const (
+	constraintRequired = "required"
+	constraintInteger  = "integer"
+	constraintPositive = "positive"
+	constraintOneOf    = "one_of"
+	constraintMaxBytes = "max_bytes"
+	constraintJSON     = "json"
+	constraintFile     = "file"
+)
+
+// FieldError describes why one request field was rejected.
+type FieldError struct {
+	// Field is the name of the field as sent by the client.
+	Field string `json:"field"`
+
+	// Reason is a human-readable explanation.
+	Reason string `json:"reason"`
+
+	// Constraint names the rule the value broke, such as "required".
+	Constraint string `json:"constraint"`
+}
+
+// ValidationError lists every invalid field of a request.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		reasons = append(reasons, field.Field+": "+field.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// ValidationErrorResponse is the 400 payload for a request with invalid
+// fields. Error summarizes Fields.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Code   ErrorCode    `json:"code"`
+	Fields []FieldError `json:"fields"`
+}
+
+// validator collects field errors so a request reports all of them at once
+// rather than stopping at the first.
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) add(field, reason, constraint string) {
+	v.fields = append(v.fields, FieldError{Field: field, Reason: reason, Constraint: constraint})
+}
+
+// required records an error if value is blank and reports whether it was
+// present.
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required", constraintRequired)
+		return false
+	}
+	return true
+}
+
+// err returns a *ValidationError holding the collected errors, or nil.
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// writeRequestError writes a 400 for a request that failed to parse or
+// validate, listing the invalid fields when err is a *ValidationError.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		writeJSON(w, http.StatusBadRequest, ValidationErrorResponse{
+			Error:  validationErr.Error(),
+			Code:   CodeValidationFailed,
+			Fields: validationErr.Fields,
+		})
+		return
+	}
+	writeErrorFrom(w, http.StatusBadRequest, err)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersionHeader names the version that served a response. On requests
+// to unversioned paths it selects the version to serve them with.
+const APIVersionHeader = "API-Version"
+
+var versionedPathPattern = regexp.MustCompile(`^/v[0-9]+(/|$)`)
+
+// APIVersion is a version of the API, mounted under its Prefix.
+type APIVersion struct {
+	Number int
+
+	// DeprecatedAt marks the version deprecated from that time on. Zero
+	// means it is current.
+	DeprecatedAt time.Time
+
+	// SunsetAt is when the version stops being served. Zero means no
+	// date is set.
+	SunsetAt time.Time
+}
+
+// Prefix returns the path prefix the version is mounted under.
+func (v APIVersion) Prefix() string {
+	return "/v" + strconv.Itoa(v.Number)
+}
+
+// VersionHeaders names the version in every response. Once the version is
+// deprecated, responses also carry a Deprecation header (RFC 9745), a
+// Sunset header (RFC 8594) when a date is set, and a link to the same path
+// under latest; after the sunset date requests fail with 410 Gone.
+func VersionHeaders(version, latest APIVersion) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(APIVersionHeader, strconv.Itoa(version.Number))
+			if version.DeprecatedAt.IsZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			successor := latest.Prefix() + strings.TrimPrefix(r.URL.Path, version.Prefix())
+			if !writeDeprecation(w, version.DeprecatedAt, version.SunsetAt, successor) {
+				writeErrorCode(w, http.StatusGone, CodeAPIVersionRetired, fmt.Sprintf("API version %d was retired; use %s", version.Number, latest.Prefix()))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UnversionedPolicy configures how paths without a version prefix are
+// served.
+type UnversionedPolicy struct {
+	// DeprecatedAt and SunsetAt are announced on every unversioned
+	// response. After SunsetAt unversioned requests fail with 410 Gone.
+	DeprecatedAt time.Time
+	SunsetAt     time.Time
+
+	// Exempt lists path prefixes that are not versioned, such as health
+	// checks, and are served as they are.
+	Exempt []string
+}
+
+// UnversionedPaths keeps clients written before versioning working by
+// routing paths without a version prefix to a mounted version: the one
+// named in the API-Version request header, or else the oldest, which
+// matches the API those clients were written against. Responses are
+// marked deprecated and link to the same path under the latest version.
+//
+// It must be installed on the root router, since it rewrites the path
+// before routing.
+func UnversionedPaths(versions []APIVersion, policy UnversionedPolicy) func(http.Handler) http.Handler {
+	oldest, latest := versions[0], versions[0]
+	for _, v := range versions {
+		if v.Number < oldest.Number {
+			oldest = v
+		}
+		if v.Number > latest.Number {
+			latest = v
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if versionedPathPattern.MatchString(path) || exemptPath(path, policy.Exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			version := oldest
+			if raw := strings.TrimSpace(r.Header.Get(APIVersionHeader)); raw != "" {
+				number, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(raw), "v"))
+				found := false
+				for _, v := range versions {
+					if err == nil && v.Number == number {
+						version, found = v, true
+					}
+				}
+				if !found {
+					writeErrorCode(w, http.StatusBadRequest, CodeAPIVersionUnsupported, fmt.Sprintf("unsupported API version %q", raw))
+					return
+				}
+			}
+
+			if !writeDeprecation(w, policy.DeprecatedAt, policy.SunsetAt, latest.Prefix()+path) {
+				writeErrorCode(w, http.StatusGone, CodeAPIVersionRetired, "unversioned API paths were retired; use "+latest.Prefix())
+				return
+			}
+
+			r.URL.Path = version.Prefix() + path
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = version.Prefix() + r.URL.RawPath
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeDeprecation sets the deprecation headers for a response unless they
+// were already set, and reports false once sunsetAt has passed.
+func writeDeprecation(w http.ResponseWriter, deprecatedAt, sunsetAt time.Time, successor string) bool {
+	if !sunsetAt.IsZero() && !time.Now().Before(sunsetAt) {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Deprecation") != "" {
+		return true
+	}
+	header.Set("Deprecation", fmt.Sprintf("@%d", deprecatedAt.Unix()))
+	if !sunsetAt.IsZero() {
+		header.Set("Sunset", sunsetAt.UTC().Format(http.TimeFormat))
+	}
+	header.Add("Link", linkValue(successor, "successor-version"))
+	return true
+}
+
+func exemptPath(path string, exempt []string) bool {
+	for _, prefix := range exempt {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+// Package jobs runs background work recorded in the database. One-off jobs
+// are queued with Enqueue; recurring ones are scheduled per task kind and
+// run once per interval across all servers sharing the database. Every run
+// is stored with its outcome, so failures can be inspected and jobs
+// interrupted by a restart are picked up again.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Repository persists jobs and schedules.
+type Repository interface {
+	Enqueue(ctx context.Context, job types.Job) (types.Job, error)
+	Claim(ctx context.Context, kinds []string, lease time.Duration) (types.Job, error)
+	Finish(ctx context.Context, job types.Job) error
+	List(ctx context.Context, status types.JobStatus, offset, limit int) ([]types.Job, int, error)
+	DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	ClaimSchedule(ctx context.Context, kind string, interval time.Duration) (bool, error)
+}
+
+// ErrUnknownKind is returned when enqueuing a job of a kind that has no
+// registered task.
+var ErrUnknownKind = errors.New("unknown job kind")
+
+// KindPrune is the built-in task that deletes finished jobs older than
+// Options.Retention.
+const KindPrune = "jobs.prune"
+
+// Task runs jobs of one kind.
+type Task struct {
+	// Run does the work described by payload. A non-nil result is stored
+	// with the job as JSON.
+	Run func(ctx context.Context, payload json.RawMessage) (any, error)
+
+	// Timeout bounds each attempt. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// MaxAttempts is the number of attempts before a failing job is given
+	// up on. Zero means one attempt.
+	MaxAttempts int
+}
+
+// DefaultTimeout bounds attempts of tasks without a Timeout.
+const DefaultTimeout = 10 * time.Minute
+
+// Options configures a Runner.
+type Options struct {
+	// Workers is the number of jobs run at once. Zero means one.
+	Workers int
+
+	// PollInterval is how often due jobs and schedules are checked for.
+	// Jobs enqueued by this process start right away regardless. Zero
+	// means five seconds.
+	PollInterval time.Duration
+
+	// Retention is how long finished jobs are kept. Zero keeps them
+	// forever.
+	Retention time.Duration
+}
+
+// Runner runs registered tasks.
+type Runner struct {
+	repo      Repository
+	opts      Options
+	tasks     map[string]Task
+	schedules map[string]time.Duration
+	wake      chan struct{}
+}
+
+// New constructs a Runner. Tasks must be registered before Run is called.
+func New(repo Repository, opts Options) *Runner {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	r := &Runner{
+		repo:      repo,
+		opts:      opts,
+		tasks:     map[string]Task{},
+		schedules: map[string]time.Duration{},
+		wake:      make(chan struct{}, 1),
+	}
+	if opts.Retention > 0 {
+		r.Register(KindPrune, Task{Run: r.prune})
+		r.Schedule(KindPrune, time.Hour)
+	}
+	return r
+}
+
+// Register sets the task that runs jobs of kind.
+func (r *Runner) Register(kind string, task Task) {
+	if task.Timeout <= 0 {
+		task.Timeout = DefaultTimeout
+	}
+	if task.MaxAttempts < 1 {
+		task.MaxAttempts = 1
+	}
+	r.tasks[kind] = task
+}
+
+// Schedule queues a job of the registered kind every interval.
+func (r *Runner) Schedule(kind string, interval time.Duration) {
+	r.schedules[kind] = interval
+}
+
+// Enqueue queues a job of kind with payload encoded as JSON.
+func (r *Runner) Enqueue(ctx context.Context, kind string, payload any) (types.Job, error) {
+	task, ok := r.tasks[kind]
+	if !ok {
+		return types.Job{}, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return types.Job{}, err
+	}
+	job, err := r.repo.Enqueue(ctx, types.Job{Kind: kind, Payload: encoded, MaxAttempts: task.MaxAttempts})
+	if err != nil {
+		return types.Job{}, err
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// List returns recent jobs, newest first, optionally only those with the
+// given status.
+func (r *Runner) List(ctx context.Context, status types.JobStatus, offset, limit int) ([]types.Job, int, error) {
+	return r.repo.List(ctx, status, offset, limit)
+}
+
+// Run queues scheduled jobs and runs due ones until ctx is cancelled,
+// waiting for running jobs to return before it does.
+func (r *Runner) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(r.tasks))
+	for kind := range r.tasks {
+		kinds = append(kinds, kind)
+	}
+
+	var wg sync.WaitGroup
+	for range r.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, kinds)
+		}()
+	}
+
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			r.schedule(ctx)
+		}
+	}
+}
+
+// schedule queues a job for each scheduled task that is due.
+func (r *Runner) schedule(ctx context.Context) {
+	for kind, interval := range r.schedules {
+		due, err := r.repo.ClaimSchedule(ctx, kind, interval)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("job schedule %s: %v", kind, err)
+			}
+			continue
+		}
+		if !due {
+			continue
+		}
+		if _, err := r.Enqueue(ctx, kind, struct{}{}); err != nil && ctx.Err() == nil {
+			log.Printf("job schedule %s: failed to enqueue: %v", kind, err)
+		}
+	}
+}
+
+// work runs due jobs one at a time, waiting for the next poll or enqueue
+// when there are none.
+func (r *Runner) work(ctx context.Context, kinds []string) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Jobs are leased for longer than they may run, so that a job is
+		// only claimed again once its runner is surely gone.
+		job, err := r.repo.Claim(ctx, kinds, r.maxTimeout()+time.Minute)
+		if err == nil {
+			r.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil {
+			log.Printf("failed to claim job: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// run runs one attempt of job and records its outcome. Jobs cut short by
+// shutdown are left running, to be claimed again once their lease ends.
+func (r *Runner) run(ctx context.Context, job types.Job) {
+	task, ok := r.tasks[job.Kind]
+	if !ok {
+		return
+	}
+
+	result, err := r.attempt(ctx, task, job)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	job.Result = nil
+	job.Error = ""
+	switch {
+	case err == nil:
+		job.Status = types.JobSucceeded
+		job.FinishedAt = &now
+		if result != nil {
+			encoded, encodeErr := json.Marshal(result)
+			if encodeErr != nil {
+				log.Printf("job %d (%s): failed to encode result: %v", job.ID, job.Kind, encodeErr)
+			}
+			job.Result = encoded
+		}
+	case job.Attempts < job.MaxAttempts:
+		job.Status = types.JobPending
+		job.Error = err.Error()
+		job.RunAt = now.Add(retryDelay(job.Attempts))
+	default:
+		job.Status = types.JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+		log.Printf("job %d (%s) failed: %v", job.ID, job.Kind, err)
+	}
+
+	if err := r.repo.Finish(context.WithoutCancel(ctx), job); err != nil {
+		log.Printf("job %d (%s): failed to record outcome: %v", job.ID, job.Kind, err)
+	}
+}
+
+// attempt runs the task, turning a panic into an error so that one bad
+// job does not take the server down.
+func (r *Runner) attempt(ctx context.Context, task Task, job types.Job) (result any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, task.Timeout)
+	defer cancel()
+	return task.Run(ctx, job.Payload)
+}
+
+func (r *Runner) maxTimeout() time.Duration {
+	longest := DefaultTimeout
+	for _, task := range r.tasks {
+		longest = max(longest, task.Timeout)
+	}
+	return longest
+}
+
+func (r *Runner) prune(ctx context.Context, _ json.RawMessage) (any, error) {
+	deleted, err := r.repo.DeleteFinishedBefore(ctx, time.Now().Add(-r.opts.Retention))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"deleted": deleted}, nil
+}
+
+// retryDelay is the wait before the attempt after attempts failed ones,
+// doubling from 30 seconds up to an hour.
+func retryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, time.Hour)
+}
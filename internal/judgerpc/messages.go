@@ -0,0 +1,239 @@
+package judgerpc
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoMessage is implemented by every message exchanged over the service,
+// including types.JudgeJob and types.JudgeResult, which carry their own
+// wire encoding.
+type protoMessage interface {
+	MarshalProto() []byte
+}
+
+type protoUnmarshaler interface {
+	UnmarshalProto(data []byte) error
+}
+
+// codec encodes messages with their hand-written protobuf encoding. It is
+// registered under the standard "proto" name so that clients generated
+// from proto/judge.proto interoperate.
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(protoMessage)
+	if !ok {
+		return nil, fmt.Errorf("judgerpc: cannot marshal %T", v)
+	}
+	return msg.MarshalProto(), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(protoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("judgerpc: cannot unmarshal into %T", v)
+	}
+	return msg.UnmarshalProto(data)
+}
+
+// FetchJobRequest asks for the next job of a pool.
+type FetchJobRequest struct {
+	// Pool is the pool whose channel to take a job from. Empty takes from
+	// the base channel.
+	Pool string
+}
+
+func (m FetchJobRequest) MarshalProto() []byte {
+	return appendString(nil, 1, m.Pool)
+}
+
+func (m *FetchJobRequest) UnmarshalProto(data []byte) error {
+	*m = FetchJobRequest{}
+	return walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == 1 {
+			return consumeString(typ, b, &m.Pool)
+		}
+		return 0, nil
+	})
+}
+
+// ResultAck acknowledges one result sent on a StreamResults stream.
+type ResultAck struct {
+	// Sequence is the position of the acknowledged result in the stream,
+	// starting at 1.
+	Sequence int64
+	// Code is the gRPC status code of storing the result, OK (0) when it
+	// was stored. Results rejected with Unavailable or Internal may be
+	// retried.
+	Code int64
+	// Error describes why the result was rejected.
+	Error string
+}
+
+func (m ResultAck) MarshalProto() []byte {
+	var b []byte
+	b = appendInt(b, 1, m.Sequence)
+	b = appendInt(b, 2, m.Code)
+	b = appendString(b, 3, m.Error)
+	return b
+}
+
+func (m *ResultAck) UnmarshalProto(data []byte) error {
+	*m = ResultAck{}
+	return walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeInt(typ, b, &m.Sequence)
+		case 2:
+			return consumeInt(typ, b, &m.Code)
+		case 3:
+			return consumeString(typ, b, &m.Error)
+		}
+		return 0, nil
+	})
+}
+
+// DownloadBundleRequest asks for the latest testcase bundle of a problem.
+type DownloadBundleRequest struct {
+	ProblemID int64
+}
+
+func (m DownloadBundleRequest) MarshalProto() []byte {
+	return appendInt(nil, 1, m.ProblemID)
+}
+
+func (m *DownloadBundleRequest) UnmarshalProto(data []byte) error {
+	*m = DownloadBundleRequest{}
+	return walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == 1 {
+			return consumeInt(typ, b, &m.ProblemID)
+		}
+		return 0, nil
+	})
+}
+
+// BundleChunk is a piece of a testcase bundle archive. Version and SHA256
+// describe the whole bundle and are only set on the first chunk.
+type BundleChunk struct {
+	Data    []byte
+	Version int64
+	SHA256  string
+}
+
+func (m BundleChunk) MarshalProto() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.Data)
+	b = appendInt(b, 2, m.Version)
+	b = appendString(b, 3, m.SHA256)
+	return b
+}
+
+func (m *BundleChunk) UnmarshalProto(data []byte) error {
+	*m = BundleChunk{}
+	return walk(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeBytes(typ, b, &m.Data)
+		case 2:
+			return consumeInt(typ, b, &m.Version)
+		case 3:
+			return consumeString(typ, b, &m.SHA256)
+		}
+		return 0, nil
+	})
+}
+
+// walk calls field for every field of a protobuf message. field returns
+// the number of bytes of b it consumed, or 0 to skip the field as unknown;
+// fields whose wire type does not match the schema are skipped the same
+// way, as types does.
+func walk(data []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n, err := field(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func consumeInt(typ protowire.Type, b []byte, v *int64) (int, error) {
+	if typ != protowire.VarintType {
+		return 0, nil
+	}
+	x, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = int64(x)
+	return n, nil
+}
+
+func consumeString(typ protowire.Type, b []byte, v *string) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, nil
+	}
+	x, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = x
+	return n, nil
+}
+
+func consumeBytes(typ protowire.Type, b []byte, v *[]byte) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, nil
+	}
+	x, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	*v = append([]byte(nil), x...)
+	return n, nil
+}
+
+// appendInt appends a varint field, omitting the zero value as proto3 does
+// for scalar fields.
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
@@ -0,0 +1,265 @@
+// Package judgerpc serves the judge worker API over gRPC. It mirrors the
+// worker endpoints of the HTTP API on top of the same services, so both
+// transports fetch jobs, store results and serve bundles identically.
+//
+// The service is jjudge.judge.v1.JudgeWorker in proto/judge.proto. Its
+// messages are encoded by hand like types.JudgeJob, so no generated code
+// is needed.
+package judgerpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	serviceName = "jjudge.judge.v1.JudgeWorker"
+
+	// maxResultBytes matches the size limit of results posted over HTTP.
+	maxResultBytes = 8 << 20
+
+	// bundleChunkBytes is the size of the chunks bundles are streamed in.
+	bundleChunkBytes = 256 << 10
+)
+
+// Server implements the judge worker service.
+type Server struct {
+	judge   *services.JudgeService
+	queue   *services.JudgeQueue
+	results *services.JudgeResultService
+	keys    services.JudgeWorkerKeys
+}
+
+// New constructs a Server. Workers authenticate with a client certificate
+// verified by the transport or with one of keys as a bearer token.
+func New(
+	judge *services.JudgeService,
+	queue *services.JudgeQueue,
+	results *services.JudgeResultService,
+	keys services.JudgeWorkerKeys,
+) *Server {
+	return &Server{
+		judge:   judge,
+		queue:   queue,
+		results: results,
+		keys:    keys,
+	}
+}
+
+// GRPCServer returns a gRPC server serving s. opts typically carry the
+// transport credentials.
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(maxResultBytes),
+		grpc.UnaryInterceptor(s.authenticateUnary),
+		grpc.StreamInterceptor(s.authenticateStream),
+	)
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&serviceDesc, s)
+	return server
+}
+
+// FetchJob takes the next job of the requested pool, waiting until one is
+// published or the call's deadline passes.
+func (s *Server) FetchJob(ctx context.Context, req *FetchJobRequest) (*types.JudgeJob, error) {
+	job, err := s.queue.Next(ctx, strings.ToLower(strings.TrimSpace(req.Pool)))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &job, nil
+}
+
+// StreamResults stores every result the worker sends and acknowledges each
+// in order. A rejected result does not end the stream.
+func (s *Server) StreamResults(stream grpc.ServerStream) error {
+	var sequence int64
+	for {
+		var result types.JudgeResult
+		if err := stream.RecvMsg(&result); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		sequence++
+
+		ack := ResultAck{Sequence: sequence}
+		err := result.Validate()
+		if err == nil {
+			err = s.results.Apply(stream.Context(), result)
+		}
+		if err != nil {
+			st := status.Convert(statusError(err))
+			ack.Code = int64(st.Code())
+			ack.Error = st.Message()
+		}
+		if err := stream.SendMsg(&ack); err != nil {
+			return err
+		}
+	}
+}
+
+// DownloadBundle streams the latest testcase bundle of a problem.
+func (s *Server) DownloadBundle(req *DownloadBundleRequest, stream grpc.ServerStream) error {
+	if req.ProblemID <= 0 {
+		return status.Error(codes.InvalidArgument, "problem_id is required")
+	}
+	reader, bundle, err := s.judge.OpenBundle(stream.Context(), int(req.ProblemID))
+	if err != nil {
+		return statusError(err)
+	}
+	defer reader.Close()
+
+	chunk := BundleChunk{Version: int64(bundle.Version), SHA256: bundle.SHA256}
+	buf := make([]byte, bundleChunkBytes)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if err := stream.SendMsg(&chunk); err != nil {
+				return err
+			}
+			chunk = BundleChunk{}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			log.Printf("judgerpc: failed to read bundle of problem %d: %v", req.ProblemID, err)
+			return status.Error(codes.Internal, "failed to read testcase bundle")
+		}
+	}
+}
+
+// statusError converts a service error to a gRPC status error. Unexpected
+// errors are logged and reported without detail.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, types.ErrInvalidJudgeMessage),
+		errors.Is(err, services.ErrInvalidJudgeWorker),
+		errors.Is(err, services.ErrInvalidRun),
+		errors.Is(err, services.ErrInvalidValidationResult):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, services.ErrStaleJudgeResult),
+		errors.Is(err, services.ErrRunFinished),
+		errors.Is(err, services.ErrBundleCorrupted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, services.ErrJudgeQueueUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		log.Printf("judgerpc: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// authenticate accepts a client certificate verified against the judge CA
+// or a worker API key sent as "authorization: Bearer <key>" metadata, like
+// the HTTP worker endpoints. The shared judge token is not accepted.
+func (s *Server) authenticate(ctx context.Context) error {
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return nil
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing worker credentials")
+	}
+	key, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || !s.keys.Valid(strings.TrimSpace(key)) {
+		return status.Error(codes.Unauthenticated, "invalid worker key")
+	}
+	return nil
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// workerServer is the interface serviceDesc dispatches to.
+type workerServer interface {
+	FetchJob(ctx context.Context, req *FetchJobRequest) (*types.JudgeJob, error)
+	StreamResults(stream grpc.ServerStream) error
+	DownloadBundle(req *DownloadBundleRequest, stream grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*workerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "FetchJob", Handler: fetchJobHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       streamResultsHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadBundle",
+			Handler:       downloadBundleHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/judge.proto",
+}
+
+func fetchJobHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	req := new(FetchJobRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(workerServer).FetchJob(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + serviceName + "/FetchJob",
+	}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(workerServer).FetchJob(ctx, req.(*FetchJobRequest))
+	})
+}
+
+func streamResultsHandler(srv any, stream grpc.ServerStream) error {
+	return srv.(workerServer).StreamResults(stream)
+}
+
+func downloadBundleHandler(srv any, stream grpc.ServerStream) error {
+	req := new(DownloadBundleRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(workerServer).DownloadBundle(req, stream)
+}
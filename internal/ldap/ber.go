@@ -0,0 +1,176 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER tags of the LDAP messages and fields used here (RFC 4511).
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	tagBindRequest      = 0x60
+	tagBindResponse     = 0x61
+	tagUnbindRequest    = 0x42
+	tagSearchRequest    = 0x63
+	tagSearchEntry      = 0x64
+	tagSearchDone       = 0x65
+	tagSearchReference  = 0x73
+	tagExtendedRequest  = 0x77
+	tagExtendedResponse = 0x78
+
+	// tagSimpleAuth is the [0] simple choice of a bind's authentication.
+	tagSimpleAuth = 0x80
+	// tagExtendedName is the [0] requestName of an extended request.
+	tagExtendedName = 0x80
+)
+
+// maxMessageSize bounds the size of a response message.
+const maxMessageSize = 16 << 20
+
+var errMalformed = errors.New("ldap: malformed message")
+
+// element is a decoded BER element with a single-byte tag.
+type element struct {
+	tag     byte
+	content []byte
+}
+
+// encode returns a BER element with the given tag whose content is the
+// concatenation of parts.
+func encode(tag byte, parts ...[]byte) []byte {
+	n := 0
+	for _, part := range parts {
+		n += len(part)
+	}
+	b := append([]byte{tag}, encodeLength(n)...)
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var digits []byte
+	for ; n > 0; n >>= 8 {
+		digits = append([]byte{byte(n)}, digits...)
+	}
+	return append([]byte{0x80 | byte(len(digits))}, digits...)
+}
+
+// encodeInt encodes the non-negative v under tag, which is tagInteger or
+// tagEnumerated.
+func encodeInt(tag byte, v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return encode(tag, b)
+}
+
+func encodeString(tag byte, s string) []byte {
+	return encode(tag, []byte(s))
+}
+
+func encodeBool(v bool) []byte {
+	if v {
+		return encode(tagBoolean, []byte{0xff})
+	}
+	return encode(tagBoolean, []byte{0x00})
+}
+
+// readElement reads one element from r.
+func readElement(r io.Reader) (element, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return element{}, err
+	}
+	if header[0]&0x1f == 0x1f {
+		return element{}, errMalformed
+	}
+	n := int(header[1])
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 {
+			return element{}, errMalformed
+		}
+		var digits [4]byte
+		if _, err := io.ReadFull(r, digits[:size]); err != nil {
+			return element{}, err
+		}
+		n = 0
+		for _, d := range digits[:size] {
+			n = n<<8 | int(d)
+		}
+	}
+	if n > maxMessageSize {
+		return element{}, fmt.Errorf("ldap: message of %d bytes is too large", n)
+	}
+	content := make([]byte, n)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return element{}, err
+	}
+	return element{tag: header[0], content: content}, nil
+}
+
+// parseElement decodes the element at the start of b and returns it with
+// the bytes that follow it.
+func parseElement(b []byte) (element, []byte, error) {
+	if len(b) < 2 || b[0]&0x1f == 0x1f {
+		return element{}, nil, errMalformed
+	}
+	tag, n, b := b[0], int(b[1]), b[2:]
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 4 || len(b) < size {
+			return element{}, nil, errMalformed
+		}
+		n = 0
+		for _, d := range b[:size] {
+			n = n<<8 | int(d)
+		}
+		b = b[size:]
+	}
+	if n < 0 || n > len(b) {
+		return element{}, nil, errMalformed
+	}
+	return element{tag: tag, content: b[:n]}, b[n:], nil
+}
+
+// children decodes the elements making up a constructed element.
+func (e element) children() ([]element, error) {
+	var children []element
+	for rest := e.content; len(rest) > 0; {
+		child, next, err := parseElement(rest)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		rest = next
+	}
+	return children, nil
+}
+
+// int decodes an integer or enumerated element.
+func (e element) int() (int, error) {
+	if len(e.content) == 0 || len(e.content) > 4 {
+		return 0, errMalformed
+	}
+	v := int(int8(e.content[0]))
+	for _, d := range e.content[1:] {
+		v = v<<8 | int(d)
+	}
+	return v, nil
+}
@@ -0,0 +1,315 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// LDAP result codes (RFC 4511, appendix A).
+const (
+	resultSuccess            = 0
+	resultSizeLimitExceeded  = 4
+	resultInvalidCredentials = 49
+)
+
+// startTLSOID names the StartTLS extended operation (RFC 4511, section 4.14).
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// ResultError is an operation result other than success.
+type ResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *ResultError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.Code)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// entry is a search result entry.
+type entry struct {
+	dn         string
+	attributes map[string][]string
+}
+
+// first returns the first value of the named attribute, if any.
+func (e entry) first(name string) string {
+	if values := e.values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// values returns the values of the named attribute. Attribute names are
+// case-insensitive.
+func (e entry) values(name string) []string {
+	return e.attributes[strings.ToLower(name)]
+}
+
+// conn is a connection to an LDAP server running one operation at a time.
+type conn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int
+}
+
+// dial connects to the server at rawURL, an ldap:// or ldaps:// URL, and
+// upgrades an ldap:// connection with StartTLS when startTLS is set. The
+// whole exchange must finish by the deadline of ctx.
+func dial(ctx context.Context, rawURL string, startTLS bool, tlsConfig *tls.Config) (*conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid URL: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var dialer net.Dialer
+	var nc net.Conn
+	switch u.Scheme {
+	case "ldap":
+		nc, err = dialer.DialContext(ctx, "tcp", host)
+	case "ldaps":
+		nc, err = (&tls.Dialer{NetDialer: &dialer, Config: serverTLSConfig(tlsConfig, u.Hostname())}).DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+	}
+
+	c := &conn{conn: nc, r: bufio.NewReader(nc)}
+	if startTLS && u.Scheme == "ldap" {
+		if err := c.startTLS(serverTLSConfig(tlsConfig, u.Hostname())); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func serverTLSConfig(base *tls.Config, host string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+func (c *conn) startTLS(cfg *tls.Config) error {
+	op := encode(tagExtendedRequest, encodeString(tagExtendedName, startTLSOID))
+	response, err := c.roundTrip(op, tagExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := resultOf(response); err != nil {
+		return fmt.Errorf("ldap: StartTLS refused: %w", err)
+	}
+
+	tlsConn := tls.Client(c.conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	c.r = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// bind authenticates the connection as dn with a simple bind.
+func (c *conn) bind(dn, password string) error {
+	op := encode(tagBindRequest,
+		encodeInt(tagInteger, 3),
+		encodeString(tagOctetString, dn),
+		encodeString(tagSimpleAuth, password),
+	)
+	response, err := c.roundTrip(op, tagBindResponse)
+	if err != nil {
+		return err
+	}
+	return resultOf(response)
+}
+
+// search returns the entries below baseDN matching filter with the given
+// attributes, at most sizeLimit of them.
+func (c *conn) search(baseDN, filter string, attributes []string, sizeLimit int) ([]entry, error) {
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var attrs [][]byte
+	for _, attr := range attributes {
+		attrs = append(attrs, encodeString(tagOctetString, attr))
+	}
+	op := encode(tagSearchRequest,
+		encodeString(tagOctetString, baseDN),
+		encodeInt(tagEnumerated, 2), // wholeSubtree
+		encodeInt(tagEnumerated, 0), // neverDerefAliases
+		encodeInt(tagInteger, sizeLimit),
+		encodeInt(tagInteger, 0),
+		encodeBool(false),
+		compiled,
+		encode(tagSequence, attrs...),
+	)
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for {
+		response, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch response.tag {
+		case tagSearchEntry:
+			e, err := parseEntry(response)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		case tagSearchReference:
+			// Referrals to other servers are not followed.
+		case tagSearchDone:
+			return entries, resultOf(response)
+		default:
+			return nil, errMalformed
+		}
+	}
+}
+
+// close sends an unbind request and closes the connection.
+func (c *conn) close() error {
+	c.nextID++
+	_, _ = c.conn.Write(encode(tagSequence, encodeInt(tagInteger, c.nextID), []byte{tagUnbindRequest, 0}))
+	return c.conn.Close()
+}
+
+// roundTrip sends op and returns its response, which must have the
+// given tag.
+func (c *conn) roundTrip(op []byte, tag byte) (element, error) {
+	id, err := c.send(op)
+	if err != nil {
+		return element{}, err
+	}
+	response, err := c.receive(id)
+	if err != nil {
+		return element{}, err
+	}
+	if response.tag != tag {
+		return element{}, errMalformed
+	}
+	return response, nil
+}
+
+func (c *conn) send(op []byte) (int, error) {
+	c.nextID++
+	_, err := c.conn.Write(encode(tagSequence, encodeInt(tagInteger, c.nextID), op))
+	return c.nextID, err
+}
+
+// receive reads the next message, which must answer message id, and
+// returns its protocol operation.
+func (c *conn) receive(id int) (element, error) {
+	message, err := readElement(c.r)
+	if err != nil {
+		return element{}, err
+	}
+	if message.tag != tagSequence {
+		return element{}, errMalformed
+	}
+	fields, err := message.children()
+	if err != nil {
+		return element{}, err
+	}
+	if len(fields) < 2 {
+		return element{}, errMalformed
+	}
+	got, err := fields[0].int()
+	if err != nil {
+		return element{}, err
+	}
+	if got == 0 {
+		// An unsolicited notification, such as notice of disconnection.
+		if err := resultOf(fields[1]); err != nil {
+			return element{}, err
+		}
+		return element{}, errors.New("ldap: server sent an unsolicited notification")
+	}
+	if got != id {
+		return element{}, fmt.Errorf("ldap: response to message %d while waiting for %d", got, id)
+	}
+	return fields[1], nil
+}
+
+// resultOf returns the error of an LDAPResult, or nil on success.
+func resultOf(response element) error {
+	fields, err := response.children()
+	if err != nil {
+		return err
+	}
+	if len(fields) < 3 {
+		return errMalformed
+	}
+	code, err := fields[0].int()
+	if err != nil {
+		return err
+	}
+	if code == resultSuccess {
+		return nil
+	}
+	return &ResultError{Code: code, Message: string(fields[2].content)}
+}
+
+func parseEntry(response element) (entry, error) {
+	fields, err := response.children()
+	if err != nil {
+		return entry{}, err
+	}
+	if len(fields) < 2 {
+		return entry{}, errMalformed
+	}
+	e := entry{dn: string(fields[0].content), attributes: make(map[string][]string)}
+	attributes, err := fields[1].children()
+	if err != nil {
+		return entry{}, err
+	}
+	for _, attribute := range attributes {
+		parts, err := attribute.children()
+		if err != nil {
+			return entry{}, err
+		}
+		if len(parts) < 2 {
+			return entry{}, errMalformed
+		}
+		values, err := parts[1].children()
+		if err != nil {
+			return entry{}, err
+		}
+		name := strings.ToLower(string(parts[0].content))
+		for _, value := range values {
+			e.attributes[name] = append(e.attributes[name], string(value.content))
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,274 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// The hex fixtures in these tests were produced by github.com/go-ldap/ldap
+// and github.com/go-asn1-ber/asn1-ber: requests by sending them with the
+// go-ldap client, responses by building them with asn1-ber.
+const (
+	// Bind as cn=svc,dc=example,dc=org with password s3cret, message 1.
+	goldenBindRequest = "302a02010160250201030418636e3d7376632c64633d6578616d706c652c64633d6f72678006733363726574"
+
+	// Message 2: a subtree search of ou=people,dc=example,dc=org for
+	// (&(objectClass=person)(uid=j\2adoe\28x\29\5c)), size limit 2,
+	// returning uid, mail, cn and memberOf.
+	goldenSearchRequest = "30770201026372041b6f753d70656f706c652c64633d6578616d706c652c64633d6f72670a01020a0100020102020100010100a029a315040b6f626a656374436c6173730406706572736f6ea310040375696404096a2a646f652878295c3019040375696404046d61696c0402636e04086d656d6265724f66"
+
+	// Message 2: the entry uid=jdoe,ou=people,dc=example,dc=org with a uid,
+	// a mail and two memberOf values. Its length needs the long form.
+	goldenSearchEntry = "3081b50201026481af04247569643d6a646f652c6f753d70656f706c652c64633d6578616d706c652c64633d6f7267308186300d0403756964310604046a646f65301a04046d61696c311204106a646f65406578616d706c652e6f7267305904086d656d6265724f66314d0424636e3d73746166662c6f753d67726f7570732c64633d6578616d706c652c64633d6f72670425636e3d61646d696e732c6f753d67726f7570732c64633d6578616d706c652c64633d6f7267"
+
+	goldenBindSuccess      = "300c02010161070a010004000400"
+	goldenBindInvalidCreds = "302c02010161270a01310400042038303039303330383a204c6461704572723a20445349442d3043303930343241"
+	goldenSearchDone       = "300c02010265070a010004000400"
+	goldenSearchSizeLimit  = "300c02010265070a010404000400"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	return b
+}
+
+// pipeConn returns a conn talking to the returned server end of a pipe.
+func pipeConn(t *testing.T) (*conn, net.Conn) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return &conn{conn: client, r: bufio.NewReader(client)}, server
+}
+
+// expectRequest reads a message from the server end of the pipe and
+// checks it against the fixture.
+func expectRequest(t *testing.T, server net.Conn, want string) {
+	t.Helper()
+	message, err := readElement(server)
+	if err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if got := hex.EncodeToString(encode(message.tag, message.content)); got != want {
+		t.Fatalf("request = %s\nwant      %s", got, want)
+	}
+}
+
+func respond(t *testing.T, server net.Conn, fixtures ...string) {
+	t.Helper()
+	for _, fixture := range fixtures {
+		if _, err := server.Write(unhex(t, fixture)); err != nil {
+			t.Fatalf("write response: %v", err)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  error
+	}{
+		{name: "success", response: goldenBindSuccess},
+		{
+			name:     "invalid credentials",
+			response: goldenBindInvalidCreds,
+			wantErr:  &ResultError{Code: resultInvalidCredentials, Message: "80090308: LdapErr: DSID-0C09042A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := pipeConn(t)
+			done := make(chan error, 1)
+			go func() { done <- c.bind("cn=svc,dc=example,dc=org", "s3cret") }()
+
+			expectRequest(t, server, goldenBindRequest)
+			respond(t, server, tt.response)
+			if err := <-done; !reflect.DeepEqual(err, tt.wantErr) {
+				t.Fatalf("bind: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	wantEntry := entry{
+		dn: "uid=jdoe,ou=people,dc=example,dc=org",
+		attributes: map[string][]string{
+			"uid":      {"jdoe"},
+			"mail":     {"jdoe@example.org"},
+			"memberof": {"cn=staff,ou=groups,dc=example,dc=org", "cn=admins,ou=groups,dc=example,dc=org"},
+		},
+	}
+	tests := []struct {
+		name    string
+		done    string
+		wantErr error
+	}{
+		{name: "success", done: goldenSearchDone},
+		{
+			name:    "size limit exceeded",
+			done:    goldenSearchSizeLimit,
+			wantErr: &ResultError{Code: resultSizeLimitExceeded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := pipeConn(t)
+			c.nextID = 1
+			type result struct {
+				entries []entry
+				err     error
+			}
+			done := make(chan result, 1)
+			go func() {
+				filter := fmt.Sprintf("(&(objectClass=person)(uid=%s))", escapeFilter(`j*doe(x)\`))
+				entries, err := c.search("ou=people,dc=example,dc=org", filter, []string{"uid", "mail", "cn", "memberOf"}, 2)
+				done <- result{entries, err}
+			}()
+
+			expectRequest(t, server, goldenSearchRequest)
+			respond(t, server, goldenSearchEntry, tt.done)
+			got := <-done
+			if !reflect.DeepEqual(got.err, tt.wantErr) {
+				t.Fatalf("search: err = %v, want %v", got.err, tt.wantErr)
+			}
+			if len(got.entries) != 1 || !reflect.DeepEqual(got.entries[0], wantEntry) {
+				t.Fatalf("entries = %+v, want [%+v]", got.entries, wantEntry)
+			}
+			if groups := got.entries[0].values("memberOf"); len(groups) != 2 {
+				t.Fatalf("memberOf = %q, want both groups", groups)
+			}
+		})
+	}
+}
+
+func TestReceive(t *testing.T) {
+	// A notice of disconnection: message 0, extended response with
+	// unavailable (52).
+	notice := hex.EncodeToString(encode(tagSequence,
+		encodeInt(tagInteger, 0),
+		encode(tagExtendedResponse, encodeInt(tagEnumerated, 52), encodeString(tagOctetString, ""), encodeString(tagOctetString, "shutting down")),
+	))
+	tests := []struct {
+		name     string
+		response []byte
+		wantErr  string
+	}{
+		{name: "other message", response: unhex(t, goldenBindSuccess), wantErr: "ldap: response to message 1 while waiting for 2"},
+		{name: "notice of disconnection", response: unhex(t, notice), wantErr: "ldap: result code 52: shutting down"},
+		{name: "not a sequence", response: []byte{0x31, 0x00}, wantErr: errMalformed.Error()},
+		{name: "missing operation", response: []byte{0x30, 0x03, 0x02, 0x01, 0x02}, wantErr: errMalformed.Error()},
+		{name: "truncated", response: unhex(t, goldenBindSuccess)[:8], wantErr: "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, server := pipeConn(t)
+			go func() {
+				_, _ = server.Write(tt.response)
+				_ = server.Close()
+			}()
+			_, err := c.receive(2)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("receive: err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadElement(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		want    element
+		wantErr bool
+	}{
+		{name: "short form", in: []byte{0x04, 0x02, 'h', 'i'}, want: element{tag: 0x04, content: []byte("hi")}},
+		{name: "long form", in: append([]byte{0x04, 0x81, 0x80}, bytes.Repeat([]byte{'x'}, 0x80)...), want: element{tag: 0x04, content: bytes.Repeat([]byte{'x'}, 0x80)}},
+		{name: "indefinite length", in: []byte{0x30, 0x80, 0x00, 0x00}, wantErr: true},
+		{name: "length of five bytes", in: []byte{0x04, 0x85, 0, 0, 0, 0, 1, 'x'}, wantErr: true},
+		{name: "too large", in: []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff}, wantErr: true},
+		{name: "multi-byte tag", in: []byte{0x1f, 0x81, 0x00}, wantErr: true},
+		{name: "truncated content", in: []byte{0x04, 0x03, 'h', 'i'}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readElement(bytes.NewReader(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("element = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeInt(t *testing.T) {
+	tests := []struct {
+		v    int
+		want string
+	}{
+		{0, "020100"},
+		{3, "020103"},
+		{127, "02017f"},
+		{128, "02020080"},
+		{256, "02020100"},
+		{65535, "020300ffff"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(encodeInt(tagInteger, tt.v)); got != tt.want {
+			t.Errorf("encodeInt(%d) = %s, want %s", tt.v, got, tt.want)
+		}
+		decoded, err := element{tag: tagInteger, content: unhex(t, tt.want)[2:]}.int()
+		if err != nil || decoded != tt.v {
+			t.Errorf("int() of %s = %d, %v, want %d", tt.want, decoded, err, tt.v)
+		}
+	}
+}
+
+func TestResultOf(t *testing.T) {
+	result := func(code int, message string) element {
+		return element{tag: tagBindResponse, content: bytes.Join([][]byte{
+			encodeInt(tagEnumerated, code),
+			encodeString(tagOctetString, ""),
+			encodeString(tagOctetString, message),
+		}, nil)}
+	}
+	tests := []struct {
+		name     string
+		response element
+		wantErr  string
+	}{
+		{name: "success", response: result(resultSuccess, "")},
+		{name: "size limit exceeded", response: result(resultSizeLimitExceeded, ""), wantErr: "ldap: result code 4"},
+		{name: "invalid credentials", response: result(resultInvalidCredentials, "bad password"), wantErr: "ldap: result code 49: bad password"},
+		{name: "missing fields", response: element{tag: tagBindResponse, content: encodeInt(tagEnumerated, 0)}, wantErr: errMalformed.Error()},
+		{name: "truncated field", response: element{tag: tagBindResponse, content: []byte{0x0a, 0x01}}, wantErr: errMalformed.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resultOf(tt.response)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
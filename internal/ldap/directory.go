@@ -0,0 +1,144 @@
+// Package ldap checks passwords against an LDAP directory with a minimal
+// LDAPv3 client: simple binds and subtree searches over ldap://, ldaps://
+// or StartTLS.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrInvalidCredentials is returned by Authenticate when the directory has
+// no single entry for the username or rejects the password.
+var ErrInvalidCredentials = errors.New("invalid directory credentials")
+
+// Directory authenticates users against an LDAP directory. It opens a
+// connection per sign-in.
+type Directory struct {
+	url               string
+	startTLS          bool
+	bindDN            string
+	bindPassword      string
+	userBaseDN        string
+	userFilter        string
+	usernameAttribute string
+	emailAttribute    string
+	nameAttribute     string
+	groupAttribute    string
+	timeout           time.Duration
+
+	// dial opens the connection for a sign-in; tests replace it.
+	dial func(ctx context.Context, rawURL string, startTLS bool, tlsConfig *tls.Config) (*conn, error)
+}
+
+// NewFromConfig returns the configured directory, or nil when no LDAP URL
+// is configured so callers can treat directory sign-in as an optional
+// feature.
+func NewFromConfig(cfg config.LDAPConfig) (*Directory, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("invalid LDAP_URL %q: want ldap://host[:port] or ldaps://host[:port]", cfg.URL)
+	}
+	if strings.TrimSpace(cfg.UserBaseDN) == "" {
+		return nil, errors.New("LDAP_USER_BASE_DN is required when LDAP_URL is set")
+	}
+	if strings.Count(cfg.UserFilter, "%s") != 1 {
+		return nil, fmt.Errorf("LDAP_USER_FILTER %q must contain one %%s for the username", cfg.UserFilter)
+	}
+	if _, err := compileFilter(fmt.Sprintf(cfg.UserFilter, "x")); err != nil {
+		return nil, fmt.Errorf("invalid LDAP_USER_FILTER: %w", err)
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &Directory{
+		url:               u.String(),
+		startTLS:          cfg.StartTLS,
+		bindDN:            cfg.BindDN,
+		bindPassword:      cfg.BindPassword,
+		userBaseDN:        cfg.UserBaseDN,
+		userFilter:        cfg.UserFilter,
+		usernameAttribute: cfg.UsernameAttribute,
+		emailAttribute:    cfg.EmailAttribute,
+		nameAttribute:     cfg.NameAttribute,
+		groupAttribute:    cfg.GroupAttribute,
+		timeout:           timeout,
+		dial:              dial,
+	}, nil
+}
+
+// Authenticate looks up the user's entry, checks the password by binding
+// as it and returns the user as described by the entry. The username is
+// taken from the entry so that it is spelled the same way at every
+// sign-in.
+func (d *Directory) Authenticate(ctx context.Context, username, password string) (types.ExternalIdentity, error) {
+	// A simple bind with an empty password is an unauthenticated bind,
+	// which many servers accept for any DN.
+	if username == "" || password == "" {
+		return types.ExternalIdentity{}, ErrInvalidCredentials
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	c, err := d.dial(ctx, d.url, d.startTLS, nil)
+	if err != nil {
+		return types.ExternalIdentity{}, err
+	}
+	defer c.close()
+
+	if d.bindDN != "" {
+		if err := c.bind(d.bindDN, d.bindPassword); err != nil {
+			return types.ExternalIdentity{}, fmt.Errorf("ldap: service account bind failed: %w", err)
+		}
+	}
+
+	var attributes []string
+	for _, attr := range []string{d.usernameAttribute, d.emailAttribute, d.nameAttribute, d.groupAttribute} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+	entries, err := c.search(d.userBaseDN, fmt.Sprintf(d.userFilter, escapeFilter(username)), attributes, 2)
+	var result *ResultError
+	if errors.As(err, &result) && result.Code == resultSizeLimitExceeded {
+		return types.ExternalIdentity{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return types.ExternalIdentity{}, err
+	}
+	if len(entries) != 1 {
+		return types.ExternalIdentity{}, ErrInvalidCredentials
+	}
+	user := entries[0]
+
+	if err := c.bind(user.dn, password); err != nil {
+		if errors.As(err, &result) && result.Code == resultInvalidCredentials {
+			return types.ExternalIdentity{}, ErrInvalidCredentials
+		}
+		return types.ExternalIdentity{}, err
+	}
+
+	identity := types.ExternalIdentity{
+		Username: username,
+		Email:    user.first(d.emailAttribute),
+		Name:     user.first(d.nameAttribute),
+		Groups:   user.values(d.groupAttribute),
+	}
+	if name := user.first(d.usernameAttribute); name != "" {
+		identity.Username = name
+	}
+	return identity, nil
+}
@@ -0,0 +1,302 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"maps"
+	"net"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	serviceDN = "cn=svc,dc=example,dc=org"
+	jdoeDN    = "uid=jdoe,ou=people,dc=example,dc=org"
+)
+
+// fakeServer answers binds from a table of passwords and every search
+// with the same entries.
+type fakeServer struct {
+	passwords map[string]string
+	entries   []entry
+	// searchResult is the result code of the search; entries are sent
+	// either way, as servers do when a size limit is hit.
+	searchResult int
+
+	mu      sync.Mutex
+	dials   int
+	binds   []string
+	filters [][]byte
+}
+
+func (s *fakeServer) dial(context.Context, string, bool, *tls.Config) (*conn, error) {
+	s.mu.Lock()
+	s.dials++
+	s.mu.Unlock()
+	client, server := net.Pipe()
+	go s.serve(server)
+	return &conn{conn: client, r: bufio.NewReader(client)}, nil
+}
+
+func (s *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	for {
+		message, err := readElement(nc)
+		if err != nil {
+			return
+		}
+		fields, err := message.children()
+		if err != nil || len(fields) < 2 {
+			return
+		}
+		id, err := fields[0].int()
+		if err != nil {
+			return
+		}
+		reply := func(op []byte) bool {
+			_, err := nc.Write(encode(tagSequence, encodeInt(tagInteger, id), op))
+			return err == nil
+		}
+		result := func(tag byte, code int) []byte {
+			return encode(tag, encodeInt(tagEnumerated, code), encodeString(tagOctetString, ""), encodeString(tagOctetString, ""))
+		}
+
+		op := fields[1]
+		switch op.tag {
+		case tagBindRequest:
+			parts, err := op.children()
+			if err != nil || len(parts) != 3 {
+				return
+			}
+			dn, password := string(parts[1].content), string(parts[2].content)
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+			code := resultInvalidCredentials
+			if want, ok := s.passwords[dn]; ok && want == password {
+				code = resultSuccess
+			}
+			if !reply(result(tagBindResponse, code)) {
+				return
+			}
+		case tagSearchRequest:
+			parts, err := op.children()
+			if err != nil || len(parts) != 8 {
+				return
+			}
+			s.mu.Lock()
+			s.filters = append(s.filters, encode(parts[6].tag, parts[6].content))
+			s.mu.Unlock()
+			for _, e := range s.entries {
+				if !reply(encodeEntry(e)) {
+					return
+				}
+			}
+			if !reply(result(tagSearchDone, s.searchResult)) {
+				return
+			}
+		default:
+			// Unbind, or anything a directory would not expect.
+			return
+		}
+	}
+}
+
+func encodeEntry(e entry) []byte {
+	var attributes [][]byte
+	for _, name := range slices.Sorted(maps.Keys(e.attributes)) {
+		var values [][]byte
+		for _, value := range e.attributes[name] {
+			values = append(values, encodeString(tagOctetString, value))
+		}
+		attributes = append(attributes, encode(tagSequence, encodeString(tagOctetString, name), encode(tagSet, values...)))
+	}
+	return encode(tagSearchEntry, encodeString(tagOctetString, e.dn), encode(tagSequence, attributes...))
+}
+
+func newTestDirectory(t *testing.T, bindDN string, server *fakeServer) *Directory {
+	t.Helper()
+	d, err := NewFromConfig(config.LDAPConfig{
+		URL:               "ldap://ldap.example.org",
+		BindDN:            bindDN,
+		BindPassword:      "svc-password",
+		UserBaseDN:        "ou=people,dc=example,dc=org",
+		UserFilter:        "(&(objectClass=person)(uid=%s))",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		NameAttribute:     "cn",
+		GroupAttribute:    "memberOf",
+	})
+	if err != nil {
+		t.Fatalf("new directory: %v", err)
+	}
+	d.dial = server.dial
+	return d
+}
+
+func jdoe() entry {
+	return entry{dn: jdoeDN, attributes: map[string][]string{
+		"uid":      {"JDoe"},
+		"mail":     {"jdoe@example.org"},
+		"cn":       {"Jane Doe"},
+		"memberof": {"cn=staff,ou=groups,dc=example,dc=org", "cn=setters,ou=groups,dc=example,dc=org"},
+	}}
+}
+
+func TestAuthenticate(t *testing.T) {
+	passwords := map[string]string{serviceDN: "svc-password", jdoeDN: "correct"}
+	tests := []struct {
+		name      string
+		bindDN    string
+		server    *fakeServer
+		username  string
+		password  string
+		want      types.ExternalIdentity
+		wantErr   error
+		wantDials int
+		wantBinds []string
+	}{
+		{
+			name:     "success",
+			bindDN:   serviceDN,
+			server:   &fakeServer{passwords: passwords, entries: []entry{jdoe()}},
+			username: "jdoe",
+			password: "correct",
+			want: types.ExternalIdentity{
+				Username: "JDoe",
+				Email:    "jdoe@example.org",
+				Name:     "Jane Doe",
+				Groups:   []string{"cn=staff,ou=groups,dc=example,dc=org", "cn=setters,ou=groups,dc=example,dc=org"},
+			},
+			wantDials: 1,
+			wantBinds: []string{serviceDN, jdoeDN},
+		},
+		{
+			name:      "anonymous search",
+			server:    &fakeServer{passwords: passwords, entries: []entry{{dn: jdoeDN, attributes: map[string][]string{}}}},
+			username:  "jdoe",
+			password:  "correct",
+			want:      types.ExternalIdentity{Username: "jdoe"},
+			wantDials: 1,
+			wantBinds: []string{jdoeDN},
+		},
+		{
+			name:     "empty password",
+			bindDN:   serviceDN,
+			server:   &fakeServer{passwords: passwords, entries: []entry{jdoe()}},
+			username: "jdoe",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:     "empty username",
+			bindDN:   serviceDN,
+			server:   &fakeServer{passwords: passwords, entries: []entry{jdoe()}},
+			password: "correct",
+			wantErr:  ErrInvalidCredentials,
+		},
+		{
+			name:      "wrong password",
+			bindDN:    serviceDN,
+			server:    &fakeServer{passwords: passwords, entries: []entry{jdoe()}},
+			username:  "jdoe",
+			password:  "wrong",
+			wantErr:   ErrInvalidCredentials,
+			wantDials: 1,
+			wantBinds: []string{serviceDN, jdoeDN},
+		},
+		{
+			name:      "no matching entry",
+			bindDN:    serviceDN,
+			server:    &fakeServer{passwords: passwords},
+			username:  "jdoe",
+			password:  "correct",
+			wantErr:   ErrInvalidCredentials,
+			wantDials: 1,
+			wantBinds: []string{serviceDN},
+		},
+		{
+			name:   "several matching entries",
+			bindDN: serviceDN,
+			server: &fakeServer{passwords: passwords, entries: []entry{
+				jdoe(),
+				{dn: "uid=jdoe,ou=contractors,dc=example,dc=org", attributes: map[string][]string{"uid": {"jdoe"}}},
+			}},
+			username:  "jdoe",
+			password:  "correct",
+			wantErr:   ErrInvalidCredentials,
+			wantDials: 1,
+			wantBinds: []string{serviceDN},
+		},
+		{
+			name:      "size limit exceeded",
+			bindDN:    serviceDN,
+			server:    &fakeServer{passwords: passwords, entries: []entry{jdoe()}, searchResult: resultSizeLimitExceeded},
+			username:  "jdoe",
+			password:  "correct",
+			wantErr:   ErrInvalidCredentials,
+			wantDials: 1,
+			wantBinds: []string{serviceDN},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDirectory(t, tt.bindDN, tt.server)
+			got, err := d.Authenticate(context.Background(), tt.username, tt.password)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("identity = %+v, want %+v", got, tt.want)
+			}
+
+			tt.server.mu.Lock()
+			defer tt.server.mu.Unlock()
+			if tt.server.dials != tt.wantDials {
+				t.Fatalf("dials = %d, want %d", tt.server.dials, tt.wantDials)
+			}
+			if !slices.Equal(tt.server.binds, tt.wantBinds) {
+				t.Fatalf("binds = %q, want %q", tt.server.binds, tt.wantBinds)
+			}
+		})
+	}
+}
+
+func TestAuthenticateServiceBindFails(t *testing.T) {
+	server := &fakeServer{passwords: map[string]string{serviceDN: "rotated"}, entries: []entry{jdoe()}}
+	d := newTestDirectory(t, serviceDN, server)
+
+	_, err := d.Authenticate(context.Background(), "jdoe", "correct")
+	var result *ResultError
+	if !errors.As(err, &result) || result.Code != resultInvalidCredentials {
+		t.Fatalf("err = %v, want the service account's bind result", err)
+	}
+	// A misconfigured service account is not the user's fault.
+	if errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want an error other than %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestAuthenticateEscapesUsername(t *testing.T) {
+	server := &fakeServer{passwords: map[string]string{serviceDN: "svc-password"}}
+	d := newTestDirectory(t, serviceDN, server)
+
+	if _, err := d.Authenticate(context.Background(), "*)(uid=*", "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidCredentials)
+	}
+	want := encode(filterAnd,
+		encode(filterEquality, encodeString(tagOctetString, "objectClass"), encodeString(tagOctetString, "person")),
+		encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, "*)(uid=*")),
+	)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.filters) != 1 || string(server.filters[0]) != string(want) {
+		t.Fatalf("filters = %x, want [%x]", server.filters, want)
+	}
+}
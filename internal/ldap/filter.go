@@ -0,0 +1,192 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choice tags (RFC 4511, section 4.5.1).
+const (
+	filterAnd            = 0xa0
+	filterOr             = 0xa1
+	filterNot            = 0xa2
+	filterEquality       = 0xa3
+	filterSubstrings     = 0xa4
+	filterGreaterOrEqual = 0xa5
+	filterLessOrEqual    = 0xa6
+	filterPresent        = 0x87
+	filterApprox         = 0xa8
+
+	substringInitial = 0x80
+	substringAny     = 0x81
+	substringFinal   = 0x82
+)
+
+// escapeFilter escapes the characters with a meaning in a string filter,
+// so that s matches itself as an assertion value.
+func escapeFilter(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes an RFC 4515 string filter, such as
+// "(&(objectClass=person)(uid=jdoe))", for a search request.
+func compileFilter(s string) ([]byte, error) {
+	b, rest, err := parseFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("ldap: unexpected %q after filter", rest)
+	}
+	return b, nil
+}
+
+// parseFilter encodes the parenthesized filter at the start of s and
+// returns it with the rest of s.
+func parseFilter(s string) ([]byte, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("ldap: filter %q does not start with (", s)
+	}
+	s = s[1:]
+
+	var b []byte
+	switch {
+	case strings.HasPrefix(s, "&"), strings.HasPrefix(s, "|"):
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		s = s[1:]
+		var parts [][]byte
+		for strings.HasPrefix(s, "(") {
+			part, rest, err := parseFilter(s)
+			if err != nil {
+				return nil, "", err
+			}
+			parts = append(parts, part)
+			s = rest
+		}
+		b = encode(tag, parts...)
+	case strings.HasPrefix(s, "!"):
+		part, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		b = encode(filterNot, part)
+		s = rest
+	default:
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, "", fmt.Errorf("ldap: unterminated filter item %q", s)
+		}
+		item, err := parseItem(s[:end])
+		if err != nil {
+			return nil, "", err
+		}
+		b = item
+		s = s[end:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", fmt.Errorf("ldap: filter is missing a closing )")
+	}
+	return b, s[1:], nil
+}
+
+// parseItem encodes a simple filter item such as "uid=jdoe", "cn=j*"
+// or "mail=*".
+func parseItem(s string) ([]byte, error) {
+	i := strings.IndexAny(s, "=~<>")
+	if i <= 0 {
+		return nil, fmt.Errorf("ldap: invalid filter item %q", s)
+	}
+	attr, op := s[:i], s[i:]
+
+	var tag byte
+	var raw string
+	switch {
+	case strings.HasPrefix(op, "~="):
+		tag, raw = filterApprox, op[2:]
+	case strings.HasPrefix(op, ">="):
+		tag, raw = filterGreaterOrEqual, op[2:]
+	case strings.HasPrefix(op, "<="):
+		tag, raw = filterLessOrEqual, op[2:]
+	case op[0] == '=':
+		raw = op[1:]
+		if raw == "*" {
+			return encodeString(filterPresent, attr), nil
+		}
+		if strings.Contains(raw, "*") {
+			return encodeSubstrings(attr, raw)
+		}
+		tag = filterEquality
+	default:
+		return nil, fmt.Errorf("ldap: invalid filter item %q", s)
+	}
+
+	value, err := unescapeFilterValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	return encode(tag, encodeString(tagOctetString, attr), encodeString(tagOctetString, value)), nil
+}
+
+func encodeSubstrings(attr, raw string) ([]byte, error) {
+	parts := strings.Split(raw, "*")
+	var subs [][]byte
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		value, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		tag := byte(substringAny)
+		switch i {
+		case 0:
+			tag = substringInitial
+		case len(parts) - 1:
+			tag = substringFinal
+		}
+		subs = append(subs, encodeString(tag, value))
+	}
+	return encode(filterSubstrings, encodeString(tagOctetString, attr), encode(tagSequence, subs...)), nil
+}
+
+// unescapeFilterValue decodes the \XX escapes of an assertion value.
+func unescapeFilterValue(s string) (string, error) {
+	if strings.ContainsAny(s, "()*") {
+		return "", fmt.Errorf("ldap: unescaped special character in filter value %q", s)
+	}
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", fmt.Errorf("ldap: truncated escape in filter value %q", s)
+		}
+		decoded, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("ldap: invalid escape in filter value %q", s)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
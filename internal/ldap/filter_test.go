@@ -0,0 +1,77 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestEscapeFilter(t *testing.T) {
+	tests := map[string]string{
+		"jdoe":       "jdoe",
+		`j*doe(x)\`:  `j\2adoe\28x\29\5c`,
+		"a\x00b":     `a\00b`,
+		"josé=admin": "josé=admin",
+	}
+	for in, want := range tests {
+		if got := escapeFilter(in); got != want {
+			t.Errorf("escapeFilter(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestEscapedUsernameMatchesItself checks that an escaped username is
+// sent as an equality match on the literal value, not as a substring
+// match or a change to the filter's structure.
+func TestEscapedUsernameMatchesItself(t *testing.T) {
+	for _, username := range []string{"*", "admin)(uid=*", `\2a`, "a\x00"} {
+		got, err := compileFilter(fmt.Sprintf("(uid=%s)", escapeFilter(username)))
+		if err != nil {
+			t.Fatalf("compile filter for %q: %v", username, err)
+		}
+		want := encode(filterEquality, encodeString(tagOctetString, "uid"), encodeString(tagOctetString, username))
+		if string(got) != string(want) {
+			t.Errorf("filter for %q = %x, want %x", username, got, want)
+		}
+	}
+}
+
+func TestCompileFilter(t *testing.T) {
+	// Encodings produced by go-ldap's CompileFilter.
+	tests := map[string]string{
+		"(&(objectClass=person)(uid=j\\2adoe\\28x\\29\\5c))": "a029a315040b6f626a656374436c6173730406706572736f6ea310040375696404096a2a646f652878295c",
+		"(cn=J*d*e)": "a40f0402636e300980014a810164820165",
+		"(cn=*x)":    "a4090402636e3003820178",
+		"(|(mail=*)(!(uid>=m))(cn~=jon)(age<=40))": "a12887046d61696ca20aa508040375696404016da8090402636e04036a6f6ea609040361676504023430",
+	}
+	for filter, want := range tests {
+		got, err := compileFilter(filter)
+		if err != nil {
+			t.Errorf("compileFilter(%q): %v", filter, err)
+			continue
+		}
+		if hex.EncodeToString(got) != want {
+			t.Errorf("compileFilter(%q) = %x, want %s", filter, got, want)
+		}
+	}
+}
+
+func TestCompileFilterRejects(t *testing.T) {
+	for _, filter := range []string{
+		"",
+		"uid=jdoe",
+		"(uid=jdoe",
+		"(uid=jdoe))",
+		"(=jdoe)",
+		"(uid)",
+		"(uid=a(b)",
+		`(uid=\2)`,
+		`(uid=\zz)`,
+		"(&(uid=a)(cn=b)",
+		"(!uid=a)",
+	} {
+		if _, err := compileFilter(filter); err == nil {
+			t.Errorf("compileFilter(%q): want an error", filter)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromConfig returns the mailer for the configured backend, or nil
+// when no SMTP host is configured for the default SMTP backend so callers
+// can treat email as an optional feature.
+func NewFromConfig(cfg config.MailConfig) (Mailer, error) {
+	backend := strings.ToLower(strings.TrimSpace(cfg.Backend))
+	if (backend == "" || backend == "smtp") && strings.TrimSpace(cfg.SMTPHost) == "" {
+		return nil, nil
+	}
+	if strings.TrimSpace(cfg.From) == "" {
+		return nil, errors.New("MAIL_FROM is required when email delivery is configured")
+	}
+
+	switch backend {
+	case "", "smtp":
+		return NewSMTPMailer(cfg), nil
+	case "ses":
+		if strings.TrimSpace(cfg.SESRegion) == "" {
+			return nil, errors.New("MAIL_SES_REGION is required for the ses mail backend")
+		}
+		if cfg.SESAccessKeyID == "" || cfg.SESSecretAccessKey == "" {
+			return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the ses mail backend")
+		}
+		return NewSESMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported MAIL_BACKEND %q", cfg.Backend)
+	}
+}
+
+// SMTPMailer sends mail through an SMTP relay using PLAIN auth when
+// credentials are configured.
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer constructs an SMTPMailer from config.
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return &SMTPMailer{
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host: cfg.SMTPHost,
+		auth: auth,
+		from: cfg.From,
+	}
+}
+
+// Send delivers msg. smtp.SendMail does not take a context, so cancellation
+// only stops waiting for the result.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("invalid header value")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
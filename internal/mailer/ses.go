@@ -0,0 +1,175 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+)
+
+// sesSendPath is the SES v2 SendEmail operation.
+const sesSendPath = "/v2/email/outbound-emails"
+
+// SESMailer sends mail through the Amazon SES v2 API, signing requests
+// with AWS Signature Version 4.
+type SESMailer struct {
+	client       *http.Client
+	endpoint     string
+	host         string
+	region       string
+	accessKeyID  string
+	secretKey    string
+	sessionToken string
+	from         string
+}
+
+// NewSESMailer constructs an SESMailer from config.
+func NewSESMailer(cfg config.MailConfig) *SESMailer {
+	host := fmt.Sprintf("email.%s.amazonaws.com", cfg.SESRegion)
+	return &SESMailer{
+		client:       &http.Client{Timeout: 30 * time.Second},
+		endpoint:     "https://" + host + sesSendPath,
+		host:         host,
+		region:       cfg.SESRegion,
+		accessKeyID:  cfg.SESAccessKeyID,
+		secretKey:    cfg.SESSecretAccessKey,
+		sessionToken: cfg.SESSessionToken,
+		from:         cfg.From,
+	}
+}
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+type sesSendRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Simple struct {
+			Subject sesContent `json:"Subject"`
+			Body    struct {
+				Text sesContent `json:"Text"`
+			} `json:"Body"`
+		} `json:"Simple"`
+	} `json:"Content"`
+}
+
+// Send delivers msg.
+func (m *SESMailer) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("invalid header value")
+	}
+
+	var payload sesSendRequest
+	payload.FromEmailAddress = m.from
+	payload.Destination.ToAddresses = []string{msg.To}
+	payload.Content.Simple.Subject = sesContent{Data: msg.Subject, Charset: "UTF-8"}
+	payload.Content.Simple.Body.Text = sesContent{Data: msg.Body, Charset: "UTF-8"}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	m.sign(req, body, time.Now())
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("ses: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 headers for a request with the given
+// body, made at now.
+func (m *SESMailer) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := [][2]string{
+		{"content-type", req.Header.Get("Content-Type")},
+		{"host", m.host},
+		{"x-amz-date", amzDate},
+	}
+	if m.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", m.sessionToken)
+		headers = append(headers, [2]string{"x-amz-security-token", m.sessionToken})
+	}
+
+	scope, signedHeaders, signature := signV4(req.Method, req.URL.EscapedPath(), headers, sha256Hex(body), amzDate, m.region, "ses", m.secretKey)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		m.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// signV4 computes a Signature Version 4 signature for a request without a
+// query string. headers are the lowercase names and values of the signed
+// headers, sorted by name.
+func signV4(method, path string, headers [][2]string, payloadHash, amzDate, region, service, secretKey string) (scope, signedHeaders, signature string) {
+	date := amzDate[:8]
+
+	var canonicalHeaders strings.Builder
+	names := make([]string, len(headers))
+	for i, header := range headers {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", header[0], strings.TrimSpace(header[1]))
+		names[i] = header[0]
+	}
+	signedHeaders = strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope = date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Email templates. Each is a plain-text file whose first line is
+// "Subject: ..." followed by a blank line and the body, both filled in
+// from the data passed to Render.
+const (
+	TemplateWelcome       = "welcome.txt"
+	TemplatePasswordReset = "password_reset.txt"
+	TemplateAccountLocked = "account_locked.txt"
+	TemplateCredentials   = "credentials.txt"
+	TemplateNotification  = "notification.txt"
+	TemplateVerdictDigest = "verdict_digest.txt"
+)
+
+//go:embed templates/*.txt
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("").Option("missingkey=error").ParseFS(templateFiles, "templates/*.txt"))
+
+// Render fills the named template with data and returns it as a message
+// to the given address.
+func Render(to, name string, data any) (Message, error) {
+	var b bytes.Buffer
+	if err := templates.ExecuteTemplate(&b, name, data); err != nil {
+		return Message{}, err
+	}
+
+	header, body, ok := strings.Cut(b.String(), "\n\n")
+	subject, found := strings.CutPrefix(header, "Subject: ")
+	if !ok || !found || strings.Contains(subject, "\n") {
+		return Message{}, fmt.Errorf("email template %s does not start with a subject line", name)
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject),
+		Body:    strings.TrimLeft(body, "\n"),
+	}, nil
+}
@@ -0,0 +1,237 @@
+// Package markdown renders problem statements to sanitized HTML.
+//
+// LaTeX is passed through untouched for client-side KaTeX: math delimited
+// by $...$, $$...$$, \(...\) or \[...\] is lifted out before markdown
+// parsing, so emphasis and escapes inside formulas are not mangled, and is
+// put back afterwards, HTML-escaped, in <span class="math ..."> elements
+// that keep the original delimiters.
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Private-use runes mark where a math segment was removed. They pass
+// through goldmark and bluemonday unchanged and are stripped from the input
+// so statements cannot forge a placeholder.
+const (
+	placeholderStart = '\uE000'
+	placeholderEnd   = '\uE001'
+)
+
+var (
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	policy   = bluemonday.UGCPolicy()
+)
+
+// Render converts markdown to sanitized HTML, preserving LaTeX segments.
+func Render(src string) (string, error) {
+	text, segments := extractMath(src)
+
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(text), &buf); err != nil {
+		return "", err
+	}
+	out := policy.Sanitize(buf.String())
+
+	return restoreMath(out, segments), nil
+}
+
+type mathSegment struct {
+	raw     string
+	display bool
+}
+
+// extractMath replaces math segments outside code with placeholders.
+func extractMath(src string) (string, []mathSegment) {
+	src = strings.Map(func(r rune) rune {
+		if r == placeholderStart || r == placeholderEnd {
+			return -1
+		}
+		return r
+	}, src)
+
+	var out strings.Builder
+	var segments []mathSegment
+	fence := ""
+	lineStart := true
+
+	for i := 0; i < len(src); {
+		lineEnd := strings.IndexByte(src[i:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src)
+		} else {
+			lineEnd += i
+		}
+
+		if lineStart {
+			line := src[i:min(lineEnd+1, len(src))]
+			trimmed := strings.TrimLeft(line, " ")
+			marker := fenceMarker(trimmed)
+			switch {
+			case fence == "" && marker != "":
+				fence = marker
+			case fence != "" && strings.HasPrefix(trimmed, fence):
+				fence = ""
+			case fence == "" && !strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "\t"):
+				lineStart = false
+				continue
+			}
+			// Fenced or indented code is copied verbatim.
+			out.WriteString(line)
+			i += len(line)
+			continue
+		}
+
+		c := src[i]
+		switch {
+		case c == '\n':
+			out.WriteByte(c)
+			i++
+			lineStart = true
+		case c == '\\' && i+1 < len(src) && (src[i+1] == '(' || src[i+1] == '['):
+			display := src[i+1] == '['
+			closer := `\)`
+			if display {
+				closer = `\]`
+			}
+			if end := strings.Index(src[i+2:], closer); end >= 0 {
+				end += i + 2 + len(closer)
+				addSegment(src[i:end], display, &out, &segments)
+				i = end
+				continue
+			}
+			out.WriteString(src[i : i+2])
+			i += 2
+		case c == '\\' && i+1 < lineEnd:
+			out.WriteString(src[i : i+2])
+			i += 2
+		case c == '`':
+			// Inline code is copied verbatim up to the matching run of
+			// backticks on the same line.
+			n := runLength(src[i:lineEnd], '`')
+			if end := strings.Index(src[i+n:lineEnd], src[i:i+n]); end >= 0 {
+				end += i + 2*n
+				out.WriteString(src[i:end])
+				i = end
+				continue
+			}
+			out.WriteString(src[i : i+n])
+			i += n
+		case strings.HasPrefix(src[i:], "$$"):
+			if end := strings.Index(src[i+2:], "$$"); end > 0 {
+				end += i + 4
+				addSegment(src[i:end], true, &out, &segments)
+				i = end
+				continue
+			}
+			out.WriteString("$$")
+			i += 2
+		case c == '$':
+			if end := inlineMathEnd(src[:lineEnd], i); end > 0 {
+				addSegment(src[i:end], false, &out, &segments)
+				i = end
+				continue
+			}
+			out.WriteByte(c)
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String(), segments
+}
+
+func addSegment(raw string, display bool, out *strings.Builder, segments *[]mathSegment) {
+	out.WriteRune(placeholderStart)
+	out.WriteString(strconv.Itoa(len(*segments)))
+	out.WriteRune(placeholderEnd)
+	*segments = append(*segments, mathSegment{raw: raw, display: display})
+}
+
+// inlineMathEnd returns the index after the closing $ of an inline segment
+// opened at i, or 0. Like pandoc, the opening $ must be followed by a
+// non-space and the closing $ preceded by a non-space and not followed by
+// a digit, so prices such as "$5 and $10" stay literal.
+func inlineMathEnd(s string, i int) int {
+	if i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == '\t' || s[i+1] == '\n' {
+		return 0
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '$':
+			if s[j-1] == ' ' || s[j-1] == '\t' {
+				continue
+			}
+			if j+1 < len(s) && s[j+1] >= '0' && s[j+1] <= '9' {
+				continue
+			}
+			return j + 1
+		}
+	}
+	return 0
+}
+
+func fenceMarker(line string) string {
+	for _, ch := range []byte{'`', '~'} {
+		if n := runLength(line, ch); n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+func runLength(s string, ch byte) int {
+	n := 0
+	for n < len(s) && s[n] == ch {
+		n++
+	}
+	return n
+}
+
+// restoreMath swaps placeholders for escaped math wrapped in spans.
+func restoreMath(s string, segments []mathSegment) string {
+	if len(segments) == 0 {
+		return s
+	}
+
+	var out strings.Builder
+	for {
+		start := strings.IndexRune(s, placeholderStart)
+		if start < 0 {
+			break
+		}
+		end := strings.IndexRune(s[start:], placeholderEnd)
+		if end < 0 {
+			break
+		}
+		end += start
+		idx, err := strconv.Atoi(s[start+len(string(placeholderStart)) : end])
+		out.WriteString(s[:start])
+		if err != nil || idx < 0 || idx >= len(segments) {
+			out.WriteString(s[start : end+len(string(placeholderEnd))])
+		} else {
+			seg := segments[idx]
+			class := "math math-inline"
+			if seg.display {
+				class = "math math-display"
+			}
+			out.WriteString(`<span class="` + class + `">`)
+			out.WriteString(html.EscapeString(seg.raw))
+			out.WriteString(`</span>`)
+		}
+		s = s[end+len(string(placeholderEnd)):]
+	}
+	out.WriteString(s)
+	return out.String()
+}
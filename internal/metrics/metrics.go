@@ -0,0 +1,230 @@
+// Package metrics exposes server metrics in the Prometheus text format.
+// It implements just the metric types the server records, so the module
+// does not depend on the Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector writes metric families in the Prometheus text format.
+type Collector interface {
+	WriteMetrics(w io.Writer) error
+}
+
+// Handler serves collectors in the Prometheus text exposition format.
+func Handler(collectors ...Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range collectors {
+			if err := c.WriteMetrics(w); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// Counter counts events, partitioned by a fixed set of labels.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	count  uint64
+}
+
+// NewCounter constructs a counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: make(map[string]*counterSeries),
+	}
+}
+
+// Inc counts one event in the series with the given label values, one per
+// label name in order. A nil Counter discards events.
+func (c *Counter) Inc(labelValues ...string) {
+	if c == nil {
+		return
+	}
+	key := seriesKey(c.name, c.labels, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: slices.Clone(labelValues)}
+		c.series[key] = s
+	}
+	s.count++
+}
+
+// WriteMetrics writes the counter's series, sorted by label values.
+func (c *Counter) WriteMetrics(w io.Writer) error {
+	c.mu.Lock()
+	series := make([]counterSeries, 0, len(c.series))
+	for _, s := range c.series {
+		series = append(series, *s)
+	}
+	c.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool {
+		return slices.Compare(series[i].values, series[j].values) < 0
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n", c.name, escapeHelp(c.help))
+	fmt.Fprintf(&b, "# TYPE %s counter\n", c.name)
+	for _, s := range series {
+		fmt.Fprintf(&b, "%s%s %d\n", c.name, formatLabels(c.labels, s.values, ""), s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Histogram counts observations in cumulative buckets, partitioned by a
+// fixed set of labels. Quantiles such as p95 are computed from the buckets
+// by Prometheus with histogram_quantile.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram constructs a histogram with the given upper bucket bounds,
+// which must be sorted, and label names. A +Inf bucket is always added.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: histogram buckets must be sorted")
+	}
+	return &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: slices.Clone(buckets),
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe records value in the series with the given label values, one per
+// label name in order. A nil Histogram discards observations.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	key := seriesKey(h.name, h.labels, labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			values: slices.Clone(labelValues),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// WriteMetrics writes the histogram's series, sorted by label values.
+func (h *Histogram) WriteMetrics(w io.Writer) error {
+	h.mu.Lock()
+	series := make([]histogramSeries, 0, len(h.series))
+	for _, s := range h.series {
+		series = append(series, histogramSeries{
+			values: s.values,
+			counts: slices.Clone(s.counts),
+			count:  s.count,
+			sum:    s.sum,
+		})
+	}
+	h.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool {
+		return slices.Compare(series[i].values, series[j].values) < 0
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n", h.name, escapeHelp(h.help))
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", h.name)
+	for _, s := range series {
+		for i, bound := range h.buckets {
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, s.values, "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.values, ""), formatFloat(s.sum))
+		fmt.Fprintf(&b, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.values, ""), s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// seriesKey identifies the series with the given label values.
+func seriesKey(name string, labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels formats a series' labels, plus le when it is not empty.
+func formatLabels(names, values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
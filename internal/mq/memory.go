@@ -0,0 +1,165 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrMemoryClosed is returned by operations on a closed Memory backend.
+var ErrMemoryClosed = errors.New("memory mq is closed")
+
+// Memory is a Backend that delivers messages in-process. It is meant for
+// tests: messages are queued per channel until a subscriber takes them,
+// messages whose handler fails are queued again, and every published
+// message is recorded for inspection with Published.
+type Memory struct {
+	mu        sync.Mutex
+	queues    map[string]*memoryQueue
+	published map[string][]Message
+	nextID    int
+	closed    bool
+	done      chan struct{}
+}
+
+type memoryQueue struct {
+	messages []Message
+	// ready is closed and replaced whenever messages are queued.
+	ready chan struct{}
+}
+
+// NewMemory constructs an empty in-memory message queue.
+func NewMemory() *Memory {
+	return &Memory{
+		queues:    make(map[string]*memoryQueue),
+		published: make(map[string][]Message),
+		done:      make(chan struct{}),
+	}
+}
+
+// Publish queues a message on the named channel.
+func (m *Memory) Publish(ctx context.Context, channel string, data []byte, attrs map[string]string) (string, error) {
+	if strings.TrimSpace(channel) == "" {
+		return "", errors.New("memory mq channel is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return "", ErrMemoryClosed
+	}
+
+	m.nextID++
+	message := Message{
+		ID:         strconv.Itoa(m.nextID),
+		Data:       append([]byte(nil), data...),
+		Attributes: maps.Clone(attrs),
+	}
+	m.published[channel] = append(m.published[channel], message)
+	m.enqueueLocked(channel, message)
+	return message.ID, nil
+}
+
+// Subscribe handles messages from the named channel until ctx is cancelled
+// or the backend is closed. A message whose handler returns an error is
+// queued again at the back of the channel.
+func (m *Memory) Subscribe(ctx context.Context, channel string, handler Handler) error {
+	if strings.TrimSpace(channel) == "" {
+		return errors.New("memory mq channel is required")
+	}
+
+	for {
+		message, ready, ok, err := m.next(channel)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-m.done:
+				return ErrMemoryClosed
+			case <-ready:
+			}
+			continue
+		}
+
+		if err := handler(ctx, message); err != nil {
+			m.mu.Lock()
+			if !m.closed {
+				m.enqueueLocked(channel, message)
+			}
+			m.mu.Unlock()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Published returns the messages published to the named channel so far,
+// including those already consumed.
+func (m *Memory) Published(channel string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.published[channel]...)
+}
+
+// Pending returns the number of messages queued on the named channel and
+// not yet taken by a subscriber.
+func (m *Memory) Pending(channel string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q, ok := m.queues[channel]; ok {
+		return len(q.messages)
+	}
+	return 0
+}
+
+// Close stops subscribers and rejects further publishes.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	return nil
+}
+
+// next takes the first queued message of channel. When there is none it
+// returns a channel that is closed once one is queued.
+func (m *Memory) next(channel string) (Message, <-chan struct{}, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return Message{}, nil, false, ErrMemoryClosed
+	}
+
+	q := m.queueLocked(channel)
+	if len(q.messages) == 0 {
+		return Message{}, q.ready, false, nil
+	}
+	message := q.messages[0]
+	q.messages = q.messages[1:]
+	return message, nil, true, nil
+}
+
+func (m *Memory) enqueueLocked(channel string, message Message) {
+	q := m.queueLocked(channel)
+	q.messages = append(q.messages, message)
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+func (m *Memory) queueLocked(channel string) *memoryQueue {
+	q, ok := m.queues[channel]
+	if !ok {
+		q = &memoryQueue{ready: make(chan struct{})}
+		m.queues[channel] = q
+	}
+	return q
+}
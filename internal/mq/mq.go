@@ -1,6 +1,12 @@
 package mq
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/config"
+)
 
 // Message represents a broker-agnostic payload delivered to subscribers.
 type Message struct {
@@ -9,6 +15,15 @@ type Message struct {
 	Attributes map[string]string
 }
 
+// AttrPriority is the message attribute carrying a delivery priority from 0
+// (lowest) to 9. Backends that support priorities deliver higher values
+// first; others ignore it.
+const AttrPriority = "priority"
+
+// AttrContentType is the message attribute naming the payload encoding,
+// e.g. "application/json". Consumers treat messages without it as JSON.
+const AttrContentType = "content-type"
+
 // Handler processes a message. Return an error to signal a retry/nack.
 type Handler func(ctx context.Context, msg Message) error
 
@@ -29,6 +44,26 @@ func New(backend Backend) *MQ {
 	return &MQ{backend: backend}
 }
 
+// NewFromConfig constructs an MQ backed by the broker selected in config.
+func NewFromConfig(ctx context.Context, cfg config.Config) (*MQ, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.MQBackend)) {
+	case "rabbitmq":
+		backend, err := NewRabbitMQClient(cfg.RabbitMQ)
+		if err != nil {
+			return nil, err
+		}
+		return New(backend), nil
+	case "pubsub":
+		backend, err := NewPubSubClient(ctx, cfg.PubSub)
+		if err != nil {
+			return nil, err
+		}
+		return New(backend), nil
+	default:
+		return nil, fmt.Errorf("unsupported mq backend: %s", cfg.MQBackend)
+	}
+}
+
 // Publish sends a message to the named channel.
 func (m *MQ) Publish(ctx context.Context, channel string, data []byte, attrs map[string]string) (string, error) {
 	return m.backend.Publish(ctx, channel, data, attrs)
@@ -12,8 +12,9 @@ import (
 
 // PubSubClient wraps the Google Cloud Pub/Sub SDK client.
 type PubSubClient struct {
-	client               *pubsub.Client
-	subscriptionSuffix   string
+	client             *pubsub.Client
+	subscriptionSuffix string
+	concurrency        int
 }
 
 // NewPubSubClient constructs a Pub/Sub client from config.
@@ -40,6 +41,7 @@ func NewPubSubClient(ctx context.Context, cfg config.PubSubConfig) (*PubSubClien
 	return &PubSubClient{
 		client:             client,
 		subscriptionSuffix: suffix,
+		concurrency:        max(cfg.ConsumerConcurrency, 1),
 	}, nil
 }
 
@@ -57,7 +59,9 @@ func (p *PubSubClient) Publish(ctx context.Context, channel string, data []byte,
 	return result.Get(ctx)
 }
 
-// Subscribe consumes messages from the named channel.
+// Subscribe consumes messages from the named channel. Up to the configured
+// consumer concurrency of messages are handled in parallel, each acked or
+// nacked on its own.
 func (p *PubSubClient) Subscribe(ctx context.Context, channel string, handler Handler) error {
 	if strings.TrimSpace(channel) == "" {
 		return errors.New("pubsub channel is required")
@@ -74,6 +78,9 @@ func (p *PubSubClient) Subscribe(ctx context.Context, channel string, handler Ha
 		return err
 	}
 
+	// Receive runs callbacks concurrently up to the outstanding message
+	// limit, so bounding it bounds the handlers in flight.
+	sub.ReceiveSettings.MaxOutstandingMessages = p.concurrency
 	return sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
 		message := Message{
 			ID:         msg.ID,
@@ -6,110 +6,302 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
+	"net"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jjudge-oj/apiserver/config"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// RabbitMQClient wraps a RabbitMQ connection/channel pair.
+// Reconnection backoff bounds. Failed dials and broken consumers are
+// retried with exponential backoff between these delays.
+const (
+	rabbitMinBackoff = 500 * time.Millisecond
+	rabbitMaxBackoff = 30 * time.Second
+)
+
+// rabbitPublishAttempts bounds how often Publish retries on a broken
+// connection before giving up.
+const rabbitPublishAttempts = 3
+
+// ErrRabbitMQClosed is returned by operations on a closed client.
+var ErrRabbitMQClosed = errors.New("rabbitmq client is closed")
+
+// Errors returned by Publish when the broker did not take responsibility
+// for a message.
+var (
+	// ErrMessageUnroutable means the message was returned because no queue
+	// was bound to receive it.
+	ErrMessageUnroutable = errors.New("rabbitmq message is unroutable")
+
+	// ErrMessageNacked means the broker refused the message, e.g. because
+	// the queue is full.
+	ErrMessageNacked = errors.New("rabbitmq message was not acknowledged")
+)
+
+// RabbitMQClient wraps a RabbitMQ connection. The connection and the
+// publishing channel are re-created on demand after the broker goes away,
+// and consumers started with Subscribe re-subscribe on their own.
 type RabbitMQClient struct {
-	conn            *amqp.Connection
-	channel         *amqp.Channel
+	url             string
 	queueDurable    bool
 	queueAutoDelete bool
 	prefetchCount   int
+	maxPriority     int
+	concurrency     int
+
+	mu        sync.Mutex
+	conn      *amqp.Connection
+	publisher *rabbitPublisher
+	closed    bool
+	done      chan struct{}
 }
 
-// NewRabbitMQClient constructs a RabbitMQ client from config.
+// rabbitPublisher is a channel in confirm mode. Publishes on it are
+// serialized so that a returned message can be matched to the publish
+// that is waiting for its confirmation.
+type rabbitPublisher struct {
+	mu      sync.Mutex
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
+// NewRabbitMQClient constructs a RabbitMQ client from config. The broker
+// must be reachable at construction time; later outages are recovered
+// from.
 func NewRabbitMQClient(cfg config.RabbitMQConfig) (*RabbitMQClient, error) {
 	if strings.TrimSpace(cfg.URL) == "" {
 		return nil, errors.New("rabbitmq url is required")
 	}
 
-	conn, err := amqp.Dial(cfg.URL)
-	if err != nil {
-		return nil, err
-	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		_ = conn.Close()
-		return nil, err
-	}
-
-	if cfg.PrefetchCount > 0 {
-		if err := ch.Qos(cfg.PrefetchCount, 0, false); err != nil {
-			_ = ch.Close()
-			_ = conn.Close()
-			return nil, err
-		}
-	}
-
-	return &RabbitMQClient{
-		conn:            conn,
-		channel:         ch,
+	client := &RabbitMQClient{
+		url:             cfg.URL,
 		queueDurable:    cfg.QueueDurable,
 		queueAutoDelete: cfg.QueueAutoDelete,
 		prefetchCount:   cfg.PrefetchCount,
-	}, nil
+		maxPriority:     cfg.MaxPriority,
+		concurrency:     max(cfg.ConsumerConcurrency, 1),
+		done:            make(chan struct{}),
+	}
+	if _, err := client.currentPublisher(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return client, nil
 }
 
-// Publish sends a message to the named queue.
+// Publish sends a message to the named queue and waits until the broker
+// confirms it has taken responsibility for it. Unroutable and rejected
+// messages are reported as errors. If the connection turns out to be broken
+// the message is retried on a fresh one, so it may be delivered more than
+// once.
 func (r *RabbitMQClient) Publish(ctx context.Context, channel string, data []byte, attrs map[string]string) (string, error) {
 	if strings.TrimSpace(channel) == "" {
 		return "", errors.New("rabbitmq channel is required")
 	}
 
-	if _, err := r.declareQueue(channel); err != nil {
-		return "", err
-	}
-
 	headers := amqp.Table{}
+	contentType := "application/octet-stream"
+	var priority uint8
 	for key, value := range attrs {
+		if key == AttrContentType {
+			contentType = value
+		}
 		headers[key] = value
+		if key == AttrPriority {
+			if p, err := strconv.ParseUint(value, 10, 8); err == nil {
+				priority = uint8(min(p, 9))
+			}
+		}
 	}
 
 	messageID := newMessageID()
-	err := r.channel.PublishWithContext(ctx, "", channel, false, false, amqp.Publishing{
-		ContentType: "application/octet-stream",
+	publishing := amqp.Publishing{
+		ContentType: contentType,
 		MessageId:   messageID,
 		Headers:     headers,
+		Priority:    priority,
 		Body:        data,
-	})
+	}
+
+	var err error
+	backoff := rabbitMinBackoff
+	for attempt := 1; ; attempt++ {
+		err = r.publish(ctx, channel, publishing)
+		if err == nil || !isConnectionError(err) || attempt == rabbitPublishAttempts {
+			break
+		}
+		if !r.sleep(ctx, backoff) {
+			return "", r.stopErr(ctx)
+		}
+		backoff = nextBackoff(backoff)
+	}
 	if err != nil {
 		return "", err
 	}
 	return messageID, nil
 }
 
-// Subscribe consumes messages from the named queue.
+func (r *RabbitMQClient) publish(ctx context.Context, queue string, publishing amqp.Publishing) error {
+	p, err := r.currentPublisher()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := r.declareQueue(p.ch, queue); err != nil {
+		r.resetPublisher(p)
+		return err
+	}
+
+	// Returns left over from publishes that gave up waiting belong to
+	// other messages.
+	p.drainReturns()
+	confirmation, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, true, false, publishing)
+	if err != nil {
+		r.resetPublisher(p)
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	// The broker sends basic.return before the confirmation, so a return
+	// for this message is already buffered once the confirmation arrived.
+	for _, returned := range p.drainReturns() {
+		if returned.MessageId == publishing.MessageId {
+			return fmt.Errorf("%w: %s", ErrMessageUnroutable, returned.ReplyText)
+		}
+	}
+	if !acked {
+		// Pending confirmations are nacked when the channel closes.
+		if p.ch.IsClosed() {
+			r.resetPublisher(p)
+			return amqp.ErrClosed
+		}
+		return ErrMessageNacked
+	}
+	return nil
+}
+
+// drainReturns empties the returned-messages buffer without blocking.
+func (p *rabbitPublisher) drainReturns() []amqp.Return {
+	var returned []amqp.Return
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return returned
+			}
+			returned = append(returned, ret)
+		default:
+			return returned
+		}
+	}
+}
+
+// Subscribe consumes messages from the named queue until ctx is cancelled
+// or the client is closed. Up to the configured consumer concurrency of
+// messages are handled in parallel, each acked or nacked on its own. When the connection or channel breaks, it
+// reconnects with backoff and consumes again; unacknowledged deliveries
+// are redelivered by the broker.
 func (r *RabbitMQClient) Subscribe(ctx context.Context, channel string, handler Handler) error {
 	if strings.TrimSpace(channel) == "" {
 		return errors.New("rabbitmq channel is required")
 	}
 
-	if _, err := r.declareQueue(channel); err != nil {
-		return err
+	backoff := rabbitMinBackoff
+	for {
+		consumed, err := r.consume(ctx, channel, handler)
+		if ctx.Err() != nil || r.isClosed() {
+			return r.stopErr(ctx)
+		}
+		if consumed {
+			backoff = rabbitMinBackoff
+		}
+		log.Printf("rabbitmq: consumer of %s interrupted, retrying in %s: %v", channel, backoff, err)
+		if !r.sleep(ctx, backoff) {
+			return r.stopErr(ctx)
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// consume runs one consumer session on a dedicated channel, handling up to
+// the configured concurrency of deliveries in parallel. consumed reports
+// whether any delivery was received, so Subscribe can reset its backoff
+// after a healthy session.
+func (r *RabbitMQClient) consume(ctx context.Context, queue string, handler Handler) (consumed bool, err error) {
+	conn, err := r.connection()
+	if err != nil {
+		return false, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	// A prefetch below the concurrency would leave workers idle.
+	if prefetch := r.prefetchCount; prefetch > 0 {
+		if err := ch.Qos(max(prefetch, r.concurrency), 0, false); err != nil {
+			return false, err
+		}
+	}
+	if _, err := r.declareQueue(ch, queue); err != nil {
+		return false, err
 	}
 
 	consumerTag := fmt.Sprintf("consumer-%s", newMessageID())
-	deliveries, err := r.channel.Consume(channel, consumerTag, false, false, false, false, nil)
+	deliveries, err := ch.Consume(queue, consumerTag, false, false, false, false, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer func() {
-		_ = r.channel.Cancel(consumerTag, false)
+		_ = ch.Cancel(consumerTag, false)
 	}()
 
+	// Workers stop together: the first one to return ends the session and
+	// its error explains why. Handlers already running are waited for so
+	// their deliveries are acked or nacked before the channel closes.
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var received atomic.Bool
+	errs := make(chan error, r.concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < r.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- r.work(ctx, sessionCtx, deliveries, handler, &received)
+		}()
+	}
+	err = <-errs
+	cancel()
+	wg.Wait()
+	return received.Load(), err
+}
+
+// work handles deliveries one at a time until the session ends. Handlers
+// run with ctx, so ending the session does not cancel them.
+func (r *RabbitMQClient) work(ctx, sessionCtx context.Context, deliveries <-chan amqp.Delivery, handler Handler, received *atomic.Bool) error {
 	for {
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
+		case <-sessionCtx.Done():
+			return sessionCtx.Err()
+		case <-r.done:
+			return ErrRabbitMQClosed
 		case delivery, ok := <-deliveries:
 			if !ok {
 				return errors.New("rabbitmq delivery channel closed")
 			}
+			received.Store(true)
 			message := Message{
 				ID:         delivery.MessageId,
 				Data:       delivery.Body,
@@ -124,25 +316,156 @@ func (r *RabbitMQClient) Subscribe(ctx context.Context, channel string, handler
 	}
 }
 
-// Close closes the underlying channel and connection.
+// Close closes the underlying channel and connection and stops active
+// consumers.
 func (r *RabbitMQClient) Close() error {
-	if r.channel != nil {
-		_ = r.channel.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	if r.publisher != nil {
+		_ = r.publisher.ch.Close()
+		r.publisher = nil
 	}
 	if r.conn != nil {
-		return r.conn.Close()
+		err := r.conn.Close()
+		r.conn = nil
+		return err
 	}
 	return nil
 }
 
-func (r *RabbitMQClient) declareQueue(name string) (amqp.Queue, error) {
-	return r.channel.QueueDeclare(
+// connection returns the open connection, dialing a new one if the
+// previous one was closed.
+func (r *RabbitMQClient) connection() (*amqp.Connection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connectionLocked()
+}
+
+func (r *RabbitMQClient) connectionLocked() (*amqp.Connection, error) {
+	if r.closed {
+		return nil, ErrRabbitMQClosed
+	}
+	if r.conn != nil && !r.conn.IsClosed() {
+		return r.conn, nil
+	}
+
+	conn, err := amqp.Dial(r.url)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+	r.publisher = nil
+	return conn, nil
+}
+
+// currentPublisher returns the publishing channel, re-creating it (and the
+// connection) when it was closed.
+func (r *RabbitMQClient) currentPublisher() (*rabbitPublisher, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conn, err := r.connectionLocked()
+	if err != nil {
+		return nil, err
+	}
+	if r.publisher != nil && !r.publisher.ch.IsClosed() {
+		return r.publisher, nil
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	// The buffer leaves room for returns of abandoned publishes; a full
+	// buffer would stall the channel until the next publish drains it.
+	returns := ch.NotifyReturn(make(chan amqp.Return, 16))
+	r.publisher = &rabbitPublisher{ch: ch, returns: returns}
+	return r.publisher, nil
+}
+
+// resetPublisher drops p as the publishing channel so the next publish
+// opens a fresh one. Errors on a channel close it on the broker side.
+func (r *RabbitMQClient) resetPublisher(p *rabbitPublisher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.publisher == p {
+		_ = p.ch.Close()
+		r.publisher = nil
+	}
+}
+
+func (r *RabbitMQClient) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// sleep waits for d, returning false if ctx is cancelled or the client is
+// closed first.
+func (r *RabbitMQClient) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *RabbitMQClient) stopErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrRabbitMQClosed
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	return min(2*d, rabbitMaxBackoff)
+}
+
+// isConnectionError reports whether err was caused by a closed connection
+// or channel, as opposed to the broker rejecting the operation itself.
+func isConnectionError(err error) bool {
+	if errors.Is(err, amqp.ErrClosed) {
+		return true
+	}
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Recover || amqpErr.Code == amqp.ConnectionForced || amqpErr.Code == amqp.ChannelError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// declareQueue declares the named queue. When a max priority is configured
+// the queue is declared as a priority queue; RabbitMQ rejects redeclaring an
+// existing queue with different arguments, so changing the setting requires
+// recreating the queue.
+func (r *RabbitMQClient) declareQueue(ch *amqp.Channel, name string) (amqp.Queue, error) {
+	var args amqp.Table
+	if r.maxPriority > 0 {
+		args = amqp.Table{"x-max-priority": int32(min(r.maxPriority, 255))}
+	}
+	return ch.QueueDeclare(
 		name,
 		r.queueDurable,
 		r.queueAutoDelete,
 		false,
 		false,
-		nil,
+		args,
 	)
 }
 
@@ -0,0 +1,294 @@
+// Package seed loads fixture problems and demo users for local development.
+package seed
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// objectKeyPrefix is where generated bundles are uploaded.
+const objectKeyPrefix = "seed/problems"
+
+// Result summarizes what a seed run created and skipped.
+type Result struct {
+	ProblemsCreated []string
+	ProblemsSkipped []string
+	UsersCreated    []string
+	UsersSkipped    []string
+}
+
+// Seeder creates fixture data through the regular services so events and
+// validation behave exactly as they do for API requests.
+type Seeder struct {
+	problems *services.ProblemService
+	users    *services.UserService
+	storage  *storage.Storage
+}
+
+// New constructs a Seeder.
+func New(problems *services.ProblemService, users *services.UserService, objectStorage *storage.Storage) *Seeder {
+	return &Seeder{problems: problems, users: users, storage: objectStorage}
+}
+
+// Run creates the fixture users and problems. Users are matched by username
+// and problems by title, so running it again only fills in what is missing.
+// Every demo user gets the given password.
+func (s *Seeder) Run(ctx context.Context, password string) (Result, error) {
+	var result Result
+	if password == "" {
+		return result, errors.New("password is required")
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return result, err
+	}
+	for _, user := range demoUsers {
+		_, err := s.users.GetByUsername(ctx, user.Username)
+		if err == nil {
+			result.UsersSkipped = append(result.UsersSkipped, user.Username)
+			continue
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return result, fmt.Errorf("check user %s: %w", user.Username, err)
+		}
+		user.PasswordHash = string(hashed)
+		if _, err := s.users.Create(ctx, user); err != nil {
+			return result, fmt.Errorf("create user %s: %w", user.Username, err)
+		}
+		result.UsersCreated = append(result.UsersCreated, user.Username)
+	}
+
+	existing, err := s.problemTitles(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, fixture := range fixtureProblems {
+		if existing[fixture.problem.Title] {
+			result.ProblemsSkipped = append(result.ProblemsSkipped, fixture.problem.Title)
+			continue
+		}
+		if err := s.createProblem(ctx, fixture); err != nil {
+			return result, fmt.Errorf("create problem %q: %w", fixture.problem.Title, err)
+		}
+		result.ProblemsCreated = append(result.ProblemsCreated, fixture.problem.Title)
+	}
+
+	return result, nil
+}
+
+func (s *Seeder) createProblem(ctx context.Context, fixture problemFixture) error {
+	groups := make([]types.TestcaseGroup, len(fixture.groups))
+	copy(groups, fixture.groups)
+
+	data, err := buildBundle(fixture)
+	if err != nil {
+		return err
+	}
+	key := path.Join(objectKeyPrefix, fixture.slug+".tar.gz")
+	bundle, err := s.problems.GetTestcaseBundleFromArchive(fixture.problem.Type, key, data, groups)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/gzip"); err != nil {
+		return fmt.Errorf("upload bundle: %w", err)
+	}
+
+	problem := fixture.problem
+	problem.TestcaseBundle = bundle
+	_, err = s.problems.Create(ctx, problem)
+	return err
+}
+
+func (s *Seeder) problemTitles(ctx context.Context) (map[string]bool, error) {
+	const pageSize = 100
+	titles := make(map[string]bool)
+	for offset := 0; ; offset += pageSize {
+		problems, total, err := s.problems.List(ctx, offset, pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list problems: %w", err)
+		}
+		for _, problem := range problems {
+			titles[problem.Title] = true
+		}
+		if len(problems) == 0 || offset+len(problems) >= total {
+			return titles, nil
+		}
+	}
+}
+
+// buildBundle renders the fixture's generated testcases as a tar.gz archive
+// in the <group>_<case>.in/.out layout the bundle validator expects.
+func buildBundle(fixture problemFixture) ([]byte, error) {
+	// Seed from the slug so every run produces identical bundles.
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fixture.slug))
+	rng := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	modTime := time.Unix(0, 0)
+
+	for groupOrder, count := range fixture.casesPerGroup {
+		for caseOrder := 0; caseOrder < count; caseOrder++ {
+			input, output := fixture.generate(rng, groupOrder)
+			name := fmt.Sprintf("%d_%d", groupOrder, caseOrder)
+			for _, file := range []struct {
+				ext  string
+				body string
+			}{{"in", input}, {"out", output}} {
+				header := &tar.Header{
+					Name:    name + "." + file.ext,
+					Mode:    0o644,
+					Size:    int64(len(file.body)),
+					ModTime: modTime,
+				}
+				if err := tw.WriteHeader(header); err != nil {
+					return nil, err
+				}
+				if _, err := tw.Write([]byte(file.body)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type problemFixture struct {
+	slug    string
+	problem types.Problem
+	groups  []types.TestcaseGroup
+	// casesPerGroup is the number of generated testcases for each group.
+	casesPerGroup []int
+	// generate returns one input/output pair for the given group.
+	generate func(rng *rand.Rand, group int) (string, string)
+}
+
+var demoUsers = []types.User{
+	{Username: "admin", Email: "admin@example.com", Name: "Demo Admin", Role: "admin"},
+	{Username: "alice", Email: "alice@example.com", Name: "Alice Example", Role: "user"},
+	{Username: "bob", Email: "bob@example.com", Name: "Bob Example", Role: "user"},
+}
+
+var fixtureProblems = []problemFixture{
+	{
+		slug: "a-plus-b",
+		problem: types.Problem{
+			Title:       "A + B",
+			Description: "Read two integers a and b and print a + b.\n\nInput: a single line with two integers -10^9 <= a, b <= 10^9.\nOutput: their sum.",
+			Type:        types.ProblemTypeBatch,
+			Difficulty:  800,
+			TimeLimit:   1000,
+			MemoryLimit: 256 << 20,
+			Tags:        []string{"implementation", "math"},
+		},
+		groups: []types.TestcaseGroup{
+			{OrderID: 0, Name: "samples", Points: 0},
+			{OrderID: 1, Name: "main", Points: 100},
+		},
+		casesPerGroup: []int{1, 5},
+		generate: func(rng *rand.Rand, group int) (string, string) {
+			limit := int64(10)
+			if group > 0 {
+				limit = 1_000_000_000
+			}
+			a := rng.Int63n(2*limit+1) - limit
+			b := rng.Int63n(2*limit+1) - limit
+			return fmt.Sprintf("%d %d\n", a, b), fmt.Sprintf("%d\n", a+b)
+		},
+	},
+	{
+		slug: "reverse-string",
+		problem: types.Problem{
+			Title:       "Reverse String",
+			Description: "Print the given lowercase string in reverse.\n\nInput: a single string of 1 to 10^5 lowercase letters.\nOutput: the reversed string.",
+			Type:        types.ProblemTypeBatch,
+			Difficulty:  800,
+			TimeLimit:   1000,
+			MemoryLimit: 256 << 20,
+			Tags:        []string{"strings", "implementation"},
+		},
+		groups: []types.TestcaseGroup{
+			{OrderID: 0, Name: "samples", Points: 0},
+			{OrderID: 1, Name: "main", Points: 100},
+		},
+		casesPerGroup: []int{1, 5},
+		generate: func(rng *rand.Rand, group int) (string, string) {
+			n := 5
+			if group > 0 {
+				n = 1 + rng.Intn(100_000)
+			}
+			letters := make([]byte, n)
+			for i := range letters {
+				letters[i] = byte('a' + rng.Intn(26))
+			}
+			reversed := make([]byte, n)
+			for i, c := range letters {
+				reversed[n-1-i] = c
+			}
+			return string(letters) + "\n", string(reversed) + "\n"
+		},
+	},
+	{
+		slug: "maximum-subarray",
+		problem: types.Problem{
+			Title:       "Maximum Subarray",
+			Description: "Given an array of n integers, print the largest sum of a non-empty contiguous subarray.\n\nInput: n (1 <= n <= 2*10^5) on the first line, then n integers with absolute value at most 10^9.\nOutput: the maximum subarray sum.",
+			Type:        types.ProblemTypeBatch,
+			Difficulty:  1200,
+			TimeLimit:   1000,
+			MemoryLimit: 256 << 20,
+			Tags:        []string{"dp", "greedy"},
+		},
+		groups: []types.TestcaseGroup{
+			{OrderID: 0, Name: "samples", Points: 0},
+			{OrderID: 1, Name: "small", Points: 30},
+			{OrderID: 2, Name: "large", Points: 70},
+		},
+		casesPerGroup: []int{1, 4, 4},
+		generate: func(rng *rand.Rand, group int) (string, string) {
+			n := []int{6, 100, 50_000}[group]
+			limit := []int64{10, 1000, 1_000_000_000}[group]
+			values := make([]string, n)
+			var best, current int64
+			for i := 0; i < n; i++ {
+				v := rng.Int63n(2*limit+1) - limit
+				values[i] = strconv.FormatInt(v, 10)
+				if i == 0 || current < 0 {
+					current = v
+				} else {
+					current += v
+				}
+				if i == 0 || current > best {
+					best = current
+				}
+			}
+			return fmt.Sprintf("%d\n%s\n", n, strings.Join(values, " ")), fmt.Sprintf("%d\n", best)
+		},
+	},
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/handlers"
+	"github.com/jjudge-oj/apiserver/internal/services"
+)
+
+// configPollInterval is how often the runtime config file is checked for
+// changes.
+const configPollInterval = 5 * time.Second
+
+// runtimeSettings applies reloadable settings to the running server. They
+// are reloaded on SIGHUP, when the CONFIG_FILE changes and through the
+// admin API.
+type runtimeSettings struct {
+	abuse    *services.AbuseDetector
+	cors     *handlers.CORS
+	features *handlers.FeatureFlags
+	logLevel *handlers.LogLevel
+
+	mu      sync.Mutex
+	current config.RuntimeConfig
+}
+
+func newRuntimeSettings(cfg config.RuntimeConfig, abuse *services.AbuseDetector) *runtimeSettings {
+	return &runtimeSettings{
+		abuse:    abuse,
+		cors:     handlers.NewCORS(cfg.CORSAllowedOrigins),
+		features: handlers.NewFeatureFlags(cfg.FeatureFlags),
+		logLevel: handlers.NewLogLevel(cfg.LogLevel),
+		current:  cfg,
+	}
+}
+
+// Reload reads the runtime config and applies it. On error the running
+// settings are kept.
+func (s *runtimeSettings) Reload() error {
+	cfg, err := config.LoadRuntimeConfig()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.DeepEqual(cfg, s.current) {
+		return nil
+	}
+	s.abuse.SetPolicy(abusePolicy(cfg.Abuse))
+	s.cors.SetOrigins(cfg.CORSAllowedOrigins)
+	s.features.Set(cfg.FeatureFlags)
+	s.logLevel.Set(cfg.LogLevel)
+	s.current = cfg
+	log.Printf("config: reloaded runtime settings")
+	return nil
+}
+
+// Watch reloads the settings on SIGHUP and when the config file at path,
+// if any, is modified, until ctx is cancelled.
+func (s *runtimeSettings) Watch(ctx context.Context, path string) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var poll <-chan time.Time
+	var modTime time.Time
+	if path != "" {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+		modTime = fileModTime(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-poll:
+			latest := fileModTime(path)
+			if latest.Equal(modTime) {
+				continue
+			}
+			modTime = latest
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("config: failed to reload, keeping the running settings: %v", err)
+		}
+	}
+}
+
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func abusePolicy(cfg config.AbuseConfig) services.AbusePolicy {
+	return services.AbusePolicy{
+		Window:        time.Duration(cfg.WindowSeconds) * time.Second,
+		MaxRequests:   cfg.MaxRequests,
+		MaxErrorRatio: float64(cfg.MaxErrorPercent) / 100,
+		MinRequests:   cfg.MinRequests,
+		BlockDuration: time.Duration(cfg.BlockSeconds) * time.Second,
+	}
+}
@@ -2,9 +2,14 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -15,8 +20,18 @@ import (
 	"github.com/jjudge-oj/apiserver/config"
 	"github.com/jjudge-oj/apiserver/internal/db"
 	"github.com/jjudge-oj/apiserver/internal/handlers"
+	"github.com/jjudge-oj/apiserver/internal/jobs"
+	"github.com/jjudge-oj/apiserver/internal/judgerpc"
+	"github.com/jjudge-oj/apiserver/internal/ldap"
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/metrics"
+	"github.com/jjudge-oj/apiserver/internal/mq"
 	"github.com/jjudge-oj/apiserver/internal/services"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/storagegc"
 	"github.com/jjudge-oj/apiserver/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Server wraps the HTTP server and router.
@@ -24,8 +39,22 @@ type Server struct {
 	httpServer *http.Server
 	router     *chi.Mux
 	db         *sql.DB
+	replica    *sql.DB
+	queue      *mq.MQ
+	stop       context.CancelFunc
+	tls        config.TLSConfig
+	grpcServer *grpc.Server
+	grpcAddr   string
 }
 
+// replicaCheckInterval is how often the read replica is pinged to take it
+// out of, or back into, rotation.
+const replicaCheckInterval = 10 * time.Second
+
+// abuseSyncInterval is how often blocks made by other servers, and lifted
+// by admins through them, are picked up.
+const abuseSyncInterval = 10 * time.Second
+
 // New constructs a Server with basic middleware and defaults.
 func New(ctx context.Context, cfg config.Config) (*Server, error) {
 	dbConn, err := db.Open(ctx, cfg)
@@ -33,34 +62,313 @@ func New(ctx context.Context, cfg config.Config) (*Server, error) {
 		return nil, err
 	}
 
-	problemRepo := store.NewProblemRepository(dbConn)
-	userRepo := store.NewUserRepository(dbConn)
+	objectStorage, err := storage.NewFromConfig(ctx, cfg)
+	if err != nil {
+		_ = dbConn.Close()
+		return nil, err
+	}
 
-	problemService := services.NewProblemService(problemRepo)
-	userService := services.NewUserService(userRepo)
+	// The message queue is optional; without it domain events are only
+	// written to the event log table.
+	var queue *mq.MQ
+	if strings.TrimSpace(cfg.MQBackend) != "" {
+		queue, err = mq.NewFromConfig(ctx, cfg)
+		if err != nil {
+			_ = dbConn.Close()
+			return nil, err
+		}
+	}
 
-	jwtSecret := strings.TrimSpace(os.Getenv("JWT_SECRET"))
-	if jwtSecret == "" {
+	mail, err := mailer.NewFromConfig(cfg.Mail)
+	if err != nil {
+		if queue != nil {
+			_ = queue.Close()
+		}
 		_ = dbConn.Close()
-		return nil, errors.New("JWT_SECRET is required")
+		return nil, err
+	}
+
+	replica, err := db.OpenReplica(cfg)
+	if err != nil {
+		if queue != nil {
+			_ = queue.Close()
+		}
+		_ = dbConn.Close()
+		return nil, err
+	}
+
+	return NewWithBackends(ctx, cfg, Backends{
+		DB:      dbConn,
+		Replica: replica,
+		Storage: objectStorage,
+		Queue:   queue,
+		Mailer:  mail,
+	})
+}
+
+// Backends are the external systems a Server depends on. New opens them
+// from config; NewWithBackends accepts them ready-made, e.g. in-memory
+// implementations in tests.
+type Backends struct {
+	DB *sql.DB
+	// Replica is optional; without it every read goes to DB.
+	Replica *sql.DB
+	Storage *storage.Storage
+	// Queue is optional; without it domain events are only written to the
+	// event log table and judge jobs are not published.
+	Queue  *mq.MQ
+	Mailer mailer.Mailer
+}
+
+func (b Backends) close() {
+	if b.Queue != nil {
+		_ = b.Queue.Close()
+	}
+	if b.Replica != nil {
+		_ = b.Replica.Close()
+	}
+	_ = b.DB.Close()
+}
+
+// NewWithBackends constructs a Server on top of the given backends. The
+// server takes ownership of the database and the queue: they are closed on
+// Shutdown, or right away if construction fails.
+func NewWithBackends(ctx context.Context, cfg config.Config, backends Backends) (*Server, error) {
+	dbConn := backends.DB
+	objectStorage := backends.Storage
+	queue := backends.Queue
+	mail := backends.Mailer
+
+	judgeContentType, err := services.JudgeContentType(cfg.Judge.MessageEncoding)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+
+	var replica *store.Replica
+	if backends.Replica != nil {
+		replica = store.NewReplica(backends.Replica)
+	}
+
+	problemRepo := store.NewProblemRepository(dbConn).WithReplica(replica)
+	userRepo := store.NewUserRepository(dbConn)
+	announcementRepo := store.NewAnnouncementRepository(dbConn)
+	postRepo := store.NewPostRepository(dbConn)
+	problemListRepo := store.NewProblemListRepository(dbConn)
+	recommendationRepo := store.NewRecommendationRepository(dbConn)
+	sessionRepo := store.NewSessionRepository(dbConn)
+	submissionRepo := store.NewSubmissionRepository(dbConn).WithReplica(replica)
+	appealRepo := store.NewAppealRepository(dbConn)
+	eventRepo := store.NewEventRepository(dbConn)
+	leaderboardRepo := store.NewLeaderboardRepository(dbConn).WithReplica(replica)
+	judgeWorkerRepo := store.NewJudgeWorkerRepository(dbConn)
+	runRepo := store.NewRunRepository(dbConn)
+	validationRepo := store.NewProblemValidationRepository(dbConn)
+	bundleUploadRepo := store.NewBundleUploadRepository(dbConn)
+	bundleIntegrityRepo := store.NewBundleIntegrityRepository(dbConn)
+	problemReviewRepo := store.NewProblemReviewRepository(dbConn)
+	loginThrottleRepo := store.NewLoginThrottleRepository(dbConn)
+	jobRepo := store.NewJobRepository(dbConn)
+	overviewRepo := store.NewOverviewRepository(dbConn)
+	notificationRepo := store.NewNotificationRepository(dbConn)
+	passwordResetRepo := store.NewPasswordResetRepository(dbConn)
+	problemCommentRepo := store.NewProblemCommentRepository(dbConn)
+	contentReportRepo := store.NewContentReportRepository(dbConn)
+	abuseBlockRepo := store.NewAbuseBlockRepository(dbConn)
+	tenantRepo := store.NewTenantRepository(dbConn)
+
+	jobRunner := jobs.New(jobRepo, jobs.Options{
+		Workers:      cfg.Jobs.Workers,
+		PollInterval: time.Duration(cfg.Jobs.PollSeconds) * time.Second,
+		Retention:    time.Duration(cfg.Jobs.RetentionSeconds) * time.Second,
+	})
+
+	judgeLatency := services.NewJudgeLatencyHistogram()
+	tenantService := services.NewTenantService(tenantRepo)
+	eventService := services.NewEventService(eventRepo, queue, cfg.Events.Channel)
+	problemService := services.NewProblemService(problemRepo, eventService)
+	userService := services.NewUserService(userRepo, eventService)
+	judgeService := services.NewJudgeService(problemRepo, objectStorage)
+	announcementService := services.NewAnnouncementService(announcementRepo)
+	postService := services.NewPostService(postRepo)
+	problemListService := services.NewProblemListService(problemListRepo)
+	recommendationService := services.NewRecommendationService(recommendationRepo, store.RecommendationParams{})
+	sessionService := services.NewSessionService(sessionRepo, cfg.Auth.MaxSessionsPerUser)
+	loginThrottleService := services.NewLoginThrottleService(loginThrottleRepo, userService, mail, services.LoginThrottlePolicy{
+		Backoff:          time.Duration(cfg.Auth.LoginBackoffSeconds) * time.Second,
+		LockoutThreshold: cfg.Auth.LockoutThreshold,
+		LockoutDuration:  time.Duration(cfg.Auth.LockoutSeconds) * time.Second,
+		UnlockURL:        cfg.Auth.UnlockURL,
+	})
+	judgeDispatcher := services.NewJudgeDispatcher(judgeWorkerRepo, cfg.Judge.QueueChannel, time.Duration(cfg.Judge.WorkerTTLSeconds)*time.Second)
+	judgeQueue := services.NewJudgeQueue(queue, judgeDispatcher, judgeContentType)
+	submissionService := services.NewSubmissionService(submissionRepo, eventService, judgeQueue, objectStorage)
+	if cfg.Judge.StaticChecksFile != "" {
+		rules, err := os.ReadFile(cfg.Judge.StaticChecksFile)
+		if err != nil {
+			backends.close()
+			return nil, fmt.Errorf("read static check rules: %w", err)
+		}
+		checker, err := services.ParseStaticCheckRules(rules)
+		if err != nil {
+			backends.close()
+			return nil, fmt.Errorf("parse static check rules: %w", err)
+		}
+		submissionService.WithCheckers(checker)
+	}
+	runService := services.NewRunService(runRepo, judgeQueue)
+	validationService := services.NewProblemValidationService(problemRepo, validationRepo, judgeQueue)
+	generationService := services.NewTestcaseGenerationService(problemService, objectStorage, judgeQueue, validationService)
+	bundleUploadService := services.NewBundleUploadService(bundleUploadRepo, problemService, generationService, objectStorage, jobRunner)
+	bundleIntegrityService := services.NewBundleIntegrityService(bundleIntegrityRepo, objectStorage)
+	problemReviewService := services.NewProblemReviewService(problemReviewRepo, problemService)
+	userImportService := services.NewUserImportService(userService, mail)
+	leaderboardService := services.NewLeaderboardService(leaderboardRepo)
+	overviewService := services.NewOverviewService(overviewRepo, submissionService)
+	exportService := services.NewExportService(problemRepo, submissionRepo)
+	abuseDetector := services.NewAbuseDetector(abuseBlockRepo, abusePolicy(cfg.Runtime.Abuse))
+	settings := newRuntimeSettings(cfg.Runtime, abuseDetector)
+	accountEmailService := services.NewAccountEmailService(passwordResetRepo, userService, sessionService, mail, jobRunner, services.AccountEmailPolicy{
+		PasswordResetTTL: time.Duration(cfg.Auth.PasswordResetSeconds) * time.Second,
+		PasswordResetURL: cfg.Auth.PasswordResetURL,
+	})
+	notificationService := services.NewNotificationService(notificationRepo, userService, mail, jobRunner, strings.Split(cfg.Notifications.EmailTypes, ","))
+	problemCommentService := services.NewProblemCommentService(problemCommentRepo, problemService, notificationService, time.Duration(cfg.Comments.EditWindowSeconds)*time.Second)
+	moderationService := services.NewModerationService(contentReportRepo, problemCommentService, problemService, problemReviewService, userService, sessionService, eventService)
+	sessionService.WithBans(moderationService)
+	appealService := services.NewAppealService(appealRepo, submissionService, problemService, eventService, notificationService)
+	judgeResultService := services.NewJudgeResultService(submissionService, problemService, runService, validationService, notificationService, cfg.Judge.CompileOutputBytes).WithLatency(judgeLatency)
+
+	jwtKeys, err := loadJWTKeys(cfg.Auth)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+
+	workerKeys, err := services.ParseJudgeWorkerKeys(cfg.Judge.WorkerKeys)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+
+	groupRoles, err := services.ParseGroupRoles(cfg.Auth.GroupRoles)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+	ldapDirectory, err := ldap.NewFromConfig(cfg.Auth.LDAP)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+	var directory services.PasswordDirectory
+	if ldapDirectory != nil {
+		directory = ldapDirectory
+	}
+	externalAuthService := services.NewExternalAuthService(userService, sessionService, directory, groupRoles)
+	samlProxy := handlers.SAMLProxy{
+		Secret:         cfg.Auth.SAML.ProxySecret,
+		UsernameHeader: cfg.Auth.SAML.UsernameHeader,
+		EmailHeader:    cfg.Auth.SAML.EmailHeader,
+		NameHeader:     cfg.Auth.SAML.NameHeader,
+		GroupsHeader:   cfg.Auth.SAML.GroupsHeader,
+		RedirectURL:    cfg.Auth.SAML.RedirectURL,
+	}
+
+	tlsConfig, err := serverTLSConfig(cfg)
+	if err != nil {
+		backends.close()
+		return nil, err
 	}
 
-	authMiddleware := handlers.RequireAuth(jwtSecret)
+	authMiddleware := handlers.RequireAuth(jwtKeys, sessionService)
+
+	apiV1 := handlers.APIVersion{Number: 1}
+	unversioned, err := unversionedPolicy(cfg.API)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
+
+	trustedProxies, err := handlers.ParseTrustedProxies(cfg.API.TrustedProxies)
+	if err != nil {
+		backends.close()
+		return nil, err
+	}
 
 	router := chi.NewRouter()
 	router.Use(
 		middleware.RequestID,
-		middleware.RealIP,
+		handlers.RealIP(trustedProxies),
 		middleware.Recoverer,
-		middleware.Logger,
+		settings.logLevel.RequestLogger,
+		settings.cors.Middleware,
 		middleware.Timeout(60*time.Second),
+		handlers.UnversionedPaths([]handlers.APIVersion{apiV1}, unversioned),
 	)
 	router.Get("/healthz", handlers.Healthz)
-	router.Route("/problems", func(r chi.Router) {
-		handlers.ProblemRouter(r, problemService, userService, authMiddleware)
+	router.Method(http.MethodGet, "/metrics", metrics.Handler(judgeLatency, abuseDetector))
+	router.Route(apiV1.Prefix(), func(r chi.Router) {
+		r.Use(
+			handlers.VersionHeaders(apiV1, apiV1),
+			handlers.ResolveTenant(tenantService, cfg.Tenants.BaseDomain),
+			handlers.AbuseGuard(abuseDetector, jwtKeys),
+			handlers.ReplicaReads,
+		)
+		r.Route("/problems", func(r chi.Router) {
+			handlers.ProblemRouter(r, problemService, validationService, bundleUploadService, problemReviewService, problemCommentService, submissionService, userService, authMiddleware)
+			handlers.RecommendationRouter(r, recommendationService, authMiddleware)
+		})
+		r.Route("/lists", func(r chi.Router) {
+			handlers.ProblemListRouter(r, problemListService, userService, authMiddleware)
+		})
+		r.Route("/bundle-uploads", func(r chi.Router) {
+			handlers.BundleUploadRouter(r, bundleUploadService, problemService, userService, authMiddleware)
+		})
+		r.Route("/submissions", func(r chi.Router) {
+			handlers.SubmissionRouter(r, submissionService, problemService, userService, authMiddleware)
+		})
+		r.Route("/languages", handlers.LanguageRouter)
+		r.Route("/runs", func(r chi.Router) {
+			handlers.RunRouter(r, runService, userService, authMiddleware)
+		})
+		r.Route("/users", func(r chi.Router) {
+			handlers.UserRouter(r, userService, submissionService)
+		})
+		r.Get("/features", settings.features.List)
+		r.Route("/leaderboard", func(r chi.Router) {
+			handlers.LeaderboardRouter(r, leaderboardService)
+		})
+		r.Route("/graphql", func(r chi.Router) {
+			handlers.GraphQLRouter(r, problemService, submissionService, leaderboardService, userService, authMiddleware)
+		})
+		r.Route("/announcements", func(r chi.Router) {
+			handlers.AnnouncementRouter(r, announcementService, userService, authMiddleware)
+		})
+		r.Route("/appeals", func(r chi.Router) {
+			handlers.AppealRouter(r, appealService, userService, authMiddleware)
+		})
+		r.Route("/posts", func(r chi.Router) {
+			handlers.PostRouter(r, postService, userService, authMiddleware)
+		})
+		r.Route("/notifications", func(r chi.Router) {
+			handlers.NotificationRouter(r, notificationService, authMiddleware)
+		})
+		r.Route("/moderation", func(r chi.Router) {
+			handlers.ModerationRouter(r, moderationService, userService, authMiddleware)
+		})
+		r.Route("/events", func(r chi.Router) {
+			handlers.EventRouter(r, eventService, userService, authMiddleware)
+		})
+		r.Route("/admin", func(r chi.Router) {
+			handlers.AdminRouter(r, userImportService, submissionService, bundleIntegrityService, sessionService, userService, jobRunner, overviewService, exportService, abuseDetector, settings, authMiddleware)
+		})
+		r.Route("/auth", func(r chi.Router) {
+			handlers.AuthRouter(r, userService, sessionService, loginThrottleService, accountEmailService, externalAuthService, samlProxy, jwtKeys)
+		})
 	})
-	router.Route("/auth", func(r chi.Router) {
-		handlers.AuthRouter(r, userService, jwtSecret)
+	router.Route("/internal/judge", func(r chi.Router) {
+		handlers.JudgeRouter(r, judgeService, judgeDispatcher, submissionService, runService, validationService, generationService, judgeResultService, cfg.Judge.Token, workerKeys)
 	})
 
 	port := cfg.ServerPort
@@ -74,27 +382,278 @@ func New(ctx context.Context, cfg config.Config) (*Server, error) {
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		TLSConfig:    tlsConfig,
+	}
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort > 0 {
+		grpcServer, err = newJudgeGRPCServer(cfg, tlsConfig, judgerpc.New(judgeService, judgeQueue, judgeResultService, workerKeys))
+		if err != nil {
+			backends.close()
+			return nil, err
+		}
+	}
+
+	jobRunner.Register(services.JobBundleUpload, jobs.Task{
+		Run:         bundleUploadService.ProcessJob,
+		Timeout:     30 * time.Minute,
+		MaxAttempts: 3,
+	})
+	jobRunner.Register(services.JobNotificationEmail, jobs.Task{
+		Run:         notificationService.EmailJob,
+		Timeout:     time.Minute,
+		MaxAttempts: 3,
+	})
+	jobRunner.Register(services.JobWelcomeEmail, jobs.Task{
+		Run:         accountEmailService.WelcomeJob,
+		Timeout:     time.Minute,
+		MaxAttempts: 3,
+	})
+	if mail != nil {
+		jobRunner.Register(services.JobVerdictDigest, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return notificationService.SendVerdictDigests(ctx)
+			},
+			Timeout: time.Hour,
+		})
+		jobRunner.Schedule(services.JobVerdictDigest, time.Hour)
+	}
+	if cfg.Notifications.RetentionSeconds > 0 {
+		maxAge := time.Duration(cfg.Notifications.RetentionSeconds) * time.Second
+		jobRunner.Register(services.JobNotificationPrune, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				deleted, err := notificationService.Prune(ctx, maxAge)
+				return map[string]int64{"deleted": deleted}, err
+			},
+		})
+		jobRunner.Schedule(services.JobNotificationPrune, 24*time.Hour)
+	}
+	if cfg.Leaderboard.RefreshSeconds > 0 {
+		jobRunner.Register(services.JobLeaderboardRefresh, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return nil, leaderboardService.Refresh(ctx)
+			},
+		})
+		jobRunner.Schedule(services.JobLeaderboardRefresh, time.Duration(cfg.Leaderboard.RefreshSeconds)*time.Second)
+	}
+	if cfg.Recommendations.RefreshSeconds > 0 {
+		jobRunner.Register(services.JobRecommendationRefresh, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				users, err := recommendationService.Refresh(ctx)
+				return map[string]int{"users": users}, err
+			},
+			Timeout: time.Hour,
+		})
+		jobRunner.Schedule(services.JobRecommendationRefresh, time.Duration(cfg.Recommendations.RefreshSeconds)*time.Second)
+	}
+	if cfg.StorageGC.IntervalSeconds > 0 {
+		collector := storagegc.New(objectStorage, problemRepo, time.Duration(cfg.StorageGC.GraceSeconds)*time.Second)
+		jobRunner.Register(storagegc.JobKind, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return collector.Run(ctx, false)
+			},
+			Timeout: time.Hour,
+		})
+		jobRunner.Schedule(storagegc.JobKind, time.Duration(cfg.StorageGC.IntervalSeconds)*time.Second)
+	}
+	if cfg.BundleVerify.IntervalSeconds > 0 {
+		jobRunner.Register(services.JobBundleVerify, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return bundleIntegrityService.Sweep(ctx)
+			},
+			Timeout: time.Hour,
+		})
+		jobRunner.Schedule(services.JobBundleVerify, time.Duration(cfg.BundleVerify.IntervalSeconds)*time.Second)
+	}
+
+	if cfg.Retention.SubmissionCodeSeconds > 0 && cfg.Retention.IntervalSeconds > 0 {
+		maxAge := time.Duration(cfg.Retention.SubmissionCodeSeconds) * time.Second
+		jobRunner.Register(services.JobSubmissionRetention, jobs.Task{
+			Run: func(ctx context.Context, _ json.RawMessage) (any, error) {
+				return submissionService.PruneCode(ctx, maxAge, cfg.Retention.DryRun)
+			},
+			Timeout: time.Hour,
+		})
+		jobRunner.Schedule(services.JobSubmissionRetention, time.Duration(cfg.Retention.IntervalSeconds)*time.Second)
 	}
 
+	// Background jobs stop on Shutdown rather than with the caller's ctx,
+	// which may be cancelled as soon as New returns.
+	jobsCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	go jobRunner.Run(jobsCtx)
+	if replica != nil {
+		go replica.Monitor(jobsCtx, replicaCheckInterval)
+	}
+	go abuseDetector.Sync(jobsCtx, abuseSyncInterval)
+	go settings.Watch(jobsCtx, config.RuntimeConfigFile())
+
 	return &Server{
 		httpServer: httpServer,
 		router:     router,
 		db:         dbConn,
+		replica:    backends.Replica,
+		queue:      queue,
+		stop:       stop,
+		tls:        cfg.TLS,
+		grpcServer: grpcServer,
+		grpcAddr:   fmt.Sprintf(":%d", cfg.GRPCPort),
 	}, nil
 }
 
+// newJudgeGRPCServer returns the gRPC server for judge workers. It uses
+// the same certificate and judge client CA as HTTPS, and plaintext when
+// TLS is not configured.
+func newJudgeGRPCServer(cfg config.Config, tlsConfig *tls.Config, judge *judgerpc.Server) (*grpc.Server, error) {
+	if tlsConfig == nil {
+		return judge.GRPCServer(), nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	grpcTLS := tlsConfig.Clone()
+	grpcTLS.Certificates = []tls.Certificate{cert}
+	return judge.GRPCServer(grpc.Creds(credentials.NewTLS(grpcTLS))), nil
+}
+
+// serverTLSConfig returns the TLS settings for serving HTTPS, or nil to
+// serve plain HTTP. With JUDGE_CLIENT_CA_FILE set, clients may present a
+// certificate issued by that CA, which authenticates them as judge
+// workers.
+func serverTLSConfig(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLS.CertFile == "" && cfg.TLS.KeyFile == "" {
+		if cfg.Judge.ClientCAFile != "" {
+			return nil, errors.New("JUDGE_CLIENT_CA_FILE requires SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+		return nil, errors.New("SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE must be set together")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Judge.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.Judge.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read judge client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("JUDGE_CLIENT_CA_FILE holds no PEM certificates")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// unversionedPolicy schedules the retirement of unversioned API paths.
+// The health check and the judge API, whose messages carry their own
+// protocol version, are not versioned.
+func unversionedPolicy(cfg config.APIConfig) (handlers.UnversionedPolicy, error) {
+	policy := handlers.UnversionedPolicy{Exempt: []string{"/healthz", "/metrics", "/internal"}}
+	var err error
+	if policy.DeprecatedAt, err = time.Parse(time.DateOnly, strings.TrimSpace(cfg.UnversionedDeprecationDate)); err != nil {
+		return handlers.UnversionedPolicy{}, fmt.Errorf("invalid API_UNVERSIONED_DEPRECATION_DATE: %w", err)
+	}
+	if raw := strings.TrimSpace(cfg.UnversionedSunsetDate); raw != "" {
+		if policy.SunsetAt, err = time.Parse(time.DateOnly, raw); err != nil {
+			return handlers.UnversionedPolicy{}, fmt.Errorf("invalid API_UNVERSIONED_SUNSET_DATE: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// loadJWTKeys builds the token keys for the configured algorithm. With an
+// asymmetric algorithm the HMAC secrets, if still set, keep verifying the
+// tokens they signed until those expire.
+func loadJWTKeys(cfg config.AuthConfig) (*handlers.JWTKeys, error) {
+	secrets := append([]string{cfg.JWTSecret}, strings.Split(cfg.PreviousJWTSecrets, ",")...)
+
+	algorithm := strings.TrimSpace(cfg.JWTAlgorithm)
+	switch strings.ToUpper(algorithm) {
+	case "", "HS256":
+		if strings.TrimSpace(cfg.JWTSecret) == "" {
+			return nil, errors.New("JWT_SECRET is required")
+		}
+		return handlers.NewJWTKeys(secrets[0], secrets[1:]...), nil
+	case "RS256", "EDDSA":
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+
+	if strings.TrimSpace(cfg.JWTPrivateKeyFile) == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", algorithm)
+	}
+	privatePEM, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT private key: %w", err)
+	}
+	var previous [][]byte
+	for _, path := range strings.Split(cfg.JWTPreviousPublicKeyFiles, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		publicPEM, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read JWT public key: %w", err)
+		}
+		previous = append(previous, publicPEM)
+	}
+
+	keys, err := handlers.NewAsymmetricJWTKeys(privatePEM, previous...)
+	if err != nil {
+		return nil, fmt.Errorf("load JWT keys: %w", err)
+	}
+	if !strings.EqualFold(keys.Algorithm(), algorithm) {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE holds a %s key, not %s", keys.Algorithm(), algorithm)
+	}
+	for _, secret := range secrets {
+		keys.AddSecret(secret)
+	}
+	return keys, nil
+}
+
 // Router exposes the chi router for route registration.
 func (s *Server) Router() *chi.Mux {
 	return s.router
 }
 
-// Start runs the HTTP server.
+// Start runs the HTTP server, over TLS when a certificate is configured,
+// and the judge gRPC server when GRPC_PORT is set.
 func (s *Server) Start() error {
+	if s.grpcServer != nil {
+		listener, err := net.Listen("tcp", s.grpcAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := s.grpcServer.Serve(listener); err != nil {
+				log.Printf("judge grpc server stopped: %v", err)
+			}
+		}()
+	}
+	if s.httpServer.TLSConfig != nil {
+		return s.httpServer.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile)
+	}
 	return s.httpServer.ListenAndServe()
 }
 
 // Shutdown attempts a graceful shutdown.
 func (s *Server) Shutdown() error {
+	if s.stop != nil {
+		s.stop()
+	}
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	if s.queue != nil {
+		_ = s.queue.Close()
+	}
+	if s.replica != nil {
+		_ = s.replica.Close()
+	}
 	if s.db != nil {
 		_ = s.db.Close()
 	}
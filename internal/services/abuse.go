@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/metrics"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AbuseBlockRepository defines persistence operations for principals
+// blocked by the abuse detector.
+type AbuseBlockRepository interface {
+	Block(ctx context.Context, block types.AbuseBlock) error
+	ListActive(ctx context.Context, now time.Time) ([]types.AbuseBlock, error)
+	Unblock(ctx context.Context, principal string) error
+}
+
+// AbusePolicy configures AbuseDetector.
+type AbusePolicy struct {
+	// Window is the period over which requests are counted. Zero disables
+	// detection; blocks are still enforced.
+	Window time.Duration
+
+	// MaxRequests flags principals making more requests than this in one
+	// window. Zero disables the check.
+	MaxRequests int
+
+	// MaxErrorRatio flags principals whose share of 4xx responses in a
+	// window exceeds it, once they made MinRequests requests. Zero
+	// disables the check.
+	MaxErrorRatio float64
+	MinRequests   int
+
+	// BlockDuration is how long flagged principals are blocked. Zero only
+	// counts and logs anomalies.
+	BlockDuration time.Duration
+}
+
+// maxAbusePrincipals caps the principals AbuseDetector counts per window,
+// so that requests from many spoofed or rotated addresses cannot grow its
+// memory without bound. Principals first seen once the cap is reached are
+// not counted until the next window; blocks are still enforced.
+const maxAbusePrincipals = 100_000
+
+// AbuseDetector tracks the request rate and error ratio of every principal,
+// a user or a client IP, over fixed windows and flags those exceeding the
+// policy. Counts are kept per server; blocks are stored so that every
+// server enforces them (see Sync).
+type AbuseDetector struct {
+	repo   AbuseBlockRepository
+	policy AbusePolicy
+	now    func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	windows     map[string]*abuseWindow
+	full        bool
+	blocks      map[string]time.Time
+
+	requests  *metrics.Counter
+	anomalies *metrics.Counter
+	rejected  *metrics.Counter
+}
+
+type abuseWindow struct {
+	requests int
+	errors   int
+	flagged  bool
+}
+
+// NewAbuseDetector constructs an AbuseDetector.
+func NewAbuseDetector(repo AbuseBlockRepository, policy AbusePolicy) *AbuseDetector {
+	return &AbuseDetector{
+		repo:    repo,
+		policy:  policy,
+		now:     time.Now,
+		windows: make(map[string]*abuseWindow),
+		blocks:  make(map[string]time.Time),
+		requests: metrics.NewCounter("jjudge_http_requests_total",
+			"API requests by principal kind and response class.", "principal", "class"),
+		anomalies: metrics.NewCounter("jjudge_abuse_anomalies_total",
+			"Principals flagged by the abuse detector, by principal kind and anomaly.", "principal", "anomaly"),
+		rejected: metrics.NewCounter("jjudge_abuse_rejected_requests_total",
+			"Requests rejected because their principal is blocked.", "principal"),
+	}
+}
+
+// SetPolicy replaces the policy. Requests already counted in the current
+// window count towards the new thresholds.
+func (d *AbuseDetector) SetPolicy(policy AbusePolicy) {
+	d.mu.Lock()
+	d.policy = policy
+	d.mu.Unlock()
+}
+
+// UserPrincipal and IPPrincipal name the principals the detector tracks.
+func UserPrincipal(userID string) string { return "user:" + userID }
+func IPPrincipal(ip string) string       { return "ip:" + ip }
+
+// Check reports whether principal is blocked and, if so, until when.
+func (d *AbuseDetector) Check(principal string) (time.Time, bool) {
+	now := d.now()
+	d.mu.Lock()
+	until, ok := d.blocks[principal]
+	if ok && !until.After(now) {
+		delete(d.blocks, principal)
+		ok = false
+	}
+	d.mu.Unlock()
+	if ok {
+		d.rejected.Inc(principalKind(principal))
+	}
+	return until, ok
+}
+
+// Record counts a request by principal answered with status, and flags the
+// principal the first time in a window that it exceeds the policy.
+func (d *AbuseDetector) Record(ctx context.Context, principal string, status int) {
+	kind := principalKind(principal)
+	d.requests.Inc(kind, fmt.Sprintf("%dxx", status/100))
+
+	now := d.now()
+	d.mu.Lock()
+	policy := d.policy
+	if policy.Window <= 0 {
+		d.mu.Unlock()
+		return
+	}
+	if now.Sub(d.windowStart) >= policy.Window {
+		d.windowStart = now
+		d.full = false
+		clear(d.windows)
+	}
+	window, ok := d.windows[principal]
+	if !ok {
+		if len(d.windows) >= maxAbusePrincipals {
+			reported := d.full
+			d.full = true
+			d.mu.Unlock()
+			if !reported {
+				log.Printf("abuse: tracking %d principals, ignoring new ones until the window ends", maxAbusePrincipals)
+			}
+			return
+		}
+		window = &abuseWindow{}
+		d.windows[principal] = window
+	}
+	window.requests++
+	if status >= 400 && status < 500 {
+		window.errors++
+	}
+	anomaly, reason := d.anomaly(window)
+	if anomaly != "" {
+		window.flagged = true
+	}
+	d.mu.Unlock()
+
+	if anomaly == "" {
+		return
+	}
+	d.anomalies.Inc(kind, anomaly)
+	log.Printf("abuse: %s flagged: %s", principal, reason)
+	if policy.BlockDuration > 0 {
+		d.block(ctx, principal, reason, now.Add(policy.BlockDuration))
+	}
+}
+
+// anomaly returns the name and description of the policy window breaks,
+// if any and not yet reported. d.mu must be held.
+func (d *AbuseDetector) anomaly(window *abuseWindow) (string, string) {
+	if window.flagged {
+		return "", ""
+	}
+	if d.policy.MaxRequests > 0 && window.requests > d.policy.MaxRequests {
+		return "rate", fmt.Sprintf("more than %d requests in %s", d.policy.MaxRequests, d.policy.Window)
+	}
+	if d.policy.MaxErrorRatio > 0 && window.requests >= d.policy.MinRequests {
+		ratio := float64(window.errors) / float64(window.requests)
+		if ratio > d.policy.MaxErrorRatio {
+			return "error_ratio", fmt.Sprintf("%d of %d requests in %s failed", window.errors, window.requests, d.policy.Window)
+		}
+	}
+	return "", ""
+}
+
+// block enforces a block locally at once and stores it for the other
+// servers. A failure to store it is only logged.
+func (d *AbuseDetector) block(ctx context.Context, principal, reason string, until time.Time) {
+	d.mu.Lock()
+	d.blocks[principal] = until
+	d.mu.Unlock()
+
+	err := d.repo.Block(context.WithoutCancel(ctx), types.AbuseBlock{
+		Principal:    principal,
+		Reason:       reason,
+		BlockedUntil: until,
+		CreatedAt:    d.now(),
+	})
+	if err != nil {
+		log.Printf("abuse: failed to store block of %s: %v", principal, err)
+	}
+}
+
+// Blocks returns the blocks in effect, soonest to lift first.
+func (d *AbuseDetector) Blocks(ctx context.Context) ([]types.AbuseBlock, error) {
+	return d.repo.ListActive(ctx, d.now())
+}
+
+// Unblock lifts the block of principal and forgets its requests in the
+// current window. Other servers stop enforcing the block on their next
+// Sync. It returns store.ErrNotFound if the principal is not blocked.
+func (d *AbuseDetector) Unblock(ctx context.Context, principal string) error {
+	if err := d.repo.Unblock(ctx, principal); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	delete(d.blocks, principal)
+	delete(d.windows, principal)
+	d.mu.Unlock()
+	return nil
+}
+
+// Sync reloads the stored blocks every interval until ctx is cancelled,
+// picking up blocks and unblocks made by other servers.
+func (d *AbuseDetector) Sync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		blocks, err := d.repo.ListActive(ctx, d.now())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("abuse: failed to load blocks: %v", err)
+			}
+		} else {
+			active := make(map[string]time.Time, len(blocks))
+			for _, block := range blocks {
+				active[block.Principal] = block.BlockedUntil
+			}
+			d.mu.Lock()
+			d.blocks = active
+			d.mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WriteMetrics writes the detector's counters in the Prometheus text
+// format.
+func (d *AbuseDetector) WriteMetrics(w io.Writer) error {
+	for _, c := range []*metrics.Counter{d.requests, d.anomalies, d.rejected} {
+		if err := c.WriteMetrics(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func principalKind(principal string) string {
+	kind, _, _ := strings.Cut(principal, ":")
+	return kind
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeAbuseBlockRepo struct {
+	mu     sync.Mutex
+	blocks map[string]types.AbuseBlock
+}
+
+func (r *fakeAbuseBlockRepo) Block(_ context.Context, block types.AbuseBlock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blocks[block.Principal] = block
+	return nil
+}
+
+func (r *fakeAbuseBlockRepo) ListActive(_ context.Context, now time.Time) ([]types.AbuseBlock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active []types.AbuseBlock
+	for _, block := range r.blocks {
+		if block.BlockedUntil.After(now) {
+			active = append(active, block)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakeAbuseBlockRepo) Unblock(_ context.Context, principal string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.blocks[principal]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.blocks, principal)
+	return nil
+}
+
+// newAbuseDetector returns a detector whose clock advances only through
+// the returned function.
+func newAbuseDetector(policy AbusePolicy) (*AbuseDetector, *fakeAbuseBlockRepo, func(time.Duration)) {
+	repo := &fakeAbuseBlockRepo{blocks: map[string]types.AbuseBlock{}}
+	d := NewAbuseDetector(repo, policy)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	return d, repo, func(elapsed time.Duration) { now = now.Add(elapsed) }
+}
+
+func TestAbuseDetectorFlags(t *testing.T) {
+	policy := AbusePolicy{
+		Window:        time.Minute,
+		MaxRequests:   10,
+		MaxErrorRatio: 0.5,
+		MinRequests:   4,
+		BlockDuration: time.Hour,
+	}
+	tests := []struct {
+		name     string
+		statuses []int
+		blocked  bool
+	}{
+		{"within the rate", repeatStatus(http.StatusOK, 10), false},
+		{"over the rate", repeatStatus(http.StatusOK, 11), true},
+		{"errors below the minimum", repeatStatus(http.StatusNotFound, 3), false},
+		{"mostly errors", []int{http.StatusOK, http.StatusNotFound, http.StatusForbidden, http.StatusNotFound}, true},
+		{"half errors", []int{http.StatusOK, http.StatusNotFound, http.StatusOK, http.StatusNotFound}, false},
+		{"server errors", repeatStatus(http.StatusInternalServerError, 4), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, repo, _ := newAbuseDetector(policy)
+			principal := IPPrincipal("192.0.2.1")
+			for _, status := range tt.statuses {
+				d.Record(context.Background(), principal, status)
+			}
+			if _, blocked := d.Check(principal); blocked != tt.blocked {
+				t.Fatalf("blocked = %v, want %v", blocked, tt.blocked)
+			}
+			if _, stored := repo.blocks[principal]; stored != tt.blocked {
+				t.Fatalf("stored block = %v, want %v", stored, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestAbuseDetectorCapsPrincipals(t *testing.T) {
+	d, _, advance := newAbuseDetector(AbusePolicy{Window: time.Minute, MaxRequests: 1, BlockDuration: time.Hour})
+	ctx := context.Background()
+
+	known := UserPrincipal("1")
+	d.Record(ctx, known, http.StatusOK)
+	for i := 1; i < maxAbusePrincipals; i++ {
+		d.Record(ctx, IPPrincipal(fmt.Sprintf("10.%d.%d.%d", i>>16, i>>8&0xff, i&0xff)), http.StatusOK)
+	}
+
+	late := UserPrincipal("2")
+	d.Record(ctx, late, http.StatusOK)
+	d.Record(ctx, late, http.StatusOK)
+	if _, blocked := d.Check(late); blocked {
+		t.Fatalf("principal past the cap blocked, want it not counted")
+	}
+	if len(d.windows) != maxAbusePrincipals {
+		t.Fatalf("tracking %d principals, want %d", len(d.windows), maxAbusePrincipals)
+	}
+	// Principals already tracked are still counted.
+	d.Record(ctx, known, http.StatusOK)
+	if _, blocked := d.Check(known); !blocked {
+		t.Fatalf("tracked principal not blocked")
+	}
+
+	advance(time.Minute)
+	d.Record(ctx, late, http.StatusOK)
+	d.Record(ctx, late, http.StatusOK)
+	if _, blocked := d.Check(late); !blocked {
+		t.Fatalf("principal not blocked in the next window")
+	}
+}
+
+func TestAbuseDetectorUnblock(t *testing.T) {
+	d, repo, _ := newAbuseDetector(AbusePolicy{Window: time.Minute, MaxRequests: 1, BlockDuration: time.Hour})
+	ctx := context.Background()
+	principal := IPPrincipal("192.0.2.1")
+	d.Record(ctx, principal, http.StatusOK)
+	d.Record(ctx, principal, http.StatusOK)
+
+	if err := d.Unblock(ctx, principal); err != nil {
+		t.Fatalf("unblock: %v", err)
+	}
+	if _, blocked := d.Check(principal); blocked {
+		t.Fatalf("still blocked after unblock")
+	}
+	if len(repo.blocks) != 0 {
+		t.Fatalf("stored blocks = %v, want none", repo.blocks)
+	}
+	// The window was forgotten, so one more request does not block again.
+	d.Record(ctx, principal, http.StatusOK)
+	if _, blocked := d.Check(principal); blocked {
+		t.Fatalf("blocked again by requests made before the unblock")
+	}
+	if err := d.Unblock(ctx, principal); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("second unblock = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func repeatStatus(status, n int) []int {
+	statuses := make([]int, n)
+	for i := range statuses {
+		statuses[i] = status
+	}
+	return statuses
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordResetTokenInvalid is returned when a password reset token is
+// unknown, already used or expired.
+var ErrPasswordResetTokenInvalid = errors.New("invalid password reset token")
+
+// PasswordResetRepository defines persistence operations for password
+// reset tokens.
+type PasswordResetRepository interface {
+	Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	Consume(ctx context.Context, tokenHash string, now time.Time) (int, error)
+}
+
+// JobWelcomeEmail is the kind of the job that welcomes a newly registered
+// user by email.
+const JobWelcomeEmail = "email.welcome"
+
+// WelcomeEmailJob is the payload of a JobWelcomeEmail job.
+type WelcomeEmailJob struct {
+	UserID int `json:"user_id"`
+}
+
+// AccountEmailPolicy configures AccountEmailService.
+type AccountEmailPolicy struct {
+	// PasswordResetTTL is how long a password reset token stays valid.
+	PasswordResetTTL time.Duration
+
+	// PasswordResetURL is where password reset emails link to, with the
+	// token appended as the "token" query parameter. When empty the email
+	// carries the bare token.
+	PasswordResetURL string
+}
+
+// AccountEmailService sends the emails that are part of managing an
+// account: a welcome on registration and password resets. They are sent
+// whatever the user's notification preferences.
+type AccountEmailService struct {
+	resets   PasswordResetRepository
+	users    *UserService
+	sessions *SessionService
+	mailer   mailer.Mailer
+	jobs     JobEnqueuer
+	policy   AccountEmailPolicy
+}
+
+// NewAccountEmailService constructs an AccountEmailService. m may be nil
+// when email delivery is not configured, in which case no welcome emails
+// are sent and passwords cannot be reset.
+func NewAccountEmailService(resets PasswordResetRepository, users *UserService, sessions *SessionService, m mailer.Mailer, jobs JobEnqueuer, policy AccountEmailPolicy) *AccountEmailService {
+	if policy.PasswordResetTTL <= 0 {
+		policy.PasswordResetTTL = time.Hour
+	}
+	return &AccountEmailService{
+		resets:   resets,
+		users:    users,
+		sessions: sessions,
+		mailer:   m,
+		jobs:     jobs,
+		policy:   policy,
+	}
+}
+
+// Welcome queues a welcome email to a newly registered user. Registration
+// does not depend on it, so failures are only logged.
+func (s *AccountEmailService) Welcome(ctx context.Context, user types.User) {
+	if s.mailer == nil || strings.TrimSpace(user.Email) == "" {
+		return
+	}
+	if _, err := s.jobs.Enqueue(ctx, JobWelcomeEmail, WelcomeEmailJob{UserID: user.ID}); err != nil {
+		log.Printf("account email: failed to queue welcome email for user %d: %v", user.ID, err)
+	}
+}
+
+// WelcomeJob runs a JobWelcomeEmail job.
+func (s *AccountEmailService) WelcomeJob(ctx context.Context, payload json.RawMessage) (any, error) {
+	var job WelcomeEmailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	user, err := s.users.GetByID(ctx, job.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if s.mailer == nil || strings.TrimSpace(user.Email) == "" {
+		return nil, nil
+	}
+	msg, err := mailer.Render(user.Email, mailer.TemplateWelcome, map[string]any{
+		"Username": user.Username,
+		"Name":     user.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.mailer.Send(ctx, msg)
+}
+
+// RequestPasswordReset emails the named user a password reset token,
+// replacing any earlier one. So as not to reveal which accounts exist, it
+// succeeds without sending anything when there is no such user, they
+// have no email address or their password is kept by an external
+// identity provider.
+func (s *AccountEmailService) RequestPasswordReset(ctx context.Context, username string) error {
+	if s.mailer == nil {
+		return ErrMailerNotConfigured
+	}
+	user, err := s.users.GetByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if strings.TrimSpace(user.Email) == "" || IsExternalAccount(user) {
+		return nil
+	}
+
+	token, err := newUnlockToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.policy.PasswordResetTTL)
+	if err := s.resets.Create(ctx, user.ID, hashUnlockToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"Username":  user.Username,
+		"Token":     token,
+		"Link":      "",
+		"ExpiresAt": expiresAt,
+	}
+	if s.policy.PasswordResetURL != "" {
+		data["Link"] = unlockLink(s.policy.PasswordResetURL, token)
+	}
+	msg, err := mailer.Render(user.Email, mailer.TemplatePasswordReset, data)
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("account email: failed to send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password for the user whose emailed reset
+// token is given and ends all of their sessions. Each token works once.
+func (s *AccountEmailService) ResetPassword(ctx context.Context, token, password string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrPasswordResetTokenInvalid
+	}
+	userID, err := s.resets.Consume(ctx, hashUnlockToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrPasswordResetTokenInvalid
+		}
+		return err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = string(hashed)
+	if _, err := s.users.Update(ctx, user); err != nil {
+		return err
+	}
+	return s.sessions.EndAll(ctx, user.ID)
+}
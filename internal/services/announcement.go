@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AnnouncementRepository defines persistence operations for announcements.
+type AnnouncementRepository interface {
+	ListActive(ctx context.Context, now time.Time, offset, limit int) ([]types.Announcement, int, error)
+	Get(ctx context.Context, id int) (types.Announcement, error)
+	Create(ctx context.Context, announcement types.Announcement) (types.Announcement, error)
+	Update(ctx context.Context, announcement types.Announcement) (types.Announcement, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// AnnouncementService encapsulates announcement use-cases.
+type AnnouncementService struct {
+	repo AnnouncementRepository
+}
+
+func NewAnnouncementService(repo AnnouncementRepository) *AnnouncementService {
+	return &AnnouncementService{repo: repo}
+}
+
+// ListActive returns announcements that have not yet expired.
+func (s *AnnouncementService) ListActive(ctx context.Context, offset, limit int) ([]types.Announcement, int, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListActive(ctx, time.Now(), offset, limit)
+}
+
+func (s *AnnouncementService) Get(ctx context.Context, id int) (types.Announcement, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *AnnouncementService) Create(ctx context.Context, announcement types.Announcement) (types.Announcement, error) {
+	return s.repo.Create(ctx, announcement)
+}
+
+func (s *AnnouncementService) Update(ctx context.Context, announcement types.Announcement) (types.Announcement, error) {
+	return s.repo.Update(ctx, announcement)
+}
+
+func (s *AnnouncementService) Delete(ctx context.Context, id int) error {
+	return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// MaxAppealMessageBytes caps the length of appeal messages and admin
+// responses.
+const MaxAppealMessageBytes = 4 << 10
+
+var (
+	// ErrInvalidAppeal is returned when an appeal or its resolution is
+	// malformed, or the submission cannot be appealed.
+	ErrInvalidAppeal = errors.New("invalid appeal")
+
+	// ErrAppealExists is returned when filing an appeal for a submission
+	// that already has an open one.
+	ErrAppealExists = errors.New("submission already has an open appeal")
+
+	// ErrAppealResolved is returned when resolving an appeal that was
+	// already resolved.
+	ErrAppealResolved = errors.New("appeal is already resolved")
+)
+
+// AppealRepository defines persistence operations for verdict appeals.
+type AppealRepository interface {
+	Create(ctx context.Context, appeal types.Appeal) (types.Appeal, error)
+	Get(ctx context.Context, id int64) (types.Appeal, error)
+	List(ctx context.Context, filter store.AppealFilter, offset, limit int) ([]types.Appeal, int, error)
+	Resolve(ctx context.Context, appeal types.Appeal) (types.Appeal, error)
+}
+
+// AppealDecision is an admin's resolution of an appeal. Verdict and Score
+// are the new outcome when Resolution is AppealOverridden.
+type AppealDecision struct {
+	Resolution types.AppealResolution
+	Response   string
+	Verdict    types.Verdict
+	Score      int
+}
+
+// AppealService lets users appeal the verdicts of their judged submissions
+// and admins resolve the appeals: dismissing them, rejudging the
+// submission or overriding its verdict.
+type AppealService struct {
+	repo          AppealRepository
+	submissions   *SubmissionService
+	problems      *ProblemService
+	events        *EventService
+	notifications *NotificationService
+}
+
+// NewAppealService constructs an AppealService. notifications may be nil,
+// in which case users are not told about resolutions.
+func NewAppealService(repo AppealRepository, submissions *SubmissionService, problems *ProblemService, events *EventService, notifications *NotificationService) *AppealService {
+	return &AppealService{
+		repo:          repo,
+		submissions:   submissions,
+		problems:      problems,
+		events:        events,
+		notifications: notifications,
+	}
+}
+
+// File appeals the verdict of one of the user's judged submissions. Other
+// users' submissions are reported as not found.
+func (s *AppealService) File(ctx context.Context, user types.User, submissionID int64, message string) (types.Appeal, error) {
+	message, err := normalizeAppealText(message, true)
+	if err != nil {
+		return types.Appeal{}, err
+	}
+	submission, err := s.submissions.Get(ctx, submissionID)
+	if err != nil {
+		return types.Appeal{}, err
+	}
+	if submission.UserID != user.ID {
+		return types.Appeal{}, store.ErrNotFound
+	}
+	switch submission.Verdict {
+	case types.VerdictPending, types.VerdictJudging, types.VerdictCancelled:
+		return types.Appeal{}, fmt.Errorf("%w: only judged submissions can be appealed", ErrInvalidAppeal)
+	}
+
+	appeal, err := s.repo.Create(ctx, types.Appeal{
+		SubmissionID: submission.ID,
+		UserID:       user.ID,
+		Message:      message,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return types.Appeal{}, ErrAppealExists
+	}
+	return appeal, err
+}
+
+// Get returns an appeal to the user who filed it or an admin.
+func (s *AppealService) Get(ctx context.Context, viewer types.User, id int64) (types.Appeal, error) {
+	appeal, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Appeal{}, err
+	}
+	if appeal.UserID != viewer.ID && !isAdmin(viewer) {
+		return types.Appeal{}, store.ErrNotFound
+	}
+	return appeal, nil
+}
+
+// List returns a page of appeals, oldest first, optionally only those with
+// the given status. Admins see everyone's appeals and other users only
+// their own.
+func (s *AppealService) List(ctx context.Context, viewer types.User, status types.AppealStatus, offset, limit int) ([]types.Appeal, int, error) {
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	switch status {
+	case "", types.AppealOpen, types.AppealResolved:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown status %q", ErrInvalidAppeal, status)
+	}
+
+	filter := store.AppealFilter{Status: status}
+	if !isAdmin(viewer) {
+		filter.UserID = viewer.ID
+	}
+	return s.repo.List(ctx, filter, offset, limit)
+}
+
+// Resolve applies an admin's decision on an open appeal and tells the
+// user who filed it. Rejudges and overrides are recorded in the event log
+// with the admin as actor.
+func (s *AppealService) Resolve(ctx context.Context, admin types.User, id int64, decision AppealDecision) (types.Appeal, error) {
+	if !isAdmin(admin) {
+		return types.Appeal{}, store.ErrNotFound
+	}
+	response, err := normalizeAppealText(decision.Response, false)
+	if err != nil {
+		return types.Appeal{}, err
+	}
+	appeal, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Appeal{}, err
+	}
+	if appeal.Status != types.AppealOpen {
+		return types.Appeal{}, ErrAppealResolved
+	}
+
+	switch decision.Resolution {
+	case types.AppealDismissed:
+	case types.AppealRejudged:
+		if err := s.rejudge(ctx, int64(appeal.SubmissionID)); err != nil {
+			return types.Appeal{}, err
+		}
+	case types.AppealOverridden:
+		if _, err := s.submissions.OverrideVerdict(ctx, int64(appeal.SubmissionID), decision.Verdict, decision.Score); err != nil {
+			if errors.Is(err, ErrInvalidVerdictOverride) {
+				return types.Appeal{}, fmt.Errorf("%w: %w", ErrInvalidAppeal, err)
+			}
+			return types.Appeal{}, err
+		}
+	default:
+		return types.Appeal{}, fmt.Errorf("%w: unknown resolution %q", ErrInvalidAppeal, decision.Resolution)
+	}
+
+	appeal.Resolution = decision.Resolution
+	appeal.Response = response
+	appeal.ResolvedBy = &admin.ID
+	resolved, err := s.repo.Resolve(ctx, appeal)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return types.Appeal{}, ErrAppealResolved
+		}
+		return types.Appeal{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventAppealResolved, resolved.ID, map[string]any{
+		"id":            resolved.ID,
+		"submission_id": resolved.SubmissionID,
+		"resolution":    resolved.Resolution,
+	})
+	s.notifyResolved(ctx, resolved)
+	return resolved, nil
+}
+
+func (s *AppealService) rejudge(ctx context.Context, submissionID int64) error {
+	submission, err := s.submissions.Get(ctx, submissionID)
+	if err != nil {
+		return err
+	}
+	problem, err := s.problems.Get(ctx, submission.ProblemID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.submissions.Rejudge(ctx, submissionID, problem); err != nil {
+		if errors.Is(err, ErrSubmissionCodePruned) {
+			return fmt.Errorf("%w: %w", ErrInvalidAppeal, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// notifyResolved tells the user who filed an appeal how it was resolved.
+// The resolution is already stored, so a failure is only logged.
+func (s *AppealService) notifyResolved(ctx context.Context, appeal types.Appeal) {
+	body := fmt.Sprintf("Your appeal of submission %d was %s.", appeal.SubmissionID, appeal.Resolution)
+	if appeal.Response != "" {
+		body += "\n\n" + appeal.Response
+	}
+	err := s.notifications.Notify(ctx, types.Notification{
+		UserID:    appeal.UserID,
+		Type:      types.NotificationAppealResolved,
+		Title:     fmt.Sprintf("Your appeal of submission %d was resolved", appeal.SubmissionID),
+		Body:      body,
+		SubjectID: appeal.ID,
+	})
+	if err != nil {
+		log.Printf("appeals: failed to notify user %d of appeal %d: %v", appeal.UserID, appeal.ID, err)
+	}
+}
+
+func normalizeAppealText(text string, required bool) (string, error) {
+	text = strings.TrimSpace(text)
+	if required && text == "" {
+		return "", fmt.Errorf("%w: message is required", ErrInvalidAppeal)
+	}
+	if len(text) > MaxAppealMessageBytes {
+		return "", fmt.Errorf("%w: text must be at most %d bytes", ErrInvalidAppeal, MaxAppealMessageBytes)
+	}
+	return text, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrBundleCorrupted is returned when a judge asks for a bundle whose stored
+// object no longer matches its recorded hash.
+var ErrBundleCorrupted = errors.New("testcase bundle is corrupted")
+
+// BundleIntegrityRepository defines persistence operations for bundle
+// integrity checks.
+type BundleIntegrityRepository interface {
+	GetLatest(ctx context.Context, problemID int) (types.BundleVerification, error)
+	ListLatest(ctx context.Context) ([]types.BundleVerification, error)
+	Record(ctx context.Context, verification types.BundleVerification) error
+}
+
+// BundleIntegrityService re-reads stored testcase bundles and flags those
+// that no longer match their recorded SHA-256, so judges never run against
+// silently truncated or altered testcases.
+type BundleIntegrityService struct {
+	repo    BundleIntegrityRepository
+	storage *storage.Storage
+}
+
+func NewBundleIntegrityService(repo BundleIntegrityRepository, objectStorage *storage.Storage) *BundleIntegrityService {
+	return &BundleIntegrityService{repo: repo, storage: objectStorage}
+}
+
+// Verify checks the latest stored bundle of a problem.
+func (s *BundleIntegrityService) Verify(ctx context.Context, problemID int) (types.BundleVerification, error) {
+	bundle, err := s.repo.GetLatest(ctx, problemID)
+	if err != nil {
+		return types.BundleVerification{}, err
+	}
+	return s.verify(ctx, bundle)
+}
+
+// VerifyAll checks the latest stored bundle of every problem, returning the
+// corrupted ones. Bundles that could not be read are logged and skipped.
+func (s *BundleIntegrityService) VerifyAll(ctx context.Context) ([]types.BundleVerification, error) {
+	bundles, err := s.repo.ListLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	corrupted := []types.BundleVerification{}
+	for _, bundle := range bundles {
+		verification, err := s.verify(ctx, bundle)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("bundle verification of problem %d failed: %v", bundle.ProblemID, err)
+			continue
+		}
+		if verification.Corrupted {
+			corrupted = append(corrupted, verification)
+		}
+	}
+	return corrupted, nil
+}
+
+// JobBundleVerify is the kind of the scheduled job that verifies every
+// stored bundle.
+const JobBundleVerify = "bundle.verify"
+
+// Sweep verifies every bundle like VerifyAll and logs the corrupted ones.
+func (s *BundleIntegrityService) Sweep(ctx context.Context) ([]types.BundleVerification, error) {
+	corrupted, err := s.VerifyAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range corrupted {
+		log.Printf("testcase bundle %s of problem %d is corrupted: %s", bundle.ObjectKey, bundle.ProblemID, bundle.Error)
+	}
+	return corrupted, nil
+}
+
+// verify hashes the stored object and records the outcome. Errors reading
+// the object are returned without flagging the bundle, since they may be
+// transient.
+func (s *BundleIntegrityService) verify(ctx context.Context, bundle types.BundleVerification) (types.BundleVerification, error) {
+	if s.storage == nil {
+		return types.BundleVerification{}, errors.New("object storage is not configured")
+	}
+
+	reader, err := s.storage.Get(ctx, bundle.ObjectKey)
+	if err != nil {
+		return types.BundleVerification{}, fmt.Errorf("failed to open bundle %s: %w", bundle.ObjectKey, err)
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, reader)
+	if err != nil {
+		return types.BundleVerification{}, fmt.Errorf("failed to read bundle %s: %w", bundle.ObjectKey, err)
+	}
+
+	bundle.ActualSHA256 = hex.EncodeToString(hasher.Sum(nil))
+	bundle.Size = size
+	bundle.Corrupted = bundle.ActualSHA256 != bundle.ExpectedSHA256
+	bundle.Error = ""
+	if bundle.Corrupted {
+		bundle.Error = fmt.Sprintf("stored object has SHA-256 %s (%d bytes), want %s", bundle.ActualSHA256, size, bundle.ExpectedSHA256)
+	}
+	bundle.VerifiedAt = time.Now()
+	if err := s.repo.Record(ctx, bundle); err != nil {
+		return types.BundleVerification{}, err
+	}
+	return bundle, nil
+}
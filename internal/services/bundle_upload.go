@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Limits applied to multipart bundle uploads.
+const (
+	MaxBundlePartBytes = 64 << 20
+	MaxBundleParts     = 1000
+
+	// bundleUploadTTL is how long a session accepts parts. Parts are
+	// unreferenced objects until the upload completes, so it should not
+	// exceed the storage GC grace period.
+	bundleUploadTTL = 24 * time.Hour
+	// bundlePartURLExpiry is how long a presigned part upload URL is valid.
+	bundlePartURLExpiry = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidBundleUpload is returned when an upload session request is
+	// malformed.
+	ErrInvalidBundleUpload = errors.New("invalid bundle upload")
+
+	// ErrInvalidBundleFilename is returned when a bundle's filename does
+	// not name a gzipped tarball. It wraps ErrInvalidBundleUpload.
+	ErrInvalidBundleFilename = fmt.Errorf("%w: filename must end in .tar.gz or .tgz", ErrInvalidBundleUpload)
+
+	// ErrBundleUploadClosed is returned when parts are sent to, or the
+	// completion of, a session that is no longer accepting parts.
+	ErrBundleUploadClosed = errors.New("bundle upload is not accepting parts")
+)
+
+// BundleUploadRepository defines persistence operations for bundle upload
+// sessions.
+type BundleUploadRepository interface {
+	Get(ctx context.Context, id string) (types.BundleUpload, error)
+	GetLatestForProblem(ctx context.Context, problemID int) (types.BundleUpload, error)
+	Create(ctx context.Context, upload types.BundleUpload) (types.BundleUpload, error)
+	Transition(ctx context.Context, id string, from, to types.BundleUploadStatus) error
+	Finish(ctx context.Context, upload types.BundleUpload) (types.BundleUpload, error)
+}
+
+// JobBundleUpload is the kind of the background job that processes a
+// completed bundle upload.
+const JobBundleUpload = "bundle_upload.process"
+
+// BundleUploadJob is the payload of a JobBundleUpload job.
+type BundleUploadJob struct {
+	UploadID string `json:"upload_id"`
+}
+
+// JobEnqueuer queues background jobs.
+type JobEnqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload any) (types.Job, error)
+}
+
+// BundleUploadService assembles testcase bundles uploaded in parts. Parts
+// are stored under uploads/<id>/ and, once the upload is completed,
+// concatenated and verified in the background like a bundle sent to
+// POST /problems.
+type BundleUploadService struct {
+	repo       BundleUploadRepository
+	problems   *ProblemService
+	generation *TestcaseGenerationService
+	storage    *storage.Storage
+	jobs       JobEnqueuer
+}
+
+func NewBundleUploadService(
+	repo BundleUploadRepository,
+	problems *ProblemService,
+	generation *TestcaseGenerationService,
+	objectStorage *storage.Storage,
+	jobs JobEnqueuer,
+) *BundleUploadService {
+	return &BundleUploadService{
+		repo:       repo,
+		problems:   problems,
+		generation: generation,
+		storage:    objectStorage,
+		jobs:       jobs,
+	}
+}
+
+func (s *BundleUploadService) Get(ctx context.Context, id string) (types.BundleUpload, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// LatestForProblem returns the most recent bundle upload of a problem,
+// which reports the processing status of its latest bundle.
+func (s *BundleUploadService) LatestForProblem(ctx context.Context, problemID int) (types.BundleUpload, error) {
+	return s.repo.GetLatestForProblem(ctx, problemID)
+}
+
+// ValidateBundleUpload runs the checks on a bundle upload that do not need
+// the archive itself, so requests can be rejected before it is processed.
+func ValidateBundleUpload(filename string, tcGroups []types.TestcaseGroup) error {
+	lower := strings.ToLower(strings.TrimSpace(filename))
+	if !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		return ErrInvalidBundleFilename
+	}
+	if err := ValidateTestcaseGroups(tcGroups); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBundleUpload, err)
+	}
+	return nil
+}
+
+// Create starts a session for uploading a new bundle for an existing
+// problem.
+func (s *BundleUploadService) Create(ctx context.Context, upload types.BundleUpload) (types.BundleUpload, error) {
+	if s.storage == nil {
+		return types.BundleUpload{}, errors.New("object storage is not configured")
+	}
+	upload.Filename = strings.TrimSpace(upload.Filename)
+	if err := ValidateBundleUpload(upload.Filename, upload.TestcaseGroups); err != nil {
+		return types.BundleUpload{}, err
+	}
+	if _, err := s.problems.Get(ctx, upload.ProblemID); err != nil {
+		return types.BundleUpload{}, err
+	}
+
+	id, err := newBundleUploadID()
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	upload.ID = id
+	upload.Status = types.BundleUploadUploading
+	upload.Error = nil
+	upload.Size = 0
+	upload.BundleVersion = 0
+	upload.ExpiresAt = time.Now().Add(bundleUploadTTL)
+	return s.repo.Create(ctx, upload)
+}
+
+// Submit processes a bundle received in a single request, such as one sent
+// to POST /problems, through a session holding it as its only part.
+func (s *BundleUploadService) Submit(ctx context.Context, upload types.BundleUpload, r io.Reader, size int64) (types.BundleUpload, error) {
+	created, err := s.Create(ctx, upload)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	if err := s.storage.Put(ctx, bundlePartKey(created.ID, 1), r, size, "application/octet-stream"); err != nil {
+		return types.BundleUpload{}, err
+	}
+	return s.Complete(ctx, created.ID)
+}
+
+// PutPart stores part number part of an open session, replacing any
+// earlier upload of the same part.
+func (s *BundleUploadService) PutPart(ctx context.Context, id string, part int, r io.Reader, size int64) error {
+	upload, err := s.openUpload(ctx, id, part)
+	if err != nil {
+		return err
+	}
+	if size <= 0 || size > MaxBundlePartBytes {
+		return fmt.Errorf("%w: part size must be between 1 and %d bytes", ErrInvalidBundleUpload, MaxBundlePartBytes)
+	}
+	return s.storage.Put(ctx, bundlePartKey(upload.ID, part), r, size, "application/octet-stream")
+}
+
+// PartUploadURL presigns an upload of part number part of an open session
+// straight to object storage.
+func (s *BundleUploadService) PartUploadURL(ctx context.Context, id string, part int) (string, time.Time, error) {
+	upload, err := s.openUpload(ctx, id, part)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(bundlePartURLExpiry)
+	url, err := s.storage.PresignPut(ctx, bundlePartKey(upload.ID, part), bundlePartURLExpiry)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return url, expiresAt, nil
+}
+
+// Complete closes a session to further parts and queues a
+// JobBundleUpload job to assemble and verify the bundle. Poll the session
+// for the outcome.
+func (s *BundleUploadService) Complete(ctx context.Context, id string) (types.BundleUpload, error) {
+	upload, err := s.openUpload(ctx, id, 1)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	parts, err := s.listParts(ctx, upload.ID)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	if err := s.repo.Transition(ctx, upload.ID, types.BundleUploadUploading, types.BundleUploadProcessing); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return types.BundleUpload{}, ErrBundleUploadClosed
+		}
+		return types.BundleUpload{}, err
+	}
+	if _, err := s.jobs.Enqueue(ctx, JobBundleUpload, BundleUploadJob{UploadID: upload.ID}); err != nil {
+		// Reopen the session so that completing it can be retried.
+		_ = s.repo.Transition(context.WithoutCancel(ctx), upload.ID, types.BundleUploadProcessing, types.BundleUploadUploading)
+		return types.BundleUpload{}, err
+	}
+	upload.Status = types.BundleUploadProcessing
+	for _, part := range parts {
+		upload.Size += part.Size
+	}
+	return upload, nil
+}
+
+// ProcessJob runs a JobBundleUpload job. Sessions that are no longer
+// processing, because an earlier attempt recorded their outcome, are left
+// alone.
+func (s *BundleUploadService) ProcessJob(ctx context.Context, payload json.RawMessage) (any, error) {
+	var job BundleUploadJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	upload, err := s.repo.Get(ctx, job.UploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status != types.BundleUploadProcessing {
+		return nil, nil
+	}
+	parts, err := s.listParts(ctx, upload.ID)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidBundleUpload) {
+			return nil, err
+		}
+		upload.Status = types.BundleUploadFailed
+		upload.Error = BundleErrorDetails(err)
+		_, err = s.repo.Finish(ctx, upload)
+		return nil, err
+	}
+	for _, part := range parts {
+		upload.Size += part.Size
+	}
+	if err := s.process(ctx, upload, parts); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// openUpload loads a session that still accepts parts.
+func (s *BundleUploadService) openUpload(ctx context.Context, id string, part int) (types.BundleUpload, error) {
+	if s.storage == nil {
+		return types.BundleUpload{}, errors.New("object storage is not configured")
+	}
+	if part < 1 || part > MaxBundleParts {
+		return types.BundleUpload{}, fmt.Errorf("%w: part number must be between 1 and %d", ErrInvalidBundleUpload, MaxBundleParts)
+	}
+	upload, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	if upload.Status != types.BundleUploadUploading || time.Now().After(upload.ExpiresAt) {
+		return types.BundleUpload{}, ErrBundleUploadClosed
+	}
+	return upload, nil
+}
+
+// listParts returns the uploaded parts of a session in order, requiring
+// them to be numbered 1 through n without gaps.
+func (s *BundleUploadService) listParts(ctx context.Context, id string) ([]storage.ObjectInfo, error) {
+	objects, err := s.storage.List(ctx, bundleUploadPrefix(id))
+	if err != nil {
+		return nil, err
+	}
+	numbers := make(map[string]int, len(objects))
+	parts := make([]storage.ObjectInfo, 0, len(objects))
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Key, bundleUploadPrefix(id))
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "part-"))
+		if err != nil || !strings.HasPrefix(name, "part-") {
+			continue
+		}
+		numbers[object.Key] = n
+		parts = append(parts, object)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return numbers[parts[i].Key] < numbers[parts[j].Key]
+	})
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: no parts were uploaded", ErrInvalidBundleUpload)
+	}
+	for i, part := range parts {
+		if numbers[part.Key] != i+1 {
+			return nil, fmt.Errorf("%w: part %d is missing", ErrInvalidBundleUpload, i+1)
+		}
+	}
+	return parts, nil
+}
+
+// process assembles the parts into a temporary file, verifies the bundle
+// and stores it as the problem's next bundle version. Once the outcome is
+// recorded the parts are removed, whatever it was; an error recording it
+// is returned with the parts kept, so that processing can be retried.
+func (s *BundleUploadService) process(ctx context.Context, upload types.BundleUpload, parts []storage.ObjectInfo) error {
+	bundle, err := s.assemble(ctx, upload, parts)
+	if err != nil {
+		upload.Status = types.BundleUploadFailed
+		upload.Error = BundleErrorDetails(err)
+	} else {
+		upload.Status = types.BundleUploadReady
+		upload.BundleVersion = bundle.Version
+		// A failed start leaves the problem's validation status as it
+		// was, as for bundles sent to POST /problems.
+		_ = s.generation.Start(ctx, upload.ProblemID)
+	}
+
+	if _, err := s.repo.Finish(ctx, upload); err != nil {
+		return fmt.Errorf("bundle upload %s: failed to record outcome: %w", upload.ID, err)
+	}
+	for _, part := range parts {
+		if err := s.storage.Delete(ctx, part.Key); err != nil {
+			log.Printf("bundle upload %s: failed to delete %s: %v", upload.ID, part.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *BundleUploadService) assemble(ctx context.Context, upload types.BundleUpload, parts []storage.ObjectInfo) (types.TestcaseBundle, error) {
+	problem, err := s.problems.Get(ctx, upload.ProblemID)
+	if err != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("failed to load problem: %w", err)
+	}
+
+	extractBase := strings.TrimSpace(os.Getenv(testcaseExtractDirEnv))
+	file, err := os.CreateTemp(extractBase, "bundle-upload-")
+	if err != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	for _, part := range parts {
+		if err := s.copyPart(ctx, file, part.Key); err != nil {
+			return types.TestcaseBundle{}, err
+		}
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	bundle, err := s.problems.GetTestcaseBundleFromReader(problem.Type, upload.Filename, file, upload.TestcaseGroups)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	bundle.ObjectKey = fmt.Sprintf("problems/%d/bundles/%s.tar.gz", upload.ProblemID, bundle.SHA256)
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	if err := s.storage.Put(ctx, bundle.ObjectKey, file, size, "application/gzip"); err != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("failed to store bundle: %w", err)
+	}
+	if err := s.problems.UpdateTestcaseBundle(ctx, upload.ProblemID, bundle); err != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("failed to update testcase bundle: %w", err)
+	}
+	updated, err := s.problems.Get(ctx, upload.ProblemID)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	return updated.TestcaseBundle, nil
+}
+
+func (s *BundleUploadService) copyPart(ctx context.Context, w io.Writer, key string) error {
+	r, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer r.Close()
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return nil
+}
+
+func bundleUploadPrefix(id string) string {
+	return "uploads/" + id + "/"
+}
+
+func bundlePartKey(id string, part int) string {
+	return fmt.Sprintf("%spart-%05d", bundleUploadPrefix(id), part)
+}
+
+func newBundleUploadID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jjudge-oj/apiserver/internal/mq"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// EventRepository defines persistence operations for the domain event log.
+type EventRepository interface {
+	Append(ctx context.Context, event types.Event) (types.Event, error)
+	ListAfter(ctx context.Context, afterID int64, eventType string, limit int) ([]types.Event, error)
+}
+
+// EventService records domain events in the append-only event log and, when
+// a queue is configured, republishes them on an MQ channel.
+type EventService struct {
+	repo    EventRepository
+	queue   *mq.MQ
+	channel string
+}
+
+// NewEventService constructs an EventService. queue may be nil, in which case
+// events are only written to the log.
+func NewEventService(repo EventRepository, queue *mq.MQ, channel string) *EventService {
+	return &EventService{
+		repo:    repo,
+		queue:   queue,
+		channel: channel,
+	}
+}
+
+// Emit appends an event to the log and publishes it to the events channel.
+// The actor is taken from the context (see WithActor). A nil EventService
+// discards events.
+func (s *EventService) Emit(ctx context.Context, eventType string, subjectID int64, payload any) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event, err := s.repo.Append(ctx, types.Event{
+		Type:      eventType,
+		SubjectID: subjectID,
+		ActorID:   actorFromContext(ctx),
+		Payload:   data,
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.queue == nil || s.channel == "" {
+		return nil
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.queue.Publish(ctx, s.channel, message, map[string]string{"type": event.Type})
+	return err
+}
+
+// ListAfter returns events recorded after the given log position.
+func (s *EventService) ListAfter(ctx context.Context, afterID int64, eventType string, limit int) ([]types.Event, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	return s.repo.ListAfter(ctx, afterID, eventType, limit)
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the ID of the user performing the
+// current operation, recorded as the actor of emitted events.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func actorFromContext(ctx context.Context) int {
+	userID, _ := ctx.Value(actorKey{}).(int)
+	return userID
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrInvalidExport is returned when an export names an unknown format or
+// column, or an empty date range.
+var ErrInvalidExport = errors.New("invalid export")
+
+// Supported export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// exportBatch is the number of rows read per query while exporting.
+const exportBatch = 1000
+
+// ProblemExportRepository pages through problems for bulk exports.
+type ProblemExportRepository interface {
+	ListForExport(ctx context.Context, filter store.ExportFilter, afterID, limit int) ([]types.Problem, error)
+}
+
+// SubmissionExportRepository pages through submissions for bulk exports.
+type SubmissionExportRepository interface {
+	ListForExport(ctx context.Context, filter store.ExportFilter, afterID, limit int) ([]types.Submission, error)
+}
+
+// ExportOptions selects what an export writes. Empty Columns means every
+// default column of the export, in their documented order.
+type ExportOptions struct {
+	Format  string
+	Columns []string
+	Filter  store.ExportFilter
+}
+
+type problemExportColumn struct {
+	name  string
+	value func(types.Problem) any
+}
+
+// problemExportColumns lists the columns of problem exports in their
+// default order. The description is only written when asked for.
+var problemExportColumns = []problemExportColumn{
+	{"id", func(p types.Problem) any { return p.ID }},
+	{"title", func(p types.Problem) any { return p.Title }},
+	{"type", func(p types.Problem) any { return string(p.Type) }},
+	{"difficulty", func(p types.Problem) any { return p.Difficulty }},
+	{"time_limit", func(p types.Problem) any { return p.TimeLimit }},
+	{"memory_limit", func(p types.Problem) any { return p.MemoryLimit }},
+	{"tags", func(p types.Problem) any { return p.Tags }},
+	{"published", func(p types.Problem) any { return p.Published }},
+	{"review_status", func(p types.Problem) any { return string(p.ReviewStatus) }},
+	{"validation_status", func(p types.Problem) any { return string(p.ValidationStatus) }},
+	{"owner_id", func(p types.Problem) any { return p.OwnerID }},
+	{"bundle_version", func(p types.Problem) any { return p.TestcaseBundle.Version }},
+	{"created_at", func(p types.Problem) any { return p.CreatedAt }},
+	{"updated_at", func(p types.Problem) any { return p.UpdatedAt }},
+	{"description", func(p types.Problem) any { return p.Description }},
+}
+
+type submissionExportColumn struct {
+	name  string
+	value func(types.Submission) any
+}
+
+// submissionExportColumns lists the columns of submission exports in their
+// default order.
+var submissionExportColumns = []submissionExportColumn{
+	{"id", func(s types.Submission) any { return s.ID }},
+	{"problem_id", func(s types.Submission) any { return s.ProblemID }},
+	{"user_id", func(s types.Submission) any { return s.UserID }},
+	{"language", func(s types.Submission) any { return s.Language }},
+	{"verdict", func(s types.Submission) any { return s.Verdict.String() }},
+	{"score", func(s types.Submission) any { return s.Score }},
+	{"cpu_time", func(s types.Submission) any { return s.CPUTime }},
+	{"memory", func(s types.Submission) any { return s.Memory }},
+	{"tests_passed", func(s types.Submission) any { return s.TestsPassed }},
+	{"tests_total", func(s types.Submission) any { return s.TestsTotal }},
+	{"code_length", func(s types.Submission) any { return s.CodeLength }},
+	{"created_at", func(s types.Submission) any { return s.CreatedAt }},
+	{"updated_at", func(s types.Submission) any { return s.UpdatedAt }},
+}
+
+// ExportService streams problems and submissions in bulk for analytics.
+// Rows are read in ID order a batch at a time, from the read replica when
+// there is one, so exports of any size use constant memory.
+type ExportService struct {
+	problems    ProblemExportRepository
+	submissions SubmissionExportRepository
+}
+
+func NewExportService(problems ProblemExportRepository, submissions SubmissionExportRepository) *ExportService {
+	return &ExportService{problems: problems, submissions: submissions}
+}
+
+// ProblemColumns returns the columns a problem export may select.
+func ProblemColumns() []string {
+	names := make([]string, len(problemExportColumns))
+	for i, column := range problemExportColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// SubmissionColumns returns the columns a submission export may select.
+func SubmissionColumns() []string {
+	names := make([]string, len(submissionExportColumns))
+	for i, column := range submissionExportColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// ValidateExport reports whether opts describe a valid export of an entity
+// with the given columns, so callers can reject it before they start
+// writing a response.
+func ValidateExport(opts ExportOptions, available []string) error {
+	switch opts.Format {
+	case ExportCSV, ExportNDJSON:
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidExport, opts.Format)
+	}
+	for _, name := range opts.Columns {
+		if !slices.Contains(available, name) {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidExport, name)
+		}
+	}
+	if !opts.Filter.From.IsZero() && !opts.Filter.To.IsZero() && !opts.Filter.From.Before(opts.Filter.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidExport)
+	}
+	return nil
+}
+
+// ExportProblems writes every problem matching opts to w.
+func (s *ExportService) ExportProblems(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	if err := ValidateExport(opts, ProblemColumns()); err != nil {
+		return err
+	}
+	var columns []problemExportColumn
+	if len(opts.Columns) == 0 {
+		columns = problemExportColumns[:len(problemExportColumns)-1]
+	}
+	for _, name := range opts.Columns {
+		i := slices.IndexFunc(problemExportColumns, func(c problemExportColumn) bool { return c.name == name })
+		columns = append(columns, problemExportColumns[i])
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+
+	out, err := newExportWriter(w, opts.Format, names)
+	if err != nil {
+		return err
+	}
+	ctx = store.WithReplicaReads(ctx)
+	values := make([]any, len(columns))
+	for afterID := 0; ; {
+		problems, err := s.problems.ListForExport(ctx, opts.Filter, afterID, exportBatch)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			for i, column := range columns {
+				values[i] = column.value(problem)
+			}
+			if err := out.write(values); err != nil {
+				return err
+			}
+			afterID = problem.ID
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+		if len(problems) < exportBatch {
+			return nil
+		}
+	}
+}
+
+// ExportSubmissions writes every submission matching opts to w. Sources
+// and test case results are not exported.
+func (s *ExportService) ExportSubmissions(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	if err := ValidateExport(opts, SubmissionColumns()); err != nil {
+		return err
+	}
+	columns := submissionExportColumns
+	if len(opts.Columns) > 0 {
+		columns = nil
+	}
+	for _, name := range opts.Columns {
+		i := slices.IndexFunc(submissionExportColumns, func(c submissionExportColumn) bool { return c.name == name })
+		columns = append(columns, submissionExportColumns[i])
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+
+	out, err := newExportWriter(w, opts.Format, names)
+	if err != nil {
+		return err
+	}
+	ctx = store.WithReplicaReads(ctx)
+	values := make([]any, len(columns))
+	for afterID := 0; ; {
+		submissions, err := s.submissions.ListForExport(ctx, opts.Filter, afterID, exportBatch)
+		if err != nil {
+			return err
+		}
+		for _, submission := range submissions {
+			for i, column := range columns {
+				values[i] = column.value(submission)
+			}
+			if err := out.write(values); err != nil {
+				return err
+			}
+			afterID = submission.ID
+		}
+		if err := out.flush(); err != nil {
+			return err
+		}
+		if len(submissions) < exportBatch {
+			return nil
+		}
+	}
+}
+
+// exportWriter writes rows as CSV, with a header line, or as one JSON
+// object per line.
+type exportWriter struct {
+	w       io.Writer
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+func newExportWriter(w io.Writer, format string, columns []string) (*exportWriter, error) {
+	out := &exportWriter{w: w, columns: columns}
+	if format == ExportNDJSON {
+		out.json = json.NewEncoder(w)
+		return out, nil
+	}
+	out.csv = csv.NewWriter(w)
+	if err := out.csv.Write(columns); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (e *exportWriter) write(values []any) error {
+	if e.json != nil {
+		row := make(map[string]any, len(values))
+		for i, value := range values {
+			row[e.columns[i]] = value
+		}
+		return e.json.Encode(row)
+	}
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = csvValue(value)
+	}
+	return e.csv.Write(record)
+}
+
+// flush pushes buffered rows out to the client, when the underlying writer
+// supports it, so that long exports stream.
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := e.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(v, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
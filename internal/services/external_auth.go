@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/ldap"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// externalPasswordHash is stored as the password hash of accounts
+// provisioned from an external identity provider. It is not a bcrypt
+// hash, so no password matches it and the account signs in only through
+// the provider.
+const externalPasswordHash = "!external"
+
+var (
+	// ErrInvalidExternalIdentity is returned when a provider describes a
+	// user without a username or email address.
+	ErrInvalidExternalIdentity = errors.New("invalid external identity")
+
+	// ErrInvalidCredentials is returned when the directory rejects a
+	// username and password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrLocalAccountExists is returned when a provider signs in a user
+	// whose username belongs to an account with a local password.
+	ErrLocalAccountExists = errors.New("a local account has this username")
+)
+
+// roleRank orders roles from least to most privileged.
+var roleRank = map[string]int{
+	types.RoleUser:     0,
+	types.RoleSetter:   1,
+	types.RoleReviewer: 2,
+	types.RoleAdmin:    3,
+}
+
+// GroupRole grants Role to the members of a provider group.
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+// ParseGroupRoles parses semicolon-separated role:group entries, such as
+// "admin:cn=judge-admins,ou=groups,dc=example,dc=edu;setter:staff".
+func ParseGroupRoles(raw string) ([]GroupRole, error) {
+	var mappings []GroupRole
+	for _, item := range strings.Split(raw, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		role, group, ok := strings.Cut(item, ":")
+		role = strings.ToLower(strings.TrimSpace(role))
+		group = strings.TrimSpace(group)
+		if _, known := roleRank[role]; !ok || !known || group == "" {
+			return nil, fmt.Errorf("invalid AUTH_GROUP_ROLES entry %q: want role:group", item)
+		}
+		mappings = append(mappings, GroupRole{Group: group, Role: role})
+	}
+	return mappings, nil
+}
+
+// PasswordDirectory checks passwords against an external user directory,
+// such as LDAP. It returns ldap.ErrInvalidCredentials when it rejects
+// them.
+type PasswordDirectory interface {
+	Authenticate(ctx context.Context, username, password string) (types.ExternalIdentity, error)
+}
+
+// IsExternalAccount reports whether user was provisioned by an external
+// identity provider and signs in through it.
+func IsExternalAccount(user types.User) bool {
+	return user.PasswordHash == externalPasswordHash
+}
+
+// ExternalAuthService signs in users authenticated by an external identity
+// provider, creating their account on first sign-in. Their role follows
+// the provider groups they belong to whenever group roles are configured.
+type ExternalAuthService struct {
+	users      *UserService
+	sessions   *SessionService
+	directory  PasswordDirectory
+	groupRoles []GroupRole
+}
+
+// NewExternalAuthService constructs an ExternalAuthService. directory may
+// be nil when directory sign-in is not configured.
+func NewExternalAuthService(users *UserService, sessions *SessionService, directory PasswordDirectory, groupRoles []GroupRole) *ExternalAuthService {
+	return &ExternalAuthService{users: users, sessions: sessions, directory: directory, groupRoles: groupRoles}
+}
+
+// DirectoryEnabled reports whether passwords can be checked against a
+// directory.
+func (s *ExternalAuthService) DirectoryEnabled() bool {
+	return s.directory != nil
+}
+
+// DirectoryLogin checks the password against the directory and signs in
+// the user it describes. It returns ErrInvalidCredentials when the
+// directory rejects them.
+func (s *ExternalAuthService) DirectoryLogin(ctx context.Context, username, password string) (types.User, error) {
+	if s.directory == nil {
+		return types.User{}, errors.New("directory sign-in is not configured")
+	}
+	identity, err := s.directory.Authenticate(ctx, username, password)
+	if errors.Is(err, ldap.ErrInvalidCredentials) {
+		return types.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return types.User{}, fmt.Errorf("directory sign-in failed: %w", err)
+	}
+	return s.SignIn(ctx, identity)
+}
+
+// SignIn returns the account of a user authenticated by a provider,
+// creating it on first sign-in and otherwise refreshing its email, name
+// and role from the identity. When a user loses or changes a privileged
+// role, their other sessions are ended, since tokens carry the role they
+// were issued with.
+func (s *ExternalAuthService) SignIn(ctx context.Context, identity types.ExternalIdentity) (types.User, error) {
+	username := strings.TrimSpace(identity.Username)
+	email := strings.TrimSpace(identity.Email)
+	name := strings.TrimSpace(identity.Name)
+	if username == "" {
+		return types.User{}, fmt.Errorf("%w: no username", ErrInvalidExternalIdentity)
+	}
+
+	user, err := s.users.GetByUsername(ctx, username)
+	if errors.Is(err, store.ErrNotFound) {
+		if email == "" {
+			return types.User{}, fmt.Errorf("%w: no email address for %s", ErrInvalidExternalIdentity, username)
+		}
+		if name == "" {
+			name = username
+		}
+		return s.users.Create(ctx, types.User{
+			Username:     username,
+			Email:        email,
+			Name:         name,
+			Role:         s.role(identity.Groups, types.RoleUser),
+			PasswordHash: externalPasswordHash,
+		})
+	}
+	if err != nil {
+		return types.User{}, err
+	}
+	if !IsExternalAccount(user) {
+		return types.User{}, fmt.Errorf("%w: %s", ErrLocalAccountExists, username)
+	}
+
+	updated := user
+	if email != "" {
+		updated.Email = email
+	}
+	if name != "" {
+		updated.Name = name
+	}
+	updated.Role = s.role(identity.Groups, user.Role)
+	if updated == user {
+		return user, nil
+	}
+	updated, err = s.users.Update(ctx, updated)
+	if err != nil {
+		return types.User{}, err
+	}
+	if updated.Role != user.Role && user.Role != types.RoleUser {
+		if err := s.sessions.EndAll(ctx, user.ID); err != nil {
+			return types.User{}, fmt.Errorf("end sessions of %s: %w", username, err)
+		}
+	}
+	return updated, nil
+}
+
+// role returns the most privileged role granted by the groups, or current
+// when no group roles are configured.
+func (s *ExternalAuthService) role(groups []string, current string) string {
+	if len(s.groupRoles) == 0 {
+		return current
+	}
+	role := types.RoleUser
+	for _, mapping := range s.groupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(strings.TrimSpace(group), mapping.Group) && roleRank[mapping.Role] > roleRank[role] {
+				role = mapping.Role
+			}
+		}
+	}
+	return role
+}
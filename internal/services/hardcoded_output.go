@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	// SuspicionFlagScore is the suspicion score from which a submission is
+	// flagged for admin review.
+	SuspicionFlagScore = 50
+
+	// minHardcodedLiteral is the length, once whitespace is collapsed, a
+	// string literal needs to count as a hardcoded output. Shorter ones,
+	// such as "YES" or "Impossible", are ordinary program text.
+	minHardcodedLiteral = 24
+)
+
+// HardcodedOutputScore rates from 0 to 100 how much of an expected output
+// the source embeds as string literals, a common way to pass weak test
+// sets without solving the problem. It is the highest share of any one
+// test case's expected output, as reported with the results, covered by
+// long literals. Whitespace differences are ignored.
+func HardcodedOutputScore(code string, results []types.TestcaseResult) int {
+	var literals []string
+	seen := make(map[string]bool)
+	for _, literal := range stringLiterals(code) {
+		literal = collapseWhitespace(literal)
+		if len(literal) >= minHardcodedLiteral && !seen[literal] {
+			seen[literal] = true
+			literals = append(literals, literal)
+		}
+	}
+	if len(literals) == 0 {
+		return 0
+	}
+
+	best := 0
+	for _, result := range results {
+		expected := collapseWhitespace(result.ExpectedOutput)
+		if len(expected) < minHardcodedLiteral {
+			continue
+		}
+		covered := 0
+		for _, literal := range literals {
+			if strings.Contains(literal, expected) {
+				covered = len(expected)
+				break
+			}
+			if strings.Contains(expected, literal) {
+				covered += len(literal)
+			}
+		}
+		best = max(best, min(covered, len(expected))*100/len(expected))
+	}
+	return best
+}
+
+// stringLiterals returns the contents of the quoted strings in code, with
+// common escapes decoded. It understands the quoting of the supported
+// languages closely enough for scoring: "...", '...', `...` and Python's
+// triple quotes. A quote left open at the end of its line, such as an
+// apostrophe in a comment, does not start a literal.
+func stringLiterals(code string) []string {
+	var literals []string
+	for i := 0; i < len(code); i++ {
+		quote := code[i]
+		if quote != '"' && quote != '\'' && quote != '`' {
+			continue
+		}
+		delim := code[i : i+1]
+		if strings.HasPrefix(code[i:], strings.Repeat(delim, 3)) {
+			delim = strings.Repeat(delim, 3)
+		}
+		multiline := quote == '`' || len(delim) == 3
+
+		var b strings.Builder
+		j := i + len(delim)
+		closed := false
+		for j < len(code) {
+			if strings.HasPrefix(code[j:], delim) {
+				closed = true
+				break
+			}
+			c := code[j]
+			if c == '\n' && !multiline {
+				break
+			}
+			if c == '\\' && quote != '`' && j+1 < len(code) {
+				b.WriteByte(unescapeByte(code[j+1]))
+				j += 2
+				continue
+			}
+			b.WriteByte(c)
+			j++
+		}
+		if !closed {
+			continue
+		}
+		literals = append(literals, b.String())
+		i = j + len(delim) - 1
+	}
+	return literals
+}
+
+// unescapeByte decodes the character after a backslash.
+func unescapeByte(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	default:
+		return c
+	}
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []string
+	}{
+		{"double and single quotes", `print("a", 'b')`, []string{"a", "b"}},
+		{"backquotes", "s := `a\\n\nb`", []string{"a\\n\nb"}},
+		{"python triple quotes", "s = \"\"\"one\ntwo \"quoted\" \"\"\"\nt = '''x'''", []string{"one\ntwo \"quoted\" ", "x"}},
+		{"empty string then a quote", `print(""'text')`, []string{"", "text"}},
+		{"quote after an empty triple", `s = """"quoted" text"""`, []string{`"quoted" text`}},
+		{"escaped quotes", `puts("say \"hi\""); c = '\''`, []string{`say "hi"`, "'"}},
+		{"escaped backslash", `s = "C:\\"; t = "x"`, []string{`C:\`, "x"}},
+		{"escapes", `"a\tb\nc\rd\qe"`, []string{"a\tb\nc\rdqe"}},
+		{"apostrophe in a comment", "// don't\nputs(\"x\");", []string{"x"}},
+		{"apostrophes in a comment", "# don't, won't\nprint('x')", []string{"t, won", "x"}},
+		{"unclosed at the end", `s = "abc`, nil},
+		{"unclosed on its line", "s = \"abc\nt = \"x\"", []string{"x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stringLiterals(tt.code); !slices.Equal(got, tt.want) {
+				t.Fatalf("stringLiterals(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHardcodedOutputScore(t *testing.T) {
+	const (
+		// fibonacci is 44 bytes long.
+		fibonacci = "0 1 1 2 3 5 8 13 21 34 55 89 144 233 377 610"
+		// counting is 80 bytes long.
+		counting = "1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30"
+		// letters is minHardcodedLiteral bytes long.
+		letters = "abcdefghijklmnopqrstuvwx"
+	)
+	tests := []struct {
+		name     string
+		code     string
+		expected []string
+		want     int
+	}{
+		{"whole output", `print("` + fibonacci + `")`, []string{fibonacci}, 100},
+		{"different whitespace", "print(\"0 1 1 2 3 5 8 13\\n21 34 55 89 144 233 377 610\\n\")", []string{"0\n1\n1\n2\n3\n5\n8\n13\n21\n34\n55\n89\n144\n233\n377\n610\n"}, 100},
+		{"output inside a literal", `print("result: ` + fibonacci + ` done")`, []string{fibonacci}, 100},
+		{"python triple quotes", "print(\"\"\"0 1 1 2 3 5 8\n13 21 34 55 89 144 233 377 610\"\"\")", []string{fibonacci}, 100},
+		{"python triple single quotes", "print('''" + fibonacci + "''')", []string{fibonacci}, 100},
+		{"empty string then a quote", `print(""'` + fibonacci + `')`, []string{fibonacci}, 100},
+		{"quote after an empty triple", `print(""""` + fibonacci + `""")`, []string{fibonacci}, 100},
+		{"escaped quotes", `puts("say \"hello\" to everyone in the room");`, []string{`say "hello" to everyone in the room`}, 100},
+		{"escaped backslash", `dir = "C:\\"; print("` + fibonacci + `")`, []string{fibonacci}, 100},
+		{"apostrophe in a comment", "// the answer isn't computed\nputs(\"" + fibonacci + "\");", []string{fibonacci}, 100},
+		{"split with a short part", `print("0 1 1 2 3 5 8 13 21 34 55" + " 89 144 233 377 610")`, []string{fibonacci}, 25 * 100 / 44},
+		{"split with long parts", `print("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 " + "16 17 18 19 20 21 22 23 24 25 26 27 28 29 30")`, []string{counting}, (35 + 44) * 100 / 80},
+		{"repeated part", `print("` + letters + `" + " " + "` + letters + `")`, []string{letters + " " + letters}, 24 * 100 / 49},
+		{"literal at the minimum", `print("` + letters + `")`, []string{letters + " " + letters}, 24 * 100 / 49},
+		{"literal below the minimum", `print("` + letters[1:] + `")`, []string{letters + " " + letters}, 0},
+		{"literal padded to the minimum", `print("  ` + letters[1:] + ` ")`, []string{letters + " " + letters}, 0},
+		{"output at the minimum", `print("` + letters + `")`, []string{letters}, 100},
+		{"output below the minimum", `print("` + letters[1:] + `")`, []string{letters[1:]}, 0},
+		{"best test case", `print("` + fibonacci + `")`, []string{counting, fibonacci, ""}, 100},
+		{"no literals", "print(sum(map(int, input().split())))", []string{fibonacci}, 0},
+		{"no results", `print("` + fibonacci + `")`, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []types.TestcaseResult
+			for i, expected := range tt.expected {
+				results = append(results, types.TestcaseResult{TestcaseID: i + 1, ExpectedOutput: expected})
+			}
+			if got := HardcodedOutputScore(tt.code, results); got != tt.want {
+				t.Fatalf("HardcodedOutputScore() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
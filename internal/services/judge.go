@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// JudgeService encapsulates use-cases consumed by judge workers.
+type JudgeService struct {
+	problems ProblemRepository
+	storage  *storage.Storage
+}
+
+func NewJudgeService(problems ProblemRepository, objectStorage *storage.Storage) *JudgeService {
+	return &JudgeService{
+		problems: problems,
+		storage:  objectStorage,
+	}
+}
+
+// bundleURLExpiry is how long presigned bundle download URLs stay valid.
+const bundleURLExpiry = 15 * time.Minute
+
+// BundleDownload is a presigned download of a problem's latest testcase
+// bundle.
+type BundleDownload struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SHA256    string    `json:"sha256"`
+	Version   int       `json:"version"`
+}
+
+// BundleDownloadURL presigns a download of the latest testcase bundle of a
+// problem so workers fetch it straight from object storage.
+func (s *JudgeService) BundleDownloadURL(ctx context.Context, problemID int) (BundleDownload, error) {
+	bundle, err := s.storedBundle(ctx, problemID)
+	if err != nil {
+		return BundleDownload{}, err
+	}
+
+	expiresAt := time.Now().Add(bundleURLExpiry)
+	url, err := s.storage.PresignGet(ctx, bundle.ObjectKey, bundleURLExpiry)
+	if err != nil {
+		return BundleDownload{}, fmt.Errorf("failed to presign bundle %s: %w", bundle.ObjectKey, err)
+	}
+	return BundleDownload{
+		URL:       url,
+		ExpiresAt: expiresAt,
+		SHA256:    bundle.SHA256,
+		Version:   bundle.Version,
+	}, nil
+}
+
+// CheckBundleAccess verifies that the latest testcase bundle of a problem can
+// be read from object storage.
+func (s *JudgeService) CheckBundleAccess(ctx context.Context, problemID int) error {
+	reader, bundle, err := s.OpenBundle(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var buf [1]byte
+	if _, err := reader.Read(buf[:]); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read bundle %s: %w", bundle.ObjectKey, err)
+	}
+	return nil
+}
+
+// OpenBundle opens the latest testcase bundle of a problem for streaming to
+// workers that cannot reach object storage directly. The caller must close
+// the reader.
+func (s *JudgeService) OpenBundle(ctx context.Context, problemID int) (io.ReadCloser, types.TestcaseBundle, error) {
+	bundle, err := s.storedBundle(ctx, problemID)
+	if err != nil {
+		return nil, types.TestcaseBundle{}, err
+	}
+	reader, err := s.storage.Get(ctx, bundle.ObjectKey)
+	if err != nil {
+		return nil, types.TestcaseBundle{}, fmt.Errorf("failed to open bundle %s: %w", bundle.ObjectKey, err)
+	}
+	return reader, bundle, nil
+}
+
+// storedBundle returns the latest testcase bundle of a problem if it can be
+// served from object storage.
+func (s *JudgeService) storedBundle(ctx context.Context, problemID int) (types.TestcaseBundle, error) {
+	if s.storage == nil {
+		return types.TestcaseBundle{}, errors.New("object storage is not configured")
+	}
+
+	bundle, err := s.problems.GetLatestTestcaseBundle(ctx, problemID)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	if strings.TrimSpace(bundle.ObjectKey) == "" {
+		return types.TestcaseBundle{}, fmt.Errorf("problem %d has no stored testcase bundle", problemID)
+	}
+	if bundle.Corrupted {
+		return types.TestcaseBundle{}, fmt.Errorf("%w: %s", ErrBundleCorrupted, bundle.ObjectKey)
+	}
+	return bundle, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrInvalidJudgeWorker is returned when a worker registration is malformed.
+var ErrInvalidJudgeWorker = errors.New("invalid judge worker")
+
+var judgePoolPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// dispatchCacheTTL bounds how long a snapshot of live workers is reused
+// before routing decisions reload the registry.
+const dispatchCacheTTL = 10 * time.Second
+
+// JudgeWorkerRepository defines persistence operations for the worker registry.
+type JudgeWorkerRepository interface {
+	Upsert(ctx context.Context, worker types.JudgeWorker) (types.JudgeWorker, error)
+	ListActive(ctx context.Context, since time.Time) ([]types.JudgeWorker, error)
+}
+
+// JudgeDispatcher routes judge jobs to per-pool channels based on the
+// languages advertised by live workers. Jobs no live pool can run go to the
+// base channel, which general-purpose workers consume.
+type JudgeDispatcher struct {
+	workers   JudgeWorkerRepository
+	channel   string
+	workerTTL time.Duration
+
+	mu        sync.Mutex
+	snapshot  []types.JudgeWorker
+	fetchedAt time.Time
+}
+
+// NewJudgeDispatcher constructs a JudgeDispatcher. Workers that have not sent
+// a heartbeat within workerTTL are not routed to.
+func NewJudgeDispatcher(workers JudgeWorkerRepository, channel string, workerTTL time.Duration) *JudgeDispatcher {
+	return &JudgeDispatcher{
+		workers:   workers,
+		channel:   channel,
+		workerTTL: workerTTL,
+	}
+}
+
+// Register records a worker registration or heartbeat and returns the worker
+// with the channel its pool consumes.
+func (d *JudgeDispatcher) Register(ctx context.Context, worker types.JudgeWorker) (types.JudgeWorker, error) {
+	worker.ID = strings.TrimSpace(worker.ID)
+	worker.Pool = strings.ToLower(strings.TrimSpace(worker.Pool))
+	worker.Arch = strings.ToLower(strings.TrimSpace(worker.Arch))
+	if worker.ID == "" || len(worker.ID) > 128 {
+		return types.JudgeWorker{}, fmt.Errorf("%w: id is required", ErrInvalidJudgeWorker)
+	}
+	if !judgePoolPattern.MatchString(worker.Pool) {
+		return types.JudgeWorker{}, fmt.Errorf("%w: invalid pool %q", ErrInvalidJudgeWorker, worker.Pool)
+	}
+	if len(worker.Languages) == 0 {
+		return types.JudgeWorker{}, fmt.Errorf("%w: languages are required", ErrInvalidJudgeWorker)
+	}
+	for _, id := range worker.Languages {
+		if _, ok := LookupLanguage(id); !ok && id != OutputOnlyLanguage {
+			return types.JudgeWorker{}, fmt.Errorf("%w: unknown language %q", ErrInvalidJudgeWorker, id)
+		}
+	}
+	slices.Sort(worker.Languages)
+	worker.Languages = slices.Compact(worker.Languages)
+	worker.LastSeenAt = time.Now()
+
+	saved, err := d.workers.Upsert(ctx, worker)
+	if err != nil {
+		return types.JudgeWorker{}, err
+	}
+	saved.Channel = d.PoolChannel(saved.Pool)
+	return saved, nil
+}
+
+// PoolChannel returns the job channel consumed by the named pool.
+func (d *JudgeDispatcher) PoolChannel(pool string) string {
+	return d.channel + "." + pool
+}
+
+// Route returns the channel a job in the given language should be published
+// to: that of the pool with the most live workers supporting the language,
+// ties broken by pool name, or the base channel if there is none.
+func (d *JudgeDispatcher) Route(ctx context.Context, language string) string {
+	workers, err := d.liveWorkers(ctx)
+	if err != nil {
+		// Fall back to the shared queue rather than dropping the job.
+		log.Printf("judge dispatch: failed to load workers: %v", err)
+		return d.channel
+	}
+
+	counts := make(map[string]int)
+	for _, worker := range workers {
+		if slices.Contains(worker.Languages, language) {
+			counts[worker.Pool]++
+		}
+	}
+
+	best := ""
+	for pool, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && pool < best) {
+			best = pool
+		}
+	}
+	if best == "" {
+		return d.channel
+	}
+	return d.PoolChannel(best)
+}
+
+func (d *JudgeDispatcher) liveWorkers(ctx context.Context) ([]types.JudgeWorker, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if !d.fetchedAt.IsZero() && now.Sub(d.fetchedAt) < dispatchCacheTTL {
+		return d.snapshot, nil
+	}
+
+	workers, err := d.workers.ListActive(ctx, now.Add(-d.workerTTL))
+	if err != nil {
+		return nil, err
+	}
+	d.snapshot = workers
+	d.fetchedAt = now
+	return workers, nil
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/mq"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Judge job priorities. Higher values are delivered first by backends that
+// support priorities (see mq.AttrPriority).
+const (
+	JudgePriorityRejudge  = 1
+	JudgePriorityPractice = 5
+	JudgePriorityContest  = 9
+)
+
+// JudgeQueue publishes judge jobs for submissions.
+type JudgeQueue struct {
+	queue       *mq.MQ
+	dispatcher  *JudgeDispatcher
+	contentType string
+}
+
+// NewJudgeQueue constructs a JudgeQueue that publishes to the channels chosen
+// by dispatcher, encoding jobs in contentType (see JudgeContentType). queue
+// may be nil, in which case no jobs are published.
+func NewJudgeQueue(queue *mq.MQ, dispatcher *JudgeDispatcher, contentType string) *JudgeQueue {
+	return &JudgeQueue{queue: queue, dispatcher: dispatcher, contentType: contentType}
+}
+
+// JudgeContentType maps a configured judge message encoding, "json" or
+// "protobuf", to the content type jobs are published with.
+func JudgeContentType(encoding string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "json":
+		return types.JudgeContentTypeJSON, nil
+	case "protobuf":
+		return types.JudgeContentTypeProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported judge message encoding: %s", encoding)
+	}
+}
+
+// ErrJudgeQueueUnavailable is returned by operations that cannot complete
+// without publishing judge jobs when no queue is configured.
+var ErrJudgeQueueUnavailable = errors.New("judge queue is not configured")
+
+// Enabled reports whether jobs are actually published.
+func (q *JudgeQueue) Enabled() bool {
+	return q != nil && q.queue != nil && q.dispatcher != nil && q.dispatcher.channel != ""
+}
+
+// Enqueue publishes a judge job for the submission to problem with the
+// given priority. A nil JudgeQueue, or one without a queue, discards jobs.
+// Limits are scaled by the submission language's multipliers. Jobs other
+// than rejudges carry the submission time for judge latency metrics.
+func (q *JudgeQueue) Enqueue(ctx context.Context, submission types.Submission, problem types.Problem, priority int) error {
+	timeLimit, memoryLimit := ScaleLimits(submission.Language, problem.TimeLimit, problem.MemoryLimit)
+	var submittedAt int64
+	if priority != JudgePriorityRejudge {
+		submittedAt = submission.CreatedAt.UnixMilli()
+	}
+	return q.publish(ctx, types.JudgeJob{
+		Kind:           types.JudgeJobSubmission,
+		SubmissionID:   submission.ID,
+		ProblemID:      submission.ProblemID,
+		UserID:         submission.UserID,
+		Language:       submission.Language,
+		TimeLimit:      int(timeLimit),
+		MemoryLimit:    int(memoryLimit),
+		LimitOverrides: limitOverrides(problem.TestcaseBundle.TestcaseGroups, submission.Language),
+		SubmittedAt:    submittedAt,
+		Contest:        priority == JudgePriorityContest,
+	}, priority)
+}
+
+// limitOverrides lists the test cases whose limits differ from the
+// problem's, scaled for the given language. A zero limit in an override
+// means the problem's.
+func limitOverrides(groups []types.TestcaseGroup, language string) []types.LimitOverride {
+	var overrides []types.LimitOverride
+	for _, group := range groups {
+		for _, testcase := range group.Testcases {
+			if testcase.TimeLimit == 0 && testcase.MemoryLimit == 0 {
+				continue
+			}
+			timeLimit, memoryLimit := ScaleLimits(language, testcase.TimeLimit, testcase.MemoryLimit)
+			overrides = append(overrides, types.LimitOverride{
+				Group:       group.OrderID,
+				Testcase:    testcase.OrderID,
+				TimeLimit:   timeLimit,
+				MemoryLimit: memoryLimit,
+			})
+		}
+	}
+	return overrides
+}
+
+// EnqueueRun publishes a custom run. Code, input and limits travel in the
+// job itself since runs are small and not tied to a problem bundle.
+func (q *JudgeQueue) EnqueueRun(ctx context.Context, run types.Run) error {
+	timeLimit, memoryLimit := ScaleLimits(run.Language, RunTimeLimit, RunMemoryLimit)
+	return q.publish(ctx, types.JudgeJob{
+		Kind:        types.JudgeJobRun,
+		RunID:       run.ID,
+		UserID:      run.UserID,
+		Language:    run.Language,
+		Code:        run.Code,
+		Stdin:       run.Stdin,
+		TimeLimit:   int(timeLimit),
+		MemoryLimit: int(memoryLimit),
+	}, JudgePriorityPractice)
+}
+
+// EnqueueValidation publishes a job judging a reference solution against
+// the problem's current bundle. Workers read the solution source from the
+// bundle and report back the verdict it received.
+func (q *JudgeQueue) EnqueueValidation(ctx context.Context, problem types.Problem, solution types.ReferenceSolution) error {
+	expected := solution.Expected
+	timeLimit, memoryLimit := ScaleLimits(solution.Language, problem.TimeLimit, problem.MemoryLimit)
+	return q.publish(ctx, types.JudgeJob{
+		Kind:           types.JudgeJobValidation,
+		ProblemID:      problem.ID,
+		BundleVersion:  problem.TestcaseBundle.Version,
+		ObjectKey:      problem.TestcaseBundle.ObjectKey,
+		Solution:       solution.File,
+		Language:       solution.Language,
+		Expected:       &expected,
+		TimeLimit:      int(timeLimit),
+		MemoryLimit:    int(memoryLimit),
+		LimitOverrides: limitOverrides(problem.TestcaseBundle.TestcaseGroups, solution.Language),
+	}, JudgePriorityPractice)
+}
+
+// EnqueueInputValidation publishes a job running the input validator of the
+// problem's current bundle against every testcase input.
+func (q *JudgeQueue) EnqueueInputValidation(ctx context.Context, problem types.Problem) error {
+	validator := problem.TestcaseBundle.Validator
+	return q.publish(ctx, types.JudgeJob{
+		Kind:          types.JudgeJobInputValidation,
+		ProblemID:     problem.ID,
+		BundleVersion: problem.TestcaseBundle.Version,
+		ObjectKey:     problem.TestcaseBundle.ObjectKey,
+		Validator:     validator,
+		Language:      ValidatorLanguage(validator),
+	}, JudgePriorityPractice)
+}
+
+// EnqueueGeneration publishes a job materializing the generated testcases
+// of the problem's current bundle. The worker runs each generator listed in
+// the manifest, produces outputs with the accepted solution, and uploads
+// the resulting bundle.
+func (q *JudgeQueue) EnqueueGeneration(ctx context.Context, problem types.Problem, solution types.ReferenceSolution) error {
+	return q.publish(ctx, types.JudgeJob{
+		Kind:          types.JudgeJobGeneration,
+		ProblemID:     problem.ID,
+		BundleVersion: problem.TestcaseBundle.Version,
+		ObjectKey:     problem.TestcaseBundle.ObjectKey,
+		Manifest:      problem.TestcaseBundle.Generation,
+		Solution:      solution.File,
+		Language:      solution.Language,
+	}, JudgePriorityPractice)
+}
+
+// Next takes a single job from the channel consumed by the named pool, or
+// from the base channel when pool is empty, waiting until one arrives or
+// ctx is done. Jobs that cannot be decoded are logged and dropped.
+func (q *JudgeQueue) Next(ctx context.Context, pool string) (types.JudgeJob, error) {
+	if !q.Enabled() {
+		return types.JudgeJob{}, ErrJudgeQueueUnavailable
+	}
+	channel := q.dispatcher.channel
+	if pool != "" {
+		if !judgePoolPattern.MatchString(pool) {
+			return types.JudgeJob{}, fmt.Errorf("%w: invalid pool %q", ErrInvalidJudgeWorker, pool)
+		}
+		channel = q.dispatcher.PoolChannel(pool)
+	}
+
+	// The handler hands over at most one job; once Next returns, the
+	// subscription is cancelled and anything else in flight is nacked.
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan types.JudgeJob)
+	done := make(chan error, 1)
+	go func() {
+		done <- q.queue.Subscribe(subCtx, channel, func(_ context.Context, msg mq.Message) error {
+			job, err := types.DecodeJudgeJob(msg.Data, msg.Attributes[mq.AttrContentType])
+			if err != nil {
+				log.Printf("judge queue: dropping undecodable job %s on %s: %v", msg.ID, channel, err)
+				return nil
+			}
+			select {
+			case jobs <- job:
+				return nil
+			case <-subCtx.Done():
+				return subCtx.Err()
+			}
+		})
+	}()
+
+	select {
+	case job := <-jobs:
+		return job, nil
+	case err := <-done:
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return types.JudgeJob{}, ctxErr
+		}
+		if err == nil {
+			err = fmt.Errorf("subscription to %s ended", channel)
+		}
+		return types.JudgeJob{}, err
+	}
+}
+
+// publish stamps the job with the current protocol version, validates it
+// and sends it, encoded in the queue's content type, to the channel serving
+// its language.
+func (q *JudgeQueue) publish(ctx context.Context, job types.JudgeJob, priority int) error {
+	if !q.Enabled() {
+		return nil
+	}
+
+	job.Version = types.JudgeProtocolVersion
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	data, err := types.EncodeJudgeJob(job, q.contentType)
+	if err != nil {
+		return err
+	}
+	_, err = q.queue.Publish(ctx, q.dispatcher.Route(ctx, job.Language), data, map[string]string{
+		mq.AttrPriority:    strconv.Itoa(priority),
+		mq.AttrContentType: q.contentType,
+	})
+	return err
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/jjudge-oj/apiserver/internal/metrics"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrStaleJudgeResult is returned when a submission result was judged
+// against a testcase bundle version other than the problem's current one.
+var ErrStaleJudgeResult = errors.New("judge result is for an outdated testcase bundle")
+
+// JudgeResultService applies results reported by judge workers, routing
+// each to the service owning its job kind.
+type JudgeResultService struct {
+	submissions   *SubmissionService
+	problems      *ProblemService
+	runs          *RunService
+	validations   *ProblemValidationService
+	notifications *NotificationService
+
+	// maxCompileOutput caps the compiler output stored per stream.
+	maxCompileOutput int
+
+	latency *metrics.Histogram
+}
+
+// NewJudgeResultService constructs a JudgeResultService. Compiler output
+// of submissions is truncated to maxCompileOutput bytes per stream; zero
+// means 64 KiB.
+func NewJudgeResultService(
+	submissions *SubmissionService,
+	problems *ProblemService,
+	runs *RunService,
+	validations *ProblemValidationService,
+	notifications *NotificationService,
+	maxCompileOutput int,
+) *JudgeResultService {
+	if maxCompileOutput <= 0 {
+		maxCompileOutput = 64 << 10
+	}
+	return &JudgeResultService{
+		submissions:      submissions,
+		problems:         problems,
+		runs:             runs,
+		validations:      validations,
+		notifications:    notifications,
+		maxCompileOutput: maxCompileOutput,
+	}
+}
+
+// NewJudgeLatencyHistogram constructs the histogram of the time from
+// submission to final verdict, labeled by language and by mode, "contest"
+// or "practice". p50 and p95 come from histogram_quantile over it.
+func NewJudgeLatencyHistogram() *metrics.Histogram {
+	return metrics.NewHistogram(
+		"jjudge_judge_latency_seconds",
+		"Time from submission to final verdict.",
+		[]float64{0.5, 1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 1800},
+		"language", "mode",
+	)
+}
+
+// WithLatency records the judge latency of submission results in h, using
+// the timestamps carried by the results.
+func (s *JudgeResultService) WithLatency(h *metrics.Histogram) *JudgeResultService {
+	s.latency = h
+	return s
+}
+
+// Apply stores a validated judge result. Generated bundles are not
+// results; workers upload them instead.
+func (s *JudgeResultService) Apply(ctx context.Context, result types.JudgeResult) error {
+	switch result.Kind {
+	case types.JudgeJobSubmission:
+		return s.applySubmission(ctx, result)
+	case types.JudgeJobRun:
+		_, err := s.runs.Complete(ctx, types.Run{
+			ID:      result.RunID,
+			Verdict: result.Verdict,
+			Stdout:  result.Stdout,
+			Stderr:  result.Stderr,
+			CPUTime: result.CPUTime,
+			Memory:  result.Memory,
+		})
+		return err
+	case types.JudgeJobValidation:
+		_, err := s.validations.Report(ctx, types.SolutionValidation{
+			ProblemID:         result.ProblemID,
+			BundleVersion:     result.BundleVersion,
+			ReferenceSolution: types.ReferenceSolution{File: result.Solution},
+			Verdict:           result.Verdict,
+			Message:           result.Message,
+		})
+		return err
+	case types.JudgeJobInputValidation:
+		_, err := s.validations.ReportInputs(ctx, types.InputValidation{
+			ProblemID:     result.ProblemID,
+			BundleVersion: result.BundleVersion,
+			Errors:        result.InputErrors,
+		})
+		return err
+	default:
+		return fmt.Errorf("%w: %s results are reported by uploading the generated bundle", types.ErrInvalidJudgeMessage, result.Kind)
+	}
+}
+
+// applySubmission scores a submission's test case results. A result
+// without test case results, such as a compilation error, sets the
+// verdict directly. Results for cancelled submissions are dropped, also
+// when the cancellation lands while the result is being applied.
+func (s *JudgeResultService) applySubmission(ctx context.Context, result types.JudgeResult) error {
+	submission, err := s.submissions.Get(ctx, int64(result.SubmissionID))
+	if err != nil {
+		return err
+	}
+	// Results arrive outside of any request, so the tenant the events and
+	// notifications belong to is the submission's.
+	ctx = store.WithTenant(ctx, submission.TenantID)
+	if submission.Verdict == types.VerdictCancelled {
+		// The job was already queued when the submitter cancelled it.
+		return nil
+	}
+	problem, err := s.problems.Get(ctx, submission.ProblemID)
+	if err != nil {
+		return err
+	}
+	if result.BundleVersion > 0 && result.BundleVersion != problem.TestcaseBundle.Version {
+		return fmt.Errorf("%w: judged version %d, current version %d", ErrStaleJudgeResult, result.BundleVersion, problem.TestcaseBundle.Version)
+	}
+
+	submission.Message = result.Message
+	submission.CompileStdout = truncateOutput(result.CompileStdout, s.maxCompileOutput)
+	submission.CompileStderr = truncateOutput(result.CompileStderr, s.maxCompileOutput)
+	if len(result.TestcaseResults) > 0 {
+		submission, err = s.submissions.ApplyResults(ctx, submission, problem.TestcaseBundle, result.TestcaseResults)
+		if errors.Is(err, store.ErrCancelled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.observeLatency(submission, result)
+		s.notifyJudged(ctx, submission, problem)
+		return nil
+	}
+
+	switch result.Verdict {
+	case types.VerdictPending, types.VerdictJudging, types.VerdictCancelled:
+		return fmt.Errorf("%w: submission results require a final verdict", types.ErrInvalidJudgeMessage)
+	case types.VerdictAccepted:
+		return fmt.Errorf("%w: accepted submission results require testcase_results", types.ErrInvalidJudgeMessage)
+	}
+	submission.Verdict = result.Verdict
+	submission.CPUTime = result.CPUTime
+	submission.Memory = result.Memory
+	submission, err = s.submissions.UpdateJudged(ctx, submission)
+	if errors.Is(err, store.ErrCancelled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.observeLatency(submission, result)
+	s.notifyJudged(ctx, submission, problem)
+	return nil
+}
+
+// observeLatency records the time between the submission and its verdict.
+// Results from workers that do not echo the timestamps, and rejudges, are
+// not recorded.
+func (s *JudgeResultService) observeLatency(submission types.Submission, result types.JudgeResult) {
+	if result.SubmittedAt <= 0 || result.JudgedAt < result.SubmittedAt {
+		return
+	}
+	mode := "practice"
+	if result.Contest {
+		mode = "contest"
+	}
+	s.latency.Observe(float64(result.JudgedAt-result.SubmittedAt)/1000, submission.Language, mode)
+}
+
+// notifyJudged tells the submitter their submission was judged. The
+// verdict is already stored, so a failure is only logged rather than
+// making the worker report the result again.
+func (s *JudgeResultService) notifyJudged(ctx context.Context, submission types.Submission, problem types.Problem) {
+	body := fmt.Sprintf("Your submission to %s was judged %s", problem.Title, submission.Verdict)
+	if submission.TestsTotal > 0 {
+		body += fmt.Sprintf(", passing %d of %d tests with a score of %d", submission.TestsPassed, submission.TestsTotal, submission.Score)
+	}
+	err := s.notifications.Notify(ctx, types.Notification{
+		UserID:    submission.UserID,
+		Type:      types.NotificationSubmissionJudged,
+		Title:     fmt.Sprintf("Submission #%d: %s", submission.ID, submission.Verdict),
+		Body:      body + ".",
+		SubjectID: int64(submission.ID),
+	})
+	if err != nil {
+		log.Printf("notifications: failed to notify user %d of submission %d: %v", submission.UserID, submission.ID, err)
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// fakeSubmissionRepo keeps submissions in memory. Like the store, it
+// refuses judged outcomes for cancelled submissions at write time.
+type fakeSubmissionRepo struct {
+	SubmissionRepository
+
+	mu          sync.Mutex
+	submissions map[int64]types.Submission
+	writes      int
+}
+
+func (r *fakeSubmissionRepo) Get(_ context.Context, id int64) (types.Submission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	submission, ok := r.submissions[id]
+	if !ok {
+		return types.Submission{}, store.ErrNotFound
+	}
+	return submission, nil
+}
+
+func (r *fakeSubmissionRepo) CancelPending(_ context.Context, id int64, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	submission, ok := r.submissions[id]
+	if !ok || submission.Verdict != types.VerdictPending {
+		return store.ErrNotFound
+	}
+	submission.Verdict = types.VerdictCancelled
+	submission.UpdatedAt = at
+	r.submissions[id] = submission
+	return nil
+}
+
+func (r *fakeSubmissionRepo) UpdateJudged(_ context.Context, submission types.Submission) (types.Submission, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.submissions[int64(submission.ID)]
+	if !ok {
+		return types.Submission{}, store.ErrNotFound
+	}
+	if current.Verdict == types.VerdictCancelled {
+		return types.Submission{}, store.ErrCancelled
+	}
+	r.writes++
+	r.submissions[int64(submission.ID)] = submission
+	return submission, nil
+}
+
+// hookedProblemRepo runs beforeGet when the problem is loaded, which
+// applySubmission does after loading the submission and before storing
+// the outcome.
+type hookedProblemRepo struct {
+	ProblemRepository
+	problem   types.Problem
+	beforeGet func()
+}
+
+func (r *hookedProblemRepo) Get(_ context.Context, id int) (types.Problem, error) {
+	if r.beforeGet != nil {
+		r.beforeGet()
+	}
+	if id != r.problem.ID {
+		return types.Problem{}, store.ErrNotFound
+	}
+	return r.problem, nil
+}
+
+func TestApplySubmissionDropsResultCancelledMidway(t *testing.T) {
+	tests := []struct {
+		name   string
+		result types.JudgeResult
+	}{
+		{
+			name: "testcase results",
+			result: types.JudgeResult{
+				Kind:         types.JudgeJobSubmission,
+				SubmissionID: 7,
+				Verdict:      types.VerdictAccepted,
+				TestcaseResults: []types.TestcaseResult{
+					{Verdict: types.VerdictAccepted},
+				},
+			},
+		},
+		{
+			name: "verdict only",
+			result: types.JudgeResult{
+				Kind:         types.JudgeJobSubmission,
+				SubmissionID: 7,
+				Verdict:      types.VerdictCompilationError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := &fakeSubmissionRepo{submissions: map[int64]types.Submission{
+				7: {ID: 7, ProblemID: 3, UserID: 5, Verdict: types.VerdictPending},
+			}}
+			submissions := NewSubmissionService(repo, nil, nil, nil)
+			problems := &hookedProblemRepo{problem: types.Problem{ID: 3}}
+			problems.beforeGet = func() {
+				if _, err := submissions.Cancel(ctx, 7, 5); err != nil {
+					t.Errorf("cancel: %v", err)
+				}
+			}
+			results := NewJudgeResultService(submissions, NewProblemService(problems, nil), nil, nil, nil, 0)
+
+			if err := results.Apply(ctx, tt.result); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if repo.writes != 0 {
+				t.Fatalf("stored %d outcomes for a cancelled submission", repo.writes)
+			}
+			if got := repo.submissions[7].Verdict; got != types.VerdictCancelled {
+				t.Fatalf("verdict = %s, want %s", got, types.VerdictCancelled)
+			}
+		})
+	}
+}
+
+func TestApplySubmissionStoresResult(t *testing.T) {
+	repo := &fakeSubmissionRepo{submissions: map[int64]types.Submission{
+		7: {ID: 7, ProblemID: 3, UserID: 5, Verdict: types.VerdictPending},
+	}}
+	submissions := NewSubmissionService(repo, nil, nil, nil)
+	problems := NewProblemService(&hookedProblemRepo{problem: types.Problem{ID: 3}}, nil)
+	results := NewJudgeResultService(submissions, problems, nil, nil, nil, 0)
+
+	err := results.Apply(context.Background(), types.JudgeResult{
+		Kind:         types.JudgeJobSubmission,
+		SubmissionID: 7,
+		Verdict:      types.VerdictCompilationError,
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got := repo.submissions[7].Verdict; got != types.VerdictCompilationError {
+		t.Fatalf("verdict = %s, want %s", got, types.VerdictCompilationError)
+	}
+}
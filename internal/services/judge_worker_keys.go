@@ -0,0 +1,38 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// JudgeWorkerKeys maps per-worker API keys to worker names.
+type JudgeWorkerKeys map[string]string
+
+// ParseJudgeWorkerKeys parses a comma-separated list of name:key pairs.
+func ParseJudgeWorkerKeys(spec string) (JudgeWorkerKeys, error) {
+	keys := JudgeWorkerKeys{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(entry, ":")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("invalid judge worker key %q, expected name:key", name)
+		}
+		keys[key] = name
+	}
+	return keys, nil
+}
+
+// Valid reports whether key belongs to a worker. Every key is compared so
+// that timing does not reveal which one matched.
+func (k JudgeWorkerKeys) Valid(key string) bool {
+	match := 0
+	for candidate := range k {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(candidate))
+	}
+	return match == 1
+}
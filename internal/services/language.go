@@ -0,0 +1,284 @@
+package services
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// minDetectionScore is the keyword score a language must reach before it is
+// assigned automatically to a submission that omitted its language.
+const minDetectionScore = 3
+
+// DefaultLanguages is the registry of languages accepted by the judge.
+var DefaultLanguages = []types.Language{
+	{
+		ID:               "cpp",
+		Name:             "C++",
+		Extension:        "cpp",
+		CompileCommand:   "g++ -std=gnu++20 -O2 -pipe -o main main.cpp",
+		ExecuteCommand:   "./main",
+		Version:          "gnu++20",
+		Aliases:          []string{"c++", "cc", "cxx", "g++", "gnu++20", "c++20"},
+		Highlight:        "cpp",
+		TimeMultiplier:   1,
+		MemoryMultiplier: 1,
+	},
+	{
+		ID:               "c",
+		Name:             "C",
+		Extension:        "c",
+		CompileCommand:   "gcc -std=gnu17 -O2 -pipe -o main main.c -lm",
+		ExecuteCommand:   "./main",
+		Version:          "gnu17",
+		Aliases:          []string{"gcc", "gnu17", "c17"},
+		Highlight:        "c",
+		TimeMultiplier:   1,
+		MemoryMultiplier: 1,
+	},
+	{
+		ID:               "java",
+		Name:             "Java",
+		Extension:        "java",
+		CompileCommand:   "javac Main.java",
+		ExecuteCommand:   "java -Xss64m Main",
+		Version:          "21",
+		Highlight:        "java",
+		TimeMultiplier:   2,
+		MemoryMultiplier: 1.5,
+	},
+	{
+		ID:               "python",
+		Name:             "Python 3",
+		Extension:        "py",
+		ExecuteCommand:   "python3 main.py",
+		Version:          "3.12",
+		Aliases:          []string{"py3", "python3"},
+		Highlight:        "python",
+		TimeMultiplier:   3,
+		MemoryMultiplier: 1.5,
+	},
+	{
+		ID:               "go",
+		Name:             "Go",
+		Extension:        "go",
+		CompileCommand:   "go build -o main main.go",
+		ExecuteCommand:   "./main",
+		Version:          "1.25",
+		Aliases:          []string{"golang"},
+		Highlight:        "go",
+		TimeMultiplier:   1.5,
+		MemoryMultiplier: 1.5,
+	},
+	{
+		ID:               "rust",
+		Name:             "Rust",
+		Extension:        "rs",
+		CompileCommand:   "rustc -O --edition 2021 -o main main.rs",
+		ExecuteCommand:   "./main",
+		Version:          "2021",
+		Highlight:        "rust",
+		TimeMultiplier:   1,
+		MemoryMultiplier: 1,
+	},
+	{
+		ID:               "javascript",
+		Name:             "JavaScript (Node.js)",
+		Extension:        "js",
+		ExecuteCommand:   "node main.js",
+		Version:          "22",
+		Aliases:          []string{"node", "nodejs"},
+		Highlight:        "javascript",
+		TimeMultiplier:   2,
+		MemoryMultiplier: 1.5,
+	},
+}
+
+type languageSignal struct {
+	pattern *regexp.Regexp
+	weight  int
+}
+
+var shebangLanguages = map[string]string{
+	"python":  "python",
+	"python3": "python",
+	"node":    "javascript",
+}
+
+var languageSignals = map[string][]languageSignal{
+	"cpp": {
+		{regexp.MustCompile(`#include\s*<(iostream|bits/stdc\+\+\.h|vector|string|algorithm|map|set)>`), 3},
+		{regexp.MustCompile(`\busing\s+namespace\s+std\b`), 3},
+		{regexp.MustCompile(`\bstd::`), 2},
+		{regexp.MustCompile(`\b(cout|cin)\s*(<<|>>)`), 2},
+	},
+	"c": {
+		{regexp.MustCompile(`#include\s*<(stdio|stdlib|string|math)\.h>`), 2},
+		{regexp.MustCompile(`\b(printf|scanf)\s*\(`), 1},
+		{regexp.MustCompile(`\bint\s+main\s*\(`), 1},
+	},
+	"java": {
+		{regexp.MustCompile(`\bpublic\s+static\s+void\s+main\s*\(`), 3},
+		{regexp.MustCompile(`\bSystem\.(out|in)\b`), 2},
+		{regexp.MustCompile(`\bimport\s+java\.`), 2},
+	},
+	"python": {
+		{regexp.MustCompile(`(?m)^\s*def\s+\w+\s*\(.*\)\s*:`), 2},
+		{regexp.MustCompile(`(?m)^\s*(from\s+\w+\s+)?import\s+(sys|math|collections|itertools|heapq)\b`), 2},
+		{regexp.MustCompile(`\binput\(\)`), 2},
+		{regexp.MustCompile(`(?m)^\s*(elif|for\s+\w+\s+in)\b.*:\s*$`), 2},
+		{regexp.MustCompile(`\bprint\(`), 1},
+	},
+	"go": {
+		{regexp.MustCompile(`(?m)^package\s+main\b`), 3},
+		{regexp.MustCompile(`\bfunc\s+main\s*\(\s*\)`), 2},
+		{regexp.MustCompile(`\bfmt\.(Print|Scan|Fprint|Sprint)`), 2},
+	},
+	"rust": {
+		{regexp.MustCompile(`\bfn\s+main\s*\(\s*\)`), 3},
+		{regexp.MustCompile(`\bprintln!\s*\(`), 2},
+		{regexp.MustCompile(`\blet\s+mut\b`), 2},
+		{regexp.MustCompile(`\buse\s+std::`), 2},
+	},
+	"javascript": {
+		{regexp.MustCompile(`\bconsole\.log\s*\(`), 3},
+		{regexp.MustCompile(`\brequire\(\s*['"](fs|readline)['"]\s*\)`), 3},
+		{regexp.MustCompile(`\bprocess\.stdin\b`), 2},
+	},
+}
+
+// LookupLanguage returns the registered language with the given ID.
+func LookupLanguage(id string) (types.Language, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	for _, lang := range DefaultLanguages {
+		if lang.ID == id {
+			return lang, true
+		}
+	}
+	return types.Language{}, false
+}
+
+// NormalizeLanguage returns the registered language named by name, which
+// may be its ID, one of its aliases or its file extension, ignoring case.
+func NormalizeLanguage(name string) (types.Language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if lang, ok := LookupLanguage(name); ok {
+		return lang, true
+	}
+	for _, lang := range DefaultLanguages {
+		if lang.Extension == name || slices.Contains(lang.Aliases, name) {
+			return lang, true
+		}
+	}
+	return types.Language{}, false
+}
+
+// LanguageIDs returns the IDs of the registered languages.
+func LanguageIDs() []string {
+	ids := make([]string, 0, len(DefaultLanguages))
+	for _, lang := range DefaultLanguages {
+		ids = append(ids, lang.ID)
+	}
+	return ids
+}
+
+// ScaleLimits applies the multipliers of the language with the given ID
+// to a time limit in milliseconds and a memory limit in bytes. Limits for
+// unknown languages are returned unchanged.
+func ScaleLimits(languageID string, timeLimit, memoryLimit int64) (int64, int64) {
+	lang, ok := LookupLanguage(languageID)
+	if !ok {
+		return timeLimit, memoryLimit
+	}
+	return lang.ScaleLimits(timeLimit, memoryLimit)
+}
+
+// LanguageLimits returns the problem's limits for each registered
+// language.
+func LanguageLimits(problem types.Problem) []types.LanguageLimit {
+	limits := make([]types.LanguageLimit, 0, len(DefaultLanguages))
+	for _, lang := range DefaultLanguages {
+		timeLimit, memoryLimit := lang.ScaleLimits(problem.TimeLimit, problem.MemoryLimit)
+		limits = append(limits, types.LanguageLimit{
+			Language:    lang.ID,
+			TimeLimit:   timeLimit,
+			MemoryLimit: memoryLimit,
+		})
+	}
+	return limits
+}
+
+// DetectLanguage guesses the language of a source file. It returns the
+// detected language ID when the guess is unambiguous, and otherwise the
+// candidate IDs ranked from most to least likely.
+func DetectLanguage(code, filename string) (string, []string) {
+	if ext := strings.TrimPrefix(strings.ToLower(path.Ext(strings.TrimSpace(filename))), "."); ext != "" {
+		for _, lang := range DefaultLanguages {
+			if lang.Extension == ext {
+				return lang.ID, []string{lang.ID}
+			}
+		}
+	}
+
+	if id := detectShebang(code); id != "" {
+		return id, []string{id}
+	}
+
+	scores := make(map[string]int, len(languageSignals))
+	for id, signals := range languageSignals {
+		for _, signal := range signals {
+			if signal.pattern.MatchString(code) {
+				scores[id] += signal.weight
+			}
+		}
+	}
+	// C++ sources usually also match the C signals; prefer C++ when it has
+	// its own evidence.
+	if scores["cpp"] > 0 && scores["c"] > 0 {
+		scores["cpp"] += scores["c"]
+		scores["c"] = 0
+	}
+
+	candidates := make([]string, 0, len(scores))
+	for id, score := range scores {
+		if score > 0 {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if scores[candidates[i]] != scores[candidates[j]] {
+			return scores[candidates[i]] > scores[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	if len(candidates) == 0 {
+		return "", nil
+	}
+	top := candidates[0]
+	if scores[top] < minDetectionScore {
+		return "", candidates
+	}
+	if len(candidates) > 1 && scores[candidates[1]] == scores[top] {
+		return "", candidates
+	}
+	return top, candidates
+}
+
+func detectShebang(code string) string {
+	if !strings.HasPrefix(code, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(code, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	for i := len(fields) - 1; i >= 0; i-- {
+		if id, ok := shebangLanguages[path.Base(fields[i])]; ok {
+			return id
+		}
+	}
+	return ""
+}
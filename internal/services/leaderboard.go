@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrInvalidLeaderboardQuery is returned for an unknown period or sort order.
+var ErrInvalidLeaderboardQuery = errors.New("invalid leaderboard query")
+
+// LeaderboardRepository defines read and refresh operations for the
+// leaderboard.
+type LeaderboardRepository interface {
+	List(ctx context.Context, period, sortBy string, offset, limit int) ([]types.LeaderboardEntry, int, error)
+	Refresh(ctx context.Context) error
+}
+
+// LeaderboardService serves the global leaderboard from a periodically
+// refreshed materialized view.
+type LeaderboardService struct {
+	repo LeaderboardRepository
+}
+
+func NewLeaderboardService(repo LeaderboardRepository) *LeaderboardService {
+	return &LeaderboardService{repo: repo}
+}
+
+// List returns ranked entries. period is "all" or a month as YYYY-MM; sortBy
+// is "solved" (default) or "score".
+func (s *LeaderboardService) List(ctx context.Context, period, sortBy string, offset, limit int) ([]types.LeaderboardEntry, int, error) {
+	if period != types.LeaderboardPeriodAll {
+		if _, err := time.Parse("2006-01", period); err != nil {
+			return nil, 0, ErrInvalidLeaderboardQuery
+		}
+	}
+	switch sortBy {
+	case "", store.LeaderboardSortSolved, store.LeaderboardSortScore:
+	default:
+		return nil, 0, ErrInvalidLeaderboardQuery
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.List(ctx, period, sortBy, offset, limit)
+}
+
+// JobLeaderboardRefresh is the kind of the scheduled job that refreshes
+// the leaderboard.
+const JobLeaderboardRefresh = "leaderboard.refresh"
+
+// Refresh recomputes the leaderboard.
+func (s *LeaderboardService) Refresh(ctx context.Context) error {
+	return s.repo.Refresh(ctx)
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/store"
+)
+
+var (
+	// ErrLoginThrottled is returned when logins from a client or for an
+	// account are backing off after failed attempts.
+	ErrLoginThrottled = errors.New("too many failed logins")
+
+	// ErrAccountLocked is returned when an account was locked after
+	// repeated failed logins.
+	ErrAccountLocked = errors.New("account is locked")
+
+	// ErrUnlockTokenInvalid is returned when an unlock token matches no
+	// lockout.
+	ErrUnlockTokenInvalid = errors.New("invalid unlock token")
+)
+
+// maxLoginBackoff caps the backoff, and bounds a streak of failures, when
+// no lockout duration is configured.
+const maxLoginBackoff = time.Hour
+
+// LoginBlockedError is returned by LoginThrottleService.Check. It wraps
+// ErrLoginThrottled or ErrAccountLocked.
+type LoginBlockedError struct {
+	Err error
+	// Until is when logins are allowed again.
+	Until time.Time
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// LoginThrottleRepository defines persistence operations for failed login
+// counters and account lockouts.
+type LoginThrottleRepository interface {
+	BlockedUntil(ctx context.Context, keys []string, now time.Time) (time.Time, error)
+	RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error)
+	Block(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	LockedUntil(ctx context.Context, username string, now time.Time) (time.Time, error)
+	Lock(ctx context.Context, userID int, until time.Time, tokenHash string) error
+	Unlock(ctx context.Context, tokenHash string) (string, error)
+}
+
+// LoginThrottlePolicy configures LoginThrottleService.
+type LoginThrottlePolicy struct {
+	// Backoff is how long logins are refused after the first failure. It
+	// doubles with every further failure, up to LockoutDuration. Zero
+	// disables backoff.
+	Backoff time.Duration
+
+	// LockoutThreshold is the number of consecutive failures that locks
+	// an account. Zero disables lockouts.
+	LockoutThreshold int
+
+	// LockoutDuration is how long a lockout lasts and caps the backoff.
+	// Failures further apart than this do not count towards the same
+	// streak. When zero, maxLoginBackoff caps the backoff and bounds the
+	// streak instead.
+	LockoutDuration time.Duration
+
+	// UnlockURL is where unlock emails link to, with the token appended
+	// as the "token" query parameter. When empty the email carries the
+	// bare token.
+	UnlockURL string
+}
+
+// LoginThrottleService slows down repeated failed logins per account and
+// per client address, and locks accounts that keep failing.
+type LoginThrottleService struct {
+	repo   LoginThrottleRepository
+	users  *UserService
+	mailer mailer.Mailer
+	policy LoginThrottlePolicy
+}
+
+// NewLoginThrottleService constructs a LoginThrottleService. m may be nil
+// when email delivery is not configured, in which case lockouts only lift
+// once they expire.
+func NewLoginThrottleService(repo LoginThrottleRepository, users *UserService, m mailer.Mailer, policy LoginThrottlePolicy) *LoginThrottleService {
+	return &LoginThrottleService{
+		repo:   repo,
+		users:  users,
+		mailer: m,
+		policy: policy,
+	}
+}
+
+// Check returns a *LoginBlockedError if a login for username from ip must
+// be refused without checking the password.
+func (s *LoginThrottleService) Check(ctx context.Context, username, ip string) error {
+	now := time.Now()
+
+	lockedUntil, err := s.repo.LockedUntil(ctx, username, now)
+	if err == nil {
+		return &LoginBlockedError{Err: ErrAccountLocked, Until: lockedUntil}
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	blockedUntil, err := s.repo.BlockedUntil(ctx, throttleKeys(ctx, username, ip), now)
+	if err != nil {
+		return err
+	}
+	if !blockedUntil.IsZero() {
+		return &LoginBlockedError{Err: ErrLoginThrottled, Until: blockedUntil}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for username from ip, extends the
+// backoff for both, and locks the account once it reaches the lockout
+// threshold. username need not name an existing account.
+func (s *LoginThrottleService) RecordFailure(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	windowStart := now.Add(-s.window())
+
+	for _, key := range throttleKeys(ctx, username, ip) {
+		failures, err := s.repo.RecordFailure(ctx, key, now, windowStart)
+		if err != nil {
+			return err
+		}
+
+		if key == userThrottleKey(ctx, username) && s.policy.LockoutThreshold > 0 && failures >= s.policy.LockoutThreshold {
+			if err := s.lock(ctx, username, now); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if delay := s.backoff(failures); delay > 0 {
+			if err := s.repo.Block(ctx, key, now.Add(delay)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failure streak. The client address
+// keeps its streak so that logging into one account does not reset the
+// backoff earned by guessing at others.
+func (s *LoginThrottleService) RecordSuccess(ctx context.Context, username string) error {
+	return s.repo.Reset(ctx, userThrottleKey(ctx, username))
+}
+
+// Unlock lifts the lockout whose emailed token is given.
+func (s *LoginThrottleService) Unlock(ctx context.Context, token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrUnlockTokenInvalid
+	}
+	username, err := s.repo.Unlock(ctx, hashUnlockToken(token))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrUnlockTokenInvalid
+		}
+		return err
+	}
+	return s.repo.Reset(ctx, userThrottleKey(ctx, username))
+}
+
+// lock locks the named account, if it exists, and emails the owner an
+// unlock token. The failure streak restarts so that the next lockout needs
+// another full run of failures.
+func (s *LoginThrottleService) lock(ctx context.Context, username string, now time.Time) error {
+	if err := s.repo.Reset(ctx, userThrottleKey(ctx, username)); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := newUnlockToken()
+	if err != nil {
+		return err
+	}
+	until := now.Add(s.policy.LockoutDuration)
+	if err := s.repo.Lock(ctx, user.ID, until, hashUnlockToken(token)); err != nil {
+		return err
+	}
+
+	if s.mailer == nil || strings.TrimSpace(user.Email) == "" {
+		return nil
+	}
+	data := map[string]any{
+		"Username": user.Username,
+		"Failures": s.policy.LockoutThreshold,
+		"Until":    until,
+		"Token":    token,
+		"Link":     "",
+	}
+	if s.policy.UnlockURL != "" {
+		data["Link"] = unlockLink(s.policy.UnlockURL, token)
+	}
+	msg, err := mailer.Render(user.Email, mailer.TemplateAccountLocked, data)
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("login throttle: failed to send unlock email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// backoff returns how long to refuse logins after the given number of
+// consecutive failures.
+func (s *LoginThrottleService) backoff(failures int) time.Duration {
+	if s.policy.Backoff <= 0 || failures < 1 {
+		return 0
+	}
+	limit := s.window()
+	delay := s.policy.Backoff
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// window returns how far apart failures may be to count towards the same
+// streak, which is also the longest backoff.
+func (s *LoginThrottleService) window() time.Duration {
+	if s.policy.LockoutDuration <= 0 {
+		return maxLoginBackoff
+	}
+	return s.policy.LockoutDuration
+}
+
+func throttleKeys(ctx context.Context, username, ip string) []string {
+	keys := []string{userThrottleKey(ctx, username)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	return keys
+}
+
+// userThrottleKey names the failure counter of an account. Usernames are
+// only unique within a tenant, so the keys of tenants other than the
+// default one include the tenant.
+func userThrottleKey(ctx context.Context, username string) string {
+	if tenantID, ok := store.TenantFromContext(ctx); ok && tenantID != store.DefaultTenantID {
+		return fmt.Sprintf("user:%d/%s", tenantID, username)
+	}
+	return "user:" + username
+}
+
+func unlockLink(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func newUnlockToken() (string, error) {
+	var buf [24]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+func hashUnlockToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
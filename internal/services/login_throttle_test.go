@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type loginFailures struct {
+	failures     int
+	lastFailedAt time.Time
+	blockedUntil time.Time
+}
+
+type accountLockout struct {
+	until     time.Time
+	tokenHash string
+}
+
+// fakeThrottleRepo keeps failure streaks and lockouts in memory, with the
+// store's semantics.
+type fakeThrottleRepo struct {
+	users    map[int]types.User
+	failures map[string]*loginFailures
+	lockouts map[int]accountLockout
+}
+
+func newFakeThrottleRepo(users ...types.User) *fakeThrottleRepo {
+	r := &fakeThrottleRepo{
+		users:    map[int]types.User{},
+		failures: map[string]*loginFailures{},
+		lockouts: map[int]accountLockout{},
+	}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeThrottleRepo) BlockedUntil(_ context.Context, keys []string, now time.Time) (time.Time, error) {
+	var until time.Time
+	for _, key := range keys {
+		if f, ok := r.failures[key]; ok && f.blockedUntil.After(now) && f.blockedUntil.After(until) {
+			until = f.blockedUntil
+		}
+	}
+	return until, nil
+}
+
+func (r *fakeThrottleRepo) RecordFailure(_ context.Context, key string, now, windowStart time.Time) (int, error) {
+	f, ok := r.failures[key]
+	switch {
+	case !ok:
+		f = &loginFailures{failures: 1, blockedUntil: now}
+		r.failures[key] = f
+	case f.lastFailedAt.Before(windowStart):
+		f.failures = 1
+	default:
+		f.failures++
+	}
+	f.lastFailedAt = now
+	return f.failures, nil
+}
+
+func (r *fakeThrottleRepo) Block(_ context.Context, key string, until time.Time) error {
+	if f, ok := r.failures[key]; ok {
+		f.blockedUntil = until
+	}
+	return nil
+}
+
+func (r *fakeThrottleRepo) Reset(_ context.Context, key string) error {
+	delete(r.failures, key)
+	return nil
+}
+
+func (r *fakeThrottleRepo) LockedUntil(_ context.Context, username string, now time.Time) (time.Time, error) {
+	for id, lockout := range r.lockouts {
+		if r.users[id].Username == username && lockout.until.After(now) {
+			return lockout.until, nil
+		}
+	}
+	return time.Time{}, store.ErrNotFound
+}
+
+func (r *fakeThrottleRepo) Lock(_ context.Context, userID int, until time.Time, tokenHash string) error {
+	r.lockouts[userID] = accountLockout{until: until, tokenHash: tokenHash}
+	return nil
+}
+
+func (r *fakeThrottleRepo) Unlock(_ context.Context, tokenHash string) (string, error) {
+	for id, lockout := range r.lockouts {
+		if lockout.tokenHash == tokenHash {
+			delete(r.lockouts, id)
+			return r.users[id].Username, nil
+		}
+	}
+	return "", store.ErrNotFound
+}
+
+// throttleUserRepo serves the users of a fakeThrottleRepo to the
+// UserService.
+type throttleUserRepo struct {
+	UserRepository
+	repo *fakeThrottleRepo
+}
+
+func (r throttleUserRepo) GetByUsername(_ context.Context, username string) (types.User, error) {
+	for _, user := range r.repo.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return types.User{}, store.ErrNotFound
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newThrottle(repo *fakeThrottleRepo, m mailer.Mailer, policy LoginThrottlePolicy) *LoginThrottleService {
+	return NewLoginThrottleService(repo, NewUserService(throttleUserRepo{repo: repo}, nil), m, policy)
+}
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   LoginThrottlePolicy
+		failures int
+		want     time.Duration
+	}{
+		{"no failures", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Second}, 0, 0},
+		{"first failure", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Second}, 1, time.Second},
+		{"doubles", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Second}, 4, 8 * time.Second},
+		{"capped by the lockout", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Second}, 5, 10 * time.Second},
+		{"long streak", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Second}, 1000, 10 * time.Second},
+		{"capped without a lockout", LoginThrottlePolicy{Backoff: time.Minute}, 7, maxLoginBackoff},
+		{"disabled", LoginThrottlePolicy{LockoutDuration: 10 * time.Second}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newThrottle(newFakeThrottleRepo(), nil, tt.policy)
+			if got := s.backoff(tt.failures); got != tt.want {
+				t.Fatalf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginFailureStreak(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy LoginThrottlePolicy
+		window time.Duration
+	}{
+		{"lockout duration", LoginThrottlePolicy{Backoff: time.Second, LockoutDuration: 10 * time.Minute}, 10 * time.Minute},
+		{"no lockout duration", LoginThrottlePolicy{Backoff: time.Second}, maxLoginBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeThrottleRepo()
+			s := newThrottle(repo, nil, tt.policy)
+			ctx := context.Background()
+
+			for range 3 {
+				if err := s.RecordFailure(ctx, "jdoe", "192.0.2.1"); err != nil {
+					t.Fatalf("record failure: %v", err)
+				}
+			}
+			for _, key := range []string{"user:jdoe", "ip:192.0.2.1"} {
+				f := repo.failures[key]
+				if f.failures != 3 {
+					t.Fatalf("%s failures = %d, want 3", key, f.failures)
+				}
+				if backoff := f.blockedUntil.Sub(f.lastFailedAt); backoff != 4*time.Second {
+					t.Fatalf("%s blocked for %v, want 4s", key, backoff)
+				}
+			}
+			var blocked *LoginBlockedError
+			if err := s.Check(ctx, "jdoe", "198.51.100.1"); !errors.As(err, &blocked) || !errors.Is(err, ErrLoginThrottled) {
+				t.Fatalf("check = %v, want %v", err, ErrLoginThrottled)
+			}
+
+			// A failure after the window starts a new streak.
+			repo.failures["user:jdoe"].lastFailedAt = time.Now().Add(-tt.window - time.Second)
+			if err := s.RecordFailure(ctx, "jdoe", "192.0.2.1"); err != nil {
+				t.Fatalf("record failure: %v", err)
+			}
+			if got := repo.failures["user:jdoe"].failures; got != 1 {
+				t.Fatalf("failures after the window = %d, want 1", got)
+			}
+			if got := repo.failures["ip:192.0.2.1"].failures; got != 4 {
+				t.Fatalf("client failures = %d, want 4", got)
+			}
+		})
+	}
+}
+
+var unlockTokenPattern = regexp.MustCompile(`token=([0-9a-f]{48})`)
+
+func TestLoginLockAndUnlock(t *testing.T) {
+	repo := newFakeThrottleRepo(types.User{ID: 1, Username: "jdoe", Email: "jdoe@example.org"})
+	mail := &fakeMailer{}
+	s := newThrottle(repo, mail, LoginThrottlePolicy{
+		Backoff:          time.Second,
+		LockoutThreshold: 3,
+		LockoutDuration:  15 * time.Minute,
+		UnlockURL:        "https://judge.example.org/unlock",
+	})
+	ctx := context.Background()
+
+	for range 3 {
+		if err := s.RecordFailure(ctx, "jdoe", "192.0.2.1"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+	var blocked *LoginBlockedError
+	err := s.Check(ctx, "jdoe", "198.51.100.1")
+	if !errors.As(err, &blocked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("check = %v, want %v", err, ErrAccountLocked)
+	}
+	if until := time.Until(blocked.Until); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Fatalf("locked for %v, want 15m", until)
+	}
+	if _, ok := repo.failures["user:jdoe"]; ok {
+		t.Fatalf("streak kept after the lockout, want it restarted")
+	}
+
+	if len(mail.sent) != 1 || mail.sent[0].To != "jdoe@example.org" {
+		t.Fatalf("sent = %+v, want one unlock email to jdoe", mail.sent)
+	}
+	match := unlockTokenPattern.FindStringSubmatch(mail.sent[0].Body)
+	if match == nil {
+		t.Fatalf("unlock email has no unlock link:\n%s", mail.sent[0].Body)
+	}
+
+	if err := s.Unlock(ctx, "not-the-token"); !errors.Is(err, ErrUnlockTokenInvalid) {
+		t.Fatalf("unlock with a wrong token = %v, want %v", err, ErrUnlockTokenInvalid)
+	}
+	if err := s.Unlock(ctx, match[1]); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := s.Check(ctx, "jdoe", "198.51.100.1"); err != nil {
+		t.Fatalf("check after unlock = %v, want nil", err)
+	}
+	if err := s.Unlock(ctx, match[1]); !errors.Is(err, ErrUnlockTokenInvalid) {
+		t.Fatalf("second unlock = %v, want %v", err, ErrUnlockTokenInvalid)
+	}
+	// The client that did the guessing stays throttled.
+	if err := s.Check(ctx, "jdoe", "192.0.2.1"); !errors.Is(err, ErrLoginThrottled) {
+		t.Fatalf("check from the guessing client = %v, want %v", err, ErrLoginThrottled)
+	}
+}
+
+func TestLoginLockUnknownAccount(t *testing.T) {
+	repo := newFakeThrottleRepo()
+	s := newThrottle(repo, nil, LoginThrottlePolicy{LockoutThreshold: 2, LockoutDuration: time.Minute})
+	ctx := context.Background()
+
+	for range 2 {
+		if err := s.RecordFailure(ctx, "nobody", ""); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+	if len(repo.lockouts) != 0 {
+		t.Fatalf("lockouts = %v, want none for an unknown account", repo.lockouts)
+	}
+	if err := s.Check(ctx, "nobody", ""); err != nil {
+		t.Fatalf("check = %v, want nil", err)
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// MaxReportReasonBytes caps the length of report reasons and moderator
+// notes.
+const MaxReportReasonBytes = 2 << 10
+
+var (
+	// ErrInvalidReport is returned when a report names an unknown kind of
+	// content or has no reason.
+	ErrInvalidReport = errors.New("invalid report")
+
+	// ErrReportClosed is returned when a report that was already resolved
+	// or dismissed is resolved again.
+	ErrReportClosed = errors.New("report is already closed")
+
+	// ErrInvalidModerationAction is returned when an action does not apply
+	// to the reported content, such as hiding a user or banning an admin.
+	ErrInvalidModerationAction = errors.New("invalid moderation action")
+)
+
+// ContentReportRepository defines persistence operations for reports and
+// bans.
+type ContentReportRepository interface {
+	Create(ctx context.Context, report types.ContentReport) (types.ContentReport, error)
+	Get(ctx context.Context, id int64) (types.ContentReport, error)
+	List(ctx context.Context, status types.ReportStatus, offset, limit int) ([]types.ContentReport, int, error)
+	Resolve(ctx context.Context, report types.ContentReport) (types.ContentReport, error)
+	Ban(ctx context.Context, ban types.UserBan) (types.UserBan, error)
+	Unban(ctx context.Context, userID int) error
+	IsBanned(ctx context.Context, userID int) (bool, error)
+}
+
+// ModerationService lets users report comments, problems and usernames,
+// and admins work through the reports: dismissing them, hiding the
+// content or banning its author. Decisions and bans are recorded in the
+// event log.
+type ModerationService struct {
+	repo     ContentReportRepository
+	comments *ProblemCommentService
+	problems *ProblemService
+	reviews  *ProblemReviewService
+	users    *UserService
+	sessions *SessionService
+	events   *EventService
+}
+
+// NewModerationService constructs a ModerationService.
+func NewModerationService(
+	repo ContentReportRepository,
+	comments *ProblemCommentService,
+	problems *ProblemService,
+	reviews *ProblemReviewService,
+	users *UserService,
+	sessions *SessionService,
+	events *EventService,
+) *ModerationService {
+	return &ModerationService{
+		repo:     repo,
+		comments: comments,
+		problems: problems,
+		reviews:  reviews,
+		users:    users,
+		sessions: sessions,
+		events:   events,
+	}
+}
+
+// Report files a report by reporter. It returns store.ErrNotFound when the
+// content does not exist or the reporter may not see it. Reporting the
+// same content again while the first report is open returns that report.
+func (s *ModerationService) Report(ctx context.Context, reporter types.User, report types.ContentReport) (types.ContentReport, error) {
+	reason, err := normalizeModerationText(report.Reason)
+	if err != nil {
+		return types.ContentReport{}, err
+	}
+	if reason == "" {
+		return types.ContentReport{}, fmt.Errorf("%w: reason is required", ErrInvalidReport)
+	}
+
+	switch report.TargetType {
+	case types.ReportTargetComment:
+		comment, err := s.comments.Get(ctx, report.TargetID)
+		if err != nil {
+			return types.ContentReport{}, err
+		}
+		if err := s.comments.authorizeView(ctx, &reporter, comment.ProblemID); err != nil {
+			return types.ContentReport{}, err
+		}
+	case types.ReportTargetProblem:
+		problem, err := s.problems.Get(ctx, int(report.TargetID))
+		if err != nil {
+			return types.ContentReport{}, err
+		}
+		if err := s.problems.AuthorizeView(ctx, reporter, problem); err != nil {
+			return types.ContentReport{}, err
+		}
+	case types.ReportTargetUser:
+		if _, err := s.users.GetByID(ctx, int(report.TargetID)); err != nil {
+			return types.ContentReport{}, err
+		}
+	default:
+		return types.ContentReport{}, fmt.Errorf("%w: target_type must be comment, problem or user", ErrInvalidReport)
+	}
+
+	report.ReporterID = reporter.ID
+	report.Reason = reason
+	return s.repo.Create(ctx, report)
+}
+
+// List returns a page of reports, oldest first. An empty status lists
+// every report.
+func (s *ModerationService) List(ctx context.Context, status types.ReportStatus, offset, limit int) ([]types.ContentReport, int, error) {
+	switch status {
+	case "", types.ReportOpen, types.ReportResolved, types.ReportDismissed:
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown status %q", ErrInvalidReport, status)
+	}
+	return s.repo.List(ctx, status, offset, limit)
+}
+
+// Resolve closes an open report. ReportActionNone dismisses it;
+// ReportActionHide hides the reported comment or unpublishes the reported
+// problem; ReportActionBan bans the reported user or the author of the
+// reported content.
+func (s *ModerationService) Resolve(ctx context.Context, moderator types.User, id int64, action types.ReportAction, note string) (types.ContentReport, error) {
+	note, err := normalizeModerationText(note)
+	if err != nil {
+		return types.ContentReport{}, err
+	}
+	report, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.ContentReport{}, err
+	}
+	if report.Status != types.ReportOpen {
+		return types.ContentReport{}, ErrReportClosed
+	}
+
+	report.Status = types.ReportResolved
+	switch action {
+	case types.ReportActionNone:
+		report.Status = types.ReportDismissed
+	case types.ReportActionHide:
+		err = s.hide(ctx, moderator, report)
+	case types.ReportActionBan:
+		var userID int
+		if userID, err = s.author(ctx, report); err == nil {
+			reason := note
+			if reason == "" {
+				reason = report.Reason
+			}
+			_, err = s.Ban(ctx, moderator, userID, reason)
+		}
+	default:
+		err = fmt.Errorf("%w: action must be none, hide or ban", ErrInvalidModerationAction)
+	}
+	if err != nil {
+		return types.ContentReport{}, err
+	}
+
+	report.Action = action
+	report.ResolverID = moderator.ID
+	report.Resolution = note
+	resolved, err := s.repo.Resolve(ctx, report)
+	if errors.Is(err, store.ErrNotFound) {
+		// Another moderator closed it in the meantime.
+		return types.ContentReport{}, ErrReportClosed
+	}
+	if err != nil {
+		return types.ContentReport{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventReportResolved, resolved.ID, map[string]any{
+		"id":          resolved.ID,
+		"target_type": resolved.TargetType,
+		"target_id":   resolved.TargetID,
+		"status":      resolved.Status,
+		"action":      resolved.Action,
+		"resolution":  resolved.Resolution,
+	})
+	return resolved, nil
+}
+
+// Ban bars a user from signing in and ends their sessions. Admins cannot
+// be banned; demote them first.
+func (s *ModerationService) Ban(ctx context.Context, moderator types.User, userID int, reason string) (types.UserBan, error) {
+	reason, err := normalizeModerationText(reason)
+	if err != nil {
+		return types.UserBan{}, err
+	}
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return types.UserBan{}, err
+	}
+	if isAdmin(user) {
+		return types.UserBan{}, fmt.Errorf("%w: admins cannot be banned", ErrInvalidModerationAction)
+	}
+
+	ban, err := s.repo.Ban(ctx, types.UserBan{UserID: user.ID, Reason: reason, BannedBy: moderator.ID})
+	if err != nil {
+		return types.UserBan{}, err
+	}
+	if err := s.sessions.EndAll(ctx, user.ID); err != nil {
+		return types.UserBan{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventUserBanned, int64(user.ID), map[string]any{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"reason":   reason,
+	})
+	return ban, nil
+}
+
+// Unban lifts a user's ban. It returns store.ErrNotFound when the user is
+// not banned.
+func (s *ModerationService) Unban(ctx context.Context, userID int) error {
+	if err := s.repo.Unban(ctx, userID); err != nil {
+		return err
+	}
+	_ = s.events.Emit(ctx, types.EventUserUnbanned, int64(userID), map[string]any{
+		"user_id": userID,
+	})
+	return nil
+}
+
+// IsBanned reports whether the user is banned. It implements BanChecker.
+func (s *ModerationService) IsBanned(ctx context.Context, userID int) (bool, error) {
+	return s.repo.IsBanned(ctx, userID)
+}
+
+// hide hides the reported comment or unpublishes the reported problem.
+func (s *ModerationService) hide(ctx context.Context, moderator types.User, report types.ContentReport) error {
+	switch report.TargetType {
+	case types.ReportTargetComment:
+		comment, err := s.comments.Get(ctx, report.TargetID)
+		if err != nil {
+			return err
+		}
+		_, err = s.comments.SetHidden(ctx, moderator, comment.ProblemID, comment.ID, true)
+		return err
+	case types.ReportTargetProblem:
+		return s.reviews.SetPublished(ctx, int(report.TargetID), false)
+	default:
+		return fmt.Errorf("%w: only comments and problems can be hidden", ErrInvalidModerationAction)
+	}
+}
+
+// author returns the user responsible for the reported content.
+func (s *ModerationService) author(ctx context.Context, report types.ContentReport) (int, error) {
+	switch report.TargetType {
+	case types.ReportTargetComment:
+		comment, err := s.comments.Get(ctx, report.TargetID)
+		if err != nil {
+			return 0, err
+		}
+		return comment.UserID, nil
+	case types.ReportTargetProblem:
+		problem, err := s.problems.Get(ctx, int(report.TargetID))
+		if err != nil {
+			return 0, err
+		}
+		if problem.OwnerID == 0 {
+			return 0, fmt.Errorf("%w: the problem has no owner", ErrInvalidModerationAction)
+		}
+		return problem.OwnerID, nil
+	default:
+		return int(report.TargetID), nil
+	}
+}
+
+// normalizeModerationText trims a reason or note and checks its length.
+func normalizeModerationText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if len(text) > MaxReportReasonBytes {
+		return "", fmt.Errorf("%w: text is longer than %d bytes", ErrInvalidReport, MaxReportReasonBytes)
+	}
+	return text, nil
+}
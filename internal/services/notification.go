@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+var (
+	// ErrInvalidNotification is returned when a notification has no
+	// recipient, type or title.
+	ErrInvalidNotification = errors.New("invalid notification")
+
+	// ErrInvalidNotificationPreferences is returned for an unknown verdict
+	// digest frequency.
+	ErrInvalidNotificationPreferences = errors.New("invalid notification preferences")
+)
+
+// NotificationRepository defines persistence operations for notifications.
+type NotificationRepository interface {
+	Create(ctx context.Context, notification types.Notification) (types.Notification, error)
+	Get(ctx context.Context, id int64) (types.Notification, error)
+	ListByUser(ctx context.Context, userID int, unreadOnly bool, offset, limit int) ([]types.Notification, int, error)
+	CountUnread(ctx context.Context, userID int) (int, error)
+	MarkRead(ctx context.Context, userID int, id int64) error
+	MarkAllRead(ctx context.Context, userID int) (int64, error)
+	DeleteReadBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	ListByUserSince(ctx context.Context, userID int, notificationType string, since time.Time, limit int) ([]types.Notification, error)
+	GetPreferences(ctx context.Context, userID int) (types.NotificationPreferences, error)
+	SetPreferences(ctx context.Context, prefs types.NotificationPreferences) (types.NotificationPreferences, error)
+	ListDigestsDue(ctx context.Context, frequency string, dueBefore time.Time, afterUserID, limit int) ([]types.NotificationPreferences, error)
+	MarkDigestSent(ctx context.Context, userID int, sentAt time.Time) error
+}
+
+// JobNotificationEmail is the kind of the job that emails a notification
+// to its user.
+const JobNotificationEmail = "notifications.email"
+
+// JobNotificationPrune is the kind of the scheduled job that deletes old
+// read notifications.
+const JobNotificationPrune = "notifications.prune"
+
+// JobVerdictDigest is the kind of the scheduled job that emails verdict
+// digests that are due.
+const JobVerdictDigest = "notifications.verdict_digest"
+
+// digestPeriods are how long each verdict digest frequency covers.
+var digestPeriods = map[string]time.Duration{
+	types.DigestDaily:  24 * time.Hour,
+	types.DigestWeekly: 7 * 24 * time.Hour,
+}
+
+// maxDigestItems caps the judged submissions listed in one digest.
+const maxDigestItems = 50
+
+// NotificationEmailJob is the payload of a JobNotificationEmail job.
+type NotificationEmailJob struct {
+	NotificationID int64 `json:"notification_id"`
+}
+
+// NotificationService keeps each user's notification center and, for the
+// configured notification types, also emails notifications to users who
+// opted in, either as they happen or summarized in a digest.
+type NotificationService struct {
+	repo       NotificationRepository
+	users      *UserService
+	mailer     mailer.Mailer
+	jobs       JobEnqueuer
+	emailTypes map[string]bool
+}
+
+// NewNotificationService constructs a NotificationService. Notifications
+// of emailTypes are emailed through a background job; mail may be nil, in
+// which case none are.
+func NewNotificationService(repo NotificationRepository, users *UserService, mail mailer.Mailer, jobs JobEnqueuer, emailTypes []string) *NotificationService {
+	emailed := make(map[string]bool, len(emailTypes))
+	for _, notificationType := range emailTypes {
+		if notificationType = strings.TrimSpace(notificationType); notificationType != "" {
+			emailed[notificationType] = true
+		}
+	}
+	return &NotificationService{
+		repo:       repo,
+		users:      users,
+		mailer:     mail,
+		jobs:       jobs,
+		emailTypes: emailed,
+	}
+}
+
+// Notify adds a notification to its user's notification center and queues
+// it to be emailed if its type is emailed. A nil NotificationService
+// discards notifications.
+func (s *NotificationService) Notify(ctx context.Context, notification types.Notification) error {
+	if s == nil {
+		return nil
+	}
+	notification.Title = strings.TrimSpace(notification.Title)
+	if notification.UserID < 1 || notification.Type == "" || notification.Title == "" {
+		return ErrInvalidNotification
+	}
+
+	created, err := s.repo.Create(ctx, notification)
+	if err != nil {
+		return err
+	}
+	if s.mailer == nil || s.jobs == nil || !s.emailTypes[created.Type] {
+		return nil
+	}
+	// The notification is already in the notification center, so a failed
+	// email is only logged.
+	prefs, err := s.repo.GetPreferences(ctx, created.UserID)
+	if err != nil {
+		log.Printf("notifications: failed to load preferences of user %d: %v", created.UserID, err)
+		return nil
+	}
+	if !prefs.EmailNotifications || (created.Type == types.NotificationSubmissionJudged && prefs.VerdictDigest != types.DigestOff) {
+		return nil
+	}
+	if _, err := s.jobs.Enqueue(ctx, JobNotificationEmail, NotificationEmailJob{NotificationID: created.ID}); err != nil {
+		log.Printf("notifications: failed to queue email for notification %d: %v", created.ID, err)
+	}
+	return nil
+}
+
+// List returns a user's notifications newest first, optionally only the
+// unread ones.
+func (s *NotificationService) List(ctx context.Context, userID int, unreadOnly bool, offset, limit int) ([]types.Notification, int, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListByUser(ctx, userID, unreadOnly, offset, limit)
+}
+
+// UnreadCount returns the number of a user's unread notifications.
+func (s *NotificationService) UnreadCount(ctx context.Context, userID int) (int, error) {
+	return s.repo.CountUnread(ctx, userID)
+}
+
+// MarkRead marks one of a user's notifications read. It returns
+// store.ErrNotFound when the notification does not belong to the user.
+func (s *NotificationService) MarkRead(ctx context.Context, userID int, id int64) error {
+	return s.repo.MarkRead(ctx, userID, id)
+}
+
+// MarkAllRead marks all of a user's notifications read.
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID int) (int64, error) {
+	return s.repo.MarkAllRead(ctx, userID)
+}
+
+// Preferences returns a user's notification preferences.
+func (s *NotificationService) Preferences(ctx context.Context, userID int) (types.NotificationPreferences, error) {
+	return s.repo.GetPreferences(ctx, userID)
+}
+
+// SetPreferences stores a user's notification preferences. An empty
+// digest frequency turns the digest off.
+func (s *NotificationService) SetPreferences(ctx context.Context, prefs types.NotificationPreferences) (types.NotificationPreferences, error) {
+	if prefs.VerdictDigest == "" {
+		prefs.VerdictDigest = types.DigestOff
+	}
+	if _, ok := digestPeriods[prefs.VerdictDigest]; !ok && prefs.VerdictDigest != types.DigestOff {
+		return types.NotificationPreferences{}, fmt.Errorf("%w: unknown verdict digest %q", ErrInvalidNotificationPreferences, prefs.VerdictDigest)
+	}
+	return s.repo.SetPreferences(ctx, prefs)
+}
+
+// Prune deletes notifications read more than maxAge ago.
+func (s *NotificationService) Prune(ctx context.Context, maxAge time.Duration) (int64, error) {
+	return s.repo.DeleteReadBefore(ctx, time.Now().Add(-maxAge))
+}
+
+// EmailJob runs a JobNotificationEmail job. Notifications that were read
+// or deleted before the job ran, or whose user has no email address, are
+// skipped.
+func (s *NotificationService) EmailJob(ctx context.Context, payload json.RawMessage) (any, error) {
+	var job NotificationEmailJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	notification, err := s.repo.Get(ctx, job.NotificationID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if notification.ReadAt != nil || s.mailer == nil {
+		return nil, nil
+	}
+	user, err := s.users.GetByID(ctx, notification.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(user.Email) == "" {
+		return nil, nil
+	}
+
+	msg, err := mailer.Render(user.Email, mailer.TemplateNotification, map[string]any{
+		"Username": user.Username,
+		"Title":    notification.Title,
+		"Body":     notification.Body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.mailer.Send(ctx, msg)
+}
+
+// SendVerdictDigests emails every user whose verdict digest is due the
+// submissions judged since their last one. Users with nothing judged get
+// no email. A digest that fails to send is retried on the next run.
+func (s *NotificationService) SendVerdictDigests(ctx context.Context) (map[string]int, error) {
+	if s.mailer == nil {
+		return nil, nil
+	}
+	const batch = 200
+	now := time.Now()
+	sent := 0
+	for frequency, period := range digestPeriods {
+		after := 0
+		for {
+			due, err := s.repo.ListDigestsDue(ctx, frequency, now.Add(-period), after, batch)
+			if err != nil {
+				return nil, err
+			}
+			for _, prefs := range due {
+				after = prefs.UserID
+				ok, err := s.sendVerdictDigest(ctx, prefs, now)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					log.Printf("notifications: failed to send verdict digest to user %d: %v", prefs.UserID, err)
+					continue
+				}
+				if ok {
+					sent++
+				}
+			}
+			if len(due) < batch {
+				break
+			}
+		}
+	}
+	return map[string]int{"sent": sent}, nil
+}
+
+// sendVerdictDigest emails one user's digest covering up to now and
+// reports whether there was anything to send.
+func (s *NotificationService) sendVerdictDigest(ctx context.Context, prefs types.NotificationPreferences, now time.Time) (bool, error) {
+	since := now.Add(-digestPeriods[prefs.VerdictDigest])
+	if prefs.VerdictDigestSentAt != nil {
+		since = *prefs.VerdictDigestSentAt
+	}
+	notifications, err := s.repo.ListByUserSince(ctx, prefs.UserID, types.NotificationSubmissionJudged, since, maxDigestItems)
+	if err != nil {
+		return false, err
+	}
+	if len(notifications) > 0 {
+		user, err := s.users.GetByID(ctx, prefs.UserID)
+		if err != nil {
+			return false, err
+		}
+		if strings.TrimSpace(user.Email) == "" {
+			notifications = nil
+		} else {
+			msg, err := mailer.Render(user.Email, mailer.TemplateVerdictDigest, map[string]any{
+				"Username":      user.Username,
+				"Since":         since,
+				"Frequency":     prefs.VerdictDigest,
+				"Notifications": notifications,
+			})
+			if err != nil {
+				return false, err
+			}
+			if err := s.mailer.Send(ctx, msg); err != nil {
+				return false, err
+			}
+		}
+	}
+	return len(notifications) > 0, s.repo.MarkDigestSent(ctx, prefs.UserID, now)
+}
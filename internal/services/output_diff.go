@@ -0,0 +1,102 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Output diffs show at most maxDiffExcerptBytes of each differing line,
+// starting up to diffExcerptContext bytes before the first difference.
+const (
+	maxDiffExcerptBytes = 256
+	diffExcerptContext  = 32
+)
+
+// prepareTestcaseResults returns a copy of results ready to be shown to
+// the submitter: wrong answers on visible test cases get an OutputDiff,
+// and hidden test cases lose their input, outputs and checker message.
+// results must be in evaluation order (see ScoreTestcaseResults); results
+// past the last test case are treated as hidden.
+func prepareTestcaseResults(groups []types.TestcaseGroup, results []types.TestcaseResult) []types.TestcaseResult {
+	ordered := make([]types.TestcaseGroup, len(groups))
+	copy(ordered, groups)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].OrderID < ordered[j].OrderID
+	})
+	var hidden []bool
+	for _, group := range ordered {
+		for _, testcase := range group.Testcases {
+			hidden = append(hidden, testcase.IsHidden)
+		}
+	}
+
+	prepared := make([]types.TestcaseResult, len(results))
+	for i, result := range results {
+		result.Diff = nil
+		if i >= len(hidden) || hidden[i] {
+			result.Input = ""
+			result.ExpectedOutput = ""
+			result.ActualOutput = ""
+			result.CheckerMessage = ""
+		} else if result.Verdict == types.VerdictWrongAnswer {
+			result.Diff = diffOutputs(result.ExpectedOutput, result.ActualOutput)
+		}
+		prepared[i] = result
+	}
+	return prepared
+}
+
+// diffOutputs finds the first line where actual differs from expected.
+// Like the default checker, it ignores trailing whitespace on each line
+// and trailing blank lines. It returns nil when the outputs match.
+func diffOutputs(expected, actual string) *types.OutputDiff {
+	expectedLines := outputLines(expected)
+	actualLines := outputLines(actual)
+	for i := 0; i < max(len(expectedLines), len(actualLines)); i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if i < len(expectedLines) && i < len(actualLines) && want == got {
+			continue
+		}
+
+		col := 0
+		for col < len(want) && col < len(got) && want[col] == got[col] {
+			col++
+		}
+		return &types.OutputDiff{
+			Line:     i + 1,
+			Column:   col + 1,
+			Expected: diffExcerpt(want, col),
+			Actual:   diffExcerpt(got, col),
+		}
+	}
+	return nil
+}
+
+func outputLines(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffExcerpt cuts line to at most maxDiffExcerptBytes around offset
+// without splitting a UTF-8 sequence.
+func diffExcerpt(line string, offset int) string {
+	if len(line) <= maxDiffExcerptBytes {
+		return line
+	}
+	start := max(0, min(offset-diffExcerptContext, len(line)-maxDiffExcerptBytes))
+	return strings.ToValidUTF8(line[start:start+maxDiffExcerptBytes], "")
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+func TestPrepareTestcaseResults(t *testing.T) {
+	// The groups are listed out of order: group 1, with the hidden test
+	// case, is evaluated first.
+	groups := []types.TestcaseGroup{
+		{OrderID: 2, Testcases: []types.Testcase{{OrderID: 1}, {OrderID: 2}}},
+		{OrderID: 1, Testcases: []types.Testcase{{OrderID: 1, IsHidden: true}}},
+	}
+	result := func(id int, verdict types.Verdict) types.TestcaseResult {
+		return types.TestcaseResult{
+			TestcaseID:     id,
+			Verdict:        verdict,
+			Input:          "1 2\n",
+			ExpectedOutput: "3\n",
+			ActualOutput:   "4\n",
+			CheckerMessage: "expected 3, found 4",
+			Diff:           &types.OutputDiff{Line: 9},
+		}
+	}
+	redacted := func(id int, verdict types.Verdict) types.TestcaseResult {
+		return types.TestcaseResult{TestcaseID: id, Verdict: verdict}
+	}
+	results := []types.TestcaseResult{
+		result(1, types.VerdictWrongAnswer),
+		result(2, types.VerdictWrongAnswer),
+		result(3, types.VerdictAccepted),
+		// Past the last test case, as when the bundle lost a test case
+		// since the submission was judged.
+		result(4, types.VerdictWrongAnswer),
+	}
+
+	tests := []struct {
+		name   string
+		groups []types.TestcaseGroup
+		want   []types.TestcaseResult
+	}{
+		{
+			name:   "ordered by group",
+			groups: groups,
+			want: []types.TestcaseResult{
+				redacted(1, types.VerdictWrongAnswer),
+				{
+					TestcaseID:     2,
+					Verdict:        types.VerdictWrongAnswer,
+					Input:          "1 2\n",
+					ExpectedOutput: "3\n",
+					ActualOutput:   "4\n",
+					CheckerMessage: "expected 3, found 4",
+					Diff:           &types.OutputDiff{Line: 1, Column: 1, Expected: "3", Actual: "4"},
+				},
+				{
+					TestcaseID:     3,
+					Verdict:        types.VerdictAccepted,
+					Input:          "1 2\n",
+					ExpectedOutput: "3\n",
+					ActualOutput:   "4\n",
+					CheckerMessage: "expected 3, found 4",
+				},
+				redacted(4, types.VerdictWrongAnswer),
+			},
+		},
+		{
+			name: "no test cases",
+			want: []types.TestcaseResult{
+				redacted(1, types.VerdictWrongAnswer),
+				redacted(2, types.VerdictWrongAnswer),
+				redacted(3, types.VerdictAccepted),
+				redacted(4, types.VerdictWrongAnswer),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prepareTestcaseResults(tt.groups, results)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("prepareTestcaseResults() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			for i, r := range results {
+				if r.ExpectedOutput == "" || r.Diff == nil || r.Diff.Line != 9 {
+					t.Fatalf("result %d was modified: %+v", i, r)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     *types.OutputDiff
+	}{
+		{"equal", "1\n2\n", "1\n2\n", nil},
+		{"CRLF", "1\n2\n", "1\r\n2\r\n", nil},
+		{"CRLF expected", "1\r\n2", "1\n2\n", nil},
+		{"trailing whitespace", "1 2\n3\n", "1 2 \t\n3\r\n", nil},
+		{"trailing blank lines", "1\n2\n", "1\n2\n\n\r\n  \n", nil},
+		{"no final newline", "1\n2\n", "1\n2", nil},
+		{"both empty", "", "\n\n", nil},
+		{"different column", "1 2 3\n", "1 2 4\n", &types.OutputDiff{Line: 1, Column: 5, Expected: "1 2 3", Actual: "1 2 4"}},
+		{"different line with CRLF", "a\r\nb\r\nc\r\n", "a\r\nb\r\nd\r\n", &types.OutputDiff{Line: 3, Column: 1, Expected: "c", Actual: "d"}},
+		{"shorter line", "abc\n", "ab\n", &types.OutputDiff{Line: 1, Column: 3, Expected: "abc", Actual: "ab"}},
+		{"leading whitespace", "1\n", " 1\n", &types.OutputDiff{Line: 1, Column: 1, Expected: "1", Actual: " 1"}},
+		{"missing final line", "a\nb\nc\n", "a\nb\n", &types.OutputDiff{Line: 3, Column: 1, Expected: "c", Actual: ""}},
+		{"extra final line", "a\nb\n", "a\nb\nc\n", &types.OutputDiff{Line: 3, Column: 1, Expected: "", Actual: "c"}},
+		{"missing blank line", "a\n\nb\n", "a\nb\n", &types.OutputDiff{Line: 2, Column: 1, Expected: "", Actual: "b"}},
+		{"empty output", "42\n", "", &types.OutputDiff{Line: 1, Column: 1, Expected: "42", Actual: ""}},
+		{"inside a character", "é\n", "è\n", &types.OutputDiff{Line: 1, Column: 2, Expected: "é", Actual: "è"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffOutputs(tt.expected, tt.actual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("diffOutputs(%q, %q) = %+v, want %+v", tt.expected, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffExcerpt(t *testing.T) {
+	digits := strings.Repeat("0123456789", 100)
+	// Each "é" is 2 bytes and each "€" 3, so most cuts land inside one.
+	accents := strings.Repeat("é", 300)
+	euros := strings.Repeat("€", 200)
+
+	tests := []struct {
+		name   string
+		line   string
+		offset int
+		want   string
+	}{
+		{"short", "0123", 2, "0123"},
+		{"at the maximum", digits[:maxDiffExcerptBytes], 200, digits[:maxDiffExcerptBytes]},
+		{"at the start", digits, 10, digits[:maxDiffExcerptBytes]},
+		{"in the middle", digits, 500, digits[500-diffExcerptContext : 500-diffExcerptContext+maxDiffExcerptBytes]},
+		{"at the end", digits, 990, digits[len(digits)-maxDiffExcerptBytes:]},
+		{"past the end", digits, len(digits), digits[len(digits)-maxDiffExcerptBytes:]},
+		// Bytes 1 to 256: half a character at either end.
+		{"two-byte characters", accents, 1 + diffExcerptContext, strings.Repeat("é", 127)},
+		// Bytes 0 to 255: 85 characters and the first byte of the next.
+		{"three-byte characters", euros, 0, strings.Repeat("€", 85)},
+		// Bytes 35 to 290: the last byte of a character, then 85 whole ones.
+		{"three-byte characters in the middle", euros, 35 + diffExcerptContext, strings.Repeat("€", 85)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffExcerpt(tt.line, tt.offset)
+			if got != tt.want {
+				t.Fatalf("diffExcerpt(%d) = %q (%d bytes), want %q (%d bytes)", tt.offset, got, len(got), tt.want, len(tt.want))
+			}
+			if !utf8.ValidString(got) || len(got) > maxDiffExcerptBytes {
+				t.Fatalf("diffExcerpt(%d) = %q, want valid UTF-8 of at most %d bytes", tt.offset, got, maxDiffExcerptBytes)
+			}
+		})
+	}
+}
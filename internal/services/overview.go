@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// failedJobsWindow is how far back Overview counts failed background jobs.
+const failedJobsWindow = 24 * time.Hour
+
+// OverviewRepository defines the counts behind the admin overview.
+type OverviewRepository interface {
+	Counts(ctx context.Context, today, windowStart, jobsSince time.Time) (types.Overview, error)
+}
+
+// OverviewService summarizes the state of the judge for the admin
+// dashboard.
+type OverviewService struct {
+	repo        OverviewRepository
+	submissions *SubmissionService
+}
+
+func NewOverviewService(repo OverviewRepository, submissions *SubmissionService) *OverviewService {
+	return &OverviewService{repo: repo, submissions: submissions}
+}
+
+// Overview returns a snapshot of the key operational numbers. Rates are
+// computed over the same window as QueueStats.
+func (s *OverviewService) Overview(ctx context.Context) (types.Overview, error) {
+	now := time.Now()
+	overview, err := s.repo.Counts(ctx, now.UTC().Truncate(24*time.Hour), now.Add(-queueStatsWindow), now.Add(-failedJobsWindow))
+	if err != nil {
+		return types.Overview{}, err
+	}
+	overview.Queue, err = s.submissions.QueueStats(ctx)
+	if err != nil {
+		return types.Overview{}, err
+	}
+	if overview.Queue.Completed > 0 {
+		overview.SystemErrorRate = float64(overview.SystemErrors) / float64(overview.Queue.Completed)
+	}
+	overview.GeneratedAt = now
+	return overview, nil
+}
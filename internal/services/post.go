@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	// maxPostTags caps the tags on one post.
+	maxPostTags = 10
+
+	// maxPostTagLength caps the length of a post tag.
+	maxPostTagLength = 32
+)
+
+// ErrInvalidPost is returned when a post is missing its title or body or
+// carries malformed tags.
+var ErrInvalidPost = errors.New("invalid post")
+
+// PostRepository defines persistence operations for news posts.
+type PostRepository interface {
+	List(ctx context.Context, filter store.PostFilter, offset, limit int) ([]types.Post, int, error)
+	Get(ctx context.Context, id int) (types.Post, error)
+	Create(ctx context.Context, post types.Post) (types.Post, error)
+	Update(ctx context.Context, post types.Post) (types.Post, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// PostService encapsulates the news feed: administrators write posts,
+// which everyone can read once they are published.
+type PostService struct {
+	repo PostRepository
+}
+
+func NewPostService(repo PostRepository) *PostService {
+	return &PostService{repo: repo}
+}
+
+// List returns a page of posts, optionally only those tagged tag. Only
+// published posts are listed unless includeDrafts is set.
+func (s *PostService) List(ctx context.Context, tag string, includeDrafts bool, offset, limit int) ([]types.Post, int, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	filter := store.PostFilter{Tag: strings.ToLower(strings.TrimSpace(tag))}
+	if !includeDrafts {
+		now := time.Now()
+		filter.PublishedBy = &now
+	}
+	return s.repo.List(ctx, filter, offset, limit)
+}
+
+// Get returns a post. Unless includeDrafts is set, drafts and posts
+// scheduled for later are reported as store.ErrNotFound.
+func (s *PostService) Get(ctx context.Context, id int, includeDrafts bool) (types.Post, error) {
+	post, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Post{}, err
+	}
+	if !includeDrafts && !post.IsPublished(time.Now()) {
+		return types.Post{}, store.ErrNotFound
+	}
+	return post, nil
+}
+
+func (s *PostService) Create(ctx context.Context, post types.Post) (types.Post, error) {
+	post, err := normalizePost(post)
+	if err != nil {
+		return types.Post{}, err
+	}
+	return s.repo.Create(ctx, post)
+}
+
+func (s *PostService) Update(ctx context.Context, post types.Post) (types.Post, error) {
+	post, err := normalizePost(post)
+	if err != nil {
+		return types.Post{}, err
+	}
+	return s.repo.Update(ctx, post)
+}
+
+func (s *PostService) Delete(ctx context.Context, id int) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// normalizePost trims a post's title and body and lowercases and dedupes
+// its tags.
+func normalizePost(post types.Post) (types.Post, error) {
+	post.Title = strings.TrimSpace(post.Title)
+	post.Body = strings.TrimSpace(post.Body)
+	if post.Title == "" {
+		return types.Post{}, fmt.Errorf("%w: title is required", ErrInvalidPost)
+	}
+	if post.Body == "" {
+		return types.Post{}, fmt.Errorf("%w: body is required", ErrInvalidPost)
+	}
+
+	tags := make([]string, 0, len(post.Tags))
+	for _, tag := range post.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(tags, tag) {
+			continue
+		}
+		if len(tag) > maxPostTagLength {
+			return types.Post{}, fmt.Errorf("%w: tag %q exceeds %d characters", ErrInvalidPost, tag, maxPostTagLength)
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxPostTags {
+		return types.Post{}, fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidPost, maxPostTags)
+	}
+	post.Tags = tags
+	return post, nil
+}
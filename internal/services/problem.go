@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/jjudge-oj/apiserver/internal/storage"
 	"github.com/jjudge-oj/apiserver/internal/store"
@@ -12,22 +14,32 @@ import (
 // ProblemRepository defines persistence operations for problems.
 type ProblemRepository interface {
 	List(ctx context.Context, offset, limit int) ([]types.Problem, int, error)
+	ListSummaries(ctx context.Context, offset, limit int, withDescription bool) ([]types.ProblemSummary, int, error)
 	Get(ctx context.Context, id int) (types.Problem, error)
+	ListTagged(ctx context.Context, limit int) ([]types.Problem, error)
+	ListCoSolved(ctx context.Context, problemID, sample, limit int) ([]store.CoSolvedProblem, error)
 	Create(ctx context.Context, problem types.Problem) (types.Problem, error)
 	Update(ctx context.Context, problem types.Problem) (types.Problem, error)
+	Patch(ctx context.Context, id int, patch types.ProblemPatch) (types.Problem, error)
 	Delete(ctx context.Context, id int) error
 	GetLatestTestcaseBundle(ctx context.Context, problemID int) (types.TestcaseBundle, error)
 	AddTestcaseBundleVersion(ctx context.Context, problemID int, bundle types.TestcaseBundle) error
+	SetValidationStatus(ctx context.Context, problemID int, status types.ValidationStatus) error
 }
 
+// ErrProblemForbidden is returned when a user may not edit a problem.
+var ErrProblemForbidden = errors.New("not allowed to edit this problem")
+
 // ProblemService encapsulates problem use-cases.
 type ProblemService struct {
-	repo    ProblemRepository
-	storage storage.Storage
+	repo      ProblemRepository
+	storage   storage.Storage
+	events    *EventService
+	tagCorpus *tagCorpusCache
 }
 
-func NewProblemService(repo ProblemRepository) *ProblemService {
-	return &ProblemService{repo: repo}
+func NewProblemService(repo ProblemRepository, events *EventService) *ProblemService {
+	return &ProblemService{repo: repo, events: events, tagCorpus: newTagCorpusCache()}
 }
 
 func (s *ProblemService) List(ctx context.Context, offset, limit int) ([]types.Problem, int, error) {
@@ -40,6 +52,18 @@ func (s *ProblemService) List(ctx context.Context, offset, limit int) ([]types.P
 	return s.repo.List(ctx, offset, limit)
 }
 
+// ListSummaries returns a page of published problems in their list shape,
+// with descriptions only when withDescription is set.
+func (s *ProblemService) ListSummaries(ctx context.Context, offset, limit int, withDescription bool) ([]types.ProblemSummary, int, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.ListSummaries(ctx, offset, limit, withDescription)
+}
+
 func (s *ProblemService) Get(ctx context.Context, id int) (types.Problem, error) {
 	return s.repo.Get(ctx, id)
 }
@@ -48,15 +72,103 @@ func (s *ProblemService) Create(ctx context.Context, problem types.Problem) (typ
 	if problem.TestcaseBundle.Version == 0 {
 		problem.TestcaseBundle.Version = 1
 	}
-	return s.repo.Create(ctx, problem)
+	if problem.Type == "" {
+		problem.Type = types.ProblemTypeBatch
+	}
+	if problem.SolutionVisibility == "" {
+		problem.SolutionVisibility = types.SolutionsNever
+	}
+	// New problems go through review before they can be published.
+	problem.ReviewStatus = types.ReviewDraft
+	problem.Published = false
+	created, err := s.repo.Create(ctx, problem)
+	if err != nil {
+		return types.Problem{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventProblemCreated, int64(created.ID), problemEventPayload(created))
+	return created, nil
 }
 
 func (s *ProblemService) Update(ctx context.Context, problem types.Problem) (types.Problem, error) {
-	return s.repo.Update(ctx, problem)
+	updated, err := s.repo.Update(ctx, problem)
+	if err != nil {
+		return types.Problem{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventProblemUpdated, int64(updated.ID), problemEventPayload(updated))
+	return updated, nil
+}
+
+// Patch applies a partial update to a problem. The fields and the
+// published flag change together or not at all: publishing a problem that
+// is not approved returns ErrReviewTransition, as
+// ProblemReviewService.SetPublished does, and leaves the fields alone.
+// Changing the statement or limits of an approved problem sends it back to
+// draft, as Update and UpdateTestcaseBundle do.
+func (s *ProblemService) Patch(ctx context.Context, id int, patch types.ProblemPatch) (types.Problem, error) {
+	updated, err := s.repo.Patch(ctx, id, patch)
+	if errors.Is(err, store.ErrNotFound) && patch.Published != nil && *patch.Published {
+		problem, getErr := s.repo.Get(ctx, id)
+		if getErr != nil {
+			return types.Problem{}, getErr
+		}
+		if problem.ReviewStatus == types.ReviewApproved {
+			return types.Problem{}, fmt.Errorf("%w: changing the statement or limits sends the problem back to review, so it cannot be published with the change", ErrReviewTransition)
+		}
+		return types.Problem{}, fmt.Errorf("%w: a problem that is %s cannot be published", ErrReviewTransition, strings.ReplaceAll(string(problem.ReviewStatus), "_", " "))
+	}
+	if err != nil {
+		return types.Problem{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventProblemUpdated, int64(updated.ID), problemEventPayload(updated))
+	return updated, nil
 }
 
 func (s *ProblemService) Delete(ctx context.Context, id int) error {
-	return s.repo.Delete(ctx, id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	_ = s.events.Emit(ctx, types.EventProblemDeleted, int64(id), map[string]int{"id": id})
+	return nil
+}
+
+// CanSetProblems reports whether a user may create problems.
+func CanSetProblems(user types.User) bool {
+	return strings.EqualFold(user.Role, types.RoleAdmin) || strings.EqualFold(user.Role, types.RoleSetter)
+}
+
+// AuthorizeEdit returns ErrProblemForbidden unless the user may edit the
+// problem: admins may edit every problem, setters only those they own.
+func (s *ProblemService) AuthorizeEdit(ctx context.Context, user types.User, problemID int) error {
+	if strings.EqualFold(user.Role, types.RoleAdmin) {
+		return nil
+	}
+	if !strings.EqualFold(user.Role, types.RoleSetter) {
+		return ErrProblemForbidden
+	}
+	problem, err := s.repo.Get(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	if problem.OwnerID == 0 || problem.OwnerID != user.ID {
+		return ErrProblemForbidden
+	}
+	return nil
+}
+
+// AuthorizeView returns store.ErrNotFound unless the user may see the
+// problem: published problems are open to everyone, unpublished ones only
+// to reviewers and to users who may edit them.
+func (s *ProblemService) AuthorizeView(ctx context.Context, user types.User, problem types.Problem) error {
+	if problem.Published || strings.EqualFold(user.Role, types.RoleReviewer) {
+		return nil
+	}
+	if err := s.AuthorizeEdit(ctx, user, problem.ID); err != nil {
+		if errors.Is(err, ErrProblemForbidden) {
+			return store.ErrNotFound
+		}
+		return err
+	}
+	return nil
 }
 
 func (s *ProblemService) UpdateTestcaseBundle(ctx context.Context, problemID int, bundle types.TestcaseBundle) error {
@@ -84,3 +196,12 @@ func (s *ProblemService) UpdateTestcaseBundle(ctx context.Context, problemID int
 
 	return s.repo.AddTestcaseBundleVersion(ctx, problemID, bundle)
 }
+
+func problemEventPayload(problem types.Problem) map[string]any {
+	return map[string]any{
+		"id":         problem.ID,
+		"title":      problem.Title,
+		"difficulty": problem.Difficulty,
+		"tags":       problem.Tags,
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// MaxProblemCommentBytes caps the length of problem discussion comments.
+const MaxProblemCommentBytes = 8 << 10
+
+var (
+	// ErrInvalidProblemComment is returned when a comment is empty or too
+	// long.
+	ErrInvalidProblemComment = errors.New("invalid comment")
+
+	// ErrProblemCommentForbidden is returned when a user may not change a
+	// comment.
+	ErrProblemCommentForbidden = errors.New("not allowed to change this comment")
+
+	// ErrProblemCommentEditWindow is returned when a comment is edited
+	// after its edit window closed.
+	ErrProblemCommentEditWindow = errors.New("comment can no longer be edited")
+)
+
+// ProblemCommentRepository defines persistence operations for problem
+// discussions.
+type ProblemCommentRepository interface {
+	Create(ctx context.Context, comment types.ProblemComment) (types.ProblemComment, error)
+	Get(ctx context.Context, id int64) (types.ProblemComment, error)
+	ListThreads(ctx context.Context, problemID, offset, limit int) ([]types.ProblemComment, int, error)
+	Update(ctx context.Context, id int64, body string, spoiler bool) error
+	SetHidden(ctx context.Context, id int64, hidden bool, moderatorID int) error
+	Delete(ctx context.Context, id int64) error
+	HasSolved(ctx context.Context, userID, problemID int) (bool, error)
+}
+
+// ProblemCommentService runs the public discussion of problems. Anyone who
+// can see a problem can read its threads and signed-in users can post.
+// Spoilers are withheld from users who have not solved the problem yet,
+// and admins moderate by hiding comments.
+type ProblemCommentService struct {
+	repo          ProblemCommentRepository
+	problems      *ProblemService
+	notifications *NotificationService
+	editWindow    time.Duration
+}
+
+// NewProblemCommentService constructs a ProblemCommentService. Authors may
+// edit their comments for editWindow after posting; zero means 15
+// minutes. notifications may be nil, in which case authors are not told
+// about replies.
+func NewProblemCommentService(repo ProblemCommentRepository, problems *ProblemService, notifications *NotificationService, editWindow time.Duration) *ProblemCommentService {
+	if editWindow <= 0 {
+		editWindow = 15 * time.Minute
+	}
+	return &ProblemCommentService{
+		repo:          repo,
+		problems:      problems,
+		notifications: notifications,
+		editWindow:    editWindow,
+	}
+}
+
+// List returns a page of a problem's threads as the viewer may see them.
+// viewer is nil for anonymous readers. It returns store.ErrNotFound when
+// the viewer may not see the problem.
+func (s *ProblemCommentService) List(ctx context.Context, viewer *types.User, problemID, offset, limit int) ([]types.ProblemComment, int, error) {
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if err := s.authorizeView(ctx, viewer, problemID); err != nil {
+		return nil, 0, err
+	}
+
+	threads, total, err := s.repo.ListThreads(ctx, problemID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	solved := false
+	if viewer != nil {
+		if solved, err = s.repo.HasSolved(ctx, viewer.ID, problemID); err != nil {
+			return nil, 0, err
+		}
+	}
+	for i := range threads {
+		redactComment(&threads[i], viewer, solved)
+		for j := range threads[i].Replies {
+			redactComment(&threads[i].Replies[j], viewer, solved)
+		}
+	}
+	return threads, total, nil
+}
+
+// Create posts a comment by author, starting a thread or replying to
+// comment.ParentID. It returns store.ErrNotFound when the author may not
+// see the problem or the parent comment does not exist.
+func (s *ProblemCommentService) Create(ctx context.Context, author types.User, comment types.ProblemComment) (types.ProblemComment, error) {
+	body, err := normalizeCommentBody(comment.Body)
+	if err != nil {
+		return types.ProblemComment{}, err
+	}
+	if err := s.authorizeView(ctx, &author, comment.ProblemID); err != nil {
+		return types.ProblemComment{}, err
+	}
+
+	comment.UserID = author.ID
+	comment.Username = author.Username
+	comment.Body = body
+	created, err := s.repo.Create(ctx, comment)
+	if err != nil {
+		return types.ProblemComment{}, err
+	}
+	if created.ParentID != nil {
+		s.notifyReply(ctx, created)
+	}
+	return created, nil
+}
+
+// Edit replaces the body and spoiler flag of one of the author's own
+// comments while its edit window is open.
+func (s *ProblemCommentService) Edit(ctx context.Context, author types.User, problemID int, id int64, body string, spoiler bool) (types.ProblemComment, error) {
+	body, err := normalizeCommentBody(body)
+	if err != nil {
+		return types.ProblemComment{}, err
+	}
+	comment, err := s.get(ctx, problemID, id)
+	if err != nil {
+		return types.ProblemComment{}, err
+	}
+	if comment.Deleted {
+		return types.ProblemComment{}, store.ErrNotFound
+	}
+	if comment.UserID != author.ID {
+		return types.ProblemComment{}, ErrProblemCommentForbidden
+	}
+	if time.Since(comment.CreatedAt) > s.editWindow {
+		return types.ProblemComment{}, ErrProblemCommentEditWindow
+	}
+
+	if err := s.repo.Update(ctx, id, body, spoiler); err != nil {
+		return types.ProblemComment{}, err
+	}
+	return s.repo.Get(ctx, id)
+}
+
+// Delete erases a comment. Authors may delete their own comments and
+// admins any comment.
+func (s *ProblemCommentService) Delete(ctx context.Context, user types.User, problemID int, id int64) error {
+	comment, err := s.get(ctx, problemID, id)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != user.ID && !isAdmin(user) {
+		return ErrProblemCommentForbidden
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Get returns a comment as stored, whatever its state, for moderation. It
+// is not redacted for any viewer.
+func (s *ProblemCommentService) Get(ctx context.Context, id int64) (types.ProblemComment, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// SetHidden hides a comment from everyone but its author and admins, or
+// shows it again. Only admins may moderate.
+func (s *ProblemCommentService) SetHidden(ctx context.Context, moderator types.User, problemID int, id int64, hidden bool) (types.ProblemComment, error) {
+	if !isAdmin(moderator) {
+		return types.ProblemComment{}, ErrProblemCommentForbidden
+	}
+	if _, err := s.get(ctx, problemID, id); err != nil {
+		return types.ProblemComment{}, err
+	}
+	if err := s.repo.SetHidden(ctx, id, hidden, moderator.ID); err != nil {
+		return types.ProblemComment{}, err
+	}
+	return s.repo.Get(ctx, id)
+}
+
+// get loads a comment, reporting store.ErrNotFound if it belongs to
+// another problem.
+func (s *ProblemCommentService) get(ctx context.Context, problemID int, id int64) (types.ProblemComment, error) {
+	comment, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.ProblemComment{}, err
+	}
+	if comment.ProblemID != problemID {
+		return types.ProblemComment{}, store.ErrNotFound
+	}
+	return comment, nil
+}
+
+func (s *ProblemCommentService) authorizeView(ctx context.Context, viewer *types.User, problemID int) error {
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	if viewer == nil {
+		if !problem.Published {
+			return store.ErrNotFound
+		}
+		return nil
+	}
+	return s.problems.AuthorizeView(ctx, *viewer, problem)
+}
+
+// notifyReply tells the author of the parent comment about a reply. The
+// reply is already stored, so a failure is only logged.
+func (s *ProblemCommentService) notifyReply(ctx context.Context, reply types.ProblemComment) {
+	parent, err := s.repo.Get(ctx, *reply.ParentID)
+	if err != nil {
+		log.Printf("problem comments: failed to load parent of comment %d: %v", reply.ID, err)
+		return
+	}
+	if parent.UserID == reply.UserID {
+		return
+	}
+	body := fmt.Sprintf("%s replied to your comment on problem %d.", reply.Username, reply.ProblemID)
+	if !reply.Spoiler {
+		body = fmt.Sprintf("%s replied to your comment on problem %d:\n\n%s", reply.Username, reply.ProblemID, reply.Body)
+	}
+	err = s.notifications.Notify(ctx, types.Notification{
+		UserID:    parent.UserID,
+		Type:      types.NotificationCommentReply,
+		Title:     fmt.Sprintf("%s replied to your comment", reply.Username),
+		Body:      body,
+		SubjectID: reply.ID,
+	})
+	if err != nil {
+		log.Printf("problem comments: failed to notify user %d of comment %d: %v", parent.UserID, reply.ID, err)
+	}
+}
+
+// redactComment withholds what the viewer may not read: deleted comments
+// have no body, hidden ones are shown only to their author and admins,
+// and spoilers only to those who solved the problem.
+func redactComment(comment *types.ProblemComment, viewer *types.User, solved bool) {
+	own := viewer != nil && viewer.ID == comment.UserID
+	admin := viewer != nil && isAdmin(*viewer)
+	switch {
+	case comment.Deleted:
+	case comment.Hidden && !own && !admin:
+	case comment.Spoiler && !own && !admin && !solved:
+	default:
+		return
+	}
+	comment.Body = ""
+	comment.Redacted = true
+}
+
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("%w: body is required", ErrInvalidProblemComment)
+	}
+	if len(body) > MaxProblemCommentBytes {
+		return "", fmt.Errorf("%w: body exceeds %d bytes", ErrInvalidProblemComment, MaxProblemCommentBytes)
+	}
+	return body, nil
+}
+
+func isAdmin(user types.User) bool {
+	return strings.EqualFold(user.Role, types.RoleAdmin)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// maxProblemListItems caps the problems on one list.
+const maxProblemListItems = 500
+
+// ErrInvalidProblemList is returned when a problem list has no title,
+// too many problems or problems that do not exist.
+var ErrInvalidProblemList = errors.New("invalid problem list")
+
+// ProblemListRepository defines persistence operations for problem lists.
+type ProblemListRepository interface {
+	List(ctx context.Context, userID int, includeUnpublished bool, offset, limit int) ([]types.ProblemList, int, error)
+	Get(ctx context.Context, id, userID int, includeUnpublished bool) (types.ProblemList, error)
+	Create(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error)
+	Update(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// ProblemListService manages problem lists such as training sheets and
+// reports each user's progress through them. Unpublished problems on a
+// list are left out unless the viewer may see them.
+type ProblemListService struct {
+	repo ProblemListRepository
+}
+
+func NewProblemListService(repo ProblemListRepository) *ProblemListService {
+	return &ProblemListService{repo: repo}
+}
+
+// List returns a page of lists with the progress of userID, which is zero
+// for anonymous requests.
+func (s *ProblemListService) List(ctx context.Context, userID int, includeUnpublished bool, offset, limit int) ([]types.ProblemList, int, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.List(ctx, userID, includeUnpublished, offset, limit)
+}
+
+// Get returns a list with its problems in order, each marked with whether
+// userID solved or attempted it.
+func (s *ProblemListService) Get(ctx context.Context, id, userID int, includeUnpublished bool) (types.ProblemList, error) {
+	return s.repo.Get(ctx, id, userID, includeUnpublished)
+}
+
+// Create stores a list of the given problems in order.
+func (s *ProblemListService) Create(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error) {
+	list, problemIDs, err := normalizeProblemList(list, problemIDs)
+	if err != nil {
+		return types.ProblemList{}, err
+	}
+	created, err := s.repo.Create(ctx, list, problemIDs)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.ProblemList{}, fmt.Errorf("%w: unknown problem", ErrInvalidProblemList)
+	}
+	return created, err
+}
+
+// Update replaces a list's title, description and problems. It returns
+// store.ErrNotFound if there is no such list.
+func (s *ProblemListService) Update(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error) {
+	list, problemIDs, err := normalizeProblemList(list, problemIDs)
+	if err != nil {
+		return types.ProblemList{}, err
+	}
+	if _, err := s.repo.Get(ctx, list.ID, 0, true); err != nil {
+		return types.ProblemList{}, err
+	}
+	updated, err := s.repo.Update(ctx, list, problemIDs)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.ProblemList{}, fmt.Errorf("%w: unknown problem", ErrInvalidProblemList)
+	}
+	return updated, err
+}
+
+func (s *ProblemListService) Delete(ctx context.Context, id int) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// normalizeProblemList trims a list's title and description and drops
+// repeated problems, keeping the first occurrence.
+func normalizeProblemList(list types.ProblemList, problemIDs []int) (types.ProblemList, []int, error) {
+	list.Title = strings.TrimSpace(list.Title)
+	list.Description = strings.TrimSpace(list.Description)
+	if list.Title == "" {
+		return types.ProblemList{}, nil, fmt.Errorf("%w: title is required", ErrInvalidProblemList)
+	}
+
+	seen := make(map[int]bool, len(problemIDs))
+	unique := make([]int, 0, len(problemIDs))
+	for _, id := range problemIDs {
+		if id < 1 {
+			return types.ProblemList{}, nil, fmt.Errorf("%w: invalid problem id %d", ErrInvalidProblemList, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > maxProblemListItems {
+		return types.ProblemList{}, nil, fmt.Errorf("%w: at most %d problems are allowed", ErrInvalidProblemList, maxProblemListItems)
+	}
+	return list, unique, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// MaxReviewCommentBytes caps the length of review comments and decision
+// comments.
+const MaxReviewCommentBytes = 16 << 10
+
+var (
+	// ErrReviewTransition is returned when a review action does not apply
+	// to the problem's current review status.
+	ErrReviewTransition = errors.New("invalid review transition")
+
+	// ErrInvalidReview is returned when a review decision or comment is
+	// malformed.
+	ErrInvalidReview = errors.New("invalid review")
+
+	// ErrReviewForbidden is returned when a user may not review a problem.
+	ErrReviewForbidden = errors.New("not allowed to review this problem")
+)
+
+// ProblemReviewRepository defines persistence operations for the review
+// workflow.
+type ProblemReviewRepository interface {
+	Submit(ctx context.Context, problemID int) error
+	Decide(ctx context.Context, decision types.ReviewDecision) (types.ReviewDecision, error)
+	SetPublished(ctx context.Context, problemID int, published bool) error
+	AddComment(ctx context.Context, comment types.ReviewComment) (types.ReviewComment, error)
+	ListDecisions(ctx context.Context, problemID int) ([]types.ReviewDecision, error)
+	ListComments(ctx context.Context, problemID int) ([]types.ReviewComment, error)
+}
+
+// ProblemReview is the review history of a problem.
+type ProblemReview struct {
+	Status    types.ReviewStatus     `json:"status"`
+	Published bool                   `json:"published"`
+	Decisions []types.ReviewDecision `json:"decisions"`
+	Comments  []types.ReviewComment  `json:"comments"`
+}
+
+// ProblemReviewService runs the review workflow: setters submit problems
+// for review, reviewers approve them or request changes, and only approved
+// problems may be published.
+type ProblemReviewService struct {
+	repo     ProblemReviewRepository
+	problems *ProblemService
+}
+
+func NewProblemReviewService(repo ProblemReviewRepository, problems *ProblemService) *ProblemReviewService {
+	return &ProblemReviewService{repo: repo, problems: problems}
+}
+
+// CanReview reports whether a user may review problems.
+func CanReview(user types.User) bool {
+	return strings.EqualFold(user.Role, types.RoleAdmin) || strings.EqualFold(user.Role, types.RoleReviewer)
+}
+
+// Get returns the review status and history of a problem.
+func (s *ProblemReviewService) Get(ctx context.Context, problemID int) (ProblemReview, error) {
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return ProblemReview{}, err
+	}
+	decisions, err := s.repo.ListDecisions(ctx, problemID)
+	if err != nil {
+		return ProblemReview{}, err
+	}
+	comments, err := s.repo.ListComments(ctx, problemID)
+	if err != nil {
+		return ProblemReview{}, err
+	}
+	return ProblemReview{
+		Status:    problem.ReviewStatus,
+		Published: problem.Published,
+		Decisions: decisions,
+		Comments:  comments,
+	}, nil
+}
+
+// Submit puts a draft problem, or one with changes requested, up for
+// review.
+func (s *ProblemReviewService) Submit(ctx context.Context, problemID int) error {
+	err := s.repo.Submit(ctx, problemID)
+	if errors.Is(err, store.ErrNotFound) {
+		return s.transitionError(ctx, problemID, "submitted for review")
+	}
+	return err
+}
+
+// Decide records a reviewer's decision on a problem in review. Reviewers
+// may not decide on problems they own.
+func (s *ProblemReviewService) Decide(ctx context.Context, reviewer types.User, decision types.ReviewDecision) (types.ReviewDecision, error) {
+	if !CanReview(reviewer) {
+		return types.ReviewDecision{}, ErrReviewForbidden
+	}
+	switch decision.Decision {
+	case types.ReviewApproved, types.ReviewChangesRequested:
+	default:
+		return types.ReviewDecision{}, fmt.Errorf("%w: decision must be %s or %s", ErrInvalidReview, types.ReviewApproved, types.ReviewChangesRequested)
+	}
+	decision.Comment = strings.TrimSpace(decision.Comment)
+	if decision.Decision == types.ReviewChangesRequested && decision.Comment == "" {
+		return types.ReviewDecision{}, fmt.Errorf("%w: requesting changes requires a comment", ErrInvalidReview)
+	}
+	if len(decision.Comment) > MaxReviewCommentBytes {
+		return types.ReviewDecision{}, fmt.Errorf("%w: comment exceeds %d bytes", ErrInvalidReview, MaxReviewCommentBytes)
+	}
+
+	problem, err := s.problems.Get(ctx, decision.ProblemID)
+	if err != nil {
+		return types.ReviewDecision{}, err
+	}
+	if problem.OwnerID == reviewer.ID && !strings.EqualFold(reviewer.Role, types.RoleAdmin) {
+		return types.ReviewDecision{}, fmt.Errorf("%w: reviewers may not review their own problems", ErrReviewForbidden)
+	}
+
+	decision.ReviewerID = reviewer.ID
+	decided, err := s.repo.Decide(ctx, decision)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.ReviewDecision{}, s.transitionError(ctx, decision.ProblemID, "decided on")
+	}
+	return decided, err
+}
+
+// Comment adds a comment to a problem's review discussion, optionally in
+// reply to an earlier comment.
+func (s *ProblemReviewService) Comment(ctx context.Context, comment types.ReviewComment) (types.ReviewComment, error) {
+	comment.Body = strings.TrimSpace(comment.Body)
+	if comment.Body == "" {
+		return types.ReviewComment{}, fmt.Errorf("%w: comment body is required", ErrInvalidReview)
+	}
+	if len(comment.Body) > MaxReviewCommentBytes {
+		return types.ReviewComment{}, fmt.Errorf("%w: comment exceeds %d bytes", ErrInvalidReview, MaxReviewCommentBytes)
+	}
+	if comment.Target == "" {
+		comment.Target = types.ReviewTargetGeneral
+	}
+	switch comment.Target {
+	case types.ReviewTargetGeneral, types.ReviewTargetStatement, types.ReviewTargetTests:
+	default:
+		return types.ReviewComment{}, fmt.Errorf("%w: unknown comment target %q", ErrInvalidReview, comment.Target)
+	}
+	if _, err := s.problems.Get(ctx, comment.ProblemID); err != nil {
+		return types.ReviewComment{}, err
+	}
+
+	created, err := s.repo.AddComment(ctx, comment)
+	if errors.Is(err, store.ErrNotFound) {
+		return types.ReviewComment{}, fmt.Errorf("%w: parent comment not found", ErrInvalidReview)
+	}
+	return created, err
+}
+
+// SetPublished publishes an approved problem, or unpublishes a problem.
+func (s *ProblemReviewService) SetPublished(ctx context.Context, problemID int, published bool) error {
+	err := s.repo.SetPublished(ctx, problemID, published)
+	if errors.Is(err, store.ErrNotFound) {
+		return s.transitionError(ctx, problemID, "published")
+	}
+	return err
+}
+
+// transitionError explains why a conditional review update matched no
+// problem: either it does not exist or its status does not allow action.
+func (s *ProblemReviewService) transitionError(ctx context.Context, problemID int, action string) error {
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: a problem that is %s cannot be %s", ErrReviewTransition, strings.ReplaceAll(string(problem.ReviewStatus), "_", " "), action)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ErrInvalidValidationResult is returned when a worker reports a malformed
+// reference solution result.
+var ErrInvalidValidationResult = errors.New("invalid validation result")
+
+// ProblemValidationRepository defines persistence operations for reference
+// solution results.
+type ProblemValidationRepository interface {
+	Reset(ctx context.Context, problemID, bundleVersion int, solutions []types.ReferenceSolution) error
+	UpdateResult(ctx context.Context, validation types.SolutionValidation) (types.SolutionValidation, error)
+	List(ctx context.Context, problemID, bundleVersion int) ([]types.SolutionValidation, error)
+	ResetInputs(ctx context.Context, problemID, bundleVersion int, validator string) error
+	UpdateInputs(ctx context.Context, validation types.InputValidation) (types.InputValidation, error)
+	GetInputs(ctx context.Context, problemID, bundleVersion int) (types.InputValidation, error)
+}
+
+// ProblemValidationService checks a testcase bundle with the input validator
+// and reference solutions it ships, and tracks whether they behave as
+// expected.
+type ProblemValidationService struct {
+	problems ProblemRepository
+	repo     ProblemValidationRepository
+	jobs     *JudgeQueue
+}
+
+func NewProblemValidationService(problems ProblemRepository, repo ProblemValidationRepository, jobs *JudgeQueue) *ProblemValidationService {
+	return &ProblemValidationService{problems: problems, repo: repo, jobs: jobs}
+}
+
+// Start queues an input validation job if the problem's latest bundle
+// ships a validator and a job for each of its reference solutions, and
+// marks the problem pending. Problems with neither are marked
+// ValidationNone.
+func (s *ProblemValidationService) Start(ctx context.Context, problemID int) error {
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	bundle := problem.TestcaseBundle
+	if len(bundle.Solutions) == 0 && bundle.Validator == "" {
+		return s.problems.SetValidationStatus(ctx, problemID, types.ValidationNone)
+	}
+
+	if err := s.repo.Reset(ctx, problemID, bundle.Version, bundle.Solutions); err != nil {
+		return err
+	}
+	if bundle.Validator != "" {
+		if err := s.repo.ResetInputs(ctx, problemID, bundle.Version, bundle.Validator); err != nil {
+			return err
+		}
+	}
+	if err := s.problems.SetValidationStatus(ctx, problemID, types.ValidationPending); err != nil {
+		return err
+	}
+	if bundle.Validator != "" {
+		if err := s.jobs.EnqueueInputValidation(ctx, problem); err != nil {
+			return err
+		}
+	}
+	for _, solution := range bundle.Solutions {
+		if err := s.jobs.EnqueueValidation(ctx, problem, solution); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report stores the verdict a worker reported for a reference solution and
+// updates the problem's validation status. Results for bundle versions that
+// have since been replaced are stored but do not affect the status.
+func (s *ProblemValidationService) Report(ctx context.Context, result types.SolutionValidation) (types.SolutionValidation, error) {
+	if result.Verdict < types.VerdictAccepted || result.Verdict > types.VerdictSkipped {
+		return types.SolutionValidation{}, fmt.Errorf("%w: verdict must be final", ErrInvalidValidationResult)
+	}
+
+	updated, err := s.repo.UpdateResult(ctx, result)
+	if err != nil {
+		return types.SolutionValidation{}, err
+	}
+
+	if err := s.refreshStatus(ctx, result.ProblemID, result.BundleVersion); err != nil {
+		return types.SolutionValidation{}, err
+	}
+	return updated, nil
+}
+
+// ReportInputs stores the outcome of running the input validator and
+// updates the problem's validation status. The result passes when no input
+// was rejected.
+func (s *ProblemValidationService) ReportInputs(ctx context.Context, result types.InputValidation) (types.InputValidation, error) {
+	for _, inputErr := range result.Errors {
+		if inputErr.File == "" {
+			return types.InputValidation{}, fmt.Errorf("%w: rejected inputs must name a file", ErrInvalidValidationResult)
+		}
+	}
+	result.Status = types.ValidationPassed
+	if len(result.Errors) > 0 {
+		result.Status = types.ValidationFailed
+	}
+
+	updated, err := s.repo.UpdateInputs(ctx, result)
+	if err != nil {
+		return types.InputValidation{}, err
+	}
+	if err := s.refreshStatus(ctx, result.ProblemID, result.BundleVersion); err != nil {
+		return types.InputValidation{}, err
+	}
+	return updated, nil
+}
+
+// refreshStatus recomputes the problem's validation status from the results
+// for bundleVersion, unless that version has since been replaced.
+func (s *ProblemValidationService) refreshStatus(ctx context.Context, problemID, bundleVersion int) error {
+	latest, err := s.problems.GetLatestTestcaseBundle(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	if latest.Version != bundleVersion {
+		return nil
+	}
+
+	validations, err := s.repo.List(ctx, problemID, bundleVersion)
+	if err != nil {
+		return err
+	}
+	inputs, err := s.repo.GetInputs(ctx, problemID, bundleVersion)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	return s.problems.SetValidationStatus(ctx, problemID, validationStatus(inputs.Status, validations))
+}
+
+// Inputs returns the input validation of the problem's latest bundle
+// version, or store.ErrNotFound if the bundle ships no validator.
+func (s *ProblemValidationService) Inputs(ctx context.Context, problemID int) (types.InputValidation, error) {
+	bundle, err := s.problems.GetLatestTestcaseBundle(ctx, problemID)
+	if err != nil {
+		return types.InputValidation{}, err
+	}
+	return s.repo.GetInputs(ctx, problemID, bundle.Version)
+}
+
+// List returns the reference solution results for the problem's latest
+// bundle version.
+func (s *ProblemValidationService) List(ctx context.Context, problemID int) ([]types.SolutionValidation, error) {
+	bundle, err := s.problems.GetLatestTestcaseBundle(ctx, problemID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, problemID, bundle.Version)
+}
+
+// validationStatus fails as soon as the validator rejects an input or one
+// solution gets an unexpected verdict, and passes once the inputs and every
+// solution passed. inputs is empty when the bundle ships no validator.
+func validationStatus(inputs types.ValidationStatus, validations []types.SolutionValidation) types.ValidationStatus {
+	if inputs == "" && len(validations) == 0 {
+		return types.ValidationNone
+	}
+	if inputs == types.ValidationFailed {
+		return types.ValidationFailed
+	}
+	status := types.ValidationPassed
+	if inputs == types.ValidationPending {
+		status = types.ValidationPending
+	}
+	for _, v := range validations {
+		switch {
+		case v.Verdict == types.VerdictPending || v.Verdict == types.VerdictJudging:
+			status = types.ValidationPending
+		case v.Verdict != v.Expected:
+			return types.ValidationFailed
+		}
+	}
+	return status
+}
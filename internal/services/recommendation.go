@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// JobRecommendationRefresh is the kind of the scheduled job that
+// recomputes problem recommendations.
+const JobRecommendationRefresh = "recommendations.refresh"
+
+// recommendationBatch is how many users' recommendations are recomputed
+// in one transaction.
+const recommendationBatch = 200
+
+// RecommendationRepository defines persistence operations for problem
+// recommendations.
+type RecommendationRepository interface {
+	ListSolverIDs(ctx context.Context, afterUserID, limit int) ([]int, error)
+	Refresh(ctx context.Context, userIDs []int, params store.RecommendationParams) error
+	ListForUser(ctx context.Context, userID int) (types.Recommendations, error)
+}
+
+// RecommendationService suggests unsolved problems near the difficulty
+// each user has been solving. Suggestions are computed by a periodic job,
+// so serving them is a single lookup.
+type RecommendationService struct {
+	repo   RecommendationRepository
+	params store.RecommendationParams
+}
+
+// NewRecommendationService constructs a RecommendationService. Zero
+// params fall back to the last 20 solves, a stretch of 100, a band of 300
+// and 20 problems per user.
+func NewRecommendationService(repo RecommendationRepository, params store.RecommendationParams) *RecommendationService {
+	if params.History <= 0 {
+		params.History = 20
+	}
+	if params.Stretch == 0 {
+		params.Stretch = 100
+	}
+	if params.Band <= 0 {
+		params.Band = 300
+	}
+	if params.Count <= 0 {
+		params.Count = 20
+	}
+	return &RecommendationService{repo: repo, params: params}
+}
+
+// ForUser returns the problems recommended to a user. Users who have not
+// solved a rated problem yet, or whose recommendations were not computed
+// yet, get none.
+func (s *RecommendationService) ForUser(ctx context.Context, userID int) (types.Recommendations, error) {
+	return s.repo.ListForUser(ctx, userID)
+}
+
+// Refresh recomputes the recommendations of every user who solved a
+// problem, returning how many users were processed.
+func (s *RecommendationService) Refresh(ctx context.Context) (int, error) {
+	processed, after := 0, 0
+	for {
+		ids, err := s.repo.ListSolverIDs(ctx, after, recommendationBatch)
+		if err != nil {
+			return processed, err
+		}
+		if len(ids) == 0 {
+			return processed, nil
+		}
+		if err := s.repo.Refresh(ctx, ids, s.params); err != nil {
+			return processed, err
+		}
+		processed += len(ids)
+		after = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Limits applied to custom runs, which are cheaper than submissions but can
+// be started by any user.
+const (
+	RunTimeLimit      = 2000      // milliseconds
+	RunMemoryLimit    = 256 << 20 // bytes
+	MaxRunCodeBytes   = 64 << 10
+	MaxRunStdinBytes  = 64 << 10
+	MaxRunOutputBytes = 64 << 10
+
+	// maxUnfinishedRuns caps how many runs a user may have queued at once.
+	maxUnfinishedRuns = 3
+	// runStaleAfter is how long an unfinished run counts towards the cap,
+	// so runs lost by a worker do not lock the user out.
+	runStaleAfter = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidRun is returned when a run request is malformed.
+	ErrInvalidRun = errors.New("invalid run")
+
+	// ErrTooManyRuns is returned when a user already has the maximum number
+	// of runs queued.
+	ErrTooManyRuns = errors.New("too many runs in progress")
+
+	// ErrRunFinished is returned when a result is reported for a run that
+	// already has one.
+	ErrRunFinished = errors.New("run already finished")
+)
+
+// RunRepository defines persistence operations for custom runs.
+type RunRepository interface {
+	Get(ctx context.Context, id int64) (types.Run, error)
+	Create(ctx context.Context, run types.Run) (types.Run, error)
+	UpdateResult(ctx context.Context, run types.Run) (types.Run, error)
+	CountUnfinished(ctx context.Context, userID int, since time.Time) (int, error)
+}
+
+// RunService encapsulates custom run use-cases.
+type RunService struct {
+	repo RunRepository
+	jobs *JudgeQueue
+}
+
+func NewRunService(repo RunRepository, jobs *JudgeQueue) *RunService {
+	return &RunService{repo: repo, jobs: jobs}
+}
+
+func (s *RunService) Get(ctx context.Context, id int64) (types.Run, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// Create validates a run, stores it as pending and publishes it to the
+// judge workers.
+func (s *RunService) Create(ctx context.Context, run types.Run) (types.Run, error) {
+	lang, ok := NormalizeLanguage(run.Language)
+	if !ok {
+		return types.Run{}, &UnsupportedLanguageError{Language: strings.TrimSpace(run.Language), Supported: LanguageIDs()}
+	}
+	run.Language = lang.ID
+	if strings.TrimSpace(run.Code) == "" {
+		return types.Run{}, fmt.Errorf("%w: code is required", ErrInvalidRun)
+	}
+	if len(run.Code) > MaxRunCodeBytes {
+		return types.Run{}, fmt.Errorf("%w: code exceeds %d bytes", ErrInvalidRun, MaxRunCodeBytes)
+	}
+	if len(run.Stdin) > MaxRunStdinBytes {
+		return types.Run{}, fmt.Errorf("%w: stdin exceeds %d bytes", ErrInvalidRun, MaxRunStdinBytes)
+	}
+
+	unfinished, err := s.repo.CountUnfinished(ctx, run.UserID, time.Now().Add(-runStaleAfter))
+	if err != nil {
+		return types.Run{}, err
+	}
+	if unfinished >= maxUnfinishedRuns {
+		return types.Run{}, ErrTooManyRuns
+	}
+
+	if !s.jobs.Enabled() {
+		return types.Run{}, ErrJudgeQueueUnavailable
+	}
+
+	run.Verdict = types.VerdictPending
+	created, err := s.repo.Create(ctx, run)
+	if err != nil {
+		return types.Run{}, err
+	}
+	if err := s.jobs.EnqueueRun(ctx, created); err != nil {
+		return types.Run{}, err
+	}
+	return created, nil
+}
+
+// Complete stores the result a judge worker reported for a run. Output is
+// truncated to MaxRunOutputBytes.
+func (s *RunService) Complete(ctx context.Context, result types.Run) (types.Run, error) {
+	run, err := s.repo.Get(ctx, result.ID)
+	if err != nil {
+		return types.Run{}, err
+	}
+	if run.Verdict != types.VerdictPending && run.Verdict != types.VerdictJudging {
+		return types.Run{}, ErrRunFinished
+	}
+	if result.Verdict < types.VerdictJudging || result.Verdict > types.VerdictSkipped {
+		return types.Run{}, fmt.Errorf("%w: invalid verdict %s", ErrInvalidRun, result.Verdict)
+	}
+
+	run.Verdict = result.Verdict
+	run.Stdout = truncateOutput(result.Stdout, MaxRunOutputBytes)
+	run.Stderr = truncateOutput(result.Stderr, MaxRunOutputBytes)
+	run.CPUTime = result.CPUTime
+	run.Memory = result.Memory
+	return s.repo.UpdateResult(ctx, run)
+}
+
+// truncateOutput cuts s to at most limit bytes without splitting a UTF-8
+// sequence.
+func truncateOutput(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return strings.ToValidUTF8(s[:limit], "")
+}
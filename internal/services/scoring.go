@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ValidateTestcaseGroups checks scoring policies and group dependencies.
+// Dependencies must name existing groups by OrderID and must not form a
+// cycle.
+func ValidateTestcaseGroups(groups []types.TestcaseGroup) error {
+	byOrder := make(map[int]types.TestcaseGroup, len(groups))
+	for _, group := range groups {
+		if _, ok := byOrder[group.OrderID]; ok {
+			return fmt.Errorf("duplicate testcase group order %d", group.OrderID)
+		}
+		byOrder[group.OrderID] = group
+
+		switch group.ScoringPolicy {
+		case "", types.ScoringAllOrNothing, types.ScoringProportional:
+		default:
+			return fmt.Errorf("testcase group %d: unknown scoring policy %q", group.OrderID, group.ScoringPolicy)
+		}
+		if group.Points < 0 {
+			return fmt.Errorf("testcase group %d: points must not be negative", group.OrderID)
+		}
+		if group.TimeLimit < 0 || group.MemoryLimit < 0 {
+			return fmt.Errorf("testcase group %d: limits must not be negative", group.OrderID)
+		}
+		overridden := make(map[int]bool, len(group.TestcaseLimits))
+		for _, limit := range group.TestcaseLimits {
+			if limit.OrderID < 0 || overridden[limit.OrderID] {
+				return fmt.Errorf("testcase group %d: invalid or duplicate testcase limit for testcase %d", group.OrderID, limit.OrderID)
+			}
+			overridden[limit.OrderID] = true
+			if limit.TimeLimit < 0 || limit.MemoryLimit < 0 {
+				return fmt.Errorf("testcase group %d: testcase %d: limits must not be negative", group.OrderID, limit.OrderID)
+			}
+		}
+	}
+
+	for _, group := range groups {
+		for _, dep := range group.Dependencies {
+			if dep == group.OrderID {
+				return fmt.Errorf("testcase group %d depends on itself", group.OrderID)
+			}
+			if _, ok := byOrder[dep]; !ok {
+				return fmt.Errorf("testcase group %d depends on unknown group %d", group.OrderID, dep)
+			}
+		}
+	}
+
+	// Depth-first search for cycles: 1 = on the current path, 2 = done.
+	state := make(map[int]int, len(groups))
+	var visit func(order int) error
+	visit = func(order int) error {
+		switch state[order] {
+		case 1:
+			return fmt.Errorf("testcase group dependencies form a cycle through group %d", order)
+		case 2:
+			return nil
+		}
+		state[order] = 1
+		for _, dep := range byOrder[order].Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[order] = 2
+		return nil
+	}
+	for _, group := range groups {
+		if err := visit(group.OrderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScoreSummary is the outcome of scoring a set of test case results.
+type ScoreSummary struct {
+	Score       int
+	TestsPassed int
+	TestsTotal  int
+}
+
+// ScoreTestcaseResults applies each group's scoring policy and
+// dependencies to results. results must be in evaluation order: groups by
+// OrderID, then test cases by OrderID within each group. Test cases without
+// a result, for example after the judge stopped early, count as failed.
+// Proportional groups also award the partial credit a custom checker gave
+// wrong answers; all-or-nothing groups and dependencies only count test
+// cases that passed.
+func ScoreTestcaseResults(groups []types.TestcaseGroup, results []types.TestcaseResult) ScoreSummary {
+	ordered := make([]types.TestcaseGroup, len(groups))
+	copy(ordered, groups)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].OrderID < ordered[j].OrderID
+	})
+
+	var summary ScoreSummary
+	type groupOutcome struct {
+		passed int
+		total  int
+		credit float64
+	}
+	outcomes := make(map[int]groupOutcome, len(ordered))
+	next := 0
+	for _, group := range ordered {
+		outcome := groupOutcome{total: len(group.Testcases)}
+		for range group.Testcases {
+			if next < len(results) {
+				outcome.credit += testcaseCredit(results[next])
+				if results[next].Verdict == types.VerdictAccepted {
+					outcome.passed++
+				}
+			}
+			next++
+		}
+		outcomes[group.OrderID] = outcome
+		summary.TestsPassed += outcome.passed
+		summary.TestsTotal += outcome.total
+	}
+
+	for _, group := range ordered {
+		outcome := outcomes[group.OrderID]
+		if outcome.total == 0 {
+			continue
+		}
+
+		blocked := false
+		for _, dep := range group.Dependencies {
+			if d := outcomes[dep]; d.passed < d.total {
+				blocked = true
+				break
+			}
+		}
+		if blocked {
+			continue
+		}
+
+		switch group.ScoringPolicy {
+		case types.ScoringProportional:
+			// The epsilon keeps whole credits from rounding down.
+			summary.Score += int(math.Floor(float64(group.Points)*outcome.credit/float64(outcome.total) + 1e-9))
+		default:
+			if outcome.passed == outcome.total {
+				summary.Score += group.Points
+			}
+		}
+	}
+	return summary
+}
+
+// testcaseCredit is the fraction of a test case's credit a result earns:
+// all of it when accepted, the checker's score for a wrong answer, and
+// none otherwise.
+func testcaseCredit(result types.TestcaseResult) float64 {
+	switch result.Verdict {
+	case types.VerdictAccepted:
+		return 1
+	case types.VerdictWrongAnswer:
+		return min(max(result.CheckerScore, 0), 1)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+var (
+	// ErrSessionInvalid is returned when a token refers to a session that
+	// was revoked, evicted or has expired.
+	ErrSessionInvalid = errors.New("session is no longer valid")
+
+	// ErrUserBanned is returned when a banned user tries to sign in.
+	ErrUserBanned = errors.New("account is banned")
+)
+
+// SessionRepository defines persistence operations for sessions.
+type SessionRepository interface {
+	Get(ctx context.Context, id string) (types.Session, error)
+	Create(ctx context.Context, session types.Session, maxPerUser int) (types.Session, error)
+	ListByUser(ctx context.Context, userID int, now time.Time) ([]types.Session, error)
+	Touch(ctx context.Context, id string, at time.Time) error
+	DeleteForUser(ctx context.Context, id string, userID int) error
+	DeleteByUser(ctx context.Context, userID int) error
+	Delete(ctx context.Context, id string) error
+}
+
+// sessionTouchInterval limits how often a session's last use is written,
+// so that authenticated requests do not each cost a database write.
+const sessionTouchInterval = time.Minute
+
+// BanChecker reports whether a user is barred from signing in.
+type BanChecker interface {
+	IsBanned(ctx context.Context, userID int) (bool, error)
+}
+
+// SessionClient describes the client that started a session.
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionService encapsulates session use-cases.
+type SessionService struct {
+	repo       SessionRepository
+	maxPerUser int
+	bans       BanChecker
+}
+
+// NewSessionService constructs a SessionService. A positive maxPerUser limits
+// the number of simultaneously valid sessions per account; the oldest
+// sessions are evicted when a new one is started.
+func NewSessionService(repo SessionRepository, maxPerUser int) *SessionService {
+	return &SessionService{
+		repo:       repo,
+		maxPerUser: maxPerUser,
+	}
+}
+
+// WithBans refuses to start sessions for the users bans reports as
+// banned.
+func (s *SessionService) WithBans(bans BanChecker) *SessionService {
+	s.bans = bans
+	return s
+}
+
+// Start opens a new session for the user that is valid for ttl. It returns
+// ErrUserBanned for banned users.
+func (s *SessionService) Start(ctx context.Context, userID int, ttl time.Duration, client SessionClient) (types.Session, error) {
+	if s.bans != nil {
+		banned, err := s.bans.IsBanned(ctx, userID)
+		if err != nil {
+			return types.Session{}, err
+		}
+		if banned {
+			return types.Session{}, ErrUserBanned
+		}
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return types.Session{}, err
+	}
+	now := time.Now()
+	return s.repo.Create(ctx, types.Session{
+		ID:         id,
+		UserID:     userID,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+		LastUsedAt: now,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+	}, s.maxPerUser)
+}
+
+// Validate checks that the session exists, belongs to the user and has not
+// expired, and records its use. Recording the use is best-effort: a
+// failure to do so is logged and does not reject the request.
+func (s *SessionService) Validate(ctx context.Context, sessionID string, userID int) error {
+	session, err := s.repo.Get(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return ErrSessionInvalid
+		}
+		return err
+	}
+	now := time.Now()
+	if session.UserID != userID || !session.ExpiresAt.After(now) {
+		return ErrSessionInvalid
+	}
+	if now.Sub(session.LastUsedAt) >= sessionTouchInterval {
+		if err := s.repo.Touch(ctx, sessionID, now); err != nil {
+			log.Printf("sessions: failed to record use of session of user %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// List returns the user's valid sessions, most recently used first.
+func (s *SessionService) List(ctx context.Context, userID int) ([]types.Session, error) {
+	return s.repo.ListByUser(ctx, userID, time.Now())
+}
+
+// Revoke ends one of the user's sessions. It returns store.ErrNotFound if
+// the user has no such session.
+func (s *SessionService) Revoke(ctx context.Context, userID int, sessionID string) error {
+	return s.repo.DeleteForUser(ctx, sessionID, userID)
+}
+
+// EndAll revokes every session of the user, logging them out everywhere.
+func (s *SessionService) EndAll(ctx context.Context, userID int) error {
+	return s.repo.DeleteByUser(ctx, userID)
+}
+
+// End revokes a session.
+func (s *SessionService) End(ctx context.Context, sessionID string) error {
+	return s.repo.Delete(ctx, sessionID)
+}
+
+func newSessionID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeSessionRepo struct {
+	SessionRepository
+	sessions map[string]types.Session
+	touchErr error
+	touches  int
+}
+
+func (r *fakeSessionRepo) Get(_ context.Context, id string) (types.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return types.Session{}, store.ErrNotFound
+	}
+	return session, nil
+}
+
+func (r *fakeSessionRepo) Touch(context.Context, string, time.Time) error {
+	r.touches++
+	return r.touchErr
+}
+
+func TestSessionValidate(t *testing.T) {
+	now := time.Now()
+	sessions := map[string]types.Session{
+		"fresh":   {ID: "fresh", UserID: 1, ExpiresAt: now.Add(time.Hour), LastUsedAt: now},
+		"idle":    {ID: "idle", UserID: 1, ExpiresAt: now.Add(time.Hour), LastUsedAt: now.Add(-time.Hour)},
+		"expired": {ID: "expired", UserID: 1, ExpiresAt: now.Add(-time.Second), LastUsedAt: now.Add(-time.Hour)},
+	}
+	tests := []struct {
+		name        string
+		sessionID   string
+		userID      int
+		touchErr    error
+		wantErr     error
+		wantTouches int
+	}{
+		{name: "recently used", sessionID: "fresh", userID: 1},
+		{name: "records use", sessionID: "idle", userID: 1, wantTouches: 1},
+		{name: "failed touch", sessionID: "idle", userID: 1, touchErr: errors.New("database is read-only"), wantTouches: 1},
+		{name: "other user", sessionID: "idle", userID: 2, wantErr: ErrSessionInvalid},
+		{name: "expired", sessionID: "expired", userID: 1, wantErr: ErrSessionInvalid},
+		{name: "revoked", sessionID: "revoked", userID: 1, wantErr: ErrSessionInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeSessionRepo{sessions: sessions, touchErr: tt.touchErr}
+			err := NewSessionService(repo, 0).Validate(context.Background(), tt.sessionID, tt.userID)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if repo.touches != tt.wantTouches {
+				t.Fatalf("touches = %d, want %d", repo.touches, tt.wantTouches)
+			}
+		})
+	}
+}
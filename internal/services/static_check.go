@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// SubmissionChecker inspects a submission before it is judged. A non-empty
+// violation rejects the submission with VerdictPolicyViolation, carrying
+// the violation as its message, and the submission is not judged.
+type SubmissionChecker interface {
+	Check(ctx context.Context, submission types.Submission, problem types.Problem) (violation string, err error)
+}
+
+// StaticCheckRule forbids sources matching Pattern, a regular expression
+// in RE2 syntax. The rule applies to the listed language identifiers, or
+// to every language when Languages is empty.
+type StaticCheckRule struct {
+	Languages []string `json:"languages,omitempty"`
+	Pattern   string   `json:"pattern"`
+
+	// Message explains the violation to the submitter. It defaults to
+	// naming the pattern.
+	Message string `json:"message,omitempty"`
+}
+
+type regexRule struct {
+	languages map[string]bool
+	pattern   *regexp.Regexp
+	message   string
+}
+
+// RegexChecker is the built-in SubmissionChecker. It rejects sources
+// matching any of its rules, such as `\bfork\s*\(` to forbid creating
+// processes. Comments and string literals are not skipped, so rules
+// should be written to tolerate them.
+type RegexChecker struct {
+	rules []regexRule
+}
+
+// ParseStaticCheckRules parses a JSON array of rules and compiles them
+// into a RegexChecker.
+func ParseStaticCheckRules(data []byte) (*RegexChecker, error) {
+	var rules []StaticCheckRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid static check rules: %w", err)
+	}
+	return NewRegexChecker(rules)
+}
+
+// NewRegexChecker compiles the rules. Language identifiers may be any
+// alias NormalizeLanguage accepts.
+func NewRegexChecker(rules []StaticCheckRule) (*RegexChecker, error) {
+	checker := &RegexChecker{}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("static check rule %d: pattern is required", i)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("static check rule %d: %w", i, err)
+		}
+		compiled := regexRule{pattern: pattern, message: rule.Message}
+		if compiled.message == "" {
+			compiled.message = fmt.Sprintf("source matches forbidden pattern %q", rule.Pattern)
+		}
+		if len(rule.Languages) > 0 {
+			compiled.languages = make(map[string]bool, len(rule.Languages))
+			for _, name := range rule.Languages {
+				lang, ok := NormalizeLanguage(name)
+				if !ok {
+					return nil, fmt.Errorf("static check rule %d: unsupported language %q", i, name)
+				}
+				compiled.languages[lang.ID] = true
+			}
+		}
+		checker.rules = append(checker.rules, compiled)
+	}
+	return checker, nil
+}
+
+// Check reports the first rule the submission's source matches, with the
+// line it matched on.
+func (c *RegexChecker) Check(_ context.Context, submission types.Submission, _ types.Problem) (string, error) {
+	for _, rule := range c.rules {
+		if rule.languages != nil && !rule.languages[submission.Language] {
+			continue
+		}
+		loc := rule.pattern.FindStringIndex(submission.Code)
+		if loc == nil {
+			continue
+		}
+		line := strings.Count(submission.Code[:loc[0]], "\n") + 1
+		return fmt.Sprintf("line %d: %s", line, rule.message), nil
+	}
+	return "", nil
+}
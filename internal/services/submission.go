@@ -2,39 +2,696 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/internal/store"
 	"github.com/jjudge-oj/apiserver/types"
 )
 
+// OutputOnlyLanguage is the language recorded for output-only submissions,
+// whose code is a JSON object of answers.
+const OutputOnlyLanguage = "output"
+
+// maxCodePreviewChars is the length of the source preview kept in the
+// submission row, matching the backfill in the migration that added it.
+const maxCodePreviewChars = 1024
+
+// MaxStatusBatch is the largest number of submissions whose statuses can
+// be requested at once.
+const MaxStatusBatch = 100
+
+var (
+	// ErrUnsupportedLanguage is returned when a submission names a language
+	// that is not in the registry or not supported by the problem.
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+
+	// ErrAnswersRequired is returned when source code is submitted to an
+	// output-only problem.
+	ErrAnswersRequired = errors.New("output-only problems take answers instead of code")
+
+	// ErrAnswersNotAccepted is returned when answers are submitted to a
+	// problem that is not output-only.
+	ErrAnswersNotAccepted = errors.New("answers are only accepted for output-only problems")
+
+	// ErrInvalidAnswers is returned when output-only answers do not match
+	// the problem's test cases.
+	ErrInvalidAnswers = errors.New("invalid answers")
+
+	// ErrTooManySubmissionIDs is returned when more than MaxStatusBatch
+	// submission statuses are requested at once.
+	ErrTooManySubmissionIDs = errors.New("too many submission ids")
+
+	// ErrSubmissionNotPending is returned when cancelling a submission
+	// that judging has already picked up or finished.
+	ErrSubmissionNotPending = errors.New("submission is no longer pending")
+
+	// ErrSubmissionCodePruned is returned when rejudging a submission whose
+	// source the retention policy removed.
+	ErrSubmissionCodePruned = errors.New("submission source was removed by the retention policy")
+
+	// ErrInvalidVerdictOverride is returned when a manual verdict is not a
+	// final verdict or its score is out of range.
+	ErrInvalidVerdictOverride = errors.New("invalid verdict override")
+)
+
+// LanguageDetectionError is returned when a submission omits its language and
+// it cannot be detected unambiguously from the source.
+type LanguageDetectionError struct {
+	Candidates []string
+}
+
+func (e *LanguageDetectionError) Error() string {
+	if len(e.Candidates) == 0 {
+		return "language is required and could not be detected"
+	}
+	return fmt.Sprintf("language is required, detected candidates: %s", strings.Join(e.Candidates, ", "))
+}
+
+// UnsupportedLanguageError is returned when a submission or run names a
+// language the judge or the problem does not accept. It wraps
+// ErrUnsupportedLanguage and lists the languages that are accepted.
+type UnsupportedLanguageError struct {
+	Language  string
+	Supported []string
+}
+
+func (e *UnsupportedLanguageError) Error() string {
+	return fmt.Sprintf("%v %q, must be one of %s", ErrUnsupportedLanguage, e.Language, strings.Join(e.Supported, ", "))
+}
+
+func (e *UnsupportedLanguageError) Unwrap() error {
+	return ErrUnsupportedLanguage
+}
+
 // SubmissionRepository defines persistence operations for submissions.
 type SubmissionRepository interface {
 	Get(ctx context.Context, id int64) (types.Submission, error)
 	Create(ctx context.Context, submission types.Submission) (types.Submission, error)
 	Update(ctx context.Context, submission types.Submission) (types.Submission, error)
+	UpdateJudged(ctx context.Context, submission types.Submission) (types.Submission, error)
+	CancelPending(ctx context.Context, id int64, at time.Time) error
+	ListVerdictHistory(ctx context.Context, submissionID int64) ([]types.SubmissionVerdictRecord, error)
 	Delete(ctx context.Context, id int64) error
+	ListSolved(ctx context.Context, userID int) ([]types.SolvedProblem, error)
+	HasSolved(ctx context.Context, userID, problemID int) (bool, error)
+	ListStatuses(ctx context.Context, ids []int64, userID int) ([]types.SubmissionStatus, error)
+	ListByProblem(ctx context.Context, problemID, userID, offset, limit int) ([]types.Submission, int, error)
+	ListSuspicious(ctx context.Context, minScore, offset, limit int) ([]types.Submission, int, error)
+	ListInlineCode(ctx context.Context, afterID, limit int) ([]types.Submission, error)
+	SetCodeKey(ctx context.Context, id int, key string) error
+	CountPrunableCode(ctx context.Context, cutoff time.Time) (int, int64, error)
+	PruneCode(ctx context.Context, cutoff time.Time, limit int) (int, int64, error)
+	CountDaily(ctx context.Context, userID int, since time.Time) ([]types.DailyActivity, error)
+	QueueStats(ctx context.Context, since time.Time) (types.JudgeQueueStats, error)
 }
 
 // SubmissionService encapsulates submission use-cases.
 type SubmissionService struct {
-	repo SubmissionRepository
+	repo     SubmissionRepository
+	events   *EventService
+	jobs     *JudgeQueue
+	storage  *storage.Storage
+	checkers []SubmissionChecker
 }
 
-func NewSubmissionService(repo SubmissionRepository) *SubmissionService {
-	return &SubmissionService{repo: repo}
+// NewSubmissionService constructs a SubmissionService. Sources are stored
+// in objectStorage; when it is nil they are kept in the submission row.
+func NewSubmissionService(repo SubmissionRepository, events *EventService, jobs *JudgeQueue, objectStorage *storage.Storage) *SubmissionService {
+	return &SubmissionService{repo: repo, events: events, jobs: jobs, storage: objectStorage}
 }
 
+// WithCheckers runs the checkers, in order, on new submissions before they
+// are queued for judging. Rejudges are not checked, so an admin can have
+// a rejected submission judged anyway.
+func (s *SubmissionService) WithCheckers(checkers ...SubmissionChecker) *SubmissionService {
+	s.checkers = append(s.checkers, checkers...)
+	return s
+}
+
+// Get returns a submission without its full source; CodePreview and
+// CodeLength describe it. Use GetWithCode for detail views.
 func (s *SubmissionService) Get(ctx context.Context, id int64) (types.Submission, error) {
-	return s.repo.Get(ctx, id)
+	submission, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	submission.Code = ""
+	return submission, nil
+}
+
+// GetWithCode returns a submission with its full source, fetching it from
+// object storage when it is not stored inline. The source of a pruned
+// submission is empty; see CodePrunedAt.
+func (s *SubmissionService) GetWithCode(ctx context.Context, id int64) (types.Submission, error) {
+	submission, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	if submission.CodeKey == "" {
+		return submission, nil
+	}
+	if s.storage == nil {
+		return types.Submission{}, fmt.Errorf("submission %d: source is in object storage, which is not configured", submission.ID)
+	}
+
+	reader, err := s.storage.Get(ctx, submission.CodeKey)
+	if err != nil {
+		return types.Submission{}, fmt.Errorf("submission %d: fetch source: %w", submission.ID, err)
+	}
+	defer reader.Close()
+	code, err := io.ReadAll(reader)
+	if err != nil {
+		return types.Submission{}, fmt.Errorf("submission %d: fetch source: %w", submission.ID, err)
+	}
+	submission.Code = string(code)
+	return submission, nil
+}
+
+// ListByProblem returns a page of submission summaries to a problem,
+// newest first, with the total count. When userID is positive only that
+// user's submissions are listed.
+func (s *SubmissionService) ListByProblem(ctx context.Context, problemID, userID, offset, limit int) ([]types.Submission, int, error) {
+	return s.repo.ListByProblem(ctx, problemID, userID, offset, limit)
+}
+
+// Statuses returns the statuses of the given submissions in ID order.
+// When userID is positive, submissions of other users are omitted, as are
+// unknown IDs.
+func (s *SubmissionService) Statuses(ctx context.Context, ids []int64, userID int) ([]types.SubmissionStatus, error) {
+	if len(ids) > MaxStatusBatch {
+		return nil, fmt.Errorf("%w: at most %d per request", ErrTooManySubmissionIDs, MaxStatusBatch)
+	}
+	if len(ids) == 0 {
+		return []types.SubmissionStatus{}, nil
+	}
+	return s.repo.ListStatuses(ctx, ids, userID)
 }
 
 func (s *SubmissionService) Create(ctx context.Context, submission types.Submission) (types.Submission, error) {
 	return s.repo.Create(ctx, submission)
 }
 
+// Submit validates a user submission and stores it as pending. When the
+// language is omitted it is detected from the source and the optional
+// filename; a *LanguageDetectionError lists the candidates if the guess is
+// ambiguous. The language may be named by any name NormalizeLanguage
+// accepts; it is stored by ID with the registry version as its dialect.
+// Other names are rejected with an *UnsupportedLanguageError. Grader
+// problems only accept languages the bundle ships a grader for, and
+// output-only problems must use SubmitAnswers.
+func (s *SubmissionService) Submit(ctx context.Context, problem types.Problem, submission types.Submission, filename string) (types.Submission, error) {
+	if problem.Type == types.ProblemTypeOutputOnly {
+		return types.Submission{}, ErrAnswersRequired
+	}
+
+	name := strings.TrimSpace(submission.Language)
+	if name == "" {
+		detected, candidates := DetectLanguage(submission.Code, filename)
+		if detected == "" {
+			return types.Submission{}, &LanguageDetectionError{Candidates: candidates}
+		}
+		name = detected
+	}
+	lang, ok := NormalizeLanguage(name)
+	if !ok {
+		return types.Submission{}, &UnsupportedLanguageError{Language: name, Supported: LanguageIDs()}
+	}
+	if problem.Type == types.ProblemTypeGrader {
+		supported := GraderLanguages(problem.TestcaseBundle.GraderFiles)
+		if !slices.Contains(supported, lang.ID) {
+			return types.Submission{}, &UnsupportedLanguageError{Language: name, Supported: supported}
+		}
+	}
+
+	submission.Language = lang.ID
+	submission.Dialect = lang.Version
+	return s.create(ctx, problem, submission)
+}
+
+// SubmitAnswers stores an output-only submission. answers maps test cases,
+// named "<group>_<case>" as in the bundle, to the user's output. Missing
+// answers are judged as failed.
+func (s *SubmissionService) SubmitAnswers(ctx context.Context, problem types.Problem, submission types.Submission, answers map[string]string) (types.Submission, error) {
+	if problem.Type != types.ProblemTypeOutputOnly {
+		return types.Submission{}, ErrAnswersNotAccepted
+	}
+	if len(answers) == 0 {
+		return types.Submission{}, fmt.Errorf("%w: at least one answer is required", ErrInvalidAnswers)
+	}
+
+	groups := problem.TestcaseBundle.TestcaseGroups
+	normalized := make(map[string]string, len(answers))
+	for name, output := range answers {
+		key := strings.TrimSuffix(strings.TrimSpace(name), ".out")
+		groupOrder, testcaseOrder, ok := parseAnswerKey(key)
+		if !ok || groupOrder >= len(groups) || !hasTestcase(groups[groupOrder], testcaseOrder) {
+			return types.Submission{}, fmt.Errorf("%w: unknown testcase %q", ErrInvalidAnswers, name)
+		}
+		if _, dup := normalized[key]; dup {
+			return types.Submission{}, fmt.Errorf("%w: duplicate answer for %s", ErrInvalidAnswers, key)
+		}
+		normalized[key] = output
+	}
+
+	encoded, err := json.Marshal(normalized)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	submission.Code = string(encoded)
+	submission.Language = OutputOnlyLanguage
+	return s.create(ctx, problem, submission)
+}
+
+func (s *SubmissionService) create(ctx context.Context, problem types.Problem, submission types.Submission) (types.Submission, error) {
+	violation, err := s.check(ctx, submission, problem)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	submission.Verdict = types.VerdictPending
+	submission.CodePreview = codePreview(submission.Code)
+	submission.CodeLength = len(submission.Code)
+	if s.storage != nil {
+		key, err := s.putCode(ctx, submission.Code)
+		if err != nil {
+			return types.Submission{}, err
+		}
+		submission.CodeKey = key
+	}
+	created, err := s.repo.Create(ctx, submission)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventSubmissionCreated, int64(created.ID), map[string]any{
+		"id":         created.ID,
+		"problem_id": created.ProblemID,
+		"user_id":    created.UserID,
+		"language":   created.Language,
+	})
+	if violation != "" {
+		// Stored through Update so the rejection counts as an attempt.
+		created.Verdict = types.VerdictPolicyViolation
+		created.Message = violation
+		return s.repo.Update(ctx, created)
+	}
+	// Publishing is best-effort like events: the submission is stored
+	// either way, and one that was never dispatched stays visible as
+	// pending in QueueStats.
+	if err := s.jobs.Enqueue(ctx, created, problem, JudgePriorityPractice); err != nil {
+		log.Printf("submission %d: failed to enqueue judge job: %v", created.ID, err)
+	}
+	return created, nil
+}
+
+// check runs the checkers on a submission and returns the first
+// violation found.
+func (s *SubmissionService) check(ctx context.Context, submission types.Submission, problem types.Problem) (string, error) {
+	for _, checker := range s.checkers {
+		violation, err := checker.Check(ctx, submission, problem)
+		if err != nil {
+			return "", fmt.Errorf("static check failed: %w", err)
+		}
+		if violation != "" {
+			return violation, nil
+		}
+	}
+	return "", nil
+}
+
+// VerdictHistory returns the outcomes a submission had before it was last
+// judged, oldest first.
+func (s *SubmissionService) VerdictHistory(ctx context.Context, id int64) ([]types.SubmissionVerdictRecord, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListVerdictHistory(ctx, id)
+}
+
+// Cancel cancels one of the user's submissions that is still pending. Its
+// judge job cannot be withdrawn from the queue, so the result a worker may
+// still report for it is ignored (see JudgeResultService.Apply). Other
+// users' submissions are reported as not found.
+func (s *SubmissionService) Cancel(ctx context.Context, id int64, userID int) (types.Submission, error) {
+	submission, err := s.Get(ctx, id)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	if submission.UserID != userID {
+		return types.Submission{}, store.ErrNotFound
+	}
+	if submission.Verdict != types.VerdictPending {
+		return types.Submission{}, ErrSubmissionNotPending
+	}
+
+	now := time.Now()
+	if err := s.repo.CancelPending(ctx, id, now); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// Judging started since the submission was read.
+			return types.Submission{}, ErrSubmissionNotPending
+		}
+		return types.Submission{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventSubmissionCancelled, id, map[string]any{
+		"id":         submission.ID,
+		"problem_id": submission.ProblemID,
+		"user_id":    submission.UserID,
+	})
+	submission.Verdict = types.VerdictCancelled
+	submission.UpdatedAt = now
+	return submission, nil
+}
+
+// Rejudge clears a submission's outcome and queues it to be judged again
+// against the problem's current bundle. The outcome it had is kept in its
+// verdict history.
+func (s *SubmissionService) Rejudge(ctx context.Context, id int64, problem types.Problem) (types.Submission, error) {
+	submission, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	if submission.CodePrunedAt != nil {
+		return types.Submission{}, ErrSubmissionCodePruned
+	}
+
+	previous := submission.Verdict
+	submission.Verdict = types.VerdictPending
+	submission.Score = 0
+	submission.TestsPassed = 0
+	submission.TestsTotal = 0
+	submission.CPUTime = 0
+	submission.Memory = 0
+	submission.Message = ""
+	submission.CompileStdout = ""
+	submission.CompileStderr = ""
+	submission.SuspicionScore = 0
+	submission.TestcaseResults = nil
+	updated, err := s.repo.Update(ctx, submission)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventSubmissionRejudged, id, map[string]any{
+		"id":               updated.ID,
+		"problem_id":       updated.ProblemID,
+		"previous_verdict": previous,
+	})
+	if err := s.jobs.Enqueue(ctx, updated, problem, JudgePriorityRejudge); err != nil {
+		log.Printf("submission %d: failed to enqueue rejudge: %v", updated.ID, err)
+	}
+	updated.Code = ""
+	return updated, nil
+}
+
+// OverrideVerdict replaces a submission's verdict and score with ones
+// chosen by an admin, recording the change in the event log. The outcome
+// it had is kept in its verdict history.
+func (s *SubmissionService) OverrideVerdict(ctx context.Context, id int64, verdict types.Verdict, score int) (types.Submission, error) {
+	switch {
+	case !verdict.Valid(), verdict == types.VerdictPending, verdict == types.VerdictJudging, verdict == types.VerdictCancelled:
+		return types.Submission{}, fmt.Errorf("%w: %s is not a final verdict", ErrInvalidVerdictOverride, verdict)
+	case score < 0:
+		return types.Submission{}, fmt.Errorf("%w: score must not be negative", ErrInvalidVerdictOverride)
+	}
+	submission, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return types.Submission{}, err
+	}
+
+	previousVerdict, previousScore := submission.Verdict, submission.Score
+	submission.Verdict = verdict
+	submission.Score = score
+	updated, err := s.repo.Update(ctx, submission)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventSubmissionOverridden, id, map[string]any{
+		"id":               updated.ID,
+		"problem_id":       updated.ProblemID,
+		"verdict":          verdict,
+		"score":            score,
+		"previous_verdict": previousVerdict,
+		"previous_score":   previousScore,
+	})
+	updated.Code = ""
+	return updated, nil
+}
+
+// OffloadCode moves sources still stored in submission rows to object
+// storage, batch rows at a time, and returns how many were moved. It is
+// safe to interrupt and run again.
+func (s *SubmissionService) OffloadCode(ctx context.Context, batch int) (int, error) {
+	if s.storage == nil {
+		return 0, errors.New("object storage is not configured")
+	}
+
+	moved, afterID := 0, 0
+	for {
+		submissions, err := s.repo.ListInlineCode(ctx, afterID, batch)
+		if err != nil {
+			return moved, err
+		}
+		if len(submissions) == 0 {
+			return moved, nil
+		}
+		for _, submission := range submissions {
+			afterID = submission.ID
+			key, err := s.putCode(ctx, submission.Code)
+			if err != nil {
+				return moved, fmt.Errorf("submission %d: %w", submission.ID, err)
+			}
+			if err := s.repo.SetCodeKey(ctx, submission.ID, key); err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					continue
+				}
+				return moved, fmt.Errorf("submission %d: %w", submission.ID, err)
+			}
+			moved++
+		}
+	}
+}
+
+// JobSubmissionRetention is the kind of the scheduled job that applies the
+// submission retention policy.
+const JobSubmissionRetention = "submissions.prune_code"
+
+// pruneCodeBatch is the number of submissions pruned per query, to keep
+// each update short.
+const pruneCodeBatch = 1000
+
+// PruneCode removes the source of every submission older than maxAge
+// whose verdict is not Accepted, keeping the rest of the submission, so
+// that old failed attempts stop taking up space. Accepted sources are kept
+// since they are what users look back at. With dryRun set nothing is
+// removed and the result reports what would have been. Sources in object
+// storage are deleted by storage GC once no submission references them.
+func (s *SubmissionService) PruneCode(ctx context.Context, maxAge time.Duration, dryRun bool) (types.SubmissionPruneResult, error) {
+	result := types.SubmissionPruneResult{Cutoff: time.Now().Add(-maxAge), DryRun: dryRun}
+	if maxAge <= 0 {
+		return result, errors.New("retention age must be positive")
+	}
+	if dryRun {
+		count, bytes, err := s.repo.CountPrunableCode(ctx, result.Cutoff)
+		if err != nil {
+			return result, err
+		}
+		result.Submissions, result.Bytes = count, bytes
+		return result, nil
+	}
+
+	for {
+		count, bytes, err := s.repo.PruneCode(ctx, result.Cutoff, pruneCodeBatch)
+		if err != nil {
+			return result, err
+		}
+		result.Submissions += count
+		result.Bytes += bytes
+		if count < pruneCodeBatch {
+			return result, nil
+		}
+	}
+}
+
+// putCode stores a source under a key derived from its SHA-256, so
+// identical sources share one object. Objects are never deleted here;
+// storage GC removes them once no submission references them.
+func (s *SubmissionService) putCode(ctx context.Context, code string) (string, error) {
+	sum := sha256.Sum256([]byte(code))
+	key := "submissions/code/" + hex.EncodeToString(sum[:])
+	if err := s.storage.Put(ctx, key, strings.NewReader(code), int64(len(code)), "text/plain; charset=utf-8"); err != nil {
+		return "", fmt.Errorf("store source: %w", err)
+	}
+	return key, nil
+}
+
+// codePreview returns the first maxCodePreviewChars characters of code.
+func codePreview(code string) string {
+	count := 0
+	for i := range code {
+		if count == maxCodePreviewChars {
+			return code[:i]
+		}
+		count++
+	}
+	return code
+}
+
+func parseAnswerKey(key string) (int, int, bool) {
+	groupPart, casePart, ok := strings.Cut(key, "_")
+	if !ok {
+		return 0, 0, false
+	}
+	groupOrder, err := strconv.Atoi(groupPart)
+	if err != nil || groupOrder < 0 {
+		return 0, 0, false
+	}
+	testcaseOrder, err := strconv.Atoi(casePart)
+	if err != nil || testcaseOrder < 0 {
+		return 0, 0, false
+	}
+	return groupOrder, testcaseOrder, true
+}
+
+func hasTestcase(group types.TestcaseGroup, order int) bool {
+	for _, testcase := range group.Testcases {
+		if testcase.OrderID == order {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyResults scores judged test case results against the problem's
+// testcase groups and stores the verdict, score and test counts on the
+// submission. results must be in evaluation order (see
+// ScoreTestcaseResults). The submission is accepted only if every test
+// case passed; otherwise it takes the verdict of the first failed result.
+// Wrong answers on visible test cases are stored with an output diff, and
+// hidden test cases without their input and outputs.
+func (s *SubmissionService) ApplyResults(ctx context.Context, submission types.Submission, bundle types.TestcaseBundle, results []types.TestcaseResult) (types.Submission, error) {
+	summary := ScoreTestcaseResults(bundle.TestcaseGroups, results)
+
+	verdict := types.VerdictAccepted
+	for _, result := range results {
+		if result.Verdict != types.VerdictAccepted {
+			verdict = result.Verdict
+			break
+		}
+	}
+	if verdict == types.VerdictAccepted && summary.TestsPassed < summary.TestsTotal {
+		verdict = types.VerdictSkipped
+	}
+
+	var cpuTime, memory int64
+	for _, result := range results {
+		cpuTime = max(cpuTime, result.CPUTime)
+		memory = max(memory, result.Memory)
+	}
+
+	submission.Verdict = verdict
+	submission.Score = summary.Score
+	submission.TestsPassed = summary.TestsPassed
+	submission.TestsTotal = summary.TestsTotal
+	submission.CPUTime = cpuTime
+	submission.Memory = memory
+	submission.SuspicionScore = s.suspicionScore(ctx, submission, results)
+	submission.TestcaseResults = prepareTestcaseResults(bundle.TestcaseGroups, results)
+	return s.repo.UpdateJudged(ctx, submission)
+}
+
+// suspicionScore rates the submission's source with HardcodedOutputScore
+// against the expected outputs in results, which are hidden from the
+// submitter once stored. Output-only submissions, which are answers by
+// design, and sources that cannot be fetched score 0.
+func (s *SubmissionService) suspicionScore(ctx context.Context, submission types.Submission, results []types.TestcaseResult) int {
+	if submission.Language == OutputOnlyLanguage {
+		return 0
+	}
+	if !slices.ContainsFunc(results, func(result types.TestcaseResult) bool { return result.ExpectedOutput != "" }) {
+		return 0
+	}
+	code := submission.Code
+	if code == "" {
+		withCode, err := s.GetWithCode(ctx, int64(submission.ID))
+		if err != nil {
+			log.Printf("submission %d: failed to fetch source for scoring: %v", submission.ID, err)
+			return 0
+		}
+		code = withCode.Code
+	}
+	return HardcodedOutputScore(code, results)
+}
+
+// ListSuspicious returns a page of submissions whose suspicion score is at
+// least minScore, most suspicious first, for admin review.
+func (s *SubmissionService) ListSuspicious(ctx context.Context, minScore, offset, limit int) ([]types.Submission, int, error) {
+	return s.repo.ListSuspicious(ctx, minScore, offset, limit)
+}
+
 func (s *SubmissionService) Update(ctx context.Context, submission types.Submission) (types.Submission, error) {
 	return s.repo.Update(ctx, submission)
 }
 
+// UpdateJudged stores a judge's outcome for a submission. It returns
+// store.ErrCancelled, without storing anything, when the submission was
+// cancelled.
+func (s *SubmissionService) UpdateJudged(ctx context.Context, submission types.Submission) (types.Submission, error) {
+	return s.repo.UpdateJudged(ctx, submission)
+}
+
 func (s *SubmissionService) Delete(ctx context.Context, id int64) error {
 	return s.repo.Delete(ctx, id)
 }
+
+// ListSolved returns the problems a user has solved with their first
+// accepted time.
+func (s *SubmissionService) ListSolved(ctx context.Context, userID int) ([]types.SolvedProblem, error) {
+	return s.repo.ListSolved(ctx, userID)
+}
+
+// CanViewCode reports whether a user other than its author may view a
+// submission to problem. Admins may view every submission; other users
+// only accepted submissions to published problems whose
+// SolutionVisibility lets them.
+func (s *SubmissionService) CanViewCode(ctx context.Context, user types.User, submission types.Submission, problem types.Problem) (bool, error) {
+	if strings.EqualFold(user.Role, types.RoleAdmin) {
+		return true, nil
+	}
+	if submission.Verdict != types.VerdictAccepted || !problem.Published {
+		return false, nil
+	}
+	switch problem.SolutionVisibility {
+	case types.SolutionsAfterSolving:
+		return s.repo.HasSolved(ctx, user.ID, problem.ID)
+	default:
+		return false, nil
+	}
+}
+
+// Activity returns a user's daily submission counts for the year ending
+// today (UTC). Days without submissions are omitted.
+func (s *SubmissionService) Activity(ctx context.Context, userID int) ([]types.DailyActivity, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return s.repo.CountDaily(ctx, userID, today.AddDate(-1, 0, 1))
+}
+
+// queueStatsWindow is the window for completed-submission turnaround stats.
+const queueStatsWindow = time.Hour
+
+// QueueStats returns the judge backlog and recent turnaround times.
+func (s *SubmissionService) QueueStats(ctx context.Context) (types.JudgeQueueStats, error) {
+	stats, err := s.repo.QueueStats(ctx, time.Now().Add(-queueStatsWindow))
+	if err != nil {
+		return types.JudgeQueueStats{}, err
+	}
+	stats.WindowSeconds = int64(queueStatsWindow / time.Second)
+	return stats, nil
+}
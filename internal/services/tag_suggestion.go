@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+const (
+	maxTagSuggestions    = 5
+	tagNeighbourCount    = 10
+	tagMentionBoost      = 0.5
+	minTagSuggestionTerm = 3
+
+	// maxTagCorpus bounds how many tagged problems, the most recent ones,
+	// make up the corpus.
+	maxTagCorpus = 5000
+
+	// tagCorpusTTL is how long a tenant's corpus statistics are reused, so
+	// that saving a problem does not reload and reindex the archive. Tags
+	// applied in the meantime are suggested once it expires.
+	tagCorpusTTL = 5 * time.Minute
+
+	// tagCoSolvedSample is how many recent solvers of the problem are
+	// compared with the solvers of other problems.
+	tagCoSolvedSample = 200
+
+	// tagCoSolvedWeight scales the score of tags of problems solved by the
+	// same users, which is at most 1 per problem, below that of similar
+	// statements.
+	tagCoSolvedWeight = 0.5
+)
+
+var tagStopwords = map[string]struct{}{
+	"and": {}, "are": {}, "can": {}, "each": {}, "for": {}, "from": {}, "given": {},
+	"has": {}, "have": {}, "how": {}, "input": {}, "integer": {}, "integers": {},
+	"its": {}, "line": {}, "lines": {}, "not": {}, "number": {}, "numbers": {},
+	"one": {}, "output": {}, "print": {}, "should": {}, "that": {}, "the": {},
+	"then": {}, "there": {}, "this": {}, "two": {}, "you": {}, "your": {},
+	"which": {}, "will": {}, "with": {}, "first": {}, "contains": {}, "single": {},
+	"example": {}, "test": {}, "case": {}, "cases": {}, "all": {}, "any": {},
+}
+
+// TagSuggestion is a tag proposed for a problem together with its relevance.
+type TagSuggestion struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+// SuggestTags proposes tags for a problem from the existing archive. Tags of
+// problems with similar statements (TF-IDF cosine similarity) are weighted by
+// similarity, tags of problems solved by the same users by the share of the
+// problem's solvers who solved them, and tags mentioned verbatim in the
+// statement receive a boost. Tags already applied to the problem are never
+// suggested.
+//
+// The statement statistics of the archive are cached per tenant for
+// tagCorpusTTL; see tagCorpus.
+func (s *ProblemService) SuggestTags(ctx context.Context, problem types.Problem) ([]TagSuggestion, error) {
+	corpus, err := s.tagCorpus.get(ctx, s.repo)
+	if err != nil {
+		return nil, err
+	}
+	var coSolved []store.CoSolvedProblem
+	if problem.ID != 0 {
+		coSolved, err = s.repo.ListCoSolved(ctx, problem.ID, tagCoSolvedSample, tagNeighbourCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	applied := make(map[string]struct{}, len(problem.Tags))
+	for _, tag := range problem.Tags {
+		applied[strings.ToLower(strings.TrimSpace(tag))] = struct{}{}
+	}
+
+	scores := make(map[string]float64)
+	canonical := make(map[string]string)
+	addScore := func(tag string, score float64) {
+		key := strings.ToLower(strings.TrimSpace(tag))
+		if key == "" {
+			return
+		}
+		if _, ok := applied[key]; ok {
+			return
+		}
+		if _, ok := canonical[key]; !ok {
+			canonical[key] = strings.TrimSpace(tag)
+		}
+		scores[key] += score
+	}
+
+	queryTerms := tokenizeStatement(problem.Title + " " + problem.Description)
+	for _, match := range corpus.similar(queryTerms, problem.ID, tagNeighbourCount) {
+		for _, tag := range corpus.docs[match.index].tags {
+			addScore(tag, match.similarity)
+		}
+	}
+
+	for _, related := range coSolved {
+		if related.Sampled == 0 {
+			continue
+		}
+		share := float64(related.Shared) / float64(related.Sampled)
+		for _, tag := range related.Tags {
+			addScore(tag, share*tagCoSolvedWeight)
+		}
+	}
+
+	statement := " " + strings.Join(queryTerms, " ") + " "
+	for _, tag := range corpus.tags {
+		if tag.usedOnlyBy == problem.ID && problem.ID != 0 {
+			continue
+		}
+		if strings.Contains(statement, " "+tag.phrase+" ") {
+			addScore(tag.tag, tagMentionBoost)
+		}
+	}
+
+	suggestions := make([]TagSuggestion, 0, len(scores))
+	for key, score := range scores {
+		suggestions = append(suggestions, TagSuggestion{
+			Tag:   canonical[key],
+			Score: math.Round(score*1000) / 1000,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+	if len(suggestions) > maxTagSuggestions {
+		suggestions = suggestions[:maxTagSuggestions]
+	}
+	return suggestions, nil
+}
+
+// tagCorpus holds the statement statistics of a tenant's tagged problems:
+// the TF-IDF vector of every statement, indexed by term so that a query
+// only visits the statements sharing one of its terms.
+type tagCorpus struct {
+	docs     []tagDocument
+	postings map[string][]tagPosting
+	idf      map[string]float64
+	// unseenIDF is the IDF of a term no statement contains.
+	unseenIDF float64
+	tags      []corpusTag
+}
+
+type tagDocument struct {
+	problemID int
+	tags      []string
+}
+
+type tagPosting struct {
+	doc    int
+	weight float64
+}
+
+// corpusTag is a tag used in the corpus, as matched against statements.
+type corpusTag struct {
+	tag    string
+	phrase string
+	// usedOnlyBy is the problem that is the only one with the tag, or 0.
+	usedOnlyBy int
+}
+
+type similarDocument struct {
+	index      int
+	similarity float64
+}
+
+func newTagCorpus(problems []types.Problem) *tagCorpus {
+	docs := make([][]string, len(problems))
+	for i, problem := range problems {
+		docs[i] = tokenizeStatement(problem.Title + " " + problem.Description)
+	}
+	corpus := &tagCorpus{
+		docs:      make([]tagDocument, len(problems)),
+		postings:  make(map[string][]tagPosting),
+		idf:       inverseDocumentFrequency(docs),
+		unseenIDF: math.Log(float64(len(docs))+1) + 1,
+	}
+
+	tagIndex := make(map[string]int)
+	for i, problem := range problems {
+		corpus.docs[i] = tagDocument{problemID: problem.ID, tags: problem.Tags}
+		for term, weight := range tfidfVector(docs[i], corpus.idf) {
+			corpus.postings[term] = append(corpus.postings[term], tagPosting{doc: i, weight: weight})
+		}
+		for _, tag := range problem.Tags {
+			key := strings.ToLower(strings.TrimSpace(tag))
+			if j, ok := tagIndex[key]; ok {
+				if corpus.tags[j].usedOnlyBy != problem.ID {
+					corpus.tags[j].usedOnlyBy = 0
+				}
+				continue
+			}
+			phrase := strings.Join(tokenizeStatement(tag), " ")
+			if phrase == "" {
+				continue
+			}
+			tagIndex[key] = len(corpus.tags)
+			corpus.tags = append(corpus.tags, corpusTag{tag: tag, phrase: phrase, usedOnlyBy: problem.ID})
+		}
+	}
+	return corpus
+}
+
+// similar returns the count statements most similar to terms, best
+// first, leaving out the statement of excludeID.
+func (c *tagCorpus) similar(terms []string, excludeID, count int) []similarDocument {
+	idf := func(term string) float64 {
+		if weight, ok := c.idf[term]; ok {
+			return weight
+		}
+		return c.unseenIDF
+	}
+	query := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		query[term]++
+	}
+	var norm float64
+	for term, tf := range query {
+		query[term] = tf * idf(term)
+		norm += query[term] * query[term]
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+
+	dots := make(map[int]float64)
+	for term, weight := range query {
+		for _, posting := range c.postings[term] {
+			dots[posting.doc] += weight / norm * posting.weight
+		}
+	}
+	similar := make([]similarDocument, 0, len(dots))
+	for doc, dot := range dots {
+		if excludeID != 0 && c.docs[doc].problemID == excludeID {
+			continue
+		}
+		if dot > 0 {
+			similar = append(similar, similarDocument{index: doc, similarity: dot})
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].similarity != similar[j].similarity {
+			return similar[i].similarity > similar[j].similarity
+		}
+		return similar[i].index < similar[j].index
+	})
+	if len(similar) > count {
+		similar = similar[:count]
+	}
+	return similar
+}
+
+// tagCorpusCache keeps the tag corpus of each tenant for tagCorpusTTL.
+type tagCorpusCache struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	corpora map[int]cachedTagCorpus
+}
+
+type cachedTagCorpus struct {
+	corpus    *tagCorpus
+	expiresAt time.Time
+}
+
+func newTagCorpusCache() *tagCorpusCache {
+	return &tagCorpusCache{now: time.Now, corpora: make(map[int]cachedTagCorpus)}
+}
+
+// get returns the corpus of the tenant of ctx, loading it from repo when
+// it is missing or expired.
+func (c *tagCorpusCache) get(ctx context.Context, repo ProblemRepository) (*tagCorpus, error) {
+	tenantID, _ := store.TenantFromContext(ctx)
+	now := c.now()
+	c.mu.Lock()
+	cached, ok := c.corpora[tenantID]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.corpus, nil
+	}
+
+	problems, err := repo.ListTagged(ctx, maxTagCorpus)
+	if err != nil {
+		return nil, err
+	}
+	corpus := newTagCorpus(problems)
+	c.mu.Lock()
+	c.corpora[tenantID] = cachedTagCorpus{corpus: corpus, expiresAt: now.Add(tagCorpusTTL)}
+	c.mu.Unlock()
+	return corpus, nil
+}
+
+func tokenizeStatement(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if len(field) < minTagSuggestionTerm {
+			continue
+		}
+		if _, stop := tagStopwords[field]; stop {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+func inverseDocumentFrequency(docs [][]string) map[string]float64 {
+	df := make(map[string]int)
+	for _, doc := range docs {
+		seen := make(map[string]struct{}, len(doc))
+		for _, term := range doc {
+			if _, ok := seen[term]; ok {
+				continue
+			}
+			seen[term] = struct{}{}
+			df[term]++
+		}
+	}
+	idf := make(map[string]float64, len(df))
+	n := float64(len(docs))
+	for term, count := range df {
+		idf[term] = math.Log((n+1)/(float64(count)+1)) + 1
+	}
+	return idf
+}
+
+func tfidfVector(terms []string, idf map[string]float64) map[string]float64 {
+	vec := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		vec[term]++
+	}
+	var norm float64
+	for term, tf := range vec {
+		weight := tf * idf[term]
+		vec[term] = weight
+		norm += weight * weight
+	}
+	if norm == 0 {
+		return vec
+	}
+	norm = math.Sqrt(norm)
+	for term := range vec {
+		vec[term] /= norm
+	}
+	return vec
+}
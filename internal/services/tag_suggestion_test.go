@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+type fakeTagRepo struct {
+	ProblemRepository
+	problems  []types.Problem
+	coSolved  map[int][]store.CoSolvedProblem
+	loads     int
+	lastLimit int
+}
+
+func (r *fakeTagRepo) ListTagged(_ context.Context, limit int) ([]types.Problem, error) {
+	r.loads++
+	r.lastLimit = limit
+	return r.problems, nil
+}
+
+func (r *fakeTagRepo) ListCoSolved(_ context.Context, problemID, _, _ int) ([]store.CoSolvedProblem, error) {
+	return r.coSolved[problemID], nil
+}
+
+func tagArchive() []types.Problem {
+	return []types.Problem{
+		{ID: 1, Title: "Shortest route", Description: "Find the shortest route between cities along weighted roads.", Tags: []string{"graphs", "Shortest Paths"}},
+		{ID: 2, Title: "Increasing subsequence", Description: "Find the longest strictly increasing subsequence of the sequence.", Tags: []string{"dp"}},
+		{ID: 3, Title: "Grid roads", Description: "Roads form a grid graph; find the cheapest route between corners.", Tags: []string{"graphs", "dijkstra"}},
+		{ID: 4, Title: "Coin change", Description: "Count the ways to pay the amount with coins.", Tags: []string{"dp", "combinatorics"}},
+	}
+}
+
+func suggestionScores(suggestions []TagSuggestion) map[string]float64 {
+	scores := make(map[string]float64, len(suggestions))
+	for _, suggestion := range suggestions {
+		scores[suggestion.Tag] = suggestion.Score
+	}
+	return scores
+}
+
+func TestSuggestTags(t *testing.T) {
+	repo := &fakeTagRepo{problems: tagArchive()}
+	problems := NewProblemService(repo, nil)
+
+	suggestions, err := problems.SuggestTags(context.Background(), types.Problem{
+		Title:       "Delivery",
+		Description: "Roads join the cities; take the shortest route for the delivery, which is combinatorics free.",
+	})
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	// graphs is on both road problems, the closest of which also has
+	// Shortest Paths.
+	scores := suggestionScores(suggestions)
+	if !(scores["graphs"] > scores["Shortest Paths"] && scores["Shortest Paths"] > scores["dijkstra"] && scores["dijkstra"] > 0) {
+		t.Fatalf("suggestions = %+v, want graphs, Shortest Paths, then dijkstra", suggestions)
+	}
+	if _, ok := scores["dp"]; ok {
+		t.Fatalf("suggestions = %+v, want no tags of unrelated statements", suggestions)
+	}
+	if scores["combinatorics"] != tagMentionBoost {
+		t.Fatalf("combinatorics = %v, want the mention boost %v", scores["combinatorics"], tagMentionBoost)
+	}
+	if repo.lastLimit != maxTagCorpus {
+		t.Fatalf("corpus limit = %d, want %d", repo.lastLimit, maxTagCorpus)
+	}
+}
+
+func TestSuggestTagsSkipsAppliedTagsAndItself(t *testing.T) {
+	problems := NewProblemService(&fakeTagRepo{problems: tagArchive()}, nil)
+
+	// Problem 1 resaved with its own tags: its statement must not match
+	// itself, and its tags are not suggested again.
+	archive := tagArchive()
+	suggestions, err := problems.SuggestTags(context.Background(), archive[0])
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	scores := suggestionScores(suggestions)
+	for _, tag := range archive[0].Tags {
+		if _, ok := scores[tag]; ok {
+			t.Fatalf("suggestions = %+v, want no applied tags", suggestions)
+		}
+	}
+	if _, ok := scores["dijkstra"]; !ok {
+		t.Fatalf("suggestions = %+v, want dijkstra from the similar problem 3", suggestions)
+	}
+
+	// A tag only problem 1 uses is not boosted for being in its own
+	// statement once the setter removes it.
+	withoutTag := archive[0]
+	withoutTag.Title = "Shortest paths"
+	withoutTag.Tags = []string{"graphs"}
+	suggestions, err = problems.SuggestTags(context.Background(), withoutTag)
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if _, ok := suggestionScores(suggestions)["Shortest Paths"]; ok {
+		t.Fatalf("suggestions = %+v, want no tag only the problem itself used", suggestions)
+	}
+}
+
+func TestSuggestTagsFromCoSolvedProblems(t *testing.T) {
+	repo := &fakeTagRepo{
+		problems: tagArchive(),
+		coSolved: map[int][]store.CoSolvedProblem{
+			7: {
+				{ProblemID: 8, Tags: []string{"greedy"}, Shared: 3, Sampled: 4},
+				{ProblemID: 9, Tags: []string{"greedy", "sorting"}, Shared: 1, Sampled: 4},
+			},
+		},
+	}
+	problems := NewProblemService(repo, nil)
+
+	suggestions, err := problems.SuggestTags(context.Background(), types.Problem{ID: 7, Title: "Queue", Description: "Serve the customers."})
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	scores := suggestionScores(suggestions)
+	if want := (0.75 + 0.25) * tagCoSolvedWeight; scores["greedy"] != want {
+		t.Fatalf("greedy = %v, want %v (suggestions %+v)", scores["greedy"], want, suggestions)
+	}
+	if want := 0.25 * tagCoSolvedWeight; scores["sorting"] != want {
+		t.Fatalf("sorting = %v, want %v (suggestions %+v)", scores["sorting"], want, suggestions)
+	}
+}
+
+func TestSuggestTagsCachesCorpus(t *testing.T) {
+	repo := &fakeTagRepo{problems: tagArchive()}
+	problems := NewProblemService(repo, nil)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	problems.tagCorpus.now = func() time.Time { return now }
+	suggest := func(ctx context.Context) {
+		t.Helper()
+		if _, err := problems.SuggestTags(ctx, types.Problem{Title: "Roads"}); err != nil {
+			t.Fatalf("suggest: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	suggest(ctx)
+	suggest(ctx)
+	if repo.loads != 1 {
+		t.Fatalf("loads = %d, want the corpus loaded once", repo.loads)
+	}
+
+	suggest(store.WithTenant(ctx, 2))
+	if repo.loads != 2 {
+		t.Fatalf("loads = %d, want a separate corpus per tenant", repo.loads)
+	}
+
+	now = now.Add(tagCorpusTTL)
+	suggest(ctx)
+	if repo.loads != 3 {
+		t.Fatalf("loads = %d, want the corpus reloaded once expired", repo.loads)
+	}
+}
+
+// TestTagCorpusSimilarity checks the indexed search against computing
+// the cosine similarity with every statement.
+func TestTagCorpusSimilarity(t *testing.T) {
+	archive := tagArchive()
+	corpus := newTagCorpus(archive)
+	query := tokenizeStatement("the cheapest route between cities over a grid of weighted roads")
+
+	got := corpus.similar(query, 0, len(archive))
+
+	docs := make([][]string, len(archive))
+	for i, problem := range archive {
+		docs[i] = tokenizeStatement(problem.Title + " " + problem.Description)
+	}
+	// Terms no statement contains still weigh in the query's norm.
+	idf := map[string]float64{}
+	for _, term := range query {
+		idf[term] = corpus.unseenIDF
+	}
+	maps.Copy(idf, corpus.idf)
+	queryVector := tfidfVector(query, idf)
+	want := map[int]float64{}
+	for i, doc := range docs {
+		var dot float64
+		for term, weight := range tfidfVector(doc, corpus.idf) {
+			dot += weight * queryVector[term]
+		}
+		if dot > 0 {
+			want[i] = dot
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("similar = %+v, want %d statements", got, len(want))
+	}
+	for i, match := range got {
+		if math.Abs(match.similarity-want[match.index]) > 1e-9 {
+			t.Fatalf("similarity of %d = %v, want %v", match.index, match.similarity, want[match.index])
+		}
+		if i > 0 && got[i-1].similarity < match.similarity {
+			t.Fatalf("similar = %+v, want best first", got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// tenantCacheTTL is how long a slug lookup, found or not, is reused.
+// Tenants are rarely created and never renamed, so a new tenant becomes
+// reachable within this delay.
+const tenantCacheTTL = time.Minute
+
+// maxCachedTenantSlugs bounds the slug cache; it is emptied when full, as
+// requests may name any number of unknown slugs.
+const maxCachedTenantSlugs = 1024
+
+// tenantSlugPattern matches slugs usable as a DNS label.
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+
+// ErrInvalidTenant is returned when a tenant's slug or name is malformed.
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// TenantRepository defines persistence operations for tenants.
+type TenantRepository interface {
+	GetBySlug(ctx context.Context, slug string) (types.Tenant, error)
+	List(ctx context.Context) ([]types.Tenant, error)
+	Create(ctx context.Context, tenant types.Tenant) (types.Tenant, error)
+}
+
+// TenantService resolves and creates tenants. Lookups by slug, made on
+// every request, are cached.
+type TenantService struct {
+	repo TenantRepository
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedTenant
+}
+
+type cachedTenant struct {
+	tenant    types.Tenant
+	err       error
+	expiresAt time.Time
+}
+
+func NewTenantService(repo TenantRepository) *TenantService {
+	return &TenantService{
+		repo:  repo,
+		now:   time.Now,
+		cache: make(map[string]cachedTenant),
+	}
+}
+
+// Resolve returns the tenant named by slug. It returns store.ErrNotFound
+// if there is none.
+func (s *TenantService) Resolve(ctx context.Context, slug string) (types.Tenant, error) {
+	slug = strings.ToLower(slug)
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.cache[slug]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.tenant, cached.err
+	}
+
+	tenant, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return types.Tenant{}, err
+	}
+	// Unknown slugs are cached too, so that requests for them do not each
+	// reach the database.
+	s.mu.Lock()
+	if len(s.cache) >= maxCachedTenantSlugs {
+		clear(s.cache)
+	}
+	s.cache[slug] = cachedTenant{tenant: tenant, err: err, expiresAt: now.Add(tenantCacheTTL)}
+	s.mu.Unlock()
+	return tenant, err
+}
+
+func (s *TenantService) List(ctx context.Context) ([]types.Tenant, error) {
+	return s.repo.List(ctx)
+}
+
+// Create adds a tenant. It returns store.ErrConflict if the slug is taken.
+func (s *TenantService) Create(ctx context.Context, slug, name string) (types.Tenant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	name = strings.TrimSpace(name)
+	if !tenantSlugPattern.MatchString(slug) {
+		return types.Tenant{}, fmt.Errorf("%w: slug must be 1-32 lowercase letters, digits or hyphens", ErrInvalidTenant)
+	}
+	if name == "" {
+		return types.Tenant{}, fmt.Errorf("%w: name is required", ErrInvalidTenant)
+	}
+
+	tenant, err := s.repo.Create(ctx, types.Tenant{Slug: slug, Name: name})
+	if err != nil {
+		return types.Tenant{}, err
+	}
+	s.mu.Lock()
+	delete(s.cache, slug)
+	s.mu.Unlock()
+	return tenant, nil
+}
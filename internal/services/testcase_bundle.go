@@ -6,6 +6,7 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -20,47 +22,161 @@ import (
 	"github.com/jjudge-oj/apiserver/types"
 )
 
-var testcaseFilenamePattern = regexp.MustCompile(`^\d+_\d+\.(in|out)$`)
+var (
+	testcaseFilenamePattern = regexp.MustCompile(`^\d+_\d+\.(in|out)$`)
+	graderFilenamePattern   = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+)
+
+// graderDir is the bundle directory holding grader sources for grader problems.
+const graderDir = "grader"
+
+// validatorDir is the bundle directory holding the input validator, which
+// must be named validator.<ext>.
+const validatorDir = "validator"
+
+// solutionsDir is the bundle directory holding reference solutions.
+const solutionsDir = "solutions"
+
+// generatorsDir is the bundle directory holding testcase generators and
+// generationManifestFile, which lists the testcases they produce.
+const (
+	generatorsDir          = "generators"
+	generationManifestFile = generatorsDir + "/manifest.json"
+	maxManifestBytes       = 1 << 20
+)
+
+// solutionKinds maps the prefix of a reference solution's file name to the
+// verdict it is expected to receive, e.g. solutions/tle_naive.py.
+var solutionKinds = map[string]types.Verdict{
+	"accepted": types.VerdictAccepted,
+	"wa":       types.VerdictWrongAnswer,
+	"tle":      types.VerdictTimeLimitExceeded,
+	"mle":      types.VerdictMemoryLimitExceeded,
+	"re":       types.VerdictRuntimeError,
+}
 
 const testcaseExtractDirEnv = "JJUDGE_TESTCASE_EXTRACT_DIR"
 
 // GetTestcaseBundleFromArchive verifies the testcase bundle data and returns its SHA-256 hash.
-func (s *ProblemService) GetTestcaseBundleFromArchive(filename string, data []byte, tcGroups []types.TestcaseGroup) (types.TestcaseBundle, error) {
+// Grader problems must also ship their grader sources under grader/.
+// Reference solutions may be shipped under solutions/; if any are, one of
+// them must be an accepted solution. An input validator may be shipped as
+// validator/validator.<ext> to check every testcase input. Testcases may also be produced by
+// generators under generators/, listed in generators/manifest.json; their
+// outputs come from the accepted solution, so one is then required.
+func (s *ProblemService) GetTestcaseBundleFromArchive(problemType types.ProblemType, filename string, data []byte, tcGroups []types.TestcaseGroup) (types.TestcaseBundle, error) {
 	if len(data) == 0 {
 		return types.TestcaseBundle{}, errors.New("empty bundle data")
 	}
+	return s.GetTestcaseBundleFromReader(problemType, filename, bytes.NewReader(data), tcGroups)
+}
 
-	hash := sha256.Sum256(data)
-	actual := hex.EncodeToString(hash[:])
+// GetTestcaseBundleFromReader is GetTestcaseBundleFromArchive for a bundle
+// streamed from r, so bundles too large to hold in memory can be verified.
+func (s *ProblemService) GetTestcaseBundleFromReader(problemType types.ProblemType, filename string, r io.Reader, tcGroups []types.TestcaseGroup) (types.TestcaseBundle, error) {
+	if err := ValidateTestcaseGroups(tcGroups); err != nil {
+		return types.TestcaseBundle{}, err
+	}
 
 	tcBundle := types.TestcaseBundle{}
 	tcBundle.ObjectKey = filename
-	tcBundle.SHA256 = actual
 
 	lower := strings.ToLower(strings.TrimSpace(filename))
 	switch {
 	case strings.HasSuffix(lower, ".zip"):
 		return types.TestcaseBundle{}, errors.New("zip bundles are not supported")
 	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
-		gr, err := gzip.NewReader(bytes.NewReader(data))
+		hasher := sha256.New()
+		tee := io.TeeReader(r, hasher)
+		gr, err := gzip.NewReader(tee)
+		if errors.Is(err, io.EOF) {
+			return types.TestcaseBundle{}, errors.New("empty bundle data")
+		}
 		if err != nil {
 			return types.TestcaseBundle{}, errors.New("invalid tar.gz bundle")
 		}
 		defer gr.Close()
 
 		tr := tar.NewReader(gr)
-		updatedGroups, err := readTestcaseFromTarGz(tr, tcGroups)
+		contents, err := readTestcaseFromTarGz(tr, tcGroups, problemType == types.ProblemTypeGrader)
 		if err != nil {
 			return types.TestcaseBundle{}, err
 		}
-		tcBundle.TestcaseGroups = updatedGroups
+		// The hash covers the whole archive, including anything after the
+		// end of the tar stream.
+		if _, err := io.Copy(io.Discard, tee); err != nil {
+			return types.TestcaseBundle{}, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		tcBundle.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+		hasAccepted := slices.ContainsFunc(contents.solutions, func(sol types.ReferenceSolution) bool {
+			return sol.Expected == types.VerdictAccepted
+		})
+		if len(contents.solutions) > 0 && !hasAccepted {
+			return types.TestcaseBundle{}, errors.New("reference solutions must include an accepted solution")
+		}
+		if contents.generation != nil && !hasAccepted {
+			return types.TestcaseBundle{}, errors.New("generated testcases require an accepted reference solution to produce outputs")
+		}
+		if problemType == types.ProblemTypeGrader && len(GraderLanguages(contents.graderFiles)) == 0 {
+			return types.TestcaseBundle{}, errors.New("grader problems require a grader source under grader/")
+		}
+		tcBundle.TestcaseGroups = contents.groups
+		tcBundle.GraderFiles = contents.graderFiles
+		tcBundle.Solutions = contents.solutions
+		tcBundle.Validator = contents.validator
+		tcBundle.Generators = contents.generators
+		tcBundle.Generation = contents.generation
 		return tcBundle, nil
 	default:
 		return types.TestcaseBundle{}, errors.New("unsupported bundle format")
 	}
 }
 
-func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]types.TestcaseGroup, error) {
+// BundleEntryError is a bundle validation error caused by a single entry of
+// the archive.
+type BundleEntryError struct {
+	File string
+	Err  error
+}
+
+func (e *BundleEntryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BundleEntryError) Unwrap() error {
+	return e.Err
+}
+
+// BundleErrorDetails converts a bundle validation error into its API form.
+func BundleErrorDetails(err error) *types.BundleError {
+	details := &types.BundleError{Message: err.Error()}
+	var entryErr *BundleEntryError
+	if errors.As(err, &entryErr) {
+		details.File = entryErr.File
+	}
+	return details
+}
+
+// bundleContents is everything readTestcaseFromTarGz found in a bundle.
+type bundleContents struct {
+	groups      []types.TestcaseGroup
+	graderFiles []string
+	solutions   []types.ReferenceSolution
+	validator   string
+	generators  []string
+	generation  *types.GenerationManifest
+}
+
+func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup, allowGrader bool) (_ bundleContents, err error) {
+	// Errors returned while an entry is being read are attributed to it.
+	var entry string
+	defer func() {
+		if err != nil && entry != "" {
+			err = &BundleEntryError{File: entry, Err: err}
+		}
+	}()
+
 	extractBase := strings.TrimSpace(os.Getenv(testcaseExtractDirEnv))
 	if extractBase == "" {
 		extractBase = "."
@@ -68,7 +184,7 @@ func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]ty
 
 	tempDir, err := os.MkdirTemp(extractBase, "testcase-bundle-")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create bundle extract directory: %w", err)
+		return bundleContents{}, fmt.Errorf("failed to create bundle extract directory: %w", err)
 	}
 	defer func() {
 		_ = os.RemoveAll(tempDir)
@@ -84,32 +200,71 @@ func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]ty
 		groupOrders[i] = make(map[int]*pair)
 	}
 
+	var contents bundleContents
 	count := 0
 	for {
+		entry = ""
 		header, err := tr.Next()
 		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			return nil, errors.New("invalid tar.gz bundle")
+			return bundleContents{}, errors.New("invalid tar.gz bundle")
 		}
+		entry = header.Name
 		if header.FileInfo().IsDir() {
 			continue
 		}
 		if !header.FileInfo().Mode().IsRegular() {
-			return nil, errors.New("bundle contains unsupported entries")
+			return bundleContents{}, errors.New("bundle contains unsupported entries")
+		}
+		if name, ok := graderFilename(header.Name); ok && allowGrader {
+			contents.graderFiles = append(contents.graderFiles, name)
+			continue
+		}
+		if solution, ok, err := referenceSolution(header.Name); ok {
+			if err != nil {
+				return bundleContents{}, err
+			}
+			contents.solutions = append(contents.solutions, solution)
+			continue
+		}
+		if name, ok, err := validatorFilename(header.Name); ok {
+			if err != nil {
+				return bundleContents{}, err
+			}
+			if contents.validator != "" {
+				return bundleContents{}, errors.New("bundle must contain at most one input validator")
+			}
+			contents.validator = name
+			continue
+		}
+		if path.Clean(header.Name) == generationManifestFile {
+			manifest, err := readGenerationManifest(tr)
+			if err != nil {
+				return bundleContents{}, err
+			}
+			contents.generation = manifest
+			continue
+		}
+		if name, ok, err := generatorFilename(header.Name); ok {
+			if err != nil {
+				return bundleContents{}, err
+			}
+			contents.generators = append(contents.generators, name)
+			continue
 		}
 		if err := validateBundleFilename(header.Name); err != nil {
-			return nil, err
+			return bundleContents{}, err
 		}
 
 		base := path.Base(path.Clean(header.Name))
 		groupOrder, testcaseOrder, ext, err := parseTestcaseFilename(base)
 		if err != nil {
-			return nil, err
+			return bundleContents{}, err
 		}
 		if groupOrder < 0 || groupOrder >= len(tcGroups) {
-			return nil, fmt.Errorf("testcase group %d does not exist", groupOrder)
+			return bundleContents{}, fmt.Errorf("testcase group %d does not exist", groupOrder)
 		}
 
 		p := groupOrders[groupOrder][testcaseOrder]
@@ -120,35 +275,56 @@ func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]ty
 		switch ext {
 		case "in":
 			if p.in {
-				return nil, fmt.Errorf("duplicate testcase input: %d_%d.in", groupOrder, testcaseOrder)
+				return bundleContents{}, fmt.Errorf("duplicate testcase input: %d_%d.in", groupOrder, testcaseOrder)
 			}
 			p.in = true
 		case "out":
 			if p.out {
-				return nil, fmt.Errorf("duplicate testcase output: %d_%d.out", groupOrder, testcaseOrder)
+				return bundleContents{}, fmt.Errorf("duplicate testcase output: %d_%d.out", groupOrder, testcaseOrder)
 			}
 			p.out = true
 		default:
-			return nil, fmt.Errorf("invalid testcase filename: %s", base)
+			return bundleContents{}, fmt.Errorf("invalid testcase filename: %s", base)
 		}
 
 		dst := filepath.Join(tempDir, base)
 		outFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 		if err != nil {
-			return nil, fmt.Errorf("failed to extract testcase: %w", err)
+			return bundleContents{}, fmt.Errorf("failed to extract testcase: %w", err)
 		}
 		if _, err := io.Copy(outFile, tr); err != nil {
 			_ = outFile.Close()
-			return nil, fmt.Errorf("failed to extract testcase: %w", err)
+			return bundleContents{}, fmt.Errorf("failed to extract testcase: %w", err)
 		}
 		if err := outFile.Close(); err != nil {
-			return nil, fmt.Errorf("failed to extract testcase: %w", err)
+			return bundleContents{}, fmt.Errorf("failed to extract testcase: %w", err)
 		}
 		count++
 	}
+	entry = ""
+
+	// Generated testcases take the place of shipped .in/.out pairs.
+	if contents.generation != nil {
+		for _, test := range contents.generation.Tests {
+			if test.Group < 0 || test.Group >= len(tcGroups) {
+				return bundleContents{}, fmt.Errorf("testcase group %d does not exist", test.Group)
+			}
+			if test.Test < 0 {
+				return bundleContents{}, fmt.Errorf("invalid generated testcase %d_%d", test.Group, test.Test)
+			}
+			if !slices.Contains(contents.generators, path.Join(generatorsDir, test.Generator)) {
+				return bundleContents{}, fmt.Errorf("generated testcase %d_%d uses unknown generator %q", test.Group, test.Test, test.Generator)
+			}
+			if groupOrders[test.Group][test.Test] != nil {
+				return bundleContents{}, fmt.Errorf("testcase %d_%d is both shipped and generated", test.Group, test.Test)
+			}
+			groupOrders[test.Group][test.Test] = &pair{in: true, out: true}
+			count++
+		}
+	}
 
 	if count == 0 {
-		return nil, errors.New("bundle has no testcases")
+		return bundleContents{}, errors.New("bundle has no testcases")
 	}
 
 	for groupOrder, orders := range groupOrders {
@@ -159,7 +335,7 @@ func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]ty
 		testcaseOrders := make([]int, 0, len(orders))
 		for order, pair := range orders {
 			if !pair.in || !pair.out {
-				return nil, fmt.Errorf("testcase %d_%d must have both .in and .out files", groupOrder, order)
+				return bundleContents{}, fmt.Errorf("testcase %d_%d must have both .in and .out files", groupOrder, order)
 			}
 			testcaseOrders = append(testcaseOrders, order)
 		}
@@ -167,18 +343,42 @@ func readTestcaseFromTarGz(tr *tar.Reader, tcGroups []types.TestcaseGroup) ([]ty
 		sort.Ints(testcaseOrders)
 		for expected, order := range testcaseOrders {
 			if order != expected {
-				return nil, fmt.Errorf("testcase order must be consecutive in group %d", groupOrder)
+				return bundleContents{}, fmt.Errorf("testcase order must be consecutive in group %d", groupOrder)
 			}
 		}
 
+		group := &tcGroups[groupOrder]
 		for _, order := range testcaseOrders {
-			tcGroups[groupOrder].Testcases = append(tcGroups[groupOrder].Testcases, types.Testcase{
-				OrderID: order,
-			})
+			testcase := types.Testcase{
+				OrderID:     order,
+				TimeLimit:   group.TimeLimit,
+				MemoryLimit: group.MemoryLimit,
+			}
+			for _, limit := range group.TestcaseLimits {
+				if limit.OrderID != order {
+					continue
+				}
+				if limit.TimeLimit > 0 {
+					testcase.TimeLimit = limit.TimeLimit
+				}
+				if limit.MemoryLimit > 0 {
+					testcase.MemoryLimit = limit.MemoryLimit
+				}
+			}
+			group.Testcases = append(group.Testcases, testcase)
+		}
+	}
+
+	for groupOrder, group := range tcGroups {
+		for _, limit := range group.TestcaseLimits {
+			if limit.OrderID >= len(group.Testcases) {
+				return bundleContents{}, fmt.Errorf("limits given for unknown testcase %d_%d", groupOrder, limit.OrderID)
+			}
 		}
 	}
 
-	return tcGroups, nil
+	contents.groups = tcGroups
+	return contents, nil
 }
 
 func parseTestcaseFilename(base string) (int, int, string, error) {
@@ -219,3 +419,131 @@ func validateBundleFilename(name string) error {
 	}
 	return nil
 }
+
+// graderFilename reports whether name is a file directly under grader/ with
+// a safe base name, returning its bundle-relative path.
+func graderFilename(name string) (string, bool) {
+	clean := path.Clean(name)
+	dir, base := path.Split(clean)
+	if dir != graderDir+"/" || !graderFilenamePattern.MatchString(base) {
+		return "", false
+	}
+	return clean, true
+}
+
+// referenceSolution reports whether name is a file directly under
+// solutions/, and if so describes it. Solutions must be named
+// <kind>[_<label>].<ext>, where kind is a key of solutionKinds and ext the
+// extension of a supported language.
+func referenceSolution(name string) (types.ReferenceSolution, bool, error) {
+	clean := path.Clean(name)
+	dir, base := path.Split(clean)
+	if dir != solutionsDir+"/" {
+		return types.ReferenceSolution{}, false, nil
+	}
+	if !graderFilenamePattern.MatchString(base) {
+		return types.ReferenceSolution{}, true, fmt.Errorf("invalid solution filename: %s", clean)
+	}
+
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	kind, _, _ := strings.Cut(strings.TrimSuffix(base, "."+ext), "_")
+	expected, ok := solutionKinds[kind]
+	if !ok {
+		return types.ReferenceSolution{}, true, fmt.Errorf("solution %s must be named accepted, wa, tle, mle or re, optionally followed by _<label>", clean)
+	}
+	language := languageForExtension(ext)
+	if language == "" {
+		return types.ReferenceSolution{}, true, fmt.Errorf("solution %s has an unsupported language", clean)
+	}
+	return types.ReferenceSolution{File: clean, Language: language, Expected: expected}, true, nil
+}
+
+// validatorFilename reports whether name is a file directly under
+// validator/, returning its bundle-relative path.
+func validatorFilename(name string) (string, bool, error) {
+	clean := path.Clean(name)
+	dir, base := path.Split(clean)
+	if dir != validatorDir+"/" {
+		return "", false, nil
+	}
+	ext := strings.TrimPrefix(path.Ext(base), ".")
+	if strings.TrimSuffix(base, "."+ext) != "validator" {
+		return "", true, fmt.Errorf("input validator must be named validator.<ext>, got %s", clean)
+	}
+	if languageForExtension(ext) == "" {
+		return "", true, fmt.Errorf("input validator %s has an unsupported language", clean)
+	}
+	return clean, true, nil
+}
+
+// ValidatorLanguage returns the language ID of a validator path returned by
+// GetTestcaseBundleFromArchive.
+func ValidatorLanguage(validator string) string {
+	return languageForExtension(strings.TrimPrefix(path.Ext(validator), "."))
+}
+
+// generatorFilename reports whether name is a file directly under
+// generators/, returning its bundle-relative path. Generators must be
+// written in a supported language.
+func generatorFilename(name string) (string, bool, error) {
+	clean := path.Clean(name)
+	dir, base := path.Split(clean)
+	if dir != generatorsDir+"/" {
+		return "", false, nil
+	}
+	if !graderFilenamePattern.MatchString(base) {
+		return "", true, fmt.Errorf("invalid generator filename: %s", clean)
+	}
+	if languageForExtension(strings.TrimPrefix(path.Ext(base), ".")) == "" {
+		return "", true, fmt.Errorf("generator %s has an unsupported language", clean)
+	}
+	return clean, true, nil
+}
+
+func readGenerationManifest(r io.Reader) (*types.GenerationManifest, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestBytes+1))
+	if err != nil {
+		return nil, errors.New("invalid tar.gz bundle")
+	}
+	if len(data) > maxManifestBytes {
+		return nil, fmt.Errorf("%s exceeds %d bytes", generationManifestFile, maxManifestBytes)
+	}
+	var manifest types.GenerationManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", generationManifestFile, err)
+	}
+	if len(manifest.Tests) == 0 {
+		return nil, fmt.Errorf("%s lists no tests", generationManifestFile)
+	}
+	return &manifest, nil
+}
+
+// languageForExtension returns the ID of the language whose sources use
+// ext, or "" if there is none.
+func languageForExtension(ext string) string {
+	for _, lang := range DefaultLanguages {
+		if lang.Extension == ext {
+			return lang.ID
+		}
+	}
+	return ""
+}
+
+// GraderLanguages returns the IDs of the languages that have a grader
+// source (grader/grader.<ext>) among the given bundle files.
+func GraderLanguages(graderFiles []string) []string {
+	var ids []string
+	for _, file := range graderFiles {
+		base := path.Base(file)
+		ext := strings.TrimPrefix(path.Ext(base), ".")
+		if strings.TrimSuffix(base, "."+ext) != "grader" {
+			continue
+		}
+		for _, lang := range DefaultLanguages {
+			if lang.Extension == ext {
+				ids = append(ids, lang.ID)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+var (
+	// ErrGenerationNotPending is returned when generated testcases are
+	// uploaded for a bundle version that is not the latest one or has no
+	// generation manifest.
+	ErrGenerationNotPending = errors.New("bundle has no pending testcase generation")
+
+	// ErrInvalidGeneratedBundle is returned when a materialized bundle does
+	// not match the bundle it was generated from.
+	ErrInvalidGeneratedBundle = errors.New("invalid generated bundle")
+)
+
+// TestcaseGenerationService materializes generated testcases through the
+// judge workers and stores the result as a new bundle version.
+type TestcaseGenerationService struct {
+	problems   *ProblemService
+	storage    *storage.Storage
+	jobs       *JudgeQueue
+	validation *ProblemValidationService
+}
+
+func NewTestcaseGenerationService(
+	problems *ProblemService,
+	objectStorage *storage.Storage,
+	jobs *JudgeQueue,
+	validation *ProblemValidationService,
+) *TestcaseGenerationService {
+	return &TestcaseGenerationService{
+		problems:   problems,
+		storage:    objectStorage,
+		jobs:       jobs,
+		validation: validation,
+	}
+}
+
+// Start queues generation for the problem's latest bundle if it lists
+// generated testcases, and otherwise starts validating its reference
+// solutions right away.
+func (s *TestcaseGenerationService) Start(ctx context.Context, problemID int) error {
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return err
+	}
+	bundle := problem.TestcaseBundle
+	if bundle.Generation == nil {
+		return s.validation.Start(ctx, problemID)
+	}
+
+	for _, solution := range bundle.Solutions {
+		if solution.Expected == types.VerdictAccepted {
+			return s.jobs.EnqueueGeneration(ctx, problem, solution)
+		}
+	}
+	return errors.New("generated testcases require an accepted reference solution")
+}
+
+// Complete stores a bundle materialized by a judge worker from bundle
+// version sourceVersion. The upload must hold the same testcases with the
+// generated ones written out as .in/.out files and no manifest. It becomes
+// the problem's next bundle version, whose reference solutions are then
+// validated.
+func (s *TestcaseGenerationService) Complete(ctx context.Context, problemID, sourceVersion int, data []byte) (types.TestcaseBundle, error) {
+	if s.storage == nil {
+		return types.TestcaseBundle{}, errors.New("object storage is not configured")
+	}
+
+	problem, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	source := problem.TestcaseBundle
+	if source.Version != sourceVersion || source.Generation == nil {
+		return types.TestcaseBundle{}, ErrGenerationNotPending
+	}
+
+	groups := make([]types.TestcaseGroup, len(source.TestcaseGroups))
+	for i, group := range source.TestcaseGroups {
+		group.Testcases = nil
+		groups[i] = group
+	}
+
+	hash := sha256.Sum256(data)
+	key := fmt.Sprintf("problems/%d/bundles/%s.tar.gz", problemID, hex.EncodeToString(hash[:]))
+	bundle, err := s.problems.GetTestcaseBundleFromArchive(problem.Type, key, data, groups)
+	if err != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("%w: %w", ErrInvalidGeneratedBundle, err)
+	}
+	if bundle.Generation != nil {
+		return types.TestcaseBundle{}, fmt.Errorf("%w: %s must be removed once testcases are generated", ErrInvalidGeneratedBundle, generationManifestFile)
+	}
+	for i, group := range bundle.TestcaseGroups {
+		if want := len(source.TestcaseGroups[i].Testcases); len(group.Testcases) != want {
+			return types.TestcaseBundle{}, fmt.Errorf("%w: group %d has %d testcases, want %d", ErrInvalidGeneratedBundle, group.OrderID, len(group.Testcases), want)
+		}
+	}
+
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/gzip"); err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	if err := s.problems.UpdateTestcaseBundle(ctx, problemID, bundle); err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	// The new version is stored either way; a failed start leaves the
+	// problem's validation status as it was.
+	_ = s.validation.Start(ctx, problemID)
+
+	updated, err := s.problems.Get(ctx, problemID)
+	if err != nil {
+		return types.TestcaseBundle{}, err
+	}
+	return updated.TestcaseBundle, nil
+}
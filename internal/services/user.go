@@ -2,6 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/jjudge-oj/apiserver/types"
 )
@@ -15,13 +18,17 @@ type UserRepository interface {
 	Delete(ctx context.Context, id int) error
 }
 
+// ErrInvalidRole is returned when assigning a role that does not exist.
+var ErrInvalidRole = errors.New("invalid role")
+
 // UserService encapsulates user use-cases.
 type UserService struct {
-	repo UserRepository
+	repo   UserRepository
+	events *EventService
 }
 
-func NewUserService(repo UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo UserRepository, events *EventService) *UserService {
+	return &UserService{repo: repo, events: events}
 }
 
 func (s *UserService) GetByID(ctx context.Context, id int) (types.User, error) {
@@ -33,13 +40,39 @@ func (s *UserService) GetByUsername(ctx context.Context, username string) (types
 }
 
 func (s *UserService) Create(ctx context.Context, user types.User) (types.User, error) {
-	return s.repo.Create(ctx, user)
+	created, err := s.repo.Create(ctx, user)
+	if err != nil {
+		return types.User{}, err
+	}
+	_ = s.events.Emit(ctx, types.EventUserRegistered, int64(created.ID), map[string]any{
+		"id":       created.ID,
+		"username": created.Username,
+	})
+	return created, nil
 }
 
 func (s *UserService) Update(ctx context.Context, user types.User) (types.User, error) {
 	return s.repo.Update(ctx, user)
 }
 
+// SetRole assigns one of RoleUser, RoleSetter, RoleReviewer or RoleAdmin to
+// a user.
+func (s *UserService) SetRole(ctx context.Context, id int, role string) (types.User, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	switch role {
+	case types.RoleUser, types.RoleSetter, types.RoleReviewer, types.RoleAdmin:
+	default:
+		return types.User{}, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return types.User{}, err
+	}
+	user.Role = role
+	return s.repo.Update(ctx, user)
+}
+
 func (s *UserService) Delete(ctx context.Context, id int) error {
 	return s.repo.Delete(ctx, id)
 }
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jjudge-oj/apiserver/internal/mailer"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMailerNotConfigured is returned when credentials should be emailed but
+// no mailer is configured.
+var ErrMailerNotConfigured = errors.New("email delivery is not configured")
+
+const (
+	generatedPasswordLength = 12
+	// passwordAlphabet leaves out characters that are easy to misread on
+	// a printed handout.
+	passwordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// Import result statuses.
+const (
+	UserImportCreated = "created"
+	UserImportFailed  = "error"
+)
+
+// UserImportRow is one account to create.
+type UserImportRow struct {
+	// Line is the source line number, used to report errors.
+	Line     int
+	Username string
+	Name     string
+	Email    string
+	// Password is optional; one is generated when empty.
+	Password string
+}
+
+// UserImportResult reports the outcome for one row.
+type UserImportResult struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	UserID   int    `json:"user_id,omitempty"`
+	// Password is the generated password. It is only returned when it was
+	// not emailed to the user.
+	Password string `json:"password,omitempty"`
+	Emailed  bool   `json:"emailed,omitempty"`
+}
+
+// UserImportService creates accounts in bulk.
+type UserImportService struct {
+	users  *UserService
+	mailer mailer.Mailer
+}
+
+// NewUserImportService constructs a UserImportService. m may be nil when
+// email delivery is not configured.
+func NewUserImportService(users *UserService, m mailer.Mailer) *UserImportService {
+	return &UserImportService{users: users, mailer: m}
+}
+
+// Import creates an account for each valid row. Rows fail independently;
+// a row error never aborts the rest of the import. When sendCredentials is
+// set, generated passwords are emailed instead of returned.
+func (s *UserImportService) Import(ctx context.Context, rows []UserImportRow, sendCredentials bool) ([]UserImportResult, error) {
+	if sendCredentials && s.mailer == nil {
+		return nil, ErrMailerNotConfigured
+	}
+
+	results := make([]UserImportResult, len(rows))
+	generated := make([]bool, len(rows))
+	seen := make(map[string]int, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		results[i] = UserImportResult{Line: row.Line, Username: row.Username}
+		if err := validateImportRow(*row); err != nil {
+			results[i].fail(err.Error())
+			continue
+		}
+		key := strings.ToLower(row.Username)
+		if line, ok := seen[key]; ok {
+			results[i].fail(fmt.Sprintf("duplicate username (also on line %d)", line))
+			continue
+		}
+		seen[key] = row.Line
+
+		if row.Password == "" {
+			password, err := generatePassword()
+			if err != nil {
+				return nil, err
+			}
+			row.Password = password
+			generated[i] = true
+		}
+	}
+
+	hashes := hashPasswords(rows, results)
+	for i, row := range rows {
+		result := &results[i]
+		if result.Status == UserImportFailed {
+			continue
+		}
+		if hashes[i] == "" {
+			result.fail("failed to hash password")
+			continue
+		}
+
+		if _, err := s.users.GetByUsername(ctx, row.Username); err == nil {
+			result.fail("username already exists")
+			continue
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+
+		user, err := s.users.Create(ctx, types.User{
+			Username:     row.Username,
+			Email:        row.Email,
+			Name:         row.Name,
+			Role:         "user",
+			PasswordHash: hashes[i],
+		})
+		if err != nil {
+			result.fail("failed to create user")
+			continue
+		}
+		result.Status = UserImportCreated
+		result.UserID = user.ID
+
+		if !generated[i] {
+			continue
+		}
+		if sendCredentials {
+			msg, err := credentialsMessage(row)
+			if err == nil {
+				err = s.mailer.Send(ctx, msg)
+			}
+			if err != nil {
+				// The account exists; hand the password back so it is
+				// not lost.
+				result.Error = "account created but credentials email failed"
+				result.Password = row.Password
+				continue
+			}
+			result.Emailed = true
+		} else {
+			result.Password = row.Password
+		}
+	}
+
+	return results, nil
+}
+
+func (r *UserImportResult) fail(message string) {
+	r.Status = UserImportFailed
+	r.Error = message
+}
+
+func validateImportRow(row UserImportRow) error {
+	switch {
+	case row.Username == "":
+		return errors.New("username is required")
+	case row.Name == "":
+		return errors.New("name is required")
+	case row.Email == "":
+		return errors.New("email is required")
+	}
+	if strings.ContainsAny(row.Username, " \t") {
+		return errors.New("username must not contain whitespace")
+	}
+	if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+		return errors.New("invalid email")
+	}
+	return nil
+}
+
+// hashPasswords bcrypt-hashes the password of every row that has not
+// already failed. Hashing dominates import time, so it is spread across
+// CPUs. An empty hash marks a failure.
+func hashPasswords(rows []UserImportRow, results []UserImportResult) []string {
+	hashes := make([]string, len(rows))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				hashed, err := bcrypt.GenerateFromPassword([]byte(rows[i].Password), bcrypt.DefaultCost)
+				if err == nil {
+					hashes[i] = string(hashed)
+				}
+			}
+		}()
+	}
+	for i := range rows {
+		if results[i].Status != UserImportFailed {
+			work <- i
+		}
+	}
+	close(work)
+	wg.Wait()
+	return hashes
+}
+
+func generatePassword() (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	b := make([]byte, generatedPasswordLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func credentialsMessage(row UserImportRow) (mailer.Message, error) {
+	return mailer.Render(row.Email, mailer.TemplateCredentials, map[string]any{
+		"Name":     row.Name,
+		"Username": row.Username,
+		"Password": row.Password,
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/jjudge-oj/apiserver/config"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -79,6 +81,47 @@ func (g *GCSClient) Delete(ctx context.Context, key string) error {
 	return g.client.Bucket(g.bucket).Object(key).Delete(ctx)
 }
 
+// List returns the objects in the configured bucket whose keys start with
+// prefix.
+func (g *GCSClient) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	it := g.client.Bucket(g.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          attrs.Name,
+			Size:         attrs.Size,
+			LastModified: attrs.Updated,
+		})
+	}
+	return objects, nil
+}
+
+// PresignGet returns a V4 signed download URL for an object. The client's
+// credentials must be able to sign, e.g. a service account key.
+func (g *GCSClient) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return g.signedURL(key, "GET", expiry)
+}
+
+// PresignPut returns a V4 signed upload URL for an object.
+func (g *GCSClient) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return g.signedURL(key, "PUT", expiry)
+}
+
+func (g *GCSClient) signedURL(key, method string, expiry time.Duration) (string, error) {
+	return g.client.Bucket(g.bucket).SignedURL(key, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  method,
+		Expires: time.Now().Add(expiry),
+	})
+}
+
 // Client exposes the underlying GCS SDK client.
 func (g *GCSClient) Client() *storage.Client {
 	return g.client
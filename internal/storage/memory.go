@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is an ObjectStorage that keeps objects in memory. It is meant for
+// tests; presigned URLs it returns are not reachable over HTTP.
+type Memory struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+// NewMemory constructs an empty in-memory object store.
+func NewMemory(bucket string) *Memory {
+	return &Memory{bucket: bucket, objects: make(map[string]memoryObject)}
+}
+
+// EnsureBucket is a no-op; the bucket always exists.
+func (m *Memory) EnsureBucket(ctx context.Context) error {
+	return nil
+}
+
+// Put stores an object, replacing any object with the same key. size is
+// checked when it is not negative.
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("object %s: read %d bytes, expected %d", key, len(data), size)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, contentType: contentType, lastModified: time.Now()}
+	return nil
+}
+
+// Get opens a reader for an object. Missing objects yield an error
+// wrapping fs.ErrNotExist.
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", key, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+// Delete removes an object. Deleting a missing object is not an error.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// List returns the objects whose keys start with prefix, sorted by key.
+func (m *Memory) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var objects []ObjectInfo
+	for key, object := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectInfo{
+				Key:          key,
+				Size:         int64(len(object.data)),
+				LastModified: object.lastModified,
+			})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// PresignGet returns a memory:// URL naming the object.
+func (m *Memory) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return m.presign("GET", key, expiry), nil
+}
+
+// PresignPut returns a memory:// URL naming the object.
+func (m *Memory) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return m.presign("PUT", key, expiry), nil
+}
+
+func (m *Memory) presign(method, key string, expiry time.Duration) string {
+	u := url.URL{
+		Scheme: "memory",
+		Host:   m.bucket,
+		Path:   "/" + key,
+		RawQuery: url.Values{
+			"method":  {method},
+			"expires": {time.Now().Add(expiry).UTC().Format(time.RFC3339)},
+		}.Encode(),
+	}
+	return u.String()
+}
+
+// Bucket returns the bucket name given to NewMemory.
+func (m *Memory) Bucket() string {
+	return m.bucket
+}
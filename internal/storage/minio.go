@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/jjudge-oj/apiserver/config"
 	"github.com/minio/minio-go/v7"
@@ -73,6 +74,44 @@ func (m *MinioClient) Delete(ctx context.Context, key string) error {
 	return m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{})
 }
 
+// List returns the objects in the configured bucket whose keys start with
+// prefix.
+func (m *MinioClient) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for object := range m.client.ListObjects(ctx, m.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// PresignGet returns a presigned download URL for an object.
+func (m *MinioClient) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := m.client.PresignedGetObject(ctx, m.bucket, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// PresignPut returns a presigned upload URL for an object.
+func (m *MinioClient) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := m.client.PresignedPutObject(ctx, m.bucket, key, expiry)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
 // Client exposes the underlying MinIO SDK client.
 func (m *MinioClient) Client() *minio.Client {
 	return m.client
@@ -2,7 +2,12 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"strings"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
 )
 
 // ObjectStorage defines common object operations across backends.
@@ -11,9 +16,19 @@ type ObjectStorage interface {
 	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
 	Get(ctx context.Context, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error)
 	Bucket() string
 }
 
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
 // Storage wraps an ObjectStorage backend with a stable API.
 type Storage struct {
 	backend ObjectStorage
@@ -24,6 +39,26 @@ func NewStorage(backend ObjectStorage) *Storage {
 	return &Storage{backend: backend}
 }
 
+// NewFromConfig constructs a Storage backed by the backend selected in config.
+func NewFromConfig(ctx context.Context, cfg config.Config) (*Storage, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.StorageBackend)) {
+	case "", "minio":
+		backend, err := NewMinioClient(cfg.Minio)
+		if err != nil {
+			return nil, err
+		}
+		return NewStorage(backend), nil
+	case "gcs":
+		backend, err := NewGCSClient(ctx, cfg.GCS)
+		if err != nil {
+			return nil, err
+		}
+		return NewStorage(backend), nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend: %s", cfg.StorageBackend)
+	}
+}
+
 // EnsureBucket ensures the configured bucket exists.
 func (s *Storage) EnsureBucket(ctx context.Context) error {
 	return s.backend.EnsureBucket(ctx)
@@ -44,6 +79,24 @@ func (s *Storage) Delete(ctx context.Context, key string) error {
 	return s.backend.Delete(ctx, key)
 }
 
+// List returns the objects in the configured bucket whose keys start with
+// prefix.
+func (s *Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return s.backend.List(ctx, prefix)
+}
+
+// PresignGet returns a URL that downloads an object without credentials
+// until expiry elapses.
+func (s *Storage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.backend.PresignGet(ctx, key, expiry)
+}
+
+// PresignPut returns a URL that uploads an object with an HTTP PUT without
+// credentials until expiry elapses.
+func (s *Storage) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.backend.PresignPut(ctx, key, expiry)
+}
+
 // Bucket returns the configured bucket name.
 func (s *Storage) Bucket() string {
 	return s.backend.Bucket()
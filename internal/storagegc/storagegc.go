@@ -0,0 +1,97 @@
+package storagegc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/internal/storage"
+)
+
+// JobKind is the kind of the scheduled job that runs a collection pass.
+const JobKind = "storage.gc"
+
+// ObjectKeyLister returns the storage object keys referenced by the database.
+type ObjectKeyLister interface {
+	ListObjectKeys(ctx context.Context) ([]string, error)
+}
+
+// Result summarizes a garbage collection pass.
+type Result struct {
+	// Scanned is the number of objects found in the bucket.
+	Scanned int `json:"scanned"`
+
+	// Referenced is the number of scanned objects still referenced.
+	Referenced int `json:"referenced"`
+
+	// Recent is the number of unreferenced objects kept because they are
+	// younger than the grace period.
+	Recent int `json:"recent"`
+
+	// Deleted lists the unreferenced objects that were deleted, or that
+	// would have been in a dry run.
+	Deleted []string `json:"deleted"`
+
+	// DeletedBytes is the total size of Deleted.
+	DeletedBytes int64 `json:"deleted_bytes"`
+}
+
+// Collector deletes storage objects that no database row references.
+type Collector struct {
+	storage *storage.Storage
+	keys    ObjectKeyLister
+	grace   time.Duration
+}
+
+// New constructs a Collector. Unreferenced objects modified within grace
+// are kept, since uploads are written to storage before the row that
+// references them is committed.
+func New(objectStorage *storage.Storage, keys ObjectKeyLister, grace time.Duration) *Collector {
+	return &Collector{
+		storage: objectStorage,
+		keys:    keys,
+		grace:   grace,
+	}
+}
+
+// Run reconciles the bucket against the referenced keys and deletes
+// unreferenced objects older than the grace period. With dryRun set nothing
+// is deleted and Result reports what would have been.
+func (c *Collector) Run(ctx context.Context, dryRun bool) (Result, error) {
+	// Objects are listed before the referenced keys are loaded, so an
+	// object referenced by a row committed in between is still seen as
+	// referenced.
+	objects, err := c.storage.List(ctx, "")
+	if err != nil {
+		return Result{}, fmt.Errorf("list objects: %w", err)
+	}
+	keys, err := c.keys.ListObjectKeys(ctx)
+	if err != nil {
+		return Result{}, fmt.Errorf("list object keys: %w", err)
+	}
+	referenced := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		referenced[key] = struct{}{}
+	}
+
+	result := Result{Scanned: len(objects), Deleted: []string{}}
+	cutoff := time.Now().Add(-c.grace)
+	for _, object := range objects {
+		if _, ok := referenced[object.Key]; ok {
+			result.Referenced++
+			continue
+		}
+		if object.LastModified.After(cutoff) {
+			result.Recent++
+			continue
+		}
+		if !dryRun {
+			if err := c.storage.Delete(ctx, object.Key); err != nil {
+				return result, fmt.Errorf("delete %s: %w", object.Key, err)
+			}
+		}
+		result.Deleted = append(result.Deleted, object.Key)
+		result.DeletedBytes += object.Size
+	}
+	return result, nil
+}
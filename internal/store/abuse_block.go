@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AbuseBlockRepository handles persistence for principals blocked by the
+// abuse detector.
+type AbuseBlockRepository struct {
+	db *sql.DB
+}
+
+func NewAbuseBlockRepository(db *sql.DB) *AbuseBlockRepository {
+	return &AbuseBlockRepository{db: db}
+}
+
+// Block stores block, replacing any earlier block of the same principal.
+func (r *AbuseBlockRepository) Block(ctx context.Context, block types.AbuseBlock) error {
+	const query = `
+		INSERT INTO abuse_blocks (principal, reason, blocked_until, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (principal) DO UPDATE
+		SET reason = EXCLUDED.reason,
+			blocked_until = EXCLUDED.blocked_until,
+			created_at = EXCLUDED.created_at`
+	_, err := r.db.ExecContext(ctx, query, block.Principal, block.Reason, block.BlockedUntil, block.CreatedAt)
+	return err
+}
+
+// ListActive returns the blocks still in effect at now, soonest to lift
+// first. Expired blocks are deleted on the way.
+func (r *AbuseBlockRepository) ListActive(ctx context.Context, now time.Time) ([]types.AbuseBlock, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM abuse_blocks WHERE blocked_until <= $1`, now); err != nil {
+		return nil, err
+	}
+
+	const query = `
+		SELECT principal, reason, blocked_until, created_at
+		FROM abuse_blocks
+		WHERE blocked_until > $1
+		ORDER BY blocked_until, principal`
+	rows, err := r.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []types.AbuseBlock
+	for rows.Next() {
+		var block types.AbuseBlock
+		if err := rows.Scan(&block.Principal, &block.Reason, &block.BlockedUntil, &block.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, rows.Err()
+}
+
+// Unblock lifts the block of principal. It returns ErrNotFound if the
+// principal is not blocked.
+func (r *AbuseBlockRepository) Unblock(ctx context.Context, principal string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM abuse_blocks WHERE principal = $1`, principal)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AnnouncementRepository handles persistence for announcements.
+type AnnouncementRepository struct {
+	db *sql.DB
+}
+
+func NewAnnouncementRepository(db *sql.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+// ListActive returns announcements that have not expired at the given time,
+// pinned ones first and newest first otherwise.
+func (r *AnnouncementRepository) ListActive(ctx context.Context, now time.Time, offset, limit int) ([]types.Announcement, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM announcements
+		WHERE (expires_at IS NULL OR expires_at > $1) AND ($2 = 0 OR tenant_id = $2)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, now, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT id, title, body, pinned, author_id, expires_at, created_at, updated_at
+		FROM announcements
+		WHERE (expires_at IS NULL OR expires_at > $1) AND ($4 = 0 OR tenant_id = $4)
+		ORDER BY pinned DESC, created_at DESC, id DESC
+		OFFSET $2 LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, listQuery, now, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	announcements := make([]types.Announcement, 0, limit)
+	for rows.Next() {
+		announcement, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		announcements = append(announcements, announcement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return announcements, total, nil
+}
+
+func (r *AnnouncementRepository) Get(ctx context.Context, id int) (types.Announcement, error) {
+	const query = `
+		SELECT id, title, body, pinned, author_id, expires_at, created_at, updated_at
+		FROM announcements
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	announcement, err := scanAnnouncement(r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Announcement{}, ErrNotFound
+		}
+		return types.Announcement{}, err
+	}
+	return announcement, nil
+}
+
+func (r *AnnouncementRepository) Create(ctx context.Context, announcement types.Announcement) (types.Announcement, error) {
+	now := time.Now()
+	announcement.CreatedAt = now
+	announcement.UpdatedAt = now
+
+	const query = `
+		INSERT INTO announcements (title, body, pinned, author_id, expires_at, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		announcement.Title,
+		announcement.Body,
+		announcement.Pinned,
+		nullableID(announcement.AuthorID),
+		announcement.ExpiresAt,
+		announcement.CreatedAt,
+		announcement.UpdatedAt,
+		tenantForWrite(ctx),
+	).Scan(&announcement.ID); err != nil {
+		return types.Announcement{}, err
+	}
+	return announcement, nil
+}
+
+func (r *AnnouncementRepository) Update(ctx context.Context, announcement types.Announcement) (types.Announcement, error) {
+	announcement.UpdatedAt = time.Now()
+
+	const query = `
+		UPDATE announcements
+		SET title = $1,
+			body = $2,
+			pinned = $3,
+			expires_at = $4,
+			updated_at = $5
+		WHERE id = $6 AND ($7 = 0 OR tenant_id = $7)
+		RETURNING author_id, created_at`
+	var authorID sql.NullInt64
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		announcement.Title,
+		announcement.Body,
+		announcement.Pinned,
+		announcement.ExpiresAt,
+		announcement.UpdatedAt,
+		announcement.ID,
+		tenantScope(ctx),
+	).Scan(&authorID, &announcement.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Announcement{}, ErrNotFound
+		}
+		return types.Announcement{}, err
+	}
+	announcement.AuthorID = int(authorID.Int64)
+	return announcement, nil
+}
+
+func (r *AnnouncementRepository) Delete(ctx context.Context, id int) error {
+	const query = `DELETE FROM announcements WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAnnouncement(row rowScanner) (types.Announcement, error) {
+	var announcement types.Announcement
+	var authorID sql.NullInt64
+	var expiresAt sql.NullTime
+	if err := row.Scan(
+		&announcement.ID,
+		&announcement.Title,
+		&announcement.Body,
+		&announcement.Pinned,
+		&authorID,
+		&expiresAt,
+		&announcement.CreatedAt,
+		&announcement.UpdatedAt,
+	); err != nil {
+		return types.Announcement{}, err
+	}
+	announcement.AuthorID = int(authorID.Int64)
+	if expiresAt.Valid {
+		announcement.ExpiresAt = &expiresAt.Time
+	}
+	return announcement, nil
+}
+
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// AppealRepository handles persistence for verdict appeals.
+type AppealRepository struct {
+	db *sql.DB
+}
+
+func NewAppealRepository(db *sql.DB) *AppealRepository {
+	return &AppealRepository{db: db}
+}
+
+// AppealFilter narrows List. Zero values match everything.
+type AppealFilter struct {
+	UserID int
+	Status types.AppealStatus
+}
+
+const appealColumns = `
+	id, submission_id, user_id, message, status, resolution, response,
+	resolved_by, resolved_at, created_at`
+
+// Create stores a new open appeal. It returns ErrConflict when the
+// submission already has an open appeal.
+func (r *AppealRepository) Create(ctx context.Context, appeal types.Appeal) (types.Appeal, error) {
+	appeal.Status = types.AppealOpen
+	appeal.CreatedAt = time.Now()
+
+	const query = `
+		INSERT INTO submission_appeals (submission_id, user_id, message, status, created_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (submission_id) WHERE status = 'open' DO NOTHING
+		RETURNING id`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		appeal.SubmissionID,
+		appeal.UserID,
+		appeal.Message,
+		appeal.Status,
+		appeal.CreatedAt,
+		tenantForWrite(ctx),
+	).Scan(&appeal.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Appeal{}, ErrConflict
+		}
+		return types.Appeal{}, err
+	}
+	return appeal, nil
+}
+
+func (r *AppealRepository) Get(ctx context.Context, id int64) (types.Appeal, error) {
+	const query = `
+		SELECT` + appealColumns + `
+		FROM submission_appeals
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	appeal, err := scanAppeal(r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Appeal{}, ErrNotFound
+		}
+		return types.Appeal{}, err
+	}
+	return appeal, nil
+}
+
+// List returns a page of appeals matching filter, oldest first so that
+// open appeals are handled in the order they were filed, and the total
+// number of matches.
+func (r *AppealRepository) List(ctx context.Context, filter AppealFilter, offset, limit int) ([]types.Appeal, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const where = `
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = '' OR status = $2) AND ($3 = 0 OR tenant_id = $3)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM submission_appeals`+where, filter.UserID, filter.Status, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + appealColumns + `
+		FROM submission_appeals` + where + `
+		ORDER BY id
+		OFFSET $4 LIMIT $5`
+	rows, err := r.db.QueryContext(ctx, listQuery, filter.UserID, filter.Status, tenantScope(ctx), offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	appeals := make([]types.Appeal, 0, limit)
+	for rows.Next() {
+		appeal, err := scanAppeal(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		appeals = append(appeals, appeal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return appeals, total, nil
+}
+
+// Resolve records an admin's resolution of an open appeal. It returns
+// ErrNotFound when there is no such open appeal.
+func (r *AppealRepository) Resolve(ctx context.Context, appeal types.Appeal) (types.Appeal, error) {
+	now := time.Now()
+	const query = `
+		UPDATE submission_appeals
+		SET status = $1,
+			resolution = $2,
+			response = $3,
+			resolved_by = $4,
+			resolved_at = $5
+		WHERE id = $6 AND status = $7 AND ($8 = 0 OR tenant_id = $8)
+		RETURNING` + appealColumns
+	resolved, err := scanAppeal(r.db.QueryRowContext(
+		ctx,
+		query,
+		types.AppealResolved,
+		appeal.Resolution,
+		appeal.Response,
+		appeal.ResolvedBy,
+		now,
+		appeal.ID,
+		types.AppealOpen,
+		tenantScope(ctx),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Appeal{}, ErrNotFound
+		}
+		return types.Appeal{}, err
+	}
+	return resolved, nil
+}
+
+func scanAppeal(row rowScanner) (types.Appeal, error) {
+	var (
+		appeal     types.Appeal
+		resolvedBy sql.NullInt64
+		resolvedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&appeal.ID,
+		&appeal.SubmissionID,
+		&appeal.UserID,
+		&appeal.Message,
+		&appeal.Status,
+		&appeal.Resolution,
+		&appeal.Response,
+		&resolvedBy,
+		&resolvedAt,
+		&appeal.CreatedAt,
+	); err != nil {
+		return types.Appeal{}, err
+	}
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		appeal.ResolvedBy = &id
+	}
+	if resolvedAt.Valid {
+		appeal.ResolvedAt = &resolvedAt.Time
+	}
+	return appeal, nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// BundleIntegrityRepository handles persistence for integrity checks of
+// stored testcase bundles.
+type BundleIntegrityRepository struct {
+	db *sql.DB
+}
+
+func NewBundleIntegrityRepository(db *sql.DB) *BundleIntegrityRepository {
+	return &BundleIntegrityRepository{db: db}
+}
+
+// GetLatest returns the latest stored bundle of a problem to be verified.
+func (r *BundleIntegrityRepository) GetLatest(ctx context.Context, problemID int) (types.BundleVerification, error) {
+	const query = `
+		SELECT problem_id, version, object_key, sha256
+		FROM testcase_bundles
+		WHERE problem_id = $1 AND object_key <> ''
+			AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))
+		ORDER BY version DESC
+		LIMIT 1`
+	var bundle types.BundleVerification
+	err := r.db.QueryRowContext(ctx, query, problemID, tenantScope(ctx)).Scan(
+		&bundle.ProblemID,
+		&bundle.Version,
+		&bundle.ObjectKey,
+		&bundle.ExpectedSHA256,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.BundleVerification{}, ErrNotFound
+		}
+		return types.BundleVerification{}, err
+	}
+	return bundle, nil
+}
+
+// ListLatest returns the latest stored bundle of every problem, least
+// recently verified first.
+func (r *BundleIntegrityRepository) ListLatest(ctx context.Context) ([]types.BundleVerification, error) {
+	const query = `
+		SELECT tb.problem_id, tb.version, tb.object_key, tb.sha256
+		FROM problems p
+		JOIN LATERAL (
+			SELECT problem_id, version, object_key, sha256, verified_at
+			FROM testcase_bundles
+			WHERE problem_id = p.id AND object_key <> ''
+			ORDER BY version DESC
+			LIMIT 1
+		) tb ON true
+		WHERE $1 = 0 OR p.tenant_id = $1
+		ORDER BY tb.verified_at ASC NULLS FIRST, tb.problem_id`
+	rows, err := r.db.QueryContext(ctx, query, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bundles []types.BundleVerification
+	for rows.Next() {
+		var bundle types.BundleVerification
+		if err := rows.Scan(
+			&bundle.ProblemID,
+			&bundle.Version,
+			&bundle.ObjectKey,
+			&bundle.ExpectedSHA256,
+		); err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, bundle)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// Record stores the outcome of verifying a bundle version.
+func (r *BundleIntegrityRepository) Record(ctx context.Context, verification types.BundleVerification) error {
+	const query = `
+		UPDATE testcase_bundles
+		SET corrupted = $1,
+			integrity_error = $2,
+			verified_at = $3
+		WHERE problem_id = $4 AND version = $5`
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		verification.Corrupted,
+		verification.Error,
+		verification.VerifiedAt,
+		verification.ProblemID,
+		verification.Version,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
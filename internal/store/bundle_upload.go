@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// BundleUploadRepository handles persistence for bundle upload sessions.
+type BundleUploadRepository struct {
+	db *sql.DB
+}
+
+func NewBundleUploadRepository(db *sql.DB) *BundleUploadRepository {
+	return &BundleUploadRepository{db: db}
+}
+
+const bundleUploadSelect = `
+	SELECT id, problem_id, user_id, filename, testcase_groups, status, error,
+	       error_file, size, bundle_version, created_at, updated_at, expires_at
+	FROM bundle_uploads`
+
+func (r *BundleUploadRepository) Get(ctx context.Context, id string) (types.BundleUpload, error) {
+	const where = `
+		WHERE id = $1
+			AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))`
+	return scanBundleUpload(r.db.QueryRowContext(ctx, bundleUploadSelect+where, id, tenantScope(ctx)))
+}
+
+// GetLatestForProblem returns the most recently started session for a
+// problem.
+func (r *BundleUploadRepository) GetLatestForProblem(ctx context.Context, problemID int) (types.BundleUpload, error) {
+	const where = `
+		WHERE problem_id = $1
+			AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))
+		ORDER BY created_at DESC
+		LIMIT 1`
+	return scanBundleUpload(r.db.QueryRowContext(ctx, bundleUploadSelect+where, problemID, tenantScope(ctx)))
+}
+
+func scanBundleUpload(row *sql.Row) (types.BundleUpload, error) {
+	var (
+		upload  types.BundleUpload
+		message string
+		file    string
+	)
+	err := row.Scan(
+		&upload.ID,
+		&upload.ProblemID,
+		&upload.UserID,
+		&upload.Filename,
+		jsonColumn(&upload.TestcaseGroups),
+		&upload.Status,
+		&message,
+		&file,
+		&upload.Size,
+		&upload.BundleVersion,
+		&upload.CreatedAt,
+		&upload.UpdatedAt,
+		&upload.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.BundleUpload{}, ErrNotFound
+		}
+		return types.BundleUpload{}, err
+	}
+	if message != "" {
+		upload.Error = &types.BundleError{File: file, Message: message}
+	}
+	return upload, nil
+}
+
+func (r *BundleUploadRepository) Create(ctx context.Context, upload types.BundleUpload) (types.BundleUpload, error) {
+	now := time.Now()
+	upload.CreatedAt = now
+	upload.UpdatedAt = now
+
+	groupsJSON, err := json.Marshal(upload.TestcaseGroups)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+
+	const query = `
+		INSERT INTO bundle_uploads (id, problem_id, user_id, filename, testcase_groups, status, created_at, updated_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	if _, err := r.db.ExecContext(
+		ctx,
+		query,
+		upload.ID,
+		upload.ProblemID,
+		upload.UserID,
+		upload.Filename,
+		groupsJSON,
+		upload.Status,
+		upload.CreatedAt,
+		upload.UpdatedAt,
+		upload.ExpiresAt,
+	); err != nil {
+		return types.BundleUpload{}, err
+	}
+	return upload, nil
+}
+
+// Transition moves a session from one status to another, returning
+// ErrNotFound if it is not in the from status, so that concurrent requests
+// cannot both complete an upload.
+func (r *BundleUploadRepository) Transition(ctx context.Context, id string, from, to types.BundleUploadStatus) error {
+	const query = `
+		UPDATE bundle_uploads
+		SET status = $1,
+			updated_at = $2
+		WHERE id = $3 AND status = $4`
+	result, err := r.db.ExecContext(ctx, query, to, time.Now(), id, from)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Finish stores the outcome of processing an assembled bundle.
+func (r *BundleUploadRepository) Finish(ctx context.Context, upload types.BundleUpload) (types.BundleUpload, error) {
+	upload.UpdatedAt = time.Now()
+
+	var message, file string
+	if upload.Error != nil {
+		message = upload.Error.Message
+		file = upload.Error.File
+	}
+
+	const query = `
+		UPDATE bundle_uploads
+		SET status = $1,
+			error = $2,
+			error_file = $3,
+			size = $4,
+			bundle_version = $5,
+			updated_at = $6
+		WHERE id = $7`
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		upload.Status,
+		message,
+		file,
+		upload.Size,
+		upload.BundleVersion,
+		upload.UpdatedAt,
+		upload.ID,
+	)
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return types.BundleUpload{}, err
+	}
+	if affected == 0 {
+		return types.BundleUpload{}, ErrNotFound
+	}
+	return upload, nil
+}
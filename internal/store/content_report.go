@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ContentReportRepository handles persistence for abuse reports and the
+// bans moderators impose.
+type ContentReportRepository struct {
+	db *sql.DB
+}
+
+func NewContentReportRepository(db *sql.DB) *ContentReportRepository {
+	return &ContentReportRepository{db: db}
+}
+
+const contentReportColumns = `
+	id, reporter_id, target_type, target_id, reason, status, action,
+	resolver_id, resolution, created_at, resolved_at`
+
+// Create stores an open report. When the reporter already has an open
+// report on the same content, that report is returned instead.
+func (r *ContentReportRepository) Create(ctx context.Context, report types.ContentReport) (types.ContentReport, error) {
+	const query = `
+		INSERT INTO content_reports (reporter_id, target_type, target_id, reason, status, created_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (reporter_id, target_type, target_id) WHERE status = 'open' DO NOTHING
+		RETURNING` + contentReportColumns
+	created, err := scanContentReport(r.db.QueryRowContext(
+		ctx,
+		query,
+		report.ReporterID,
+		report.TargetType,
+		report.TargetID,
+		report.Reason,
+		types.ReportOpen,
+		time.Now(),
+		tenantForWrite(ctx),
+	))
+	if err == nil {
+		return created, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return types.ContentReport{}, err
+	}
+
+	const existing = `
+		SELECT` + contentReportColumns + `
+		FROM content_reports
+		WHERE reporter_id = $1 AND target_type = $2 AND target_id = $3 AND status = $4
+			AND ($5 = 0 OR tenant_id = $5)`
+	return scanContentReport(r.db.QueryRowContext(ctx, existing, report.ReporterID, report.TargetType, report.TargetID, types.ReportOpen, tenantScope(ctx)))
+}
+
+func (r *ContentReportRepository) Get(ctx context.Context, id int64) (types.ContentReport, error) {
+	const query = `
+		SELECT` + contentReportColumns + `
+		FROM content_reports
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	report, err := scanContentReport(r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ContentReport{}, ErrNotFound
+		}
+		return types.ContentReport{}, err
+	}
+	return report, nil
+}
+
+// List returns a page of reports, oldest first, and the total number of
+// matches. An empty status matches every report.
+func (r *ContentReportRepository) List(ctx context.Context, status types.ReportStatus, offset, limit int) ([]types.ContentReport, int, error) {
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM content_reports
+		WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR tenant_id = $2)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, status, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + contentReportColumns + `
+		FROM content_reports
+		WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR tenant_id = $2)
+		ORDER BY id
+		OFFSET $3 LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, listQuery, status, tenantScope(ctx), offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reports := make([]types.ContentReport, 0, limit)
+	for rows.Next() {
+		report, err := scanContentReport(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+// Resolve closes an open report with the moderator's decision. It returns
+// ErrNotFound when the report does not exist or is already closed.
+func (r *ContentReportRepository) Resolve(ctx context.Context, report types.ContentReport) (types.ContentReport, error) {
+	const query = `
+		UPDATE content_reports
+		SET status = $1,
+			action = $2,
+			resolver_id = $3,
+			resolution = $4,
+			resolved_at = $5
+		WHERE id = $6 AND status = $7 AND ($8 = 0 OR tenant_id = $8)
+		RETURNING` + contentReportColumns
+	resolved, err := scanContentReport(r.db.QueryRowContext(
+		ctx,
+		query,
+		report.Status,
+		report.Action,
+		report.ResolverID,
+		report.Resolution,
+		time.Now(),
+		report.ID,
+		types.ReportOpen,
+		tenantScope(ctx),
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ContentReport{}, ErrNotFound
+		}
+		return types.ContentReport{}, err
+	}
+	return resolved, nil
+}
+
+// Ban bars a user from signing in, replacing any earlier ban.
+func (r *ContentReportRepository) Ban(ctx context.Context, ban types.UserBan) (types.UserBan, error) {
+	ban.CreatedAt = time.Now()
+	const query = `
+		INSERT INTO user_bans (user_id, reason, banned_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET reason = EXCLUDED.reason,
+			banned_by = EXCLUDED.banned_by,
+			created_at = EXCLUDED.created_at`
+	if _, err := r.db.ExecContext(ctx, query, ban.UserID, ban.Reason, ban.BannedBy, ban.CreatedAt); err != nil {
+		return types.UserBan{}, err
+	}
+	return ban, nil
+}
+
+// Unban lifts a user's ban. It returns ErrNotFound when the user is not
+// banned.
+func (r *ContentReportRepository) Unban(ctx context.Context, userID int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_bans WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// IsBanned reports whether the user is banned.
+func (r *ContentReportRepository) IsBanned(ctx context.Context, userID int) (bool, error) {
+	var banned bool
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_bans WHERE user_id = $1)`, userID).Scan(&banned)
+	return banned, err
+}
+
+func scanContentReport(row rowScanner) (types.ContentReport, error) {
+	var report types.ContentReport
+	var resolverID sql.NullInt64
+	var resolvedAt sql.NullTime
+	if err := row.Scan(
+		&report.ID,
+		&report.ReporterID,
+		&report.TargetType,
+		&report.TargetID,
+		&report.Reason,
+		&report.Status,
+		&report.Action,
+		&resolverID,
+		&report.Resolution,
+		&report.CreatedAt,
+		&resolvedAt,
+	); err != nil {
+		return types.ContentReport{}, err
+	}
+	report.ResolverID = int(resolverID.Int64)
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	return report, nil
+}
@@ -4,3 +4,10 @@ import "errors"
 
 // ErrNotFound is returned when a record does not exist.
 var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a write would violate a uniqueness rule.
+var ErrConflict = errors.New("conflict")
+
+// ErrCancelled is returned when a judging outcome is stored for a
+// submission its submitter has cancelled.
+var ErrCancelled = errors.New("submission was cancelled")
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// EventRepository handles persistence for the domain event log.
+type EventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Append adds an event to the end of the log.
+func (r *EventRepository) Append(ctx context.Context, event types.Event) (types.Event, error) {
+	event.CreatedAt = time.Now()
+	event.TenantID = tenantForWrite(ctx)
+	if len(event.Payload) == 0 {
+		event.Payload = []byte("{}")
+	}
+
+	const query = `
+		INSERT INTO events (type, subject_id, actor_id, payload, created_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		event.Type,
+		event.SubjectID,
+		event.ActorID,
+		[]byte(event.Payload),
+		event.CreatedAt,
+		event.TenantID,
+	).Scan(&event.ID); err != nil {
+		return types.Event{}, err
+	}
+	return event, nil
+}
+
+// ListAfter returns up to limit events with an ID greater than afterID,
+// oldest first, optionally restricted to a single event type.
+func (r *EventRepository) ListAfter(ctx context.Context, afterID int64, eventType string, limit int) ([]types.Event, error) {
+	if limit < 1 {
+		limit = 100
+	}
+
+	const query = `
+		SELECT id, type, subject_id, actor_id, tenant_id, payload, created_at
+		FROM events
+		WHERE id > $1
+			AND ($2 = '' OR type = $2)
+			AND ($4 = 0 OR tenant_id = $4)
+		ORDER BY id
+		LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, query, afterID, eventType, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]types.Event, 0, limit)
+	for rows.Next() {
+		var event types.Event
+		var payload []byte
+		if err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.SubjectID,
+			&event.ActorID,
+			&event.TenantID,
+			&payload,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,17 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ExportFilter narrows bulk exports to rows created in [From, To). A zero
+// bound leaves that side open.
+type ExportFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+func nullableTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,228 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// JobRepository handles persistence for background jobs and the schedules
+// of recurring ones.
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+const jobColumns = `
+	id, kind, payload, status, attempts, max_attempts, result, error,
+	run_at, started_at, finished_at, created_at`
+
+// Enqueue stores a new pending job.
+func (r *JobRepository) Enqueue(ctx context.Context, job types.Job) (types.Job, error) {
+	job.CreatedAt = time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = job.CreatedAt
+	}
+	job.Status = types.JobPending
+	if len(job.Payload) == 0 {
+		job.Payload = []byte("{}")
+	}
+
+	const query = `
+		INSERT INTO jobs (kind, payload, status, max_attempts, run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		job.Kind,
+		[]byte(job.Payload),
+		job.Status,
+		job.MaxAttempts,
+		job.RunAt,
+		job.CreatedAt,
+	).Scan(&job.ID); err != nil {
+		return types.Job{}, err
+	}
+	return job, nil
+}
+
+// Claim starts the next due job of one of the given kinds and leases it
+// until lease from now. Jobs left running past their lease, by a server
+// that died, are due again. It returns ErrNotFound when no job is due.
+func (r *JobRepository) Claim(ctx context.Context, kinds []string, lease time.Duration) (types.Job, error) {
+	now := time.Now()
+	const query = `
+		UPDATE jobs
+		SET status = $1,
+			attempts = attempts + 1,
+			started_at = $2,
+			locked_until = $3
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE kind = ANY($4)
+			  AND run_at <= $2
+			  AND (status = $5 OR (status = $1 AND locked_until <= $2))
+			ORDER BY run_at, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING` + jobColumns
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, types.JobRunning, now, now.Add(lease), kinds, types.JobPending))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Job{}, ErrNotFound
+		}
+		return types.Job{}, err
+	}
+	return job, nil
+}
+
+// Finish stores the outcome of an attempt: the job's status, result and
+// error, and for a job to be retried its next run time.
+func (r *JobRepository) Finish(ctx context.Context, job types.Job) error {
+	const query = `
+		UPDATE jobs
+		SET status = $1,
+			result = $2,
+			error = $3,
+			run_at = $4,
+			finished_at = $5,
+			locked_until = NULL
+		WHERE id = $6`
+	var result []byte
+	if len(job.Result) > 0 {
+		result = job.Result
+	}
+	_, err := r.db.ExecContext(ctx, query, job.Status, result, job.Error, job.RunAt, job.FinishedAt, job.ID)
+	return err
+}
+
+// List returns jobs newest first, optionally only those with the given
+// status.
+func (r *JobRepository) List(ctx context.Context, status types.JobStatus, offset, limit int) ([]types.Job, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM jobs
+		WHERE $1 = '' OR status = $1`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + jobColumns + `
+		FROM jobs
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		OFFSET $2 LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, listQuery, status, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs := make([]types.Job, 0, limit)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return jobs, total, nil
+}
+
+// DeleteFinishedBefore removes succeeded and failed jobs that finished
+// before cutoff, returning how many were removed.
+func (r *JobRepository) DeleteFinishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const query = `
+		DELETE FROM jobs
+		WHERE status IN ($1, $2) AND finished_at < $3`
+	result, err := r.db.ExecContext(ctx, query, types.JobSucceeded, types.JobFailed, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ClaimSchedule reports whether a run of the scheduled task kind is due,
+// and if so moves its next run interval ahead, so that only one caller
+// wins each run. A task seen for the first time is first due after one
+// interval.
+func (r *JobRepository) ClaimSchedule(ctx context.Context, kind string, interval time.Duration) (bool, error) {
+	now := time.Now()
+	const insertQuery = `
+		INSERT INTO job_schedules (kind, next_run_at)
+		VALUES ($1, $2)
+		ON CONFLICT (kind) DO NOTHING`
+	if _, err := r.db.ExecContext(ctx, insertQuery, kind, now.Add(interval)); err != nil {
+		return false, err
+	}
+
+	const claimQuery = `
+		UPDATE job_schedules
+		SET next_run_at = $1
+		WHERE kind = $2 AND next_run_at <= $3`
+	result, err := r.db.ExecContext(ctx, claimQuery, now.Add(interval), kind, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanJob(row rowScanner) (types.Job, error) {
+	var (
+		job        types.Job
+		payload    []byte
+		result     []byte
+		startedAt  sql.NullTime
+		finishedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&result,
+		&job.Error,
+		&job.RunAt,
+		&startedAt,
+		&finishedAt,
+		&job.CreatedAt,
+	); err != nil {
+		return types.Job{}, err
+	}
+	job.Payload = payload
+	job.Result = result
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
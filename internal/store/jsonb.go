@@ -0,0 +1,37 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonColumn scans a json or jsonb column into the value dst points to,
+// leaving it unchanged when the column is NULL. A value that does not
+// decode fails the Scan, which database/sql reports with the column's
+// name, instead of being dropped.
+func jsonColumn(dst any) *jsonScanner {
+	return &jsonScanner{dst: dst}
+}
+
+type jsonScanner struct {
+	dst any
+}
+
+// Scan implements sql.Scanner.
+func (s *jsonScanner) Scan(src any) error {
+	var data []byte
+	switch value := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("cannot decode %T as JSON", src)
+	}
+	if err := json.Unmarshal(data, s.dst); err != nil {
+		return fmt.Errorf("decode JSON: %w", err)
+	}
+	return nil
+}
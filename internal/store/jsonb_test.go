@@ -0,0 +1,34 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJSONColumn(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    []string
+		wantErr bool
+	}{
+		{name: "bytes", src: []byte(`["dp","graphs"]`), want: []string{"dp", "graphs"}},
+		{name: "string", src: `["math"]`, want: []string{"math"}},
+		{name: "null keeps value", src: nil, want: []string{"kept"}},
+		{name: "corrupt", src: []byte(`["dp",`), want: []string{"kept"}, wantErr: true},
+		{name: "wrong shape", src: []byte(`{"tag":"dp"}`), want: []string{"kept"}, wantErr: true},
+		{name: "unsupported type", src: int64(1), want: []string{"kept"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := []string{"kept"}
+			err := jsonColumn(&tags).Scan(tt.src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(tags, tt.want) {
+				t.Fatalf("tags = %q, want %q", tags, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// JudgeWorkerRepository handles persistence for registered judge workers.
+type JudgeWorkerRepository struct {
+	db *sql.DB
+}
+
+func NewJudgeWorkerRepository(db *sql.DB) *JudgeWorkerRepository {
+	return &JudgeWorkerRepository{db: db}
+}
+
+// Upsert registers a worker or refreshes an existing registration with its
+// current capabilities and heartbeat.
+func (r *JudgeWorkerRepository) Upsert(ctx context.Context, worker types.JudgeWorker) (types.JudgeWorker, error) {
+	languagesJSON, err := json.Marshal(worker.Languages)
+	if err != nil {
+		return types.JudgeWorker{}, err
+	}
+
+	const query = `
+		INSERT INTO judge_workers (id, pool, languages, arch, registered_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			pool = EXCLUDED.pool,
+			languages = EXCLUDED.languages,
+			arch = EXCLUDED.arch,
+			last_seen_at = EXCLUDED.last_seen_at
+		RETURNING registered_at, last_seen_at`
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		worker.ID,
+		worker.Pool,
+		languagesJSON,
+		worker.Arch,
+		worker.LastSeenAt,
+	).Scan(&worker.RegisteredAt, &worker.LastSeenAt)
+	if err != nil {
+		return types.JudgeWorker{}, err
+	}
+	return worker, nil
+}
+
+// ListActive returns the workers that sent a heartbeat after since.
+func (r *JudgeWorkerRepository) ListActive(ctx context.Context, since time.Time) ([]types.JudgeWorker, error) {
+	const query = `
+		SELECT id, pool, languages, arch, registered_at, last_seen_at
+		FROM judge_workers
+		WHERE last_seen_at > $1
+		ORDER BY pool, id`
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workers []types.JudgeWorker
+	for rows.Next() {
+		var worker types.JudgeWorker
+		if err := rows.Scan(
+			&worker.ID,
+			&worker.Pool,
+			jsonColumn(&worker.Languages),
+			&worker.Arch,
+			&worker.RegisteredAt,
+			&worker.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		workers = append(workers, worker)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return workers, nil
+}
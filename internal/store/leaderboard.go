@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// Leaderboard sort orders.
+const (
+	LeaderboardSortSolved = "solved"
+	LeaderboardSortScore  = "score"
+)
+
+// LeaderboardRepository reads the leaderboard_entries materialized view.
+type LeaderboardRepository struct {
+	db      *sql.DB
+	replica *Replica
+}
+
+func NewLeaderboardRepository(db *sql.DB) *LeaderboardRepository {
+	return &LeaderboardRepository{db: db}
+}
+
+// WithReplica serves List from replica for contexts marked with
+// WithReplicaReads.
+func (r *LeaderboardRepository) WithReplica(replica *Replica) *LeaderboardRepository {
+	r.replica = replica
+	return r
+}
+
+// List returns one page of ranked entries for a period. Ranks are computed
+// over the whole period, among the users of the context's tenant, before
+// paging.
+func (r *LeaderboardRepository) List(ctx context.Context, period, sortBy string, offset, limit int) ([]types.LeaderboardEntry, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	var order string
+	switch sortBy {
+	case LeaderboardSortScore:
+		order = "le.score DESC, le.solved DESC"
+	case LeaderboardSortSolved, "":
+		order = "le.solved DESC, le.score DESC"
+	default:
+		return nil, 0, fmt.Errorf("unsupported leaderboard sort: %s", sortBy)
+	}
+
+	var (
+		entries []types.LeaderboardEntry
+		total   int
+	)
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		var err error
+		entries, total, err = listLeaderboard(ctx, db, period, order, offset, limit)
+		return err
+	})
+	return entries, total, err
+}
+
+func listLeaderboard(ctx context.Context, db *sql.DB, period, order string, offset, limit int) ([]types.LeaderboardEntry, int, error) {
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM leaderboard_entries le
+		JOIN users u ON u.id = le.user_id
+		WHERE le.period = $1 AND ($2 = 0 OR u.tenant_id = $2)`
+	var total int
+	if err := db.QueryRowContext(ctx, countQuery, period, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	listQuery := `
+		SELECT RANK() OVER (ORDER BY ` + order + `) AS rank,
+			le.user_id,
+			u.username,
+			u.name,
+			le.solved,
+			le.score
+		FROM leaderboard_entries le
+		JOIN users u ON u.id = le.user_id
+		WHERE le.period = $1 AND ($4 = 0 OR u.tenant_id = $4)
+		ORDER BY rank, le.user_id
+		OFFSET $2 LIMIT $3`
+	rows, err := db.QueryContext(ctx, listQuery, period, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := make([]types.LeaderboardEntry, 0, limit)
+	for rows.Next() {
+		var entry types.LeaderboardEntry
+		if err := rows.Scan(
+			&entry.Rank,
+			&entry.UserID,
+			&entry.Username,
+			&entry.Name,
+			&entry.Solved,
+			&entry.Score,
+		); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// Refresh recomputes the materialized view without blocking readers.
+func (r *LeaderboardRepository) Refresh(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_entries`)
+	return err
+}
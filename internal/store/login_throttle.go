@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LoginThrottleRepository handles persistence for failed login counters
+// and account lockouts.
+type LoginThrottleRepository struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// BlockedUntil returns the latest block among keys that is still in effect
+// at now, or the zero time if none is.
+func (r *LoginThrottleRepository) BlockedUntil(ctx context.Context, keys []string, now time.Time) (time.Time, error) {
+	const query = `
+		SELECT MAX(blocked_until)
+		FROM login_failures
+		WHERE key = ANY($1) AND blocked_until > $2`
+	var until sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, keys, now).Scan(&until); err != nil {
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+// RecordFailure counts a failed login for key and returns the number of
+// failures in the current streak. A streak restarts when the previous
+// failure happened before windowStart.
+func (r *LoginThrottleRepository) RecordFailure(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	const query = `
+		INSERT INTO login_failures (key, failures, last_failed_at, blocked_until)
+		VALUES ($1, 1, $2, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET failures = CASE
+				WHEN login_failures.last_failed_at < $3 THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING failures`
+	var failures int
+	if err := r.db.QueryRowContext(ctx, query, key, now, windowStart).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+// Block refuses logins for key until the given time.
+func (r *LoginThrottleRepository) Block(ctx context.Context, key string, until time.Time) error {
+	const query = `UPDATE login_failures SET blocked_until = $2 WHERE key = $1`
+	_, err := r.db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+// Reset clears the failure streak for key.
+func (r *LoginThrottleRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+	return err
+}
+
+// LockedUntil returns when the lockout on the named account lifts. It
+// returns ErrNotFound if the account is not locked at now.
+func (r *LoginThrottleRepository) LockedUntil(ctx context.Context, username string, now time.Time) (time.Time, error) {
+	const query = `
+		SELECT al.locked_until
+		FROM account_lockouts al
+		JOIN users u ON u.id = al.user_id
+		WHERE u.username = $1 AND al.locked_until > $2 AND u.tenant_id = $3`
+	var until time.Time
+	if err := r.db.QueryRowContext(ctx, query, username, now, tenantForWrite(ctx)).Scan(&until); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrNotFound
+		}
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// Lock locks a user's account until the given time, replacing any earlier
+// lockout and its unlock token.
+func (r *LoginThrottleRepository) Lock(ctx context.Context, userID int, until time.Time, tokenHash string) error {
+	const query = `
+		INSERT INTO account_lockouts (user_id, locked_until, unlock_token_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET locked_until = EXCLUDED.locked_until,
+			unlock_token_hash = EXCLUDED.unlock_token_hash,
+			created_at = EXCLUDED.created_at`
+	_, err := r.db.ExecContext(ctx, query, userID, until, tokenHash, time.Now())
+	return err
+}
+
+// Unlock removes the lockout whose unlock token hashes to tokenHash and
+// returns the username of the unlocked account. It returns ErrNotFound if
+// no lockout matches.
+func (r *LoginThrottleRepository) Unlock(ctx context.Context, tokenHash string) (string, error) {
+	const query = `
+		DELETE FROM account_lockouts al
+		USING users u
+		WHERE u.id = al.user_id AND al.unlock_token_hash = $1
+		RETURNING u.username`
+	var username string
+	if err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return username, nil
+}
@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// NotificationRepository handles persistence for user notifications.
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+const notificationColumns = `
+	id, user_id, type, title, body, subject_id, read_at, created_at`
+
+// Create stores a new unread notification.
+func (r *NotificationRepository) Create(ctx context.Context, notification types.Notification) (types.Notification, error) {
+	notification.CreatedAt = time.Now()
+	notification.ReadAt = nil
+
+	const query = `
+		INSERT INTO notifications (user_id, type, title, body, subject_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		notification.UserID,
+		notification.Type,
+		notification.Title,
+		notification.Body,
+		notification.SubjectID,
+		notification.CreatedAt,
+	).Scan(&notification.ID); err != nil {
+		return types.Notification{}, err
+	}
+	return notification, nil
+}
+
+func (r *NotificationRepository) Get(ctx context.Context, id int64) (types.Notification, error) {
+	const query = `
+		SELECT` + notificationColumns + `
+		FROM notifications
+		WHERE id = $1`
+	notification, err := scanNotification(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Notification{}, ErrNotFound
+		}
+		return types.Notification{}, err
+	}
+	return notification, nil
+}
+
+// ListByUser returns a user's notifications newest first, optionally only
+// the unread ones.
+func (r *NotificationRepository) ListByUser(ctx context.Context, userID int, unreadOnly bool, offset, limit int) ([]types.Notification, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, userID, unreadOnly).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + notificationColumns + `
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY id DESC
+		OFFSET $3 LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, listQuery, userID, unreadOnly, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := make([]types.Notification, 0, limit)
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+// CountUnread returns the number of a user's unread notifications.
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID int) (int, error) {
+	const query = `
+		SELECT COUNT(1)
+		FROM notifications
+		WHERE user_id = $1 AND read_at IS NULL`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkRead marks one of a user's notifications read, keeping the time it
+// was first read. It returns ErrNotFound when the user has no such
+// notification.
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID int, id int64) error {
+	const query = `
+		UPDATE notifications
+		SET read_at = COALESCE(read_at, $1)
+		WHERE id = $2 AND user_id = $3`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks all of a user's unread notifications read, returning
+// how many there were.
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID int) (int64, error) {
+	const query = `
+		UPDATE notifications
+		SET read_at = $1
+		WHERE user_id = $2 AND read_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteReadBefore removes notifications read before cutoff, returning how
+// many were removed.
+func (r *NotificationRepository) DeleteReadBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	const query = `
+		DELETE FROM notifications
+		WHERE read_at < $1`
+	result, err := r.db.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListByUserSince returns up to limit of a user's notifications of one
+// type created after since, oldest first.
+func (r *NotificationRepository) ListByUserSince(ctx context.Context, userID int, notificationType string, since time.Time, limit int) ([]types.Notification, error) {
+	const query = `
+		SELECT` + notificationColumns + `
+		FROM notifications
+		WHERE user_id = $1 AND type = $2 AND created_at > $3
+		ORDER BY id
+		LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, userID, notificationType, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []types.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+const notificationPreferencesColumns = `
+	user_id, email_notifications, verdict_digest, verdict_digest_sent_at`
+
+// GetPreferences returns a user's notification preferences, or the
+// defaults if they have not set any.
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID int) (types.NotificationPreferences, error) {
+	const query = `
+		SELECT` + notificationPreferencesColumns + `
+		FROM notification_preferences
+		WHERE user_id = $1`
+	prefs, err := scanNotificationPreferences(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.DefaultNotificationPreferences(userID), nil
+		}
+		return types.NotificationPreferences{}, err
+	}
+	return prefs, nil
+}
+
+// SetPreferences stores a user's notification preferences. When the
+// digest frequency changes, the next digest covers the time since the
+// change.
+func (r *NotificationRepository) SetPreferences(ctx context.Context, prefs types.NotificationPreferences) (types.NotificationPreferences, error) {
+	now := time.Now()
+	const query = `
+		INSERT INTO notification_preferences (user_id, email_notifications, verdict_digest, verdict_digest_sent_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email_notifications = EXCLUDED.email_notifications,
+			verdict_digest = EXCLUDED.verdict_digest,
+			verdict_digest_sent_at = CASE
+				WHEN notification_preferences.verdict_digest = EXCLUDED.verdict_digest
+				THEN notification_preferences.verdict_digest_sent_at
+				ELSE EXCLUDED.verdict_digest_sent_at
+			END,
+			updated_at = EXCLUDED.updated_at
+		RETURNING` + notificationPreferencesColumns
+	return scanNotificationPreferences(r.db.QueryRowContext(ctx, query, prefs.UserID, prefs.EmailNotifications, prefs.VerdictDigest, now))
+}
+
+// ListDigestsDue returns up to limit preferences, by user ID after
+// afterUserID, of users with the given digest frequency whose last digest
+// was due before dueBefore.
+func (r *NotificationRepository) ListDigestsDue(ctx context.Context, frequency string, dueBefore time.Time, afterUserID, limit int) ([]types.NotificationPreferences, error) {
+	const query = `
+		SELECT` + notificationPreferencesColumns + `
+		FROM notification_preferences
+		WHERE verdict_digest = $1 AND verdict_digest_sent_at <= $2 AND user_id > $3
+		ORDER BY user_id
+		LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, frequency, dueBefore, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []types.NotificationPreferences
+	for rows.Next() {
+		prefs, err := scanNotificationPreferences(rows)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, prefs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// MarkDigestSent records that a user's digest covering up to sentAt was
+// sent.
+func (r *NotificationRepository) MarkDigestSent(ctx context.Context, userID int, sentAt time.Time) error {
+	const query = `
+		UPDATE notification_preferences
+		SET verdict_digest_sent_at = $1
+		WHERE user_id = $2`
+	_, err := r.db.ExecContext(ctx, query, sentAt, userID)
+	return err
+}
+
+func scanNotification(row rowScanner) (types.Notification, error) {
+	var (
+		notification types.Notification
+		readAt       sql.NullTime
+	)
+	if err := row.Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.Type,
+		&notification.Title,
+		&notification.Body,
+		&notification.SubjectID,
+		&readAt,
+		&notification.CreatedAt,
+	); err != nil {
+		return types.Notification{}, err
+	}
+	if readAt.Valid {
+		notification.ReadAt = &readAt.Time
+	}
+	return notification, nil
+}
+
+func scanNotificationPreferences(row rowScanner) (types.NotificationPreferences, error) {
+	var (
+		prefs  types.NotificationPreferences
+		sentAt sql.NullTime
+	)
+	if err := row.Scan(
+		&prefs.UserID,
+		&prefs.EmailNotifications,
+		&prefs.VerdictDigest,
+		&sentAt,
+	); err != nil {
+		return types.NotificationPreferences{}, err
+	}
+	if sentAt.Valid {
+		prefs.VerdictDigestSentAt = &sentAt.Time
+	}
+	return prefs, nil
+}
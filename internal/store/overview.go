@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// OverviewRepository computes the counts shown on the admin dashboard.
+// Every count is served by an index or a small table, so the overview is
+// cheap enough to poll.
+type OverviewRepository struct {
+	db *sql.DB
+}
+
+func NewOverviewRepository(db *sql.DB) *OverviewRepository {
+	return &OverviewRepository{db: db}
+}
+
+// Counts fills in the overview's counts: submissions created since today,
+// system errors since windowStart and jobs failed since jobsSince. Jobs
+// are shared by every tenant, so their count is not scoped.
+func (r *OverviewRepository) Counts(ctx context.Context, today, windowStart, jobsSince time.Time) (types.Overview, error) {
+	const query = `
+		SELECT
+			(SELECT COUNT(1) FROM users WHERE $6 = 0 OR tenant_id = $6),
+			(SELECT COUNT(1) FROM problems WHERE $6 = 0 OR tenant_id = $6),
+			(SELECT COUNT(1) FROM problems WHERE published AND ($6 = 0 OR tenant_id = $6)),
+			(SELECT COUNT(1) FROM submissions WHERE created_at >= $1 AND ($6 = 0 OR tenant_id = $6)),
+			(SELECT COUNT(1) FROM submissions WHERE updated_at >= $2 AND verdict = $3 AND ($6 = 0 OR tenant_id = $6)),
+			(SELECT COUNT(1) FROM jobs WHERE status = $4 AND finished_at >= $5)`
+	var overview types.Overview
+	err := r.db.QueryRowContext(ctx, query, today, windowStart, types.VerdictSystemError, types.JobFailed, jobsSince, tenantScope(ctx)).Scan(
+		&overview.Users,
+		&overview.Problems,
+		&overview.PublishedProblems,
+		&overview.SubmissionsToday,
+		&overview.SystemErrors,
+		&overview.FailedJobs,
+	)
+	if err != nil {
+		return types.Overview{}, err
+	}
+	return overview, nil
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PasswordResetRepository handles persistence for password reset tokens.
+// Only hashes of the tokens are stored.
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create stores a reset token for a user, replacing their earlier ones.
+func (r *PasswordResetRepository) Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	const query = `
+		INSERT INTO password_resets (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, tokenHash, userID, expiresAt, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Consume deletes the token with the given hash and returns its user. It
+// returns ErrNotFound when there is no such token or it expired before
+// now.
+func (r *PasswordResetRepository) Consume(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	const query = `
+		DELETE FROM password_resets
+		WHERE token_hash = $1
+		RETURNING user_id, expires_at`
+	var (
+		userID    int
+		expiresAt time.Time
+	)
+	if err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID, &expiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	if !expiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	return userID, nil
+}
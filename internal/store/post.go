@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// PostRepository handles persistence for news posts.
+type PostRepository struct {
+	db *sql.DB
+}
+
+func NewPostRepository(db *sql.DB) *PostRepository {
+	return &PostRepository{db: db}
+}
+
+const postColumns = `
+	id, title, body, tags, pinned, author_id, published_at, created_at, updated_at`
+
+// PostFilter narrows a post listing.
+type PostFilter struct {
+	// Tag, when set, keeps only posts carrying it.
+	Tag string
+
+	// PublishedBy, when set, keeps only posts published at or before it.
+	// Otherwise drafts and scheduled posts are listed too.
+	PublishedBy *time.Time
+}
+
+// List returns posts matching filter, pinned ones first and newest first
+// otherwise. Drafts count as newer than any published post.
+func (r *PostRepository) List(ctx context.Context, filter PostFilter, offset, limit int) ([]types.Post, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const where = `
+		WHERE ($1 = '' OR tags @> jsonb_build_array($1::text))
+			AND ($2::timestamptz IS NULL OR published_at <= $2)
+			AND ($3 = 0 OR tenant_id = $3)`
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM posts` + where
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, filter.Tag, filter.PublishedBy, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + postColumns + `
+		FROM posts` + where + `
+		ORDER BY pinned DESC, published_at DESC NULLS FIRST, id DESC
+		OFFSET $4 LIMIT $5`
+	rows, err := r.db.QueryContext(ctx, listQuery, filter.Tag, filter.PublishedBy, tenantScope(ctx), offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	posts := make([]types.Post, 0, limit)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return posts, total, nil
+}
+
+func (r *PostRepository) Get(ctx context.Context, id int) (types.Post, error) {
+	const query = `
+		SELECT` + postColumns + `
+		FROM posts
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	post, err := scanPost(r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Post{}, ErrNotFound
+		}
+		return types.Post{}, err
+	}
+	return post, nil
+}
+
+func (r *PostRepository) Create(ctx context.Context, post types.Post) (types.Post, error) {
+	now := time.Now()
+	post.CreatedAt = now
+	post.UpdatedAt = now
+
+	tagsJSON, err := json.Marshal(post.Tags)
+	if err != nil {
+		return types.Post{}, err
+	}
+	const query = `
+		INSERT INTO posts (title, body, tags, pinned, author_id, published_at, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		post.Title,
+		post.Body,
+		tagsJSON,
+		post.Pinned,
+		nullableID(post.AuthorID),
+		post.PublishedAt,
+		post.CreatedAt,
+		post.UpdatedAt,
+		tenantForWrite(ctx),
+	).Scan(&post.ID); err != nil {
+		return types.Post{}, err
+	}
+	return post, nil
+}
+
+// Update replaces a post's content, keeping its author.
+func (r *PostRepository) Update(ctx context.Context, post types.Post) (types.Post, error) {
+	post.UpdatedAt = time.Now()
+
+	tagsJSON, err := json.Marshal(post.Tags)
+	if err != nil {
+		return types.Post{}, err
+	}
+	const query = `
+		UPDATE posts
+		SET title = $1,
+			body = $2,
+			tags = $3,
+			pinned = $4,
+			published_at = $5,
+			updated_at = $6
+		WHERE id = $7 AND ($8 = 0 OR tenant_id = $8)
+		RETURNING author_id, created_at`
+	var authorID sql.NullInt64
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		post.Title,
+		post.Body,
+		tagsJSON,
+		post.Pinned,
+		post.PublishedAt,
+		post.UpdatedAt,
+		post.ID,
+		tenantScope(ctx),
+	).Scan(&authorID, &post.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Post{}, ErrNotFound
+		}
+		return types.Post{}, err
+	}
+	post.AuthorID = int(authorID.Int64)
+	return post, nil
+}
+
+func (r *PostRepository) Delete(ctx context.Context, id int) error {
+	const query = `DELETE FROM posts WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanPost(row rowScanner) (types.Post, error) {
+	var (
+		post        types.Post
+		authorID    sql.NullInt64
+		publishedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Body,
+		jsonColumn(&post.Tags),
+		&post.Pinned,
+		&authorID,
+		&publishedAt,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	); err != nil {
+		return types.Post{}, err
+	}
+	post.AuthorID = int(authorID.Int64)
+	if publishedAt.Valid {
+		post.PublishedAt = &publishedAt.Time
+	}
+	return post, nil
+}
@@ -12,52 +12,168 @@ import (
 
 // ProblemRepository handles persistence for problems.
 type ProblemRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	replica *Replica
 }
 
 func NewProblemRepository(db *sql.DB) *ProblemRepository {
 	return &ProblemRepository{db: db}
 }
 
-func (r *ProblemRepository) List(ctx context.Context, offset, limit int) ([]types.Problem, int, error) {
-	if offset < 0 {
-		offset = 0
-	}
-	if limit < 1 {
-		limit = 20
-	}
-
-	const countQuery = `SELECT COUNT(1) FROM problems`
-	var total int
-	if err := r.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
-		return nil, 0, err
-	}
+// WithReplica serves List, ListSummaries, ListForExport and Get from
+// replica for contexts marked with WithReplicaReads.
+func (r *ProblemRepository) WithReplica(replica *Replica) *ProblemRepository {
+	r.replica = replica
+	return r
+}
 
-	const listQuery = `
+// problemSelect selects a problem together with its latest testcase bundle
+// version. Rows must be read with scanProblem so the column order stays in
+// sync.
+const problemSelect = `
 		SELECT p.id,
 			p.title,
 			p.description,
+			p.type,
 			p.difficulty,
 			p.time_limit,
 			p.memory_limit,
 			p.tags,
+			p.validation_status,
+			p.owner_id,
+			p.review_status,
+			p.published,
+			p.solution_visibility,
 			p.testcase_bundle,
 			p.created_at,
 			p.updated_at,
 			tb.object_key,
 			tb.sha256,
-			tb.version
+			tb.version,
+			tb.corrupted
 		FROM problems p
 		LEFT JOIN LATERAL (
-			SELECT object_key, sha256, version
+			SELECT object_key, sha256, version, corrupted
 			FROM testcase_bundles
 			WHERE problem_id = p.id
 			ORDER BY version DESC
 			LIMIT 1
 		) tb ON true
+`
+
+// scanProblem scans a row selected by problemSelect. Testcase groups come
+// from the denormalized testcase_bundle column; the object key, hash,
+// version and corruption flag of the latest testcase_bundles row take
+// precedence over it.
+func scanProblem(row rowScanner) (types.Problem, error) {
+	var problem types.Problem
+	var objectKey, sha256 sql.NullString
+	var version sql.NullInt64
+	var corrupted sql.NullBool
+	var ownerID sql.NullInt64
+	if err := row.Scan(
+		&problem.ID,
+		&problem.Title,
+		&problem.Description,
+		&problem.Type,
+		&problem.Difficulty,
+		&problem.TimeLimit,
+		&problem.MemoryLimit,
+		jsonColumn(&problem.Tags),
+		&problem.ValidationStatus,
+		&ownerID,
+		&problem.ReviewStatus,
+		&problem.Published,
+		&problem.SolutionVisibility,
+		jsonColumn(&problem.TestcaseBundle),
+		&problem.CreatedAt,
+		&problem.UpdatedAt,
+		&objectKey,
+		&sha256,
+		&version,
+		&corrupted,
+	); err != nil {
+		return types.Problem{}, err
+	}
+
+	problem.OwnerID = int(ownerID.Int64)
+	if objectKey.Valid && sha256.Valid && version.Valid {
+		problem.TestcaseBundle.ObjectKey = objectKey.String
+		problem.TestcaseBundle.SHA256 = sha256.String
+		problem.TestcaseBundle.Version = int(version.Int64)
+		problem.TestcaseBundle.Corrupted = corrupted.Bool
+	}
+	return problem, nil
+}
+
+// ListForExport returns up to limit problems matching filter with IDs
+// after afterID, in ID order, published or not. Callers page through all
+// of them by passing the last ID seen.
+func (r *ProblemRepository) ListForExport(ctx context.Context, filter ExportFilter, afterID, limit int) ([]types.Problem, error) {
+	const query = problemSelect + `
+		WHERE p.id > $1
+			AND ($2::TIMESTAMPTZ IS NULL OR p.created_at >= $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR p.created_at < $3)
+			AND ($5 = 0 OR p.tenant_id = $5)
+		ORDER BY p.id
+		LIMIT $4`
+	var problems []types.Problem
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, query, afterID, nullableTime(filter.From), nullableTime(filter.To), limit, tenantScope(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		problems = make([]types.Problem, 0, limit)
+		for rows.Next() {
+			problem, err := scanProblem(rows)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, problem)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// List returns a page of published problems.
+func (r *ProblemRepository) List(ctx context.Context, offset, limit int) ([]types.Problem, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	var (
+		problems []types.Problem
+		total    int
+	)
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		var err error
+		problems, total, err = listProblems(ctx, db, offset, limit)
+		return err
+	})
+	return problems, total, err
+}
+
+func listProblems(ctx context.Context, db *sql.DB, offset, limit int) ([]types.Problem, int, error) {
+	const countQuery = `SELECT COUNT(1) FROM problems WHERE published AND ($1 = 0 OR tenant_id = $1)`
+	var total int
+	if err := db.QueryRowContext(ctx, countQuery, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = problemSelect + `
+		WHERE p.published AND ($3 = 0 OR p.tenant_id = $3)
 		ORDER BY p.id
 		OFFSET $1 LIMIT $2`
-	rows, err := r.db.QueryContext(ctx, listQuery, offset, limit)
+	rows, err := db.QueryContext(ctx, listQuery, offset, limit, tenantScope(ctx))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -65,38 +181,10 @@ func (r *ProblemRepository) List(ctx context.Context, offset, limit int) ([]type
 
 	problems := make([]types.Problem, 0, limit)
 	for rows.Next() {
-		var problem types.Problem
-		var tagsJSON, bundleJSON []byte
-		var objectKey, sha256 sql.NullString
-		var version sql.NullInt64
-		if err := rows.Scan(
-			&problem.ID,
-			&problem.Title,
-			&problem.Description,
-			&problem.Difficulty,
-			&problem.TimeLimit,
-			&problem.MemoryLimit,
-			&tagsJSON,
-			&bundleJSON,
-			&problem.CreatedAt,
-			&problem.UpdatedAt,
-			&objectKey,
-			&sha256,
-			&version,
-		); err != nil {
+		problem, err := scanProblem(rows)
+		if err != nil {
 			return nil, 0, err
 		}
-
-		_ = json.Unmarshal(tagsJSON, &problem.Tags)
-		if objectKey.Valid && sha256.Valid && version.Valid {
-			problem.TestcaseBundle = types.TestcaseBundle{
-				ObjectKey: objectKey.String,
-				SHA256:    sha256.String,
-				Version:   int(version.Int64),
-			}
-		} else {
-			_ = json.Unmarshal(bundleJSON, &problem.TestcaseBundle)
-		}
 		problems = append(problems, problem)
 	}
 
@@ -107,67 +195,188 @@ func (r *ProblemRepository) List(ctx context.Context, offset, limit int) ([]type
 	return problems, total, nil
 }
 
-func (r *ProblemRepository) Get(ctx context.Context, id int) (types.Problem, error) {
-	const query = `
+// ListSummaries returns a page of published problems as summaries, with
+// descriptions only when withDescription is set.
+func (r *ProblemRepository) ListSummaries(ctx context.Context, offset, limit int, withDescription bool) ([]types.ProblemSummary, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	var (
+		summaries []types.ProblemSummary
+		total     int
+	)
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		var err error
+		summaries, total, err = listProblemSummaries(ctx, db, offset, limit, withDescription)
+		return err
+	})
+	return summaries, total, err
+}
+
+func listProblemSummaries(ctx context.Context, db *sql.DB, offset, limit int, withDescription bool) ([]types.ProblemSummary, int, error) {
+	const countQuery = `SELECT COUNT(1) FROM problems WHERE published AND ($1 = 0 OR tenant_id = $1)`
+	var total int
+	if err := db.QueryRowContext(ctx, countQuery, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
 		SELECT p.id,
 			p.title,
-			p.description,
+			p.type,
 			p.difficulty,
-			p.time_limit,
-			p.memory_limit,
 			p.tags,
-			p.testcase_bundle,
-			p.created_at,
-			p.updated_at,
-			tb.object_key,
-			tb.sha256,
-			tb.version
+			CASE WHEN $3 THEN p.description ELSE '' END,
+			pr.solved,
+			pr.attempted
 		FROM problems p
-		LEFT JOIN LATERAL (
-			SELECT object_key, sha256, version
-			FROM testcase_bundles
+		CROSS JOIN LATERAL (
+			SELECT COUNT(1) FILTER (WHERE first_accepted_at IS NOT NULL) AS solved,
+				COUNT(1) AS attempted
+			FROM problem_results
 			WHERE problem_id = p.id
-			ORDER BY version DESC
-			LIMIT 1
-		) tb ON true
-		WHERE p.id = $1`
+		) pr
+		WHERE p.published AND ($4 = 0 OR p.tenant_id = $4)
+		ORDER BY p.id
+		OFFSET $1 LIMIT $2`
+	rows, err := db.QueryContext(ctx, listQuery, offset, limit, withDescription, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries := make([]types.ProblemSummary, 0, limit)
+	for rows.Next() {
+		var summary types.ProblemSummary
+		if err := rows.Scan(
+			&summary.ID,
+			&summary.Title,
+			&summary.Type,
+			&summary.Difficulty,
+			jsonColumn(&summary.Tags),
+			&summary.Description,
+			&summary.SolvedCount,
+			&summary.AttemptedCount,
+		); err != nil {
+			return nil, 0, err
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return summaries, total, nil
+}
+
+func (r *ProblemRepository) Get(ctx context.Context, id int) (types.Problem, error) {
+	const query = problemSelect + `
+		WHERE p.id = $1 AND ($2 = 0 OR p.tenant_id = $2)`
 	var problem types.Problem
-	var tagsJSON, bundleJSON []byte
-	var objectKey, sha256 sql.NullString
-	var version sql.NullInt64
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&problem.ID,
-		&problem.Title,
-		&problem.Description,
-		&problem.Difficulty,
-		&problem.TimeLimit,
-		&problem.MemoryLimit,
-		&tagsJSON,
-		&bundleJSON,
-		&problem.CreatedAt,
-		&problem.UpdatedAt,
-		&objectKey,
-		&sha256,
-		&version,
-	)
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		var err error
+		problem, err = scanProblem(db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return types.Problem{}, ErrNotFound
 		}
 		return types.Problem{}, err
 	}
+	return problem, nil
+}
 
-	_ = json.Unmarshal(tagsJSON, &problem.Tags)
-	if objectKey.Valid && sha256.Valid && version.Valid {
-		problem.TestcaseBundle = types.TestcaseBundle{
-			ObjectKey: objectKey.String,
-			SHA256:    sha256.String,
-			Version:   int(version.Int64),
+// ListTagged returns the id, title, description and tags of the most
+// recently created tagged problems, at most limit of them. It is used as
+// the corpus for tag suggestions.
+func (r *ProblemRepository) ListTagged(ctx context.Context, limit int) ([]types.Problem, error) {
+	const query = `
+		SELECT id, title, description, tags
+		FROM problems
+		WHERE tags <> '[]'::jsonb AND ($1 = 0 OR tenant_id = $1)
+		ORDER BY id DESC
+		LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, tenantScope(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []types.Problem
+	for rows.Next() {
+		var problem types.Problem
+		if err := rows.Scan(&problem.ID, &problem.Title, &problem.Description, jsonColumn(&problem.Tags)); err != nil {
+			return nil, err
 		}
-	} else {
-		_ = json.Unmarshal(bundleJSON, &problem.TestcaseBundle)
+		problems = append(problems, problem)
 	}
-	return problem, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// CoSolvedProblem is a tagged problem solved by some of the same users
+// as another one.
+type CoSolvedProblem struct {
+	ProblemID int
+	Tags      []string
+
+	// Shared is how many of the sampled solvers of the other problem
+	// solved this one too.
+	Shared int
+
+	// Sampled is how many solvers of the other problem were sampled.
+	Sampled int
+}
+
+// ListCoSolved returns the tagged problems most often solved by the users
+// who solved problemID, at most limit of them, most shared solvers first.
+// Only the sample most recent solvers of problemID are considered, which
+// bounds the work for popular problems.
+func (r *ProblemRepository) ListCoSolved(ctx context.Context, problemID, sample, limit int) ([]CoSolvedProblem, error) {
+	const query = `
+		WITH solvers AS (
+			SELECT user_id
+			FROM problem_results
+			WHERE problem_id = $1 AND first_accepted_at IS NOT NULL
+			ORDER BY first_accepted_at DESC
+			LIMIT $3
+		), shared AS (
+			SELECT pr.problem_id, COUNT(1) AS shared
+			FROM problem_results pr
+			JOIN solvers s ON s.user_id = pr.user_id
+			WHERE pr.problem_id <> $1 AND pr.first_accepted_at IS NOT NULL
+			GROUP BY pr.problem_id
+		)
+		SELECT p.id, p.tags, shared.shared, (SELECT COUNT(1) FROM solvers)
+		FROM shared
+		JOIN problems p ON p.id = shared.problem_id
+		WHERE p.tags <> '[]'::jsonb AND ($2 = 0 OR p.tenant_id = $2)
+		ORDER BY shared.shared DESC, p.id
+		LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, problemID, tenantScope(ctx), sample, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []CoSolvedProblem
+	for rows.Next() {
+		var problem CoSolvedProblem
+		if err := rows.Scan(&problem.ProblemID, jsonColumn(&problem.Tags), &problem.Shared, &problem.Sampled); err != nil {
+			return nil, err
+		}
+		problems = append(problems, problem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return problems, nil
 }
 
 func (r *ProblemRepository) Create(ctx context.Context, problem types.Problem) (types.Problem, error) {
@@ -179,10 +388,14 @@ func (r *ProblemRepository) Create(ctx context.Context, problem types.Problem) (
 	if err != nil {
 		return types.Problem{}, err
 	}
+	bundleJSON, err := json.Marshal(problem.TestcaseBundle)
+	if err != nil {
+		return types.Problem{}, err
+	}
 
 	const query = `
-		INSERT INTO problems (title, description, difficulty, time_limit, memory_limit, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO problems (title, description, type, difficulty, time_limit, memory_limit, tags, testcase_bundle, owner_id, review_status, published, solution_visibility, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id`
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -199,12 +412,19 @@ func (r *ProblemRepository) Create(ctx context.Context, problem types.Problem) (
 		query,
 		problem.Title,
 		problem.Description,
+		problem.Type,
 		problem.Difficulty,
 		problem.TimeLimit,
 		problem.MemoryLimit,
 		tagsJSON,
+		bundleJSON,
+		sql.NullInt64{Int64: int64(problem.OwnerID), Valid: problem.OwnerID != 0},
+		problem.ReviewStatus,
+		problem.Published,
+		problem.SolutionVisibility,
 		problem.CreatedAt,
 		problem.UpdatedAt,
+		tenantForWrite(ctx),
 	).Scan(&problem.ID); err != nil {
 		return types.Problem{}, err
 	}
@@ -227,6 +447,10 @@ func (r *ProblemRepository) Create(ctx context.Context, problem types.Problem) (
 	return problem, nil
 }
 
+// Update replaces the editable fields of a problem and returns it. Changing
+// the statement, type or limits of an approved problem sends it back to
+// draft; it stays published if it was, but must pass review again to be
+// republished.
 func (r *ProblemRepository) Update(ctx context.Context, problem types.Problem) (types.Problem, error) {
 	problem.UpdatedAt = time.Now()
 
@@ -239,23 +463,32 @@ func (r *ProblemRepository) Update(ctx context.Context, problem types.Problem) (
 		UPDATE problems
 		SET title = $1,
 			description = $2,
-			difficulty = $3,
-			time_limit = $4,
-			memory_limit = $5,
-			tags = $6,
-			updated_at = $7
-		WHERE id = $8`
+			type = $3,
+			difficulty = $4,
+			time_limit = $5,
+			memory_limit = $6,
+			tags = $7,
+			review_status = CASE
+				WHEN review_status = $11
+					AND (title, description, type, time_limit, memory_limit) IS DISTINCT FROM ($1, $2, $3, $5, $6)
+				THEN $12 ELSE review_status END,
+			updated_at = $8
+		WHERE id = $9 AND ($10 = 0 OR tenant_id = $10)`
 	result, err := r.db.ExecContext(
 		ctx,
 		query,
 		problem.Title,
 		problem.Description,
+		problem.Type,
 		problem.Difficulty,
 		problem.TimeLimit,
 		problem.MemoryLimit,
 		tagsJSON,
 		problem.UpdatedAt,
 		problem.ID,
+		tenantScope(ctx),
+		types.ReviewApproved,
+		types.ReviewDraft,
 	)
 	if err != nil {
 		return types.Problem{}, err
@@ -268,12 +501,81 @@ func (r *ProblemRepository) Update(ctx context.Context, problem types.Problem) (
 		return types.Problem{}, ErrNotFound
 	}
 
-	return problem, nil
+	return r.Get(ctx, problem.ID)
+}
+
+// patchChangesReview is true for a patch, in the parameters of Patch's
+// query, that changes the statement or the limits a reviewer approved.
+const patchChangesReview = `(COALESCE($1, title), COALESCE($2, description), COALESCE($4, time_limit), COALESCE($5, memory_limit))
+			IS DISTINCT FROM (title, description, time_limit, memory_limit)`
+
+// Patch applies the non-nil fields of patch to a problem in a single
+// update, leaving the others unchanged, and returns the updated problem.
+// Like Update, it sends an approved problem back to draft when the
+// statement or limits change. Only approved problems can be published, and
+// not along with such a change; like a missing problem, any other matches
+// nothing, and ErrNotFound is returned with nothing changed.
+func (r *ProblemRepository) Patch(ctx context.Context, id int, patch types.ProblemPatch) (types.Problem, error) {
+	var tagsJSON []byte
+	if patch.Tags != nil {
+		tags := *patch.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		var err error
+		if tagsJSON, err = json.Marshal(tags); err != nil {
+			return types.Problem{}, err
+		}
+	}
+
+	const query = `
+		UPDATE problems
+		SET title = COALESCE($1, title),
+			description = COALESCE($2, description),
+			difficulty = COALESCE($3, difficulty),
+			time_limit = COALESCE($4, time_limit),
+			memory_limit = COALESCE($5, memory_limit),
+			tags = COALESCE($6::jsonb, tags),
+			solution_visibility = COALESCE($7, solution_visibility),
+			published = COALESCE($8, published),
+			review_status = CASE WHEN review_status = $12 AND ` + patchChangesReview + ` THEN $13 ELSE review_status END,
+			updated_at = $9
+		WHERE id = $10 AND ($11 = 0 OR tenant_id = $11)
+			AND ($8 IS NULL OR NOT $8 OR (review_status = $12 AND NOT ` + patchChangesReview + `))`
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		patch.Title,
+		patch.Description,
+		patch.Difficulty,
+		patch.TimeLimit,
+		patch.MemoryLimit,
+		tagsJSON,
+		patch.SolutionVisibility,
+		patch.Published,
+		time.Now(),
+		id,
+		tenantScope(ctx),
+		types.ReviewApproved,
+		types.ReviewDraft,
+	)
+	if err != nil {
+		return types.Problem{}, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return types.Problem{}, err
+	}
+	if affected == 0 {
+		return types.Problem{}, ErrNotFound
+	}
+
+	return r.Get(ctx, id)
 }
 
 func (r *ProblemRepository) Delete(ctx context.Context, id int) error {
-	const query = `DELETE FROM problems WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, id)
+	const query = `DELETE FROM problems WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, tenantScope(ctx))
 	if err != nil {
 		return err
 	}
@@ -289,16 +591,18 @@ func (r *ProblemRepository) Delete(ctx context.Context, id int) error {
 
 func (r *ProblemRepository) GetLatestTestcaseBundle(ctx context.Context, problemID int) (types.TestcaseBundle, error) {
 	const query = `
-		SELECT object_key, sha256, version
+		SELECT object_key, sha256, version, corrupted
 		FROM testcase_bundles
 		WHERE problem_id = $1
+			AND EXISTS (SELECT 1 FROM problems WHERE id = $1 AND ($2 = 0 OR tenant_id = $2))
 		ORDER BY version DESC
 		LIMIT 1`
 	var bundle types.TestcaseBundle
-	err := r.db.QueryRowContext(ctx, query, problemID).Scan(
+	err := r.db.QueryRowContext(ctx, query, problemID, tenantScope(ctx)).Scan(
 		&bundle.ObjectKey,
 		&bundle.SHA256,
 		&bundle.Version,
+		&bundle.Corrupted,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -309,6 +613,60 @@ func (r *ProblemRepository) GetLatestTestcaseBundle(ctx context.Context, problem
 	return bundle, nil
 }
 
+// SetValidationStatus records the outcome of validating a problem's
+// reference solutions.
+func (r *ProblemRepository) SetValidationStatus(ctx context.Context, problemID int, status types.ValidationStatus) error {
+	const query = `UPDATE problems SET validation_status = $1 WHERE id = $2 AND ($3 = 0 OR tenant_id = $3)`
+	result, err := r.db.ExecContext(ctx, query, status, problemID, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListObjectKeys returns the distinct object storage keys referenced by the
+// database: those of every testcase bundle version and submission source,
+// across tenants.
+func (r *ProblemRepository) ListObjectKeys(ctx context.Context) ([]string, error) {
+	const query = `
+		SELECT object_key
+		FROM testcase_bundles
+		WHERE object_key <> ''
+		UNION
+		SELECT code_key
+		FROM submissions
+		WHERE code_key IS NOT NULL
+		ORDER BY 1`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// AddTestcaseBundleVersion stores a new testcase bundle version and makes
+// it the problem's current one. New tests send an approved problem back to
+// draft, as Update does.
 func (r *ProblemRepository) AddTestcaseBundleVersion(ctx context.Context, problemID int, bundle types.TestcaseBundle) error {
 	bundleJSON, err := json.Marshal(bundle)
 	if err != nil {
@@ -338,10 +696,17 @@ func (r *ProblemRepository) AddTestcaseBundleVersion(ctx context.Context, proble
 
 	result, err := tx.ExecContext(
 		ctx,
-		`UPDATE problems SET testcase_bundle = $1, updated_at = $2 WHERE id = $3`,
+		`UPDATE problems
+		SET testcase_bundle = $1,
+			review_status = CASE WHEN review_status = $5 THEN $6 ELSE review_status END,
+			updated_at = $2
+		WHERE id = $3 AND ($4 = 0 OR tenant_id = $4)`,
 		bundleJSON,
 		time.Now(),
 		problemID,
+		tenantScope(ctx),
+		types.ReviewApproved,
+		types.ReviewDraft,
 	)
 	if err != nil {
 		return err
@@ -351,7 +716,8 @@ func (r *ProblemRepository) AddTestcaseBundleVersion(ctx context.Context, proble
 		return err
 	}
 	if affected == 0 {
-		return ErrNotFound
+		err = ErrNotFound
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemCommentRepository handles persistence for problem discussions.
+type ProblemCommentRepository struct {
+	db *sql.DB
+}
+
+func NewProblemCommentRepository(db *sql.DB) *ProblemCommentRepository {
+	return &ProblemCommentRepository{db: db}
+}
+
+const problemCommentColumns = `
+	c.id, c.problem_id, c.root_id, c.parent_id, c.user_id, u.username,
+	c.body, c.spoiler, c.hidden_at, c.deleted_at, c.edited_at, c.created_at`
+
+// Create stores a comment. A reply must answer a comment on the same
+// problem that was not deleted; ErrNotFound is returned otherwise.
+func (r *ProblemCommentRepository) Create(ctx context.Context, comment types.ProblemComment) (types.ProblemComment, error) {
+	comment.CreatedAt = time.Now()
+
+	const query = `
+		INSERT INTO problem_comments (problem_id, root_id, parent_id, user_id, body, spoiler, created_at)
+		SELECT $1, (SELECT COALESCE(root_id, id) FROM problem_comments WHERE id = $2), $2, $3, $4, $5, $6
+		WHERE ($2::BIGINT IS NULL OR EXISTS (
+			SELECT 1 FROM problem_comments WHERE id = $2 AND problem_id = $1 AND deleted_at IS NULL
+		)) AND EXISTS (
+			SELECT 1 FROM problems WHERE id = $1 AND ($7 = 0 OR tenant_id = $7)
+		)
+		RETURNING id, root_id`
+	var rootID sql.NullInt64
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		comment.ProblemID,
+		comment.ParentID,
+		comment.UserID,
+		comment.Body,
+		comment.Spoiler,
+		comment.CreatedAt,
+		tenantScope(ctx),
+	).Scan(&comment.ID, &rootID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ProblemComment{}, ErrNotFound
+		}
+		return types.ProblemComment{}, err
+	}
+	if rootID.Valid {
+		comment.RootID = &rootID.Int64
+	}
+	return comment, nil
+}
+
+func (r *ProblemCommentRepository) Get(ctx context.Context, id int64) (types.ProblemComment, error) {
+	const query = `
+		SELECT` + problemCommentColumns + `
+		FROM problem_comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND ($2 = 0 OR u.tenant_id = $2)`
+	comment, err := scanProblemComment(r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ProblemComment{}, ErrNotFound
+		}
+		return types.ProblemComment{}, err
+	}
+	return comment, nil
+}
+
+// ListThreads returns a page of a problem's threads, oldest first, each
+// with all of its replies.
+func (r *ProblemCommentRepository) ListThreads(ctx context.Context, problemID, offset, limit int) ([]types.ProblemComment, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM problem_comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.problem_id = $1 AND c.root_id IS NULL AND ($2 = 0 OR u.tenant_id = $2)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, problemID, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT` + problemCommentColumns + `
+		FROM problem_comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.problem_id = $1 AND c.root_id IS NULL AND ($4 = 0 OR u.tenant_id = $4)
+		ORDER BY c.id
+		OFFSET $2 LIMIT $3`
+	threads, err := r.list(ctx, listQuery, problemID, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(threads) == 0 {
+		return threads, total, nil
+	}
+
+	rootIDs := make([]int64, len(threads))
+	index := make(map[int64]int, len(threads))
+	for i, thread := range threads {
+		rootIDs[i] = thread.ID
+		index[thread.ID] = i
+	}
+	const repliesQuery = `
+		SELECT` + problemCommentColumns + `
+		FROM problem_comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.root_id = ANY($1) AND ($2 = 0 OR u.tenant_id = $2)
+		ORDER BY c.id`
+	replies, err := r.list(ctx, repliesQuery, rootIDs, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, reply := range replies {
+		i := index[*reply.RootID]
+		threads[i].Replies = append(threads[i].Replies, reply)
+	}
+	return threads, total, nil
+}
+
+// Update replaces a comment's body and spoiler flag. It returns
+// ErrNotFound when there is no such comment or it was deleted.
+func (r *ProblemCommentRepository) Update(ctx context.Context, id int64, body string, spoiler bool) error {
+	const query = `
+		UPDATE problem_comments
+		SET body = $1, spoiler = $2, edited_at = $3
+		WHERE id = $4 AND deleted_at IS NULL
+			AND ($5 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $5))`
+	return r.exec(ctx, query, body, spoiler, time.Now(), id, tenantScope(ctx))
+}
+
+// SetHidden hides a comment on behalf of a moderator, or shows it again.
+func (r *ProblemCommentRepository) SetHidden(ctx context.Context, id int64, hidden bool, moderatorID int) error {
+	if !hidden {
+		const query = `
+			UPDATE problem_comments
+			SET hidden_at = NULL, hidden_by = NULL
+			WHERE id = $1
+				AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))`
+		return r.exec(ctx, query, id, tenantScope(ctx))
+	}
+	const query = `
+		UPDATE problem_comments
+		SET hidden_at = COALESCE(hidden_at, $1), hidden_by = COALESCE(hidden_by, $2)
+		WHERE id = $3
+			AND ($4 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $4))`
+	return r.exec(ctx, query, time.Now(), moderatorID, id, tenantScope(ctx))
+}
+
+// Delete erases a comment's body and marks it deleted. The comment keeps
+// its place in the thread so that replies to it still make sense.
+func (r *ProblemCommentRepository) Delete(ctx context.Context, id int64) error {
+	const query = `
+		UPDATE problem_comments
+		SET body = '', deleted_at = COALESCE(deleted_at, $1)
+		WHERE id = $2
+			AND ($3 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $3))`
+	return r.exec(ctx, query, time.Now(), id, tenantScope(ctx))
+}
+
+// HasSolved reports whether a user has an accepted submission to a
+// problem.
+func (r *ProblemCommentRepository) HasSolved(ctx context.Context, userID, problemID int) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1 FROM problem_results
+			WHERE user_id = $1 AND problem_id = $2 AND first_accepted_at IS NOT NULL
+		)`
+	var solved bool
+	if err := r.db.QueryRowContext(ctx, query, userID, problemID).Scan(&solved); err != nil {
+		return false, err
+	}
+	return solved, nil
+}
+
+func (r *ProblemCommentRepository) list(ctx context.Context, query string, args ...any) ([]types.ProblemComment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []types.ProblemComment{}
+	for rows.Next() {
+		comment, err := scanProblemComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *ProblemCommentRepository) exec(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanProblemComment(row rowScanner) (types.ProblemComment, error) {
+	var (
+		comment   types.ProblemComment
+		rootID    sql.NullInt64
+		parentID  sql.NullInt64
+		hiddenAt  sql.NullTime
+		deletedAt sql.NullTime
+		editedAt  sql.NullTime
+	)
+	if err := row.Scan(
+		&comment.ID,
+		&comment.ProblemID,
+		&rootID,
+		&parentID,
+		&comment.UserID,
+		&comment.Username,
+		&comment.Body,
+		&comment.Spoiler,
+		&hiddenAt,
+		&deletedAt,
+		&editedAt,
+		&comment.CreatedAt,
+	); err != nil {
+		return types.ProblemComment{}, err
+	}
+	if rootID.Valid {
+		comment.RootID = &rootID.Int64
+	}
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	comment.Hidden = hiddenAt.Valid
+	comment.Deleted = deletedAt.Valid
+	return comment, nil
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemListRepository handles persistence for problem lists.
+type ProblemListRepository struct {
+	db *sql.DB
+}
+
+func NewProblemListRepository(db *sql.DB) *ProblemListRepository {
+	return &ProblemListRepository{db: db}
+}
+
+// problemListSelect selects lists with their problem counts and how many
+// of those the user $1 solved. Unpublished problems only count when $2 is
+// true.
+const problemListSelect = `
+	SELECT l.id, l.title, l.description, l.owner_id, l.created_at, l.updated_at,
+		COUNT(i.problem_id), COUNT(pr.first_accepted_at)
+	FROM problem_lists l
+	LEFT JOIN (
+		problem_list_items i
+		JOIN problems p ON p.id = i.problem_id AND ($2 OR p.published)
+	) ON i.list_id = l.id
+	LEFT JOIN problem_results pr ON pr.problem_id = i.problem_id AND pr.user_id = $1`
+
+// List returns lists newest first, with the progress of userID, which is
+// zero for anonymous requests.
+func (r *ProblemListRepository) List(ctx context.Context, userID int, includeUnpublished bool, offset, limit int) ([]types.ProblemList, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	var total int
+	const countQuery = `SELECT COUNT(1) FROM problem_lists WHERE $1 = 0 OR tenant_id = $1`
+	if err := r.db.QueryRowContext(ctx, countQuery, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = problemListSelect + `
+		WHERE $5 = 0 OR l.tenant_id = $5
+		GROUP BY l.id
+		ORDER BY l.id DESC
+		OFFSET $3 LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, listQuery, userID, includeUnpublished, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lists := make([]types.ProblemList, 0, limit)
+	for rows.Next() {
+		list, err := scanProblemList(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return lists, total, nil
+}
+
+// Get returns a list with its items in order and the progress of userID.
+func (r *ProblemListRepository) Get(ctx context.Context, id, userID int, includeUnpublished bool) (types.ProblemList, error) {
+	const query = problemListSelect + `
+		WHERE l.id = $3 AND ($4 = 0 OR l.tenant_id = $4)
+		GROUP BY l.id`
+	list, err := scanProblemList(r.db.QueryRowContext(ctx, query, userID, includeUnpublished, id, tenantScope(ctx)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ProblemList{}, ErrNotFound
+		}
+		return types.ProblemList{}, err
+	}
+
+	const itemsQuery = `
+		SELECT i.position, p.id, p.title, p.difficulty,
+			pr.first_accepted_at IS NOT NULL, pr.user_id IS NOT NULL
+		FROM problem_list_items i
+		JOIN problems p ON p.id = i.problem_id
+		LEFT JOIN problem_results pr ON pr.problem_id = p.id AND pr.user_id = $1
+		WHERE i.list_id = $2 AND ($3 OR p.published)
+		ORDER BY i.position`
+	rows, err := r.db.QueryContext(ctx, itemsQuery, userID, id, includeUnpublished)
+	if err != nil {
+		return types.ProblemList{}, err
+	}
+	defer rows.Close()
+
+	list.Items = []types.ProblemListItem{}
+	for rows.Next() {
+		var item types.ProblemListItem
+		if err := rows.Scan(&item.Position, &item.ProblemID, &item.Title, &item.Difficulty, &item.Solved, &item.Attempted); err != nil {
+			return types.ProblemList{}, err
+		}
+		list.Items = append(list.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return types.ProblemList{}, err
+	}
+	return list, nil
+}
+
+// Create stores a list with the given problems in order. It returns
+// ErrNotFound if any of the problems does not exist.
+func (r *ProblemListRepository) Create(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error) {
+	now := time.Now()
+	list.CreatedAt = now
+	list.UpdatedAt = now
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.ProblemList{}, err
+	}
+	defer tx.Rollback()
+
+	const query = `
+		INSERT INTO problem_lists (title, description, owner_id, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	if err := tx.QueryRowContext(
+		ctx,
+		query,
+		list.Title,
+		list.Description,
+		nullableID(list.OwnerID),
+		list.CreatedAt,
+		list.UpdatedAt,
+		tenantForWrite(ctx),
+	).Scan(&list.ID); err != nil {
+		return types.ProblemList{}, err
+	}
+	if err := setProblemListItems(ctx, tx, list.ID, problemIDs); err != nil {
+		return types.ProblemList{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return types.ProblemList{}, err
+	}
+	list.ProblemCount = len(problemIDs)
+	return list, nil
+}
+
+// Update replaces a list's title, description and problems. It returns
+// ErrNotFound if the list or any of the problems does not exist.
+func (r *ProblemListRepository) Update(ctx context.Context, list types.ProblemList, problemIDs []int) (types.ProblemList, error) {
+	list.UpdatedAt = time.Now()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.ProblemList{}, err
+	}
+	defer tx.Rollback()
+
+	const query = `
+		UPDATE problem_lists
+		SET title = $1, description = $2, updated_at = $3
+		WHERE id = $4 AND ($5 = 0 OR tenant_id = $5)
+		RETURNING owner_id, created_at`
+	var ownerID sql.NullInt64
+	err = tx.QueryRowContext(ctx, query, list.Title, list.Description, list.UpdatedAt, list.ID, tenantScope(ctx)).Scan(&ownerID, &list.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ProblemList{}, ErrNotFound
+		}
+		return types.ProblemList{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM problem_list_items WHERE list_id = $1`, list.ID); err != nil {
+		return types.ProblemList{}, err
+	}
+	if err := setProblemListItems(ctx, tx, list.ID, problemIDs); err != nil {
+		return types.ProblemList{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return types.ProblemList{}, err
+	}
+	list.OwnerID = int(ownerID.Int64)
+	list.ProblemCount = len(problemIDs)
+	return list, nil
+}
+
+func (r *ProblemListRepository) Delete(ctx context.Context, id int) error {
+	const query = `DELETE FROM problem_lists WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// setProblemListItems adds the problems to an empty list in order, with
+// positions starting at 1. problemIDs must not repeat and must belong to
+// the list's tenant.
+func setProblemListItems(ctx context.Context, tx *sql.Tx, listID int, problemIDs []int) error {
+	if len(problemIDs) == 0 {
+		return nil
+	}
+	const query = `
+		INSERT INTO problem_list_items (list_id, problem_id, position)
+		SELECT $1, p.id, ids.position
+		FROM unnest($2::int[]) WITH ORDINALITY AS ids(problem_id, position)
+		JOIN problems p ON p.id = ids.problem_id
+			AND p.tenant_id = (SELECT tenant_id FROM problem_lists WHERE id = $1)`
+	result, err := tx.ExecContext(ctx, query, listID, problemIDs)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != int64(len(problemIDs)) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanProblemList(row rowScanner) (types.ProblemList, error) {
+	var (
+		list    types.ProblemList
+		ownerID sql.NullInt64
+	)
+	if err := row.Scan(
+		&list.ID,
+		&list.Title,
+		&list.Description,
+		&ownerID,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+		&list.ProblemCount,
+		&list.SolvedCount,
+	); err != nil {
+		return types.ProblemList{}, err
+	}
+	list.OwnerID = int(ownerID.Int64)
+	return list, nil
+}
@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemReviewRepository handles persistence for the problem review
+// workflow.
+type ProblemReviewRepository struct {
+	db *sql.DB
+}
+
+func NewProblemReviewRepository(db *sql.DB) *ProblemReviewRepository {
+	return &ProblemReviewRepository{db: db}
+}
+
+// Submit moves a draft problem, or one with changes requested, into review.
+// It returns ErrNotFound if the problem is in neither state.
+func (r *ProblemReviewRepository) Submit(ctx context.Context, problemID int) error {
+	const query = `
+		UPDATE problems
+		SET review_status = $1,
+			updated_at = $2
+		WHERE id = $3 AND review_status IN ($4, $5) AND ($6 = 0 OR tenant_id = $6)`
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		types.ReviewInReview,
+		time.Now(),
+		problemID,
+		types.ReviewDraft,
+		types.ReviewChangesRequested,
+		tenantScope(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Decide records a reviewer's decision and moves the problem out of review
+// to the decided status. It returns ErrNotFound if the problem is not in
+// review.
+func (r *ProblemReviewRepository) Decide(ctx context.Context, decision types.ReviewDecision) (types.ReviewDecision, error) {
+	decision.CreatedAt = time.Now()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.ReviewDecision{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var result sql.Result
+	result, err = tx.ExecContext(
+		ctx,
+		`UPDATE problems SET review_status = $1, updated_at = $2 WHERE id = $3 AND review_status = $4 AND ($5 = 0 OR tenant_id = $5)`,
+		decision.Decision,
+		decision.CreatedAt,
+		decision.ProblemID,
+		types.ReviewInReview,
+		tenantScope(ctx),
+	)
+	if err != nil {
+		return types.ReviewDecision{}, err
+	}
+	if err = expectAffected(result); err != nil {
+		return types.ReviewDecision{}, err
+	}
+
+	const insert = `
+		INSERT INTO problem_review_decisions (problem_id, reviewer_id, decision, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	if err = tx.QueryRowContext(
+		ctx,
+		insert,
+		decision.ProblemID,
+		decision.ReviewerID,
+		decision.Decision,
+		decision.Comment,
+		decision.CreatedAt,
+	).Scan(&decision.ID); err != nil {
+		return types.ReviewDecision{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return types.ReviewDecision{}, err
+	}
+	return decision, nil
+}
+
+// SetPublished publishes or unpublishes a problem. Only approved problems
+// can be published; ErrNotFound is returned otherwise.
+func (r *ProblemReviewRepository) SetPublished(ctx context.Context, problemID int, published bool) error {
+	const query = `
+		UPDATE problems
+		SET published = $1,
+			updated_at = $2
+		WHERE id = $3 AND (NOT $1 OR review_status = $4) AND ($5 = 0 OR tenant_id = $5)`
+	result, err := r.db.ExecContext(ctx, query, published, time.Now(), problemID, types.ReviewApproved, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// AddComment stores a review comment. A reply must answer a comment on the
+// same problem; ErrNotFound is returned otherwise.
+func (r *ProblemReviewRepository) AddComment(ctx context.Context, comment types.ReviewComment) (types.ReviewComment, error) {
+	comment.CreatedAt = time.Now()
+
+	const query = `
+		INSERT INTO problem_review_comments (problem_id, parent_id, user_id, target, body, created_at)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE ($2::BIGINT IS NULL OR EXISTS (
+			SELECT 1 FROM problem_review_comments WHERE id = $2 AND problem_id = $1
+		)) AND EXISTS (
+			SELECT 1 FROM problems WHERE id = $1 AND ($7 = 0 OR tenant_id = $7)
+		)
+		RETURNING id`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		comment.ProblemID,
+		comment.ParentID,
+		comment.UserID,
+		comment.Target,
+		comment.Body,
+		comment.CreatedAt,
+		tenantScope(ctx),
+	).Scan(&comment.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.ReviewComment{}, ErrNotFound
+		}
+		return types.ReviewComment{}, err
+	}
+	return comment, nil
+}
+
+// ListDecisions returns the decisions on a problem, oldest first.
+func (r *ProblemReviewRepository) ListDecisions(ctx context.Context, problemID int) ([]types.ReviewDecision, error) {
+	const query = `
+		SELECT id, problem_id, reviewer_id, decision, comment, created_at
+		FROM problem_review_decisions
+		WHERE problem_id = $1
+			AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))
+		ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, problemID, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []types.ReviewDecision{}
+	for rows.Next() {
+		var decision types.ReviewDecision
+		if err := rows.Scan(
+			&decision.ID,
+			&decision.ProblemID,
+			&decision.ReviewerID,
+			&decision.Decision,
+			&decision.Comment,
+			&decision.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// ListComments returns the review comments on a problem, oldest first.
+func (r *ProblemReviewRepository) ListComments(ctx context.Context, problemID int) ([]types.ReviewComment, error) {
+	const query = `
+		SELECT id, problem_id, parent_id, user_id, target, body, created_at
+		FROM problem_review_comments
+		WHERE problem_id = $1
+			AND ($2 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $2))
+		ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, problemID, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []types.ReviewComment{}
+	for rows.Next() {
+		var (
+			comment  types.ReviewComment
+			parentID sql.NullInt64
+		)
+		if err := rows.Scan(
+			&comment.ID,
+			&comment.ProblemID,
+			&parentID,
+			&comment.UserID,
+			&comment.Target,
+			&comment.Body,
+			&comment.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			comment.ParentID = &parentID.Int64
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// ProblemValidationRepository handles persistence for reference solution
+// results.
+type ProblemValidationRepository struct {
+	db *sql.DB
+}
+
+func NewProblemValidationRepository(db *sql.DB) *ProblemValidationRepository {
+	return &ProblemValidationRepository{db: db}
+}
+
+// Reset stores a pending result for each solution of a bundle version,
+// discarding any earlier results for it.
+func (r *ProblemValidationRepository) Reset(ctx context.Context, problemID, bundleVersion int, solutions []types.ReferenceSolution) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM problem_validations WHERE problem_id = $1 AND bundle_version = $2`,
+		problemID,
+		bundleVersion,
+	); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, solution := range solutions {
+		if _, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO problem_validations (problem_id, bundle_version, solution, language, expected, verdict, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			problemID,
+			bundleVersion,
+			solution.File,
+			solution.Language,
+			solution.Expected,
+			types.VerdictPending,
+			now,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateResult stores the verdict a worker reported for one solution.
+func (r *ProblemValidationRepository) UpdateResult(ctx context.Context, validation types.SolutionValidation) (types.SolutionValidation, error) {
+	validation.UpdatedAt = time.Now()
+
+	const query = `
+		UPDATE problem_validations
+		SET verdict = $1,
+			message = $2,
+			updated_at = $3
+		WHERE problem_id = $4 AND bundle_version = $5 AND solution = $6
+		RETURNING language, expected`
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		validation.Verdict,
+		validation.Message,
+		validation.UpdatedAt,
+		validation.ProblemID,
+		validation.BundleVersion,
+		validation.File,
+	).Scan(&validation.Language, &validation.Expected)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.SolutionValidation{}, ErrNotFound
+		}
+		return types.SolutionValidation{}, err
+	}
+	return validation, nil
+}
+
+// List returns the results for a bundle version ordered by solution.
+func (r *ProblemValidationRepository) List(ctx context.Context, problemID, bundleVersion int) ([]types.SolutionValidation, error) {
+	const query = `
+		SELECT problem_id, bundle_version, solution, language, expected, verdict, message, updated_at
+		FROM problem_validations
+		WHERE problem_id = $1 AND bundle_version = $2
+			AND ($3 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $3))
+		ORDER BY solution`
+	rows, err := r.db.QueryContext(ctx, query, problemID, bundleVersion, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var validations []types.SolutionValidation
+	for rows.Next() {
+		var v types.SolutionValidation
+		if err := rows.Scan(
+			&v.ProblemID,
+			&v.BundleVersion,
+			&v.File,
+			&v.Language,
+			&v.Expected,
+			&v.Verdict,
+			&v.Message,
+			&v.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		validations = append(validations, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return validations, nil
+}
+
+// ResetInputs stores a pending input validation for a bundle version,
+// discarding any earlier result for it.
+func (r *ProblemValidationRepository) ResetInputs(ctx context.Context, problemID, bundleVersion int, validator string) error {
+	const query = `
+		INSERT INTO input_validations (problem_id, bundle_version, validator, status, errors, updated_at)
+		VALUES ($1, $2, $3, $4, '[]'::jsonb, $5)
+		ON CONFLICT (problem_id, bundle_version) DO UPDATE SET
+			validator = EXCLUDED.validator,
+			status = EXCLUDED.status,
+			errors = EXCLUDED.errors,
+			updated_at = EXCLUDED.updated_at`
+	_, err := r.db.ExecContext(ctx, query, problemID, bundleVersion, validator, types.ValidationPending, time.Now())
+	return err
+}
+
+// UpdateInputs stores the input validation result reported by a worker.
+func (r *ProblemValidationRepository) UpdateInputs(ctx context.Context, validation types.InputValidation) (types.InputValidation, error) {
+	validation.UpdatedAt = time.Now()
+
+	errorsJSON, err := json.Marshal(validation.Errors)
+	if err != nil {
+		return types.InputValidation{}, err
+	}
+
+	const query = `
+		UPDATE input_validations
+		SET status = $1,
+			errors = $2,
+			updated_at = $3
+		WHERE problem_id = $4 AND bundle_version = $5
+		RETURNING validator`
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		validation.Status,
+		errorsJSON,
+		validation.UpdatedAt,
+		validation.ProblemID,
+		validation.BundleVersion,
+	).Scan(&validation.Validator)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.InputValidation{}, ErrNotFound
+		}
+		return types.InputValidation{}, err
+	}
+	return validation, nil
+}
+
+// GetInputs returns the input validation of a bundle version.
+func (r *ProblemValidationRepository) GetInputs(ctx context.Context, problemID, bundleVersion int) (types.InputValidation, error) {
+	const query = `
+		SELECT problem_id, bundle_version, validator, status, errors, updated_at
+		FROM input_validations
+		WHERE problem_id = $1 AND bundle_version = $2
+			AND ($3 = 0 OR problem_id IN (SELECT id FROM problems WHERE tenant_id = $3))`
+	var validation types.InputValidation
+	err := r.db.QueryRowContext(ctx, query, problemID, bundleVersion, tenantScope(ctx)).Scan(
+		&validation.ProblemID,
+		&validation.BundleVersion,
+		&validation.Validator,
+		&validation.Status,
+		jsonColumn(&validation.Errors),
+		&validation.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.InputValidation{}, ErrNotFound
+		}
+		return types.InputValidation{}, err
+	}
+	return validation, nil
+}
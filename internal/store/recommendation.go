@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// RecommendationRepository handles persistence for problem
+// recommendations.
+type RecommendationRepository struct {
+	db *sql.DB
+}
+
+func NewRecommendationRepository(db *sql.DB) *RecommendationRepository {
+	return &RecommendationRepository{db: db}
+}
+
+// RecommendationParams tune how recommendations are chosen.
+type RecommendationParams struct {
+	// History is how many of a user's most recent solves set their
+	// difficulty band.
+	History int
+
+	// Stretch is added to the average difficulty of those solves to get
+	// the target difficulty.
+	Stretch int
+
+	// Band is how far from the target a problem's difficulty may be.
+	Band int
+
+	// Count is how many problems are recommended to each user.
+	Count int
+}
+
+// ListSolverIDs returns up to limit IDs, after afterUserID in order, of
+// users who solved at least one problem.
+func (r *RecommendationRepository) ListSolverIDs(ctx context.Context, afterUserID, limit int) ([]int, error) {
+	const query = `
+		SELECT DISTINCT user_id
+		FROM problem_results
+		WHERE first_accepted_at IS NOT NULL AND user_id > $1
+		ORDER BY user_id
+		LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterUserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Refresh recomputes the recommendations of the given users. Each user's
+// target difficulty is the average difficulty of their latest solves plus
+// the stretch; the published problems of their tenant they have not
+// solved that are closest to it are recommended.
+func (r *RecommendationRepository) Refresh(ctx context.Context, userIDs []int, params RecommendationParams) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM problem_recommendations WHERE user_id = ANY($1)`, userIDs); err != nil {
+		return err
+	}
+
+	const query = `
+		WITH recent AS (
+			SELECT user_id, difficulty
+			FROM (
+				SELECT pr.user_id, p.difficulty,
+					ROW_NUMBER() OVER (PARTITION BY pr.user_id ORDER BY pr.first_accepted_at DESC) AS n
+				FROM problem_results pr
+				JOIN problems p ON p.id = pr.problem_id
+				WHERE pr.user_id = ANY($1) AND pr.first_accepted_at IS NOT NULL AND p.difficulty > 0
+			) solves
+			WHERE n <= $2
+		), bands AS (
+			SELECT user_id, ROUND(AVG(difficulty))::int + $3 AS target
+			FROM recent
+			GROUP BY user_id
+		), ranked AS (
+			SELECT b.user_id, b.target, p.id AS problem_id,
+				ROW_NUMBER() OVER (PARTITION BY b.user_id ORDER BY ABS(p.difficulty - b.target), p.id) AS rank
+			FROM bands b
+			JOIN users u ON u.id = b.user_id
+			JOIN problems p ON p.published AND p.tenant_id = u.tenant_id
+				AND p.difficulty BETWEEN b.target - $4 AND b.target + $4
+			WHERE NOT EXISTS (
+				SELECT 1 FROM problem_results pr
+				WHERE pr.user_id = b.user_id AND pr.problem_id = p.id AND pr.first_accepted_at IS NOT NULL
+			)
+		)
+		INSERT INTO problem_recommendations (user_id, problem_id, rank, target_difficulty, computed_at)
+		SELECT user_id, problem_id, rank, target, $5
+		FROM ranked
+		WHERE rank <= $6`
+	if _, err := tx.ExecContext(ctx, query, userIDs, params.History, params.Stretch, params.Band, time.Now(), params.Count); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListForUser returns a user's recommendations, best match first. Problems
+// solved or unpublished since they were computed are left out.
+func (r *RecommendationRepository) ListForUser(ctx context.Context, userID int) (types.Recommendations, error) {
+	const query = `
+		SELECT rec.rank, p.id, p.title, p.difficulty, p.tags, rec.target_difficulty, rec.computed_at
+		FROM problem_recommendations rec
+		JOIN problems p ON p.id = rec.problem_id
+		WHERE rec.user_id = $1 AND p.published AND ($2 = 0 OR p.tenant_id = $2) AND NOT EXISTS (
+			SELECT 1 FROM problem_results pr
+			WHERE pr.user_id = rec.user_id AND pr.problem_id = p.id AND pr.first_accepted_at IS NOT NULL
+		)
+		ORDER BY rec.rank`
+	rows, err := r.db.QueryContext(ctx, query, userID, tenantScope(ctx))
+	if err != nil {
+		return types.Recommendations{}, err
+	}
+	defer rows.Close()
+
+	recommendations := types.Recommendations{Items: []types.ProblemRecommendation{}}
+	for rows.Next() {
+		var (
+			item       types.ProblemRecommendation
+			computedAt time.Time
+		)
+		if err := rows.Scan(
+			&item.Rank,
+			&item.ProblemID,
+			&item.Title,
+			&item.Difficulty,
+			jsonColumn(&item.Tags),
+			&recommendations.TargetDifficulty,
+			&computedAt,
+		); err != nil {
+			return types.Recommendations{}, err
+		}
+		recommendations.ComputedAt = &computedAt
+		recommendations.Items = append(recommendations.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return types.Recommendations{}, err
+	}
+	return recommendations, nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+type replicaReadsKey struct{}
+
+// WithReplicaReads marks ctx as tolerating replication lag, so that
+// repositories with a Replica serve its reads from the replica. Only read
+// paths that do not act on what they read should be marked: a read that
+// follows a write may not see it yet.
+func WithReplicaReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadsKey{}, true)
+}
+
+func replicaReadsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(replicaReadsKey{}).(bool)
+	return allowed
+}
+
+// Replica is a read-only copy of the database. Reads marked with
+// WithReplicaReads go to it while it is up; when it is down, or a read
+// fails on it, they fall back to the primary.
+type Replica struct {
+	db   *sql.DB
+	down atomic.Bool
+}
+
+// NewReplica wraps a connection to a read replica. It is considered up
+// until a read or a health check fails.
+func NewReplica(db *sql.DB) *Replica {
+	return &Replica{db: db}
+}
+
+// Monitor pings the replica every interval until ctx is cancelled, taking
+// it out of rotation while pings fail and back once one succeeds.
+func (r *Replica) Monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			err := r.db.PingContext(pingCtx)
+			cancel()
+			if err != nil {
+				if ctx.Err() == nil {
+					r.markDown(err)
+				}
+				continue
+			}
+			if r.down.CompareAndSwap(true, false) {
+				log.Printf("read replica is back up")
+			}
+		}
+	}
+}
+
+func (r *Replica) markDown(err error) {
+	if r.down.CompareAndSwap(false, true) {
+		log.Printf("read replica is down, reading from the primary: %v", err)
+	}
+}
+
+// read runs fn against the replica when ctx allows it and the replica is
+// up, and otherwise against primary. If fn fails on the replica for any
+// reason but a missing row, the replica is marked down and fn is run
+// again against primary. A nil Replica always reads from primary.
+func (r *Replica) read(ctx context.Context, primary *sql.DB, fn func(db *sql.DB) error) error {
+	if r == nil || r.down.Load() || !replicaReadsAllowed(ctx) {
+		return fn(primary)
+	}
+	err := fn(r.db)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+		return err
+	}
+	r.markDown(err)
+	return fn(primary)
+}
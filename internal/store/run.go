@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// RunRepository handles persistence for custom runs.
+type RunRepository struct {
+	db *sql.DB
+}
+
+func NewRunRepository(db *sql.DB) *RunRepository {
+	return &RunRepository{db: db}
+}
+
+func (r *RunRepository) Get(ctx context.Context, id int64) (types.Run, error) {
+	const query = `
+		SELECT id, user_id, language, code, stdin, verdict, stdout, stderr,
+		       cpu_time, memory, created_at, updated_at
+		FROM runs
+		WHERE id = $1`
+	var run types.Run
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&run.ID,
+		&run.UserID,
+		&run.Language,
+		&run.Code,
+		&run.Stdin,
+		&run.Verdict,
+		&run.Stdout,
+		&run.Stderr,
+		&run.CPUTime,
+		&run.Memory,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Run{}, ErrNotFound
+		}
+		return types.Run{}, err
+	}
+	return run, nil
+}
+
+func (r *RunRepository) Create(ctx context.Context, run types.Run) (types.Run, error) {
+	now := time.Now()
+	run.CreatedAt = now
+	run.UpdatedAt = now
+
+	const query = `
+		INSERT INTO runs (user_id, language, code, stdin, verdict, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+	if err := r.db.QueryRowContext(
+		ctx,
+		query,
+		run.UserID,
+		run.Language,
+		run.Code,
+		run.Stdin,
+		run.Verdict,
+		run.CreatedAt,
+		run.UpdatedAt,
+	).Scan(&run.ID); err != nil {
+		return types.Run{}, err
+	}
+	return run, nil
+}
+
+// UpdateResult stores the outcome reported by a judge worker.
+func (r *RunRepository) UpdateResult(ctx context.Context, run types.Run) (types.Run, error) {
+	run.UpdatedAt = time.Now()
+
+	const query = `
+		UPDATE runs
+		SET verdict = $1,
+			stdout = $2,
+			stderr = $3,
+			cpu_time = $4,
+			memory = $5,
+			updated_at = $6
+		WHERE id = $7`
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		run.Verdict,
+		run.Stdout,
+		run.Stderr,
+		run.CPUTime,
+		run.Memory,
+		run.UpdatedAt,
+		run.ID,
+	)
+	if err != nil {
+		return types.Run{}, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return types.Run{}, err
+	}
+	if affected == 0 {
+		return types.Run{}, ErrNotFound
+	}
+	return run, nil
+}
+
+// CountUnfinished returns the number of a user's runs created after since
+// that are pending or still executing.
+func (r *RunRepository) CountUnfinished(ctx context.Context, userID int, since time.Time) (int, error) {
+	const query = `
+		SELECT COUNT(1)
+		FROM runs
+		WHERE user_id = $1 AND verdict IN ($2, $3) AND created_at > $4`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, userID, types.VerdictPending, types.VerdictJudging, since).Scan(&count)
+	return count, err
+}
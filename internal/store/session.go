@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// SessionRepository handles persistence for sessions.
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Get(ctx context.Context, id string) (types.Session, error) {
+	const query = `
+		SELECT id, user_id, created_at, expires_at, last_used_at, user_agent, ip
+		FROM sessions
+		WHERE id = $1`
+	var session types.Session
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&session.LastUsedAt,
+		&session.UserAgent,
+		&session.IP,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Session{}, ErrNotFound
+		}
+		return types.Session{}, err
+	}
+	return session, nil
+}
+
+// Create stores a new session. When maxPerUser is positive, the user's oldest
+// sessions beyond that limit are deleted in the same transaction, along with
+// any of the user's expired sessions.
+func (r *SessionRepository) Create(ctx context.Context, session types.Session, maxPerUser int) (types.Session, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.Session{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO sessions (id, user_id, created_at, expires_at, last_used_at, user_agent, ip) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.ID,
+		session.UserID,
+		session.CreatedAt,
+		session.ExpiresAt,
+		session.LastUsedAt,
+		session.UserAgent,
+		session.IP,
+	); err != nil {
+		return types.Session{}, err
+	}
+
+	if _, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM sessions WHERE user_id = $1 AND expires_at <= $2`,
+		session.UserID,
+		time.Now(),
+	); err != nil {
+		return types.Session{}, err
+	}
+
+	if maxPerUser > 0 {
+		const evictQuery = `
+			DELETE FROM sessions
+			WHERE user_id = $1
+				AND id NOT IN (
+					SELECT id
+					FROM sessions
+					WHERE user_id = $1
+					ORDER BY created_at DESC, id DESC
+					LIMIT $2
+				)`
+		if _, err = tx.ExecContext(ctx, evictQuery, session.UserID, maxPerUser); err != nil {
+			return types.Session{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return types.Session{}, err
+	}
+	return session, nil
+}
+
+// ListByUser returns the user's sessions that are still valid at now, most
+// recently used first.
+func (r *SessionRepository) ListByUser(ctx context.Context, userID int, now time.Time) ([]types.Session, error) {
+	const query = `
+		SELECT id, user_id, created_at, expires_at, last_used_at, user_agent, ip
+		FROM sessions
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY last_used_at DESC, id`
+	rows, err := r.db.QueryContext(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []types.Session{}
+	for rows.Next() {
+		var session types.Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.CreatedAt,
+			&session.ExpiresAt,
+			&session.LastUsedAt,
+			&session.UserAgent,
+			&session.IP,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Touch records that the session was used at the given time.
+func (r *SessionRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE sessions SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// DeleteForUser deletes a session only if it belongs to the user.
+func (r *SessionRepository) DeleteForUser(ctx context.Context, id string, userID int) error {
+	const query = `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+	result, err := r.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteByUser deletes all of a user's sessions.
+func (r *SessionRepository) DeleteByUser(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+func (r *SessionRepository) Delete(ctx context.Context, id string) error {
+	const query = `DELETE FROM sessions WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -12,39 +12,61 @@ import (
 
 // SubmissionRepository handles persistence for submissions.
 type SubmissionRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	replica *Replica
 }
 
 func NewSubmissionRepository(db *sql.DB) *SubmissionRepository {
 	return &SubmissionRepository{db: db}
 }
 
+// WithReplica serves Get, ListByProblem and ListForExport from replica for
+// contexts marked with WithReplicaReads.
+func (r *SubmissionRepository) WithReplica(replica *Replica) *SubmissionRepository {
+	r.replica = replica
+	return r
+}
+
 func (r *SubmissionRepository) Get(ctx context.Context, id int64) (types.Submission, error) {
 	const query = `
-		SELECT id, problem_id, user_id, code, language, verdict, score,
-		       cpu_time, memory, message, tests_passed, tests_total,
-		       created_at, updated_at, testcase_results
+		SELECT id, problem_id, user_id, tenant_id, code, code_key, code_preview,
+		       code_length, code_pruned_at, language, dialect, verdict, score,
+		       cpu_time, memory, message, compile_stdout, compile_stderr,
+		       tests_passed, tests_total, suspicion_score, created_at, updated_at,
+		       testcase_results
 		FROM submissions
-		WHERE id = $1`
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
 	var submission types.Submission
-	var resultsJSON []byte
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&submission.ID,
-		&submission.ProblemID,
-		&submission.UserID,
-		&submission.Code,
-		&submission.Language,
-		&submission.Verdict,
-		&submission.Score,
-		&submission.CPUTime,
-		&submission.Memory,
-		&submission.Message,
-		&submission.TestsPassed,
-		&submission.TestsTotal,
-		&submission.CreatedAt,
-		&submission.UpdatedAt,
-		&resultsJSON,
-	)
+	var codeKey sql.NullString
+	var prunedAt sql.NullTime
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, query, id, tenantScope(ctx)).Scan(
+			&submission.ID,
+			&submission.ProblemID,
+			&submission.UserID,
+			&submission.TenantID,
+			&submission.Code,
+			&codeKey,
+			&submission.CodePreview,
+			&submission.CodeLength,
+			&prunedAt,
+			&submission.Language,
+			&submission.Dialect,
+			&submission.Verdict,
+			&submission.Score,
+			&submission.CPUTime,
+			&submission.Memory,
+			&submission.Message,
+			&submission.CompileStdout,
+			&submission.CompileStderr,
+			&submission.TestsPassed,
+			&submission.TestsTotal,
+			&submission.SuspicionScore,
+			&submission.CreatedAt,
+			&submission.UpdatedAt,
+			jsonColumn(&submission.TestcaseResults),
+		)
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return types.Submission{}, ErrNotFound
@@ -52,10 +74,15 @@ func (r *SubmissionRepository) Get(ctx context.Context, id int64) (types.Submiss
 		return types.Submission{}, err
 	}
 
-	_ = json.Unmarshal(resultsJSON, &submission.TestcaseResults)
+	submission.CodeKey = codeKey.String
+	if prunedAt.Valid {
+		submission.CodePrunedAt = &prunedAt.Time
+	}
 	return submission, nil
 }
 
+// Create inserts a submission. When CodeKey is set the source lives in
+// object storage and is not copied into the row.
 func (r *SubmissionRepository) Create(ctx context.Context, submission types.Submission) (types.Submission, error) {
 	now := time.Now()
 	submission.CreatedAt = now
@@ -66,21 +93,31 @@ func (r *SubmissionRepository) Create(ctx context.Context, submission types.Subm
 		return types.Submission{}, err
 	}
 
+	code := submission.Code
+	if submission.CodeKey != "" {
+		code = ""
+	}
+
 	const query = `
 		INSERT INTO submissions (
-			problem_id, user_id, code, language, verdict, score,
+			problem_id, user_id, code, code_key, code_preview, code_length,
+			language, dialect, verdict, score,
 			cpu_time, memory, message, tests_passed, tests_total,
-			created_at, updated_at, testcase_results
+			created_at, updated_at, testcase_results, tenant_id
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 		RETURNING id`
 	if err := r.db.QueryRowContext(
 		ctx,
 		query,
 		submission.ProblemID,
 		submission.UserID,
-		submission.Code,
+		code,
+		sql.NullString{String: submission.CodeKey, Valid: submission.CodeKey != ""},
+		submission.CodePreview,
+		submission.CodeLength,
 		submission.Language,
+		submission.Dialect,
 		submission.Verdict,
 		submission.Score,
 		submission.CPUTime,
@@ -91,6 +128,7 @@ func (r *SubmissionRepository) Create(ctx context.Context, submission types.Subm
 		submission.CreatedAt,
 		submission.UpdatedAt,
 		resultsJSON,
+		tenantForWrite(ctx),
 	).Scan(&submission.ID); err != nil {
 		return types.Submission{}, err
 	}
@@ -98,7 +136,21 @@ func (r *SubmissionRepository) Create(ctx context.Context, submission types.Subm
 	return submission, nil
 }
 
+// Update stores a submission's judging outcome. The outcome it replaces,
+// if the submission had been judged, is kept in its verdict history.
 func (r *SubmissionRepository) Update(ctx context.Context, submission types.Submission) (types.Submission, error) {
+	return r.update(ctx, submission, false)
+}
+
+// UpdateJudged stores a judge's outcome like Update, unless the submission
+// was cancelled, in which case it returns ErrCancelled and leaves it as
+// is. The verdict is checked under the row lock the write holds, so a
+// cancellation cannot slip in between.
+func (r *SubmissionRepository) UpdateJudged(ctx context.Context, submission types.Submission) (types.Submission, error) {
+	return r.update(ctx, submission, true)
+}
+
+func (r *SubmissionRepository) update(ctx context.Context, submission types.Submission, keepCancelled bool) (types.Submission, error) {
 	submission.UpdatedAt = time.Now()
 
 	resultsJSON, err := json.Marshal(submission.TestcaseResults)
@@ -116,9 +168,58 @@ func (r *SubmissionRepository) Update(ctx context.Context, submission types.Subm
 			tests_passed = $6,
 			tests_total = $7,
 			updated_at = $8,
-			testcase_results = $9
-		WHERE id = $10`
-	result, err := r.db.ExecContext(
+			testcase_results = $9,
+			compile_stdout = $10,
+			compile_stderr = $11,
+			suspicion_score = $12
+		WHERE id = $13 AND ($14 = 0 OR tenant_id = $14)
+		RETURNING user_id, problem_id`
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.Submission{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Lock the row so that a cancellation waits for this write, or this
+	// write sees it.
+	const lock = `
+		SELECT verdict
+		FROM submissions
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)
+		FOR UPDATE`
+	var current types.Verdict
+	if err = tx.QueryRowContext(ctx, lock, submission.ID, tenantScope(ctx)).Scan(&current); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+		}
+		return types.Submission{}, err
+	}
+	if keepCancelled && current == types.VerdictCancelled {
+		err = ErrCancelled
+		return types.Submission{}, err
+	}
+
+	// Keep the outcome being replaced, unless the submission was never
+	// judged.
+	const archive = `
+		INSERT INTO submission_verdict_history (
+			submission_id, verdict, score, tests_passed, tests_total,
+			cpu_time, memory, message, judged_at, replaced_at
+		)
+		SELECT id, verdict, score, tests_passed, tests_total,
+			cpu_time, memory, message, updated_at, $2
+		FROM submissions
+		WHERE id = $1 AND verdict NOT IN ($3, $4)`
+	if _, err = tx.ExecContext(ctx, archive, submission.ID, submission.UpdatedAt, types.VerdictPending, types.VerdictJudging); err != nil {
+		return types.Submission{}, err
+	}
+
+	var userID, problemID int
+	if err = tx.QueryRowContext(
 		ctx,
 		query,
 		submission.Verdict,
@@ -130,24 +231,396 @@ func (r *SubmissionRepository) Update(ctx context.Context, submission types.Subm
 		submission.TestsTotal,
 		submission.UpdatedAt,
 		resultsJSON,
+		submission.CompileStdout,
+		submission.CompileStderr,
+		submission.SuspicionScore,
 		submission.ID,
-	)
-	if err != nil {
+		tenantScope(ctx),
+	).Scan(&userID, &problemID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Submission{}, ErrNotFound
+		}
 		return types.Submission{}, err
 	}
+
+	if err = refreshProblemResult(ctx, tx, userID, problemID); err != nil {
+		return types.Submission{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return types.Submission{}, err
+	}
+	return submission, nil
+}
+
+// ListVerdictHistory returns the outcomes a submission had before it was
+// last judged, oldest first.
+func (r *SubmissionRepository) ListVerdictHistory(ctx context.Context, submissionID int64) ([]types.SubmissionVerdictRecord, error) {
+	const query = `
+		SELECT h.id, h.submission_id, h.verdict, h.score, h.tests_passed, h.tests_total,
+			h.cpu_time, h.memory, h.message, h.judged_at, h.replaced_at
+		FROM submission_verdict_history h
+		JOIN submissions s ON s.id = h.submission_id
+		WHERE h.submission_id = $1 AND ($2 = 0 OR s.tenant_id = $2)
+		ORDER BY h.id`
+	rows, err := r.db.QueryContext(ctx, query, submissionID, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []types.SubmissionVerdictRecord{}
+	for rows.Next() {
+		var record types.SubmissionVerdictRecord
+		if err := rows.Scan(
+			&record.ID,
+			&record.SubmissionID,
+			&record.Verdict,
+			&record.Score,
+			&record.TestsPassed,
+			&record.TestsTotal,
+			&record.CPUTime,
+			&record.Memory,
+			&record.Message,
+			&record.JudgedAt,
+			&record.ReplacedAt,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// CancelPending sets the verdict of a submission that is still pending to
+// Cancelled. It returns ErrNotFound when there is no such pending
+// submission.
+func (r *SubmissionRepository) CancelPending(ctx context.Context, id int64, at time.Time) error {
+	const query = `
+		UPDATE submissions
+		SET verdict = $1, updated_at = $2
+		WHERE id = $3 AND verdict = $4 AND ($5 = 0 OR tenant_id = $5)`
+	result, err := r.db.ExecContext(ctx, query, types.VerdictCancelled, at, id, types.VerdictPending, tenantScope(ctx))
+	if err != nil {
+		return err
+	}
 	affected, err := result.RowsAffected()
 	if err != nil {
-		return types.Submission{}, err
+		return err
 	}
 	if affected == 0 {
-		return types.Submission{}, ErrNotFound
+		return ErrNotFound
 	}
-	return submission, nil
+	return nil
+}
+
+// refreshProblemResult recomputes a user's problem_results row for a
+// problem from their judged submissions, removing it when none are left.
+// Cancelled submissions do not count as attempts.
+// Refreshes for the same (user, problem) are serialized so that two
+// verdicts landing together cannot each overwrite the row from a snapshot
+// missing the other.
+func refreshProblemResult(ctx context.Context, tx *sql.Tx, userID, problemID int) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`, int32(problemID), int32(userID)); err != nil {
+		return err
+	}
+
+	const upsert = `
+		INSERT INTO problem_results (user_id, problem_id, best_submission_id, best_score, best_verdict, attempts, first_accepted_at, updated_at)
+		SELECT user_id,
+			problem_id,
+			id,
+			score,
+			verdict,
+			COUNT(1) OVER w,
+			MIN(created_at) FILTER (WHERE verdict = $3) OVER w,
+			NOW()
+		FROM submissions
+		WHERE user_id = $1 AND problem_id = $2 AND verdict NOT IN ($4, $5, $6)
+		WINDOW w AS (PARTITION BY user_id, problem_id)
+		ORDER BY (verdict = $3) DESC, score DESC, id
+		LIMIT 1
+		ON CONFLICT (user_id, problem_id) DO UPDATE
+		SET best_submission_id = EXCLUDED.best_submission_id,
+			best_score = EXCLUDED.best_score,
+			best_verdict = EXCLUDED.best_verdict,
+			attempts = EXCLUDED.attempts,
+			first_accepted_at = EXCLUDED.first_accepted_at,
+			updated_at = EXCLUDED.updated_at`
+	if _, err := tx.ExecContext(ctx, upsert, userID, problemID, types.VerdictAccepted, types.VerdictPending, types.VerdictJudging, types.VerdictCancelled); err != nil {
+		return err
+	}
+
+	const prune = `
+		DELETE FROM problem_results pr
+		WHERE pr.user_id = $1 AND pr.problem_id = $2
+			AND NOT EXISTS (
+				SELECT 1 FROM submissions s
+				WHERE s.user_id = pr.user_id AND s.problem_id = pr.problem_id AND s.verdict NOT IN ($3, $4, $5)
+			)`
+	_, err := tx.ExecContext(ctx, prune, userID, problemID, types.VerdictPending, types.VerdictJudging, types.VerdictCancelled)
+	return err
+}
+
+// ListByProblem returns a page of submissions to a problem, newest first,
+// and the total number of matches. When userID is positive only that
+// user's submissions are listed. Sources and testcase results are not
+// loaded; CodePreview and CodeLength describe the source.
+func (r *SubmissionRepository) ListByProblem(ctx context.Context, problemID, userID, offset, limit int) ([]types.Submission, int, error) {
+	var (
+		submissions []types.Submission
+		total       int
+	)
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		var err error
+		submissions, total, err = listSubmissionsByProblem(ctx, db, problemID, userID, offset, limit)
+		return err
+	})
+	return submissions, total, err
 }
 
-func (r *SubmissionRepository) Delete(ctx context.Context, id int64) error {
-	const query = `DELETE FROM submissions WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, id)
+func listSubmissionsByProblem(ctx context.Context, db *sql.DB, problemID, userID, offset, limit int) ([]types.Submission, int, error) {
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM submissions
+		WHERE problem_id = $1 AND ($2 <= 0 OR user_id = $2) AND ($3 = 0 OR tenant_id = $3)`
+	var total int
+	if err := db.QueryRowContext(ctx, countQuery, problemID, userID, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT id, problem_id, user_id, code_preview, code_length, language,
+		       dialect, verdict, score, cpu_time, memory, tests_passed, tests_total,
+		       created_at, updated_at
+		FROM submissions
+		WHERE problem_id = $1 AND ($2 <= 0 OR user_id = $2) AND ($5 = 0 OR tenant_id = $5)
+		ORDER BY id DESC
+		OFFSET $3 LIMIT $4`
+	rows, err := db.QueryContext(ctx, listQuery, problemID, userID, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	submissions := make([]types.Submission, 0, limit)
+	for rows.Next() {
+		var submission types.Submission
+		if err := rows.Scan(
+			&submission.ID,
+			&submission.ProblemID,
+			&submission.UserID,
+			&submission.CodePreview,
+			&submission.CodeLength,
+			&submission.Language,
+			&submission.Dialect,
+			&submission.Verdict,
+			&submission.Score,
+			&submission.CPUTime,
+			&submission.Memory,
+			&submission.TestsPassed,
+			&submission.TestsTotal,
+			&submission.CreatedAt,
+			&submission.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return submissions, total, nil
+}
+
+// ListSuspicious returns a page of submissions with a suspicion score of
+// at least minScore, most suspicious first, and the total number of
+// matches. Sources and testcase results are not loaded.
+func (r *SubmissionRepository) ListSuspicious(ctx context.Context, minScore, offset, limit int) ([]types.Submission, int, error) {
+	const countQuery = `
+		SELECT COUNT(1)
+		FROM submissions
+		WHERE suspicion_score > 0 AND suspicion_score >= $1 AND ($2 = 0 OR tenant_id = $2)`
+	var total int
+	if err := r.db.QueryRowContext(ctx, countQuery, minScore, tenantScope(ctx)).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	const listQuery = `
+		SELECT id, problem_id, user_id, code_preview, code_length, language,
+		       dialect, verdict, score, cpu_time, memory, tests_passed, tests_total,
+		       suspicion_score, created_at, updated_at
+		FROM submissions
+		WHERE suspicion_score > 0 AND suspicion_score >= $1 AND ($4 = 0 OR tenant_id = $4)
+		ORDER BY suspicion_score DESC, id DESC
+		OFFSET $2 LIMIT $3`
+	rows, err := r.db.QueryContext(ctx, listQuery, minScore, offset, limit, tenantScope(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	submissions := make([]types.Submission, 0, limit)
+	for rows.Next() {
+		var submission types.Submission
+		if err := rows.Scan(
+			&submission.ID,
+			&submission.ProblemID,
+			&submission.UserID,
+			&submission.CodePreview,
+			&submission.CodeLength,
+			&submission.Language,
+			&submission.Dialect,
+			&submission.Verdict,
+			&submission.Score,
+			&submission.CPUTime,
+			&submission.Memory,
+			&submission.TestsPassed,
+			&submission.TestsTotal,
+			&submission.SuspicionScore,
+			&submission.CreatedAt,
+			&submission.UpdatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return submissions, total, nil
+}
+
+// ListForExport returns up to limit submissions matching filter with IDs
+// after afterID, in ID order. Callers page through all of them by passing
+// the last ID seen. Sources and testcase results are not loaded.
+func (r *SubmissionRepository) ListForExport(ctx context.Context, filter ExportFilter, afterID, limit int) ([]types.Submission, error) {
+	const query = `
+		SELECT id, problem_id, user_id, code_length, language, verdict, score,
+		       cpu_time, memory, tests_passed, tests_total, created_at, updated_at
+		FROM submissions
+		WHERE id > $1
+			AND ($2::TIMESTAMPTZ IS NULL OR created_at >= $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR created_at < $3)
+			AND ($5 = 0 OR tenant_id = $5)
+		ORDER BY id
+		LIMIT $4`
+	var submissions []types.Submission
+	err := r.replica.read(ctx, r.db, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, query, afterID, nullableTime(filter.From), nullableTime(filter.To), limit, tenantScope(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		submissions = make([]types.Submission, 0, limit)
+		for rows.Next() {
+			var submission types.Submission
+			if err := rows.Scan(
+				&submission.ID,
+				&submission.ProblemID,
+				&submission.UserID,
+				&submission.CodeLength,
+				&submission.Language,
+				&submission.Verdict,
+				&submission.Score,
+				&submission.CPUTime,
+				&submission.Memory,
+				&submission.TestsPassed,
+				&submission.TestsTotal,
+				&submission.CreatedAt,
+				&submission.UpdatedAt,
+			); err != nil {
+				return err
+			}
+			submissions = append(submissions, submission)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// ListStatuses returns the statuses of the submissions among ids, in ID
+// order. When userID is positive only that user's submissions are
+// returned. Unknown IDs are skipped.
+func (r *SubmissionRepository) ListStatuses(ctx context.Context, ids []int64, userID int) ([]types.SubmissionStatus, error) {
+	const query = `
+		SELECT id, verdict, score, cpu_time, memory, updated_at
+		FROM submissions
+		WHERE id = ANY($1) AND ($2 <= 0 OR user_id = $2) AND ($3 = 0 OR tenant_id = $3)
+		ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, ids, userID, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []types.SubmissionStatus{}
+	for rows.Next() {
+		var status types.SubmissionStatus
+		if err := rows.Scan(
+			&status.ID,
+			&status.Verdict,
+			&status.Score,
+			&status.CPUTime,
+			&status.Memory,
+			&status.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// ListInlineCode returns up to limit submissions with IDs above afterID
+// whose source is still stored in the row, in ID order, skipping pruned
+// ones. Only the ID and Code are set.
+func (r *SubmissionRepository) ListInlineCode(ctx context.Context, afterID, limit int) ([]types.Submission, error) {
+	const query = `
+		SELECT id, code
+		FROM submissions
+		WHERE id > $1 AND code_key IS NULL AND code_pruned_at IS NULL
+		ORDER BY id
+		LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var submissions []types.Submission
+	for rows.Next() {
+		var submission types.Submission
+		if err := rows.Scan(&submission.ID, &submission.Code); err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+// SetCodeKey records that the submission's source now lives under key in
+// object storage and clears the inline copy. It returns ErrNotFound if the
+// submission does not exist, was already moved or was pruned meanwhile.
+func (r *SubmissionRepository) SetCodeKey(ctx context.Context, id int, key string) error {
+	const query = `
+		UPDATE submissions
+		SET code_key = $1, code = ''
+		WHERE id = $2 AND code_key IS NULL AND code_pruned_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, key, id)
 	if err != nil {
 		return err
 	}
@@ -160,3 +633,178 @@ func (r *SubmissionRepository) Delete(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// CountPrunableCode returns the number and total source size of the
+// submissions created before cutoff whose source the retention policy
+// removes: those judged with any verdict but Accepted.
+func (r *SubmissionRepository) CountPrunableCode(ctx context.Context, cutoff time.Time) (int, int64, error) {
+	const query = `
+		SELECT COUNT(1), COALESCE(SUM(code_length), 0)
+		FROM submissions
+		WHERE created_at < $1 AND verdict NOT IN ($2, $3, $4) AND code_pruned_at IS NULL`
+	var (
+		count int
+		bytes int64
+	)
+	err := r.db.QueryRowContext(ctx, query, cutoff, types.VerdictPending, types.VerdictJudging, types.VerdictAccepted).Scan(&count, &bytes)
+	return count, bytes, err
+}
+
+// PruneCode removes the source of up to limit of the submissions counted
+// by CountPrunableCode, keeping the rest of each row, and returns how many
+// were pruned and the total size of their sources. Objects holding the
+// sources are left for storage GC.
+func (r *SubmissionRepository) PruneCode(ctx context.Context, cutoff time.Time, limit int) (int, int64, error) {
+	const query = `
+		WITH pruned AS (
+			UPDATE submissions
+			SET code = '', code_key = NULL, code_preview = '', code_pruned_at = $1
+			WHERE id IN (
+				SELECT id
+				FROM submissions
+				WHERE created_at < $2 AND verdict NOT IN ($3, $4, $5) AND code_pruned_at IS NULL
+				ORDER BY id
+				LIMIT $6
+			)
+			RETURNING code_length
+		)
+		SELECT COUNT(1), COALESCE(SUM(code_length), 0)
+		FROM pruned`
+	var (
+		count int
+		bytes int64
+	)
+	err := r.db.QueryRowContext(ctx, query, time.Now(), cutoff, types.VerdictPending, types.VerdictJudging, types.VerdictAccepted, limit).Scan(&count, &bytes)
+	return count, bytes, err
+}
+
+func (r *SubmissionRepository) Delete(ctx context.Context, id int64) (err error) {
+	const query = `DELETE FROM submissions WHERE id = $1 AND ($2 = 0 OR tenant_id = $2) RETURNING user_id, problem_id`
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var userID, problemID int
+	if err = tx.QueryRowContext(ctx, query, id, tenantScope(ctx)).Scan(&userID, &problemID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if err = refreshProblemResult(ctx, tx, userID, problemID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListSolved returns the problems a user has an accepted submission for,
+// with the time of the first acceptance, oldest first.
+func (r *SubmissionRepository) ListSolved(ctx context.Context, userID int) ([]types.SolvedProblem, error) {
+	const query = `
+		SELECT pr.problem_id, pr.first_accepted_at
+		FROM problem_results pr
+		JOIN problems p ON p.id = pr.problem_id
+		WHERE pr.user_id = $1 AND pr.first_accepted_at IS NOT NULL AND ($2 = 0 OR p.tenant_id = $2)
+		ORDER BY pr.first_accepted_at, pr.problem_id`
+	rows, err := r.db.QueryContext(ctx, query, userID, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	solved := []types.SolvedProblem{}
+	for rows.Next() {
+		var item types.SolvedProblem
+		if err := rows.Scan(&item.ProblemID, &item.FirstAcceptedAt); err != nil {
+			return nil, err
+		}
+		solved = append(solved, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return solved, nil
+}
+
+// HasSolved reports whether a user has an accepted submission to a
+// problem.
+func (r *SubmissionRepository) HasSolved(ctx context.Context, userID, problemID int) (bool, error) {
+	const query = `
+		SELECT EXISTS (
+			SELECT 1 FROM problem_results
+			WHERE user_id = $1 AND problem_id = $2 AND first_accepted_at IS NOT NULL
+		)`
+	var solved bool
+	err := r.db.QueryRowContext(ctx, query, userID, problemID).Scan(&solved)
+	return solved, err
+}
+
+// CountDaily returns per-day submission counts for a user since the given
+// time. Days are UTC and days without submissions are omitted.
+func (r *SubmissionRepository) CountDaily(ctx context.Context, userID int, since time.Time) ([]types.DailyActivity, error) {
+	const query = `
+		SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(1)
+		FROM submissions
+		WHERE user_id = $1 AND created_at >= $2 AND ($3 = 0 OR tenant_id = $3)
+		GROUP BY day
+		ORDER BY day`
+	rows, err := r.db.QueryContext(ctx, query, userID, since, tenantScope(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []types.DailyActivity{}
+	for rows.Next() {
+		var item types.DailyActivity
+		if err := rows.Scan(&item.Date, &item.Count); err != nil {
+			return nil, err
+		}
+		activity = append(activity, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// QueueStats returns the judge backlog and the turnaround of submissions
+// completed since the given time.
+func (r *SubmissionRepository) QueueStats(ctx context.Context, since time.Time) (types.JudgeQueueStats, error) {
+	const backlogQuery = `
+		SELECT COUNT(1) FILTER (WHERE verdict = $1),
+			COUNT(1) FILTER (WHERE verdict = $2),
+			COALESCE(EXTRACT(EPOCH FROM AVG(now() - created_at) FILTER (WHERE verdict = $1)), 0)::float8,
+			COALESCE(EXTRACT(EPOCH FROM MAX(now() - created_at) FILTER (WHERE verdict = $1)), 0)::float8
+		FROM submissions
+		WHERE verdict IN ($1, $2) AND ($3 = 0 OR tenant_id = $3)`
+	var stats types.JudgeQueueStats
+	if err := r.db.QueryRowContext(ctx, backlogQuery, types.VerdictPending, types.VerdictJudging, tenantScope(ctx)).Scan(
+		&stats.Pending,
+		&stats.Judging,
+		&stats.AvgPendingWaitSeconds,
+		&stats.OldestPendingWaitSeconds,
+	); err != nil {
+		return types.JudgeQueueStats{}, err
+	}
+
+	const completedQuery = `
+		SELECT COUNT(1),
+			COALESCE(EXTRACT(EPOCH FROM AVG(updated_at - created_at)), 0)::float8
+		FROM submissions
+		WHERE verdict NOT IN ($1, $2) AND updated_at >= $3 AND ($4 = 0 OR tenant_id = $4)`
+	if err := r.db.QueryRowContext(ctx, completedQuery, types.VerdictPending, types.VerdictJudging, since, tenantScope(ctx)).Scan(
+		&stats.Completed,
+		&stats.AvgTurnaroundSeconds,
+	); err != nil {
+		return types.JudgeQueueStats{}, err
+	}
+	return stats, nil
+}
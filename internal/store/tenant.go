@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// DefaultTenantID is the tenant created by the migration that introduced
+// tenants. Existing data belongs to it, as do rows written without a tenant
+// in their context.
+const DefaultTenantID = 1
+
+type tenantKey struct{}
+
+// WithTenant scopes the repository calls made with ctx to one tenant: reads
+// and updates only see its rows and inserts add rows to it. Rows owned by
+// a user, such as sessions and notifications, are scoped through their
+// user, and system tables such as jobs are shared by every tenant.
+func WithTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set with WithTenant.
+func TenantFromContext(ctx context.Context) (int, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(int)
+	return tenantID, ok
+}
+
+// tenantScope returns the tenant the queries made with ctx are limited to,
+// or 0 for work spanning every tenant, such as background jobs and applying
+// judge results. Queries compare it as ($n = 0 OR tenant_id = $n).
+func tenantScope(ctx context.Context) int {
+	tenantID, _ := TenantFromContext(ctx)
+	return tenantID
+}
+
+// tenantForWrite returns the tenant rows inserted with ctx belong to.
+func tenantForWrite(ctx context.Context) int {
+	if tenantID, ok := TenantFromContext(ctx); ok && tenantID != 0 {
+		return tenantID
+	}
+	return DefaultTenantID
+}
+
+// TenantRepository handles persistence for tenants.
+type TenantRepository struct {
+	db *sql.DB
+}
+
+func NewTenantRepository(db *sql.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+const tenantSelect = `SELECT id, slug, name, created_at FROM tenants`
+
+func scanTenant(row rowScanner) (types.Tenant, error) {
+	var tenant types.Tenant
+	err := row.Scan(&tenant.ID, &tenant.Slug, &tenant.Name, &tenant.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Tenant{}, ErrNotFound
+		}
+		return types.Tenant{}, err
+	}
+	return tenant, nil
+}
+
+func (r *TenantRepository) GetBySlug(ctx context.Context, slug string) (types.Tenant, error) {
+	return scanTenant(r.db.QueryRowContext(ctx, tenantSelect+` WHERE slug = $1`, slug))
+}
+
+func (r *TenantRepository) List(ctx context.Context) ([]types.Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, tenantSelect+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []types.Tenant
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// Create adds a tenant. It returns ErrConflict if the slug is taken.
+func (r *TenantRepository) Create(ctx context.Context, tenant types.Tenant) (types.Tenant, error) {
+	tenant.CreatedAt = time.Now()
+
+	const query = `
+		INSERT INTO tenants (slug, name, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (slug) DO NOTHING
+		RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, tenant.Slug, tenant.Name, tenant.CreatedAt).Scan(&tenant.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return types.Tenant{}, ErrConflict
+		}
+		return types.Tenant{}, err
+	}
+	return tenant, nil
+}
@@ -22,9 +22,9 @@ func (r *UserRepository) GetByID(ctx context.Context, id int) (types.User, error
 	const query = `
 		SELECT id, username, email, name, role, password_hash, created_at, updated_at
 		FROM users
-		WHERE id = $1`
+		WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
 	var user types.User
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id, tenantScope(ctx)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -43,13 +43,15 @@ func (r *UserRepository) GetByID(ctx context.Context, id int) (types.User, error
 	return user, nil
 }
 
+// GetByUsername looks the user up in the context's tenant, or the default
+// tenant without one, as usernames are only unique within a tenant.
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (types.User, error) {
 	const query = `
 		SELECT id, username, email, name, role, password_hash, created_at, updated_at
 		FROM users
-		WHERE username = $1`
+		WHERE username = $1 AND tenant_id = $2`
 	var user types.User
-	err := r.db.QueryRowContext(ctx, query, username).Scan(
+	err := r.db.QueryRowContext(ctx, query, username, tenantForWrite(ctx)).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -74,12 +76,13 @@ func (r *UserRepository) Create(ctx context.Context, user types.User) (types.Use
 	user.UpdatedAt = now
 
 	const query = `
-		INSERT INTO users (username, email, name, role, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO users (tenant_id, username, email, name, role, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id`
 	if err := r.db.QueryRowContext(
 		ctx,
 		query,
+		tenantForWrite(ctx),
 		user.Username,
 		user.Email,
 		user.Name,
@@ -104,7 +107,7 @@ func (r *UserRepository) Update(ctx context.Context, user types.User) (types.Use
 			role = $4,
 			password_hash = $5,
 			updated_at = $6
-		WHERE id = $7`
+		WHERE id = $7 AND ($8 = 0 OR tenant_id = $8)`
 	result, err := r.db.ExecContext(
 		ctx,
 		query,
@@ -115,6 +118,7 @@ func (r *UserRepository) Update(ctx context.Context, user types.User) (types.Use
 		user.PasswordHash,
 		user.UpdatedAt,
 		user.ID,
+		tenantScope(ctx),
 	)
 	if err != nil {
 		return types.User{}, err
@@ -130,8 +134,8 @@ func (r *UserRepository) Update(ctx context.Context, user types.User) (types.Use
 }
 
 func (r *UserRepository) Delete(ctx context.Context, id int) error {
-	const query = `DELETE FROM users WHERE id = $1`
-	result, err := r.db.ExecContext(ctx, query, id)
+	const query = `DELETE FROM users WHERE id = $1 AND ($2 = 0 OR tenant_id = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, tenantScope(ctx))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,129 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/minio"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/modules/rabbitmq"
+)
+
+const (
+	postgresImage = "postgres:16-alpine"
+	minioImage    = "minio/minio:RELEASE.2024-01-16T16-07-38Z"
+	rabbitmqImage = "rabbitmq:3-management-alpine"
+
+	dbName     = "jjudge"
+	dbUser     = "jjudge"
+	dbPassword = "jjudge"
+)
+
+// backends are the containers the server under test runs against. Each
+// run gets its own, on ports Docker picks, and the testcontainers reaper
+// removes them even if the test binary dies before terminate runs.
+type backends struct {
+	postgres *postgres.PostgresContainer
+	minio    *minio.MinioContainer
+	rabbitmq *rabbitmq.RabbitMQContainer
+}
+
+// startBackends starts Postgres, MinIO and RabbitMQ and waits for them to
+// accept connections. The returned backends must be terminated even when
+// an error is returned, since some containers may have started.
+func startBackends(ctx context.Context) (*backends, error) {
+	b := &backends{}
+	var err error
+	b.postgres, err = postgres.Run(ctx, postgresImage,
+		postgres.WithDatabase(dbName),
+		postgres.WithUsername(dbUser),
+		postgres.WithPassword(dbPassword),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return b, fmt.Errorf("postgres: %w", err)
+	}
+	b.minio, err = minio.Run(ctx, minioImage)
+	if err != nil {
+		return b, fmt.Errorf("minio: %w", err)
+	}
+	b.rabbitmq, err = rabbitmq.Run(ctx, rabbitmqImage)
+	if err != nil {
+		return b, fmt.Errorf("rabbitmq: %w", err)
+	}
+	return b, nil
+}
+
+// setEnv points the configuration config.LoadConfig reads at the
+// containers.
+func (b *backends) setEnv(ctx context.Context) error {
+	dbHost, err := b.postgres.Host(ctx)
+	if err != nil {
+		return err
+	}
+	dbPort, err := b.postgres.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return err
+	}
+	minioEndpoint, err := b.minio.ConnectionString(ctx)
+	if err != nil {
+		return err
+	}
+	amqpURL, err := b.rabbitmq.AmqpURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	env := map[string]string{
+		"DB_HOST":          dbHost,
+		"DB_PORT":          dbPort.Port(),
+		"DB_USER":          dbUser,
+		"DB_PASSWORD":      dbPassword,
+		"DB_NAME":          dbName,
+		"DB_USE_SSL":       "false",
+		"STORAGE_BACKEND":  "minio",
+		"MINIO_ENDPOINT":   minioEndpoint,
+		"MINIO_ACCESS_KEY": b.minio.Username,
+		"MINIO_SECRET_KEY": b.minio.Password,
+		"MINIO_BUCKET":     "jjudge",
+		"MINIO_USE_SSL":    "false",
+		"MQ_BACKEND":       "rabbitmq",
+		"RABBITMQ_URL":     amqpURL,
+	}
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// terminate stops and removes the containers that were started.
+func (b *backends) terminate() {
+	if b == nil {
+		return
+	}
+	var errs []error
+	for _, c := range []testcontainers.Container{b.rabbitmq, b.minio, b.postgres} {
+		errs = append(errs, testcontainers.TerminateContainer(c))
+	}
+	if err := errors.Join(errs...); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to terminate containers: %v\n", err)
+	}
+}
+
+// freePort returns a TCP port that was free on localhost a moment ago.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
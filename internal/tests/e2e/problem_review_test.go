@@ -0,0 +1,149 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// TestEditSendsApprovedProblemBackToReview changes an approved problem in
+// each way the store allows and checks which changes need a new review.
+func TestEditSendsApprovedProblemBackToReview(t *testing.T) {
+	baseURL := fmt.Sprintf("http://localhost:%d", serverPort)
+	username := fmt.Sprintf("review_%d", time.Now().UnixNano())
+	token, err := registerUser(t, baseURL, username, "testpass123!")
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	if err := promoteUserToAdmin(username); err != nil {
+		t.Fatalf("promote user: %v", err)
+	}
+	bundleName, bundleData, err := buildTestBundle()
+	if err != nil {
+		t.Fatalf("build bundle: %v", err)
+	}
+	created, err := createProblem(t, baseURL, token, bundleName, bundleData)
+	if err != nil {
+		t.Fatalf("create problem: %v", err)
+	}
+	// Let the bundle settle first, so that its processing does not race
+	// the changes below.
+	if status, err := waitForBundle(t, baseURL, token, created.ID); err != nil || status.Status != "ready" {
+		t.Fatalf("wait for bundle: status %+v, err %v", status, err)
+	}
+
+	db, err := sql.Open("postgres", buildPostgresURL(config.LoadConfig()))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	repo := store.NewProblemRepository(db)
+	approve := func(t *testing.T) types.Problem {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, `UPDATE problems SET review_status = $1, published = FALSE WHERE id = $2`, types.ReviewApproved, created.ID); err != nil {
+			t.Fatalf("approve: %v", err)
+		}
+		problem, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("get problem: %v", err)
+		}
+		return problem
+	}
+	expectStatus := func(t *testing.T, status types.ReviewStatus, published bool) types.Problem {
+		t.Helper()
+		problem, err := repo.Get(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("get problem: %v", err)
+		}
+		if problem.ReviewStatus != status || problem.Published != published {
+			t.Fatalf("review status = %s, published = %v, want %s, %v", problem.ReviewStatus, problem.Published, status, published)
+		}
+		return problem
+	}
+	ptr := func(s string) *string { return &s }
+	yes := true
+
+	t.Run("metadata", func(t *testing.T) {
+		approve(t)
+		if _, err := repo.Patch(ctx, created.ID, types.ProblemPatch{Tags: &[]string{"math"}}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		expectStatus(t, types.ReviewApproved, false)
+	})
+
+	t.Run("unchanged statement", func(t *testing.T) {
+		problem := approve(t)
+		if _, err := repo.Patch(ctx, created.ID, types.ProblemPatch{Description: ptr(problem.Description), Published: &yes}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		expectStatus(t, types.ReviewApproved, true)
+	})
+
+	t.Run("patched statement", func(t *testing.T) {
+		approve(t)
+		updated, err := repo.Patch(ctx, created.ID, types.ProblemPatch{Description: ptr("A new statement.")})
+		if err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		if updated.ReviewStatus != types.ReviewDraft {
+			t.Fatalf("returned review status = %s, want %s", updated.ReviewStatus, types.ReviewDraft)
+		}
+		expectStatus(t, types.ReviewDraft, false)
+	})
+
+	t.Run("published with a new statement", func(t *testing.T) {
+		problem := approve(t)
+		_, err := repo.Patch(ctx, created.ID, types.ProblemPatch{Description: ptr("Another statement."), Published: &yes})
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("patch: err = %v, want %v", err, store.ErrNotFound)
+		}
+		if after := expectStatus(t, types.ReviewApproved, false); after.Description != problem.Description {
+			t.Fatalf("description = %q, want it unchanged", after.Description)
+		}
+	})
+
+	t.Run("updated limits", func(t *testing.T) {
+		problem := approve(t)
+		problem.Tags = []string{"math", "greedy"}
+		if _, err := repo.Update(ctx, problem); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		expectStatus(t, types.ReviewApproved, false)
+
+		problem.TimeLimit *= 2
+		updated, err := repo.Update(ctx, problem)
+		if err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if updated.ReviewStatus != types.ReviewDraft {
+			t.Fatalf("returned review status = %s, want %s", updated.ReviewStatus, types.ReviewDraft)
+		}
+		expectStatus(t, types.ReviewDraft, false)
+	})
+
+	t.Run("new tests", func(t *testing.T) {
+		approve(t)
+		bundle, err := repo.GetLatestTestcaseBundle(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("get bundle: %v", err)
+		}
+		bundle.Version++
+		bundle.SHA256 = fmt.Sprintf("%064x", time.Now().UnixNano())
+		if err := repo.AddTestcaseBundleVersion(ctx, created.ID, bundle); err != nil {
+			t.Fatalf("add bundle version: %v", err)
+		}
+		expectStatus(t, types.ReviewDraft, false)
+	})
+}
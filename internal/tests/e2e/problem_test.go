@@ -14,7 +14,6 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -29,57 +28,62 @@ import (
 	_ "github.com/lib/pq"
 )
 
-const (
-	serverPort = 18080
-)
+// serverPort is the port the server under test listens on. TestMain
+// picks a free one so that concurrent runs do not collide.
+var serverPort int
 
 func TestMain(m *testing.M) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	os.Exit(run(m))
+}
+
+// run starts the backends and the server, runs the tests and returns the
+// exit code, so that the deferred cleanup runs before os.Exit.
+func run(m *testing.M) int {
+	// Allow for pulling the container images on the first run.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	root, err := repoRoot()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to locate repo root: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	if err := dockerCompose(ctx, root, "up", "-d"); err != nil {
-		fmt.Fprintf(os.Stderr, "failed to start docker compose: %v\n", err)
-		os.Exit(1)
+	containers, err := startBackends(ctx)
+	defer containers.terminate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start containers: %v\n", err)
+		return 1
 	}
-
-	if err := waitForPostgres(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "postgres not ready: %v\n", err)
-		_ = dockerCompose(context.Background(), root, "down")
-		os.Exit(1)
+	if err := containers.setEnv(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure backends: %v\n", err)
+		return 1
 	}
 
 	if err := runMigrations(root); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to run migrations: %v\n", err)
-		_ = dockerCompose(context.Background(), root, "down")
-		os.Exit(1)
+		return 1
 	}
 
+	serverPort, err = freePort()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to pick server port: %v\n", err)
+		return 1
+	}
 	srv, err := startServer()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to start server: %v\n", err)
-		_ = dockerCompose(context.Background(), root, "down")
-		os.Exit(1)
+		return 1
 	}
+	defer func() { _ = srv.Shutdown() }()
 
 	baseURL := fmt.Sprintf("http://localhost:%d", serverPort)
 	if err := waitForHealth(ctx, baseURL+"/healthz"); err != nil {
 		fmt.Fprintf(os.Stderr, "server not healthy: %v\n", err)
-		_ = srv.Shutdown()
-		_ = dockerCompose(context.Background(), root, "down")
-		os.Exit(1)
+		return 1
 	}
 
-	code := m.Run()
-
-	_ = srv.Shutdown()
-	_ = dockerCompose(context.Background(), root, "down")
-	os.Exit(code)
+	return m.Run()
 }
 
 func TestProblemLifecycle(t *testing.T) {
@@ -113,6 +117,14 @@ func TestProblemLifecycle(t *testing.T) {
 		t.Fatalf("expected problem ID to be set")
 	}
 
+	status, err := waitForBundle(t, baseURL, token, resp.ID)
+	if err != nil {
+		t.Fatalf("wait for bundle: %v", err)
+	}
+	if status.Status != "ready" {
+		t.Fatalf("unexpected bundle status: %q (%s)", status.Status, status.Error.Message)
+	}
+
 	updated, err := updateProblem(t, baseURL, token, resp.ID, bundleName, bundleData)
 	if err != nil {
 		t.Fatalf("update problem: %v", err)
@@ -121,7 +133,7 @@ func TestProblemLifecycle(t *testing.T) {
 		t.Fatalf("unexpected updated problem title: %q", updated.Title)
 	}
 
-	fetched, err := getProblem(t, baseURL, resp.ID)
+	fetched, err := getProblem(t, baseURL, token, resp.ID)
 	if err != nil {
 		t.Fatalf("get problem: %v", err)
 	}
@@ -143,6 +155,14 @@ type problemResponse struct {
 	Title string `json:"title"`
 }
 
+type bundleStatusResponse struct {
+	Status string `json:"status"`
+	Error  struct {
+		File    string `json:"file"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
 type authResponse struct {
 	Token string `json:"token"`
 }
@@ -242,7 +262,7 @@ func createProblem(t *testing.T, baseURL, token, bundleName string, bundle []byt
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusAccepted {
 		msg, _ := io.ReadAll(resp.Body)
 		return problemResponse{}, fmt.Errorf("create problem status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
 	}
@@ -254,6 +274,43 @@ func createProblem(t *testing.T, baseURL, token, bundleName string, bundle []byt
 	return parsed, nil
 }
 
+func waitForBundle(t *testing.T, baseURL, token string, id int) (bundleStatusResponse, error) {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/problems/%d/bundle-status", baseURL, id), nil)
+		if err != nil {
+			return bundleStatusResponse{}, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return bundleStatusResponse{}, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return bundleStatusResponse{}, fmt.Errorf("bundle status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+		}
+
+		var parsed bundleStatusResponse
+		err = json.NewDecoder(resp.Body).Decode(&parsed)
+		resp.Body.Close()
+		if err != nil {
+			return bundleStatusResponse{}, err
+		}
+		if parsed.Status != "processing" {
+			return parsed, nil
+		}
+		if time.Now().After(deadline) {
+			return bundleStatusResponse{}, fmt.Errorf("bundle still processing")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
 func updateProblem(t *testing.T, baseURL, token string, id int, bundleName string, bundle []byte) (problemResponse, error) {
 	t.Helper()
 
@@ -304,13 +361,14 @@ func updateProblem(t *testing.T, baseURL, token string, id int, bundleName strin
 	return parsed, nil
 }
 
-func getProblem(t *testing.T, baseURL string, id int) (problemResponse, error) {
+func getProblem(t *testing.T, baseURL, token string, id int) (problemResponse, error) {
 	t.Helper()
 
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/problems/%d", baseURL, id), nil)
 	if err != nil {
 		return problemResponse{}, err
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -429,33 +487,6 @@ func buildTestcaseGroupsJSON() string {
 	return string(data)
 }
 
-func waitForPostgres(ctx context.Context) error {
-	cfg := config.LoadConfig()
-	dsn := buildPostgresURL(cfg)
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-		err := db.PingContext(pingCtx)
-		cancel()
-		if err == nil {
-			return nil
-		}
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("postgres ping timeout: %w", err)
-		case <-ticker.C:
-		}
-	}
-}
-
 func waitForHealth(ctx context.Context, url string) error {
 	client := &http.Client{Timeout: 2 * time.Second}
 	ticker := time.NewTicker(500 * time.Millisecond)
@@ -523,15 +554,6 @@ func buildPostgresURL(cfg config.Config) string {
 func startServer() (*server.Server, error) {
 	_ = os.Setenv("JWT_SECRET", "test-secret")
 	_ = os.Setenv("SERVER_PORT", fmt.Sprintf("%d", serverPort))
-	_ = os.Setenv("DB_HOST", "localhost")
-	_ = os.Setenv("DB_PORT", "5432")
-	_ = os.Setenv("DB_USER", "jjudge")
-	_ = os.Setenv("DB_PASSWORD", "jjudge")
-	_ = os.Setenv("DB_NAME", "jjudge")
-	_ = os.Setenv("DB_USE_SSL", "false")
-	_ = os.Setenv("MINIO_ACCESS_KEY", "minioadmin")
-	_ = os.Setenv("MINIO_SECRET_KEY", "minioadmin")
-	_ = os.Setenv("MINIO_BUCKET", "jjudge")
 
 	cfg := config.LoadConfig()
 	srv, err := server.New(context.Background(), cfg)
@@ -546,15 +568,6 @@ func startServer() (*server.Server, error) {
 	return srv, nil
 }
 
-func dockerCompose(ctx context.Context, root string, args ...string) error {
-	composeFile := filepath.Join(root, "development", "docker-compose.yml")
-	baseArgs := append([]string{"compose", "-f", composeFile}, args...)
-	cmd := exec.CommandContext(ctx, "docker", baseArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 func repoRoot() (string, error) {
 	dir, err := os.Getwd()
 	if err != nil {
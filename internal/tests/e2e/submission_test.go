@@ -0,0 +1,155 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jjudge-oj/apiserver/config"
+	"github.com/jjudge-oj/apiserver/internal/store"
+	"github.com/jjudge-oj/apiserver/types"
+)
+
+// TestJudgedOutcomeKeepsCancellation stores a judge's outcome for a
+// submission cancelled after the judge loaded it, both when the cancel
+// commits first and when it commits while the outcome waits for the row.
+func TestJudgedOutcomeKeepsCancellation(t *testing.T) {
+	baseURL := fmt.Sprintf("http://localhost:%d", serverPort)
+	username := fmt.Sprintf("cancel_%d", time.Now().UnixNano())
+	token, err := registerUser(t, baseURL, username, "testpass123!")
+	if err != nil {
+		t.Fatalf("register user: %v", err)
+	}
+	if err := promoteUserToAdmin(username); err != nil {
+		t.Fatalf("promote user: %v", err)
+	}
+	bundleName, bundleData, err := buildTestBundle()
+	if err != nil {
+		t.Fatalf("build bundle: %v", err)
+	}
+	problem, err := createProblem(t, baseURL, token, bundleName, bundleData)
+	if err != nil {
+		t.Fatalf("create problem: %v", err)
+	}
+
+	db, err := sql.Open("postgres", buildPostgresURL(config.LoadConfig()))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var userID int
+	if err := db.QueryRowContext(ctx, `SELECT id FROM users WHERE username = $1`, username).Scan(&userID); err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	repo := store.NewSubmissionRepository(db)
+	newSubmission := func(t *testing.T) types.Submission {
+		t.Helper()
+		submission, err := repo.Create(ctx, types.Submission{
+			ProblemID: problem.ID,
+			UserID:    userID,
+			Code:      "print(1)",
+			Language:  "python",
+			Verdict:   types.VerdictPending,
+		})
+		if err != nil {
+			t.Fatalf("create submission: %v", err)
+		}
+		return submission
+	}
+	judged := func(submission types.Submission) types.Submission {
+		submission.Verdict = types.VerdictAccepted
+		submission.Score = 100
+		return submission
+	}
+	expectCancelled := func(t *testing.T, id int) {
+		t.Helper()
+		submission, err := repo.Get(ctx, int64(id))
+		if err != nil {
+			t.Fatalf("get submission: %v", err)
+		}
+		if submission.Verdict != types.VerdictCancelled {
+			t.Fatalf("verdict = %s, want %s", submission.Verdict, types.VerdictCancelled)
+		}
+		history, err := repo.ListVerdictHistory(ctx, int64(id))
+		if err != nil {
+			t.Fatalf("list verdict history: %v", err)
+		}
+		if len(history) != 0 {
+			t.Fatalf("verdict history = %+v, want none", history)
+		}
+	}
+
+	t.Run("cancel before write", func(t *testing.T) {
+		submission := newSubmission(t)
+		loaded, err := repo.Get(ctx, int64(submission.ID))
+		if err != nil {
+			t.Fatalf("get submission: %v", err)
+		}
+		if err := repo.CancelPending(ctx, int64(submission.ID), time.Now()); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if _, err := repo.UpdateJudged(ctx, judged(loaded)); !errors.Is(err, store.ErrCancelled) {
+			t.Fatalf("update judged: err = %v, want %v", err, store.ErrCancelled)
+		}
+		expectCancelled(t, submission.ID)
+	})
+
+	t.Run("cancel during write", func(t *testing.T) {
+		submission := newSubmission(t)
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM submissions WHERE id = $1 FOR UPDATE`, submission.ID); err != nil {
+			t.Fatalf("lock submission: %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := repo.UpdateJudged(ctx, judged(submission))
+			done <- err
+		}()
+		if err := waitForLockWaiter(ctx, db); err != nil {
+			t.Fatalf("wait for the outcome to block: %v", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE submissions SET verdict = $1 WHERE id = $2 AND verdict = $3`, types.VerdictCancelled, submission.ID, types.VerdictPending); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("commit cancel: %v", err)
+		}
+		if err := <-done; !errors.Is(err, store.ErrCancelled) {
+			t.Fatalf("update judged: err = %v, want %v", err, store.ErrCancelled)
+		}
+		expectCancelled(t, submission.ID)
+	})
+}
+
+// waitForLockWaiter waits until a backend is blocked on a row lock.
+func waitForLockWaiter(ctx context.Context, db *sql.DB) error {
+	for {
+		var waiting bool
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_stat_activity WHERE wait_event_type = 'Lock')`).Scan(&waiting)
+		if err != nil {
+			return err
+		}
+		if waiting {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
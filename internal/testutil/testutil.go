@@ -0,0 +1,25 @@
+// Package testutil wires in-memory backends for tests that exercise
+// services and handlers without the docker-compose environment.
+package testutil
+
+import (
+	"github.com/jjudge-oj/apiserver/internal/mq"
+	"github.com/jjudge-oj/apiserver/internal/storage"
+)
+
+// Bucket is the bucket name reported by storage returned from NewStorage.
+const Bucket = "jjudge-test"
+
+// NewMQ returns a message queue backed by an in-memory broker, together
+// with the broker for inspecting published messages.
+func NewMQ() (*mq.MQ, *mq.Memory) {
+	backend := mq.NewMemory()
+	return mq.New(backend), backend
+}
+
+// NewStorage returns object storage backed by an in-memory store, together
+// with the store for inspecting objects.
+func NewStorage() (*storage.Storage, *storage.Memory) {
+	backend := storage.NewMemory(Bucket)
+	return storage.NewStorage(backend), backend
+}
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// AbuseBlock keeps a principal, a user or a client IP, away from the API
+// after the abuse detector flagged its traffic.
+type AbuseBlock struct {
+	// Principal identifies who is blocked, as "user:<id>" or
+	// "ip:<address>".
+	Principal string `json:"principal" db:"principal"`
+
+	// Reason describes the anomaly that triggered the block.
+	Reason string `json:"reason" db:"reason"`
+
+	// BlockedUntil is when the block lifts on its own.
+	BlockedUntil time.Time `json:"blocked_until" db:"blocked_until"`
+
+	// CreatedAt is when the principal was blocked.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
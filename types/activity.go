@@ -0,0 +1,22 @@
+package types
+
+import "time"
+
+// SolvedProblem records the first accepted submission of a user for a problem.
+type SolvedProblem struct {
+	// ProblemID identifies the solved problem.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// FirstAcceptedAt is the creation time of the user's earliest
+	// accepted submission for the problem.
+	FirstAcceptedAt time.Time `json:"first_accepted_at" db:"first_accepted_at"`
+}
+
+// DailyActivity is the number of submissions a user made on one UTC day.
+type DailyActivity struct {
+	// Date is the UTC day formatted as YYYY-MM-DD.
+	Date string `json:"date" db:"date"`
+
+	// Count is the number of submissions made on that day.
+	Count int `json:"count" db:"count"`
+}
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// Announcement represents a site-wide message posted by an administrator.
+type Announcement struct {
+	// ID is the unique identifier of the announcement.
+	ID int `json:"id" db:"id"`
+
+	// Title is the short headline of the announcement.
+	Title string `json:"title" db:"title"`
+
+	// Body is the full announcement text.
+	Body string `json:"body" db:"body"`
+
+	// Pinned keeps the announcement at the top of listings.
+	Pinned bool `json:"pinned" db:"pinned"`
+
+	// AuthorID identifies the administrator who posted the announcement.
+	AuthorID int `json:"author_id" db:"author_id"`
+
+	// ExpiresAt is the time after which the announcement is no longer
+	// listed. A nil value means the announcement never expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+
+	// CreatedAt is the timestamp at which the announcement was posted.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is the timestamp of the most recent edit.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
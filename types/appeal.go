@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// AppealStatus is where an appeal is in its review.
+type AppealStatus string
+
+// Supported appeal statuses.
+const (
+	// AppealOpen appeals wait for an admin.
+	AppealOpen AppealStatus = "open"
+
+	// AppealResolved appeals were answered by an admin.
+	AppealResolved AppealStatus = "resolved"
+)
+
+// AppealResolution is how an admin resolved an appeal.
+type AppealResolution string
+
+// Supported appeal resolutions.
+const (
+	// AppealDismissed keeps the submission's verdict.
+	AppealDismissed AppealResolution = "dismissed"
+
+	// AppealRejudged judges the submission again.
+	AppealRejudged AppealResolution = "rejudged"
+
+	// AppealOverridden replaces the submission's verdict and score with
+	// ones chosen by the admin.
+	AppealOverridden AppealResolution = "overridden"
+)
+
+// Appeal is a user's request to have the verdict of one of their
+// submissions looked at again, for example after a judging mistake.
+type Appeal struct {
+	// ID is the unique identifier of the appeal.
+	ID int64 `json:"id" db:"id"`
+
+	// SubmissionID identifies the appealed submission.
+	SubmissionID int `json:"submission_id" db:"submission_id"`
+
+	// UserID identifies the user who filed the appeal.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Message is the user's explanation of what went wrong.
+	Message string `json:"message" db:"message"`
+
+	// Status is AppealOpen until an admin resolves the appeal.
+	Status AppealStatus `json:"status" db:"status"`
+
+	// Resolution is how the appeal was resolved, empty while it is open.
+	Resolution AppealResolution `json:"resolution,omitempty" db:"resolution"`
+
+	// Response is the admin's answer to the user.
+	Response string `json:"response,omitempty" db:"response"`
+
+	// ResolvedBy identifies the admin who resolved the appeal.
+	ResolvedBy *int `json:"resolved_by,omitempty" db:"resolved_by"`
+
+	// ResolvedAt is when the appeal was resolved.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+
+	// CreatedAt is when the appeal was filed.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,74 @@
+package types
+
+import "time"
+
+// BundleUpload is a session for uploading a testcase bundle in parts, for
+// bundles too large to send in a single request.
+type BundleUpload struct {
+	// ID is the unique, unguessable identifier of the session.
+	ID string `json:"id" db:"id"`
+
+	// ProblemID identifies the problem the bundle is uploaded for.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// UserID identifies the user who started the session.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Filename is the name of the bundle archive, which selects its format.
+	Filename string `json:"filename" db:"filename"`
+
+	// TestcaseGroups configures the groups of the uploaded bundle.
+	TestcaseGroups []TestcaseGroup `json:"testcase_groups" db:"testcase_groups"`
+
+	// Status is the state of the session.
+	Status BundleUploadStatus `json:"status" db:"status"`
+
+	// Error explains why processing the assembled bundle failed.
+	Error *BundleError `json:"error,omitempty" db:"-"`
+
+	// Size is the size of the assembled bundle in bytes, known once the
+	// upload is completed.
+	Size int64 `json:"size" db:"size"`
+
+	// BundleVersion is the problem's bundle version created from the
+	// upload, once it is ready.
+	BundleVersion int `json:"bundle_version,omitempty" db:"bundle_version"`
+
+	// CreatedAt is the timestamp when the session was started.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is the timestamp when the session was last updated.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// ExpiresAt is the deadline for completing the upload.
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// BundleError describes why a testcase bundle was rejected.
+type BundleError struct {
+	// File is the bundle entry at fault, if the error concerns a single
+	// entry.
+	File string `json:"file,omitempty"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// BundleUploadStatus is the state of a bundle upload session.
+type BundleUploadStatus string
+
+const (
+	// BundleUploadUploading means parts are still being uploaded.
+	BundleUploadUploading BundleUploadStatus = "uploading"
+
+	// BundleUploadProcessing means the upload was completed and the
+	// assembled bundle is being verified.
+	BundleUploadProcessing BundleUploadStatus = "processing"
+
+	// BundleUploadReady means the bundle was stored as the problem's
+	// latest bundle version.
+	BundleUploadReady BundleUploadStatus = "ready"
+
+	// BundleUploadFailed means the assembled bundle was rejected.
+	BundleUploadFailed BundleUploadStatus = "failed"
+)
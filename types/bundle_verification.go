@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+// BundleVerification is the result of checking a stored testcase bundle
+// against the hash recorded when it was uploaded.
+type BundleVerification struct {
+	// ProblemID identifies the problem the bundle belongs to.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// Version is the bundle version that was checked.
+	Version int `json:"version" db:"version"`
+
+	// ObjectKey is the key of the bundle in object storage.
+	ObjectKey string `json:"object_key" db:"object_key"`
+
+	// ExpectedSHA256 is the hash recorded for the bundle.
+	ExpectedSHA256 string `json:"expected_sha256" db:"sha256"`
+
+	// ActualSHA256 is the hash of the stored object.
+	ActualSHA256 string `json:"actual_sha256" db:"-"`
+
+	// Size is the size of the stored object in bytes.
+	Size int64 `json:"size" db:"-"`
+
+	// Corrupted reports whether the stored object does not match the
+	// recorded hash. Judges refuse corrupted bundles.
+	Corrupted bool `json:"corrupted" db:"corrupted"`
+
+	// Error describes the mismatch of a corrupted bundle.
+	Error string `json:"error,omitempty" db:"integrity_error"`
+
+	// VerifiedAt is the timestamp of the check.
+	VerifiedAt time.Time `json:"verified_at" db:"verified_at"`
+}
@@ -0,0 +1,96 @@
+package types
+
+import "time"
+
+// ReportTarget is the kind of content a report is about.
+type ReportTarget string
+
+// Reportable content.
+const (
+	ReportTargetComment ReportTarget = "comment"
+	ReportTargetProblem ReportTarget = "problem"
+	ReportTargetUser    ReportTarget = "user"
+)
+
+// ReportStatus is where a report is in the moderation queue.
+type ReportStatus string
+
+// Report statuses.
+const (
+	// ReportOpen reports wait in the moderation queue.
+	ReportOpen ReportStatus = "open"
+
+	// ReportResolved reports led a moderator to act on the content.
+	ReportResolved ReportStatus = "resolved"
+
+	// ReportDismissed reports were reviewed and needed no action.
+	ReportDismissed ReportStatus = "dismissed"
+)
+
+// ReportAction is what a moderator did about a report.
+type ReportAction string
+
+// Moderation actions.
+const (
+	// ReportActionNone dismisses the report.
+	ReportActionNone ReportAction = "none"
+
+	// ReportActionHide hides a comment or unpublishes a problem.
+	ReportActionHide ReportAction = "hide"
+
+	// ReportActionBan bans the reported user, or the author of the
+	// reported comment or problem.
+	ReportActionBan ReportAction = "ban"
+)
+
+// ContentReport is a user's report of abusive content, waiting for or
+// resolved by a moderator.
+type ContentReport struct {
+	// ID is the unique identifier of the report.
+	ID int64 `json:"id" db:"id"`
+
+	// ReporterID identifies the user who made the report.
+	ReporterID int `json:"reporter_id" db:"reporter_id"`
+
+	// TargetType is the kind of content reported.
+	TargetType ReportTarget `json:"target_type" db:"target_type"`
+
+	// TargetID identifies the comment, problem or user reported.
+	TargetID int64 `json:"target_id" db:"target_id"`
+
+	// Reason is the reporter's explanation.
+	Reason string `json:"reason" db:"reason"`
+
+	// Status is where the report is in the moderation queue.
+	Status ReportStatus `json:"status" db:"status"`
+
+	// Action is what the moderator did, once the report is closed.
+	Action ReportAction `json:"action,omitempty" db:"action"`
+
+	// ResolverID identifies the moderator who closed the report.
+	ResolverID int `json:"resolver_id,omitempty" db:"resolver_id"`
+
+	// Resolution is the moderator's note on the outcome.
+	Resolution string `json:"resolution,omitempty" db:"resolution"`
+
+	// CreatedAt is the timestamp when the report was made.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// ResolvedAt is the timestamp when the report was closed.
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// UserBan bars a user from signing in.
+type UserBan struct {
+	// UserID identifies the banned user.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Reason explains the ban.
+	Reason string `json:"reason" db:"reason"`
+
+	// BannedBy identifies the moderator who banned the user.
+	BannedBy int `json:"banned_by" db:"banned_by"`
+
+	// CreatedAt is the timestamp when the ban was imposed.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
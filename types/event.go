@@ -0,0 +1,47 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Supported domain event types.
+const (
+	EventProblemCreated       = "problem.created"
+	EventProblemUpdated       = "problem.updated"
+	EventProblemDeleted       = "problem.deleted"
+	EventSubmissionCreated    = "submission.created"
+	EventSubmissionCancelled  = "submission.cancelled"
+	EventSubmissionRejudged   = "submission.rejudged"
+	EventSubmissionOverridden = "submission.verdict_overridden"
+	EventAppealResolved       = "appeal.resolved"
+	EventUserRegistered       = "user.registered"
+	EventUserBanned           = "user.banned"
+	EventUserUnbanned         = "user.unbanned"
+	EventReportResolved       = "report.resolved"
+)
+
+// Event is an entry in the append-only domain event log.
+type Event struct {
+	// ID is the monotonically increasing position of the event in the log.
+	ID int64 `json:"id" db:"id"`
+
+	// Type identifies what happened (e.g., "problem.created").
+	Type string `json:"type" db:"type"`
+
+	// SubjectID identifies the entity the event is about, such as the
+	// problem or submission ID.
+	SubjectID int64 `json:"subject_id" db:"subject_id"`
+
+	// ActorID identifies the user who caused the event, or 0 for the system.
+	ActorID int `json:"actor_id" db:"actor_id"`
+
+	// TenantID identifies the tenant the event happened in.
+	TenantID int `json:"tenant_id" db:"tenant_id"`
+
+	// Payload holds event-specific data encoded as JSON.
+	Payload json.RawMessage `json:"payload" db:"payload"`
+
+	// CreatedAt is the timestamp at which the event was recorded.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
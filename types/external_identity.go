@@ -0,0 +1,16 @@
+package types
+
+// ExternalIdentity is a user as described by an external identity
+// provider, such as an LDAP directory or a SAML identity provider.
+type ExternalIdentity struct {
+	// Username is the user's login name at the provider.
+	Username string
+
+	// Email and Name are copied to the account at every sign-in when set.
+	Email string
+	Name  string
+
+	// Groups lists the provider groups the user belongs to, matched
+	// against the configured group roles.
+	Groups []string
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job is a unit of background work, such as processing an uploaded bundle
+// or refreshing the leaderboard, recorded in the database so that its
+// outcome can be inspected and interrupted work is picked up again.
+type Job struct {
+	// ID is the unique identifier of the job.
+	ID int64 `json:"id" db:"id"`
+
+	// Kind names the task that runs the job.
+	Kind string `json:"kind" db:"kind"`
+
+	// Payload holds the task's arguments.
+	Payload json.RawMessage `json:"payload" db:"payload"`
+
+	// Status is the state of the job.
+	Status JobStatus `json:"status" db:"status"`
+
+	// Attempts is the number of times the job was started.
+	Attempts int `json:"attempts" db:"attempts"`
+
+	// MaxAttempts is the number of attempts after which a failing job is
+	// given up on.
+	MaxAttempts int `json:"max_attempts" db:"max_attempts"`
+
+	// Result is what the task reported on success, if anything.
+	Result json.RawMessage `json:"result,omitempty" db:"result"`
+
+	// Error is the error of the latest failed attempt.
+	Error string `json:"error,omitempty" db:"error"`
+
+	// RunAt is the earliest time the job may be started.
+	RunAt time.Time `json:"run_at" db:"run_at"`
+
+	// StartedAt is the timestamp when the latest attempt started.
+	StartedAt *time.Time `json:"started_at,omitempty" db:"started_at"`
+
+	// FinishedAt is the timestamp when the job succeeded or was given up
+	// on.
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+
+	// CreatedAt is the timestamp when the job was queued.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	// JobPending means the job waits for its first or next attempt.
+	JobPending JobStatus = "pending"
+
+	// JobRunning means an attempt is in progress.
+	JobRunning JobStatus = "running"
+
+	// JobSucceeded means an attempt completed.
+	JobSucceeded JobStatus = "succeeded"
+
+	// JobFailed means every attempt failed.
+	JobFailed JobStatus = "failed"
+)
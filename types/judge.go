@@ -0,0 +1,331 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JudgeProtocolVersion is the version of the judge job and result formats
+// produced by this server. It is incremented whenever a change would break
+// workers built against the previous version; adding optional fields does
+// not change it.
+const JudgeProtocolVersion = 1
+
+// ErrInvalidJudgeMessage is returned when a judge job or result is
+// malformed or uses an unsupported protocol version.
+var ErrInvalidJudgeMessage = errors.New("invalid judge message")
+
+// JudgeJobKind tells workers consuming a channel what kind of work a job
+// describes.
+type JudgeJobKind string
+
+// Supported judge job kinds.
+const (
+	// JudgeJobSubmission judges a submission against its problem's
+	// testcase bundle.
+	JudgeJobSubmission JudgeJobKind = "submission"
+
+	// JudgeJobRun executes user code against user-provided input.
+	JudgeJobRun JudgeJobKind = "run"
+
+	// JudgeJobValidation judges a reference solution shipped with a
+	// testcase bundle.
+	JudgeJobValidation JudgeJobKind = "validation"
+
+	// JudgeJobGeneration materializes the generated testcases of a
+	// testcase bundle.
+	JudgeJobGeneration JudgeJobKind = "generation"
+
+	// JudgeJobInputValidation runs a bundle's input validator against
+	// every testcase input.
+	JudgeJobInputValidation JudgeJobKind = "input_validation"
+)
+
+// JudgeJob is the message published to judge workers. Which fields are set
+// depends on Kind; see Validate for the fields each kind requires.
+type JudgeJob struct {
+	// Version is the protocol version the job was encoded with.
+	Version int `json:"version"`
+
+	// Kind is the kind of work requested.
+	Kind JudgeJobKind `json:"kind"`
+
+	// SubmissionID identifies the submission to judge.
+	SubmissionID int `json:"submission_id,omitempty"`
+
+	// RunID identifies the custom run to execute.
+	RunID int64 `json:"run_id,omitempty"`
+
+	// ProblemID identifies the problem whose bundle the job uses.
+	ProblemID int `json:"problem_id,omitempty"`
+
+	// UserID identifies the user who requested the submission or run.
+	UserID int `json:"user_id,omitempty"`
+
+	// Language is the identifier of the language of the program to run.
+	Language string `json:"language"`
+
+	// Code is the source of a custom run.
+	Code string `json:"code,omitempty"`
+
+	// Stdin is the input of a custom run.
+	Stdin string `json:"stdin,omitempty"`
+
+	// TimeLimit is the time limit of a custom run, or the problem's time
+	// limit for submission and validation jobs, expressed in milliseconds.
+	TimeLimit int `json:"time_limit,omitempty"`
+
+	// MemoryLimit is the memory limit of a custom run, or the problem's
+	// memory limit for submission and validation jobs, expressed in bytes.
+	MemoryLimit int `json:"memory_limit,omitempty"`
+
+	// BundleVersion is the testcase bundle version the job applies to.
+	BundleVersion int `json:"bundle_version,omitempty"`
+
+	// ObjectKey is the storage key of the testcase bundle.
+	ObjectKey string `json:"object_key,omitempty"`
+
+	// Solution is the bundle-relative path of the reference solution to
+	// judge or to produce generated outputs with.
+	Solution string `json:"solution,omitempty"`
+
+	// Expected is the verdict the reference solution must receive.
+	Expected *Verdict `json:"expected,omitempty"`
+
+	// Validator is the bundle-relative path of the input validator.
+	Validator string `json:"validator,omitempty"`
+
+	// Manifest lists the testcases to generate.
+	Manifest *GenerationManifest `json:"manifest,omitempty"`
+
+	// LimitOverrides lists the test cases of a submission or validation
+	// job whose limits differ from TimeLimit and MemoryLimit.
+	LimitOverrides []LimitOverride `json:"limit_overrides,omitempty"`
+
+	// SubmittedAt is when the submission of a submission job was created,
+	// in milliseconds since the Unix epoch. Workers echo it, along with
+	// Contest, in the result so the server can measure judge latency.
+	// Rejudges leave it unset.
+	SubmittedAt int64 `json:"submitted_at,omitempty"`
+
+	// Contest reports whether the submission was made during a contest
+	// rather than for practice.
+	Contest bool `json:"contest,omitempty"`
+}
+
+// LimitOverride gives one test case limits different from the job's.
+type LimitOverride struct {
+	// Group is the OrderID of the test case's group.
+	Group int `json:"group"`
+
+	// Testcase is the OrderID of the test case within its group.
+	Testcase int `json:"testcase"`
+
+	// TimeLimit is the test case's time limit, expressed in milliseconds.
+	TimeLimit int64 `json:"time_limit"`
+
+	// MemoryLimit is the test case's memory limit, expressed in bytes.
+	MemoryLimit int64 `json:"memory_limit"`
+}
+
+// Validate reports whether the job carries a supported protocol version and
+// every field its kind requires.
+func (j JudgeJob) Validate() error {
+	if err := checkJudgeProtocolVersion(j.Version); err != nil {
+		return err
+	}
+	if strings.TrimSpace(j.Language) == "" {
+		return fmt.Errorf("%w: language is required", ErrInvalidJudgeMessage)
+	}
+
+	switch j.Kind {
+	case JudgeJobSubmission:
+		if j.SubmissionID <= 0 || j.ProblemID <= 0 {
+			return fmt.Errorf("%w: submission jobs require submission_id and problem_id", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobRun:
+		if j.RunID <= 0 {
+			return fmt.Errorf("%w: run jobs require run_id", ErrInvalidJudgeMessage)
+		}
+		if j.TimeLimit <= 0 || j.MemoryLimit <= 0 {
+			return fmt.Errorf("%w: run jobs require time_limit and memory_limit", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobValidation:
+		if err := j.requireBundle(); err != nil {
+			return err
+		}
+		if j.Solution == "" || j.Expected == nil {
+			return fmt.Errorf("%w: validation jobs require solution and expected", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobGeneration:
+		if err := j.requireBundle(); err != nil {
+			return err
+		}
+		if j.Solution == "" || j.Manifest == nil {
+			return fmt.Errorf("%w: generation jobs require solution and manifest", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobInputValidation:
+		if err := j.requireBundle(); err != nil {
+			return err
+		}
+		if j.Validator == "" {
+			return fmt.Errorf("%w: input validation jobs require validator", ErrInvalidJudgeMessage)
+		}
+	default:
+		return fmt.Errorf("%w: unknown job kind %q", ErrInvalidJudgeMessage, j.Kind)
+	}
+	return nil
+}
+
+func (j JudgeJob) requireBundle() error {
+	if j.ProblemID <= 0 || j.BundleVersion <= 0 || j.ObjectKey == "" {
+		return fmt.Errorf("%w: %s jobs require problem_id, bundle_version and object_key", ErrInvalidJudgeMessage, j.Kind)
+	}
+	return nil
+}
+
+// ParseJudgeJob decodes and validates a JSON-encoded judge job.
+func ParseJudgeJob(data []byte) (JudgeJob, error) {
+	var job JudgeJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return JudgeJob{}, fmt.Errorf("%w: %v", ErrInvalidJudgeMessage, err)
+	}
+	if err := job.Validate(); err != nil {
+		return JudgeJob{}, err
+	}
+	return job, nil
+}
+
+// JudgeResult is the outcome of a judge job reported by a worker. Which
+// fields are set depends on Kind; see Validate for the fields each kind
+// requires.
+type JudgeResult struct {
+	// Version is the protocol version the result was encoded with.
+	Version int `json:"version"`
+
+	// Kind is the kind of the job the result answers.
+	Kind JudgeJobKind `json:"kind"`
+
+	// SubmissionID identifies the judged submission.
+	SubmissionID int `json:"submission_id,omitempty"`
+
+	// RunID identifies the executed custom run.
+	RunID int64 `json:"run_id,omitempty"`
+
+	// ProblemID identifies the problem whose bundle the job used.
+	ProblemID int `json:"problem_id,omitempty"`
+
+	// BundleVersion is the testcase bundle version the job used.
+	BundleVersion int `json:"bundle_version,omitempty"`
+
+	// Verdict is the overall outcome.
+	Verdict Verdict `json:"verdict"`
+
+	// Message contains additional information about the verdict, such as
+	// compilation errors.
+	Message string `json:"message,omitempty"`
+
+	// CPUTime is the CPU time consumed, expressed in milliseconds.
+	CPUTime int64 `json:"cpu_time,omitempty"`
+
+	// Memory is the peak memory usage, expressed in bytes.
+	Memory int64 `json:"memory,omitempty"`
+
+	// Stdout is the standard output of a custom run.
+	Stdout string `json:"stdout,omitempty"`
+
+	// Stderr is the standard error or compiler output of a custom run.
+	Stderr string `json:"stderr,omitempty"`
+
+	// CompileStdout is the compiler's standard output for a submission.
+	CompileStdout string `json:"compile_stdout,omitempty"`
+
+	// CompileStderr is the compiler's standard error for a submission,
+	// which carries the diagnostics of a compilation error.
+	CompileStderr string `json:"compile_stderr,omitempty"`
+
+	// TestcaseResults holds the per-testcase results of a submission.
+	TestcaseResults []TestcaseResult `json:"testcase_results,omitempty"`
+
+	// Solution is the bundle-relative path of the judged reference
+	// solution.
+	Solution string `json:"solution,omitempty"`
+
+	// InputErrors lists the inputs rejected by the input validator.
+	InputErrors []InputError `json:"input_errors,omitempty"`
+
+	// SubmittedAt and Contest echo the fields of the submission job.
+	SubmittedAt int64 `json:"submitted_at,omitempty"`
+	Contest     bool  `json:"contest,omitempty"`
+
+	// JudgedAt is when the worker finished judging, in milliseconds since
+	// the Unix epoch.
+	JudgedAt int64 `json:"judged_at,omitempty"`
+}
+
+// Validate reports whether the result carries a supported protocol version
+// and every field its kind requires.
+func (r JudgeResult) Validate() error {
+	if err := checkJudgeProtocolVersion(r.Version); err != nil {
+		return err
+	}
+	if !r.Verdict.Valid() {
+		return fmt.Errorf("%w: unknown verdict %d", ErrInvalidJudgeMessage, int(r.Verdict))
+	}
+
+	switch r.Kind {
+	case JudgeJobSubmission:
+		if r.SubmissionID <= 0 {
+			return fmt.Errorf("%w: submission results require submission_id", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobRun:
+		if r.RunID <= 0 {
+			return fmt.Errorf("%w: run results require run_id", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobValidation:
+		if r.ProblemID <= 0 || r.BundleVersion <= 0 || r.Solution == "" {
+			return fmt.Errorf("%w: validation results require problem_id, bundle_version and solution", ErrInvalidJudgeMessage)
+		}
+	case JudgeJobGeneration, JudgeJobInputValidation:
+		if r.ProblemID <= 0 || r.BundleVersion <= 0 {
+			return fmt.Errorf("%w: %s results require problem_id and bundle_version", ErrInvalidJudgeMessage, r.Kind)
+		}
+	default:
+		return fmt.Errorf("%w: unknown job kind %q", ErrInvalidJudgeMessage, r.Kind)
+	}
+
+	for i, tc := range r.TestcaseResults {
+		if !tc.Verdict.Valid() {
+			return fmt.Errorf("%w: testcase result %d: unknown verdict %d", ErrInvalidJudgeMessage, i, int(tc.Verdict))
+		}
+		// The negated comparison also rejects NaN.
+		if !(tc.CheckerScore >= 0 && tc.CheckerScore <= 1) {
+			return fmt.Errorf("%w: testcase result %d: checker_score must be between 0 and 1", ErrInvalidJudgeMessage, i)
+		}
+	}
+	return nil
+}
+
+// ParseJudgeResult decodes and validates a JSON-encoded judge result.
+func ParseJudgeResult(data []byte) (JudgeResult, error) {
+	var result JudgeResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return JudgeResult{}, fmt.Errorf("%w: %v", ErrInvalidJudgeMessage, err)
+	}
+	if err := result.Validate(); err != nil {
+		return JudgeResult{}, err
+	}
+	return result, nil
+}
+
+// checkJudgeProtocolVersion rejects messages from a newer, incompatible
+// protocol. A missing version is treated as version 1, the format used
+// before versions were introduced.
+func checkJudgeProtocolVersion(version int) error {
+	if version < 0 || version > JudgeProtocolVersion {
+		return fmt.Errorf("%w: unsupported protocol version %d", ErrInvalidJudgeMessage, version)
+	}
+	return nil
+}
@@ -0,0 +1,515 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Content types of judge messages, carried in the message queue's
+// content-type attribute. Messages without one are JSON.
+const (
+	JudgeContentTypeJSON     = "application/json"
+	JudgeContentTypeProtobuf = "application/x-protobuf"
+)
+
+// EncodeJudgeJob encodes the job in the given content type.
+func EncodeJudgeJob(job JudgeJob, contentType string) ([]byte, error) {
+	switch contentType {
+	case "", JudgeContentTypeJSON:
+		return json.Marshal(job)
+	case JudgeContentTypeProtobuf:
+		return job.MarshalProto(), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported content type %q", ErrInvalidJudgeMessage, contentType)
+	}
+}
+
+// DecodeJudgeJob decodes and validates a judge job encoded in the given
+// content type.
+func DecodeJudgeJob(data []byte, contentType string) (JudgeJob, error) {
+	switch contentType {
+	case "", JudgeContentTypeJSON:
+		return ParseJudgeJob(data)
+	case JudgeContentTypeProtobuf:
+		var job JudgeJob
+		if err := job.UnmarshalProto(data); err != nil {
+			return JudgeJob{}, err
+		}
+		if err := job.Validate(); err != nil {
+			return JudgeJob{}, err
+		}
+		return job, nil
+	default:
+		return JudgeJob{}, fmt.Errorf("%w: unsupported content type %q", ErrInvalidJudgeMessage, contentType)
+	}
+}
+
+// EncodeJudgeResult encodes the result in the given content type.
+func EncodeJudgeResult(result JudgeResult, contentType string) ([]byte, error) {
+	switch contentType {
+	case "", JudgeContentTypeJSON:
+		return json.Marshal(result)
+	case JudgeContentTypeProtobuf:
+		return result.MarshalProto(), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported content type %q", ErrInvalidJudgeMessage, contentType)
+	}
+}
+
+// DecodeJudgeResult decodes and validates a judge result encoded in the
+// given content type.
+func DecodeJudgeResult(data []byte, contentType string) (JudgeResult, error) {
+	switch contentType {
+	case "", JudgeContentTypeJSON:
+		return ParseJudgeResult(data)
+	case JudgeContentTypeProtobuf:
+		var result JudgeResult
+		if err := result.UnmarshalProto(data); err != nil {
+			return JudgeResult{}, err
+		}
+		if err := result.Validate(); err != nil {
+			return JudgeResult{}, err
+		}
+		return result, nil
+	default:
+		return JudgeResult{}, fmt.Errorf("%w: unsupported content type %q", ErrInvalidJudgeMessage, contentType)
+	}
+}
+
+// The protobuf encoding follows proto/judge.proto. It is written by hand
+// on top of protowire so the module does not depend on generated code;
+// keep both in sync when adding fields.
+
+// MarshalProto encodes the job as a jjudge.judge.v1.JudgeJob message.
+func (j JudgeJob) MarshalProto() []byte {
+	var b []byte
+	b = appendProtoInt(b, 1, int64(j.Version))
+	b = appendProtoString(b, 2, string(j.Kind))
+	b = appendProtoInt(b, 3, int64(j.SubmissionID))
+	b = appendProtoInt(b, 4, j.RunID)
+	b = appendProtoInt(b, 5, int64(j.ProblemID))
+	b = appendProtoInt(b, 6, int64(j.UserID))
+	b = appendProtoString(b, 7, j.Language)
+	b = appendProtoString(b, 8, j.Code)
+	b = appendProtoString(b, 9, j.Stdin)
+	b = appendProtoInt(b, 10, int64(j.TimeLimit))
+	b = appendProtoInt(b, 11, int64(j.MemoryLimit))
+	b = appendProtoInt(b, 12, int64(j.BundleVersion))
+	b = appendProtoString(b, 13, j.ObjectKey)
+	b = appendProtoString(b, 14, j.Solution)
+	if j.Expected != nil {
+		b = protowire.AppendTag(b, 15, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(int64(*j.Expected)))
+	}
+	b = appendProtoString(b, 16, j.Validator)
+	if j.Manifest != nil {
+		var m []byte
+		for _, test := range j.Manifest.Tests {
+			var t []byte
+			t = appendProtoInt(t, 1, int64(test.Group))
+			t = appendProtoInt(t, 2, int64(test.Test))
+			t = appendProtoString(t, 3, test.Generator)
+			for _, arg := range test.Args {
+				t = protowire.AppendTag(t, 4, protowire.BytesType)
+				t = protowire.AppendString(t, arg)
+			}
+			m = appendProtoMessage(m, 1, t)
+		}
+		b = appendProtoMessage(b, 17, m)
+	}
+	for _, override := range j.LimitOverrides {
+		var o []byte
+		o = appendProtoInt(o, 1, int64(override.Group))
+		o = appendProtoInt(o, 2, int64(override.Testcase))
+		o = appendProtoInt(o, 3, override.TimeLimit)
+		o = appendProtoInt(o, 4, override.MemoryLimit)
+		b = appendProtoMessage(b, 18, o)
+	}
+	b = appendProtoInt(b, 19, j.SubmittedAt)
+	b = appendProtoBool(b, 20, j.Contest)
+	return b
+}
+
+// UnmarshalProto decodes a jjudge.judge.v1.JudgeJob message into j.
+func (j *JudgeJob) UnmarshalProto(data []byte) error {
+	*j = JudgeJob{}
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeProtoInt(typ, b, func(v int64) { j.Version = int(int32(v)) })
+		case 2:
+			return consumeProtoString(typ, b, func(s string) { j.Kind = JudgeJobKind(s) })
+		case 3:
+			return consumeProtoInt(typ, b, func(v int64) { j.SubmissionID = int(v) })
+		case 4:
+			return consumeProtoInt(typ, b, func(v int64) { j.RunID = v })
+		case 5:
+			return consumeProtoInt(typ, b, func(v int64) { j.ProblemID = int(v) })
+		case 6:
+			return consumeProtoInt(typ, b, func(v int64) { j.UserID = int(v) })
+		case 7:
+			return consumeProtoString(typ, b, func(s string) { j.Language = s })
+		case 8:
+			return consumeProtoString(typ, b, func(s string) { j.Code = s })
+		case 9:
+			return consumeProtoString(typ, b, func(s string) { j.Stdin = s })
+		case 10:
+			return consumeProtoInt(typ, b, func(v int64) { j.TimeLimit = int(v) })
+		case 11:
+			return consumeProtoInt(typ, b, func(v int64) { j.MemoryLimit = int(v) })
+		case 12:
+			return consumeProtoInt(typ, b, func(v int64) { j.BundleVersion = int(v) })
+		case 13:
+			return consumeProtoString(typ, b, func(s string) { j.ObjectKey = s })
+		case 14:
+			return consumeProtoString(typ, b, func(s string) { j.Solution = s })
+		case 15:
+			return consumeProtoInt(typ, b, func(v int64) {
+				expected := Verdict(int32(v))
+				j.Expected = &expected
+			})
+		case 16:
+			return consumeProtoString(typ, b, func(s string) { j.Validator = s })
+		case 17:
+			return consumeProtoMessage(typ, b, func(m []byte) error {
+				if j.Manifest == nil {
+					j.Manifest = &GenerationManifest{}
+				}
+				return unmarshalProtoManifest(m, j.Manifest)
+			})
+		case 18:
+			return consumeProtoMessage(typ, b, func(m []byte) error {
+				var override LimitOverride
+				err := walkProto(m, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+					switch num {
+					case 1:
+						return consumeProtoInt(typ, b, func(v int64) { override.Group = int(v) })
+					case 2:
+						return consumeProtoInt(typ, b, func(v int64) { override.Testcase = int(v) })
+					case 3:
+						return consumeProtoInt(typ, b, func(v int64) { override.TimeLimit = v })
+					case 4:
+						return consumeProtoInt(typ, b, func(v int64) { override.MemoryLimit = v })
+					}
+					return 0, nil
+				})
+				if err != nil {
+					return err
+				}
+				j.LimitOverrides = append(j.LimitOverrides, override)
+				return nil
+			})
+		case 19:
+			return consumeProtoInt(typ, b, func(v int64) { j.SubmittedAt = v })
+		case 20:
+			return consumeProtoInt(typ, b, func(v int64) { j.Contest = v != 0 })
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJudgeMessage, err)
+	}
+	return nil
+}
+
+func unmarshalProtoManifest(data []byte, manifest *GenerationManifest) error {
+	return walkProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num != 1 {
+			return 0, nil
+		}
+		return consumeProtoMessage(typ, b, func(m []byte) error {
+			var test GeneratedTestcase
+			err := walkProto(m, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch num {
+				case 1:
+					return consumeProtoInt(typ, b, func(v int64) { test.Group = int(v) })
+				case 2:
+					return consumeProtoInt(typ, b, func(v int64) { test.Test = int(v) })
+				case 3:
+					return consumeProtoString(typ, b, func(s string) { test.Generator = s })
+				case 4:
+					return consumeProtoString(typ, b, func(s string) { test.Args = append(test.Args, s) })
+				}
+				return 0, nil
+			})
+			if err != nil {
+				return err
+			}
+			manifest.Tests = append(manifest.Tests, test)
+			return nil
+		})
+	})
+}
+
+// MarshalProto encodes the result as a jjudge.judge.v1.JudgeResult
+// message.
+func (r JudgeResult) MarshalProto() []byte {
+	var b []byte
+	b = appendProtoInt(b, 1, int64(r.Version))
+	b = appendProtoString(b, 2, string(r.Kind))
+	b = appendProtoInt(b, 3, int64(r.SubmissionID))
+	b = appendProtoInt(b, 4, r.RunID)
+	b = appendProtoInt(b, 5, int64(r.ProblemID))
+	b = appendProtoInt(b, 6, int64(r.BundleVersion))
+	b = appendProtoInt(b, 7, int64(r.Verdict))
+	b = appendProtoString(b, 8, r.Message)
+	b = appendProtoInt(b, 9, r.CPUTime)
+	b = appendProtoInt(b, 10, r.Memory)
+	b = appendProtoString(b, 11, r.Stdout)
+	b = appendProtoString(b, 12, r.Stderr)
+	for _, tc := range r.TestcaseResults {
+		var t []byte
+		t = appendProtoInt(t, 1, tc.SubmissionID)
+		t = appendProtoInt(t, 2, int64(tc.TestcaseID))
+		t = appendProtoInt(t, 3, int64(tc.Verdict))
+		t = appendProtoInt(t, 4, tc.CPUTime)
+		t = appendProtoInt(t, 5, tc.Memory)
+		t = appendProtoString(t, 6, tc.Input)
+		t = appendProtoString(t, 7, tc.ExpectedOutput)
+		t = appendProtoString(t, 8, tc.ActualOutput)
+		t = appendProtoString(t, 9, tc.ErrorMessage)
+		t = appendProtoDouble(t, 10, tc.CheckerScore)
+		t = appendProtoString(t, 11, tc.CheckerMessage)
+		b = appendProtoMessage(b, 13, t)
+	}
+	b = appendProtoString(b, 14, r.Solution)
+	for _, inputErr := range r.InputErrors {
+		var e []byte
+		e = appendProtoString(e, 1, inputErr.File)
+		e = appendProtoString(e, 2, inputErr.Message)
+		b = appendProtoMessage(b, 15, e)
+	}
+	b = appendProtoString(b, 16, r.CompileStdout)
+	b = appendProtoString(b, 17, r.CompileStderr)
+	b = appendProtoInt(b, 18, r.SubmittedAt)
+	b = appendProtoBool(b, 19, r.Contest)
+	b = appendProtoInt(b, 20, r.JudgedAt)
+	return b
+}
+
+// UnmarshalProto decodes a jjudge.judge.v1.JudgeResult message into r.
+func (r *JudgeResult) UnmarshalProto(data []byte) error {
+	*r = JudgeResult{}
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeProtoInt(typ, b, func(v int64) { r.Version = int(int32(v)) })
+		case 2:
+			return consumeProtoString(typ, b, func(s string) { r.Kind = JudgeJobKind(s) })
+		case 3:
+			return consumeProtoInt(typ, b, func(v int64) { r.SubmissionID = int(v) })
+		case 4:
+			return consumeProtoInt(typ, b, func(v int64) { r.RunID = v })
+		case 5:
+			return consumeProtoInt(typ, b, func(v int64) { r.ProblemID = int(v) })
+		case 6:
+			return consumeProtoInt(typ, b, func(v int64) { r.BundleVersion = int(v) })
+		case 7:
+			return consumeProtoInt(typ, b, func(v int64) { r.Verdict = Verdict(int32(v)) })
+		case 8:
+			return consumeProtoString(typ, b, func(s string) { r.Message = s })
+		case 9:
+			return consumeProtoInt(typ, b, func(v int64) { r.CPUTime = v })
+		case 10:
+			return consumeProtoInt(typ, b, func(v int64) { r.Memory = v })
+		case 11:
+			return consumeProtoString(typ, b, func(s string) { r.Stdout = s })
+		case 12:
+			return consumeProtoString(typ, b, func(s string) { r.Stderr = s })
+		case 13:
+			return consumeProtoMessage(typ, b, func(m []byte) error {
+				tc, err := unmarshalProtoTestcaseResult(m)
+				if err != nil {
+					return err
+				}
+				r.TestcaseResults = append(r.TestcaseResults, tc)
+				return nil
+			})
+		case 14:
+			return consumeProtoString(typ, b, func(s string) { r.Solution = s })
+		case 15:
+			return consumeProtoMessage(typ, b, func(m []byte) error {
+				var inputErr InputError
+				err := walkProto(m, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+					switch num {
+					case 1:
+						return consumeProtoString(typ, b, func(s string) { inputErr.File = s })
+					case 2:
+						return consumeProtoString(typ, b, func(s string) { inputErr.Message = s })
+					}
+					return 0, nil
+				})
+				if err != nil {
+					return err
+				}
+				r.InputErrors = append(r.InputErrors, inputErr)
+				return nil
+			})
+		case 16:
+			return consumeProtoString(typ, b, func(s string) { r.CompileStdout = s })
+		case 17:
+			return consumeProtoString(typ, b, func(s string) { r.CompileStderr = s })
+		case 18:
+			return consumeProtoInt(typ, b, func(v int64) { r.SubmittedAt = v })
+		case 19:
+			return consumeProtoInt(typ, b, func(v int64) { r.Contest = v != 0 })
+		case 20:
+			return consumeProtoInt(typ, b, func(v int64) { r.JudgedAt = v })
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJudgeMessage, err)
+	}
+	return nil
+}
+
+func unmarshalProtoTestcaseResult(data []byte) (TestcaseResult, error) {
+	var tc TestcaseResult
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case 1:
+			return consumeProtoInt(typ, b, func(v int64) { tc.SubmissionID = v })
+		case 2:
+			return consumeProtoInt(typ, b, func(v int64) { tc.TestcaseID = int(v) })
+		case 3:
+			return consumeProtoInt(typ, b, func(v int64) { tc.Verdict = Verdict(int32(v)) })
+		case 4:
+			return consumeProtoInt(typ, b, func(v int64) { tc.CPUTime = v })
+		case 5:
+			return consumeProtoInt(typ, b, func(v int64) { tc.Memory = v })
+		case 6:
+			return consumeProtoString(typ, b, func(s string) { tc.Input = s })
+		case 7:
+			return consumeProtoString(typ, b, func(s string) { tc.ExpectedOutput = s })
+		case 8:
+			return consumeProtoString(typ, b, func(s string) { tc.ActualOutput = s })
+		case 9:
+			return consumeProtoString(typ, b, func(s string) { tc.ErrorMessage = s })
+		case 10:
+			return consumeProtoDouble(typ, b, func(v float64) { tc.CheckerScore = v })
+		case 11:
+			return consumeProtoString(typ, b, func(s string) { tc.CheckerMessage = s })
+		}
+		return 0, nil
+	})
+	return tc, err
+}
+
+// walkProto calls field for every field of a protobuf message. field
+// returns the number of bytes of b it consumed, or 0 to skip the field as
+// unknown; fields whose wire type does not match the schema are skipped
+// the same way, as protobuf implementations do.
+func walkProto(data []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n, err := field(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func consumeProtoInt(typ protowire.Type, b []byte, set func(int64)) (int, error) {
+	if typ != protowire.VarintType {
+		return 0, nil
+	}
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	set(int64(v))
+	return n, nil
+}
+
+func consumeProtoDouble(typ protowire.Type, b []byte, set func(float64)) (int, error) {
+	if typ != protowire.Fixed64Type {
+		return 0, nil
+	}
+	v, n := protowire.ConsumeFixed64(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	set(math.Float64frombits(v))
+	return n, nil
+}
+
+func consumeProtoString(typ protowire.Type, b []byte, set func(string)) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, nil
+	}
+	v, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	set(v)
+	return n, nil
+}
+
+func consumeProtoMessage(typ protowire.Type, b []byte, decode func([]byte) error) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, nil
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	if err := decode(v); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// appendProtoInt appends a varint field, omitting the zero value as proto3
+// does for scalar fields.
+func appendProtoInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendProtoBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendProtoInt(b, num, 1)
+}
+
+func appendProtoDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendProtoMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,799 @@
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func verdictPtr(v Verdict) *Verdict { return &v }
+
+func sampleJudgeJobs() map[JudgeJobKind]JudgeJob {
+	return map[JudgeJobKind]JudgeJob{
+		JudgeJobSubmission: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobSubmission,
+			SubmissionID:  42,
+			ProblemID:     7,
+			UserID:        3,
+			Language:      "cpp17",
+			TimeLimit:     2000,
+			MemoryLimit:   256 << 20,
+			BundleVersion: 4,
+			ObjectKey:     "bundles/7/4.tar.gz",
+			LimitOverrides: []LimitOverride{
+				{Group: 1, Testcase: 2, TimeLimit: 5000, MemoryLimit: 512 << 20},
+				{Group: 3, Testcase: 1, TimeLimit: 100},
+			},
+			SubmittedAt: 1_760_000_000_123,
+			Contest:     true,
+		},
+		JudgeJobRun: {
+			Version:     JudgeProtocolVersion,
+			Kind:        JudgeJobRun,
+			RunID:       1 << 40,
+			UserID:      3,
+			Language:    "python3",
+			Code:        "print(input())\n",
+			Stdin:       "héllo, wörld\n",
+			TimeLimit:   1000,
+			MemoryLimit: 64 << 20,
+		},
+		JudgeJobValidation: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobValidation,
+			ProblemID:     7,
+			Language:      "cpp17",
+			TimeLimit:     2000,
+			MemoryLimit:   256 << 20,
+			BundleVersion: 4,
+			ObjectKey:     "bundles/7/4.tar.gz",
+			Solution:      "solutions/slow.cpp",
+			Expected:      verdictPtr(VerdictTimeLimitExceeded),
+		},
+		JudgeJobGeneration: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobGeneration,
+			ProblemID:     7,
+			Language:      "cpp17",
+			BundleVersion: 4,
+			ObjectKey:     "bundles/7/4.tar.gz",
+			Solution:      "solutions/main.cpp",
+			Manifest: &GenerationManifest{Tests: []GeneratedTestcase{
+				{Group: 1, Test: 1, Generator: "gen.py", Args: []string{"--n", "10", ""}},
+				{Group: 1, Test: 2, Generator: "gen.py"},
+			}},
+		},
+		JudgeJobInputValidation: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobInputValidation,
+			ProblemID:     7,
+			Language:      "cpp17",
+			BundleVersion: 4,
+			ObjectKey:     "bundles/7/4.tar.gz",
+			Validator:     "validator.cpp",
+		},
+	}
+}
+
+func sampleJudgeResults() map[JudgeJobKind]JudgeResult {
+	return map[JudgeJobKind]JudgeResult{
+		JudgeJobSubmission: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobSubmission,
+			SubmissionID:  42,
+			ProblemID:     7,
+			BundleVersion: 4,
+			Verdict:       VerdictWrongAnswer,
+			Message:       "wrong answer on test 2",
+			CPUTime:       1234,
+			Memory:        56 << 20,
+			CompileStdout: "ok",
+			CompileStderr: "warning: unused variable",
+			TestcaseResults: []TestcaseResult{
+				{SubmissionID: 42, TestcaseID: 1, Verdict: VerdictAccepted, CPUTime: 10, Memory: 1 << 20},
+				{
+					SubmissionID:   42,
+					TestcaseID:     2,
+					Verdict:        VerdictWrongAnswer,
+					CPUTime:        20,
+					Memory:         2 << 20,
+					Input:          "1 2\n",
+					ExpectedOutput: "3\n",
+					ActualOutput:   "4\n",
+					ErrorMessage:   "exit status 0",
+					CheckerScore:   0.25,
+					CheckerMessage: "partially correct",
+				},
+			},
+			SubmittedAt: 1_760_000_000_123,
+			Contest:     true,
+			JudgedAt:    1_760_000_004_567,
+		},
+		JudgeJobRun: {
+			Version: JudgeProtocolVersion,
+			Kind:    JudgeJobRun,
+			RunID:   1 << 40,
+			Verdict: VerdictRuntimeError,
+			CPUTime: 5,
+			Memory:  3 << 20,
+			Stdout:  "partial\n",
+			Stderr:  "Traceback (most recent call last):\n",
+		},
+		JudgeJobValidation: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobValidation,
+			ProblemID:     7,
+			BundleVersion: 4,
+			Solution:      "solutions/slow.cpp",
+			Verdict:       VerdictTimeLimitExceeded,
+		},
+		JudgeJobGeneration: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobGeneration,
+			ProblemID:     7,
+			BundleVersion: 4,
+			Verdict:       VerdictAccepted,
+		},
+		JudgeJobInputValidation: {
+			Version:       JudgeProtocolVersion,
+			Kind:          JudgeJobInputValidation,
+			ProblemID:     7,
+			BundleVersion: 4,
+			Verdict:       VerdictAccepted,
+			InputErrors: []InputError{
+				{File: "1_3.in", Message: "n out of range"},
+				{File: "2_1.in"},
+			},
+		},
+	}
+}
+
+func TestJudgeJobProtoRoundTrip(t *testing.T) {
+	for kind, job := range sampleJudgeJobs() {
+		t.Run(string(kind), func(t *testing.T) {
+			data, err := EncodeJudgeJob(job, JudgeContentTypeProtobuf)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := DecodeJudgeJob(data, JudgeContentTypeProtobuf)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, job) {
+				t.Fatalf("round trip mismatch\n got %+v\nwant %+v", decoded, job)
+			}
+		})
+	}
+}
+
+func TestJudgeResultProtoRoundTrip(t *testing.T) {
+	for kind, result := range sampleJudgeResults() {
+		t.Run(string(kind), func(t *testing.T) {
+			data, err := EncodeJudgeResult(result, JudgeContentTypeProtobuf)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			decoded, err := DecodeJudgeResult(data, JudgeContentTypeProtobuf)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, result) {
+				t.Fatalf("round trip mismatch\n got %+v\nwant %+v", decoded, result)
+			}
+		})
+	}
+}
+
+func TestJudgeJobProtoKeepsPresence(t *testing.T) {
+	// Expected is optional, so a pending verdict, the zero value, must
+	// survive, as must an empty manifest.
+	job := JudgeJob{Expected: verdictPtr(VerdictPending), Manifest: &GenerationManifest{}}
+	var decoded JudgeJob
+	if err := decoded.UnmarshalProto(job.MarshalProto()); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if decoded.Expected == nil || *decoded.Expected != VerdictPending {
+		t.Fatalf("expected = %v, want pending", decoded.Expected)
+	}
+	if decoded.Manifest == nil {
+		t.Fatalf("manifest was dropped")
+	}
+
+	// Proto3 scalars are omitted when zero.
+	if data := (JudgeJob{}).MarshalProto(); len(data) != 0 {
+		t.Fatalf("empty job encoded as %x", data)
+	}
+}
+
+// appendUnknownFields appends fields the schema does not define, one of
+// each wire type.
+func appendUnknownFields(b []byte) []byte {
+	b = protowire.AppendTag(b, 900, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1<<63)
+	b = protowire.AppendTag(b, 901, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 7)
+	b = protowire.AppendTag(b, 902, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, 7)
+	b = protowire.AppendTag(b, 903, protowire.BytesType)
+	b = protowire.AppendString(b, "from a newer server")
+	b = protowire.AppendTag(b, 904, protowire.StartGroupType)
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	b = protowire.AppendTag(b, 904, protowire.EndGroupType)
+	return b
+}
+
+// appendMistypedKind appends field 2, kind, which is a string, as a
+// varint; decoders must skip it like an unknown field.
+func appendMistypedKind(b []byte) []byte {
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	return protowire.AppendVarint(b, 5)
+}
+
+func TestJudgeProtoSkipsUnknownFields(t *testing.T) {
+	for kind, job := range sampleJudgeJobs() {
+		t.Run("job/"+string(kind), func(t *testing.T) {
+			// Unknown fields before and after the known ones.
+			data := appendUnknownFields(nil)
+			data = append(data, job.MarshalProto()...)
+			data = appendUnknownFields(data)
+			data = appendMistypedKind(data)
+			data = appendProtoString(data, 2, string(job.Kind))
+			var decoded JudgeJob
+			if err := decoded.UnmarshalProto(data); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, job) {
+				t.Fatalf("mismatch\n got %+v\nwant %+v", decoded, job)
+			}
+		})
+	}
+	for kind, result := range sampleJudgeResults() {
+		t.Run("result/"+string(kind), func(t *testing.T) {
+			data := appendUnknownFields(nil)
+			data = append(data, result.MarshalProto()...)
+			data = appendUnknownFields(data)
+			data = appendMistypedKind(data)
+			data = appendProtoString(data, 2, string(result.Kind))
+			var decoded JudgeResult
+			if err := decoded.UnmarshalProto(data); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, result) {
+				t.Fatalf("mismatch\n got %+v\nwant %+v", decoded, result)
+			}
+		})
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		var tc []byte
+		tc = appendProtoInt(tc, 2, 9)
+		tc = appendUnknownFields(tc)
+		tc = appendProtoInt(tc, 3, int64(VerdictAccepted))
+		data := appendProtoMessage(nil, 13, tc)
+		var decoded JudgeResult
+		if err := decoded.UnmarshalProto(data); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		want := []TestcaseResult{{TestcaseID: 9, Verdict: VerdictAccepted}}
+		if !reflect.DeepEqual(decoded.TestcaseResults, want) {
+			t.Fatalf("testcase results = %+v, want %+v", decoded.TestcaseResults, want)
+		}
+	})
+}
+
+func TestJudgeProtoRejectsTruncatedInput(t *testing.T) {
+	jobs, results := sampleJudgeJobs(), sampleJudgeResults()
+	encoded := map[string][]byte{
+		"job":    jobs[JudgeJobGeneration].MarshalProto(),
+		"result": results[JudgeJobSubmission].MarshalProto(),
+	}
+	for name, data := range encoded {
+		t.Run(name, func(t *testing.T) {
+			for n := range len(data) {
+				// Prefixes may end on a field boundary, which decodes;
+				// none may panic.
+				var job JudgeJob
+				errJob := job.UnmarshalProto(data[:n])
+				var result JudgeResult
+				errResult := result.UnmarshalProto(data[:n])
+				for _, err := range []error{errJob, errResult} {
+					if err != nil && !errors.Is(err, ErrInvalidJudgeMessage) {
+						t.Fatalf("prefix %d: err = %v, want %v", n, err, ErrInvalidJudgeMessage)
+					}
+				}
+			}
+		})
+	}
+
+	cases := map[string][]byte{
+		"tag only":           protowire.AppendTag(nil, 3, protowire.VarintType),
+		"string past end":    append(protowire.AppendTag(nil, 7, protowire.BytesType), 10, 'a', 'b'),
+		"message past end":   append(protowire.AppendTag(nil, 17, protowire.BytesType), 200, 1),
+		"fixed64 cut":        append(protowire.AppendTag(nil, 902, protowire.Fixed64Type), 1, 2, 3),
+		"unterminated group": protowire.AppendTag(nil, 904, protowire.StartGroupType),
+		"varint cut":         append(protowire.AppendTag(nil, 3, protowire.VarintType), 0x80, 0x80),
+		"field number zero":  {0x00, 0x01},
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			var job JudgeJob
+			if err := job.UnmarshalProto(data); !errors.Is(err, ErrInvalidJudgeMessage) {
+				t.Fatalf("job: err = %v, want %v", err, ErrInvalidJudgeMessage)
+			}
+			var result JudgeResult
+			if err := result.UnmarshalProto(data); !errors.Is(err, ErrInvalidJudgeMessage) {
+				t.Fatalf("result: err = %v, want %v", err, ErrInvalidJudgeMessage)
+			}
+		})
+	}
+
+	// Truncation inside nested messages: testcase results and input
+	// errors of a result, limit overrides and generated tests of a job.
+	var job JudgeJob
+	overrideCut := appendProtoMessage(nil, 18, protowire.AppendTag(nil, 3, protowire.VarintType))
+	if err := job.UnmarshalProto(overrideCut); !errors.Is(err, ErrInvalidJudgeMessage) {
+		t.Fatalf("limit override cut: err = %v, want %v", err, ErrInvalidJudgeMessage)
+	}
+	testCut := appendProtoMessage(nil, 17, appendProtoMessage(nil, 1, append(protowire.AppendTag(nil, 4, protowire.BytesType), 5, 'a')))
+	if err := job.UnmarshalProto(testCut); !errors.Is(err, ErrInvalidJudgeMessage) {
+		t.Fatalf("generated test cut: err = %v, want %v", err, ErrInvalidJudgeMessage)
+	}
+	var result JudgeResult
+	testcaseCut := appendProtoMessage(nil, 13, protowire.AppendTag(nil, 6, protowire.BytesType))
+	if err := result.UnmarshalProto(testcaseCut); !errors.Is(err, ErrInvalidJudgeMessage) {
+		t.Fatalf("testcase result cut: err = %v, want %v", err, ErrInvalidJudgeMessage)
+	}
+	inputErrorCut := appendProtoMessage(nil, 15, append(protowire.AppendTag(nil, 1, protowire.BytesType), 9))
+	if err := result.UnmarshalProto(inputErrorCut); !errors.Is(err, ErrInvalidJudgeMessage) {
+		t.Fatalf("input error cut: err = %v, want %v", err, ErrInvalidJudgeMessage)
+	}
+}
+
+func TestJudgeProtoRejectsVarintOverflow(t *testing.T) {
+	// Eleven continuation bytes encode more than 64 bits.
+	overflow := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	cases := map[string][]byte{
+		"value":   append(protowire.AppendTag(nil, 3, protowire.VarintType), overflow...),
+		"tag":     overflow,
+		"length":  append(protowire.AppendTag(nil, 7, protowire.BytesType), overflow...),
+		"unknown": append(protowire.AppendTag(nil, 900, protowire.VarintType), overflow...),
+		"nested": append(
+			appendProtoMessage(nil, 18, append(protowire.AppendTag(nil, 4, protowire.VarintType), overflow...)),
+			appendProtoMessage(nil, 13, append(protowire.AppendTag(nil, 4, protowire.VarintType), overflow...))...,
+		),
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			var job JudgeJob
+			if err := job.UnmarshalProto(data); !errors.Is(err, ErrInvalidJudgeMessage) {
+				t.Fatalf("job: err = %v, want %v", err, ErrInvalidJudgeMessage)
+			}
+			var result JudgeResult
+			if err := result.UnmarshalProto(data); !errors.Is(err, ErrInvalidJudgeMessage) {
+				t.Fatalf("result: err = %v, want %v", err, ErrInvalidJudgeMessage)
+			}
+		})
+	}
+
+	// Ten bytes hold the largest varint, which decodes.
+	maxVarint := append(protowire.AppendTag(nil, 9, protowire.VarintType), overflow[1:]...)
+	var result JudgeResult
+	if err := result.UnmarshalProto(maxVarint); err != nil {
+		t.Fatalf("max varint: %v", err)
+	}
+	if result.CPUTime != -1 {
+		t.Fatalf("cpu_time = %d, want -1", result.CPUTime)
+	}
+}
+
+// judgeProtoFile mirrors the messages of proto/judge.proto, so that the
+// reference protobuf implementation can encode and decode them the way
+// protoc-generated code does.
+func judgeProtoFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	type field struct {
+		name     string
+		number   int32
+		kind     descriptorpb.FieldDescriptorProto_Type
+		repeated bool
+		message  string
+		optional bool
+	}
+	var (
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		i32 = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		bln = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		dbl = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	messages := []struct {
+		name   string
+		fields []field
+	}{
+		{"JudgeJob", []field{
+			{"version", 1, i32, false, "", false},
+			{"kind", 2, str, false, "", false},
+			{"submission_id", 3, i64, false, "", false},
+			{"run_id", 4, i64, false, "", false},
+			{"problem_id", 5, i64, false, "", false},
+			{"user_id", 6, i64, false, "", false},
+			{"language", 7, str, false, "", false},
+			{"code", 8, str, false, "", false},
+			{"stdin", 9, str, false, "", false},
+			{"time_limit", 10, i64, false, "", false},
+			{"memory_limit", 11, i64, false, "", false},
+			{"bundle_version", 12, i64, false, "", false},
+			{"object_key", 13, str, false, "", false},
+			{"solution", 14, str, false, "", false},
+			{"expected", 15, i32, false, "", true},
+			{"validator", 16, str, false, "", false},
+			{"manifest", 17, msg, false, "GenerationManifest", false},
+			{"limit_overrides", 18, msg, true, "LimitOverride", false},
+			{"submitted_at", 19, i64, false, "", false},
+			{"contest", 20, bln, false, "", false},
+		}},
+		{"LimitOverride", []field{
+			{"group", 1, i64, false, "", false},
+			{"testcase", 2, i64, false, "", false},
+			{"time_limit", 3, i64, false, "", false},
+			{"memory_limit", 4, i64, false, "", false},
+		}},
+		{"GenerationManifest", []field{
+			{"tests", 1, msg, true, "GeneratedTestcase", false},
+		}},
+		{"GeneratedTestcase", []field{
+			{"group", 1, i64, false, "", false},
+			{"test", 2, i64, false, "", false},
+			{"generator", 3, str, false, "", false},
+			{"args", 4, str, true, "", false},
+		}},
+		{"JudgeResult", []field{
+			{"version", 1, i32, false, "", false},
+			{"kind", 2, str, false, "", false},
+			{"submission_id", 3, i64, false, "", false},
+			{"run_id", 4, i64, false, "", false},
+			{"problem_id", 5, i64, false, "", false},
+			{"bundle_version", 6, i64, false, "", false},
+			{"verdict", 7, i32, false, "", false},
+			{"message", 8, str, false, "", false},
+			{"cpu_time", 9, i64, false, "", false},
+			{"memory", 10, i64, false, "", false},
+			{"stdout", 11, str, false, "", false},
+			{"stderr", 12, str, false, "", false},
+			{"testcase_results", 13, msg, true, "TestcaseResult", false},
+			{"solution", 14, str, false, "", false},
+			{"input_errors", 15, msg, true, "InputError", false},
+			{"compile_stdout", 16, str, false, "", false},
+			{"compile_stderr", 17, str, false, "", false},
+			{"submitted_at", 18, i64, false, "", false},
+			{"contest", 19, bln, false, "", false},
+			{"judged_at", 20, i64, false, "", false},
+		}},
+		{"TestcaseResult", []field{
+			{"submission_id", 1, i64, false, "", false},
+			{"testcase_id", 2, i64, false, "", false},
+			{"verdict", 3, i32, false, "", false},
+			{"cpu_time", 4, i64, false, "", false},
+			{"memory", 5, i64, false, "", false},
+			{"input", 6, str, false, "", false},
+			{"expected_output", 7, str, false, "", false},
+			{"actual_output", 8, str, false, "", false},
+			{"error_message", 9, str, false, "", false},
+			{"checker_score", 10, dbl, false, "", false},
+			{"checker_message", 11, str, false, "", false},
+		}},
+		{"InputError", []field{
+			{"file", 1, str, false, "", false},
+			{"message", 2, str, false, "", false},
+		}},
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("judge.proto"),
+		Package: proto.String("jjudge.judge.v1"),
+		Syntax:  proto.String("proto3"),
+	}
+	for _, m := range messages {
+		message := &descriptorpb.DescriptorProto{Name: proto.String(m.name)}
+		for _, f := range m.fields {
+			label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+			if f.repeated {
+				label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+			}
+			fd := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(f.name),
+				Number:   proto.Int32(f.number),
+				Label:    label.Enum(),
+				Type:     f.kind.Enum(),
+				JsonName: proto.String(f.name),
+			}
+			if f.message != "" {
+				fd.TypeName = proto.String(".jjudge.judge.v1." + f.message)
+			}
+			if f.optional {
+				fd.Proto3Optional = proto.Bool(true)
+				fd.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+				message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.name)})
+			}
+			message.Field = append(message.Field, fd)
+		}
+		file.MessageType = append(file.MessageType, message)
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatalf("build judge.proto descriptor: %v", err)
+	}
+	return fd
+}
+
+// protoMessage builds a dynamic message of the named type from values
+// keyed by field name; nested messages are given as maps and repeated
+// fields as slices.
+func protoMessage(t *testing.T, desc protoreflect.MessageDescriptor, values map[string]any) *dynamicpb.Message {
+	t.Helper()
+	m := dynamicpb.NewMessage(desc)
+	for name, value := range values {
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			t.Fatalf("%s has no field %s", desc.FullName(), name)
+		}
+		if fd.IsList() {
+			list := m.Mutable(fd).List()
+			switch items := value.(type) {
+			case []map[string]any:
+				for _, item := range items {
+					list.Append(protoreflect.ValueOfMessage(protoMessage(t, fd.Message(), item)))
+				}
+			case []string:
+				for _, item := range items {
+					list.Append(protoreflect.ValueOfString(item))
+				}
+			default:
+				t.Fatalf("%s: unsupported list %T", name, value)
+			}
+			continue
+		}
+		if nested, ok := value.(map[string]any); ok {
+			m.Set(fd, protoreflect.ValueOfMessage(protoMessage(t, fd.Message(), nested)))
+			continue
+		}
+		m.Set(fd, protoreflect.ValueOf(value))
+	}
+	return m
+}
+
+// assertNoUnknownFields fails if the reference decoder did not recognize
+// every field of m, which would mean a field number or wire type differs
+// from judge.proto.
+func assertNoUnknownFields(t *testing.T, m protoreflect.Message) {
+	t.Helper()
+	if unknown := m.GetUnknown(); len(unknown) > 0 {
+		t.Fatalf("%s: unknown fields %x", m.Descriptor().FullName(), unknown)
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			for i := range v.List().Len() {
+				assertNoUnknownFields(t, v.List().Get(i).Message())
+			}
+		case fd.Message() != nil:
+			assertNoUnknownFields(t, v.Message())
+		}
+		return true
+	})
+}
+
+func TestJudgeJobProtoInterop(t *testing.T) {
+	desc := judgeProtoFile(t).Messages().ByName("JudgeJob")
+	reference := protoMessage(t, desc, map[string]any{
+		"version":        int32(JudgeProtocolVersion),
+		"kind":           "generation",
+		"submission_id":  int64(42),
+		"run_id":         int64(1 << 40),
+		"problem_id":     int64(7),
+		"user_id":        int64(3),
+		"language":       "cpp17",
+		"code":           "int main() {}",
+		"stdin":          "1 2\n",
+		"time_limit":     int64(2000),
+		"memory_limit":   int64(256 << 20),
+		"bundle_version": int64(4),
+		"object_key":     "bundles/7/4.tar.gz",
+		"solution":       "solutions/main.cpp",
+		"expected":       int32(VerdictPending),
+		"validator":      "validator.cpp",
+		"manifest": map[string]any{"tests": []map[string]any{
+			{"group": int64(1), "test": int64(2), "generator": "gen.py", "args": []string{"-n", "10"}},
+		}},
+		"limit_overrides": []map[string]any{
+			{"group": int64(1), "testcase": int64(2), "time_limit": int64(5000), "memory_limit": int64(-1)},
+		},
+		"submitted_at": int64(1_760_000_000_123),
+		"contest":      true,
+	})
+	want := JudgeJob{
+		Version:       JudgeProtocolVersion,
+		Kind:          JudgeJobGeneration,
+		SubmissionID:  42,
+		RunID:         1 << 40,
+		ProblemID:     7,
+		UserID:        3,
+		Language:      "cpp17",
+		Code:          "int main() {}",
+		Stdin:         "1 2\n",
+		TimeLimit:     2000,
+		MemoryLimit:   256 << 20,
+		BundleVersion: 4,
+		ObjectKey:     "bundles/7/4.tar.gz",
+		Solution:      "solutions/main.cpp",
+		Expected:      verdictPtr(VerdictPending),
+		Validator:     "validator.cpp",
+		Manifest: &GenerationManifest{Tests: []GeneratedTestcase{
+			{Group: 1, Test: 2, Generator: "gen.py", Args: []string{"-n", "10"}},
+		}},
+		LimitOverrides: []LimitOverride{{Group: 1, Testcase: 2, TimeLimit: 5000, MemoryLimit: -1}},
+		SubmittedAt:    1_760_000_000_123,
+		Contest:        true,
+	}
+
+	t.Run("decode reference bytes", func(t *testing.T) {
+		data, err := proto.Marshal(reference)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var got JudgeJob
+		if err := got.UnmarshalProto(data); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatch\n got %+v\nwant %+v", got, want)
+		}
+	})
+
+	t.Run("reference decodes ours", func(t *testing.T) {
+		got := dynamicpb.NewMessage(desc)
+		if err := proto.Unmarshal(want.MarshalProto(), got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		assertNoUnknownFields(t, got)
+		if !proto.Equal(got, reference) {
+			t.Fatalf("mismatch\n got %v\nwant %v", got, reference)
+		}
+	})
+}
+
+func TestJudgeResultProtoInterop(t *testing.T) {
+	desc := judgeProtoFile(t).Messages().ByName("JudgeResult")
+	reference := protoMessage(t, desc, map[string]any{
+		"version":        int32(JudgeProtocolVersion),
+		"kind":           "submission",
+		"submission_id":  int64(42),
+		"run_id":         int64(9),
+		"problem_id":     int64(7),
+		"bundle_version": int64(4),
+		"verdict":        int32(VerdictWrongAnswer),
+		"message":        "wrong answer on test 2",
+		"cpu_time":       int64(1234),
+		"memory":         int64(56 << 20),
+		"stdout":         "out",
+		"stderr":         "err",
+		"testcase_results": []map[string]any{
+			{"submission_id": int64(42), "testcase_id": int64(1), "verdict": int32(VerdictAccepted)},
+			{
+				"submission_id":   int64(42),
+				"testcase_id":     int64(2),
+				"verdict":         int32(VerdictWrongAnswer),
+				"cpu_time":        int64(20),
+				"memory":          int64(2 << 20),
+				"input":           "1 2\n",
+				"expected_output": "3\n",
+				"actual_output":   "4\n",
+				"error_message":   "exit status 0",
+				"checker_score":   0.25,
+				"checker_message": "partially correct",
+			},
+		},
+		"solution":       "solutions/main.cpp",
+		"input_errors":   []map[string]any{{"file": "1_3.in", "message": "n out of range"}},
+		"compile_stdout": "ok",
+		"compile_stderr": "warning",
+		"submitted_at":   int64(1_760_000_000_123),
+		"contest":        true,
+		"judged_at":      int64(1_760_000_004_567),
+	})
+	want := JudgeResult{
+		Version:       JudgeProtocolVersion,
+		Kind:          JudgeJobSubmission,
+		SubmissionID:  42,
+		RunID:         9,
+		ProblemID:     7,
+		BundleVersion: 4,
+		Verdict:       VerdictWrongAnswer,
+		Message:       "wrong answer on test 2",
+		CPUTime:       1234,
+		Memory:        56 << 20,
+		Stdout:        "out",
+		Stderr:        "err",
+		TestcaseResults: []TestcaseResult{
+			{SubmissionID: 42, TestcaseID: 1, Verdict: VerdictAccepted},
+			{
+				SubmissionID:   42,
+				TestcaseID:     2,
+				Verdict:        VerdictWrongAnswer,
+				CPUTime:        20,
+				Memory:         2 << 20,
+				Input:          "1 2\n",
+				ExpectedOutput: "3\n",
+				ActualOutput:   "4\n",
+				ErrorMessage:   "exit status 0",
+				CheckerScore:   0.25,
+				CheckerMessage: "partially correct",
+			},
+		},
+		Solution:      "solutions/main.cpp",
+		InputErrors:   []InputError{{File: "1_3.in", Message: "n out of range"}},
+		CompileStdout: "ok",
+		CompileStderr: "warning",
+		SubmittedAt:   1_760_000_000_123,
+		Contest:       true,
+		JudgedAt:      1_760_000_004_567,
+	}
+
+	t.Run("decode reference bytes", func(t *testing.T) {
+		data, err := proto.Marshal(reference)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var got JudgeResult
+		if err := got.UnmarshalProto(data); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatch\n got %+v\nwant %+v", got, want)
+		}
+	})
+
+	t.Run("reference decodes ours", func(t *testing.T) {
+		got := dynamicpb.NewMessage(desc)
+		if err := proto.Unmarshal(want.MarshalProto(), got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		assertNoUnknownFields(t, got)
+		if !proto.Equal(got, reference) {
+			t.Fatalf("mismatch\n got %v\nwant %v", got, reference)
+		}
+	})
+
+	t.Run("negative int32", func(t *testing.T) {
+		// int32 fields are sign-extended to ten bytes on the wire.
+		m := protoMessage(t, desc, map[string]any{"verdict": int32(-1)})
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		var got JudgeResult
+		if err := got.UnmarshalProto(data); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got.Verdict != -1 {
+			t.Fatalf("verdict = %d, want -1", got.Verdict)
+		}
+		back := dynamicpb.NewMessage(desc)
+		if err := proto.Unmarshal(got.MarshalProto(), back); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if !proto.Equal(back, m) {
+			t.Fatalf("mismatch\n got %v\nwant %v", back, m)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// JudgeWorker is a judge node registered with the server. Workers of the
+// same pool share capabilities and consume the same job channel.
+type JudgeWorker struct {
+	// ID uniquely identifies the worker, typically its hostname.
+	ID string `json:"id" db:"id"`
+
+	// Pool names the group of workers the node belongs to, e.g. "java".
+	Pool string `json:"pool" db:"pool"`
+
+	// Languages lists the language IDs the worker can compile and run.
+	Languages []string `json:"languages" db:"languages"`
+
+	// Arch is the CPU architecture of the worker, e.g. "amd64".
+	Arch string `json:"arch" db:"arch"`
+
+	// Channel is the job channel the worker's pool consumes.
+	Channel string `json:"channel" db:"-"`
+
+	// RegisteredAt is the timestamp of the worker's first registration.
+	RegisteredAt time.Time `json:"registered_at" db:"registered_at"`
+
+	// LastSeenAt is the timestamp of the worker's latest heartbeat.
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
@@ -0,0 +1,26 @@
+package types
+
+// LeaderboardPeriodAll is the period key for all-time standings.
+const LeaderboardPeriodAll = "all"
+
+// LeaderboardEntry is a user's position on the global leaderboard for a
+// period.
+type LeaderboardEntry struct {
+	// Rank is the 1-based position; users with equal totals share a rank.
+	Rank int `json:"rank" db:"rank"`
+
+	// UserID identifies the ranked user.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Username is the ranked user's login name.
+	Username string `json:"username" db:"username"`
+
+	// Name is the ranked user's display name.
+	Name string `json:"name" db:"name"`
+
+	// Solved is the number of distinct problems accepted in the period.
+	Solved int `json:"solved" db:"solved"`
+
+	// Score is the sum of the user's best score per problem in the period.
+	Score int64 `json:"score" db:"score"`
+}
@@ -0,0 +1,83 @@
+package types
+
+import "time"
+
+// Supported notification types.
+const (
+	// NotificationSubmissionJudged tells a user that one of their
+	// submissions received its final verdict.
+	NotificationSubmissionJudged = "submission.judged"
+
+	// NotificationCommentReply tells a user that someone replied to their
+	// comment on a problem.
+	NotificationCommentReply = "comment.reply"
+
+	// NotificationAppealResolved tells a user that an admin resolved their
+	// appeal against a verdict.
+	NotificationAppealResolved = "appeal.resolved"
+)
+
+// Notification is a message for one user, shown in their notification
+// center until they mark it read.
+type Notification struct {
+	// ID is the unique identifier of the notification.
+	ID int64 `json:"id" db:"id"`
+
+	// UserID identifies the user the notification is for.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Type identifies what the notification is about (e.g.,
+	// "submission.judged").
+	Type string `json:"type" db:"type"`
+
+	// Title is a one-line summary.
+	Title string `json:"title" db:"title"`
+
+	// Body is the full notification text.
+	Body string `json:"body" db:"body"`
+
+	// SubjectID identifies the entity the notification is about, such as
+	// the submission ID, or 0 if there is none.
+	SubjectID int64 `json:"subject_id" db:"subject_id"`
+
+	// ReadAt is when the user marked the notification read, or nil while
+	// it is unread.
+	ReadAt *time.Time `json:"read_at,omitempty" db:"read_at"`
+
+	// CreatedAt is the timestamp at which the notification was created.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Verdict digest frequencies.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// NotificationPreferences are a user's choices about which notifications
+// reach them by email. Notifications always appear in the notification
+// center.
+type NotificationPreferences struct {
+	// UserID identifies the user the preferences belong to.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// EmailNotifications emails notifications as they are created, for
+	// the notification types the server emails.
+	EmailNotifications bool `json:"email_notifications" db:"email_notifications"`
+
+	// VerdictDigest is how often judged submissions are summarized in one
+	// email: DigestOff, DigestDaily or DigestWeekly. While a digest is
+	// on, judged submissions are not emailed one by one.
+	VerdictDigest string `json:"verdict_digest" db:"verdict_digest"`
+
+	// VerdictDigestSentAt is where the next digest starts: the end of the
+	// period the last one covered, or when the digest was turned on.
+	VerdictDigestSentAt *time.Time `json:"verdict_digest_sent_at,omitempty" db:"verdict_digest_sent_at"`
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who
+// has not set any: nothing is emailed.
+func DefaultNotificationPreferences(userID int) NotificationPreferences {
+	return NotificationPreferences{UserID: userID, VerdictDigest: DigestOff}
+}
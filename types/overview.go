@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// Overview is a snapshot of the key operational numbers for the admin
+// dashboard.
+type Overview struct {
+	// Users is the number of registered users.
+	Users int `json:"users"`
+
+	// Problems is the number of problems, published or not.
+	Problems int `json:"problems"`
+
+	// PublishedProblems is the number of problems open to submissions.
+	PublishedProblems int `json:"published_problems"`
+
+	// SubmissionsToday is the number of submissions since midnight UTC.
+	SubmissionsToday int `json:"submissions_today"`
+
+	// Queue is the judge backlog and turnaround. Its AvgTurnaroundSeconds
+	// is the average judge latency.
+	Queue JudgeQueueStats `json:"queue"`
+
+	// SystemErrors is the number of submissions that ended in a system
+	// error within the queue stats window.
+	SystemErrors int `json:"system_errors"`
+
+	// SystemErrorRate is SystemErrors as a fraction of the submissions
+	// completed within the window, or 0 when none were.
+	SystemErrorRate float64 `json:"system_error_rate"`
+
+	// FailedJobs is the number of background jobs given up on within the
+	// last day.
+	FailedJobs int `json:"failed_jobs"`
+
+	// GeneratedAt is when the snapshot was taken.
+	GeneratedAt time.Time `json:"generated_at"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// Post is a news post written by an administrator, shown on the judge's
+// homepage.
+type Post struct {
+	// ID is the unique identifier of the post.
+	ID int `json:"id" db:"id"`
+
+	// Title is the headline of the post.
+	Title string `json:"title" db:"title"`
+
+	// Body is the post text in Markdown.
+	Body string `json:"body" db:"body"`
+
+	// Tags categorize the post (e.g., "release", "contest").
+	Tags []string `json:"tags" db:"tags"`
+
+	// Pinned keeps the post at the top of listings.
+	Pinned bool `json:"pinned" db:"pinned"`
+
+	// AuthorID identifies the administrator who wrote the post.
+	AuthorID int `json:"author_id" db:"author_id"`
+
+	// PublishedAt is when the post is listed from. A nil value marks a
+	// draft that only administrators can see.
+	PublishedAt *time.Time `json:"published_at,omitempty" db:"published_at"`
+
+	// CreatedAt is the timestamp at which the post was written.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is the timestamp of the most recent edit.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IsPublished reports whether the post is listed at the given time.
+func (p Post) IsPublished(now time.Time) bool {
+	return p.PublishedAt != nil && !p.PublishedAt.After(now)
+}
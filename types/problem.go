@@ -16,6 +16,10 @@ type Problem struct {
 	// input/output specifications and examples.
 	Description string `json:"description" db:"description"`
 
+	// Type determines how submissions are provided and judged.
+	// Defaults to ProblemTypeBatch.
+	Type ProblemType `json:"type" db:"type"`
+
 	// Difficulty indicates the relative difficulty level of the problem.
 	// Uses Codeforces difficulty scale (800 to 3500).
 	Difficulty int `json:"difficulty" db:"difficulty"`
@@ -37,13 +41,139 @@ type Problem struct {
 	// categorization, filtering, and search.
 	Tags []string `json:"tags" db:"tags"`
 
+	// ValidationStatus reports whether the reference solutions shipped in
+	// the latest testcase bundle behave as expected.
+	ValidationStatus ValidationStatus `json:"validation_status" db:"validation_status"`
+
+	// ReviewStatus is the state of the problem in the review workflow.
+	ReviewStatus ReviewStatus `json:"review_status" db:"review_status"`
+
+	// Published reports whether the problem is listed and open to
+	// submissions. Only approved problems may be published.
+	Published bool `json:"published" db:"published"`
+
+	// SolutionVisibility controls who besides their authors may view the
+	// code of accepted submissions. Defaults to SolutionsNever.
+	SolutionVisibility SolutionVisibility `json:"solution_visibility" db:"solution_visibility"`
+
+	// OwnerID identifies the setter who created the problem. It is zero for
+	// problems without an owner, which only admins may edit.
+	OwnerID int `json:"owner_id,omitempty" db:"owner_id"`
+
 	// CreatedAt is the timestamp at which the problem was created.
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 
 	// UpdatedAt is the timestamp of the most recent update to the problem.
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+
+	// LanguageLimits are the time and memory limits submissions in each
+	// language actually run with. They are only filled in when a single
+	// problem is fetched.
+	LanguageLimits []LanguageLimit `json:"language_limits,omitempty" db:"-"`
+}
+
+// LanguageLimit is a problem's limits after applying one language's
+// multipliers.
+type LanguageLimit struct {
+	// Language is the identifier of the language.
+	Language string `json:"language"`
+
+	// TimeLimit is the effective time limit, expressed in milliseconds.
+	TimeLimit int64 `json:"time_limit"`
+
+	// MemoryLimit is the effective memory limit, expressed in bytes.
+	MemoryLimit int64 `json:"memory_limit"`
+}
+
+// ProblemSummary is the shape of a problem in lists: enough to pick a
+// problem without its statement or testcase bundle.
+type ProblemSummary struct {
+	// ID is the unique identifier of the problem.
+	ID int `json:"id" db:"id"`
+
+	// Title is the human-readable name of the problem.
+	Title string `json:"title" db:"title"`
+
+	// Type determines how submissions are provided and judged.
+	Type ProblemType `json:"type" db:"type"`
+
+	// Difficulty is the Codeforces-scale difficulty rating.
+	Difficulty int `json:"difficulty" db:"difficulty"`
+
+	// Tags are the problem's labels.
+	Tags []string `json:"tags" db:"tags"`
+
+	// SolvedCount is the number of users with an accepted submission.
+	SolvedCount int `json:"solved_count" db:"solved_count"`
+
+	// AttemptedCount is the number of users with a judged submission.
+	AttemptedCount int `json:"attempted_count" db:"attempted_count"`
+
+	// Description is the problem statement, only included on request.
+	Description string `json:"description,omitempty" db:"description"`
 }
 
+// ProblemPatch is a partial update to a problem. Nil fields are left
+// unchanged.
+type ProblemPatch struct {
+	// Title replaces the problem title.
+	Title *string `json:"title,omitempty"`
+
+	// Description replaces the problem statement.
+	Description *string `json:"description,omitempty"`
+
+	// Difficulty replaces the difficulty rating.
+	Difficulty *int `json:"difficulty,omitempty"`
+
+	// TimeLimit replaces the time limit, expressed in milliseconds.
+	TimeLimit *int64 `json:"time_limit,omitempty"`
+
+	// MemoryLimit replaces the memory limit, expressed in bytes.
+	MemoryLimit *int64 `json:"memory_limit,omitempty"`
+
+	// Tags replaces the whole tag list.
+	Tags *[]string `json:"tags,omitempty"`
+
+	// Published publishes or unpublishes the problem. Only approved
+	// problems may be published.
+	Published *bool `json:"published,omitempty"`
+
+	// SolutionVisibility replaces the solution viewing policy.
+	SolutionVisibility *SolutionVisibility `json:"solution_visibility,omitempty"`
+}
+
+// SolutionVisibility controls who may view the code of a problem's
+// accepted submissions besides their authors and admins.
+type SolutionVisibility string
+
+// Solution viewing policies.
+const (
+	// SolutionsNever keeps accepted code private.
+	SolutionsNever SolutionVisibility = "never"
+
+	// SolutionsAfterSolving shows accepted code to users who have solved
+	// the problem themselves.
+	SolutionsAfterSolving SolutionVisibility = "after_solving"
+)
+
+// ProblemType determines how submissions to a problem are provided and judged.
+type ProblemType string
+
+// Supported problem types.
+const (
+	// ProblemTypeBatch problems take a full program that reads each test
+	// case input and writes its output.
+	ProblemTypeBatch ProblemType = "batch"
+
+	// ProblemTypeOutputOnly problems take the answers directly: the user
+	// downloads the inputs and uploads one output per test case.
+	ProblemTypeOutputOnly ProblemType = "output_only"
+
+	// ProblemTypeGrader problems take a set of functions that are linked
+	// against a grader shipped in the testcase bundle.
+	ProblemTypeGrader ProblemType = "grader"
+)
+
 // TestcaseBundle represents a versioned collection of test case groups
 // used to evaluate submissions for a problem.
 //
@@ -65,6 +195,150 @@ type TestcaseBundle struct {
 
 	// Version indicates the version number of this testcase bundle.
 	Version int `json:"version" db:"version"`
+
+	// Corrupted reports whether the stored bundle was found not to match
+	// SHA256 when it was last verified.
+	Corrupted bool `json:"corrupted,omitempty" db:"corrupted"`
+
+	// GraderFiles lists the grader sources and headers shipped under
+	// grader/ in the bundle. It is only set for grader problems.
+	GraderFiles []string `json:"grader_files,omitempty" db:"grader_files"`
+
+	// Solutions lists the reference solutions shipped under solutions/ in
+	// the bundle, used to validate the testcases.
+	Solutions []ReferenceSolution `json:"solutions,omitempty" db:"solutions"`
+
+	// Validator is the bundle-relative path of the input validator, e.g.
+	// "validator/validator.cpp", if the bundle ships one.
+	Validator string `json:"validator,omitempty" db:"validator"`
+
+	// Generators lists the testcase generator sources shipped under
+	// generators/ in the bundle.
+	Generators []string `json:"generators,omitempty" db:"generators"`
+
+	// Generation lists the testcases still to be produced by generators.
+	// It is set until a judge worker materializes them into a new bundle
+	// version.
+	Generation *GenerationManifest `json:"generation,omitempty" db:"generation"`
+}
+
+// InputValidation is the result of running a bundle's input validator
+// against every testcase input of one bundle version.
+type InputValidation struct {
+	// ProblemID identifies the problem the bundle belongs to.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// BundleVersion is the testcase bundle version that was checked.
+	BundleVersion int `json:"bundle_version" db:"bundle_version"`
+
+	// Validator is the bundle-relative path of the validator that ran.
+	Validator string `json:"validator" db:"validator"`
+
+	// Status is ValidationPending until a worker reports, then
+	// ValidationPassed or ValidationFailed.
+	Status ValidationStatus `json:"status" db:"status"`
+
+	// Errors lists the inputs the validator rejected.
+	Errors []InputError `json:"errors" db:"errors"`
+
+	// UpdatedAt is the timestamp of the latest change to the result.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// InputError is a testcase input rejected by the input validator.
+type InputError struct {
+	// File is the name of the rejected input, e.g. "1_3.in".
+	File string `json:"file"`
+
+	// Message is the validator's explanation, such as the violated
+	// constraint.
+	Message string `json:"message"`
+}
+
+// GenerationManifest describes testcases produced by generator programs
+// instead of being shipped as files. It is read from
+// generators/manifest.json in the bundle.
+type GenerationManifest struct {
+	// Tests lists the testcases to generate.
+	Tests []GeneratedTestcase `json:"tests"`
+}
+
+// GeneratedTestcase is a testcase whose input is the standard output of a
+// generator run with the given arguments. Its expected output is produced
+// by the bundle's accepted reference solution.
+type GeneratedTestcase struct {
+	// Group is the OrderID of the testcase group.
+	Group int `json:"group"`
+
+	// Test is the OrderID of the testcase within its group.
+	Test int `json:"test"`
+
+	// Generator is the file name of the generator under generators/.
+	Generator string `json:"generator"`
+
+	// Args are the command-line arguments passed to the generator.
+	Args []string `json:"args,omitempty"`
+}
+
+// ReferenceSolution is a solution shipped with a testcase bundle together
+// with the verdict it is expected to receive.
+type ReferenceSolution struct {
+	// File is the bundle-relative path of the source, e.g.
+	// "solutions/accepted.cpp".
+	File string `json:"file" db:"file"`
+
+	// Language is the identifier of the solution's language.
+	Language string `json:"language" db:"language"`
+
+	// Expected is the verdict the solution must receive for the bundle to
+	// be considered valid.
+	Expected Verdict `json:"expected" db:"expected"`
+}
+
+// ValidationStatus is the outcome of running a problem's reference solutions.
+type ValidationStatus string
+
+// Supported validation statuses.
+const (
+	// ValidationNone means the bundle ships no reference solutions.
+	ValidationNone ValidationStatus = "none"
+
+	// ValidationPending means some reference solutions have not been
+	// judged yet.
+	ValidationPending ValidationStatus = "pending"
+
+	// ValidationPassed means every reference solution received its
+	// expected verdict.
+	ValidationPassed ValidationStatus = "validated"
+
+	// ValidationFailed means at least one reference solution received an
+	// unexpected verdict.
+	ValidationFailed ValidationStatus = "failed"
+)
+
+// SolutionValidation is the result of judging one reference solution
+// against one testcase bundle version.
+type SolutionValidation struct {
+	// ProblemID identifies the problem the solution belongs to.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// BundleVersion is the testcase bundle version the solution was
+	// judged against.
+	BundleVersion int `json:"bundle_version" db:"bundle_version"`
+
+	// ReferenceSolution describes the solution and its expected verdict.
+	ReferenceSolution
+
+	// Verdict is the verdict actually received, VerdictPending until a
+	// worker reports it.
+	Verdict Verdict `json:"verdict" db:"verdict"`
+
+	// Message contains details reported by the worker, such as the first
+	// failing testcase.
+	Message string `json:"message" db:"message"`
+
+	// UpdatedAt is the timestamp of the latest change to the result.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // TestcaseGroup represents a logical grouping of test cases within a problem.
@@ -90,8 +364,56 @@ type TestcaseGroup struct {
 	// Points is the number of points awarded if all test cases in this
 	// group pass successfully.
 	Points int `json:"points" db:"points"`
+
+	// ScoringPolicy controls how Points are awarded. An empty value means
+	// ScoringAllOrNothing.
+	ScoringPolicy ScoringPolicy `json:"scoring_policy,omitempty" db:"scoring_policy"`
+
+	// Dependencies lists the OrderIDs of groups that must pass completely
+	// before this group can score any points.
+	Dependencies []int `json:"dependencies,omitempty" db:"dependencies"`
+
+	// TimeLimit overrides the problem's time limit for every test case in
+	// this group, expressed in milliseconds. Zero keeps the problem's.
+	TimeLimit int64 `json:"time_limit,omitempty" db:"time_limit"`
+
+	// MemoryLimit overrides the problem's memory limit for every test case
+	// in this group, expressed in bytes. Zero keeps the problem's.
+	MemoryLimit int64 `json:"memory_limit,omitempty" db:"memory_limit"`
+
+	// TestcaseLimits overrides the limits of individual test cases of this
+	// group, taking precedence over the group's own limits.
+	TestcaseLimits []TestcaseLimit `json:"testcase_limits,omitempty" db:"testcase_limits"`
+}
+
+// TestcaseLimit overrides the limits of one test case.
+type TestcaseLimit struct {
+	// OrderID identifies the test case within its group.
+	OrderID int `json:"order_id"`
+
+	// TimeLimit is the test case's time limit, expressed in milliseconds.
+	// Zero keeps the group's or problem's.
+	TimeLimit int64 `json:"time_limit,omitempty"`
+
+	// MemoryLimit is the test case's memory limit, expressed in bytes.
+	// Zero keeps the group's or problem's.
+	MemoryLimit int64 `json:"memory_limit,omitempty"`
 }
 
+// ScoringPolicy determines how a testcase group's points are awarded.
+type ScoringPolicy string
+
+// Supported scoring policies.
+const (
+	// ScoringAllOrNothing awards the group's points only when every test
+	// case in the group passes.
+	ScoringAllOrNothing ScoringPolicy = "all_or_nothing"
+
+	// ScoringProportional awards points in proportion to the fraction of
+	// passed test cases, rounded down.
+	ScoringProportional ScoringPolicy = "proportional"
+)
+
 // Testcase represents a single input/output pair used to evaluate a submission.
 type Testcase struct {
 	// ID is the unique identifier of the test case.
@@ -112,4 +434,13 @@ type Testcase struct {
 	// IsHidden indicates whether this test case is hidden from users.
 	// Hidden test cases are typically used to prevent hard-coded solutions.
 	IsHidden bool `json:"is_hidden" db:"is_hidden"`
+
+	// TimeLimit is the effective time limit override of this test case,
+	// resolved from its group's configuration, expressed in milliseconds.
+	// Zero means the problem's time limit applies.
+	TimeLimit int64 `json:"time_limit,omitempty" db:"time_limit"`
+
+	// MemoryLimit is the effective memory limit override of this test
+	// case, expressed in bytes. Zero means the problem's limit applies.
+	MemoryLimit int64 `json:"memory_limit,omitempty" db:"memory_limit"`
 }
@@ -0,0 +1,56 @@
+package types
+
+import "time"
+
+// ProblemComment is a comment in a problem's public discussion. Comments
+// without a parent start a thread; replies reference the comment they
+// answer.
+type ProblemComment struct {
+	// ID is the unique identifier of the comment.
+	ID int64 `json:"id" db:"id"`
+
+	// ProblemID identifies the problem under discussion.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// RootID identifies the comment that started the thread, or is nil
+	// for a comment that starts one.
+	RootID *int64 `json:"root_id,omitempty" db:"root_id"`
+
+	// ParentID identifies the comment this one replies to, if any.
+	ParentID *int64 `json:"parent_id,omitempty" db:"parent_id"`
+
+	// UserID identifies the author.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Username is the author's login name.
+	Username string `json:"username" db:"username"`
+
+	// Body is the text of the comment. It is empty when Redacted is set.
+	Body string `json:"body" db:"body"`
+
+	// Spoiler marks a comment that gives away the solution. Its body is
+	// only shown to users who solved the problem.
+	Spoiler bool `json:"spoiler" db:"spoiler"`
+
+	// Redacted reports that the body was withheld from the viewer: the
+	// comment is a spoiler they may not read yet, or it was hidden or
+	// deleted.
+	Redacted bool `json:"redacted,omitempty" db:"-"`
+
+	// Hidden reports that a moderator hid the comment.
+	Hidden bool `json:"hidden" db:"-"`
+
+	// Deleted reports that the comment was deleted. Deleted comments stay
+	// in their thread, without a body, so that replies keep their place.
+	Deleted bool `json:"deleted" db:"-"`
+
+	// EditedAt is the timestamp of the most recent edit, if any.
+	EditedAt *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+
+	// CreatedAt is the timestamp when the comment was posted.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Replies are all replies in the thread, oldest first, on comments
+	// that start one.
+	Replies []ProblemComment `json:"replies,omitempty" db:"-"`
+}
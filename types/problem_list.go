@@ -0,0 +1,58 @@
+package types
+
+import "time"
+
+// ProblemList is an ordered collection of problems, such as a training
+// sheet.
+type ProblemList struct {
+	// ID is the unique identifier of the list.
+	ID int `json:"id" db:"id"`
+
+	// Title is the name of the list.
+	Title string `json:"title" db:"title"`
+
+	// Description explains what the list is for.
+	Description string `json:"description" db:"description"`
+
+	// OwnerID identifies the user who created the list.
+	OwnerID int `json:"owner_id" db:"owner_id"`
+
+	// ProblemCount is the number of problems on the list.
+	ProblemCount int `json:"problem_count" db:"problem_count"`
+
+	// SolvedCount is the number of problems on the list the requester has
+	// solved. It is zero for anonymous requests.
+	SolvedCount int `json:"solved_count" db:"solved_count"`
+
+	// Items are the problems in order. They are only included when a
+	// single list is fetched.
+	Items []ProblemListItem `json:"items,omitempty" db:"-"`
+
+	// CreatedAt is the timestamp at which the list was created.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is the timestamp of the most recent edit.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ProblemListItem is a problem on a list with the requester's progress on
+// it.
+type ProblemListItem struct {
+	// Position is the 1-based place of the problem on the list.
+	Position int `json:"position" db:"position"`
+
+	// ProblemID identifies the problem.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// Title is the human-readable name of the problem.
+	Title string `json:"title" db:"title"`
+
+	// Difficulty is the Codeforces-scale difficulty rating.
+	Difficulty int `json:"difficulty" db:"difficulty"`
+
+	// Solved reports whether the requester has an accepted submission.
+	Solved bool `json:"solved" db:"solved"`
+
+	// Attempted reports whether the requester has a judged submission.
+	Attempted bool `json:"attempted" db:"attempted"`
+}
@@ -0,0 +1,83 @@
+package types
+
+import "time"
+
+// ReviewStatus is the state of a problem in the review workflow.
+type ReviewStatus string
+
+const (
+	// ReviewDraft means the setter is still preparing the problem.
+	ReviewDraft ReviewStatus = "draft"
+
+	// ReviewInReview means the problem was submitted and awaits a
+	// reviewer's decision.
+	ReviewInReview ReviewStatus = "in_review"
+
+	// ReviewChangesRequested means a reviewer asked for changes; the setter
+	// resubmits the problem once they are made.
+	ReviewChangesRequested ReviewStatus = "changes_requested"
+
+	// ReviewApproved means a reviewer approved the problem, which may now
+	// be published.
+	ReviewApproved ReviewStatus = "approved"
+)
+
+// ReviewDecision is a reviewer's verdict on a submitted problem.
+type ReviewDecision struct {
+	// ID is the unique identifier of the decision.
+	ID int64 `json:"id" db:"id"`
+
+	// ProblemID identifies the reviewed problem.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// ReviewerID identifies the reviewer.
+	ReviewerID int `json:"reviewer_id" db:"reviewer_id"`
+
+	// Decision is ReviewApproved or ReviewChangesRequested.
+	Decision ReviewStatus `json:"decision" db:"decision"`
+
+	// Comment explains the decision.
+	Comment string `json:"comment" db:"comment"`
+
+	// CreatedAt is the timestamp when the decision was made.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReviewTarget is the part of a problem a review comment is about.
+type ReviewTarget string
+
+const (
+	// ReviewTargetGeneral is a comment on the problem as a whole.
+	ReviewTargetGeneral ReviewTarget = "general"
+
+	// ReviewTargetStatement is a comment on the problem statement.
+	ReviewTargetStatement ReviewTarget = "statement"
+
+	// ReviewTargetTests is a comment on the testcases.
+	ReviewTargetTests ReviewTarget = "tests"
+)
+
+// ReviewComment is a comment in a problem's review discussion. Replies
+// reference the comment they answer, forming threads.
+type ReviewComment struct {
+	// ID is the unique identifier of the comment.
+	ID int64 `json:"id" db:"id"`
+
+	// ProblemID identifies the problem under review.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// ParentID identifies the comment this one replies to, if any.
+	ParentID *int64 `json:"parent_id,omitempty" db:"parent_id"`
+
+	// UserID identifies the author.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Target is the part of the problem the comment is about.
+	Target ReviewTarget `json:"target" db:"target"`
+
+	// Body is the text of the comment.
+	Body string `json:"body" db:"body"`
+
+	// CreatedAt is the timestamp when the comment was posted.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
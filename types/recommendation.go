@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// ProblemRecommendation is an unsolved problem suggested to a user
+// because its difficulty is near what they have been solving.
+type ProblemRecommendation struct {
+	// Rank orders recommendations, starting at 1 for the best match.
+	Rank int `json:"rank" db:"rank"`
+
+	// ProblemID identifies the problem.
+	ProblemID int `json:"problem_id" db:"problem_id"`
+
+	// Title is the human-readable name of the problem.
+	Title string `json:"title" db:"title"`
+
+	// Difficulty is the Codeforces-scale difficulty rating.
+	Difficulty int `json:"difficulty" db:"difficulty"`
+
+	// Tags are the problem's labels.
+	Tags []string `json:"tags" db:"tags"`
+}
+
+// Recommendations are the problems suggested to a user next.
+type Recommendations struct {
+	// Items are the recommended problems, best match first.
+	Items []ProblemRecommendation `json:"items"`
+
+	// TargetDifficulty is the difficulty the recommendations were chosen
+	// around, or zero if none were computed yet.
+	TargetDifficulty int `json:"target_difficulty"`
+
+	// ComputedAt is when the recommendations were computed, or nil if
+	// none were computed yet.
+	ComputedAt *time.Time `json:"computed_at,omitempty"`
+}
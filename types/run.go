@@ -0,0 +1,45 @@
+package types
+
+import "time"
+
+// Run is a custom invocation: user code executed against user-provided
+// input, outside of any problem and without grading.
+type Run struct {
+	// ID is the unique identifier of the run.
+	ID int64 `json:"id" db:"id"`
+
+	// UserID identifies the user who started the run.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// Language is the identifier of the programming language used.
+	Language string `json:"language" db:"language"`
+
+	// Code is the source code to execute.
+	Code string `json:"code" db:"code"`
+
+	// Stdin is the input fed to the program.
+	Stdin string `json:"stdin" db:"stdin"`
+
+	// Verdict is the outcome of the run. VerdictAccepted means the
+	// program compiled and exited normally within the limits.
+	Verdict Verdict `json:"verdict" db:"verdict"`
+
+	// Stdout is the program's standard output, possibly truncated.
+	Stdout string `json:"stdout" db:"stdout"`
+
+	// Stderr is the program's standard error or the compiler output,
+	// possibly truncated.
+	Stderr string `json:"stderr" db:"stderr"`
+
+	// CPUTime is the CPU time consumed, expressed in milliseconds.
+	CPUTime int64 `json:"cpu_time" db:"cpu_time"`
+
+	// Memory is the peak memory usage, expressed in bytes.
+	Memory int64 `json:"memory" db:"memory"`
+
+	// CreatedAt is the timestamp when the run was requested.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is the timestamp when the run was last updated.
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+// Session represents an issued access token that is still considered valid.
+// Tokens carry the session ID, so deleting a session revokes its token.
+type Session struct {
+	// ID is the unique identifier of the session, embedded in the token.
+	ID string `json:"id" db:"id"`
+
+	// UserID identifies the account the session belongs to.
+	UserID int `json:"user_id" db:"user_id"`
+
+	// CreatedAt is the timestamp at which the session was started.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// ExpiresAt is the timestamp after which the session is no longer valid.
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+
+	// LastUsedAt is roughly when the session last authenticated a request.
+	// It is refreshed at most once a minute.
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+
+	// UserAgent is the User-Agent header of the login request.
+	UserAgent string `json:"user_agent" db:"user_agent"`
+
+	// IP is the client address of the login request.
+	IP string `json:"ip" db:"ip"`
+}
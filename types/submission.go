@@ -2,6 +2,10 @@ package types
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -17,12 +21,36 @@ type Submission struct {
 	// UserID identifies the user who made the submission.
 	UserID int `json:"user_id" db:"user_id"`
 
-	// Code is the source code submitted by the user.
-	Code string `json:"code" db:"code"`
+	// TenantID identifies the tenant the submission was made in. It is
+	// only loaded by Get, for work that spans tenants such as judging.
+	TenantID int `json:"-" db:"tenant_id"`
+
+	// Code is the source code submitted by the user. It is only loaded
+	// for detail views; summaries carry CodePreview instead.
+	Code string `json:"code,omitempty" db:"code"`
+
+	// CodeKey is the object storage key holding the full source. It is
+	// empty for sources still stored inline in Code.
+	CodeKey string `json:"-" db:"code_key"`
+
+	// CodePreview is the beginning of the source, at most 1024 characters.
+	CodePreview string `json:"code_preview" db:"code_preview"`
+
+	// CodeLength is the size of the full source in bytes.
+	CodeLength int `json:"code_length" db:"code_length"`
+
+	// CodePrunedAt is when the source was removed under the submission
+	// retention policy. The rest of the submission is kept.
+	CodePrunedAt *time.Time `json:"code_pruned_at,omitempty" db:"code_pruned_at"`
 
 	// Language is the identifier of the programming language used.
 	Language string `json:"language" db:"language"`
 
+	// Dialect is the language version the submission was judged with,
+	// such as "gnu++20". It is empty for output-only submissions and for
+	// submissions made before dialects were recorded.
+	Dialect string `json:"dialect,omitempty" db:"dialect"`
+
 	// Verdict is the final outcome of judging the submission.
 	Verdict Verdict `json:"verdict" db:"verdict"`
 
@@ -41,12 +69,25 @@ type Submission struct {
 	// such as compilation errors or system messages.
 	Message string `json:"message" db:"message"`
 
+	// CompileStdout is the compiler's standard output, truncated. It is
+	// only shown to the submission's owner.
+	CompileStdout string `json:"compile_stdout,omitempty" db:"compile_stdout"`
+
+	// CompileStderr is the compiler's standard error, truncated. It is
+	// only shown to the submission's owner.
+	CompileStderr string `json:"compile_stderr,omitempty" db:"compile_stderr"`
+
 	// TestsPassed is the number of test cases successfully passed.
 	TestsPassed int `json:"tests_passed" db:"tests_passed"`
 
 	// TestsTotal is the total number of test cases executed.
 	TestsTotal int `json:"tests_total" db:"tests_total"`
 
+	// SuspicionScore, from 0 to 100, is how much of an expected output the
+	// source embeds as string literals, a sign of hardcoded answers. It is
+	// only shown to admins.
+	SuspicionScore int `json:"suspicion_score,omitempty" db:"suspicion_score"`
+
 	// CreatedAt is the timestamp when the submission was created.
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 
@@ -58,6 +99,83 @@ type Submission struct {
 	TestcaseResults []TestcaseResult `json:"testcase_results" db:"testcase_results"`
 }
 
+// SubmissionPruneResult summarizes a pass of the submission retention
+// policy.
+type SubmissionPruneResult struct {
+	// Cutoff is the creation time before which non-accepted submissions
+	// lose their source.
+	Cutoff time.Time `json:"cutoff"`
+
+	// Submissions is the number of submissions whose source was removed,
+	// or would have been in a dry run.
+	Submissions int `json:"submissions"`
+
+	// Bytes is the total size of those sources.
+	Bytes int64 `json:"bytes"`
+
+	// DryRun reports that nothing was removed.
+	DryRun bool `json:"dry_run"`
+}
+
+// SubmissionStatus is the compact judging state of a submission, returned
+// to clients polling several submissions at once.
+type SubmissionStatus struct {
+	// ID identifies the submission.
+	ID int `json:"id"`
+
+	// Verdict is the current outcome of judging the submission.
+	Verdict Verdict `json:"verdict"`
+
+	// Score is the total score awarded so far.
+	Score int `json:"score"`
+
+	// CPUTime is the total CPU time consumed, expressed in milliseconds.
+	CPUTime int64 `json:"cpu_time"`
+
+	// Memory is the peak memory usage, expressed in bytes.
+	Memory int64 `json:"memory"`
+
+	// UpdatedAt is the timestamp when the submission was last updated.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SubmissionVerdictRecord is an outcome a submission had before it was
+// judged again.
+type SubmissionVerdictRecord struct {
+	// ID identifies the record.
+	ID int64 `json:"id" db:"id"`
+
+	// SubmissionID identifies the submission the outcome belonged to.
+	SubmissionID int `json:"submission_id" db:"submission_id"`
+
+	// Verdict is the verdict the submission had.
+	Verdict Verdict `json:"verdict" db:"verdict"`
+
+	// Score is the score the submission had.
+	Score int `json:"score" db:"score"`
+
+	// TestsPassed is the number of test cases that passed.
+	TestsPassed int `json:"tests_passed" db:"tests_passed"`
+
+	// TestsTotal is the number of test cases judged against.
+	TestsTotal int `json:"tests_total" db:"tests_total"`
+
+	// CPUTime is the CPU time consumed, expressed in milliseconds.
+	CPUTime int64 `json:"cpu_time" db:"cpu_time"`
+
+	// Memory is the peak memory usage, expressed in bytes.
+	Memory int64 `json:"memory" db:"memory"`
+
+	// Message is the judge message that came with the verdict.
+	Message string `json:"message" db:"message"`
+
+	// JudgedAt is when the submission received the outcome.
+	JudgedAt time.Time `json:"judged_at" db:"judged_at"`
+
+	// ReplacedAt is when the outcome was replaced.
+	ReplacedAt time.Time `json:"replaced_at" db:"replaced_at"`
+}
+
 // TestcaseResult represents the result of executing a single test case
 // as part of judging a submission.
 type TestcaseResult struct {
@@ -92,11 +210,49 @@ type TestcaseResult struct {
 
 	// ErrorMessage contains runtime or system error messages, if any.
 	ErrorMessage string `json:"error_message,omitempty" db:"error_message,omitempty"`
+
+	// CheckerScore is the fraction of this test case's credit, between 0
+	// and 1, that a custom checker awarded a wrong answer. Accepted test
+	// cases always earn full credit and other verdicts none.
+	CheckerScore float64 `json:"checker_score,omitempty" db:"checker_score,omitempty"`
+
+	// CheckerMessage is the custom checker's comment on the output, if any.
+	// This field is omitted when the test case is hidden.
+	CheckerMessage string `json:"checker_message,omitempty" db:"checker_message,omitempty"`
+
+	// Diff locates the first difference between the expected and actual
+	// output of a wrong answer.
+	// This field is omitted when the test case is hidden.
+	Diff *OutputDiff `json:"diff,omitempty" db:"diff,omitempty"`
+}
+
+// OutputDiff is a bounded excerpt of where a program's output first
+// differs from the expected output, so a wrong answer can be debugged
+// without the full outputs.
+type OutputDiff struct {
+	// Line is the 1-based number of the first line that differs.
+	Line int `json:"line"`
+
+	// Column is the 1-based byte offset within Line of the first
+	// difference.
+	Column int `json:"column"`
+
+	// Expected is an excerpt of the expected line around Column. It is
+	// empty when the expected output has fewer lines.
+	Expected string `json:"expected"`
+
+	// Actual is an excerpt of the program's line around Column. It is
+	// empty when the program's output has fewer lines.
+	Actual string `json:"actual"`
 }
 
 // Language represents a supported programming language configuration
 // used by the judge system.
 type Language struct {
+	// ID is the stable identifier of the language used in submissions
+	// (e.g., "cpp", "python").
+	ID string `json:"id"`
+
 	// Name is the human-readable name of the language.
 	Name string `json:"name"`
 
@@ -111,9 +267,18 @@ type Language struct {
 	// or interpreted program.
 	ExecuteCommand string `json:"execute_command"`
 
-	// Version indicates the compiler or interpreter version.
+	// Version indicates the compiler or interpreter version. It is
+	// recorded as the dialect of submissions in the language.
 	Version string `json:"version"`
 
+	// Aliases are other names accepted for the language in submissions,
+	// such as "c++" or "python3".
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Highlight is the syntax highlighting mode for sources in the
+	// language, as named by highlight.js and Prism.
+	Highlight string `json:"highlight"`
+
 	// TimeMultiplier is a factor applied to time limits for this language.
 	TimeMultiplier float64 `json:"time_multiplier"`
 
@@ -121,9 +286,29 @@ type Language struct {
 	MemoryMultiplier float64 `json:"memory_multiplier"`
 }
 
+// ScaleLimits applies the language's multipliers to a time limit in
+// milliseconds and a memory limit in bytes, rounding up. Multipliers that
+// are not positive count as 1, and zero limits stay zero.
+func (l Language) ScaleLimits(timeLimit, memoryLimit int64) (int64, int64) {
+	return scaleLimit(timeLimit, l.TimeMultiplier), scaleLimit(memoryLimit, l.MemoryMultiplier)
+}
+
+func scaleLimit(limit int64, multiplier float64) int64 {
+	if multiplier <= 0 {
+		return limit
+	}
+	// Allow for rounding error so that, e.g., 1000 * 1.1 is 1100 rather
+	// than 1101.
+	return int64(math.Ceil(float64(limit)*multiplier - 1e-6))
+}
+
 // Verdict represents the outcome of judging a submission or test case.
 type Verdict int
 
+// ErrUnknownVerdict is returned when parsing a value that does not name a
+// defined verdict.
+var ErrUnknownVerdict = errors.New("unknown verdict")
+
 // Supported verdict values.
 const (
 	// VerdictPending indicates the submission has been received
@@ -159,6 +344,15 @@ const (
 
 	// VerdictSkipped indicates the submission or test case was skipped.
 	VerdictSkipped
+
+	// VerdictCancelled indicates the submitter cancelled the submission
+	// before judging started.
+	VerdictCancelled
+
+	// VerdictPolicyViolation indicates a static check found something the
+	// judging policy forbids, such as a forbidden system call, so the
+	// submission was not judged.
+	VerdictPolicyViolation
 )
 
 // String returns the compact string representation of the verdict
@@ -187,11 +381,84 @@ func (v Verdict) String() string {
 		return "IE"
 	case VerdictSkipped:
 		return "SKIPPED"
+	case VerdictCancelled:
+		return "CANCELLED"
+	case VerdictPolicyViolation:
+		return "POLICY_VIOLATION"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// Valid reports whether v is one of the defined verdicts.
+func (v Verdict) Valid() bool {
+	return v >= VerdictPending && v <= VerdictPolicyViolation
+}
+
+// ParseVerdict returns the verdict whose String form is s, ignoring case.
+// It returns ErrUnknownVerdict for any other value, including "UNKNOWN".
+func ParseVerdict(s string) (Verdict, error) {
+	for candidate := VerdictPending; candidate <= VerdictPolicyViolation; candidate++ {
+		if strings.EqualFold(candidate.String(), s) {
+			return candidate, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownVerdict, s)
+}
+
 func (v Verdict) MarshalJSON() ([]byte, error) {
 	return json.Marshal(v.String())
 }
+
+// UnmarshalJSON accepts the string form produced by MarshalJSON as well as
+// the numeric value. Values outside the defined verdicts are rejected with
+// ErrUnknownVerdict.
+func (v *Verdict) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		if !Verdict(n).Valid() {
+			return fmt.Errorf("%w: %d", ErrUnknownVerdict, n)
+		}
+		*v = Verdict(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid verdict: %s", data)
+	}
+	parsed, err := ParseVerdict(s)
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// JudgeQueueStats summarizes the judge backlog.
+type JudgeQueueStats struct {
+	// Pending is the number of submissions waiting to be judged.
+	Pending int `json:"pending"`
+
+	// Judging is the number of submissions currently being judged.
+	Judging int `json:"judging"`
+
+	// AvgPendingWaitSeconds is how long pending submissions have waited
+	// on average so far.
+	AvgPendingWaitSeconds float64 `json:"avg_pending_wait_seconds"`
+
+	// OldestPendingWaitSeconds is the wait of the oldest pending submission.
+	OldestPendingWaitSeconds float64 `json:"oldest_pending_wait_seconds"`
+
+	// Completed is the number of submissions that reached a final verdict
+	// within the stats window.
+	Completed int `json:"completed"`
+
+	// AvgTurnaroundSeconds is the average time from creation to final
+	// verdict for submissions completed within the stats window.
+	AvgTurnaroundSeconds float64 `json:"avg_turnaround_seconds"`
+
+	// WindowSeconds is the length of the window used for Completed and
+	// AvgTurnaroundSeconds.
+	WindowSeconds int64 `json:"window_seconds"`
+}
@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// Tenant is an isolated judge hosted by the deployment, with its own users,
+// problems and submissions. Requests select it by subdomain or by the
+// X-Tenant header.
+type Tenant struct {
+	// ID is the unique identifier of the tenant.
+	ID int `json:"id" db:"id"`
+
+	// Slug names the tenant in subdomains and the X-Tenant header.
+	Slug string `json:"slug" db:"slug"`
+
+	// Name is the tenant's display name.
+	Name string `json:"name" db:"name"`
+
+	// CreatedAt is when the tenant was created.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
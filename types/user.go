@@ -18,7 +18,7 @@ type User struct {
 	Name string `json:"name" db:"name"`
 
 	// Role indicates the user's authorization level or role
-	// within the system (e.g., "admin", "user").
+	// within the system: RoleUser, RoleSetter, RoleReviewer or RoleAdmin.
 	Role string `json:"role" db:"role"`
 
 	// PasswordHash stores the hashed representation of the user's password.
@@ -31,3 +31,20 @@ type User struct {
 	// UpdatedAt is the timestamp of the most recent update to the user account.
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
+
+// Roles a user may hold.
+const (
+	// RoleUser may submit solutions.
+	RoleUser = "user"
+
+	// RoleSetter may additionally create problems and edit the problems
+	// they own.
+	RoleSetter = "setter"
+
+	// RoleReviewer may review submitted problems.
+	RoleReviewer = "reviewer"
+
+	// RoleAdmin may edit and review every problem and administer the
+	// system.
+	RoleAdmin = "admin"
+)